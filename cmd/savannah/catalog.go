@@ -0,0 +1,101 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/Oj-washingtone/savannah-store/internal/catalog"
+	"github.com/urfave/cli/v2"
+)
+
+func seedCommand() *cli.Command {
+	return &cli.Command{
+		Name:  "seed",
+		Usage: "fill the store with a demo catalogue, adding only what is missing",
+		Action: withStore(func(c *cli.Context, s *store) error {
+			result, err := catalog.Seed(c.Context, s.repos)
+			if err != nil {
+				return err
+			}
+
+			fmt.Fprintf(c.App.Writer, "Added %d categories and %d products\n", result.Categories, result.Products)
+			return nil
+		}),
+	}
+}
+
+func catalogCommand() *cli.Command {
+	return &cli.Command{
+		Name:  "catalog",
+		Usage: "move products in and out of the store as CSV",
+		Subcommands: []*cli.Command{
+			{
+				Name:  "export",
+				Usage: "write every product as CSV",
+				Flags: []cli.Flag{
+					&cli.StringFlag{Name: "output", Aliases: []string{"o"}, Usage: "the file to write, standard output if not given"},
+				},
+				Action: withStore(func(c *cli.Context, s *store) error {
+					var out io.Writer = c.App.Writer
+
+					if path := c.String("output"); path != "" {
+						f, err := os.Create(path)
+						if err != nil {
+							return err
+						}
+						defer f.Close()
+						out = f
+					}
+
+					written, err := catalog.Export(c.Context, s.repos, out)
+					if err != nil {
+						return err
+					}
+
+					fmt.Fprintf(c.App.ErrWriter, "Exported %d products\n", written)
+					return nil
+				}),
+			},
+			{
+				Name:      "import",
+				Usage:     "add and update products from CSV in the layout export writes",
+				ArgsUsage: "[--dry-run] FILE",
+				Flags: []cli.Flag{
+					&cli.BoolFlag{Name: "dry-run", Usage: "check the file and report what would change without changing anything"},
+				},
+				Action: withStore(func(c *cli.Context, s *store) error {
+					if c.Args().Len() != 1 {
+						return fmt.Errorf("usage: catalog import [--dry-run] FILE")
+					}
+
+					f, err := os.Open(c.Args().First())
+					if err != nil {
+						return err
+					}
+					defer f.Close()
+
+					result, err := catalog.Import(c.Context, s.repos, f, c.Bool("dry-run"))
+					if err != nil {
+						return err
+					}
+
+					for _, problem := range result.Problems {
+						fmt.Fprintln(c.App.Writer, problem)
+					}
+
+					if c.Bool("dry-run") {
+						fmt.Fprintf(c.App.Writer, "Would add %d products, update %d and skip %d\n", result.Created, result.Updated, len(result.Problems))
+					} else {
+						fmt.Fprintf(c.App.Writer, "Added %d products, updated %d and skipped %d\n", result.Created, result.Updated, len(result.Problems))
+					}
+
+					if len(result.Problems) > 0 {
+						return cli.Exit("", 1)
+					}
+					return nil
+				}),
+			},
+		},
+	}
+}
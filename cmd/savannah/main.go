@@ -0,0 +1,170 @@
+// @title Savannah Store API
+// @version 1.0
+// @description This is the API documentation for Savannah Store.
+// @host savanna.apis.linxs.co.ke
+// @BasePath /api
+
+// @securityDefinitions.apikey BearerAuth
+// @in header
+// @name Authorization
+// @description Type "Bearer" followed by a space and JWT token.
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	docs "github.com/Oj-washingtone/savannah-store/docs"
+	"github.com/Oj-washingtone/savannah-store/internal/api"
+	"github.com/Oj-washingtone/savannah-store/internal/app"
+	"github.com/Oj-washingtone/savannah-store/internal/authenticator"
+	"github.com/Oj-washingtone/savannah-store/internal/database"
+	"github.com/Oj-washingtone/savannah-store/internal/middleware"
+	"github.com/Oj-washingtone/savannah-store/internal/repocitory"
+	"github.com/Oj-washingtone/savannah-store/internal/service"
+	"github.com/Oj-washingtone/savannah-store/internal/storage"
+	"github.com/gin-contrib/cors"
+	"github.com/gin-gonic/gin"
+	swaggerFiles "github.com/swaggo/files"
+	ginSwagger "github.com/swaggo/gin-swagger"
+	"github.com/urfave/cli/v2"
+)
+
+func main() {
+	app := &cli.App{
+		Name:  "savannah",
+		Usage: "run and look after the Savannah Store",
+		// without a command it serves the API, as the server always has
+		Action: serve,
+		Commands: []*cli.Command{
+			{Name: "serve", Usage: "serve the API", Action: serve},
+			migrateCommand(),
+			seedCommand(),
+			userCommand(),
+			catalogCommand(),
+			ordersCommand(),
+			notificationsCommand(),
+		},
+	}
+
+	if err := app.Run(os.Args); err != nil {
+		slog.Error(err.Error())
+		os.Exit(1)
+	}
+}
+
+// serve serves the API until it gets SIGINT or SIGTERM. It then stops
+// taking connections, lets the requests in flight and the background work
+// finish within the shutdown timeout, and closes the database pools.
+func serve(c *cli.Context) error {
+	ctx, stop := signal.NotifyContext(c.Context, syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	cfg, db, err := connect(ctx)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	slog.InfoContext(ctx, "Loaded the config", "config", cfg)
+
+	if cfg.Database.AutoMigrate {
+		if err := database.AutoMigrate(ctx, db.Pool); err != nil {
+			return fmt.Errorf("migrating the database: %w", err)
+		}
+	}
+
+	store, err := storage.Connect(cfg.Storage)
+	if err != nil {
+		return fmt.Errorf("setting up storage: %w", err)
+	}
+
+	// the composition root: everything below gets what it needs from here
+	repos := repocitory.NewRepositories(db)
+	email := service.NewResendEmailSender(cfg.Email)
+	sms := service.NewAfricasTalkingSMSSender(cfg.SMS)
+	svc := app.NewServices(cfg, repos, store, email, sms)
+	handlers := app.NewHandlers(cfg, repos, svc)
+
+	reminders := service.NewCartReminderService(repos.CartReminders, email, sms, cfg.CartReminders, cfg.Shop.StorefrontURL)
+	svc.Background.Run(reminders.Run)
+	svc.Background.Run(svc.DiscountAlerts.Run)
+
+	// gin's own route listing and access log would not be JSON, so the
+	// middleware below logs instead
+	if os.Getenv(gin.EnvGinMode) == "" {
+		gin.SetMode(gin.ReleaseMode)
+	}
+
+	router := gin.New()
+
+	router.Use(middleware.RequestID(), middleware.AccessLog(), middleware.Recovery())
+
+	router.Use(cors.New(cors.Config{
+		AllowOrigins:     []string{"http://localhost:3000", "https://savanna.apis.linxs.co.ke"},
+		AllowMethods:     []string{"GET", "POST", "PUT", "PATCH", "DELETE", "OPTIONS"},
+		AllowHeaders:     []string{"Origin", "Content-Type", "Authorization", middleware.RequestIDHeader},
+		ExposeHeaders:    []string{"Content-Length", middleware.RequestIDHeader},
+		AllowCredentials: true,
+		MaxAge:           12 * time.Hour,
+	}))
+
+	api.RegisterHealthRoutes(router, handlers.Health)
+
+	// uploads kept on the local disk are served by the app itself
+	if local, ok := store.(*storage.Local); ok {
+		router.Static("/uploads", local.Dir)
+	}
+
+	apiGroup := router.Group("/api")
+	api.AppRoutes(apiGroup, handlers, repos, authenticator.NewVerifier(cfg.Auth))
+
+	// Serve Swagger UI
+	docs.SwaggerInfo.BasePath = "/api"
+	router.GET("/swagger/*any", ginSwagger.WrapHandler(swaggerFiles.Handler))
+
+	server := &http.Server{
+		Addr:              ":" + cfg.Port,
+		Handler:           router,
+		ReadHeaderTimeout: cfg.Server.ReadHeaderTimeout,
+		ReadTimeout:       cfg.Server.ReadTimeout,
+		WriteTimeout:      cfg.Server.WriteTimeout,
+		IdleTimeout:       cfg.Server.IdleTimeout,
+	}
+
+	served := make(chan error, 1)
+	go func() {
+		slog.InfoContext(ctx, "Listening", "address", server.Addr)
+		served <- server.ListenAndServe()
+	}()
+
+	select {
+	case err = <-served:
+		err = fmt.Errorf("serving: %w", err)
+	case <-ctx.Done():
+		slog.InfoContext(ctx, "Shutting down")
+	}
+
+	// a second signal kills the server straight away
+	stop()
+
+	shutdown, cancel := context.WithTimeout(context.Background(), cfg.Server.ShutdownTimeout)
+	defer cancel()
+
+	if err := server.Shutdown(shutdown); err != nil {
+		slog.Error("Failed to finish the requests in flight", "error", err)
+	}
+
+	if err := svc.Background.Shutdown(shutdown); err != nil {
+		slog.Error("Failed to finish the background work", "error", err)
+	}
+
+	return err
+}
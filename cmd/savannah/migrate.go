@@ -0,0 +1,137 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/Oj-washingtone/savannah-store/internal/database"
+	"github.com/urfave/cli/v2"
+)
+
+func migrateCommand() *cli.Command {
+	return &cli.Command{
+		Name:  "migrate",
+		Usage: "change the database schema with the migrations built into the binary",
+		Subcommands: []*cli.Command{
+			{
+				Name:  "up",
+				Usage: "apply every migration the database does not have yet",
+				Action: withMigrator(func(c *cli.Context, m *database.Migrator) error {
+					if err := m.Up(); err != nil {
+						return err
+					}
+					return printVersion(c, m)
+				}),
+			},
+			{
+				Name:      "down",
+				Usage:     "undo the last N migrations, the last one if N is not given",
+				ArgsUsage: "[N]",
+				Action: withMigrator(func(c *cli.Context, m *database.Migrator) error {
+					steps := 1
+					if c.Args().Present() {
+						n, err := strconv.Atoi(c.Args().First())
+						if err != nil {
+							return fmt.Errorf("%q is not a number of migrations", c.Args().First())
+						}
+						steps = n
+					}
+
+					if err := m.Down(steps); err != nil {
+						return err
+					}
+					return printVersion(c, m)
+				}),
+			},
+			{
+				Name:  "status",
+				Usage: "list the migrations and which of them the database has",
+				Action: withMigrator(func(c *cli.Context, m *database.Migrator) error {
+					status, err := m.Status()
+					if err != nil {
+						return err
+					}
+
+					fmt.Fprintf(c.App.Writer, "Database is at migration %d\n", status.Version)
+					if status.Dirty {
+						fmt.Fprintf(c.App.Writer, "Migration %d failed part way. Finish or undo it by hand, then run migrate force\n", status.Version)
+					}
+
+					for _, migration := range status.Migrations {
+						state := "pending"
+						if migration.Applied {
+							state = "applied"
+						}
+						fmt.Fprintf(c.App.Writer, "  %s  %06d %s\n", state, migration.Version, migration.Name)
+					}
+
+					return nil
+				}),
+			},
+			{
+				Name:      "force",
+				Usage:     "record the database as being at VERSION without running anything, after fixing a failed migration by hand",
+				ArgsUsage: "VERSION",
+				Action: withMigrator(func(c *cli.Context, m *database.Migrator) error {
+					version, err := strconv.Atoi(c.Args().First())
+					if err != nil || c.Args().Len() != 1 {
+						return fmt.Errorf("usage: migrate force VERSION")
+					}
+
+					if err := m.Force(version); err != nil {
+						return err
+					}
+					return printVersion(c, m)
+				}),
+			},
+			{
+				Name:      "create",
+				Usage:     "add empty up and down files for a new migration",
+				ArgsUsage: "[--dir DIR] NAME",
+				Flags: []cli.Flag{
+					&cli.StringFlag{Name: "dir", Value: "migrations", Usage: "the directory the migrations are in"},
+				},
+				Action: func(c *cli.Context) error {
+					paths, err := database.CreateMigration(c.String("dir"), c.Args().First())
+					if err != nil {
+						return err
+					}
+
+					for _, path := range paths {
+						fmt.Fprintln(c.App.Writer, path)
+					}
+					return nil
+				},
+			},
+		},
+	}
+}
+
+// withMigrator runs action with a migrator for the configured database.
+func withMigrator(action func(c *cli.Context, m *database.Migrator) error) cli.ActionFunc {
+	return func(c *cli.Context) error {
+		_, db, err := connect(c.Context)
+		if err != nil {
+			return err
+		}
+		defer db.Close()
+
+		m, err := database.NewMigrator(db.Pool)
+		if err != nil {
+			return err
+		}
+		defer m.Close()
+
+		return action(c, m)
+	}
+}
+
+func printVersion(c *cli.Context, m *database.Migrator) error {
+	version, _, err := m.Version()
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintf(c.App.Writer, "Database is at migration %d\n", version)
+	return nil
+}
@@ -0,0 +1,42 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/Oj-washingtone/savannah-store/internal/service"
+	"github.com/urfave/cli/v2"
+)
+
+func notificationsCommand() *cli.Command {
+	return &cli.Command{
+		Name:  "notifications",
+		Usage: "look after the emails and text messages the store sends",
+		Subcommands: []*cli.Command{
+			{
+				Name: "retry",
+				Usage: fmt.Sprintf("send the notifications that failed again, oldest first, giving up on each after %d attempts",
+					service.MaxSendAttempts),
+				Flags: []cli.Flag{
+					&cli.IntFlag{Name: "limit", Value: 100, Usage: "how many to send at most"},
+				},
+				Action: withStore(func(c *cli.Context, s *store) error {
+					if c.Int("limit") < 1 {
+						return fmt.Errorf("the limit must be at least 1")
+					}
+
+					sent, failed, err := s.services.Notifications.RetryFailed(c.Context, c.Int("limit"))
+					if err != nil {
+						return err
+					}
+
+					fmt.Fprintf(c.App.Writer, "Sent %d notifications, %d failed again\n", sent, failed)
+
+					if failed > 0 {
+						return cli.Exit("", 1)
+					}
+					return nil
+				}),
+			},
+		},
+	}
+}
@@ -0,0 +1,130 @@
+package main
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/Oj-washingtone/savannah-store/internal/service"
+	"github.com/google/uuid"
+	"github.com/urfave/cli/v2"
+)
+
+func ordersCommand() *cli.Command {
+	return &cli.Command{
+		Name:  "orders",
+		Usage: "look after orders",
+		Subcommands: []*cli.Command{
+			{
+				Name: "reconcile-payments",
+				Usage: "mark orders paid from a CSV of payments with the columns reference, order and amount, " +
+					"for payments whose callback never reached the store",
+				ArgsUsage: "FILE",
+				Action: withStore(func(c *cli.Context, s *store) error {
+					if c.Args().Len() != 1 {
+						return fmt.Errorf("usage: orders reconcile-payments FILE")
+					}
+
+					f, err := os.Open(c.Args().First())
+					if err != nil {
+						return err
+					}
+					defer f.Close()
+
+					payments, err := readPayments(f)
+					if err != nil {
+						return err
+					}
+
+					outcomes := map[service.PaymentOutcome]int{}
+
+					for _, payment := range payments {
+						outcome, err := s.services.Orders.ReconcilePayment(c.Context, payment)
+						if err != nil {
+							return err
+						}
+						outcomes[outcome]++
+
+						if outcome != service.PaymentRecorded && outcome != service.PaymentAlreadyRecorded {
+							fmt.Fprintf(c.App.Writer, "%s: %s, order %s, Ksh.%d\n", outcome, payment.Reference, payment.OrderID, payment.Amount)
+						}
+					}
+
+					recorded, already := outcomes[service.PaymentRecorded], outcomes[service.PaymentAlreadyRecorded]
+					fmt.Fprintf(c.App.Writer, "Recorded %d payments, %d were already recorded and %d need looking into\n",
+						recorded, already, len(payments)-recorded-already)
+
+					if len(payments) > recorded+already {
+						return cli.Exit("", 1)
+					}
+					return nil
+				}),
+			},
+		},
+	}
+}
+
+// readPayments reads the payments CSV. The header names the columns, which
+// may come in any order and alongside others, as payment provider exports
+// have plenty.
+func readPayments(r io.Reader) ([]service.Payment, error) {
+	in := csv.NewReader(r)
+	in.FieldsPerRecord = -1
+
+	header, err := in.Read()
+	if err != nil {
+		return nil, fmt.Errorf("reading the header: %w", err)
+	}
+
+	columns := map[string]int{}
+	for i, name := range header {
+		columns[strings.ToLower(strings.TrimSpace(strings.TrimPrefix(name, "\ufeff")))] = i
+	}
+
+	for _, required := range []string{"reference", "order", "amount"} {
+		if _, ok := columns[required]; !ok {
+			return nil, fmt.Errorf("the %s column is missing", required)
+		}
+	}
+
+	var payments []service.Payment
+
+	for {
+		record, err := in.Read()
+		if errors.Is(err, io.EOF) {
+			return payments, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		line, _ := in.FieldPos(0)
+
+		cell := func(name string) string {
+			if i := columns[name]; i < len(record) {
+				return strings.TrimSpace(record[i])
+			}
+			return ""
+		}
+
+		payment := service.Payment{Reference: cell("reference")}
+
+		if payment.Reference == "" {
+			return nil, fmt.Errorf("line %d: the reference is empty", line)
+		}
+
+		if payment.OrderID, err = uuid.Parse(cell("order")); err != nil {
+			return nil, fmt.Errorf("line %d: %q is not an order ID", line, cell("order"))
+		}
+
+		if payment.Amount, err = strconv.ParseInt(cell("amount"), 10, 64); err != nil {
+			return nil, fmt.Errorf("line %d: %q is not a whole number of shillings", line, cell("amount"))
+		}
+
+		payments = append(payments, payment)
+	}
+}
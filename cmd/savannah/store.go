@@ -0,0 +1,70 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+
+	"github.com/Oj-washingtone/savannah-store/internal/app"
+	"github.com/Oj-washingtone/savannah-store/internal/config"
+	"github.com/Oj-washingtone/savannah-store/internal/database"
+	"github.com/Oj-washingtone/savannah-store/internal/logging"
+	"github.com/Oj-washingtone/savannah-store/internal/repocitory"
+	"github.com/Oj-washingtone/savannah-store/internal/service"
+	"github.com/Oj-washingtone/savannah-store/internal/storage"
+	"github.com/urfave/cli/v2"
+)
+
+// store is the store as the ops commands see it, built from the same config
+// and packages as the server so they follow the same rules.
+type store struct {
+	cfg      *config.Config
+	repos    *repocitory.Repositories
+	services *app.Services
+}
+
+// connect loads the config, sets up the log and connects to the database.
+// The caller closes the connection.
+func connect(ctx context.Context) (*config.Config, *database.DB, error) {
+	cfg, err := config.Load()
+	if err != nil {
+		return nil, nil, fmt.Errorf("loading the config: %w", err)
+	}
+
+	// the log goes to stderr, leaving stdout to the commands' output
+	slog.SetDefault(logging.New(cfg.Logging, os.Stderr))
+
+	db, err := database.ConnectDB(ctx, cfg.Database)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return cfg, db, nil
+}
+
+// withStore runs action against the configured store.
+func withStore(action func(c *cli.Context, s *store) error) cli.ActionFunc {
+	return func(c *cli.Context) error {
+		cfg, db, err := connect(c.Context)
+		if err != nil {
+			return err
+		}
+		defer db.Close()
+
+		files, err := storage.Connect(cfg.Storage)
+		if err != nil {
+			return fmt.Errorf("setting up storage: %w", err)
+		}
+
+		repos := repocitory.NewRepositories(db)
+		email := service.NewResendEmailSender(cfg.Email)
+		sms := service.NewAfricasTalkingSMSSender(cfg.SMS)
+
+		return action(c, &store{
+			cfg:      cfg,
+			repos:    repos,
+			services: app.NewServices(cfg, repos, files, email, sms),
+		})
+	}
+}
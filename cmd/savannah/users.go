@@ -0,0 +1,59 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/Oj-washingtone/savannah-store/internal/model"
+	"github.com/jackc/pgx/v5"
+	"github.com/urfave/cli/v2"
+)
+
+func userCommand() *cli.Command {
+	return &cli.Command{
+		Name:  "user",
+		Usage: "manage user accounts",
+		Subcommands: []*cli.Command{
+			{
+				Name:      "promote",
+				Usage:     "give the user with EMAIL the ROLE customer, admin or super_admin",
+				ArgsUsage: "EMAIL ROLE",
+				Action: withStore(func(c *cli.Context, s *store) error {
+					if c.Args().Len() != 2 {
+						return fmt.Errorf("usage: user promote EMAIL ROLE")
+					}
+
+					email := strings.TrimSpace(c.Args().Get(0))
+					role := model.Roles(c.Args().Get(1))
+
+					switch role {
+					case model.CustomerRole, model.AdminRole, model.SuperAdminRole:
+					default:
+						return fmt.Errorf("%q is not a role, the roles are customer, admin and super_admin", role)
+					}
+
+					user, err := s.repos.Users.GetByEmail(c.Context, email)
+					if errors.Is(err, pgx.ErrNoRows) {
+						return fmt.Errorf("there is no user with the email %s, they have to sign in once first", email)
+					}
+					if err != nil {
+						return err
+					}
+
+					if user.Role == role {
+						fmt.Fprintf(c.App.Writer, "%s is already %s\n", user.Email, role)
+						return nil
+					}
+
+					if err := s.repos.Users.UpdateRole(c.Context, user.ID, role); err != nil {
+						return err
+					}
+
+					fmt.Fprintf(c.App.Writer, "%s is now %s, was %s\n", user.Email, role, user.Role)
+					return nil
+				}),
+			},
+		},
+	}
+}
@@ -1,54 +0,0 @@
-// @title Savannah Store API
-// @version 1.0
-// @description This is the API documentation for Savannah Store.
-// @host savanna.apis.linxs.co.ke
-// @BasePath /api
-
-// @securityDefinitions.apikey BearerAuth
-// @in header
-// @name Authorization
-// @description Type "Bearer" followed by a space and JWT token.
-
-package main
-
-import (
-	"log"
-	"time"
-
-	docs "github.com/Oj-washingtone/savannah-store/docs"
-	"github.com/Oj-washingtone/savannah-store/internal/api"
-	"github.com/Oj-washingtone/savannah-store/internal/database"
-	"github.com/gin-contrib/cors"
-	"github.com/gin-gonic/gin"
-	"github.com/joho/godotenv"
-	swaggerFiles "github.com/swaggo/files"
-	ginSwagger "github.com/swaggo/gin-swagger"
-)
-
-func main() {
-
-	if err := godotenv.Load(); err != nil {
-		log.Fatalf("Failed to load the env vars: %v", err)
-	}
-
-	database.ConnectDB()
-	router := gin.Default()
-
-	router.Use(cors.New(cors.Config{
-		AllowOrigins:     []string{"http://localhost:3000", "https://savanna.apis.linxs.co.ke"},
-		AllowMethods:     []string{"GET", "POST", "PUT", "DELETE", "OPTIONS"},
-		AllowHeaders:     []string{"Origin", "Content-Type", "Authorization"},
-		ExposeHeaders:    []string{"Content-Length"},
-		AllowCredentials: true,
-		MaxAge:           12 * time.Hour,
-	}))
-
-	apiGroup := router.Group("/api")
-	api.AppRoutes(apiGroup)
-
-	// Serve Swagger UI
-	docs.SwaggerInfo.BasePath = "/api"
-	router.GET("/swagger/*any", ginSwagger.WrapHandler(swaggerFiles.Handler))
-
-	router.Run(":8080")
-}
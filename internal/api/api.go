@@ -1,12 +1,51 @@
 package api
 
 import (
+	"github.com/Oj-washingtone/savannah-store/internal/handlers"
+	"github.com/Oj-washingtone/savannah-store/internal/middleware"
+	"github.com/Oj-washingtone/savannah-store/internal/model"
+	"github.com/Oj-washingtone/savannah-store/internal/repocitory"
+	"github.com/Oj-washingtone/savannah-store/internal/validation"
+	"github.com/coreos/go-oidc/v3/oidc"
 	"github.com/gin-gonic/gin"
 )
 
-func AppRoutes(router *gin.RouterGroup) {
-	RegisterAuthRoutes(router)
-	RegisterProductRoutes(router)
-	RegisterCartRoutes(router)
-	RegisterOrdersRoutes(router)
+// Handlers are the handlers the routes are served by. app.NewHandlers
+// builds them.
+type Handlers struct {
+	Auth       *handlers.AuthHandler
+	Products   *handlers.ProductHandler
+	Categories *handlers.CategoryHandler
+	Images     *handlers.ProductImageHandler
+	Variants   *handlers.VariantHandler
+	Cart       *handlers.CartHandler
+	Orders     *handlers.OrderHandler
+	Me         *handlers.MeHandler
+	Wishlists  *handlers.WishlistHandler
+	Promotions *handlers.PromotionHandler
+	Discounts  *handlers.DiscountHandler
+	Health     *handlers.HealthHandler
+}
+
+// AppRoutes registers the API under router. verifier checks the bearer
+// tokens of signed in users, and repos backs the middleware's role checks
+// and the exists validation tag.
+func AppRoutes(router *gin.RouterGroup, h *Handlers, repos *repocitory.Repositories, verifier *oidc.IDTokenVerifier) {
+	// the request bodies' binding rules use the custom validation tags
+	validation.Register(repos.Lookups())
+
+	// handlers report failures with c.Error and this renders them
+	router.Use(middleware.ErrorHandler())
+
+	auth := middleware.AuthMiddleware(verifier)
+	admin := middleware.RequireRole(repos.Users, model.AdminRole, model.SuperAdminRole)
+
+	RegisterAuthRoutes(router, h.Auth)
+	RegisterProductRoutes(router, h, auth, admin)
+	RegisterCartRoutes(router, h.Cart, auth)
+	RegisterOrdersRoutes(router, h.Orders, auth, admin)
+	RegisterMeRoutes(router, h.Me, h.Orders, auth)
+	RegisterWishlistRoutes(router, h.Wishlists, auth)
+	RegisterPromotionRoutes(router, h.Promotions, auth, admin)
+	RegisterDiscountRoutes(router, h.Discounts, auth, admin)
 }
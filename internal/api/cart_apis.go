@@ -2,19 +2,20 @@ package api
 
 import (
 	"github.com/Oj-washingtone/savannah-store/internal/handlers"
-	"github.com/Oj-washingtone/savannah-store/internal/middleware"
 	"github.com/gin-gonic/gin"
 )
 
-func RegisterCartRoutes(router *gin.RouterGroup) {
+func RegisterCartRoutes(router *gin.RouterGroup, h *handlers.CartHandler, auth gin.HandlerFunc) {
 	cart := router.Group("/cart")
 
-	cart.Use(middleware.AuthMiddleware())
+	cart.Use(auth)
 
 	{
-		cart.POST("/create", handlers.AddToCart)
-		cart.DELETE("/remove/:id", handlers.RemoveFromCart)
-		cart.GET("/", handlers.GetCartItems)
-		cart.PATCH("/update/quantity/:id", handlers.UpdateQuantity)
+		cart.POST("/create", h.AddToCart)
+		cart.DELETE("/remove/:id", h.RemoveFromCart)
+		cart.GET("/", h.GetCartItems)
+		cart.PATCH("/update/quantity/:id", h.UpdateQuantity)
+		cart.POST("/coupons", h.ApplyCoupon)
+		cart.DELETE("/coupons/:code", h.RemoveCoupon)
 	}
 }
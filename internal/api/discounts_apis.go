@@ -0,0 +1,20 @@
+package api
+
+import (
+	"github.com/Oj-washingtone/savannah-store/internal/handlers"
+	"github.com/gin-gonic/gin"
+)
+
+func RegisterDiscountRoutes(router *gin.RouterGroup, h *handlers.DiscountHandler, auth, admin gin.HandlerFunc) {
+	discounts := router.Group("/discounts")
+
+	discounts.Use(auth, admin)
+
+	{
+		discounts.POST("/", h.CreateDiscount)
+		discounts.GET("/", h.ListDiscounts)
+		discounts.GET("/:id", h.GetDiscount)
+		discounts.PATCH("/:id", h.UpdateDiscount)
+		discounts.DELETE("/:id", h.DeleteDiscount)
+	}
+}
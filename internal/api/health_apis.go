@@ -0,0 +1,13 @@
+package api
+
+import (
+	"github.com/Oj-washingtone/savannah-store/internal/handlers"
+	"github.com/gin-gonic/gin"
+)
+
+// RegisterHealthRoutes registers the probes at the root of the server, where
+// orchestrators expect them, rather than under /api.
+func RegisterHealthRoutes(router gin.IRoutes, h *handlers.HealthHandler) {
+	router.GET("/healthz", h.Live)
+	router.GET("/readyz", h.Ready)
+}
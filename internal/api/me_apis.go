@@ -0,0 +1,18 @@
+package api
+
+import (
+	"github.com/Oj-washingtone/savannah-store/internal/handlers"
+	"github.com/gin-gonic/gin"
+)
+
+func RegisterMeRoutes(router *gin.RouterGroup, h *handlers.MeHandler, orders *handlers.OrderHandler, auth gin.HandlerFunc) {
+	me := router.Group("/me")
+
+	me.Use(auth)
+
+	{
+		me.PATCH("/preferences", h.UpdatePreferences)
+		me.PATCH("/phone", h.UpdatePhone)
+		me.GET("/orders/:id/invoice.pdf", orders.GetOrderInvoice)
+	}
+}
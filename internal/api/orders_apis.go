@@ -2,15 +2,15 @@ package api
 
 import (
 	"github.com/Oj-washingtone/savannah-store/internal/handlers"
-	"github.com/Oj-washingtone/savannah-store/internal/middleware"
 	"github.com/gin-gonic/gin"
 )
 
-func RegisterOrdersRoutes(router *gin.RouterGroup) {
+func RegisterOrdersRoutes(router *gin.RouterGroup, h *handlers.OrderHandler, auth, admin gin.HandlerFunc) {
 	orders := router.Group("/orders")
 
 	{
-		orders.POST("/create", middleware.AuthMiddleware(), handlers.CreateOrder)
-		orders.GET("/", handlers.GetAllOrders)
+		orders.POST("/create", auth, h.CreateOrder)
+		orders.GET("/", h.GetAllOrders)
+		orders.POST("/:id/payments", auth, admin, h.MarkOrderPaid)
 	}
 }
@@ -1,26 +1,65 @@
 package api
 
 import (
-	"github.com/Oj-washingtone/savannah-store/internal/handlers"
 	"github.com/gin-gonic/gin"
 )
 
-func RegisterProductRoutes(router *gin.RouterGroup) {
+func RegisterProductRoutes(router *gin.RouterGroup, h *Handlers, auth, admin gin.HandlerFunc) {
 	products := router.Group("/products")
 
 	{
 		// product categories
 
-		products.POST("/categories/create", handlers.AddProductCategory)
-		products.GET("/categories", handlers.ListCategories)
-		products.PATCH("/categories/:id", handlers.UpdateCategory)
+		products.POST("/categories/create", h.Categories.AddProductCategory)
+		products.GET("/categories", h.Categories.ListCategories)
+		products.GET("/categories/tree", h.Categories.GetCategoryTree)
+		products.GET("/categories/:id", h.Categories.GetCategory)
+		products.GET("/categories/:id/products", h.Categories.ListCategoryProducts)
+		products.GET("/categories/slug/:slug", h.Categories.GetCategoryBySlug)
+		products.PATCH("/categories/:id", h.Categories.UpdateCategory)
+		products.DELETE("/categories/:id",
+			auth,
+			admin,
+			h.Categories.DeleteCategory,
+		)
 
 		// products
-		products.POST("/create", handlers.CreateProduct)
-		products.GET("/:id", handlers.GetProductById)
-		products.GET("/", handlers.ListProducts)
-		products.PATCH("/:id", handlers.UpdateProduct)
-		products.DELETE("/:id", handlers.DeleteProduct)
+		products.POST("/create", h.Products.CreateProduct)
+		products.GET("/search", h.Products.SearchProducts)
+		products.GET("/by-slug/:slug", h.Products.GetProductBySlug)
+		products.GET("/by-sku/:sku", h.Products.GetProductBySKU)
+		products.GET("/:id", h.Products.GetProductById)
+		products.GET("/", h.Products.ListProducts)
+		products.PATCH("/:id", h.Products.UpdateProduct)
+		products.DELETE("/:id", h.Products.DeleteProduct)
+
+		// product images
+		products.GET("/:id/images", h.Images.ListProductImages)
+
+		images := products.Group("/:id/images")
+		images.Use(auth, admin)
+
+		images.POST("", h.Images.UploadProductImage)
+		images.PUT("/order", h.Images.ReorderProductImages)
+		images.PATCH("/:imageId", h.Images.UpdateProductImage)
+		images.DELETE("/:imageId", h.Images.DeleteProductImage)
+
+		// product options and variants
+		products.GET("/:id/variants", h.Variants.ListProductVariants)
+
+		options := products.Group("/:id/options")
+		options.Use(auth, admin)
+
+		options.POST("", h.Variants.CreateProductOption)
+		options.POST("/:optionId/values", h.Variants.AddProductOptionValue)
+		options.DELETE("/:optionId", h.Variants.DeleteProductOption)
+
+		variants := products.Group("/:id/variants")
+		variants.Use(auth, admin)
+
+		variants.POST("", h.Variants.CreateProductVariant)
+		variants.PATCH("/:variantId", h.Variants.UpdateProductVariant)
+		variants.DELETE("/:variantId", h.Variants.DeleteProductVariant)
 
 	}
 }
@@ -0,0 +1,20 @@
+package api
+
+import (
+	"github.com/Oj-washingtone/savannah-store/internal/handlers"
+	"github.com/gin-gonic/gin"
+)
+
+func RegisterPromotionRoutes(router *gin.RouterGroup, h *handlers.PromotionHandler, auth, admin gin.HandlerFunc) {
+	promotions := router.Group("/promotions")
+
+	promotions.Use(auth, admin)
+
+	{
+		promotions.POST("/", h.CreatePromotion)
+		promotions.GET("/", h.ListPromotions)
+		promotions.GET("/:id", h.GetPromotion)
+		promotions.PATCH("/:id", h.UpdatePromotion)
+		promotions.DELETE("/:id", h.DeletePromotion)
+	}
+}
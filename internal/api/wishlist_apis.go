@@ -0,0 +1,27 @@
+package api
+
+import (
+	"github.com/Oj-washingtone/savannah-store/internal/handlers"
+	"github.com/gin-gonic/gin"
+)
+
+func RegisterWishlistRoutes(router *gin.RouterGroup, h *handlers.WishlistHandler, auth gin.HandlerFunc) {
+	wishlists := router.Group("/wishlists")
+
+	{
+		wishlists.GET("/shared/:token", h.GetSharedWishlist)
+	}
+
+	wishlists.Use(auth)
+
+	{
+		wishlists.GET("/", h.ListWishlists)
+		wishlists.POST("/", h.CreateWishlist)
+		wishlists.GET("/:id", h.GetWishlist)
+		wishlists.PATCH("/:id", h.UpdateWishlist)
+		wishlists.DELETE("/:id", h.DeleteWishlist)
+		wishlists.POST("/:id/items", h.AddToWishlist)
+		wishlists.DELETE("/:id/items/:productId", h.RemoveFromWishlist)
+		wishlists.POST("/:id/items/:productId/move-to-cart", h.MoveWishlistItemToCart)
+	}
+}
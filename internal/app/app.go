@@ -0,0 +1,70 @@
+// Package app wires the repositories, services and handlers together. The
+// server and the end to end tests build the store the same way through it.
+package app
+
+import (
+	"github.com/Oj-washingtone/savannah-store/internal/api"
+	"github.com/Oj-washingtone/savannah-store/internal/config"
+	"github.com/Oj-washingtone/savannah-store/internal/handlers"
+	"github.com/Oj-washingtone/savannah-store/internal/repocitory"
+	"github.com/Oj-washingtone/savannah-store/internal/service"
+	"github.com/Oj-washingtone/savannah-store/internal/storage"
+)
+
+// Services are the business logic the handlers and background workers share.
+type Services struct {
+	Products       *service.ProductService
+	Discounts      *service.DiscountService
+	Categories     *service.CategoryService
+	Pricing        *service.PricingService
+	Invoices       *service.InvoiceService
+	Notifications  *service.NotificationService
+	DiscountAlerts *service.DiscountAlertService
+	Orders         *service.OrderService
+	Health         *service.HealthService
+
+	// Background is the work started by requests that carries on after
+	// them, which shutting down waits for
+	Background *service.Background
+}
+
+// NewServices builds the services on top of the repositories, sending
+// notifications with email and sms.
+func NewServices(cfg *config.Config, repos *repocitory.Repositories, store storage.Storage, email service.EmailSender, sms service.SMSSender) *Services {
+	pricing := service.NewPricingService(repos.Products, repos.Variants, repos.Promotions, cfg.Shop.PricesIncludeTax)
+	invoices := service.NewInvoiceService(repos, cfg.Shop.Business)
+	notifications := service.NewNotificationService(repos, email, sms, invoices, cfg.Shop)
+	background := service.NewBackground()
+	products := service.NewProductService(repos, store, notifications, background, cfg.Shop.ProductImageMaxBytes)
+
+	return &Services{
+		Products:       products,
+		Discounts:      service.NewDiscountService(repos.Discounts, products),
+		Categories:     service.NewCategoryService(repos.Categories),
+		Pricing:        pricing,
+		Invoices:       invoices,
+		Notifications:  notifications,
+		DiscountAlerts: service.NewDiscountAlertService(repos.Discounts, products, notifications),
+		Orders:         service.NewOrderService(repos, pricing, notifications, background),
+		Health:         service.NewHealthService(repos),
+		Background:     background,
+	}
+}
+
+// NewHandlers builds the HTTP handlers.
+func NewHandlers(cfg *config.Config, repos *repocitory.Repositories, svc *Services) *api.Handlers {
+	return &api.Handlers{
+		Auth:       handlers.NewAuthHandler(repos.Users, cfg.Auth),
+		Products:   handlers.NewProductHandler(repos, svc.Products),
+		Categories: handlers.NewCategoryHandler(repos, svc.Categories, svc.Products),
+		Images:     handlers.NewProductImageHandler(repos, svc.Products),
+		Variants:   handlers.NewVariantHandler(repos, svc.Products),
+		Cart:       handlers.NewCartHandler(repos, svc.Pricing, svc.Products),
+		Orders:     handlers.NewOrderHandler(repos, svc.Orders, svc.Invoices),
+		Me:         handlers.NewMeHandler(repos.Users),
+		Wishlists:  handlers.NewWishlistHandler(repos, svc.Products),
+		Promotions: handlers.NewPromotionHandler(repos.Promotions),
+		Discounts:  handlers.NewDiscountHandler(repos, svc.Discounts),
+		Health:     handlers.NewHealthHandler(svc.Health),
+	}
+}
@@ -0,0 +1,144 @@
+// Package apperr defines the errors the store's layers hand up to the HTTP
+// layer. An Error has a Kind saying what went wrong in terms a client can act
+// on, and a message that is safe to show it. The error that caused it is kept
+// for logs and errors.Is, but never shown.
+package apperr
+
+import (
+	"errors"
+	"net/http"
+)
+
+type Kind int
+
+const (
+	// KindInternal is anything unexpected. Its details are never shown.
+	KindInternal Kind = iota
+	// KindNotFound means the thing asked for does not exist.
+	KindNotFound
+	// KindConflict means the request clashes with the current state, such as
+	// a name already in use.
+	KindConflict
+	// KindValidation means the request itself is wrong.
+	KindValidation
+	// KindForbidden means the caller may not do this.
+	KindForbidden
+	// KindUnavailable means a dependency such as the database is down or too
+	// slow, and trying again later may work.
+	KindUnavailable
+	// KindUnsupportedMedia means an uploaded file is of a type that is not
+	// accepted.
+	KindUnsupportedMedia
+)
+
+// Status is the HTTP status for errors of the kind.
+func (k Kind) Status() int {
+	switch k {
+	case KindNotFound:
+		return http.StatusNotFound
+	case KindConflict:
+		return http.StatusConflict
+	case KindValidation:
+		return http.StatusBadRequest
+	case KindForbidden:
+		return http.StatusForbidden
+	case KindUnavailable:
+		return http.StatusServiceUnavailable
+	case KindUnsupportedMedia:
+		return http.StatusUnsupportedMediaType
+	}
+
+	return http.StatusInternalServerError
+}
+
+// String is the kind's name as shown to clients in the error field.
+func (k Kind) String() string {
+	switch k {
+	case KindNotFound:
+		return "not_found"
+	case KindConflict:
+		return "conflict"
+	case KindValidation:
+		return "validation"
+	case KindForbidden:
+		return "forbidden"
+	case KindUnavailable:
+		return "unavailable"
+	case KindUnsupportedMedia:
+		return "unsupported_media"
+	}
+
+	return "internal"
+}
+
+type Error struct {
+	Kind    Kind
+	Message string
+	Err     error
+}
+
+func (e *Error) Error() string {
+	if e.Err != nil {
+		return e.Message + ": " + e.Err.Error()
+	}
+	return e.Message
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// New makes an error of the kind. Package level sentinel errors are made
+// with New too, so errors.Is still finds them.
+func New(kind Kind, message string) *Error {
+	return &Error{Kind: kind, Message: message}
+}
+
+// Wrap makes an error of the kind caused by err.
+func Wrap(kind Kind, message string, err error) *Error {
+	return &Error{Kind: kind, Message: message, Err: err}
+}
+
+func NotFound(message string) *Error {
+	return New(KindNotFound, message)
+}
+
+func Conflict(message string) *Error {
+	return New(KindConflict, message)
+}
+
+func Validation(message string) *Error {
+	return New(KindValidation, message)
+}
+
+func Forbidden(message string) *Error {
+	return New(KindForbidden, message)
+}
+
+func UnsupportedMedia(message string) *Error {
+	return New(KindUnsupportedMedia, message)
+}
+
+func Unavailable(message string, err error) *Error {
+	return Wrap(KindUnavailable, message, err)
+}
+
+// KindOf gives the kind of the first Error in err's chain, KindInternal if
+// there is none.
+func KindOf(err error) Kind {
+	var e *Error
+	if errors.As(err, &e) {
+		return e.Kind
+	}
+	return KindInternal
+}
+
+// Public gives the message that may be shown for err. Internal errors get a
+// fixed message, so nothing about the cause leaks.
+func Public(err error) string {
+	var e *Error
+	if errors.As(err, &e) && e.Kind != KindInternal {
+		return e.Message
+	}
+	return "Something went wrong, please try again later"
+}
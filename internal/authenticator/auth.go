@@ -3,8 +3,8 @@ package authenticator
 import (
 	"context"
 	"errors"
-	"os"
 
+	"github.com/Oj-washingtone/savannah-store/internal/config"
 	"github.com/coreos/go-oidc/v3/oidc"
 	"golang.org/x/oauth2"
 )
@@ -15,20 +15,17 @@ type Authenticator struct {
 	oauth2.Config
 }
 
-func New() (*Authenticator, error) {
-	provider, err := oidc.NewProvider(
-		context.Background(),
-		"https://"+os.Getenv("AUTH0_DOMAIN")+"/",
-	)
+func New(cfg config.Auth) (*Authenticator, error) {
+	provider, err := oidc.NewProvider(context.Background(), issuer(cfg))
 
 	if err != nil {
 		return nil, err
 	}
 
 	conf := oauth2.Config{
-		ClientID:     os.Getenv("AUTH0_CLIENT_ID"),
-		ClientSecret: os.Getenv("AUTH0_CLIENT_SECRET"),
-		RedirectURL:  os.Getenv("AUTH0_CALLBACK_URL"),
+		ClientID:     cfg.ClientID,
+		ClientSecret: string(cfg.ClientSecret),
+		RedirectURL:  cfg.CallbackURL,
 		Endpoint:     provider.Endpoint(),
 		Scopes:       []string{oidc.ScopeOpenID, "profile", "email", "role"},
 	}
@@ -40,6 +37,20 @@ func New() (*Authenticator, error) {
 
 }
 
+// NewVerifier checks ID tokens issued by the Auth0 tenant for the app's
+// client. The tenant's signing keys are fetched when the first token is
+// checked, so making one needs no network.
+func NewVerifier(cfg config.Auth) *oidc.IDTokenVerifier {
+	keys := oidc.NewRemoteKeySet(context.Background(), issuer(cfg)+".well-known/jwks.json")
+
+	return oidc.NewVerifier(issuer(cfg), keys, &oidc.Config{ClientID: cfg.ClientID})
+}
+
+// issuer is the URL of the Auth0 tenant, as it appears in its tokens.
+func issuer(cfg config.Auth) string {
+	return "https://" + cfg.Domain + "/"
+}
+
 // VerifyIDToken verifies that an *oauth2.Token is a valid *oidc.IDToken.
 
 func (a *Authenticator) VerifyIDToken(ctx context.Context, token *oauth2.Token) (*oidc.IDToken, error) {
@@ -0,0 +1,52 @@
+// Package catalog moves the product catalogue in and out of the store in
+// bulk: CSV export and import, for editing it in a spreadsheet, and a demo
+// catalogue to fill a new database with.
+package catalog
+
+import (
+	"context"
+	"strings"
+
+	"github.com/Oj-washingtone/savannah-store/internal/model"
+	"github.com/Oj-washingtone/savannah-store/internal/repocitory"
+	"github.com/Oj-washingtone/savannah-store/internal/slug"
+)
+
+// Columns are the CSV columns, in the order Export writes them. category is
+// the slug of the product's category.
+var Columns = []string{"sku", "slug", "name", "category", "description", "price", "stock", "tax_class", "meta_title", "meta_description"}
+
+const (
+	maxSlugLength = 120
+	maxSKULength  = 64
+	maxNameLength = 100
+)
+
+// productSlug makes a free slug from the product's name.
+func productSlug(ctx context.Context, repos *repocitory.Repositories, product *model.Product) (string, error) {
+	base := slug.Make(product.Name)
+	if base == "" {
+		base = "product"
+	}
+
+	return slug.Unique(ctx, base, func(ctx context.Context, s string) (bool, error) {
+		return repos.Products.SlugTaken(ctx, s, product.ID)
+	})
+}
+
+func validTaxClass(class model.TaxClass) bool {
+	switch class {
+	case model.TaxStandard, model.TaxZeroRated, model.TaxExempt:
+		return true
+	}
+	return false
+}
+
+// clean trims a cell, giving nil for an empty one.
+func clean(s string) *string {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return nil
+	}
+	return &s
+}
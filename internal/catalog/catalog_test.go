@@ -0,0 +1,106 @@
+package catalog_test
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/Oj-washingtone/savannah-store/internal/catalog"
+	"github.com/Oj-washingtone/savannah-store/internal/repocitory/memory"
+)
+
+func TestSeedExportImport(t *testing.T) {
+	ctx := context.Background()
+	repos := memory.NewRepositories()
+
+	seeded, err := catalog.Seed(ctx, repos)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if seeded.Categories == 0 || seeded.Products == 0 {
+		t.Fatalf("seeding an empty store added %+v", seeded)
+	}
+
+	again, err := catalog.Seed(ctx, repos)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if again.Categories != 0 || again.Products != 0 {
+		t.Fatalf("seeding again added %+v", again)
+	}
+
+	kiondo, err := repos.Products.GetBySKU(ctx, "BSK-KIONDO-L")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if kiondo.Stock != 66 {
+		t.Errorf("the kiondo has %d in stock over its sizes, want 66", kiondo.Stock)
+	}
+
+	var exported bytes.Buffer
+	written, err := catalog.Export(ctx, repos, &exported)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if written != seeded.Products {
+		t.Fatalf("exported %d products, want %d", written, seeded.Products)
+	}
+
+	result, err := catalog.Import(ctx, repos, &exported, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if result.Created != 0 || result.Updated != written || len(result.Problems) != 0 {
+		t.Fatalf("importing the export gave %+v", result)
+	}
+
+	csv := strings.Join([]string{
+		"SKU,Name,Category,Price,Stock",
+		"PAN-TEA-KER,Kericho Gold Tea,pantry,350,90",
+		"PAN-CHAPATI,Chapati Flour,pantry,190,",
+		"PAN-SUGAR,Sugar,groceries,200,",
+		"PAN-SALT,Salt,pantry,free,",
+	}, "\n")
+
+	dryRun, err := catalog.Import(ctx, repos, strings.NewReader(csv), true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if dryRun.Created != 1 || dryRun.Updated != 1 || len(dryRun.Problems) != 2 {
+		t.Fatalf("the dry run gave %+v", dryRun)
+	}
+	if _, err := repos.Products.GetBySKU(ctx, "PAN-CHAPATI"); err == nil {
+		t.Fatal("the dry run added a product")
+	}
+
+	if _, err := catalog.Import(ctx, repos, strings.NewReader(csv), false); err != nil {
+		t.Fatal(err)
+	}
+
+	tea, err := repos.Products.GetBySKU(ctx, "PAN-TEA-KER")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tea.Price != 350 || tea.Stock != 90 || tea.Description == "" {
+		t.Errorf("the tea is %d shillings with %d in stock and description %q", tea.Price, tea.Stock, tea.Description)
+	}
+
+	if _, err := repos.Products.GetBySKU(ctx, "PAN-CHAPATI"); err != nil {
+		t.Errorf("the chapati flour was not added: %v", err)
+	}
+
+	restock := "sku,name,category,price,stock\nBSK-KIONDO,Sisal Kiondo,baskets,1200,10\n"
+	result, err = catalog.Import(ctx, repos, strings.NewReader(restock), false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(result.Problems) != 1 {
+		t.Errorf("setting the stock of a product with variants gave %+v", result)
+	}
+
+	if _, err := catalog.Import(ctx, repos, strings.NewReader("sku,colour\n"), false); err == nil {
+		t.Error("an unknown column was accepted")
+	}
+}
@@ -0,0 +1,77 @@
+package catalog
+
+import (
+	"context"
+	"encoding/csv"
+	"io"
+	"strconv"
+
+	"github.com/Oj-washingtone/savannah-store/internal/pagination"
+	"github.com/Oj-washingtone/savannah-store/internal/repocitory"
+	"github.com/google/uuid"
+)
+
+// Export writes every live product to w as CSV with a header of Columns,
+// newest first, and returns how many it wrote. Stock is the total over the
+// product's variants.
+func Export(ctx context.Context, repos *repocitory.Repositories, w io.Writer) (int, error) {
+	categories, err := repos.Categories.ListAll(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	categorySlugs := map[uuid.UUID]string{}
+	for _, c := range categories {
+		categorySlugs[c.ID] = c.Slug
+	}
+
+	out := csv.NewWriter(w)
+	if err := out.Write(Columns); err != nil {
+		return 0, err
+	}
+
+	written := 0
+	page := pagination.Page{Limit: 100}
+
+	for {
+		products, info, err := repos.Products.List(ctx, page)
+		if err != nil {
+			return written, err
+		}
+
+		for _, p := range products {
+			sku := ""
+			if p.SKU != nil {
+				sku = *p.SKU
+			}
+
+			err := out.Write([]string{
+				sku,
+				p.Slug,
+				p.Name,
+				categorySlugs[p.CategoryID],
+				p.Description,
+				strconv.FormatInt(p.Price, 10),
+				strconv.Itoa(p.Stock),
+				string(p.TaxClass),
+				p.MetaTitle,
+				p.MetaDescription,
+			})
+			if err != nil {
+				return written, err
+			}
+			written++
+		}
+
+		if info.NextCursor == nil {
+			break
+		}
+
+		if page.Cursor, err = pagination.Decode(*info.NextCursor); err != nil {
+			return written, err
+		}
+	}
+
+	out.Flush()
+	return written, out.Error()
+}
@@ -0,0 +1,295 @@
+package catalog
+
+import (
+	"context"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/Oj-washingtone/savannah-store/internal/model"
+	"github.com/Oj-washingtone/savannah-store/internal/repocitory"
+	"github.com/Oj-washingtone/savannah-store/internal/service"
+	"github.com/Oj-washingtone/savannah-store/internal/slug"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+)
+
+// ImportResult is what Import did, or would have done on a dry run.
+type ImportResult struct {
+	Created int
+	Updated int
+
+	// Problems are the rows that were left out and why
+	Problems []string
+}
+
+// rowError is a problem with a row, which leaves the row out of the import
+// but lets the rest go ahead.
+type rowError string
+
+func (e rowError) Error() string {
+	return string(e)
+}
+
+// Import adds and updates products from CSV in the layout Export writes.
+// Rows are matched to products by SKU, then by slug, and rows matching
+// neither are added. name, category and price are required. Other columns
+// may be left out of the file, which leaves them as they are; an empty stock
+// or tax_class cell does the same. Stock can only be changed on products
+// without variants.
+//
+// A bad row is reported in the result and skipped. The error is for
+// anything that stops the import as a whole, such as unreadable CSV or the
+// database going away. With dryRun nothing is written.
+func Import(ctx context.Context, repos *repocitory.Repositories, r io.Reader, dryRun bool) (*ImportResult, error) {
+	in := csv.NewReader(r)
+	in.FieldsPerRecord = -1
+
+	header, err := in.Read()
+	if err != nil {
+		return nil, fmt.Errorf("reading the header: %w", err)
+	}
+
+	columns := map[string]int{}
+	for i, name := range header {
+		name = strings.ToLower(strings.TrimSpace(strings.TrimPrefix(name, "\ufeff")))
+		if !slices.Contains(Columns, name) {
+			return nil, fmt.Errorf("unknown column %q, the columns are %s", name, strings.Join(Columns, ", "))
+		}
+		columns[name] = i
+	}
+
+	for _, required := range []string{"name", "category", "price"} {
+		if _, ok := columns[required]; !ok {
+			return nil, fmt.Errorf("the %s column is required", required)
+		}
+	}
+
+	result := &ImportResult{}
+
+	for {
+		record, err := in.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return result, err
+		}
+
+		line, _ := in.FieldPos(0)
+
+		row := map[string]string{}
+		for name, i := range columns {
+			if i < len(record) {
+				row[name] = record[i]
+			}
+		}
+
+		created, err := importRow(ctx, repos, row, dryRun)
+
+		var problem rowError
+		switch {
+		case errors.As(err, &problem):
+			result.Problems = append(result.Problems, fmt.Sprintf("line %d: %s", line, problem))
+		case err != nil:
+			return result, fmt.Errorf("line %d: %w", line, err)
+		case created:
+			result.Created++
+		default:
+			result.Updated++
+		}
+	}
+
+	return result, nil
+}
+
+// importRow adds or updates the product in one row, reporting whether it
+// was added. Cells of columns missing from the file are not in row.
+func importRow(ctx context.Context, repos *repocitory.Repositories, row map[string]string, dryRun bool) (bool, error) {
+	name := strings.TrimSpace(row["name"])
+	if name == "" || utf8.RuneCountInString(name) > maxNameLength {
+		return false, rowError(fmt.Sprintf("name must be 1 to %d characters", maxNameLength))
+	}
+
+	price, err := strconv.ParseInt(strings.TrimSpace(row["price"]), 10, 64)
+	if err != nil || price <= 0 {
+		return false, rowError(fmt.Sprintf("price %q is not a whole number of shillings above 0", row["price"]))
+	}
+
+	categorySlug := strings.TrimSpace(row["category"])
+	category, err := repos.Categories.GetBySlug(ctx, categorySlug)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return false, rowError(fmt.Sprintf("there is no category with the slug %q", categorySlug))
+	}
+	if err != nil {
+		return false, err
+	}
+
+	var stock *int
+	if s := clean(row["stock"]); s != nil {
+		n, err := strconv.Atoi(*s)
+		if err != nil || n < 0 {
+			return false, rowError(fmt.Sprintf("stock %q is not a whole number of 0 or more", *s))
+		}
+		stock = &n
+	}
+
+	var taxClass *model.TaxClass
+	if s := clean(row["tax_class"]); s != nil {
+		class := model.TaxClass(*s)
+		if !validTaxClass(class) {
+			return false, rowError(fmt.Sprintf("tax_class %q is not standard, zero_rated or exempt", *s))
+		}
+		taxClass = &class
+	}
+
+	sku := clean(row["sku"])
+	if sku != nil && len(*sku) > maxSKULength {
+		return false, rowError(fmt.Sprintf("sku can be at most %d characters", maxSKULength))
+	}
+
+	requestedSlug := clean(row["slug"])
+	if requestedSlug != nil && (!slug.Valid(*requestedSlug) || len(*requestedSlug) > maxSlugLength) {
+		return false, rowError(fmt.Sprintf("slug %q must be lowercase letters and digits joined by single dashes, at most %d characters", *requestedSlug, maxSlugLength))
+	}
+
+	product, err := findProduct(ctx, repos, sku, requestedSlug)
+	if err != nil {
+		return false, err
+	}
+
+	created := product == nil
+
+	if created {
+		product = &model.Product{TaxClass: model.TaxStandard}
+		product.ID = uuid.New()
+	}
+
+	product.Name = name
+	product.Price = price
+	product.CategoryID = category.ID
+
+	if description, ok := row["description"]; ok {
+		product.Description = strings.TrimSpace(description)
+	}
+
+	if taxClass != nil {
+		product.TaxClass = *taxClass
+	}
+
+	if metaTitle, ok := row["meta_title"]; ok {
+		product.MetaTitle = strings.TrimSpace(metaTitle)
+	}
+
+	if metaDescription, ok := row["meta_description"]; ok {
+		product.MetaDescription = strings.TrimSpace(metaDescription)
+	}
+
+	if utf8.RuneCountInString(product.MetaTitle) > model.MaxMetaTitleLength ||
+		utf8.RuneCountInString(product.MetaDescription) > model.MaxMetaDescriptionLength {
+		return false, rowError(fmt.Sprintf("meta_title and meta_description can be at most %d and %d characters", model.MaxMetaTitleLength, model.MaxMetaDescriptionLength))
+	}
+
+	if sku != nil && (product.SKU == nil || *product.SKU != *sku) {
+		taken, err := repos.Products.SKUTaken(ctx, *sku, product.ID)
+		if err != nil {
+			return false, err
+		}
+		if taken {
+			return false, rowError("another product or variant already has the SKU " + *sku)
+		}
+		product.SKU = sku
+	}
+
+	switch {
+	case requestedSlug != nil && *requestedSlug != product.Slug:
+		taken, err := repos.Products.SlugTaken(ctx, *requestedSlug, product.ID)
+		if err != nil {
+			return false, err
+		}
+		if taken {
+			return false, rowError("another product already has the slug " + *requestedSlug)
+		}
+		product.Slug = *requestedSlug
+
+	case created:
+		if product.Slug, err = productSlug(ctx, repos, product); err != nil {
+			return false, err
+		}
+	}
+
+	if created {
+		if stock != nil {
+			product.Stock = *stock
+		}
+
+		if dryRun {
+			return true, nil
+		}
+		return true, repos.Products.Create(ctx, product)
+	}
+
+	// stock lives on the variants, a simple product's single variant takes
+	// the new stock. An unchanged total, as Export writes it, is left alone
+	// so exported products with variants import again.
+	var stockVariant *model.ProductVariant
+
+	if stock != nil && *stock != product.Stock {
+		variants, err := repos.Variants.ListByProduct(ctx, product.ID)
+		if err != nil {
+			return false, err
+		}
+
+		if !service.IsSimpleProduct(variants) {
+			return false, rowError(product.Name + " has variants, set the stock on each variant instead")
+		}
+
+		stockVariant = variants[0]
+		stockVariant.Stock = *stock
+	}
+
+	if dryRun {
+		return false, nil
+	}
+
+	if stockVariant != nil {
+		return false, repos.Products.UpdateWithStock(ctx, product, stockVariant)
+	}
+
+	return false, repos.Products.Update(ctx, product)
+}
+
+// findProduct looks up the product a row is about by its SKU and then its
+// slug, giving nil if there is none.
+func findProduct(ctx context.Context, repos *repocitory.Repositories, sku, productSlug *string) (*model.Product, error) {
+	if sku != nil {
+		product, err := repos.Products.GetBySKU(ctx, *sku)
+
+		switch {
+		case err == nil && (product.SKU == nil || *product.SKU != *sku):
+			return nil, rowError(fmt.Sprintf("the SKU %s belongs to a variant of %s, not a product", *sku, product.Name))
+		case err == nil:
+			return product, nil
+		case !errors.Is(err, pgx.ErrNoRows):
+			return nil, err
+		}
+	}
+
+	if productSlug != nil {
+		product, err := repos.Products.GetBySlug(ctx, *productSlug)
+
+		switch {
+		case err == nil:
+			return product, nil
+		case !errors.Is(err, pgx.ErrNoRows):
+			return nil, err
+		}
+	}
+
+	return nil, nil
+}
@@ -0,0 +1,241 @@
+package catalog
+
+import (
+	"context"
+	"errors"
+
+	"github.com/Oj-washingtone/savannah-store/internal/model"
+	"github.com/Oj-washingtone/savannah-store/internal/repocitory"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+)
+
+// SeedResult counts what Seed added.
+type SeedResult struct {
+	Categories int
+	Products   int
+}
+
+type demoCategory struct {
+	slug   string
+	name   string
+	parent string
+}
+
+type demoVariant struct {
+	value string
+	sku   string
+	price int64
+	stock int
+}
+
+type demoProduct struct {
+	sku         string
+	name        string
+	category    string
+	description string
+	price       int64
+	stock       int
+	taxClass    model.TaxClass
+
+	// option is the name of the product's one option, its variants hold
+	// the stock instead of stock
+	option   string
+	variants []demoVariant
+}
+
+// Parents come before their subcategories.
+var demoCategories = []demoCategory{
+	{slug: "kitchen", name: "Kitchen"},
+	{slug: "home-decor", name: "Home Decor"},
+	{slug: "baskets", name: "Baskets", parent: "home-decor"},
+	{slug: "fashion", name: "Fashion"},
+	{slug: "kikoi-kanga", name: "Kikoi & Kanga", parent: "fashion"},
+	{slug: "sandals", name: "Sandals", parent: "fashion"},
+	{slug: "pantry", name: "Pantry"},
+}
+
+var demoProducts = []demoProduct{
+	{
+		sku: "KIT-JIKO-01", name: "Kenya Ceramic Jiko", category: "kitchen", price: 1800, stock: 40,
+		description: "Charcoal stove with a ceramic liner that burns a third less charcoal than an all-metal jiko.",
+	},
+	{
+		sku: "KIT-SUF-05", name: "Aluminium Sufuria Set", category: "kitchen", price: 3200, stock: 25,
+		description: "Five nesting sufurias with lids, from a 1 litre chai pot to a 7 litre pot for ugali.",
+	},
+	{
+		sku: "KIT-MWIKO-01", name: "Olive Wood Mwiko", category: "kitchen", price: 450, stock: 120,
+		description: "Hand-carved cooking stick for stirring ugali without scratching the sufuria.",
+	},
+	{
+		sku: "DEC-KISII-01", name: "Kisii Soapstone Bowl", category: "home-decor", price: 1500, stock: 18,
+		description: "Carved and polished soapstone bowl from Tabaka, about 15 cm across.",
+	},
+	{
+		sku: "BSK-KIONDO", name: "Sisal Kiondo", category: "baskets", price: 1200,
+		description: "Woven sisal basket with leather handles, made by women's groups in Machakos.",
+		option:      "Size",
+		variants: []demoVariant{
+			{value: "S", sku: "BSK-KIONDO-S", price: 1200, stock: 30},
+			{value: "M", sku: "BSK-KIONDO-M", price: 1600, stock: 24},
+			{value: "L", sku: "BSK-KIONDO-L", price: 2100, stock: 12},
+		},
+	},
+	{
+		sku: "FSH-KANGA-01", name: "Printed Kanga", category: "kikoi-kanga", price: 650, stock: 80,
+		description: "Pair of cotton kangas printed with a Swahili proverb along the border.",
+	},
+	{
+		sku: "FSH-SHUKA-01", name: "Maasai Shuka", category: "kikoi-kanga", price: 900, stock: 60,
+		description: "Red checked acrylic shuka, warm enough for a night in Naivasha.",
+	},
+	{
+		sku: "FSH-KIKOI", name: "Cotton Kikoi", category: "kikoi-kanga", price: 1100,
+		description: "Striped cotton kikoi woven in Lamu, with tasselled ends.",
+		option:      "Colour",
+		variants: []demoVariant{
+			{value: "Blue", sku: "FSH-KIKOI-BLU", stock: 35},
+			{value: "Orange", sku: "FSH-KIKOI-ORG", stock: 28},
+			{value: "Green", sku: "FSH-KIKOI-GRN", stock: 20},
+		},
+	},
+	{
+		sku: "FSH-AKALA", name: "Akala Tyre Sandals", category: "sandals", price: 700,
+		description: "Sandals with soles cut from old car tyres and beaded leather straps.",
+		option:      "Size",
+		variants: []demoVariant{
+			{value: "40", sku: "FSH-AKALA-40", stock: 15},
+			{value: "42", sku: "FSH-AKALA-42", stock: 22},
+			{value: "44", sku: "FSH-AKALA-44", stock: 10},
+		},
+	},
+	{
+		sku: "PAN-COFFEE-AA", name: "Kiambu AA Coffee, 500 g", category: "pantry", price: 1350, stock: 70,
+		description: "Medium roast whole beans of the AA grade from estates in Kiambu.",
+	},
+	{
+		sku: "PAN-TEA-KER", name: "Kericho Gold Tea, 250 g", category: "pantry", price: 320, stock: 150,
+		description: "Loose black tea from the Kericho highlands, strong enough for chai.",
+	},
+	{
+		sku: "PAN-UNGA-2KG", name: "Sifted Maize Flour, 2 kg", category: "pantry", price: 210, stock: 200,
+		description: "Sifted white maize meal for ugali.", taxClass: model.TaxZeroRated,
+	},
+}
+
+// Seed fills the store with a demo catalogue of Kenyan homeware, clothing
+// and food, with categories, subcategories and some products that come in
+// sizes or colours. Categories are matched by slug and products by SKU, so
+// seeding again only adds what is missing.
+func Seed(ctx context.Context, repos *repocitory.Repositories) (*SeedResult, error) {
+	result := &SeedResult{}
+	categoryIds := map[string]uuid.UUID{}
+
+	for _, demo := range demoCategories {
+		category, err := repos.Categories.GetBySlug(ctx, demo.slug)
+
+		if errors.Is(err, pgx.ErrNoRows) {
+			category = &model.ProductCategory{Name: demo.name, Slug: demo.slug}
+			category.ID = uuid.New()
+
+			if demo.parent != "" {
+				parentId := categoryIds[demo.parent]
+				category.ParentId = &parentId
+			}
+
+			err = repos.Categories.Create(ctx, category)
+			result.Categories++
+		}
+
+		if err != nil {
+			return result, err
+		}
+
+		categoryIds[demo.slug] = category.ID
+	}
+
+	for _, demo := range demoProducts {
+		_, err := repos.Products.GetBySKU(ctx, demo.sku)
+
+		if err == nil {
+			continue
+		}
+
+		if !errors.Is(err, pgx.ErrNoRows) {
+			return result, err
+		}
+
+		if err := seedProduct(ctx, repos, demo, categoryIds[demo.category]); err != nil {
+			return result, err
+		}
+
+		result.Products++
+	}
+
+	return result, nil
+}
+
+func seedProduct(ctx context.Context, repos *repocitory.Repositories, demo demoProduct, categoryId uuid.UUID) error {
+	sku := demo.sku
+
+	product := &model.Product{
+		CategoryID:  categoryId,
+		Name:        demo.name,
+		SKU:         &sku,
+		Description: demo.description,
+		Price:       demo.price,
+		Stock:       demo.stock,
+		TaxClass:    model.TaxStandard,
+	}
+	product.ID = uuid.New()
+
+	if demo.taxClass != "" {
+		product.TaxClass = demo.taxClass
+	}
+
+	var err error
+	if product.Slug, err = productSlug(ctx, repos, product); err != nil {
+		return err
+	}
+
+	if err := repos.Products.Create(ctx, product); err != nil {
+		return err
+	}
+
+	if demo.option == "" {
+		return nil
+	}
+
+	option := &model.ProductOption{ProductID: product.ID, Name: demo.option}
+	option.ID = uuid.New()
+
+	for i, v := range demo.variants {
+		option.Values = append(option.Values, &model.ProductOptionValue{
+			ID:       uuid.New(),
+			OptionID: option.ID,
+			Value:    v.value,
+			Position: i,
+		})
+	}
+
+	if err := repos.Variants.CreateOption(ctx, option); err != nil {
+		return err
+	}
+
+	// the first variant retires the default one Products.Create made
+	for i, v := range demo.variants {
+		variant := &model.ProductVariant{ProductID: product.ID, SKU: &v.sku, Stock: v.stock}
+		variant.ID = uuid.New()
+
+		if v.price != 0 && v.price != demo.price {
+			variant.Price = &v.price
+		}
+
+		if err := repos.Variants.Create(ctx, variant, []uuid.UUID{option.Values[i].ID}); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
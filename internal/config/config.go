@@ -1 +1,176 @@
+// Package config holds the settings of the store. Load reads them once at
+// startup and main hands each subsystem the part it needs, so nothing else
+// reads the environment.
 package config
+
+import "time"
+
+// Config is every setting of the store.
+//
+// Each setting is read from the environment variable in its env tag, which
+// may also be set in a .env file, or from the key in its yaml tag in the file
+// named by CONFIG_FILE. The environment wins over .env, which wins over the
+// YAML file, which wins over the default tag. Settings tagged required must
+// be set one way or another.
+type Config struct {
+	Port string `env:"PORT" yaml:"port" default:"8080"`
+
+	Server        Server        `yaml:"server"`
+	Logging       Logging       `yaml:"logging"`
+	Database      Database      `yaml:"database"`
+	Auth          Auth          `yaml:"auth"`
+	Email         Email         `yaml:"email"`
+	SMS           SMS           `yaml:"sms"`
+	Storage       Storage       `yaml:"storage"`
+	CartReminders CartReminders `yaml:"cartReminders"`
+	Shop          Shop          `yaml:"shop"`
+}
+
+// Server is how long the HTTP server gives clients and, when it is told to
+// stop, the requests and background work still running.
+type Server struct {
+	ReadHeaderTimeout time.Duration `env:"SERVER_READ_HEADER_TIMEOUT" yaml:"readHeaderTimeout" default:"5s"`
+
+	// ReadTimeout covers the whole request body, which for an image upload
+	// can be several megabytes
+	ReadTimeout  time.Duration `env:"SERVER_READ_TIMEOUT" yaml:"readTimeout" default:"30s"`
+	WriteTimeout time.Duration `env:"SERVER_WRITE_TIMEOUT" yaml:"writeTimeout" default:"60s"`
+	IdleTimeout  time.Duration `env:"SERVER_IDLE_TIMEOUT" yaml:"idleTimeout" default:"120s"`
+
+	// ShutdownTimeout is how long a stopping server waits for requests and
+	// background work to finish. Keep it below the orchestrator's grace
+	// period, or the server is killed before it is done.
+	ShutdownTimeout time.Duration `env:"SERVER_SHUTDOWN_TIMEOUT" yaml:"shutdownTimeout" default:"25s"`
+}
+
+// Logging is how much the store logs and in what form.
+type Logging struct {
+	// Level is the least severe level logged: debug, info, warn or error.
+	// Debug adds every database query and health probe.
+	Level string `env:"LOG_LEVEL" yaml:"level" default:"info"`
+
+	// Format is json, for log collectors, or text, for reading in a terminal
+	Format string `env:"LOG_FORMAT" yaml:"format" default:"json"`
+}
+
+// Database is where the Postgres database is.
+type Database struct {
+	Host     string `env:"DB_HOST" yaml:"host" required:"true"`
+	Port     string `env:"DB_PORT" yaml:"port" default:"5432"`
+	User     string `env:"DB_USER" yaml:"user" required:"true"`
+	Password Secret `env:"DB_PASSWORD" yaml:"password"`
+	Name     string `env:"DB_NAME" yaml:"name" required:"true"`
+
+	// SSLMode is how the connection uses TLS, as in libpq: disable, allow,
+	// prefer, require, verify-ca or verify-full. The verify modes check the
+	// server's certificate against SSLRootCert, a CA file.
+	SSLMode     string `env:"DB_SSLMODE" yaml:"sslMode" default:"prefer"`
+	SSLRootCert string `env:"DB_SSLROOTCERT" yaml:"sslRootCert"`
+
+	// the size of each pool and how long its connections are kept
+	MaxConns        int           `env:"DB_MAX_CONNS" yaml:"maxConns" default:"10"`
+	MinConns        int           `env:"DB_MIN_CONNS" yaml:"minConns" default:"0"`
+	MaxConnLifetime time.Duration `env:"DB_MAX_CONN_LIFETIME" yaml:"maxConnLifetime" default:"1h"`
+	MaxConnIdleTime time.Duration `env:"DB_MAX_CONN_IDLE_TIME" yaml:"maxConnIdleTime" default:"30m"`
+
+	// ConnectTimeout bounds each attempt at opening a connection.
+	// ConnectRetryFor is how long the first connection is retried at
+	// startup, for a database that is still coming up.
+	ConnectTimeout  time.Duration `env:"DB_CONNECT_TIMEOUT" yaml:"connectTimeout" default:"5s"`
+	ConnectRetryFor time.Duration `env:"DB_CONNECT_RETRY_FOR" yaml:"connectRetryFor" default:"1m"`
+
+	// StatementTimeout makes Postgres cancel queries that run longer, 0 for
+	// no limit. Migrations are not held to it.
+	StatementTimeout time.Duration `env:"DB_STATEMENT_TIMEOUT" yaml:"statementTimeout" default:"30s"`
+
+	// ReplicaHost is a read replica for product listing, search and other
+	// heavy reads, reached with the same user, database and settings. Without
+	// one everything goes to the primary.
+	ReplicaHost string `env:"DB_REPLICA_HOST" yaml:"replicaHost"`
+	ReplicaPort string `env:"DB_REPLICA_PORT" yaml:"replicaPort" default:"5432"`
+
+	// AutoMigrate applies any migrations the database is missing when the
+	// server starts
+	AutoMigrate bool `env:"DB_AUTO_MIGRATE" yaml:"autoMigrate"`
+}
+
+// Auth is the Auth0 application users sign in through.
+type Auth struct {
+	Domain       string `env:"AUTH0_DOMAIN" yaml:"domain" required:"true"`
+	ClientID     string `env:"AUTH0_CLIENT_ID" yaml:"clientId" required:"true"`
+	ClientSecret Secret `env:"AUTH0_CLIENT_SECRET" yaml:"clientSecret"`
+	CallbackURL  string `env:"AUTH0_CALLBACK_URL" yaml:"callbackUrl"`
+}
+
+// Email is the Resend account emails are sent through. Without a key no
+// email can be sent.
+type Email struct {
+	ResendKey Secret `env:"RESEND_KEY" yaml:"resendKey"`
+	From      string `env:"EMAIL_FROM" yaml:"from" default:"orders@linxs.co.ke"`
+}
+
+// SMS is the Africa's Talking account text messages are sent through.
+// Without a username and API key no SMS can be sent.
+type SMS struct {
+	Username string `env:"AFRICASTALKING_USERNAME" yaml:"username"`
+	APIKey   Secret `env:"AFRICASTALKING_API_KEY" yaml:"apiKey"`
+	URL      string `env:"AFRICASTALKING_URL" yaml:"url" default:"https://api.africastalking.com/version1/messaging"`
+}
+
+// Storage is where uploaded files are kept, see package storage.
+type Storage struct {
+	// Driver is "local" or "s3"
+	Driver string `env:"STORAGE_DRIVER" yaml:"driver" default:"local"`
+
+	LocalDir string `env:"STORAGE_LOCAL_DIR" yaml:"localDir" default:"uploads"`
+
+	// PublicURL is the base address files are served from. The local driver
+	// defaults to /uploads and S3 to the bucket on its endpoint.
+	PublicURL string `env:"STORAGE_PUBLIC_URL" yaml:"publicUrl"`
+
+	S3 S3 `yaml:"s3"`
+}
+
+// S3 is the S3-compatible bucket used by the s3 storage driver.
+type S3 struct {
+	Endpoint  string `env:"S3_ENDPOINT" yaml:"endpoint"`
+	Region    string `env:"S3_REGION" yaml:"region"`
+	Bucket    string `env:"S3_BUCKET" yaml:"bucket"`
+	AccessKey string `env:"S3_ACCESS_KEY" yaml:"accessKey"`
+	SecretKey Secret `env:"S3_SECRET_KEY" yaml:"secretKey"`
+	UseSSL    bool   `env:"S3_USE_SSL" yaml:"useSsl" default:"true"`
+}
+
+// CartReminders is when customers are reminded of carts they left behind.
+type CartReminders struct {
+	Enabled   bool          `env:"CART_REMINDERS_ENABLED" yaml:"enabled"`
+	After     time.Duration `env:"CART_REMINDER_AFTER" yaml:"after" default:"24h"`
+	Interval  time.Duration `env:"CART_REMINDER_INTERVAL" yaml:"interval" default:"1h"`
+	BatchSize int           `env:"CART_REMINDER_BATCH_SIZE" yaml:"batchSize" default:"100"`
+}
+
+// Shop is how the shop presents itself and prices its goods.
+type Shop struct {
+	// StorefrontURL is the customer facing site the links in emails and SMS
+	// point at
+	StorefrontURL string `env:"STOREFRONT_URL" yaml:"storefrontUrl" default:"http://localhost:3000"`
+
+	// AdminEmail gets a copy of every new order
+	AdminEmail string `env:"ADMIN_EMAIL" yaml:"adminEmail"`
+
+	// PricesIncludeTax is whether catalogue prices already contain VAT
+	PricesIncludeTax bool `env:"PRICES_INCLUDE_TAX" yaml:"pricesIncludeTax" default:"true"`
+
+	ProductImageMaxBytes int64 `env:"PRODUCT_IMAGE_MAX_BYTES" yaml:"productImageMaxBytes" default:"5242880"`
+
+	Business Business `yaml:"business"`
+}
+
+// Business is the seller printed on invoices.
+type Business struct {
+	Name    string `env:"BUSINESS_NAME" yaml:"name" default:"Savannah Store"`
+	Address string `env:"BUSINESS_ADDRESS" yaml:"address"`
+	Phone   string `env:"BUSINESS_PHONE" yaml:"phone"`
+	Email   string `env:"BUSINESS_EMAIL" yaml:"email"`
+	KRAPin  string `env:"BUSINESS_KRA_PIN" yaml:"kraPin"`
+}
@@ -0,0 +1,273 @@
+package config
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"log/slog"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/joho/godotenv"
+	"gopkg.in/yaml.v3"
+)
+
+// Error lists everything wrong with the config, so it can all be fixed in
+// one go.
+type Error struct {
+	Problems []string
+}
+
+func (e *Error) Error() string {
+	return "invalid config:\n  " + strings.Join(e.Problems, "\n  ")
+}
+
+// Load reads the config from the environment, a .env file in the working
+// directory if there is one, and the YAML file named by CONFIG_FILE if it is
+// set. It returns an *Error if a setting is missing or malformed.
+func Load() (*Config, error) {
+	if err := godotenv.Load(); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return nil, fmt.Errorf("reading .env: %w", err)
+	}
+
+	cfg := Defaults()
+
+	if path := os.Getenv("CONFIG_FILE"); path != "" {
+		if err := readYAML(path, cfg); err != nil {
+			return nil, fmt.Errorf("reading %s: %w", path, err)
+		}
+	}
+
+	var problems []string
+
+	for _, s := range settings(cfg) {
+		raw := os.Getenv(s.env)
+		if raw == "" {
+			continue
+		}
+
+		if err := parse(s.value, raw); err != nil {
+			problems = append(problems, fmt.Sprintf("%s: %q %s", s.env, raw, err))
+		}
+	}
+
+	cfg.normalize()
+	problems = append(problems, cfg.validate()...)
+
+	if len(problems) > 0 {
+		return nil, &Error{Problems: problems}
+	}
+
+	return cfg, nil
+}
+
+// Defaults returns the config with every setting at its default, as Load
+// starts from.
+func Defaults() *Config {
+	cfg := &Config{}
+
+	for _, s := range settings(cfg) {
+		if def, ok := s.field.Tag.Lookup("default"); ok {
+			if err := parse(s.value, def); err != nil {
+				panic(fmt.Sprintf("config: bad default for %s: %v", s.env, err))
+			}
+		}
+	}
+
+	return cfg
+}
+
+// String lists the settings as NAME=value lines, with secrets redacted.
+func (c *Config) String() string {
+	var b strings.Builder
+
+	for _, s := range settings(c) {
+		fmt.Fprintf(&b, "%s=%v\n", s.env, s.value.Interface())
+	}
+
+	return b.String()
+}
+
+// LogValue logs the settings as a group of NAME=value pairs, with secrets
+// redacted.
+func (c *Config) LogValue() slog.Value {
+	var attrs []slog.Attr
+
+	for _, s := range settings(c) {
+		attrs = append(attrs, slog.String(s.env, fmt.Sprint(s.value.Interface())))
+	}
+
+	return slog.GroupValue(attrs...)
+}
+
+func readYAML(path string, cfg *Config) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+
+	if err := decoder.Decode(cfg); err != nil && !errors.Is(err, io.EOF) {
+		return err
+	}
+
+	return nil
+}
+
+// normalize fills in the defaults that depend on other settings and tidies
+// values that are written more than one way.
+func (c *Config) normalize() {
+	if c.Storage.Driver == "local" && c.Storage.PublicURL == "" {
+		c.Storage.PublicURL = "/uploads"
+	}
+
+	c.Shop.StorefrontURL = strings.TrimRight(c.Shop.StorefrontURL, "/")
+}
+
+// validate checks what the tags cannot say. It only reports, the config is
+// left as it is.
+func (c *Config) validate() []string {
+	var problems []string
+
+	for _, s := range settings(c) {
+		if s.field.Tag.Get("required") == "true" && s.value.IsZero() {
+			problems = append(problems, s.env+" is required")
+		}
+	}
+
+	if port, err := strconv.Atoi(c.Port); err != nil || port < 1 || port > 65535 {
+		problems = append(problems, fmt.Sprintf("PORT: %q is not a port number", c.Port))
+	}
+
+	switch strings.ToLower(c.Logging.Level) {
+	case "debug", "info", "warn", "error":
+	default:
+		problems = append(problems, fmt.Sprintf("LOG_LEVEL: %q is not debug, info, warn or error", c.Logging.Level))
+	}
+
+	switch strings.ToLower(c.Logging.Format) {
+	case "json", "text":
+	default:
+		problems = append(problems, fmt.Sprintf("LOG_FORMAT: %q is not json or text", c.Logging.Format))
+	}
+
+	switch c.Database.SSLMode {
+	case "disable", "allow", "prefer", "require", "verify-ca", "verify-full":
+	default:
+		problems = append(problems, fmt.Sprintf("DB_SSLMODE: %q is not disable, allow, prefer, require, verify-ca or verify-full", c.Database.SSLMode))
+	}
+
+	if c.Database.MaxConns < 1 || c.Database.MinConns < 0 || c.Database.MinConns > c.Database.MaxConns {
+		problems = append(problems, "DB_MAX_CONNS must be at least 1 and DB_MIN_CONNS between 0 and DB_MAX_CONNS")
+	}
+
+	if c.Database.ConnectTimeout <= 0 || c.Database.ConnectRetryFor < 0 || c.Database.StatementTimeout < 0 ||
+		c.Database.MaxConnLifetime <= 0 || c.Database.MaxConnIdleTime <= 0 {
+		problems = append(problems, "DB_CONNECT_TIMEOUT, DB_MAX_CONN_LIFETIME and DB_MAX_CONN_IDLE_TIME must be positive, "+
+			"DB_CONNECT_RETRY_FOR and DB_STATEMENT_TIMEOUT 0 or more")
+	}
+
+	if c.Server.ReadHeaderTimeout <= 0 || c.Server.ReadTimeout <= 0 || c.Server.WriteTimeout <= 0 ||
+		c.Server.IdleTimeout <= 0 || c.Server.ShutdownTimeout <= 0 {
+		problems = append(problems, "the SERVER_*_TIMEOUT settings must be positive")
+	}
+
+	switch c.Storage.Driver {
+	case "local":
+	case "s3":
+		if c.Storage.S3.Endpoint == "" || c.Storage.S3.Bucket == "" {
+			problems = append(problems, "S3_ENDPOINT and S3_BUCKET are required when STORAGE_DRIVER is s3")
+		}
+	default:
+		problems = append(problems, fmt.Sprintf("STORAGE_DRIVER: %q is not local or s3", c.Storage.Driver))
+	}
+
+	if (c.SMS.Username == "") != (c.SMS.APIKey == "") {
+		problems = append(problems, "AFRICASTALKING_USERNAME and AFRICASTALKING_API_KEY must be set together")
+	}
+
+	if c.CartReminders.After <= 0 || c.CartReminders.Interval <= 0 {
+		problems = append(problems, "CART_REMINDER_AFTER and CART_REMINDER_INTERVAL must be positive")
+	}
+
+	if c.CartReminders.BatchSize <= 0 {
+		problems = append(problems, "CART_REMINDER_BATCH_SIZE must be positive")
+	}
+
+	if c.Shop.ProductImageMaxBytes <= 0 {
+		problems = append(problems, "PRODUCT_IMAGE_MAX_BYTES must be positive")
+	}
+
+	return problems
+}
+
+// setting is one field of the config that holds a value.
+type setting struct {
+	env   string
+	field reflect.StructField
+	value reflect.Value
+}
+
+// settings lists the settings of cfg in the order they are declared.
+func settings(cfg *Config) []setting {
+	var list []setting
+
+	var walk func(v reflect.Value)
+	walk = func(v reflect.Value) {
+		for i := 0; i < v.NumField(); i++ {
+			field := v.Type().Field(i)
+
+			if env, ok := field.Tag.Lookup("env"); ok {
+				list = append(list, setting{env: env, field: field, value: v.Field(i)})
+			} else if field.Type.Kind() == reflect.Struct {
+				walk(v.Field(i))
+			}
+		}
+	}
+	walk(reflect.ValueOf(cfg).Elem())
+
+	return list
+}
+
+var durationType = reflect.TypeOf(time.Duration(0))
+
+// parse sets value from its text form, returning what was wrong with raw if
+// it cannot.
+func parse(value reflect.Value, raw string) error {
+	if value.Type() == durationType {
+		d, err := time.ParseDuration(raw)
+		if err != nil {
+			return errors.New("is not a duration such as 30s or 1h")
+		}
+		value.SetInt(int64(d))
+		return nil
+	}
+
+	switch value.Kind() {
+	case reflect.String:
+		value.SetString(raw)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(raw)
+		if err != nil {
+			return errors.New("is not true or false")
+		}
+		value.SetBool(b)
+	case reflect.Int, reflect.Int64:
+		n, err := strconv.ParseInt(raw, 10, 64)
+		if err != nil {
+			return errors.New("is not a whole number")
+		}
+		value.SetInt(n)
+	default:
+		panic("config: no parser for " + value.Type().String())
+	}
+
+	return nil
+}
@@ -0,0 +1,201 @@
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// required are settings every valid config has.
+var required = map[string]string{
+	"DB_HOST":         "localhost",
+	"DB_USER":         "savannah",
+	"DB_NAME":         "savannah",
+	"AUTH0_DOMAIN":    "savannah.eu.auth0.com",
+	"AUTH0_CLIENT_ID": "client",
+}
+
+// loadWith runs Load in an empty directory with only env set, plus a .env
+// file and a YAML config file when they are not empty. Whatever Load puts in
+// the environment from the .env file is undone when the test ends.
+func loadWith(t *testing.T, env map[string]string, dotenv, yamlFile string) (*Config, error) {
+	t.Helper()
+
+	dir := t.TempDir()
+	t.Chdir(dir)
+
+	for _, s := range settings(Defaults()) {
+		t.Setenv(s.env, "")
+		os.Unsetenv(s.env)
+	}
+	t.Setenv("CONFIG_FILE", "")
+	os.Unsetenv("CONFIG_FILE")
+
+	if dotenv != "" {
+		if err := os.WriteFile(filepath.Join(dir, ".env"), []byte(dotenv), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if yamlFile != "" {
+		path := filepath.Join(dir, "config.yaml")
+		if err := os.WriteFile(path, []byte(yamlFile), 0o600); err != nil {
+			t.Fatal(err)
+		}
+		t.Setenv("CONFIG_FILE", path)
+	}
+
+	for key, value := range env {
+		t.Setenv(key, value)
+	}
+
+	return Load()
+}
+
+func withRequired(env map[string]string) map[string]string {
+	all := map[string]string{}
+	for key, value := range required {
+		all[key] = value
+	}
+	for key, value := range env {
+		all[key] = value
+	}
+	return all
+}
+
+func TestLoadPrecedence(t *testing.T) {
+	yamlFile := `
+port: "9000"
+logging:
+  level: warn
+database:
+  sslMode: disable
+  maxConns: 20
+`
+	dotenv := "PORT=9100\nDB_SSLMODE=require\nDB_MAX_CONNS=30\n"
+
+	cfg, err := loadWith(t, withRequired(map[string]string{"PORT": "9200"}), dotenv, yamlFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for name, tc := range map[string]struct{ got, want any }{
+		"the environment over all":   {cfg.Port, "9200"},
+		".env over YAML":             {cfg.Database.SSLMode, "require"},
+		".env over YAML for numbers": {cfg.Database.MaxConns, 30},
+		"YAML over the default":      {cfg.Logging.Level, "warn"},
+		"the default":                {cfg.Logging.Format, "json"},
+	} {
+		if tc.got != tc.want {
+			t.Errorf("%s: got %v, want %v", name, tc.got, tc.want)
+		}
+	}
+}
+
+func TestLoadProblems(t *testing.T) {
+	_, err := loadWith(t, map[string]string{"PORT": "http", "DB_MAX_CONNS": "many", "DB_USER": "savannah"}, "", "")
+
+	var configErr *Error
+	if !errors.As(err, &configErr) {
+		t.Fatalf("want an *Error, got %v", err)
+	}
+
+	for _, want := range []string{
+		"DB_HOST is required",
+		"DB_NAME is required",
+		"AUTH0_DOMAIN is required",
+		"AUTH0_CLIENT_ID is required",
+		`DB_MAX_CONNS: "many" is not a whole number`,
+		`PORT: "http" is not a port number`,
+	} {
+		if !slices.Contains(configErr.Problems, want) {
+			t.Errorf("%q missing from the problems:\n%s", want, err)
+		}
+	}
+
+	if slices.Contains(configErr.Problems, "DB_USER is required") {
+		t.Error("DB_USER is set but reported missing")
+	}
+}
+
+func TestLoadUnknownYAMLField(t *testing.T) {
+	_, err := loadWith(t, withRequired(nil), "", "databse:\n  host: db\n")
+	if err == nil || !strings.Contains(err.Error(), "databse") {
+		t.Errorf("want the misspelt field reported, got %v", err)
+	}
+}
+
+func TestLoadNormalizes(t *testing.T) {
+	cfg, err := loadWith(t, withRequired(map[string]string{"STOREFRONT_URL": "https://savannah.co.ke/"}), "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if cfg.Storage.PublicURL != "/uploads" || cfg.Shop.StorefrontURL != "https://savannah.co.ke" {
+		t.Errorf("got public URL %q and storefront %q", cfg.Storage.PublicURL, cfg.Shop.StorefrontURL)
+	}
+
+	s3 := withRequired(map[string]string{"STORAGE_DRIVER": "s3", "S3_ENDPOINT": "s3.example.com", "S3_BUCKET": "images"})
+	cfg, err = loadWith(t, s3, "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if cfg.Storage.PublicURL != "" {
+		t.Errorf("S3 storage got the local public URL %q", cfg.Storage.PublicURL)
+	}
+
+	// validate only reports
+	cfg = Defaults()
+	cfg.Shop.StorefrontURL = "https://savannah.co.ke/"
+	cfg.validate()
+
+	if cfg.Shop.StorefrontURL != "https://savannah.co.ke/" || cfg.Storage.PublicURL != "" {
+		t.Error("validate changed the config")
+	}
+}
+
+func TestSecretsRedacted(t *testing.T) {
+	cfg := Defaults()
+	cfg.Database.Password = "hunter2"
+	cfg.Auth.ClientSecret = "auth0-secret"
+	cfg.SMS.APIKey = "atsk_live"
+
+	asJSON, err := json.Marshal(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var logged bytes.Buffer
+	slog.New(slog.NewJSONHandler(&logged, nil)).Info("Config", "config", cfg, "password", cfg.Database.Password)
+
+	var text bytes.Buffer
+	slog.New(slog.NewTextHandler(&text, nil)).Info("Config", "config", cfg)
+
+	for name, out := range map[string]string{
+		"String":      cfg.String(),
+		"MarshalJSON": string(asJSON),
+		"slog JSON":   logged.String(),
+		"slog text":   text.String(),
+	} {
+		for _, secret := range []string{"hunter2", "auth0-secret", "atsk_live"} {
+			if strings.Contains(out, secret) {
+				t.Errorf("%s gives away %q: %s", name, secret, out)
+			}
+		}
+
+		if !strings.Contains(out, redacted) {
+			t.Errorf("%s does not show the secrets as redacted: %s", name, out)
+		}
+	}
+
+	if !strings.Contains(cfg.String(), "DB_PASSWORD=[redacted]\n") || !strings.Contains(cfg.String(), "RESEND_KEY=\n") {
+		t.Errorf("want set secrets shown as %q and unset ones empty, got:\n%s", redacted, cfg.String())
+	}
+}
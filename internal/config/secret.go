@@ -0,0 +1,33 @@
+package config
+
+import (
+	"encoding/json"
+	"log/slog"
+)
+
+const redacted = "[redacted]"
+
+// Secret is a setting such as a password or API key. Printed with fmt, log
+// or slog, or marshalled to JSON, it shows as [redacted], so a logged config
+// never gives it away. Convert it to a string where the value is needed.
+type Secret string
+
+func (s Secret) String() string {
+	if s == "" {
+		return ""
+	}
+
+	return redacted
+}
+
+func (s Secret) GoString() string {
+	return `config.Secret("` + s.String() + `")`
+}
+
+func (s Secret) MarshalJSON() ([]byte, error) {
+	return json.Marshal(s.String())
+}
+
+func (s Secret) LogValue() slog.Value {
+	return slog.StringValue(s.String())
+}
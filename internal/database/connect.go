@@ -2,40 +2,161 @@ package database
 
 import (
 	"context"
+	"errors"
 	"fmt"
-	"os"
+	"log/slog"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
 
+	"github.com/Oj-washingtone/savannah-store/internal/config"
+	"github.com/jackc/pgx/v5/pgconn"
 	"github.com/jackc/pgx/v5/pgxpool"
 )
 
+// DB is the store's connection pools. Pool is the primary, which takes every
+// write and every read that has to see them.
 type DB struct {
 	Pool *pgxpool.Pool
+
+	// Replica is a read replica, nil without one. Reads chooses it for the
+	// heavy reads that can be a moment behind the primary.
+	Replica *pgxpool.Pool
 }
 
-var dbInstance *DB
+// Reads is the pool for listing, search and other heavy reads that can be a
+// moment behind the primary: the replica if there is one, else the primary.
+func (db *DB) Reads() *pgxpool.Pool {
+	if db.Replica != nil {
+		return db.Replica
+	}
+	return db.Pool
+}
 
-func ConnectDB() *DB {
-	if dbInstance != nil {
-		return dbInstance
+// Close closes the pools, waiting for the connections in use to be given
+// back.
+func (db *DB) Close() {
+	if db.Replica != nil {
+		db.Replica.Close()
 	}
+	db.Pool.Close()
+}
 
-	host := os.Getenv("DB_HOST")
-	user := os.Getenv("DB_USER")
-	password := os.Getenv("DB_PASSWORD")
-	db_name := os.Getenv("DB_NAME")
-	port := os.Getenv("DB_PORT")
+// ConnectDB connects to the primary and, when cfg has one, the replica. A
+// database that cannot be reached is tried again, backing off, for up to
+// cfg.ConnectRetryFor, so the server can start alongside the database. A
+// wrong password or database name fails straight away.
+func ConnectDB(ctx context.Context, cfg config.Database) (*DB, error) {
+	primary, err := connectPool(ctx, cfg, cfg.Host, cfg.Port)
+	if err != nil {
+		return nil, err
+	}
+
+	db := &DB{Pool: primary}
+
+	if cfg.ReplicaHost != "" {
+		if db.Replica, err = connectPool(ctx, cfg, cfg.ReplicaHost, cfg.ReplicaPort); err != nil {
+			primary.Close()
+			return nil, fmt.Errorf("read replica: %w", err)
+		}
+	}
 
-	dsn := fmt.Sprintf("postgres://%s:%s@%s:%s/%s", user, password, host, port, db_name)
-	pool, err := pgxpool.New(context.Background(), dsn)
+	return db, nil
+}
+
+// poolConfig is the pool settings of cfg for the server at host and port.
+func poolConfig(cfg config.Database, host, port string) (*pgxpool.Config, error) {
+	query := url.Values{"sslmode": {cfg.SSLMode}}
+	if cfg.SSLRootCert != "" {
+		query.Set("sslrootcert", cfg.SSLRootCert)
+	}
+
+	dsn := &url.URL{
+		Scheme:   "postgres",
+		User:     url.UserPassword(cfg.User, string(cfg.Password)),
+		Host:     net.JoinHostPort(host, port),
+		Path:     "/" + cfg.Name,
+		RawQuery: query.Encode(),
+	}
+
+	poolCfg, err := pgxpool.ParseConfig(dsn.String())
 	if err != nil {
-		panic(fmt.Sprintf("Unable to connect to database: %v", err))
+		return nil, err
 	}
 
-	dbInstance = &DB{Pool: pool}
-	fmt.Println("Connected to database")
-	return dbInstance
+	poolCfg.MaxConns = int32(cfg.MaxConns)
+	poolCfg.MinConns = int32(cfg.MinConns)
+	poolCfg.MaxConnLifetime = cfg.MaxConnLifetime
+	poolCfg.MaxConnIdleTime = cfg.MaxConnIdleTime
+	poolCfg.ConnConfig.ConnectTimeout = cfg.ConnectTimeout
+	poolCfg.ConnConfig.Tracer = queryTracer{}
+
+	params := poolCfg.ConnConfig.RuntimeParams
+	params["application_name"] = "savannah"
+
+	if cfg.StatementTimeout > 0 {
+		params["statement_timeout"] = strconv.FormatInt(cfg.StatementTimeout.Milliseconds(), 10)
+	}
+
+	return poolCfg, nil
+}
+
+const (
+	firstRetryWait = 500 * time.Millisecond
+	maxRetryWait   = 10 * time.Second
+)
+
+func connectPool(ctx context.Context, cfg config.Database, host, port string) (*pgxpool.Pool, error) {
+	poolCfg, err := poolConfig(cfg, host, port)
+	if err != nil {
+		return nil, err
+	}
+
+	address := net.JoinHostPort(host, port)
+	giveUp := time.Now().Add(cfg.ConnectRetryFor)
+	wait := firstRetryWait
+
+	for {
+		pool, err := pgxpool.NewWithConfig(ctx, poolCfg)
+		if err == nil {
+			// the pool connects lazily, so without this a wrong host or
+			// password would only show on the first request
+			if err = pool.Ping(ctx); err == nil {
+				slog.InfoContext(ctx, "Connected to the database", "address", address)
+				return pool, nil
+			}
+			pool.Close()
+		}
+
+		if !retryable(err) || time.Now().Add(wait).After(giveUp) {
+			return nil, fmt.Errorf("connecting to the database at %s: %w", address, err)
+		}
+
+		slog.WarnContext(ctx, "Database is not reachable yet",
+			"address", address,
+			"retry_in", wait.String(),
+			"error", err,
+		)
+
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(wait):
+		}
+
+		wait = min(wait*2, maxRetryWait)
+	}
 }
 
-func GetDB() *DB {
-	return dbInstance
+// retryable reports whether connecting could work on another try. Postgres
+// turning down the password (class 28) or not having the database (3D000)
+// will not change by waiting, while one still starting up (57P03) will.
+func retryable(err error) bool {
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) {
+		return !strings.HasPrefix(pgErr.Code, "28") && pgErr.Code != "3D000"
+	}
+	return true
 }
@@ -0,0 +1,301 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/Oj-washingtone/savannah-store/migrations"
+	"github.com/golang-migrate/migrate/v4"
+	pgxmigrate "github.com/golang-migrate/migrate/v4/database/pgx/v5"
+	"github.com/golang-migrate/migrate/v4/source"
+	"github.com/golang-migrate/migrate/v4/source/iofs"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/jackc/pgx/v5/stdlib"
+)
+
+// Migrator applies the migrations embedded in package migrations. Like the
+// migrate tool it records the version the database is at in the
+// schema_migrations table, so databases that tool migrated carry on from
+// where they are.
+type Migrator struct {
+	m  *migrate.Migrate
+	db *sql.DB
+}
+
+// Migration is one of the embedded migrations and whether the database has
+// it.
+type Migration struct {
+	Version uint
+	Name    string
+	Applied bool
+}
+
+// MigrationStatus is the version the database is at and the migrations up
+// to the latest one. Dirty means the migration to Version failed part way
+// and has to be cleaned up by hand before Force marks it done or undone.
+type MigrationStatus struct {
+	Version    uint
+	Dirty      bool
+	Migrations []Migration
+}
+
+// NewMigrator makes a migrator for the database pool connects to. It opens
+// a connection of its own, without the pool's statement timeout.
+func NewMigrator(pool *pgxpool.Pool) (*Migrator, error) {
+	src, err := iofs.New(migrations.FS, ".")
+	if err != nil {
+		return nil, err
+	}
+
+	db := stdlib.OpenDB(*migrationConnConfig(pool))
+
+	driver, err := pgxmigrate.WithInstance(db, &pgxmigrate.Config{})
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	m, err := migrate.NewWithInstance("iofs", src, "pgx5", driver)
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+	m.Log = migrateLog{}
+
+	return &Migrator{m: m, db: db}, nil
+}
+
+// Close closes the migrator's connection. The pool stays open.
+func (m *Migrator) Close() error {
+	srcErr, dbErr := m.m.Close()
+	return errors.Join(srcErr, dbErr, m.db.Close())
+}
+
+// migrationConnConfig is how the pool connects, less the statement timeout.
+// Migrations, and waiting for another server to finish them, take as long
+// as they take.
+func migrationConnConfig(pool *pgxpool.Pool) *pgx.ConnConfig {
+	connCfg := pool.Config().ConnConfig
+	connCfg.RuntimeParams["statement_timeout"] = "0"
+	return connCfg
+}
+
+// Up applies every migration the database does not have yet.
+func (m *Migrator) Up() error {
+	if err := m.m.Up(); err != nil && !errors.Is(err, migrate.ErrNoChange) {
+		return err
+	}
+	return nil
+}
+
+// Down undoes the last steps migrations.
+func (m *Migrator) Down(steps int) error {
+	if steps < 1 {
+		return fmt.Errorf("cannot undo %d migrations", steps)
+	}
+
+	if err := m.m.Steps(-steps); err != nil && !errors.Is(err, migrate.ErrNoChange) {
+		return err
+	}
+	return nil
+}
+
+// Force records the database as being at version without running anything,
+// for after a failed migration was finished or undone by hand. Version -1
+// means no migration at all.
+func (m *Migrator) Force(version int) error {
+	return m.m.Force(version)
+}
+
+// Version is the version the database is at, 0 before the first migration.
+func (m *Migrator) Version() (uint, bool, error) {
+	version, dirty, err := m.m.Version()
+	if errors.Is(err, migrate.ErrNilVersion) {
+		return 0, false, nil
+	}
+	return version, dirty, err
+}
+
+// Status lists the embedded migrations and which of them the database has.
+func (m *Migrator) Status() (*MigrationStatus, error) {
+	version, dirty, err := m.Version()
+	if err != nil {
+		return nil, err
+	}
+
+	status := &MigrationStatus{Version: version, Dirty: dirty}
+
+	status.Migrations, err = embeddedMigrations()
+	if err != nil {
+		return nil, err
+	}
+
+	for i, migration := range status.Migrations {
+		status.Migrations[i].Applied = migration.Version < version || (migration.Version == version && !dirty)
+	}
+
+	return status, nil
+}
+
+// LatestMigration is the version of the newest embedded migration, the one
+// this build of the store expects the database to be at.
+func LatestMigration() (uint, error) {
+	all, err := embeddedMigrations()
+	if err != nil || len(all) == 0 {
+		return 0, err
+	}
+
+	return all[len(all)-1].Version, nil
+}
+
+// SchemaVersion reads the version the database is at straight from
+// schema_migrations, 0 before the first migration. Unlike a Migrator it
+// takes no locks, so it suits checks that run every few seconds.
+func SchemaVersion(ctx context.Context, pool *pgxpool.Pool) (uint, bool, error) {
+	var version int64
+	var dirty bool
+
+	err := pool.QueryRow(ctx, "SELECT version, dirty FROM schema_migrations LIMIT 1").Scan(&version, &dirty)
+
+	// no row or no table yet, 42P01 being undefined_table
+	var pgErr *pgconn.PgError
+	switch {
+	case errors.Is(err, pgx.ErrNoRows), errors.As(err, &pgErr) && pgErr.Code == "42P01":
+		return 0, false, nil
+	case err != nil:
+		return 0, false, err
+	}
+
+	return uint(version), dirty, nil
+}
+
+// embeddedMigrations lists the embedded migrations, oldest first.
+func embeddedMigrations() ([]Migration, error) {
+	names, err := fs.Glob(migrations.FS, "*.up.sql")
+	if err != nil {
+		return nil, err
+	}
+
+	var all []Migration
+	for _, name := range names {
+		parsed, err := source.Parse(name)
+		if err != nil {
+			return nil, err
+		}
+
+		all = append(all, Migration{Version: parsed.Version, Name: parsed.Identifier})
+	}
+
+	sort.Slice(all, func(i, j int) bool {
+		return all[i].Version < all[j].Version
+	})
+
+	return all, nil
+}
+
+// migrateLockID is the key of the advisory lock AutoMigrate holds. Any
+// number will do, as long as nothing else locks it.
+const migrateLockID = 7_264_330_184
+
+// AutoMigrate brings the database up to date as the server starts. It holds
+// an advisory lock while it does, so of several servers starting at once one
+// migrates and the others wait for it and then find nothing left to do.
+func AutoMigrate(ctx context.Context, pool *pgxpool.Pool) error {
+	conn, err := pgx.ConnectConfig(ctx, migrationConnConfig(pool))
+	if err != nil {
+		return err
+	}
+	defer conn.Close(context.Background())
+
+	if _, err := conn.Exec(ctx, "SELECT pg_advisory_lock($1)", migrateLockID); err != nil {
+		return fmt.Errorf("waiting for the migration lock: %w", err)
+	}
+	defer conn.Exec(context.Background(), "SELECT pg_advisory_unlock($1)", migrateLockID)
+
+	m, err := NewMigrator(pool)
+	if err != nil {
+		return err
+	}
+	defer m.Close()
+
+	if err := m.Up(); err != nil {
+		return err
+	}
+
+	version, _, err := m.Version()
+	if err != nil {
+		return err
+	}
+
+	slog.InfoContext(ctx, "Database is migrated", "version", version)
+	return nil
+}
+
+var (
+	migrationFile = regexp.MustCompile(`^(\d+)_.*\.(up|down)\.sql$`)
+	notNameChars  = regexp.MustCompile(`[^a-z0-9]+`)
+)
+
+// CreateMigration adds empty up and down files for a new migration to dir,
+// numbered after the last migration there, and returns their paths.
+func CreateMigration(dir, name string) ([]string, error) {
+	name = strings.Trim(notNameChars.ReplaceAllString(strings.ToLower(name), "_"), "_")
+	if name == "" {
+		return nil, errors.New("the migration needs a name")
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var last uint64
+	for _, entry := range entries {
+		match := migrationFile.FindStringSubmatch(entry.Name())
+		if match == nil {
+			continue
+		}
+
+		if version, err := strconv.ParseUint(match[1], 10, 64); err == nil {
+			last = max(last, version)
+		}
+	}
+
+	var paths []string
+	for _, direction := range []string{"up", "down"} {
+		path := filepath.Join(dir, fmt.Sprintf("%06d_%s.%s.sql", last+1, name, direction))
+
+		f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o644)
+		if err != nil {
+			return paths, err
+		}
+		f.Close()
+
+		paths = append(paths, path)
+	}
+
+	return paths, nil
+}
+
+// migrateLog passes what the migrate library reports on to the log.
+type migrateLog struct{}
+
+func (migrateLog) Printf(format string, v ...any) {
+	slog.Info(strings.TrimSpace(fmt.Sprintf(format, v...)))
+}
+
+func (migrateLog) Verbose() bool {
+	return false
+}
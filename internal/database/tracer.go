@@ -0,0 +1,57 @@
+package database
+
+import (
+	"context"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// slowQuery is how long a query runs before it is logged as a warning
+const slowQuery = time.Second
+
+// queryTracer logs each query at debug level and slow ones as warnings. The
+// log line is made with the query's context, so it carries the request that
+// ran it. Arguments are left out, as they hold customers' details.
+type queryTracer struct{}
+
+type queryStartKey struct{}
+
+type queryStart struct {
+	sql string
+	at  time.Time
+}
+
+func (queryTracer) TraceQueryStart(ctx context.Context, _ *pgx.Conn, data pgx.TraceQueryStartData) context.Context {
+	return context.WithValue(ctx, queryStartKey{}, queryStart{sql: data.SQL, at: time.Now()})
+}
+
+func (queryTracer) TraceQueryEnd(ctx context.Context, _ *pgx.Conn, data pgx.TraceQueryEndData) {
+	start, ok := ctx.Value(queryStartKey{}).(queryStart)
+	if !ok {
+		return
+	}
+
+	took := time.Since(start.at)
+
+	level := slog.LevelDebug
+	if took >= slowQuery {
+		level = slog.LevelWarn
+	}
+
+	if !slog.Default().Enabled(ctx, level) {
+		return
+	}
+
+	attrs := []any{
+		"sql", strings.Join(strings.Fields(start.sql), " "),
+		"duration_ms", float64(took.Microseconds()) / 1000,
+	}
+	if data.Err != nil {
+		attrs = append(attrs, "error", data.Err)
+	}
+
+	slog.Log(ctx, level, "Query", attrs...)
+}
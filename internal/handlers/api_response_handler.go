@@ -1,12 +1,25 @@
 package handlers
 
-import "github.com/gin-gonic/gin"
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/Oj-washingtone/savannah-store/internal/apperr"
+	"github.com/Oj-washingtone/savannah-store/internal/pagination"
+	"github.com/Oj-washingtone/savannah-store/internal/validation"
+	"github.com/gin-gonic/gin"
+)
 
 type ApiResponse struct {
-	Success bool        `json:"success"`
-	Message string      `json:"message"`
-	Data    interface{} `json:"data,omitempty"`
-	Error   string      `json:"error,omitempty"`
+	Success    bool             `json:"success"`
+	Message    string           `json:"message"`
+	Data       interface{}      `json:"data,omitempty"`
+	Pagination *pagination.Info `json:"pagination,omitempty"`
+	Error      string           `json:"error,omitempty"`
+
+	// Fields lists each invalid field of the request and why
+	Fields []validation.FieldError `json:"fields,omitempty"`
 }
 
 func RespondSuccess(c *gin.Context, status int, message string, data interface{}) {
@@ -17,10 +30,56 @@ func RespondSuccess(c *gin.Context, status int, message string, data interface{}
 	})
 }
 
-func RespondError(c *gin.Context, status int, message string, err string) {
+// RespondPage responds with one page of a list, adding the pagination
+// envelope and Link headers pointing at the next and previous pages.
+func RespondPage(c *gin.Context, status int, message string, data interface{}, page *pagination.Info) {
+	setPageLinks(c, page)
+
+	c.JSON(status, ApiResponse{
+		Success:    true,
+		Message:    message,
+		Data:       data,
+		Pagination: page,
+	})
+}
+
+func RespondError(c *gin.Context, status int, message string, err string, fields ...validation.FieldError) {
 	c.JSON(status, ApiResponse{
 		Success: false,
 		Message: message,
 		Error:   err,
+		Fields:  fields,
 	})
 }
+
+// fail hands err to the error middleware, which responds with the status of
+// its apperr kind. Handlers return right after calling it.
+func fail(c *gin.Context, err error) {
+	_ = c.Error(err)
+}
+
+// bindJSON binds the request body and checks its binding rules, responding
+// with the invalid fields when it fails. The rules are checked with the
+// request's context, and a lookup they could not make, such as with the
+// database down, goes to the error middleware rather than being blamed on
+// the body.
+func bindJSON(c *gin.Context, body any) bool {
+	err := json.NewDecoder(c.Request.Body).Decode(body)
+	if err == nil {
+		err = validation.Struct(c.Request.Context(), body)
+	}
+
+	var appErr *apperr.Error
+	if errors.As(err, &appErr) {
+		fail(c, err)
+		return false
+	}
+
+	if err != nil {
+		fields, problem := validation.Fields(err)
+		RespondError(c, http.StatusBadRequest, "Invalid request body", problem, fields...)
+		return false
+	}
+
+	return true
+}
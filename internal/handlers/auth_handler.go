@@ -3,12 +3,27 @@ package handlers
 import (
 	"crypto/rand"
 	"encoding/base64"
+	"errors"
 	"net/http"
 
 	"github.com/Oj-washingtone/savannah-store/internal/authenticator"
+	"github.com/Oj-washingtone/savannah-store/internal/config"
+	"github.com/Oj-washingtone/savannah-store/internal/model"
+	"github.com/Oj-washingtone/savannah-store/internal/repocitory"
 	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
 )
 
+type AuthHandler struct {
+	users repocitory.UserRepository
+	auth  config.Auth
+}
+
+func NewAuthHandler(users repocitory.UserRepository, auth config.Auth) *AuthHandler {
+	return &AuthHandler{users: users, auth: auth}
+}
+
 // Login godoc
 // @Summary Login endpoint
 // @Description Redirects the user to the Auth0/Google login page for authentication.
@@ -17,19 +32,19 @@ import (
 // @Success 302 {string} string "Redirects to Auth0/Google login page"
 // @Failure 500 {object} map[string]string "Failed to initialize authenticator"
 // @Router /auth/login [get]
-func Login(c *gin.Context) {
+func (h *AuthHandler) Login(c *gin.Context) {
 
-	auth, err := authenticator.New()
+	auth, err := authenticator.New(h.auth)
 
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"message": "failed to initialize authenticator", "error": err.Error()})
+		fail(c, err)
 		return
 	}
 
 	state, err := generateRandomState()
 
 	if err != nil {
-		c.String(http.StatusInternalServerError, err.Error())
+		fail(c, err)
 		return
 	}
 
@@ -49,3 +64,77 @@ func generateRandomState() (string, error) {
 
 	return state, nil
 }
+
+// Callback finishes the Auth0 login, creating an account for first time
+// users, and responds with the user and their ID token.
+func (h *AuthHandler) Callback(c *gin.Context) {
+	code := c.Query("code")
+
+	if code == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "no code in query"})
+		return
+	}
+
+	auth, err := authenticator.New(h.auth)
+
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to init authenticator"})
+		return
+	}
+
+	token, err := auth.Exchange(c.Request.Context(), code)
+
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "failed to exchange code for token"})
+		return
+	}
+
+	idToken, err := auth.VerifyIDToken(c.Request.Context(), token)
+
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid id token"})
+		return
+	}
+
+	var claims struct {
+		Sub     string `json:"sub"`
+		Name    string `json:"name"`
+		Email   string `json:"email"`
+		Picture string `json:"picture"`
+	}
+
+	if err := idToken.Claims(&claims); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to parse claims"})
+		return
+	}
+
+	user, err := h.users.GetByEmail(c.Request.Context(), claims.Email)
+
+	// only someone we have never seen gets a new account, a failed lookup
+	// must not create a second one
+	if errors.Is(err, pgx.ErrNoRows) {
+		newUser := &model.User{
+			Name:    claims.Name,
+			Email:   claims.Email,
+			Auth0Id: claims.Sub,
+			Role:    "customer",
+		}
+
+		newUser.ID = uuid.New()
+
+		if err := h.users.Create(c.Request.Context(), newUser); err != nil {
+			fail(c, err)
+			return
+		}
+
+		user = newUser
+	} else if err != nil {
+		fail(c, err)
+		return
+	}
+
+	c.IndentedJSON(http.StatusOK, gin.H{
+		"user":  user,
+		"token": token.Extra("id_token"),
+	})
+}
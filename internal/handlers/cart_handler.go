@@ -3,17 +3,47 @@ package handlers
 import (
 	"errors"
 	"net/http"
+	"time"
 
 	"github.com/Oj-washingtone/savannah-store/internal/model"
 	"github.com/Oj-washingtone/savannah-store/internal/repocitory"
+	"github.com/Oj-washingtone/savannah-store/internal/service"
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
 	"github.com/jackc/pgx/v5"
 )
 
+type CartHandler struct {
+	users          repocitory.UserRepository
+	products       repocitory.ProductRepository
+	carts          repocitory.ShoppingCartRepository
+	cartItems      repocitory.CartItemsRepository
+	promotions     repocitory.PromotionRepository
+	pricing        *service.PricingService
+	productService *service.ProductService
+}
+
+func NewCartHandler(repos *repocitory.Repositories, pricing *service.PricingService, productService *service.ProductService) *CartHandler {
+	return &CartHandler{
+		users:          repos.Users,
+		products:       repos.Products,
+		carts:          repos.Carts,
+		cartItems:      repos.CartItems,
+		promotions:     repos.Promotions,
+		pricing:        pricing,
+		productService: productService,
+	}
+}
+
 type ItemBody struct {
-	ProductID string `json:"product_id"`
-	Quantity  int    `json:"quantity"`
+	ProductID string `json:"product_id" binding:"required,uuid"`
+	// VariantID is required for products with options such as size or colour
+	VariantID string `json:"variant_id,omitempty" binding:"omitempty,uuid"`
+	Quantity  int    `json:"quantity" binding:"required,min=1"`
+}
+
+type updateQuantityBody struct {
+	Quantity int `json:"quantity" binding:"required,min=1"`
 }
 
 //TODO: Add security annotation
@@ -21,7 +51,7 @@ type ItemBody struct {
 // Add product to cart
 // AddProductToCart godoc
 // @Summary Add product to cart
-// @Description Add a product to the shopping cart
+// @Description Add a product to the shopping cart. Products with options need the variant_id of the chosen variant; simple products can leave it out.
 // @Tags Shopping Cart
 // @Accept json
 // @Produce json
@@ -32,78 +62,68 @@ type ItemBody struct {
 // @Failure 401 {object} map[string]string
 // @Failure 500 {object} map[string]string
 // @Router /cart/create [post]
-func AddToCart(c *gin.Context) {
-
-	userClaims, exists := c.Get("user")
-	if !exists {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
-		return
-	}
-
-	claims := userClaims.(map[string]interface{})
-
-	// Auth0 user_id (sub claim)
-	auth0ID := claims["sub"].(string)
-
-	user, err := repocitory.NewUserRepository().GetByAuth0Id(c, auth0ID)
-
-	if err != nil {
-		RespondError(c, http.StatusInternalServerError, "failed to get user", err.Error())
+func (h *CartHandler) AddToCart(c *gin.Context) {
+	user, ok := currentUser(c, h.users)
+	if !ok {
 		return
 	}
 
-	cartRepo := repocitory.NewShoppingCartRepository()
-
-	cart, err := cartRepo.GetShoppingCart(c.Request.Context(), user.ID)
+	cart, err := h.carts.GetShoppingCart(c.Request.Context(), user.ID)
 
 	if err != nil {
 		if errors.Is(err, pgx.ErrNoRows) {
-			cart, err = cartRepo.CreateCart(c.Request.Context(), user.ID)
+			cart, err = h.carts.CreateCart(c.Request.Context(), user.ID)
 
 			if err != nil {
-				RespondError(c, http.StatusInternalServerError, "failed to create cart", err.Error())
+				fail(c, err)
 				return
 			}
 		} else {
-			RespondError(c, http.StatusInternalServerError, "failed to get cart", err.Error())
+			fail(c, err)
 			return
 		}
 	}
 
 	var body ItemBody
 
-	if err := c.ShouldBindJSON(&body); err != nil {
-		RespondError(c, http.StatusBadRequest, "invalid request body", err.Error())
+	if !bindJSON(c, &body) {
 		return
 	}
 
-	productId, err := uuid.Parse(body.ProductID)
-
-	if err != nil {
-		RespondError(c, http.StatusBadRequest, "invalid product ID", err.Error())
+	productId, ok := parseId(c, body.ProductID, "product")
+	if !ok {
 		return
 	}
 
-	product, err := repocitory.NewProductRepository().GetById(c.Request.Context(), productId)
+	product, err := h.products.GetById(c.Request.Context(), productId)
 
 	if err != nil {
-		RespondError(c, http.StatusNotFound, "product not found", err.Error())
+		fail(c, err)
 		return
 	}
 
-	if body.Quantity <= 0 {
-		RespondError(c, http.StatusBadRequest, "invalid quantity", "Quantity must be greater than zero")
-		return
+	var variantId *uuid.UUID
+
+	if body.VariantID != "" {
+		id, ok := parseId(c, body.VariantID, "variant")
+		if !ok {
+			return
+		}
+
+		variantId = &id
 	}
 
-	cartItemRepo := repocitory.NewCartItemsRepository()
+	variant, ok := resolveCartVariant(c, h.productService, product, variantId)
+	if !ok {
+		return
+	}
 
 	// item already exist in cart
 
-	exists, err = cartItemRepo.Exists(c.Request.Context(), cart.ID, body.ProductID)
+	exists, err := h.cartItems.Exists(c.Request.Context(), cart.ID, variant.ID)
 
 	if err != nil {
-		RespondError(c, http.StatusInternalServerError, "failed to check item existence", err.Error())
+		fail(c, err)
 		return
 	}
 
@@ -114,23 +134,38 @@ func AddToCart(c *gin.Context) {
 
 	cartItem := &model.CartItem{
 		ProductId: productId,
+		VariantId: variant.ID,
 		Quantity:  body.Quantity,
 		CartId:    cart.ID,
-		Price:     product.Price,
+		Price:     variant.DiscountedPrice,
 	}
 
 	cartItem.ID = uuid.New()
 
-	err = cartItemRepo.AddItem(c.Request.Context(), cartItem)
+	err = h.cartItems.AddItem(c.Request.Context(), cartItem)
 
 	if err != nil {
-		RespondError(c, http.StatusInternalServerError, "failed to add item to cart", err.Error())
+		fail(c, err)
 		return
 	}
 
 	RespondSuccess(c, http.StatusCreated, "Item added to cart successfully", cartItem)
 }
 
+// resolveCartVariant picks the variant of the product going into the cart,
+// responding with an error if there is no such variant or one has to be
+// chosen.
+func resolveCartVariant(c *gin.Context, products *service.ProductService, product *model.Product, variantId *uuid.UUID) (*model.ProductVariant, bool) {
+	variant, err := products.ResolveVariant(c.Request.Context(), product, variantId)
+
+	if err != nil {
+		fail(c, err)
+		return nil, false
+	}
+
+	return variant, true
+}
+
 // Remove item from cart
 // @Summary Remove item from cart
 // @Description Remove a product from the shopping cart by its item ID
@@ -145,20 +180,18 @@ func AddToCart(c *gin.Context) {
 // @Failure 404 {object} map[string]string
 // @Failure 500 {object} map[string]string
 // @Router /cart/remove/{id} [delete]
-func RemoveFromCart(c *gin.Context) {
+func (h *CartHandler) RemoveFromCart(c *gin.Context) {
 	idParam := c.Param("id")
 
-	itemId, err := uuid.Parse(idParam)
-
-	if err != nil {
-		RespondError(c, http.StatusBadRequest, "Invalid item id", err.Error())
+	itemId, ok := parseId(c, idParam, "item")
+	if !ok {
 		return
 	}
 
-	err = repocitory.NewCartItemsRepository().RemoveItem(c.Request.Context(), itemId)
+	err := h.cartItems.RemoveItem(c.Request.Context(), itemId)
 
 	if err != nil {
-		RespondError(c, http.StatusInternalServerError, "failed to remove item from cart", err.Error())
+		fail(c, err)
 		return
 	}
 
@@ -172,49 +205,42 @@ func RemoveFromCart(c *gin.Context) {
 // @Accept json
 // @Produce json
 // @Security BearerAuth
-// @Success 200 {object} map[string]interface{} "cart_id and list of items"
+// @Success 200 {object} map[string]interface{} "cart_id, list of items and the priced summary"
 // @Failure 401 {object} map[string]string "Unauthorized"
 // @Failure 500 {object} map[string]string "Internal server error"
 // @Router /cart [get]
-func GetCartItems(c *gin.Context) {
-	userClaims, exists := c.Get("user")
-	if !exists {
-		RespondError(c, http.StatusUnauthorized, "unauthorized", "User not authenticated")
+func (h *CartHandler) GetCartItems(c *gin.Context) {
+	user, ok := currentUser(c, h.users)
+	if !ok {
 		return
 	}
 
-	claims := userClaims.(map[string]interface{})
-
-	auth0ID := claims["sub"].(string)
-
-	user, err := repocitory.NewUserRepository().GetByAuth0Id(c, auth0ID)
+	cart, err := h.carts.GetShoppingCart(c.Request.Context(), user.ID)
 
 	if err != nil {
-		RespondError(c, http.StatusInternalServerError, "failed to get user", err.Error())
+		if errors.Is(err, pgx.ErrNoRows) {
+			c.JSON(http.StatusOK, gin.H{"message": "Cart is empty"})
+			return
+		}
+		fail(c, err)
 		return
 	}
 
-	cartRepo := repocitory.NewShoppingCartRepository()
-
-	cart, err := cartRepo.GetShoppingCart(c.Request.Context(), user.ID)
+	items, err := h.cartItems.GetItems(c.Request.Context(), cart.ID)
 
 	if err != nil {
-		if errors.Is(err, pgx.ErrNoRows) {
-			c.JSON(http.StatusOK, gin.H{"message": "Cart is empty"})
-			return
-		}
-		RespondError(c, http.StatusInternalServerError, "failed to get cart", err.Error())
+		fail(c, err)
 		return
 	}
 
-	items, err := repocitory.NewCartItemsRepository().GetItems(c.Request.Context(), cart.ID)
+	summary, err := h.pricing.PriceCart(c.Request.Context(), cart.ID, user.ID, items)
 
 	if err != nil {
-		RespondError(c, http.StatusInternalServerError, "failed to get cart items", err.Error())
+		fail(c, err)
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"cart_id": cart.ID.String(), "items": items})
+	c.JSON(http.StatusOK, gin.H{"cart_id": cart.ID.String(), "items": items, "summary": summary})
 }
 
 // UpdateQuantity godoc
@@ -225,40 +251,184 @@ func GetCartItems(c *gin.Context) {
 // @Produce json
 // @Security BearerAuth
 // @Param id path string true "Cart Item ID"
-// @Param item body ItemBody true "Updated quantity"
+// @Param item body updateQuantityBody true "Updated quantity"
 // @Success 200 {object} map[string]string "Item quantity updated successfully"
 // @Failure 400 {object} map[string]string "Invalid request"
 // @Failure 401 {object} map[string]string "Unauthorized"
 // @Failure 500 {object} map[string]string "Internal server error"
 // @Router /cart/update/quantity/{id} [patch]
-func UpdateQuantity(c *gin.Context) {
+func (h *CartHandler) UpdateQuantity(c *gin.Context) {
 	idParam := c.Param("id")
 
-	itemId, err := uuid.Parse(idParam)
+	itemId, ok := parseId(c, idParam, "item")
+	if !ok {
+		return
+	}
+
+	var body updateQuantityBody
+
+	if !bindJSON(c, &body) {
+		return
+	}
+
+	err := h.cartItems.UpdateQuantity(c.Request.Context(), itemId, body.Quantity)
 
 	if err != nil {
-		RespondError(c, http.StatusBadRequest, "Invalid item id", err.Error())
+		fail(c, err)
 		return
 	}
 
-	var body ItemBody
+	RespondSuccess(c, http.StatusOK, "Item quantity updated successfully", nil)
+}
+
+// getOrCreateCart returns the user's shopping cart, creating it the first
+// time it is needed.
+func getOrCreateCart(c *gin.Context, carts repocitory.ShoppingCartRepository, userId uuid.UUID) (*model.Cart, error) {
+	cart, err := carts.GetShoppingCart(c.Request.Context(), userId)
+
+	if err == nil {
+		return cart, nil
+	}
+
+	if !errors.Is(err, pgx.ErrNoRows) {
+		return nil, err
+	}
+
+	return carts.CreateCart(c.Request.Context(), userId)
+}
 
-	if err := c.ShouldBindJSON(&body); err != nil {
-		RespondError(c, http.StatusBadRequest, "Invalid request body", err.Error())
+type couponBody struct {
+	Code string `json:"code" binding:"required,max=50"`
+}
+
+// ApplyCoupon godoc
+// @Summary Apply a coupon code to the cart
+// @Description Adds a promotion code to the cart and returns the repriced cart summary
+// @Tags Shopping Cart
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param body body couponBody true "Coupon code"
+// @Success 200 {object} model.CartSummary
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /cart/coupons [post]
+func (h *CartHandler) ApplyCoupon(c *gin.Context) {
+	user, ok := currentUser(c, h.users)
+	if !ok {
 		return
 	}
 
-	if body.Quantity <= 0 {
-		RespondError(c, http.StatusBadRequest, "Invalid quantity", "Quantity must be greater than zero")
+	var body couponBody
+
+	if !bindJSON(c, &body) {
 		return
 	}
 
-	err = repocitory.NewCartItemsRepository().UpdateQuantity(c.Request.Context(), itemId, body.Quantity)
+	promotion, err := h.promotions.GetByCode(c.Request.Context(), service.NormalizePromotionCode(body.Code))
 
 	if err != nil {
-		RespondError(c, http.StatusInternalServerError, "failed to update item quantity", err.Error())
+		if errors.Is(err, pgx.ErrNoRows) {
+			RespondError(c, http.StatusNotFound, "invalid coupon code", "No promotion found for this code")
+			return
+		}
+
+		fail(c, err)
 		return
 	}
 
-	RespondSuccess(c, http.StatusOK, "Item quantity updated successfully", nil)
+	reason, err := h.pricing.CheckPromotion(c.Request.Context(), promotion, user.ID, time.Now())
+
+	if err != nil {
+		fail(c, err)
+		return
+	}
+
+	if reason != "" {
+		RespondError(c, http.StatusBadRequest, "coupon code cannot be used", reason)
+		return
+	}
+
+	cart, err := getOrCreateCart(c, h.carts, user.ID)
+
+	if err != nil {
+		fail(c, err)
+		return
+	}
+
+	if err := h.promotions.AddToCart(c.Request.Context(), cart.ID, promotion.ID); err != nil {
+		fail(c, err)
+		return
+	}
+
+	h.respondCartSummary(c, cart, user.ID, "Coupon code applied")
+}
+
+// RemoveCoupon godoc
+// @Summary Remove a coupon code from the cart
+// @Tags Shopping Cart
+// @Produce json
+// @Security BearerAuth
+// @Param code path string true "Coupon code"
+// @Success 200 {object} model.CartSummary
+// @Failure 401 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /cart/coupons/{code} [delete]
+func (h *CartHandler) RemoveCoupon(c *gin.Context) {
+	user, ok := currentUser(c, h.users)
+	if !ok {
+		return
+	}
+
+	cart, err := h.carts.GetShoppingCart(c.Request.Context(), user.ID)
+
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			RespondError(c, http.StatusNotFound, "cart not found", "No cart found for the user")
+			return
+		}
+
+		fail(c, err)
+		return
+	}
+
+	promotion, err := h.promotions.GetByCode(c.Request.Context(), service.NormalizePromotionCode(c.Param("code")))
+
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			RespondError(c, http.StatusNotFound, "invalid coupon code", "No promotion found for this code")
+			return
+		}
+
+		fail(c, err)
+		return
+	}
+
+	if err := h.promotions.RemoveFromCart(c.Request.Context(), cart.ID, promotion.ID); err != nil {
+		fail(c, err)
+		return
+	}
+
+	h.respondCartSummary(c, cart, user.ID, "Coupon code removed")
+}
+
+func (h *CartHandler) respondCartSummary(c *gin.Context, cart *model.Cart, userId uuid.UUID, message string) {
+	items, err := h.cartItems.GetItems(c.Request.Context(), cart.ID)
+
+	if err != nil {
+		fail(c, err)
+		return
+	}
+
+	summary, err := h.pricing.PriceCart(c.Request.Context(), cart.ID, userId, items)
+
+	if err != nil {
+		fail(c, err)
+		return
+	}
+
+	RespondSuccess(c, http.StatusOK, message, summary)
 }
@@ -0,0 +1,35 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/Oj-washingtone/savannah-store/internal/model"
+	"github.com/Oj-washingtone/savannah-store/internal/repocitory"
+	"github.com/gin-gonic/gin"
+)
+
+// currentUser loads the account of the authenticated caller. It writes the
+// error response itself, so callers only need to return when ok is false.
+func currentUser(c *gin.Context, users repocitory.UserRepository) (*model.User, bool) {
+	userClaims, exists := c.Get("user")
+	if !exists {
+		RespondError(c, http.StatusUnauthorized, "unauthorized", "User not authenticated")
+		return nil, false
+	}
+
+	claims, _ := userClaims.(map[string]interface{})
+	auth0ID, _ := claims["sub"].(string)
+	if auth0ID == "" {
+		RespondError(c, http.StatusUnauthorized, "unauthorized", "The token does not name a user")
+		return nil, false
+	}
+
+	user, err := users.GetByAuth0Id(c.Request.Context(), auth0ID)
+
+	if err != nil {
+		fail(c, err)
+		return nil, false
+	}
+
+	return user, true
+}
@@ -0,0 +1,276 @@
+package handlers
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/Oj-washingtone/savannah-store/internal/model"
+	"github.com/Oj-washingtone/savannah-store/internal/repocitory"
+	"github.com/Oj-washingtone/savannah-store/internal/service"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+type DiscountHandler struct {
+	discounts       repocitory.DiscountRepository
+	discountService *service.DiscountService
+}
+
+func NewDiscountHandler(repos *repocitory.Repositories, discountService *service.DiscountService) *DiscountHandler {
+	return &DiscountHandler{
+		discounts:       repos.Discounts,
+		discountService: discountService,
+	}
+}
+
+type createDiscountBody struct {
+	ProductId uuid.UUID          `json:"productId" binding:"required,exists=product"`
+	Type      model.DiscountType `json:"type" binding:"required,oneof=percentage fixed"`
+	Value     int64              `json:"value" binding:"required,money"`
+	StartsAt  *time.Time         `json:"startsAt,omitempty"`
+	EndsAt    *time.Time         `json:"endsAt,omitempty"`
+	Priority  int                `json:"priority"`
+}
+
+// validateDiscount returns a description of what is wrong with the discount,
+// or an empty string if it is valid.
+func validateDiscount(d *model.Discount) string {
+	if d.Type != model.DiscountPercentage && d.Type != model.DiscountFixed {
+		return "type must be percentage or fixed"
+	}
+
+	if d.Value <= 0 {
+		return "value must be greater than zero"
+	}
+
+	if d.Type == model.DiscountPercentage && d.Value > 100 {
+		return "percentage discounts cannot be more than 100"
+	}
+
+	if d.StartsAt != nil && d.EndsAt != nil && !d.EndsAt.After(*d.StartsAt) {
+		return "endsAt must be after startsAt"
+	}
+
+	return ""
+}
+
+// saveDiscount validates and stores the discount, responding with what is
+// wrong when it cannot be saved.
+func (h *DiscountHandler) saveDiscount(c *gin.Context, discount *model.Discount, create bool) bool {
+	if reason := validateDiscount(discount); reason != "" {
+		RespondError(c, http.StatusBadRequest, "Invalid discount", reason)
+		return false
+	}
+
+	if err := h.discountService.Save(c.Request.Context(), discount, create); err != nil {
+		fail(c, err)
+		return false
+	}
+
+	return true
+}
+
+// CreateDiscount godoc
+// @Summary Put a product on sale
+// @Description Creates a percentage or fixed discount on a product, optionally limited to a time window. When several discounts run at once, the highest priority wins, then the one that started last. Discounts on the same product with the same priority cannot overlap.
+// @Tags Discounts
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param body body createDiscountBody true "Discount"
+// @Success 201 {object} model.Discount
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 409 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /discounts [post]
+func (h *DiscountHandler) CreateDiscount(c *gin.Context) {
+	var body createDiscountBody
+
+	if !bindJSON(c, &body) {
+		return
+	}
+
+	discount := &model.Discount{
+		ProductID: body.ProductId,
+		Type:      body.Type,
+		Value:     body.Value,
+		StartsAt:  body.StartsAt,
+		EndsAt:    body.EndsAt,
+		Priority:  body.Priority,
+	}
+	discount.ID = uuid.New()
+
+	if !h.saveDiscount(c, discount, true) {
+		return
+	}
+
+	RespondSuccess(c, http.StatusCreated, "Discount created successfully", discount)
+}
+
+// ListDiscounts godoc
+// @Summary List the discounts of a product
+// @Description Returns a page of the running, scheduled and expired discounts of a product, newest first. Follow pagination.nextCursor (or the Link header) for the next page.
+// @Tags Discounts
+// @Produce json
+// @Security BearerAuth
+// @Param productId query string true "Product ID"
+// @Param limit query int false "Number of discounts to return (100 max)" default(10)
+// @Param cursor query string false "nextCursor or prevCursor from the previous page"
+// @Param total query bool false "Include the total number of discounts"
+// @Success 200 {array} model.Discount
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /discounts [get]
+func (h *DiscountHandler) ListDiscounts(c *gin.Context) {
+	productId, ok := parseId(c, c.Query("productId"), "product")
+	if !ok {
+		return
+	}
+
+	page, ok := parsePage(c)
+	if !ok {
+		return
+	}
+
+	discounts, info, err := h.discounts.List(c.Request.Context(), productId, page)
+
+	if err != nil {
+		fail(c, err)
+		return
+	}
+
+	RespondPage(c, http.StatusOK, "Discounts fetched successfully", discounts, info)
+}
+
+func (h *DiscountHandler) loadDiscount(c *gin.Context) (*model.Discount, bool) {
+	discountId, ok := parseId(c, c.Param("id"), "discount")
+	if !ok {
+		return nil, false
+	}
+
+	discount, err := h.discounts.GetById(c.Request.Context(), discountId)
+
+	if err != nil {
+		fail(c, err)
+		return nil, false
+	}
+
+	return discount, true
+}
+
+// GetDiscount godoc
+// @Summary Get a discount
+// @Tags Discounts
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "Discount ID"
+// @Success 200 {object} model.Discount
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /discounts/{id} [get]
+func (h *DiscountHandler) GetDiscount(c *gin.Context) {
+	discount, ok := h.loadDiscount(c)
+	if !ok {
+		return
+	}
+
+	RespondSuccess(c, http.StatusOK, "success", discount)
+}
+
+type updateDiscountBody struct {
+	Type     *model.DiscountType `json:"type,omitempty" binding:"omitempty,oneof=percentage fixed"`
+	Value    *int64              `json:"value,omitempty" binding:"omitempty,money"`
+	StartsAt *time.Time          `json:"startsAt,omitempty"`
+	EndsAt   *time.Time          `json:"endsAt,omitempty"`
+	Priority *int                `json:"priority,omitempty"`
+}
+
+// UpdateDiscount godoc
+// @Summary Update a discount
+// @Tags Discounts
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "Discount ID"
+// @Param body body updateDiscountBody true "Discount data"
+// @Success 200 {object} model.Discount
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 409 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /discounts/{id} [patch]
+func (h *DiscountHandler) UpdateDiscount(c *gin.Context) {
+	var body updateDiscountBody
+
+	if !bindJSON(c, &body) {
+		return
+	}
+
+	discount, ok := h.loadDiscount(c)
+	if !ok {
+		return
+	}
+
+	if body.Type != nil {
+		discount.Type = *body.Type
+	}
+
+	if body.Value != nil {
+		discount.Value = *body.Value
+	}
+
+	if body.StartsAt != nil {
+		discount.StartsAt = body.StartsAt
+	}
+
+	if body.EndsAt != nil {
+		discount.EndsAt = body.EndsAt
+	}
+
+	if body.Priority != nil {
+		discount.Priority = *body.Priority
+	}
+
+	if !h.saveDiscount(c, discount, false) {
+		return
+	}
+
+	RespondSuccess(c, http.StatusOK, "Discount updated successfully", discount)
+}
+
+// DeleteDiscount godoc
+// @Summary End a discount
+// @Description Soft deletes the discount, the product goes back to its list price unless another discount applies
+// @Tags Discounts
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "Discount ID"
+// @Success 200 {object} map[string]string
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /discounts/{id} [delete]
+func (h *DiscountHandler) DeleteDiscount(c *gin.Context) {
+	discountId, ok := parseId(c, c.Param("id"), "discount")
+	if !ok {
+		return
+	}
+
+	if err := h.discounts.Delete(c.Request.Context(), discountId); err != nil {
+		fail(c, err)
+		return
+	}
+
+	RespondSuccess(c, http.StatusOK, "Discount deleted successfully", nil)
+}
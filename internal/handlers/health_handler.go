@@ -0,0 +1,38 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/Oj-washingtone/savannah-store/internal/service"
+	"github.com/gin-gonic/gin"
+)
+
+// HealthHandler answers the container orchestrator's probes. They sit
+// outside /api and are left out of the API docs.
+type HealthHandler struct {
+	health *service.HealthService
+}
+
+func NewHealthHandler(health *service.HealthService) *HealthHandler {
+	return &HealthHandler{health: health}
+}
+
+// Live answers the liveness probe. It checks nothing beyond the server
+// answering, since restarting it would not fix the database or anything
+// else it depends on.
+func (h *HealthHandler) Live(c *gin.Context) {
+	RespondSuccess(c, http.StatusOK, "Alive", nil)
+}
+
+// Ready answers the readiness probe with 200 when the database is reachable
+// and migrated and 503 when not, with the details either way.
+func (h *HealthHandler) Ready(c *gin.Context) {
+	readiness := h.health.Ready(c.Request.Context())
+
+	if !readiness.Ready {
+		c.JSON(http.StatusServiceUnavailable, ApiResponse{Success: false, Message: "Not ready", Data: readiness})
+		return
+	}
+
+	RespondSuccess(c, http.StatusOK, "Ready", readiness)
+}
@@ -0,0 +1,22 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// parseId reads the id of a thing from a path, query or body value and
+// answers 400 when it is not a UUID. The parser's own message is left out,
+// as it only repeats what was sent.
+func parseId(c *gin.Context, raw, thing string) (uuid.UUID, bool) {
+	id, err := uuid.Parse(raw)
+
+	if err != nil {
+		RespondError(c, http.StatusBadRequest, "Invalid "+thing+" id", "The "+thing+" id must be a UUID")
+		return uuid.Nil, false
+	}
+
+	return id, true
+}
@@ -1,22 +1,33 @@
 package handlers
 
 import (
-	"errors"
-	"fmt"
 	"net/http"
-	"os"
+	"strings"
 
-	"github.com/Oj-washingtone/savannah-store/internal/model"
 	"github.com/Oj-washingtone/savannah-store/internal/repocitory"
 	"github.com/Oj-washingtone/savannah-store/internal/service"
 	"github.com/gin-gonic/gin"
-	"github.com/google/uuid"
-	"github.com/jackc/pgx/v5"
 )
 
+type OrderHandler struct {
+	users        repocitory.UserRepository
+	orders       repocitory.OrdersRepository
+	orderService *service.OrderService
+	invoices     *service.InvoiceService
+}
+
+func NewOrderHandler(repos *repocitory.Repositories, orderService *service.OrderService, invoices *service.InvoiceService) *OrderHandler {
+	return &OrderHandler{
+		users:        repos.Users,
+		orders:       repos.Orders,
+		orderService: orderService,
+		invoices:     invoices,
+	}
+}
+
 // CreateOrder godoc
 // @Summary Create a new order for the authenticated user
-// @Description Creates an order based on the user's cart and persists order items. Calculates total automatically. Requires user authentication.
+// @Description Creates an order based on the user's cart and persists order items. Calculates subtotal, promotion discounts, VAT and total automatically. Requires user authentication.
 // @Tags Orders
 // @Accept json
 // @Produce json
@@ -24,137 +35,144 @@ import (
 // @Success 201 {object} model.Orders "Order created successfully"
 // @Failure 401 {object} map[string]string "Unauthorized"
 // @Failure 404 {object} map[string]string "Cart not found or empty"
+// @Failure 409 {object} map[string]string "A promotion on the cart is no longer available"
 // @Failure 500 {object} map[string]string "Internal server error"
 // @Security ApiKeyAuth
 // @Router /orders/create [post]
-func CreateOrder(c *gin.Context) {
-	userClaims, exists := c.Get("user")
-	if !exists {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+func (h *OrderHandler) CreateOrder(c *gin.Context) {
+	user, ok := currentUser(c, h.users)
+	if !ok {
 		return
 	}
 
-	claims := userClaims.(map[string]interface{})
+	order, err := h.orderService.PlaceOrder(c.Request.Context(), user)
 
-	auth0ID := claims["sub"].(string)
+	if err != nil {
+		fail(c, err)
+		return
+	}
 
-	user, err := repocitory.NewUserRepository().GetByAuth0Id(c, auth0ID)
+	RespondSuccess(c, http.StatusCreated, "Order created successfully", order)
+}
 
-	if err != nil {
-		RespondError(c, http.StatusInternalServerError, "failed to load user account", err.Error())
+// GetAllOrders godoc
+// @Summary Get all orders
+// @Description Fetches a page of orders, newest first.
+// @Tags Orders
+// @Accept json
+// @Produce json
+// @Param limit query int false "Number of orders to return (100 max)" default(10)
+// @Param cursor query string false "nextCursor or prevCursor from the previous page"
+// @Param total query bool false "Include the total number of orders"
+// @Success 200 {array} model.Orders "List of all orders"
+// @Failure 400 {object} map[string]string "Invalid pagination"
+// @Failure 500 {object} map[string]string "Internal server error"
+// @Security ApiKeyAuth
+// @Router /orders [get]
+func (h *OrderHandler) GetAllOrders(c *gin.Context) {
+	page, ok := parsePage(c)
+	if !ok {
 		return
 	}
 
-	cart, err := repocitory.NewShoppingCartRepository().GetShoppingCart(c.Request.Context(), user.ID)
+	orders, info, err := h.orders.GetAll(c.Request.Context(), page)
 
 	if err != nil {
-
-		if errors.Is(err, pgx.ErrNoRows) {
-			RespondError(c, http.StatusNotFound, "cart not found", "No cart found for the user")
-			return
-		}
+		fail(c, err)
+		return
 	}
 
-	// cart items
-
-	cartItemsRepo := repocitory.NewCartItemsRepository()
+	RespondPage(c, http.StatusOK, "Orders fetched successfully", orders, info)
+}
 
-	items, err := cartItemsRepo.GetItems(c.Request.Context(), cart.ID)
+type markOrderPaidBody struct {
+	PaymentReference string `json:"paymentReference" binding:"required,max=100"`
+}
 
-	if err != nil {
-		if errors.Is(err, pgx.ErrNoRows) {
-			RespondError(c, http.StatusNotFound, "Empty cart", "No items found in the cart")
-			return
-		} else {
-			RespondError(c, http.StatusInternalServerError, "failed to load cart items", err.Error())
-			return
-		}
+// MarkOrderPaid godoc
+// @Summary Record the payment of an order
+// @Description Marks the order paid with the payment reference (e.g. the M-Pesa receipt number) and allocates the next invoice number. The customer is emailed the tax invoice.
+// @Tags Orders
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "Order ID"
+// @Param body body markOrderPaidBody true "Payment"
+// @Success 200 {object} model.Orders
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 409 {object} map[string]string "Order already paid or cancelled"
+// @Failure 500 {object} map[string]string
+// @Router /orders/{id}/payments [post]
+func (h *OrderHandler) MarkOrderPaid(c *gin.Context) {
+	orderId, ok := parseId(c, c.Param("id"), "order")
+	if !ok {
+		return
 	}
 
-	var total int64 = 0
-	for _, item := range items {
-		total += item.Price * int64(item.Quantity)
-	}
+	var body markOrderPaidBody
 
-	order := &model.Orders{
-		UserID: user.ID,
-		Total:  total,
+	if !bindJSON(c, &body) {
+		return
 	}
 
-	order.ID = uuid.New()
-
-	theOrder, err := repocitory.NewOrdersRepository().Create(c.Request.Context(), order)
+	order, err := h.orderService.MarkPaid(c.Request.Context(), orderId, strings.TrimSpace(body.PaymentReference))
 
 	if err != nil {
-		RespondError(c, http.StatusInternalServerError, "failed to create order", err.Error())
+		// a paid or cancelled order is a conflict
+		fail(c, err)
 		return
 	}
 
-	var orderItems []*model.OrderItems
-	for _, item := range items {
-		orderItem := &model.OrderItems{
-			OrderID:   theOrder.ID,
-			ProductID: item.ProductId,
-			Quantity:  item.Quantity,
-			Price:     item.Price,
-		}
-
-		orderItem.ID = uuid.New()
-		orderItems = append(orderItems, orderItem)
-	}
-
-	orderItemsRepo := repocitory.NewOrderItemsRepository()
+	RespondSuccess(c, http.StatusOK, "Payment recorded successfully", order)
+}
 
-	err = orderItemsRepo.CreateBulk(c.Request.Context(), orderItems)
+// GetOrderInvoice godoc
+// @Summary Download the invoice of one of my orders
+// @Description Returns the tax invoice of a paid order, or a pro forma invoice if it has not been paid yet
+// @Tags Me
+// @Produce application/pdf
+// @Security BearerAuth
+// @Param id path string true "Order ID"
+// @Success 200 {file} file
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /me/orders/{id}/invoice.pdf [get]
+func (h *OrderHandler) GetOrderInvoice(c *gin.Context) {
+	user, ok := currentUser(c, h.users)
+	if !ok {
+		return
+	}
 
-	if err != nil {
-		RespondError(c, http.StatusInternalServerError, "failed to create order items", err.Error())
+	orderId, ok := parseId(c, c.Param("id"), "order")
+	if !ok {
 		return
 	}
 
-	err = cartItemsRepo.ClearCart(c.Request.Context(), cart.ID)
+	order, err := h.orders.GetById(c.Request.Context(), orderId)
 
 	if err != nil {
-		RespondError(c, http.StatusInternalServerError, "Unable to clear cart", err.Error())
+		fail(c, err)
 		return
 	}
 
-	// send SMS
-	if user.Phone != "" {
-		err := service.SendSMS(user.Phone, "Your order has been received and is being processed")
-
-		if err != nil {
-			fmt.Println("Failed to send SMS:", err)
-		}
+	// someone else's order is reported as missing rather than forbidden
+	if order.UserID != user.ID {
+		RespondError(c, http.StatusNotFound, "Order not found", "No order with this id on your account")
+		return
 	}
 
-	// email to admin
-
-	adminEmail := os.Getenv("ADMIN_EMAIL")
-	emailBody := service.BuildOrderEmailBody(c.Request.Context(), theOrder, orderItems)
-
-	service.SendEmail(adminEmail, "New Order Created", emailBody)
-
-	RespondSuccess(c, http.StatusCreated, "Order created successfully", theOrder)
-}
-
-// GetAllOrders godoc
-// @Summary Get all orders
-// @Description Fetches all orders with associated order items.
-// @Tags Orders
-// @Accept json
-// @Produce json
-// @Success 200 {array} model.Orders "List of all orders"
-// @Failure 500 {object} map[string]string "Internal server error"
-// @Security ApiKeyAuth
-// @Router /orders [get]
-func GetAllOrders(c *gin.Context) {
-	orders, err := repocitory.NewOrdersRepository().GetAll(c.Request.Context())
+	pdf, filename, err := h.invoices.Render(c.Request.Context(), order)
 
 	if err != nil {
-		RespondError(c, http.StatusInternalServerError, "failed to fetch orders", err.Error())
+		fail(c, err)
 		return
 	}
 
-	RespondSuccess(c, http.StatusOK, "Orders fetched successfully", orders)
+	c.Header("Content-Disposition", `inline; filename="`+filename+`"`)
+	c.Data(http.StatusOK, "application/pdf", pdf)
 }
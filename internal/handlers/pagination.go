@@ -0,0 +1,85 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/Oj-washingtone/savannah-store/internal/pagination"
+	"github.com/gin-gonic/gin"
+)
+
+// parsePage reads the limit, cursor and total query parameters of a list
+// endpoint. Lists used to be paged by offset, so a client still sending one
+// is told to use cursor rather than silently getting the first page again.
+// offset=0 is the first page either way and is let through.
+func parsePage(c *gin.Context) (pagination.Page, bool) {
+	page := pagination.Page{Limit: pagination.DefaultLimit}
+
+	if raw := c.Query("offset"); raw != "" && raw != "0" {
+		RespondError(c, http.StatusBadRequest, "Invalid offset", "offset is no longer supported, page with the nextCursor or prevCursor of the previous response as cursor")
+		return page, false
+	}
+
+	if raw := c.Query("limit"); raw != "" {
+		limit, err := strconv.Atoi(raw)
+
+		if err != nil || limit <= 0 || limit > pagination.MaxLimit {
+			RespondError(c, http.StatusBadRequest, "Invalid limit", "limit must be between 1 and "+strconv.Itoa(pagination.MaxLimit))
+			return page, false
+		}
+
+		page.Limit = limit
+	}
+
+	if raw := c.Query("cursor"); raw != "" {
+		cursor, err := pagination.Decode(raw)
+
+		if err != nil {
+			RespondError(c, http.StatusBadRequest, "Invalid cursor", "Use the nextCursor or prevCursor of a previous response")
+			return page, false
+		}
+
+		page.Cursor = cursor
+	}
+
+	if raw := c.Query("total"); raw != "" {
+		withTotal, err := strconv.ParseBool(raw)
+
+		if err != nil {
+			RespondError(c, http.StatusBadRequest, "Invalid total", "total must be true or false")
+			return page, false
+		}
+
+		page.WithTotal = withTotal
+	}
+
+	return page, true
+}
+
+// setPageLinks sets the Link header to the URLs of the next and previous
+// pages, keeping the rest of the request's query.
+func setPageLinks(c *gin.Context, page *pagination.Info) {
+	var links []string
+
+	link := func(cursor *string, rel string) {
+		if cursor == nil {
+			return
+		}
+
+		query := c.Request.URL.Query()
+		query.Set("cursor", *cursor)
+
+		u := *c.Request.URL
+		u.RawQuery = query.Encode()
+
+		links = append(links, "<"+u.RequestURI()+`>; rel="`+rel+`"`)
+	}
+
+	link(page.NextCursor, "next")
+	link(page.PrevCursor, "prev")
+
+	if len(links) > 0 {
+		c.Header("Link", strings.Join(links, ", "))
+	}
+}
@@ -0,0 +1,52 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/Oj-washingtone/savannah-store/internal/pagination"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+func TestParsePage(t *testing.T) {
+	cursor := pagination.Cursor{ID: uuid.New(), Direction: pagination.Next}.Encode()
+
+	for _, tc := range []struct {
+		query string
+		ok    bool
+		limit int
+	}{
+		{"", true, pagination.DefaultLimit},
+		{"limit=100", true, 100},
+		{"limit=101", false, 0},
+		{"limit=0", false, 0},
+		{"limit=ten", false, 0},
+		{"cursor=" + cursor, true, pagination.DefaultLimit},
+		{"cursor=garbage", false, 0},
+		{"offset=0&limit=5", true, 5},
+		{"offset=20", false, 0},
+		{"total=maybe", false, 0},
+	} {
+		t.Run(tc.query, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			c, _ := gin.CreateTestContext(w)
+			c.Request = httptest.NewRequest(http.MethodGet, "/products?"+tc.query, nil)
+
+			page, ok := parsePage(c)
+
+			if ok != tc.ok {
+				t.Fatalf("got ok %v, want %v", ok, tc.ok)
+			}
+
+			if !ok && w.Code != http.StatusBadRequest {
+				t.Errorf("got status %d, want 400", w.Code)
+			}
+
+			if ok && page.Limit != tc.limit {
+				t.Errorf("got limit %d, want %d", page.Limit, tc.limit)
+			}
+		})
+	}
+}
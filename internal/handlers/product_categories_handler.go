@@ -1,48 +1,95 @@
 package handlers
 
 import (
+	"context"
+	"errors"
 	"net/http"
-	"strconv"
 	"strings"
 
 	"github.com/Oj-washingtone/savannah-store/internal/model"
 	"github.com/Oj-washingtone/savannah-store/internal/repocitory"
+	"github.com/Oj-washingtone/savannah-store/internal/service"
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
 )
 
+type CategoryHandler struct {
+	categories      repocitory.CategoryRepository
+	products        repocitory.ProductRepository
+	categoryService *service.CategoryService
+	productService  *service.ProductService
+}
+
+func NewCategoryHandler(repos *repocitory.Repositories, categoryService *service.CategoryService, productService *service.ProductService) *CategoryHandler {
+	return &CategoryHandler{
+		categories:      repos.Categories,
+		products:        repos.Products,
+		categoryService: categoryService,
+		productService:  productService,
+	}
+}
+
 type CreateCategoryBody struct {
-	Name     string     `json:"name" binding:"required"`
-	ParentId *uuid.UUID `json:"parentId"`
+	Name     string     `json:"name" binding:"required,max=100"`
+	Slug     *string    `json:"slug" binding:"omitempty,max=120"`
+	ParentId *uuid.UUID `json:"parentId" binding:"omitempty,exists=category"`
+	model.SEO
+}
+
+// categorySlug checks the slug asked for a category, or makes one from its
+// name.
+func (h *CategoryHandler) categorySlug(c *gin.Context, requested *string, name string, categoryId uuid.UUID) (string, bool) {
+	return resolveSlug(c, "category", requested, name, func(ctx context.Context, s string) (bool, error) {
+		return h.categories.SlugTaken(ctx, s, categoryId)
+	})
 }
 
 // CreateCategory godoc
 // @Summary Create a new category
+// @Description The slug is made from the name when none is given.
 // @Tags categories
 // @Accept json
 // @Produce json
 // @Param body body CreateCategoryBody true "Category body"
 // @Success 201 {object} model.ProductCategory
 // @Failure 400 {object} map[string]string
+// @Failure 409 {object} map[string]string
+// @Failure 500 {object} map[string]string
 // @Router /products/categories/create [post]
-func AddProductCategory(c *gin.Context) {
+func (h *CategoryHandler) AddProductCategory(c *gin.Context) {
 	var body CreateCategoryBody
 
-	if err := c.ShouldBindJSON(&body); err != nil {
-		RespondError(c, http.StatusBadRequest, "Invalid request body", err.Error())
+	if !bindJSON(c, &body) {
+		return
+	}
+
+	name := strings.TrimSpace(body.Name)
+	if name == "" {
+		RespondError(c, http.StatusBadRequest, "Invalid name", "Category name cannot be empty")
+		return
+	}
+
+	if !cleanSEO(c, &body.SEO) {
 		return
 	}
 
 	category := &model.ProductCategory{
-		Name:     strings.ToLower(body.Name),
+		Name:     name,
 		ParentId: body.ParentId,
+		SEO:      body.SEO,
 	}
 	category.ID = uuid.New()
 
-	err := repocitory.NewCategoryRepository().Create(c, category)
+	var ok bool
+	if category.Slug, ok = h.categorySlug(c, body.Slug, name, category.ID); !ok {
+		return
+	}
+
+	err := h.categories.Create(c.Request.Context(), category)
 
 	if err != nil {
-		RespondError(c, http.StatusInternalServerError, "Failled to create category", err.Error())
+		fail(c, err)
 		return
 	}
 
@@ -51,49 +98,146 @@ func AddProductCategory(c *gin.Context) {
 
 // ListCategories godoc
 // @Summary List product categories
-// @Description Returns paginated list of product categories
+// @Description Returns a page of product categories, newest first
 // @Tags categories
 // @Produce json
-// @Param limit query int false "Number of categories to return" default(10)
-// @Param offset query int false "Offset for pagination" default(0)
+// @Param limit query int false "Number of categories to return (100 max)" default(10)
+// @Param cursor query string false "nextCursor or prevCursor from the previous page"
+// @Param total query bool false "Include the total number of categories"
 // @Success 200 {array} model.ProductCategory
 // @Failure 400 {object} map[string]string
 // @Failure 500 {object} map[string]string
 // @Router /products/categories [get]
-func ListCategories(c *gin.Context) {
-	limit, err := strconv.Atoi(c.DefaultQuery("limit", "10"))
+func (h *CategoryHandler) ListCategories(c *gin.Context) {
+	page, ok := parsePage(c)
+	if !ok {
+		return
+	}
+
+	categories, info, err := h.categories.List(c.Request.Context(), page)
 
-	if err != nil || limit <= 0 {
-		RespondError(c, http.StatusBadRequest, "Invalid limit", err.Error())
+	if err != nil {
+		fail(c, err)
 		return
 	}
 
-	offset, err := strconv.Atoi(c.DefaultQuery("offset", "0"))
+	RespondPage(c, http.StatusOK, "Success", categories, info)
+}
 
-	if err != nil || offset < 0 {
-		RespondError(c, http.StatusBadRequest, "Invalid offset", err.Error())
+// GetCategoryTree godoc
+// @Summary Get the category tree
+// @Description Returns the top level categories with their subcategories nested under children. Every category has its breadcrumbs, the number of products directly in it and the number including its subcategories.
+// @Tags categories
+// @Produce json
+// @Success 200 {array} model.CategoryNode
+// @Failure 500 {object} map[string]string
+// @Router /products/categories/tree [get]
+func (h *CategoryHandler) GetCategoryTree(c *gin.Context) {
+	roots, _, err := h.categoryService.Tree(c.Request.Context())
+
+	if err != nil {
+		fail(c, err)
 		return
 	}
 
-	categoryRepo := repocitory.NewCategoryRepository()
-	categories, err := categoryRepo.List(c.Request.Context(), limit, offset)
+	RespondSuccess(c, http.StatusOK, "Category tree fetched successfully", roots)
+}
+
+// GetCategory godoc
+// @Summary Get a category
+// @Description Returns the category with its breadcrumbs, product counts and subcategories.
+// @Tags categories
+// @Produce json
+// @Param id path string true "Category ID"
+// @Success 200 {object} model.CategoryNode
+// @Failure 400 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /products/categories/{id} [get]
+func (h *CategoryHandler) GetCategory(c *gin.Context) {
+	categoryId, ok := parseId(c, c.Param("id"), "category")
+	if !ok {
+		return
+	}
+
+	_, tree, err := h.categoryService.Tree(c.Request.Context())
+
+	if err != nil {
+		fail(c, err)
+		return
+	}
+
+	node, ok := tree[categoryId]
+
+	if !ok {
+		RespondError(c, http.StatusNotFound, "Category not found", "No category with this id")
+		return
+	}
+
+	RespondSuccess(c, http.StatusOK, "Category fetched successfully", node)
+}
+
+// ListCategoryProducts godoc
+// @Summary List the products of a category
+// @Description Returns a page of the products in the category and all its subcategories, newest first.
+// @Tags categories
+// @Produce json
+// @Param id path string true "Category ID"
+// @Param limit query int false "Number of products to return (100 max)" default(10)
+// @Param cursor query string false "nextCursor or prevCursor from the previous page"
+// @Param total query bool false "Include the total number of products"
+// @Success 200 {array} model.Product
+// @Failure 400 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /products/categories/{id}/products [get]
+func (h *CategoryHandler) ListCategoryProducts(c *gin.Context) {
+	categoryId, ok := parseId(c, c.Param("id"), "category")
+	if !ok {
+		return
+	}
+
+	page, ok := parsePage(c)
+	if !ok {
+		return
+	}
+
+	if _, err := h.categories.GetById(c.Request.Context(), categoryId); err != nil {
+		fail(c, err)
+		return
+	}
+
+	products, info, err := h.products.ListByCategory(c.Request.Context(), categoryId, page)
 
 	if err != nil {
-		RespondError(c, http.StatusInternalServerError, "failled to fetch categories", err.Error())
+		fail(c, err)
+		return
+	}
+
+	withImages := make([]*model.Product, len(products))
+	for i := range products {
+		withImages[i] = &products[i]
+	}
+
+	if err := h.productService.AttachProductImages(c.Request.Context(), withImages...); err != nil {
+		fail(c, err)
 		return
 	}
 
-	RespondSuccess(c, http.StatusOK, "Success", categories)
+	RespondPage(c, http.StatusOK, "Products found", products, info)
 }
 
 type updateCategoryBody struct {
-	Name     *string    `json:"name,omitempty"`
-	ParentId *uuid.UUID `json:"parentId,omitempty"`
+	Name            *string    `json:"name,omitempty" binding:"omitempty,max=100"`
+	Slug            *string    `json:"slug,omitempty" binding:"omitempty,max=120"`
+	ParentId        *uuid.UUID `json:"parentId,omitempty" binding:"omitempty,exists=category"`
+	MetaTitle       *string    `json:"metaTitle,omitempty" binding:"omitempty,max=100"`
+	MetaDescription *string    `json:"metaDescription,omitempty" binding:"omitempty,max=300"`
 }
 
 // UpdateCategory godoc
 // @Summary Update a category
-// @Description Update a category by ID
+// @Description Update a category by ID. A category cannot be moved below itself or one of its subcategories. The slug only changes when a new one is given, renaming keeps it.
 // @Tags categories
 // @Accept json
 // @Produce json
@@ -102,47 +246,204 @@ type updateCategoryBody struct {
 // @Success 200 {object} model.ProductCategory
 // @Failure 400 {object} map[string]string
 // @Failure 404 {object} map[string]string
+// @Failure 409 {object} map[string]string
 // @Failure 500 {object} map[string]string
 // @Router /products/categories/{id} [patch]
-func UpdateCategory(c *gin.Context) {
+func (h *CategoryHandler) UpdateCategory(c *gin.Context) {
 	idParam := c.Param("id")
 
-	categoryID, err := uuid.Parse(idParam)
-
-	if err != nil {
-		RespondError(c, http.StatusBadRequest, "Invaluid category id", err.Error())
-
+	categoryID, ok := parseId(c, idParam, "category")
+	if !ok {
 		return
 	}
 
 	var body updateCategoryBody
-	if err := c.ShouldBindJSON(&body); err != nil {
-		RespondError(c, http.StatusBadRequest, "Invalid request body", err.Error())
-
+	if !bindJSON(c, &body) {
 		return
 	}
 
-	categoryRepo := repocitory.NewCategoryRepository()
-
-	category, err := categoryRepo.GetById(c.Request.Context(), categoryID)
+	category, err := h.categories.GetById(c.Request.Context(), categoryID)
 	if err != nil {
-		RespondError(c, http.StatusNotFound, "Category not found", err.Error())
+		fail(c, err)
 		return
 	}
 
 	if body.Name != nil {
-		category.Name = *body.Name
+		category.Name = strings.TrimSpace(*body.Name)
+
+		if category.Name == "" {
+			RespondError(c, http.StatusBadRequest, "Invalid name", "Category name cannot be empty")
+			return
+		}
+	}
+
+	if body.Slug != nil && *body.Slug != category.Slug {
+		if category.Slug, ok = h.categorySlug(c, body.Slug, category.Name, category.ID); !ok {
+			return
+		}
+	}
+
+	if body.MetaTitle != nil {
+		category.MetaTitle = *body.MetaTitle
+	}
+
+	if body.MetaDescription != nil {
+		category.MetaDescription = *body.MetaDescription
+	}
+
+	if !cleanSEO(c, &category.SEO) {
+		return
 	}
 
 	if body.ParentId != nil {
+		cycle, err := h.categoryService.WouldCreateCycle(c.Request.Context(), category.ID, *body.ParentId)
+
+		if err != nil {
+			fail(c, err)
+			return
+		}
+
+		if cycle {
+			RespondError(c, http.StatusConflict, "Invalid parent category", "A category cannot be moved below itself or one of its subcategories")
+			return
+		}
+
 		category.ParentId = body.ParentId
 	}
 
-	if err := categoryRepo.Update(c.Request.Context(), category); err != nil {
-		RespondError(c, http.StatusInternalServerError, "failed to update category", err.Error())
+	if err := h.categories.Update(c.Request.Context(), category); err != nil {
+		fail(c, err)
 
 		return
 	}
 
 	RespondSuccess(c, http.StatusOK, "category updated successfully", category)
 }
+
+// GetCategoryBySlug godoc
+// @Summary Get a category by its slug
+// @Tags categories
+// @Produce json
+// @Param slug path string true "Category slug"
+// @Success 200 {object} model.ProductCategory
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /products/categories/slug/{slug} [get]
+func (h *CategoryHandler) GetCategoryBySlug(c *gin.Context) {
+	category, err := h.categories.GetBySlug(c.Request.Context(), c.Param("slug"))
+
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			RespondError(c, http.StatusNotFound, "Category not found", "No category with this slug")
+			return
+		}
+
+		fail(c, err)
+		return
+	}
+
+	RespondSuccess(c, http.StatusOK, "Category fetched successfully", category)
+}
+
+var categoryDeletePolicies = map[model.CategoryDeletePolicy]bool{
+	model.CategoryDeleteBlock:    true,
+	model.CategoryDeleteReassign: true,
+	model.CategoryDeleteCascade:  true,
+}
+
+// DeleteCategory godoc
+// @Summary Delete a category
+// @Description Soft deletes a category. With policy block (the default) a category that still has subcategories or products is not deleted. With reassign its subcategories and products move to reassignTo, or to its parent when reassignTo is left out. With cascade its subcategories and all their products are deleted too.
+// @Tags categories
+// @Produce json
+// @Param id path string true "Category ID"
+// @Param policy query string false "block, reassign or cascade" default(block)
+// @Param reassignTo query string false "Category ID to move the subcategories and products to"
+// @Success 200 {object} map[string]string
+// @Failure 400 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 409 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Security BearerAuth
+// @Router /products/categories/{id} [delete]
+func (h *CategoryHandler) DeleteCategory(c *gin.Context) {
+	categoryId, ok := parseId(c, c.Param("id"), "category")
+	if !ok {
+		return
+	}
+
+	policy := model.CategoryDeletePolicy(c.DefaultQuery("policy", string(model.CategoryDeleteBlock)))
+
+	if !categoryDeletePolicies[policy] {
+		RespondError(c, http.StatusBadRequest, "Invalid policy", "policy must be block, reassign or cascade")
+		return
+	}
+
+	category, err := h.categories.GetById(c.Request.Context(), categoryId)
+
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			RespondError(c, http.StatusNotFound, "Category not found", "No category with this id")
+			return
+		}
+
+		fail(c, err)
+		return
+	}
+
+	var reassignTo *uuid.UUID
+
+	if policy == model.CategoryDeleteReassign {
+		reassignTo = category.ParentId
+
+		if raw := c.Query("reassignTo"); raw != "" {
+			targetId, err := uuid.Parse(raw)
+
+			if err != nil {
+				RespondError(c, http.StatusBadRequest, "Invalid reassignTo", "reassignTo must be the UUID of a category")
+				return
+			}
+
+			reassignTo = &targetId
+		}
+
+		if reassignTo == nil {
+			RespondError(c, http.StatusBadRequest, "Nowhere to reassign to", "The category has no parent, give reassignTo")
+			return
+		}
+
+		if _, err := h.categories.GetById(c.Request.Context(), *reassignTo); err != nil {
+			if errors.Is(err, pgx.ErrNoRows) {
+				RespondError(c, http.StatusBadRequest, "Category to reassign to not found", "No category with id "+reassignTo.String())
+				return
+			}
+
+			fail(c, err)
+			return
+		}
+
+		below, err := h.categoryService.WouldCreateCycle(c.Request.Context(), categoryId, *reassignTo)
+
+		if err != nil {
+			fail(c, err)
+			return
+		}
+
+		if below {
+			RespondError(c, http.StatusBadRequest, "Invalid reassignTo", "Cannot reassign to the category itself or one of its subcategories")
+			return
+		}
+	}
+
+	if err := h.categories.Delete(c.Request.Context(), categoryId, policy, reassignTo); err != nil {
+		switch {
+		case errors.Is(err, repocitory.ErrCategoryNotEmpty):
+			RespondError(c, http.StatusConflict, "Category is not empty", "Move or delete its subcategories and products first, or use policy reassign or cascade")
+		default:
+			fail(c, err)
+		}
+		return
+	}
+
+	RespondSuccess(c, http.StatusOK, "Category deleted successfully", nil)
+}
@@ -0,0 +1,383 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/Oj-washingtone/savannah-store/internal/model"
+	"github.com/Oj-washingtone/savannah-store/internal/repocitory"
+	"github.com/Oj-washingtone/savannah-store/internal/service"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+)
+
+type ProductImageHandler struct {
+	products       repocitory.ProductRepository
+	variants       repocitory.ProductVariantRepository
+	images         repocitory.ProductImageRepository
+	productService *service.ProductService
+}
+
+func NewProductImageHandler(repos *repocitory.Repositories, productService *service.ProductService) *ProductImageHandler {
+	return &ProductImageHandler{
+		products:       repos.Products,
+		variants:       repos.Variants,
+		images:         repos.Images,
+		productService: productService,
+	}
+}
+
+const maxAltTextLength = 100
+
+// loadImageProduct parses the product id from the path and checks the
+// product exists.
+func (h *ProductImageHandler) loadImageProduct(c *gin.Context) (uuid.UUID, bool) {
+	productId, ok := parseId(c, c.Param("id"), "product")
+	if !ok {
+		return uuid.Nil, false
+	}
+
+	if _, err := h.products.GetById(c.Request.Context(), productId); err != nil {
+		fail(c, err)
+		return uuid.Nil, false
+	}
+
+	return productId, true
+}
+
+// loadProductImage loads the image from the path, making sure it belongs to
+// the product in the path.
+func (h *ProductImageHandler) loadProductImage(c *gin.Context, productId uuid.UUID) (*model.ProductImage, bool) {
+	imageId, ok := parseId(c, c.Param("imageId"), "image")
+	if !ok {
+		return nil, false
+	}
+
+	image, err := h.images.GetById(c.Request.Context(), imageId)
+
+	if err != nil && !errors.Is(err, pgx.ErrNoRows) {
+		fail(c, err)
+		return nil, false
+	}
+
+	if err != nil || image.ProductID != productId {
+		RespondError(c, http.StatusNotFound, "Image not found", "No image with this id on the product")
+		return nil, false
+	}
+
+	return image, true
+}
+
+// UploadProductImage godoc
+// @Summary Upload a product image
+// @Description Uploads a JPEG, PNG, GIF or WebP image (5MB max by default). The type is worked out from the file contents. EXIF metadata is stripped and thumbnail, medium and large variants are made, each also as WebP. The first image of a product becomes its primary image; later ones are added at the end.
+// @Tags products
+// @Accept multipart/form-data
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "Product ID"
+// @Param image formData file true "Image file"
+// @Param altText formData string false "Alt text (100 characters max)"
+// @Success 201 {object} model.ProductImage
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 413 {object} map[string]string
+// @Failure 415 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /products/{id}/images [post]
+func (h *ProductImageHandler) UploadProductImage(c *gin.Context) {
+	maxSize := h.productService.MaxImageSize()
+
+	// leave room for the rest of the multipart body on top of the file
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxSize+1<<20)
+
+	productId, ok := h.loadImageProduct(c)
+	if !ok {
+		return
+	}
+
+	header, err := c.FormFile("image")
+
+	if err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			RespondError(c, http.StatusRequestEntityTooLarge, "Image too large", fmt.Sprintf("Images can be at most %d bytes", maxSize))
+			return
+		}
+
+		RespondError(c, http.StatusBadRequest, "Missing image", "Send the image as the image field of a multipart form")
+		return
+	}
+
+	if header.Size > maxSize {
+		RespondError(c, http.StatusRequestEntityTooLarge, "Image too large", fmt.Sprintf("Images can be at most %d bytes", maxSize))
+		return
+	}
+
+	altText := strings.TrimSpace(c.PostForm("altText"))
+
+	if len(altText) > maxAltTextLength {
+		RespondError(c, http.StatusBadRequest, "Alt text too long", fmt.Sprintf("altText can be at most %d characters", maxAltTextLength))
+		return
+	}
+
+	file, err := header.Open()
+
+	if err != nil {
+		RespondError(c, http.StatusBadRequest, "Unreadable image", "The uploaded image could not be read")
+		return
+	}
+	defer file.Close()
+
+	image, err := h.productService.UploadProductImage(c.Request.Context(), productId, file, altText)
+
+	if err != nil {
+		fail(c, err)
+		return
+	}
+
+	RespondSuccess(c, http.StatusCreated, "Image uploaded successfully", image)
+}
+
+// ListProductImages godoc
+// @Summary List the images of a product
+// @Tags products
+// @Produce json
+// @Param id path string true "Product ID"
+// @Success 200 {array} model.ProductImage
+// @Failure 400 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /products/{id}/images [get]
+func (h *ProductImageHandler) ListProductImages(c *gin.Context) {
+	productId, ok := h.loadImageProduct(c)
+	if !ok {
+		return
+	}
+
+	images, err := h.images.ListByProduct(c.Request.Context(), productId)
+
+	if err != nil {
+		fail(c, err)
+		return
+	}
+
+	if images == nil {
+		images = []*model.ProductImage{}
+	}
+
+	RespondSuccess(c, http.StatusOK, "Images fetched successfully", images)
+}
+
+type updateProductImageBody struct {
+	AltText   *string `json:"altText,omitempty" binding:"omitempty,max=100"`
+	IsPrimary *bool   `json:"isPrimary,omitempty"`
+	// VariantID ties the image to a variant of the product, an empty string
+	// unties it
+	VariantID *string `json:"variantId,omitempty" binding:"omitempty,uuid"`
+}
+
+// UpdateProductImage godoc
+// @Summary Update a product image
+// @Description Changes the alt text, ties the image to a variant or makes the image the primary image of the product. The primary image cannot be unset directly, make another image primary instead.
+// @Tags products
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "Product ID"
+// @Param imageId path string true "Image ID"
+// @Param body body updateProductImageBody true "Image data"
+// @Success 200 {object} model.ProductImage
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /products/{id}/images/{imageId} [patch]
+func (h *ProductImageHandler) UpdateProductImage(c *gin.Context) {
+	var body updateProductImageBody
+
+	if !bindJSON(c, &body) {
+		return
+	}
+
+	productId, ok := h.loadImageProduct(c)
+	if !ok {
+		return
+	}
+
+	image, ok := h.loadProductImage(c, productId)
+	if !ok {
+		return
+	}
+
+	if body.AltText != nil {
+		altText := strings.TrimSpace(*body.AltText)
+
+		if len(altText) > maxAltTextLength {
+			RespondError(c, http.StatusBadRequest, "Alt text too long", fmt.Sprintf("altText can be at most %d characters", maxAltTextLength))
+			return
+		}
+
+		if err := h.images.UpdateAltText(c.Request.Context(), image.ID, altText); err != nil {
+			fail(c, err)
+			return
+		}
+	}
+
+	if body.VariantID != nil {
+		var variantId *uuid.UUID
+
+		if *body.VariantID != "" {
+			id, ok := parseId(c, *body.VariantID, "variant")
+			if !ok {
+				return
+			}
+
+			variant, err := h.variants.GetById(c.Request.Context(), id)
+
+			if err != nil && !errors.Is(err, pgx.ErrNoRows) {
+				fail(c, err)
+				return
+			}
+
+			if err != nil || variant.ProductID != productId {
+				RespondError(c, http.StatusBadRequest, "Unknown variant", "Variant "+id.String()+" does not belong to this product")
+				return
+			}
+
+			variantId = &id
+		}
+
+		if err := h.images.SetVariant(c.Request.Context(), image.ID, variantId); err != nil {
+			fail(c, err)
+			return
+		}
+	}
+
+	if body.IsPrimary != nil {
+		if !*body.IsPrimary {
+			RespondError(c, http.StatusBadRequest, "Cannot unset primary image", "Make another image primary instead")
+			return
+		}
+
+		if err := h.images.SetPrimary(c.Request.Context(), productId, image.ID); err != nil {
+			fail(c, err)
+			return
+		}
+	}
+
+	image, err := h.images.GetById(c.Request.Context(), image.ID)
+
+	if err != nil {
+		fail(c, err)
+		return
+	}
+
+	RespondSuccess(c, http.StatusOK, "Image updated successfully", image)
+}
+
+type reorderProductImagesBody struct {
+	ImageIds []uuid.UUID `json:"imageIds" binding:"required,min=1"`
+}
+
+// ReorderProductImages godoc
+// @Summary Reorder the images of a product
+// @Description Puts the images in the order given. Images left out keep their order after the listed ones.
+// @Tags products
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "Product ID"
+// @Param body body reorderProductImagesBody true "Image ids in display order"
+// @Success 200 {array} model.ProductImage
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /products/{id}/images/order [put]
+func (h *ProductImageHandler) ReorderProductImages(c *gin.Context) {
+	var body reorderProductImagesBody
+
+	if !bindJSON(c, &body) {
+		return
+	}
+
+	productId, ok := h.loadImageProduct(c)
+	if !ok {
+		return
+	}
+
+	images, err := h.images.ListByProduct(c.Request.Context(), productId)
+
+	if err != nil {
+		fail(c, err)
+		return
+	}
+
+	known := map[uuid.UUID]bool{}
+	for _, image := range images {
+		known[image.ID] = true
+	}
+
+	for _, id := range body.ImageIds {
+		if !known[id] {
+			RespondError(c, http.StatusBadRequest, "Unknown image", "Image "+id.String()+" does not belong to this product")
+			return
+		}
+	}
+
+	if err := h.images.Reorder(c.Request.Context(), productId, body.ImageIds); err != nil {
+		fail(c, err)
+		return
+	}
+
+	images, err = h.images.ListByProduct(c.Request.Context(), productId)
+
+	if err != nil {
+		fail(c, err)
+		return
+	}
+
+	RespondSuccess(c, http.StatusOK, "Images reordered successfully", images)
+}
+
+// DeleteProductImage godoc
+// @Summary Delete a product image
+// @Description Removes the image. If it was the primary image, the next image takes its place.
+// @Tags products
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "Product ID"
+// @Param imageId path string true "Image ID"
+// @Success 200 {object} map[string]string
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /products/{id}/images/{imageId} [delete]
+func (h *ProductImageHandler) DeleteProductImage(c *gin.Context) {
+	productId, ok := h.loadImageProduct(c)
+	if !ok {
+		return
+	}
+
+	image, ok := h.loadProductImage(c, productId)
+	if !ok {
+		return
+	}
+
+	if err := h.productService.DeleteProductImage(c.Request.Context(), image); err != nil {
+		fail(c, err)
+		return
+	}
+
+	RespondSuccess(c, http.StatusOK, "Image deleted successfully", nil)
+}
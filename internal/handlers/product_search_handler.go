@@ -0,0 +1,157 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/Oj-washingtone/savannah-store/internal/model"
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	maxSearchQueryLength = 200
+	defaultSearchLimit   = 20
+)
+
+var productSorts = map[model.ProductSort]bool{
+	model.SortRelevance: true,
+	model.SortPriceAsc:  true,
+	model.SortPriceDesc: true,
+	model.SortNewest:    true,
+	model.SortName:      true,
+}
+
+// queryPrice reads an optional price filter from the query string.
+func queryPrice(c *gin.Context, name string) (*int64, bool) {
+	raw := c.Query(name)
+	if raw == "" {
+		return nil, true
+	}
+
+	price, err := strconv.ParseInt(raw, 10, 64)
+
+	if err != nil || price < 0 {
+		RespondError(c, http.StatusBadRequest, "Invalid "+name, name+" must be a whole number of Ksh, zero or more")
+		return nil, false
+	}
+
+	return &price, true
+}
+
+// queryFlag reads an optional true/false filter from the query string.
+func queryFlag(c *gin.Context, name string) (bool, bool) {
+	raw := c.Query(name)
+	if raw == "" {
+		return false, true
+	}
+
+	flag, err := strconv.ParseBool(raw)
+
+	if err != nil {
+		RespondError(c, http.StatusBadRequest, "Invalid "+name, name+" must be true or false")
+		return false, false
+	}
+
+	return flag, true
+}
+
+// SearchProducts godoc
+// @Summary Search products
+// @Description Full-text search over product names and descriptions that also finds names with typos. Filters narrow the results, price filters use the price after any running sale. Facets count the matches per category and price bucket, each ignoring its own filter. Follow pagination.nextCursor (or the Link header) for the next page; ties in the sort come newest first.
+// @Tags products
+// @Produce json
+// @Param q query string false "Search text, e.g. running shoes or \"red dress\" -long"
+// @Param category query string false "Category ID, includes its subcategories"
+// @Param minPrice query int false "Lowest price"
+// @Param maxPrice query int false "Highest price"
+// @Param inStock query bool false "Only products in stock"
+// @Param onSale query bool false "Only products on sale"
+// @Param sort query string false "relevance, price_asc, price_desc, newest or name" default(relevance)
+// @Param limit query int false "Number of products to return (100 max)" default(20)
+// @Param cursor query string false "nextCursor or prevCursor from the previous page of the same search"
+// @Param total query bool false "Include the total number of matches in pagination.total"
+// @Success 200 {object} model.ProductSearchResult
+// @Failure 400 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /products/search [get]
+func (h *ProductHandler) SearchProducts(c *gin.Context) {
+	search := &model.ProductSearch{
+		Query: strings.TrimSpace(c.Query("q")),
+		Sort:  model.ProductSort(c.DefaultQuery("sort", string(model.SortRelevance))),
+	}
+
+	if len(search.Query) > maxSearchQueryLength {
+		RespondError(c, http.StatusBadRequest, "Search text too long", "q can be at most "+strconv.Itoa(maxSearchQueryLength)+" characters")
+		return
+	}
+
+	if !productSorts[search.Sort] {
+		RespondError(c, http.StatusBadRequest, "Invalid sort", "sort must be relevance, price_asc, price_desc, newest or name")
+		return
+	}
+
+	if raw := c.Query("category"); raw != "" {
+		categoryId, ok := parseId(c, raw, "category")
+		if !ok {
+			return
+		}
+
+		search.CategoryID = &categoryId
+	}
+
+	var ok bool
+
+	if search.MinPrice, ok = queryPrice(c, "minPrice"); !ok {
+		return
+	}
+
+	if search.MaxPrice, ok = queryPrice(c, "maxPrice"); !ok {
+		return
+	}
+
+	if search.MinPrice != nil && search.MaxPrice != nil && *search.MinPrice > *search.MaxPrice {
+		RespondError(c, http.StatusBadRequest, "Invalid price range", "minPrice cannot be more than maxPrice")
+		return
+	}
+
+	if search.InStock, ok = queryFlag(c, "inStock"); !ok {
+		return
+	}
+
+	if search.OnSale, ok = queryFlag(c, "onSale"); !ok {
+		return
+	}
+
+	page, ok := parsePage(c)
+	if !ok {
+		return
+	}
+
+	if c.Query("limit") == "" {
+		page.Limit = defaultSearchLimit
+	}
+
+	page.Sort = string(search.Sort)
+
+	if !page.Fits() {
+		RespondError(c, http.StatusBadRequest, "Invalid cursor", "The cursor belongs to a search with another sort")
+		return
+	}
+
+	search.Page = page
+
+	result, info, err := h.products.Search(c.Request.Context(), search)
+
+	if err != nil {
+		fail(c, err)
+		return
+	}
+
+	if err := h.productService.AttachProductImages(c.Request.Context(), result.Products...); err != nil {
+		fail(c, err)
+		return
+	}
+
+	RespondPage(c, http.StatusOK, "Products found", result, info)
+}
@@ -0,0 +1,656 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/Oj-washingtone/savannah-store/internal/model"
+	"github.com/Oj-washingtone/savannah-store/internal/repocitory"
+	"github.com/Oj-washingtone/savannah-store/internal/service"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+)
+
+type VariantHandler struct {
+	products       repocitory.ProductRepository
+	variants       repocitory.ProductVariantRepository
+	productService *service.ProductService
+}
+
+func NewVariantHandler(repos *repocitory.Repositories, productService *service.ProductService) *VariantHandler {
+	return &VariantHandler{
+		products:       repos.Products,
+		variants:       repos.Variants,
+		productService: productService,
+	}
+}
+
+const (
+	maxOptionLength = 50
+	maxSKULength    = 64
+)
+
+// loadVariantProduct parses the product id from the path and loads the
+// product.
+func (h *VariantHandler) loadVariantProduct(c *gin.Context) (*model.Product, bool) {
+	productId, ok := parseId(c, c.Param("id"), "product")
+	if !ok {
+		return nil, false
+	}
+
+	product, err := h.products.GetById(c.Request.Context(), productId)
+
+	if err != nil {
+		fail(c, err)
+		return nil, false
+	}
+
+	return product, true
+}
+
+// loadProductVariant loads the variant from the path, making sure it belongs
+// to the product.
+func (h *VariantHandler) loadProductVariant(c *gin.Context, product *model.Product) (*model.ProductVariant, bool) {
+	variantId, ok := parseId(c, c.Param("variantId"), "variant")
+	if !ok {
+		return nil, false
+	}
+
+	variant, err := h.variants.GetById(c.Request.Context(), variantId)
+
+	if err != nil && !errors.Is(err, pgx.ErrNoRows) {
+		fail(c, err)
+		return nil, false
+	}
+
+	if err != nil || variant.ProductID != product.ID {
+		RespondError(c, http.StatusNotFound, "Variant not found", "No variant with this id on the product")
+		return nil, false
+	}
+
+	return variant, true
+}
+
+// loadProductOption finds the option from the path among the product's
+// options.
+func (h *VariantHandler) loadProductOption(c *gin.Context, options []*model.ProductOption) (*model.ProductOption, bool) {
+	optionId, ok := parseId(c, c.Param("optionId"), "option")
+	if !ok {
+		return nil, false
+	}
+
+	for _, option := range options {
+		if option.ID == optionId {
+			return option, true
+		}
+	}
+
+	RespondError(c, http.StatusNotFound, "Option not found", "No option with this id on the product")
+	return nil, false
+}
+
+// cleanOptionText trims an option name or value and checks its length.
+func cleanOptionText(c *gin.Context, field, text string) (string, bool) {
+	text = strings.TrimSpace(text)
+
+	if text == "" || len(text) > maxOptionLength {
+		RespondError(c, http.StatusBadRequest, "Invalid "+field, fmt.Sprintf("%s must be between 1 and %d characters", field, maxOptionLength))
+		return "", false
+	}
+
+	return text, true
+}
+
+// checkSKU trims the SKU and makes sure no other variant or product uses it.
+// An empty SKU comes back as nil.
+func (h *VariantHandler) checkSKU(c *gin.Context, sku *string, productId, variantId uuid.UUID) (*string, bool) {
+	if sku == nil {
+		return nil, true
+	}
+
+	trimmed := strings.TrimSpace(*sku)
+	if trimmed == "" {
+		return nil, true
+	}
+
+	if len(trimmed) > maxSKULength {
+		RespondError(c, http.StatusBadRequest, "Invalid SKU", fmt.Sprintf("sku can be at most %d characters", maxSKULength))
+		return nil, false
+	}
+
+	taken, err := h.variants.SKUTaken(c.Request.Context(), trimmed, productId, variantId)
+
+	if err != nil {
+		fail(c, err)
+		return nil, false
+	}
+
+	if taken {
+		RespondError(c, http.StatusConflict, "SKU already in use", "Another variant or product already has the SKU "+trimmed)
+		return nil, false
+	}
+
+	return &trimmed, true
+}
+
+type createProductOptionBody struct {
+	Name   string   `json:"name" binding:"required,max=50"`
+	Values []string `json:"values" binding:"required,min=1,dive,required,max=50"`
+}
+
+// CreateProductOption godoc
+// @Summary Add an option to a product
+// @Description Adds an option such as "Size" with its values, e.g. ["S", "M", "L"]. Options can only be added while the product has no variants with options.
+// @Tags products
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "Product ID"
+// @Param body body createProductOptionBody true "Option"
+// @Success 201 {object} model.ProductOption
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 409 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /products/{id}/options [post]
+func (h *VariantHandler) CreateProductOption(c *gin.Context) {
+	var body createProductOptionBody
+
+	if !bindJSON(c, &body) {
+		return
+	}
+
+	product, ok := h.loadVariantProduct(c)
+	if !ok {
+		return
+	}
+
+	name, ok := cleanOptionText(c, "name", body.Name)
+	if !ok {
+		return
+	}
+
+	if len(body.Values) == 0 {
+		RespondError(c, http.StatusBadRequest, "Missing values", "An option needs at least one value")
+		return
+	}
+
+	option := &model.ProductOption{ProductID: product.ID, Name: name}
+	option.ID = uuid.New()
+
+	seen := map[string]bool{}
+
+	for i, raw := range body.Values {
+		value, ok := cleanOptionText(c, "value", raw)
+		if !ok {
+			return
+		}
+
+		if seen[strings.ToLower(value)] {
+			RespondError(c, http.StatusBadRequest, "Duplicate value", "The value "+value+" is listed more than once")
+			return
+		}
+		seen[strings.ToLower(value)] = true
+
+		option.Values = append(option.Values, &model.ProductOptionValue{
+			ID:       uuid.New(),
+			OptionID: option.ID,
+			Value:    value,
+			Position: i,
+		})
+	}
+
+	options, err := h.variants.ListOptions(c.Request.Context(), product.ID)
+
+	if err != nil {
+		fail(c, err)
+		return
+	}
+
+	for _, existing := range options {
+		if strings.EqualFold(existing.Name, name) {
+			RespondError(c, http.StatusConflict, "Option already exists", "The product already has an option called "+existing.Name)
+			return
+		}
+	}
+
+	variants, err := h.variants.ListByProduct(c.Request.Context(), product.ID)
+
+	if err != nil {
+		fail(c, err)
+		return
+	}
+
+	// existing variants would have no value for the new option
+	if !service.IsSimpleProduct(variants) {
+		RespondError(c, http.StatusConflict, "Product has variants", "Options cannot be added once the product has variants, delete them first")
+		return
+	}
+
+	if err := h.variants.CreateOption(c.Request.Context(), option); err != nil {
+		fail(c, err)
+		return
+	}
+
+	RespondSuccess(c, http.StatusCreated, "Option added successfully", option)
+}
+
+type addProductOptionValueBody struct {
+	Value string `json:"value" binding:"required,max=50"`
+}
+
+// AddProductOptionValue godoc
+// @Summary Add a value to a product option
+// @Description Adds a value such as "XL" to an option after its existing values, ready to be used by new variants.
+// @Tags products
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "Product ID"
+// @Param optionId path string true "Option ID"
+// @Param body body addProductOptionValueBody true "Value"
+// @Success 201 {object} model.ProductOption
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 409 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /products/{id}/options/{optionId}/values [post]
+func (h *VariantHandler) AddProductOptionValue(c *gin.Context) {
+	var body addProductOptionValueBody
+
+	if !bindJSON(c, &body) {
+		return
+	}
+
+	product, ok := h.loadVariantProduct(c)
+	if !ok {
+		return
+	}
+
+	options, err := h.variants.ListOptions(c.Request.Context(), product.ID)
+
+	if err != nil {
+		fail(c, err)
+		return
+	}
+
+	option, ok := h.loadProductOption(c, options)
+	if !ok {
+		return
+	}
+
+	value, ok := cleanOptionText(c, "value", body.Value)
+	if !ok {
+		return
+	}
+
+	for _, existing := range option.Values {
+		if strings.EqualFold(existing.Value, value) {
+			RespondError(c, http.StatusConflict, "Value already exists", "The option already has the value "+existing.Value)
+			return
+		}
+	}
+
+	optionValue := &model.ProductOptionValue{
+		ID:       uuid.New(),
+		OptionID: option.ID,
+		Value:    value,
+		Position: len(option.Values),
+	}
+
+	if err := h.variants.AddOptionValue(c.Request.Context(), optionValue); err != nil {
+		fail(c, err)
+		return
+	}
+
+	option.Values = append(option.Values, optionValue)
+
+	RespondSuccess(c, http.StatusCreated, "Value added successfully", option)
+}
+
+// DeleteProductOption godoc
+// @Summary Delete a product option
+// @Description Removes the option and its values. Not allowed while the product has variants with options.
+// @Tags products
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "Product ID"
+// @Param optionId path string true "Option ID"
+// @Success 200 {object} map[string]string
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 409 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /products/{id}/options/{optionId} [delete]
+func (h *VariantHandler) DeleteProductOption(c *gin.Context) {
+	product, ok := h.loadVariantProduct(c)
+	if !ok {
+		return
+	}
+
+	options, err := h.variants.ListOptions(c.Request.Context(), product.ID)
+
+	if err != nil {
+		fail(c, err)
+		return
+	}
+
+	option, ok := h.loadProductOption(c, options)
+	if !ok {
+		return
+	}
+
+	variants, err := h.variants.ListByProduct(c.Request.Context(), product.ID)
+
+	if err != nil {
+		fail(c, err)
+		return
+	}
+
+	if !service.IsSimpleProduct(variants) {
+		RespondError(c, http.StatusConflict, "Option in use", "Variants use this option, delete them first")
+		return
+	}
+
+	if err := h.variants.DeleteOption(c.Request.Context(), option.ID); err != nil {
+		fail(c, err)
+		return
+	}
+
+	RespondSuccess(c, http.StatusOK, "Option deleted successfully", nil)
+}
+
+// ListProductVariants godoc
+// @Summary List the variants of a product
+// @Description Returns the variants with their option values, price after any running discount and stock. Simple products have a single variant without options.
+// @Tags products
+// @Produce json
+// @Param id path string true "Product ID"
+// @Success 200 {array} model.ProductVariant
+// @Failure 400 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /products/{id}/variants [get]
+func (h *VariantHandler) ListProductVariants(c *gin.Context) {
+	product, ok := h.loadVariantProduct(c)
+	if !ok {
+		return
+	}
+
+	if err := h.productService.AttachProductImages(c.Request.Context(), product); err != nil {
+		fail(c, err)
+		return
+	}
+
+	if err := h.productService.AttachProductVariants(c.Request.Context(), product); err != nil {
+		fail(c, err)
+		return
+	}
+
+	RespondSuccess(c, http.StatusOK, "Variants fetched successfully", product.Variants)
+}
+
+type createProductVariantBody struct {
+	SKU   *string `json:"sku,omitempty" binding:"omitempty,max=64"`
+	Price *int64  `json:"price,omitempty" binding:"omitempty,min=0"`
+	Stock int     `json:"stock" binding:"min=0"`
+
+	// Options gives a value for every option of the product, e.g.
+	// {"Size": "M", "Colour": "Red"}
+	Options map[string]string `json:"options" binding:"required"`
+}
+
+// CreateProductVariant godoc
+// @Summary Add a variant to a product
+// @Description Adds a variant with a value for every option of the product. Price overrides the product price, leave it out to use the product price. The first variant replaces the single variant the product had before it had options.
+// @Tags products
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "Product ID"
+// @Param body body createProductVariantBody true "Variant"
+// @Success 201 {object} model.ProductVariant
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 409 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /products/{id}/variants [post]
+func (h *VariantHandler) CreateProductVariant(c *gin.Context) {
+	var body createProductVariantBody
+
+	if !bindJSON(c, &body) {
+		return
+	}
+
+	product, ok := h.loadVariantProduct(c)
+	if !ok {
+		return
+	}
+
+	if body.Price != nil && *body.Price < 0 {
+		RespondError(c, http.StatusBadRequest, "Invalid price", "Price cannot be negative")
+		return
+	}
+
+	if body.Stock < 0 {
+		RespondError(c, http.StatusBadRequest, "Invalid stock", "Stock cannot be negative")
+		return
+	}
+
+	options, err := h.variants.ListOptions(c.Request.Context(), product.ID)
+
+	if err != nil {
+		fail(c, err)
+		return
+	}
+
+	if len(options) == 0 {
+		RespondError(c, http.StatusBadRequest, "Product has no options", "Add options such as size or colour before adding variants")
+		return
+	}
+
+	if len(body.Options) != len(options) {
+		RespondError(c, http.StatusBadRequest, "Invalid options", "Give exactly one value for each option of the product")
+		return
+	}
+
+	variant := &model.ProductVariant{
+		ProductID: product.ID,
+		Price:     body.Price,
+		Stock:     body.Stock,
+		Options:   map[string]string{},
+	}
+	variant.ID = uuid.New()
+
+	var valueIds []uuid.UUID
+
+	for _, option := range options {
+		given, ok := body.Options[option.Name]
+		if !ok {
+			RespondError(c, http.StatusBadRequest, "Missing option", "Give a value for "+option.Name)
+			return
+		}
+
+		var match *model.ProductOptionValue
+		for _, value := range option.Values {
+			if strings.EqualFold(value.Value, strings.TrimSpace(given)) {
+				match = value
+			}
+		}
+
+		if match == nil {
+			RespondError(c, http.StatusBadRequest, "Unknown option value", given+" is not a value of "+option.Name)
+			return
+		}
+
+		variant.Options[option.Name] = match.Value
+		valueIds = append(valueIds, match.ID)
+	}
+
+	variants, err := h.variants.ListByProduct(c.Request.Context(), product.ID)
+
+	if err != nil {
+		fail(c, err)
+		return
+	}
+
+	label := variant.Label(options)
+
+	for _, existing := range variants {
+		if existing.Label(options) == label {
+			RespondError(c, http.StatusConflict, "Variant already exists", "The product already has a "+label+" variant")
+			return
+		}
+	}
+
+	if variant.SKU, ok = h.checkSKU(c, body.SKU, product.ID, variant.ID); !ok {
+		return
+	}
+
+	if err := h.variants.Create(c.Request.Context(), variant, valueIds); err != nil {
+		fail(c, err)
+		return
+	}
+
+	variant.Images = []*model.ProductImage{}
+	variant.ApplyPricing(product)
+
+	RespondSuccess(c, http.StatusCreated, "Variant added successfully", variant)
+}
+
+type updateProductVariantBody struct {
+	SKU *string `json:"sku,omitempty" binding:"omitempty,max=64"`
+	// Price overrides the product price, -1 goes back to the product price
+	Price *int64 `json:"price,omitempty" binding:"omitempty,min=-1"`
+	Stock *int   `json:"stock,omitempty" binding:"omitempty,min=0"`
+}
+
+// UpdateProductVariant godoc
+// @Summary Update a product variant
+// @Description Changes the SKU, price or stock of a variant. An empty SKU removes it and a price of -1 goes back to the product price.
+// @Tags products
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "Product ID"
+// @Param variantId path string true "Variant ID"
+// @Param body body updateProductVariantBody true "Variant data"
+// @Success 200 {object} model.ProductVariant
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 409 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /products/{id}/variants/{variantId} [patch]
+func (h *VariantHandler) UpdateProductVariant(c *gin.Context) {
+	var body updateProductVariantBody
+
+	if !bindJSON(c, &body) {
+		return
+	}
+
+	product, ok := h.loadVariantProduct(c)
+	if !ok {
+		return
+	}
+
+	variant, ok := h.loadProductVariant(c, product)
+	if !ok {
+		return
+	}
+
+	if body.SKU != nil {
+		if variant.SKU, ok = h.checkSKU(c, body.SKU, product.ID, variant.ID); !ok {
+			return
+		}
+	}
+
+	if body.Price != nil {
+		switch {
+		case *body.Price == -1:
+			variant.Price = nil
+		case *body.Price < 0:
+			RespondError(c, http.StatusBadRequest, "Invalid price", "Price cannot be negative")
+			return
+		default:
+			variant.Price = body.Price
+		}
+	}
+
+	if body.Stock != nil {
+		if *body.Stock < 0 {
+			RespondError(c, http.StatusBadRequest, "Invalid stock", "Stock cannot be negative")
+			return
+		}
+
+		variant.Stock = *body.Stock
+	}
+
+	if err := h.productService.UpdateVariant(c.Request.Context(), variant); err != nil {
+		fail(c, err)
+		return
+	}
+
+	variant.ApplyPricing(product)
+
+	RespondSuccess(c, http.StatusOK, "Variant updated successfully", variant)
+}
+
+// DeleteProductVariant godoc
+// @Summary Delete a product variant
+// @Description Removes the variant. Past orders keep referring to it. A product's last variant cannot be deleted.
+// @Tags products
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "Product ID"
+// @Param variantId path string true "Variant ID"
+// @Success 200 {object} map[string]string
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 409 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /products/{id}/variants/{variantId} [delete]
+func (h *VariantHandler) DeleteProductVariant(c *gin.Context) {
+	product, ok := h.loadVariantProduct(c)
+	if !ok {
+		return
+	}
+
+	variant, ok := h.loadProductVariant(c, product)
+	if !ok {
+		return
+	}
+
+	variants, err := h.variants.ListByProduct(c.Request.Context(), product.ID)
+
+	if err != nil {
+		fail(c, err)
+		return
+	}
+
+	if len(variants) <= 1 {
+		RespondError(c, http.StatusConflict, "Last variant", "A product needs at least one variant")
+		return
+	}
+
+	if err := h.variants.Delete(c.Request.Context(), variant); err != nil {
+		fail(c, err)
+		return
+	}
+
+	RespondSuccess(c, http.StatusOK, "Variant deleted successfully", nil)
+}
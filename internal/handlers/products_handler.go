@@ -1,67 +1,155 @@
 package handlers
 
 import (
+	"context"
+	"errors"
+	"fmt"
 	"net/http"
-	"strconv"
+	"path"
 	"strings"
 
 	"github.com/Oj-washingtone/savannah-store/internal/model"
 	"github.com/Oj-washingtone/savannah-store/internal/repocitory"
+	"github.com/Oj-washingtone/savannah-store/internal/service"
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
 )
 
+type ProductHandler struct {
+	products       repocitory.ProductRepository
+	productService *service.ProductService
+}
+
+func NewProductHandler(repos *repocitory.Repositories, productService *service.ProductService) *ProductHandler {
+	return &ProductHandler{
+		products:       repos.Products,
+		productService: productService,
+	}
+}
+
 type createProductBody struct {
-	Name        string
-	CategoryId  uuid.UUID
-	Description string
-	Price       int64
-	Stock       int
+	Name        string         `json:"name" binding:"required,max=100"`
+	Slug        *string        `json:"slug,omitempty" binding:"omitempty,max=120"`
+	SKU         *string        `json:"sku,omitempty" binding:"omitempty,max=64"`
+	CategoryId  uuid.UUID      `json:"categoryId" binding:"required,exists=category"`
+	Description string         `json:"description"`
+	Price       int64          `json:"price" binding:"required,money"`
+	Stock       int            `json:"stock" binding:"min=0"`
+	TaxClass    model.TaxClass `json:"taxClass,omitempty" binding:"omitempty,tax_class"`
+	model.SEO
+}
+
+// productSKU trims the product's SKU and makes sure no other product or
+// variant uses it. An empty SKU comes back as nil.
+func (h *ProductHandler) productSKU(c *gin.Context, sku *string, productId uuid.UUID) (*string, bool) {
+	if sku == nil {
+		return nil, true
+	}
+
+	trimmed := strings.TrimSpace(*sku)
+	if trimmed == "" {
+		return nil, true
+	}
+
+	if len(trimmed) > maxSKULength {
+		RespondError(c, http.StatusBadRequest, "Invalid SKU", fmt.Sprintf("sku can be at most %d characters", maxSKULength))
+		return nil, false
+	}
+
+	taken, err := h.products.SKUTaken(c.Request.Context(), trimmed, productId)
+
+	if err != nil {
+		fail(c, err)
+		return nil, false
+	}
+
+	if taken {
+		RespondError(c, http.StatusConflict, "SKU already in use", "Another product or variant already has the SKU "+trimmed)
+		return nil, false
+	}
+
+	return &trimmed, true
+}
+
+// productSlug checks the slug asked for a product, or makes one from its
+// name.
+func (h *ProductHandler) productSlug(c *gin.Context, requested *string, name string, productId uuid.UUID) (string, bool) {
+	return resolveSlug(c, "product", requested, name, func(ctx context.Context, s string) (bool, error) {
+		return h.products.SlugTaken(ctx, s, productId)
+	})
 }
 
 // CreateProduct godoc
 // @Summary Add a new product
+// @Description The slug is made from the name when none is given. Slugs and SKUs must be unique.
 // @Tags products
 // @Accept json
 // @Produce json
 // @Param body body createProductBody true "Product body"
 // @Success 201 {object} model.Product
 // @Failure 400 {object} map[string]string
+// @Failure 409 {object} map[string]string
 // @Router /products/create [post]
-func CreateProduct(c *gin.Context) {
+func (h *ProductHandler) CreateProduct(c *gin.Context) {
 	var body createProductBody
 
-	if err := c.ShouldBindJSON(&body); err != nil {
-		RespondError(c, http.StatusBadRequest, "Invalid request body", err.Error())
+	if !bindJSON(c, &body) {
+		return
+	}
+
+	if body.TaxClass == "" {
+		body.TaxClass = model.TaxStandard
+	}
+
+	name := strings.TrimSpace(body.Name)
+	if name == "" {
+		RespondError(c, http.StatusBadRequest, "Invalid name", "Product name cannot be empty")
+		return
+	}
+
+	if !cleanSEO(c, &body.SEO) {
 		return
 	}
 
 	product := &model.Product{
-		Name:        strings.ToLower(body.Name),
+		Name:        name,
 		CategoryID:  body.CategoryId,
 		Description: body.Description,
 		Price:       body.Price,
 		Stock:       body.Stock,
+		TaxClass:    body.TaxClass,
+		SEO:         body.SEO,
 	}
 
 	product.ID = uuid.New()
 
-	productRepository := repocitory.NewProductRepository()
+	var ok bool
 
-	err := productRepository.Create(c, product)
+	if product.Slug, ok = h.productSlug(c, body.Slug, name, product.ID); !ok {
+		return
+	}
+
+	if product.SKU, ok = h.productSKU(c, body.SKU, product.ID); !ok {
+		return
+	}
+
+	err := h.products.Create(c.Request.Context(), product)
 
 	if err != nil {
-		RespondError(c, http.StatusInternalServerError, "Failled to create product", err.Error())
+		fail(c, err)
 		return
 	}
 
+	product.ApplyDiscount(nil)
+
 	RespondSuccess(c, http.StatusCreated, "Product Added successfully", product)
 
 }
 
 // GetProductById godoc
 // @Summary Get product by ID
-// @Description get product by its ID
+// @Description get product by its ID, with its options and variants
 // @Tags products
 // @Accept json
 // @Produce json
@@ -71,74 +159,166 @@ func CreateProduct(c *gin.Context) {
 // @Failure 404 {object} map[string]string
 // @Failure 500 {object} map[string]string
 // @Router /products/{id} [get]
-func GetProductById(c *gin.Context) {
+func (h *ProductHandler) GetProductById(c *gin.Context) {
 	idParam := c.Param("id")
 
-	productId, err := uuid.Parse(idParam)
+	productId, ok := parseId(c, idParam, "product")
+	if !ok {
+		return
+	}
+
+	product, err := h.products.GetById(c.Request.Context(), productId)
 
 	if err != nil {
-		RespondError(c, http.StatusBadRequest, "Invalid product id", err.Error())
+		fail(c, err)
 		return
 	}
 
-	product, err := repocitory.NewProductRepository().GetById(c, productId)
+	h.respondProductDetail(c, product)
+}
 
-	if err != nil {
-		RespondError(c, http.StatusNotFound, "Failed to fetch product", err.Error())
+// respondProductDetail responds with the product and its images, options
+// and variants.
+func (h *ProductHandler) respondProductDetail(c *gin.Context, product *model.Product) {
+	if err := h.productService.AttachProductImages(c.Request.Context(), product); err != nil {
+		fail(c, err)
+		return
+	}
+
+	if err := h.productService.AttachProductVariants(c.Request.Context(), product); err != nil {
+		fail(c, err)
 		return
 	}
 
 	RespondSuccess(c, http.StatusOK, "success", product)
 }
 
+// GetProductBySlug godoc
+// @Summary Get product by slug
+// @Description Get a product by its slug, with its options and variants. An old slug of the product redirects to its current one.
+// @Tags products
+// @Produce json
+// @Param slug path string true "Product slug"
+// @Success 200 {object} model.Product
+// @Success 301 "Redirect to the product's current slug"
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /products/by-slug/{slug} [get]
+func (h *ProductHandler) GetProductBySlug(c *gin.Context) {
+	product, err := h.products.GetBySlug(c.Request.Context(), c.Param("slug"))
+
+	if err == nil {
+		h.respondProductDetail(c, product)
+		return
+	}
+
+	if !errors.Is(err, pgx.ErrNoRows) {
+		fail(c, err)
+		return
+	}
+
+	current, err := h.products.SlugRedirect(c.Request.Context(), c.Param("slug"))
+
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			RespondError(c, http.StatusNotFound, "Product not found", "No product with this slug")
+			return
+		}
+
+		fail(c, err)
+		return
+	}
+
+	// the same URL with the slug swapped, keeping any query
+	target := *c.Request.URL
+	target.Path = path.Join(path.Dir(target.Path), current)
+	target.RawPath = ""
+
+	c.Redirect(http.StatusMovedPermanently, target.RequestURI())
+}
+
+// GetProductBySKU godoc
+// @Summary Get product by SKU
+// @Description Get a product by its SKU or the SKU of one of its variants, with its options and variants.
+// @Tags products
+// @Produce json
+// @Param sku path string true "Product or variant SKU"
+// @Success 200 {object} model.Product
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /products/by-sku/{sku} [get]
+func (h *ProductHandler) GetProductBySKU(c *gin.Context) {
+	product, err := h.products.GetBySKU(c.Request.Context(), c.Param("sku"))
+
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			RespondError(c, http.StatusNotFound, "Product not found", "No product or variant with this SKU")
+			return
+		}
+
+		fail(c, err)
+		return
+	}
+
+	h.respondProductDetail(c, product)
+}
+
 // ListProducts godoc
 // @Summary List product
-// @Description Returns paginated list of product
+// @Description Returns a page of products, newest first. Follow pagination.nextCursor (or the Link header) for the next page.
 // @Tags products
 // @Produce json
-// @Param limit query int false "Number of products to return" default(10)
-// @Param offset query int false "Offset for pagination" default(0)
+// @Param limit query int false "Number of products to return (100 max)" default(10)
+// @Param cursor query string false "nextCursor or prevCursor from the previous page"
+// @Param total query bool false "Include the total number of products"
 // @Success 200 {array} model.Product
 // @Failure 400 {object} map[string]string
 // @Failure 500 {object} map[string]string
 // @Router /products [get]
-func ListProducts(c *gin.Context) {
-	limit, err := strconv.Atoi(c.DefaultQuery("limit", "10"))
-
-	if err != nil || limit <= 0 {
-		RespondError(c, http.StatusBadRequest, "Invalid limit", err.Error())
+func (h *ProductHandler) ListProducts(c *gin.Context) {
+	page, ok := parsePage(c)
+	if !ok {
 		return
 	}
 
-	offset, err := strconv.Atoi(c.DefaultQuery("offset", "0"))
+	products, info, err := h.products.List(c.Request.Context(), page)
 
-	if err != nil || offset < 0 {
-		RespondError(c, http.StatusBadRequest, "Invalid offset", err.Error())
+	if err != nil {
+		fail(c, err)
 		return
 	}
 
-	products, err := repocitory.NewProductRepository().List(c, limit, offset)
+	withImages := make([]*model.Product, len(products))
+	for i := range products {
+		withImages[i] = &products[i]
+	}
 
-	if err != nil {
-		RespondError(c, http.StatusInternalServerError, "failled to fetch categories", err.Error())
+	if err := h.productService.AttachProductImages(c.Request.Context(), withImages...); err != nil {
+		fail(c, err)
 		return
 	}
 
-	RespondSuccess(c, http.StatusOK, "Products found", products)
+	RespondPage(c, http.StatusOK, "Products found", products, info)
 
 }
 
 type updateProductBody struct {
-	Name        *string    `json:"name,omitempty"`
-	CategoryId  *uuid.UUID `json:"categoryId,omitempty"`
-	Description *string    `json:"description,omitempty"`
-	Price       *int64     `json:"price,omitempty"`
-	Stock       *int       `json:"stock,omitempty"`
+	Name        *string         `json:"name,omitempty" binding:"omitempty,max=100"`
+	Slug        *string         `json:"slug,omitempty" binding:"omitempty,max=120"`
+	SKU         *string         `json:"sku,omitempty" binding:"omitempty,max=64"`
+	CategoryId  *uuid.UUID      `json:"categoryId,omitempty" binding:"omitempty,exists=category"`
+	Description *string         `json:"description,omitempty"`
+	Price       *int64          `json:"price,omitempty" binding:"omitempty,money"`
+	Stock       *int            `json:"stock,omitempty" binding:"omitempty,min=0"`
+	TaxClass    *model.TaxClass `json:"taxClass,omitempty" binding:"omitempty,tax_class"`
+
+	MetaTitle       *string `json:"metaTitle,omitempty" binding:"omitempty,max=100"`
+	MetaDescription *string `json:"metaDescription,omitempty" binding:"omitempty,max=300"`
 }
 
 // UpdateProduct godoc
 // @Summary Update a product
-// @Description Update a Product by ID
+// @Description Update a Product by ID. Stock can only be set here for products without options, otherwise set it on the variants. A changed slug keeps redirecting from the old one, an empty sku clears it.
 // @Tags products
 // @Accept json
 // @Produce json
@@ -147,35 +327,51 @@ type updateProductBody struct {
 // @Success 200 {object} model.Product
 // @Failure 400 {object} map[string]string
 // @Failure 404 {object} map[string]string
+// @Failure 409 {object} map[string]string
 // @Failure 500 {object} map[string]string
 // @Router /products/{id} [patch]
-func UpdateProduct(c *gin.Context) {
+func (h *ProductHandler) UpdateProduct(c *gin.Context) {
 	idParam := c.Param("id")
 
-	productId, err := uuid.Parse(idParam)
-
-	if err != nil {
-		RespondError(c, http.StatusBadRequest, "Invalid product Id", err.Error())
+	productId, ok := parseId(c, idParam, "product")
+	if !ok {
 		return
 	}
 
 	var body updateProductBody
 
-	if err := c.ShouldBindJSON(&body); err != nil {
-		RespondError(c, http.StatusBadRequest, "Invalid request body", err.Error())
+	if !bindJSON(c, &body) {
 		return
 	}
 
-	productRepo := repocitory.NewProductRepository()
-
-	product, err := productRepo.GetById(c, productId)
+	product, err := h.products.GetById(c.Request.Context(), productId)
 
 	if err != nil {
-		RespondError(c, http.StatusNotFound, "Product not found", err.Error())
+		fail(c, err)
+		return
 	}
 
 	if body.Name != nil {
-		product.Name = strings.ToLower(*body.Name)
+		product.Name = strings.TrimSpace(*body.Name)
+
+		if product.Name == "" {
+			RespondError(c, http.StatusBadRequest, "Invalid name", "Product name cannot be empty")
+			return
+		}
+	}
+
+	// renaming keeps the slug, links to the product only change when a new
+	// slug is given
+	if body.Slug != nil && *body.Slug != product.Slug {
+		if product.Slug, ok = h.productSlug(c, body.Slug, product.Name, product.ID); !ok {
+			return
+		}
+	}
+
+	if body.SKU != nil {
+		if product.SKU, ok = h.productSKU(c, body.SKU, product.ID); !ok {
+			return
+		}
 	}
 
 	if body.CategoryId != nil {
@@ -190,15 +386,31 @@ func UpdateProduct(c *gin.Context) {
 		product.Price = *body.Price
 	}
 
-	if body.Stock != nil {
-		product.Stock = *body.Stock
+	if body.TaxClass != nil {
+		product.TaxClass = *body.TaxClass
 	}
 
-	if err := productRepo.Update(c, product); err != nil {
-		RespondError(c, http.StatusInternalServerError, "Failled to update product", err.Error())
+	if body.MetaTitle != nil {
+		product.MetaTitle = *body.MetaTitle
+	}
+
+	if body.MetaDescription != nil {
+		product.MetaDescription = *body.MetaDescription
+	}
+
+	if !cleanSEO(c, &product.SEO) {
+		return
+	}
+
+	// stock lives on the variants, a simple product's single variant takes
+	// the new stock
+	if err := h.productService.UpdateProduct(c.Request.Context(), product, body.Stock); err != nil {
+		fail(c, err)
 		return
 	}
 
+	product.ApplyDiscount(product.Discount)
+
 	RespondSuccess(c, http.StatusOK, "Product updated successfully", product)
 }
 
@@ -219,21 +431,16 @@ func UpdateProduct(c *gin.Context) {
 // @Failure      400  {object}  map[string]string "Invalid product ID"
 // @Failure      500  {object}  map[string]string "Failed to delete product"
 // @Router       /products/{id} [delete]
-func DeleteProduct(c *gin.Context) {
+func (h *ProductHandler) DeleteProduct(c *gin.Context) {
 	idParam := c.Param("id")
 
-	productId, err := uuid.Parse(idParam)
-
-	if err != nil {
-		RespondError(c, http.StatusBadRequest, "Invaluid category id", err.Error())
-
+	productId, ok := parseId(c, idParam, "product")
+	if !ok {
 		return
 	}
 
-	productRepo := repocitory.NewProductRepository()
-
-	if err := productRepo.Delete(c, productId); err != nil {
-		RespondError(c, http.StatusInternalServerError, "Failled to delete product", err.Error())
+	if err := h.products.Delete(c.Request.Context(), productId); err != nil {
+		fail(c, err)
 		return
 	}
 
@@ -0,0 +1,98 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/Oj-washingtone/savannah-store/internal/config"
+	"github.com/Oj-washingtone/savannah-store/internal/logging"
+	"github.com/Oj-washingtone/savannah-store/internal/middleware"
+	"github.com/Oj-washingtone/savannah-store/internal/model"
+	"github.com/Oj-washingtone/savannah-store/internal/repocitory"
+	"github.com/Oj-washingtone/savannah-store/internal/repocitory/memory"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// loggedProducts logs each lookup the way the query tracer does, with the
+// context the handler passed in.
+type loggedProducts struct {
+	repocitory.ProductRepository
+}
+
+func (r loggedProducts) GetById(ctx context.Context, id uuid.UUID) (*model.Product, error) {
+	slog.DebugContext(ctx, "Query", "sql", "SELECT * FROM products WHERE id = $1")
+	return r.ProductRepository.GetById(ctx, id)
+}
+
+func TestQueryLogCarriesRequestID(t *testing.T) {
+	var out bytes.Buffer
+	defaultLogger := slog.Default()
+	slog.SetDefault(logging.New(config.Logging{Level: "debug", Format: "json"}, &out))
+	t.Cleanup(func() { slog.SetDefault(defaultLogger) })
+
+	repos := memory.NewRepositories()
+	repos.Products = loggedProducts{repos.Products}
+	h := NewProductHandler(repos, nil)
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(middleware.RequestID())
+	router.GET("/products/:id", h.GetProductById)
+
+	req := httptest.NewRequest(http.MethodGet, "/products/"+uuid.NewString(), nil)
+	req.Header.Set(middleware.RequestIDHeader, "req-42")
+	router.ServeHTTP(httptest.NewRecorder(), req)
+
+	var line map[string]any
+	if err := json.Unmarshal(out.Bytes(), &line); err != nil {
+		t.Fatalf("want one JSON line, got %q: %v", out.String(), err)
+	}
+
+	if line["msg"] != "Query" || line["request_id"] != "req-42" || line["route"] != "/products/:id" {
+		t.Errorf("the query was logged without its request: %s", out.String())
+	}
+}
+
+func TestOldSlugRedirectKeepsQuery(t *testing.T) {
+	ctx := context.Background()
+	repos := memory.NewRepositories()
+
+	category := &model.ProductCategory{BaseModel: model.BaseModel{ID: uuid.New()}, Name: "Textiles", Slug: "textiles"}
+	product := &model.Product{
+		BaseModel:  model.BaseModel{ID: uuid.New()},
+		CategoryID: category.ID,
+		Name:       "Kikoi",
+		Slug:       "kikoi",
+		Price:      1200,
+		TaxClass:   model.TaxStandard,
+	}
+	if err := repos.Categories.Create(ctx, category); err != nil {
+		t.Fatal(err)
+	}
+	if err := repos.Products.Create(ctx, product); err != nil {
+		t.Fatal(err)
+	}
+
+	product.Slug = "kikoi-striped"
+	if err := repos.Products.Update(ctx, product); err != nil {
+		t.Fatal(err)
+	}
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.GET("/api/products/by-slug/:slug", NewProductHandler(repos, nil).GetProductBySlug)
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/products/by-slug/kikoi?utm_source=mail&ref=a%2Fb", nil))
+
+	want := "/api/products/by-slug/kikoi-striped?utm_source=mail&ref=a%2Fb"
+	if w.Code != http.StatusMovedPermanently || w.Header().Get("Location") != want {
+		t.Errorf("got %d to %q, want 301 to %q", w.Code, w.Header().Get("Location"), want)
+	}
+}
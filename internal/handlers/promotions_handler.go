@@ -0,0 +1,405 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/Oj-washingtone/savannah-store/internal/model"
+	"github.com/Oj-washingtone/savannah-store/internal/repocitory"
+	"github.com/Oj-washingtone/savannah-store/internal/service"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+)
+
+type PromotionHandler struct {
+	promotions repocitory.PromotionRepository
+}
+
+func NewPromotionHandler(promotions repocitory.PromotionRepository) *PromotionHandler {
+	return &PromotionHandler{promotions: promotions}
+}
+
+type createPromotionBody struct {
+	Code             *string              `json:"code,omitempty" binding:"omitempty,max=50"`
+	Description      string               `json:"description"`
+	Scope            model.PromotionScope `json:"scope" binding:"required,oneof=cart product category"`
+	ProductId        *uuid.UUID           `json:"productId,omitempty" binding:"omitempty,exists=product"`
+	CategoryId       *uuid.UUID           `json:"categoryId,omitempty" binding:"omitempty,exists=category"`
+	Type             model.DiscountType   `json:"type" binding:"required,oneof=percentage fixed"`
+	Value            int64                `json:"value" binding:"required,money"`
+	MinSpend         int64                `json:"minSpend" binding:"min=0"`
+	StartsAt         *time.Time           `json:"startsAt,omitempty"`
+	EndsAt           *time.Time           `json:"endsAt,omitempty"`
+	UsageLimit       *int                 `json:"usageLimit,omitempty" binding:"omitempty,min=1"`
+	PerCustomerLimit *int                 `json:"perCustomerLimit,omitempty" binding:"omitempty,min=1"`
+	Stackable        bool                 `json:"stackable"`
+	Active           *bool                `json:"active,omitempty"`
+}
+
+// validatePromotion returns a description of what is wrong with the
+// promotion, or an empty string if it is valid.
+func validatePromotion(p *model.Promotion) string {
+	switch p.Scope {
+	case model.PromotionScopeCart:
+		if p.ProductID != nil || p.CategoryID != nil {
+			return "cart promotions cannot target a product or category"
+		}
+	case model.PromotionScopeProduct:
+		if p.ProductID == nil {
+			return "productId is required for product promotions"
+		}
+		p.CategoryID = nil
+	case model.PromotionScopeCategory:
+		if p.CategoryID == nil {
+			return "categoryId is required for category promotions"
+		}
+		p.ProductID = nil
+	default:
+		return "scope must be one of cart, product or category"
+	}
+
+	if p.Type != model.DiscountPercentage && p.Type != model.DiscountFixed {
+		return "type must be percentage or fixed"
+	}
+
+	if p.Value <= 0 {
+		return "value must be greater than zero"
+	}
+
+	if p.Type == model.DiscountPercentage && p.Value > 100 {
+		return "percentage discounts cannot be more than 100"
+	}
+
+	if p.MinSpend < 0 {
+		return "minSpend cannot be negative"
+	}
+
+	if p.StartsAt != nil && p.EndsAt != nil && !p.EndsAt.After(*p.StartsAt) {
+		return "endsAt must be after startsAt"
+	}
+
+	if (p.UsageLimit != nil && *p.UsageLimit <= 0) || (p.PerCustomerLimit != nil && *p.PerCustomerLimit <= 0) {
+		return "usage limits must be greater than zero"
+	}
+
+	if p.Code != nil && *p.Code == "" {
+		return "code cannot be empty"
+	}
+
+	return ""
+}
+
+// codeTaken reports whether another promotion already uses the code.
+func (h *PromotionHandler) codeTaken(c *gin.Context, code *string, id uuid.UUID) (bool, error) {
+	if code == nil {
+		return false, nil
+	}
+
+	existing, err := h.promotions.GetByCode(c.Request.Context(), *code)
+
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return false, nil
+		}
+		return false, err
+	}
+
+	return existing.ID != id, nil
+}
+
+// CreatePromotion godoc
+// @Summary Create a promotion
+// @Description Creates a coupon code (when code is set) or an automatic promotion. Percentage values are whole percents, fixed values are shillings off the cart or off each unit.
+// @Tags Promotions
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param body body createPromotionBody true "Promotion"
+// @Success 201 {object} model.Promotion
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 409 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /promotions [post]
+func (h *PromotionHandler) CreatePromotion(c *gin.Context) {
+	var body createPromotionBody
+
+	if !bindJSON(c, &body) {
+		return
+	}
+
+	promotion := &model.Promotion{
+		Description:      body.Description,
+		Scope:            body.Scope,
+		ProductID:        body.ProductId,
+		CategoryID:       body.CategoryId,
+		Type:             body.Type,
+		Value:            body.Value,
+		MinSpend:         body.MinSpend,
+		StartsAt:         body.StartsAt,
+		EndsAt:           body.EndsAt,
+		UsageLimit:       body.UsageLimit,
+		PerCustomerLimit: body.PerCustomerLimit,
+		Stackable:        body.Stackable,
+		Active:           true,
+	}
+	promotion.ID = uuid.New()
+
+	if body.Code != nil {
+		code := service.NormalizePromotionCode(*body.Code)
+		promotion.Code = &code
+	}
+
+	if body.Active != nil {
+		promotion.Active = *body.Active
+	}
+
+	if reason := validatePromotion(promotion); reason != "" {
+		RespondError(c, http.StatusBadRequest, "Invalid promotion", reason)
+		return
+	}
+
+	taken, err := h.codeTaken(c, promotion.Code, promotion.ID)
+
+	if err != nil {
+		fail(c, err)
+		return
+	}
+
+	if taken {
+		RespondError(c, http.StatusConflict, "Promotion code already exists", "Another promotion already uses this code")
+		return
+	}
+
+	if err := h.promotions.Create(c.Request.Context(), promotion); err != nil {
+		fail(c, err)
+		return
+	}
+
+	RespondSuccess(c, http.StatusCreated, "Promotion created successfully", promotion)
+}
+
+// ListPromotions godoc
+// @Summary List promotions
+// @Tags Promotions
+// @Produce json
+// @Security BearerAuth
+// @Param limit query int false "Number of promotions to return (100 max)" default(10)
+// @Param cursor query string false "nextCursor or prevCursor from the previous page"
+// @Param total query bool false "Include the total number of promotions"
+// @Success 200 {array} model.Promotion
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /promotions [get]
+func (h *PromotionHandler) ListPromotions(c *gin.Context) {
+	page, ok := parsePage(c)
+	if !ok {
+		return
+	}
+
+	promotions, info, err := h.promotions.List(c.Request.Context(), page)
+
+	if err != nil {
+		fail(c, err)
+		return
+	}
+
+	RespondPage(c, http.StatusOK, "Promotions fetched successfully", promotions, info)
+}
+
+func (h *PromotionHandler) loadPromotion(c *gin.Context) (*model.Promotion, bool) {
+	promotionId, ok := parseId(c, c.Param("id"), "promotion")
+	if !ok {
+		return nil, false
+	}
+
+	promotion, err := h.promotions.GetById(c.Request.Context(), promotionId)
+
+	if err != nil {
+		fail(c, err)
+		return nil, false
+	}
+
+	return promotion, true
+}
+
+// GetPromotion godoc
+// @Summary Get a promotion
+// @Tags Promotions
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "Promotion ID"
+// @Success 200 {object} model.Promotion
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /promotions/{id} [get]
+func (h *PromotionHandler) GetPromotion(c *gin.Context) {
+	promotion, ok := h.loadPromotion(c)
+	if !ok {
+		return
+	}
+
+	RespondSuccess(c, http.StatusOK, "success", promotion)
+}
+
+type updatePromotionBody struct {
+	Code             *string               `json:"code,omitempty" binding:"omitempty,max=50"`
+	Description      *string               `json:"description,omitempty"`
+	Scope            *model.PromotionScope `json:"scope,omitempty" binding:"omitempty,oneof=cart product category"`
+	ProductId        *uuid.UUID            `json:"productId,omitempty" binding:"omitempty,exists=product"`
+	CategoryId       *uuid.UUID            `json:"categoryId,omitempty" binding:"omitempty,exists=category"`
+	Type             *model.DiscountType   `json:"type,omitempty" binding:"omitempty,oneof=percentage fixed"`
+	Value            *int64                `json:"value,omitempty" binding:"omitempty,money"`
+	MinSpend         *int64                `json:"minSpend,omitempty" binding:"omitempty,min=0"`
+	StartsAt         *time.Time            `json:"startsAt,omitempty"`
+	EndsAt           *time.Time            `json:"endsAt,omitempty"`
+	UsageLimit       *int                  `json:"usageLimit,omitempty" binding:"omitempty,min=1"`
+	PerCustomerLimit *int                  `json:"perCustomerLimit,omitempty" binding:"omitempty,min=1"`
+	Stackable        *bool                 `json:"stackable,omitempty"`
+	Active           *bool                 `json:"active,omitempty"`
+}
+
+// UpdatePromotion godoc
+// @Summary Update a promotion
+// @Tags Promotions
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "Promotion ID"
+// @Param body body updatePromotionBody true "Promotion data"
+// @Success 200 {object} model.Promotion
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 409 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /promotions/{id} [patch]
+func (h *PromotionHandler) UpdatePromotion(c *gin.Context) {
+	var body updatePromotionBody
+
+	if !bindJSON(c, &body) {
+		return
+	}
+
+	promotion, ok := h.loadPromotion(c)
+	if !ok {
+		return
+	}
+
+	if body.Code != nil {
+		code := service.NormalizePromotionCode(*body.Code)
+		promotion.Code = &code
+	}
+
+	if body.Description != nil {
+		promotion.Description = *body.Description
+	}
+
+	if body.Scope != nil {
+		promotion.Scope = *body.Scope
+	}
+
+	if body.ProductId != nil {
+		promotion.ProductID = body.ProductId
+	}
+
+	if body.CategoryId != nil {
+		promotion.CategoryID = body.CategoryId
+	}
+
+	if body.Type != nil {
+		promotion.Type = *body.Type
+	}
+
+	if body.Value != nil {
+		promotion.Value = *body.Value
+	}
+
+	if body.MinSpend != nil {
+		promotion.MinSpend = *body.MinSpend
+	}
+
+	if body.StartsAt != nil {
+		promotion.StartsAt = body.StartsAt
+	}
+
+	if body.EndsAt != nil {
+		promotion.EndsAt = body.EndsAt
+	}
+
+	if body.UsageLimit != nil {
+		promotion.UsageLimit = body.UsageLimit
+	}
+
+	if body.PerCustomerLimit != nil {
+		promotion.PerCustomerLimit = body.PerCustomerLimit
+	}
+
+	if body.Stackable != nil {
+		promotion.Stackable = *body.Stackable
+	}
+
+	if body.Active != nil {
+		promotion.Active = *body.Active
+	}
+
+	if reason := validatePromotion(promotion); reason != "" {
+		RespondError(c, http.StatusBadRequest, "Invalid promotion", reason)
+		return
+	}
+
+	taken, err := h.codeTaken(c, promotion.Code, promotion.ID)
+
+	if err != nil {
+		fail(c, err)
+		return
+	}
+
+	if taken {
+		RespondError(c, http.StatusConflict, "Promotion code already exists", "Another promotion already uses this code")
+		return
+	}
+
+	if err := h.promotions.Update(c.Request.Context(), promotion); err != nil {
+		fail(c, err)
+		return
+	}
+
+	RespondSuccess(c, http.StatusOK, "Promotion updated successfully", promotion)
+}
+
+// DeletePromotion godoc
+// @Summary Delete a promotion
+// @Description Soft deletes the promotion. Past redemptions are kept.
+// @Tags Promotions
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "Promotion ID"
+// @Success 200 {object} map[string]string
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /promotions/{id} [delete]
+func (h *PromotionHandler) DeletePromotion(c *gin.Context) {
+	promotionId, ok := parseId(c, c.Param("id"), "promotion")
+	if !ok {
+		return
+	}
+
+	if err := h.promotions.Delete(c.Request.Context(), promotionId); err != nil {
+		fail(c, err)
+		return
+	}
+
+	RespondSuccess(c, http.StatusOK, "Promotion deleted successfully", nil)
+}
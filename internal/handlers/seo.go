@@ -0,0 +1,29 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/Oj-washingtone/savannah-store/internal/model"
+	"github.com/gin-gonic/gin"
+)
+
+// cleanSEO trims the SEO fields and checks they fit their columns.
+func cleanSEO(c *gin.Context, seo *model.SEO) bool {
+	seo.MetaTitle = strings.TrimSpace(seo.MetaTitle)
+	seo.MetaDescription = strings.TrimSpace(seo.MetaDescription)
+
+	if utf8.RuneCountInString(seo.MetaTitle) > model.MaxMetaTitleLength {
+		RespondError(c, http.StatusBadRequest, "Meta title too long", "metaTitle can be at most "+strconv.Itoa(model.MaxMetaTitleLength)+" characters")
+		return false
+	}
+
+	if utf8.RuneCountInString(seo.MetaDescription) > model.MaxMetaDescriptionLength {
+		RespondError(c, http.StatusBadRequest, "Meta description too long", "metaDescription can be at most "+strconv.Itoa(model.MaxMetaDescriptionLength)+" characters")
+		return false
+	}
+
+	return true
+}
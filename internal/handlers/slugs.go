@@ -0,0 +1,57 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/Oj-washingtone/savannah-store/internal/slug"
+	"github.com/gin-gonic/gin"
+)
+
+// maxSlugLength is the size of the slug columns.
+const maxSlugLength = 120
+
+// resolveSlug checks a slug asked for, or makes one from the name when none
+// is given. A slug made from the name gets a number added if it is taken, a
+// slug asked for must be free. kind names the thing the slug is for in
+// messages and stands in for names with nothing usable in them.
+func resolveSlug(c *gin.Context, kind string, requested *string, name string, taken func(ctx context.Context, s string) (bool, error)) (string, bool) {
+	if requested == nil || strings.TrimSpace(*requested) == "" {
+		base := slug.Make(name)
+		if base == "" {
+			base = kind
+		}
+
+		unique, err := slug.Unique(c.Request.Context(), base, taken)
+
+		if err != nil {
+			fail(c, err)
+			return "", false
+		}
+
+		return unique, true
+	}
+
+	s := strings.TrimSpace(*requested)
+
+	if !slug.Valid(s) || len(s) > maxSlugLength {
+		RespondError(c, http.StatusBadRequest, "Invalid slug", fmt.Sprintf("slug must be lowercase letters and digits joined by single dashes, at most %d characters", maxSlugLength))
+		return "", false
+	}
+
+	exists, err := taken(c.Request.Context(), s)
+
+	if err != nil {
+		fail(c, err)
+		return "", false
+	}
+
+	if exists {
+		RespondError(c, http.StatusConflict, "Slug already in use", "Another "+kind+" already has the slug "+s)
+		return "", false
+	}
+
+	return s, true
+}
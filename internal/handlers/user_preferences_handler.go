@@ -0,0 +1,114 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/Oj-washingtone/savannah-store/internal/repocitory"
+	"github.com/Oj-washingtone/savannah-store/internal/validation"
+	"github.com/gin-gonic/gin"
+)
+
+// MeHandler serves the caller's own account settings.
+type MeHandler struct {
+	users repocitory.UserRepository
+}
+
+func NewMeHandler(users repocitory.UserRepository) *MeHandler {
+	return &MeHandler{users: users}
+}
+
+type updatePreferencesBody struct {
+	CartReminders  *bool `json:"cartReminders,omitempty"`
+	WishlistAlerts *bool `json:"wishlistAlerts,omitempty"`
+}
+
+// UpdatePreferences godoc
+// @Summary Update notification preferences
+// @Description Lets the authenticated user opt in or out of abandoned cart reminders and wishlist alerts
+// @Tags Me
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param body body updatePreferencesBody true "Preferences"
+// @Success 200 {object} model.User
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /me/preferences [patch]
+func (h *MeHandler) UpdatePreferences(c *gin.Context) {
+	user, ok := currentUser(c, h.users)
+	if !ok {
+		return
+	}
+
+	var body updatePreferencesBody
+
+	if !bindJSON(c, &body) {
+		return
+	}
+
+	if body.CartReminders != nil {
+		optOut := !*body.CartReminders
+
+		if err := h.users.UpdateCartRemindersOptOut(c.Request.Context(), user.ID, optOut); err != nil {
+			fail(c, err)
+			return
+		}
+
+		user.CartRemindersOptOut = optOut
+	}
+
+	if body.WishlistAlerts != nil {
+		optOut := !*body.WishlistAlerts
+
+		if err := h.users.UpdateWishlistAlertsOptOut(c.Request.Context(), user.ID, optOut); err != nil {
+			fail(c, err)
+			return
+		}
+
+		user.WishlistAlertsOptOut = optOut
+	}
+
+	RespondSuccess(c, http.StatusOK, "Preferences updated successfully", user)
+}
+
+type updatePhoneBody struct {
+	Phone string `json:"phone" binding:"required,ke_phone"`
+}
+
+// UpdatePhone godoc
+// @Summary Set phone number
+// @Description Sets the Kenyan phone number order and reminder SMS go to. It is stored in +254 form.
+// @Tags Me
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param body body updatePhoneBody true "Phone number, e.g. 0712345678"
+// @Success 200 {object} model.User
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /me/phone [patch]
+func (h *MeHandler) UpdatePhone(c *gin.Context) {
+	user, ok := currentUser(c, h.users)
+	if !ok {
+		return
+	}
+
+	var body updatePhoneBody
+
+	if !bindJSON(c, &body) {
+		return
+	}
+
+	phone := validation.NormalizeKenyanPhone(body.Phone)
+
+	if err := h.users.UpdatePhone(c.Request.Context(), user.ID, phone); err != nil {
+		fail(c, err)
+		return
+	}
+
+	user.Phone = phone
+
+	RespondSuccess(c, http.StatusOK, "Phone number updated successfully", user)
+}
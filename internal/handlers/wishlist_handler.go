@@ -0,0 +1,595 @@
+package handlers
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"net/http"
+
+	"github.com/Oj-washingtone/savannah-store/internal/model"
+	"github.com/Oj-washingtone/savannah-store/internal/repocitory"
+	"github.com/Oj-washingtone/savannah-store/internal/service"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+)
+
+type WishlistHandler struct {
+	users          repocitory.UserRepository
+	products       repocitory.ProductRepository
+	carts          repocitory.ShoppingCartRepository
+	cartItems      repocitory.CartItemsRepository
+	wishlists      repocitory.WishlistRepository
+	productService *service.ProductService
+}
+
+func NewWishlistHandler(repos *repocitory.Repositories, productService *service.ProductService) *WishlistHandler {
+	return &WishlistHandler{
+		users:          repos.Users,
+		products:       repos.Products,
+		carts:          repos.Carts,
+		cartItems:      repos.CartItems,
+		wishlists:      repos.Wishlists,
+		productService: productService,
+	}
+}
+
+const defaultWishlistName = "Saved for later"
+
+// getOrCreateDefaultWishlist returns the user's default wishlist, creating it
+// the first time it is needed.
+func (h *WishlistHandler) getOrCreateDefaultWishlist(c *gin.Context, userId uuid.UUID) (*model.Wishlist, error) {
+	wishlist, err := h.wishlists.GetDefault(c.Request.Context(), userId)
+
+	if err == nil {
+		return wishlist, nil
+	}
+
+	if !errors.Is(err, pgx.ErrNoRows) {
+		return nil, err
+	}
+
+	wishlist = &model.Wishlist{
+		UserId:    userId,
+		Name:      defaultWishlistName,
+		IsDefault: true,
+	}
+	wishlist.ID = uuid.New()
+
+	if err := h.wishlists.Create(c.Request.Context(), wishlist); err != nil {
+		return nil, err
+	}
+
+	return wishlist, nil
+}
+
+// loadOwnWishlist resolves the :id path param, which is either a wishlist id or
+// "default", to a wishlist owned by the user. It writes the error response
+// itself, so callers only need to return when ok is false.
+func (h *WishlistHandler) loadOwnWishlist(c *gin.Context, user *model.User) (*model.Wishlist, bool) {
+	idParam := c.Param("id")
+
+	if idParam == "default" {
+		wishlist, err := h.getOrCreateDefaultWishlist(c, user.ID)
+
+		if err != nil {
+			fail(c, err)
+			return nil, false
+		}
+
+		return wishlist, true
+	}
+
+	wishlistId, ok := parseId(c, idParam, "wishlist")
+	if !ok {
+		return nil, false
+	}
+
+	wishlist, err := h.wishlists.GetById(c.Request.Context(), wishlistId)
+
+	if err != nil {
+		fail(c, err)
+		return nil, false
+	}
+
+	if wishlist.UserId != user.ID {
+		RespondError(c, http.StatusNotFound, "Wishlist not found", "Wishlist not found")
+		return nil, false
+	}
+
+	return wishlist, true
+}
+
+func generateShareToken() (string, error) {
+	b := make([]byte, 24)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// ListWishlists godoc
+// @Summary List my wishlists
+// @Description Returns a page of the authenticated user's wishlists, the default one first and then the named ones newest first. Follow pagination.nextCursor (or the Link header) for the next page.
+// @Tags Wishlists
+// @Produce json
+// @Security BearerAuth
+// @Param limit query int false "Number of wishlists to return (100 max)" default(10)
+// @Param cursor query string false "nextCursor or prevCursor from the previous page"
+// @Param total query bool false "Include the total number of wishlists"
+// @Success 200 {array} model.Wishlist
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /wishlists [get]
+func (h *WishlistHandler) ListWishlists(c *gin.Context) {
+	page, ok := parsePage(c)
+	if !ok {
+		return
+	}
+
+	user, ok := currentUser(c, h.users)
+	if !ok {
+		return
+	}
+
+	if _, err := h.getOrCreateDefaultWishlist(c, user.ID); err != nil {
+		fail(c, err)
+		return
+	}
+
+	wishlists, info, err := h.wishlists.ListByUser(c.Request.Context(), user.ID, page)
+
+	if err != nil {
+		fail(c, err)
+		return
+	}
+
+	RespondPage(c, http.StatusOK, "Wishlists fetched successfully", wishlists, info)
+}
+
+type createWishlistBody struct {
+	Name string `json:"name" binding:"required,max=100"`
+}
+
+// CreateWishlist godoc
+// @Summary Create a named wishlist
+// @Tags Wishlists
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param body body createWishlistBody true "Wishlist"
+// @Success 201 {object} model.Wishlist
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /wishlists [post]
+func (h *WishlistHandler) CreateWishlist(c *gin.Context) {
+	user, ok := currentUser(c, h.users)
+	if !ok {
+		return
+	}
+
+	var body createWishlistBody
+
+	if !bindJSON(c, &body) {
+		return
+	}
+
+	wishlist := &model.Wishlist{
+		UserId: user.ID,
+		Name:   body.Name,
+	}
+	wishlist.ID = uuid.New()
+
+	if err := h.wishlists.Create(c.Request.Context(), wishlist); err != nil {
+		fail(c, err)
+		return
+	}
+
+	RespondSuccess(c, http.StatusCreated, "Wishlist created successfully", wishlist)
+}
+
+// GetWishlist godoc
+// @Summary Get a wishlist with its items
+// @Description Use "default" as the id for the default wishlist
+// @Tags Wishlists
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "Wishlist ID or default"
+// @Success 200 {object} model.Wishlist
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /wishlists/{id} [get]
+func (h *WishlistHandler) GetWishlist(c *gin.Context) {
+	user, ok := currentUser(c, h.users)
+	if !ok {
+		return
+	}
+
+	wishlist, ok := h.loadOwnWishlist(c, user)
+	if !ok {
+		return
+	}
+
+	items, err := h.wishlists.GetItems(c.Request.Context(), wishlist.ID)
+
+	if err != nil {
+		fail(c, err)
+		return
+	}
+
+	wishlist.Items = items
+
+	RespondSuccess(c, http.StatusOK, "Wishlist fetched successfully", wishlist)
+}
+
+type updateWishlistBody struct {
+	Name   *string `json:"name,omitempty" binding:"omitempty,max=100"`
+	Public *bool   `json:"public,omitempty"`
+}
+
+// UpdateWishlist godoc
+// @Summary Rename or share a wishlist
+// @Description Setting public to true creates a share token, setting it to false revokes it
+// @Tags Wishlists
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "Wishlist ID or default"
+// @Param body body updateWishlistBody true "Wishlist data"
+// @Success 200 {object} model.Wishlist
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /wishlists/{id} [patch]
+func (h *WishlistHandler) UpdateWishlist(c *gin.Context) {
+	user, ok := currentUser(c, h.users)
+	if !ok {
+		return
+	}
+
+	var body updateWishlistBody
+
+	if !bindJSON(c, &body) {
+		return
+	}
+
+	wishlist, ok := h.loadOwnWishlist(c, user)
+	if !ok {
+		return
+	}
+
+	if body.Name != nil {
+		wishlist.Name = *body.Name
+	}
+
+	if body.Public != nil {
+		if !*body.Public {
+			wishlist.ShareToken = nil
+		} else if wishlist.ShareToken == nil {
+			token, err := generateShareToken()
+
+			if err != nil {
+				fail(c, err)
+				return
+			}
+
+			wishlist.ShareToken = &token
+		}
+	}
+
+	if err := h.wishlists.Update(c.Request.Context(), wishlist); err != nil {
+		fail(c, err)
+		return
+	}
+
+	RespondSuccess(c, http.StatusOK, "Wishlist updated successfully", wishlist)
+}
+
+// DeleteWishlist godoc
+// @Summary Delete a named wishlist
+// @Description The default wishlist cannot be deleted
+// @Tags Wishlists
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "Wishlist ID"
+// @Success 200 {object} map[string]string
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /wishlists/{id} [delete]
+func (h *WishlistHandler) DeleteWishlist(c *gin.Context) {
+	user, ok := currentUser(c, h.users)
+	if !ok {
+		return
+	}
+
+	wishlist, ok := h.loadOwnWishlist(c, user)
+	if !ok {
+		return
+	}
+
+	if wishlist.IsDefault {
+		RespondError(c, http.StatusBadRequest, "Cannot delete wishlist", "The default wishlist cannot be deleted")
+		return
+	}
+
+	if err := h.wishlists.Delete(c.Request.Context(), wishlist.ID); err != nil {
+		fail(c, err)
+		return
+	}
+
+	RespondSuccess(c, http.StatusOK, "Wishlist deleted successfully", nil)
+}
+
+type wishlistItemBody struct {
+	ProductID string `json:"product_id" binding:"required,uuid"`
+}
+
+// AddToWishlist godoc
+// @Summary Add a product to a wishlist
+// @Tags Wishlists
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "Wishlist ID or default"
+// @Param item body wishlistItemBody true "Product to save"
+// @Success 201 {object} model.WishlistItem
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 409 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /wishlists/{id}/items [post]
+func (h *WishlistHandler) AddToWishlist(c *gin.Context) {
+	user, ok := currentUser(c, h.users)
+	if !ok {
+		return
+	}
+
+	var body wishlistItemBody
+
+	if !bindJSON(c, &body) {
+		return
+	}
+
+	productId, ok := parseId(c, body.ProductID, "product")
+	if !ok {
+		return
+	}
+
+	wishlist, ok := h.loadOwnWishlist(c, user)
+	if !ok {
+		return
+	}
+
+	product, err := h.products.GetById(c.Request.Context(), productId)
+
+	if err != nil {
+		fail(c, err)
+		return
+	}
+
+	exists, err := h.wishlists.ItemExists(c.Request.Context(), wishlist.ID, productId)
+
+	if err != nil {
+		fail(c, err)
+		return
+	}
+
+	if exists {
+		RespondError(c, http.StatusConflict, "item already exists in wishlist", "Item already exists in wishlist")
+		return
+	}
+
+	item := &model.WishlistItem{
+		WishlistId: wishlist.ID,
+		ProductId:  productId,
+		PriceAtAdd: product.DiscountedPrice,
+	}
+	item.ID = uuid.New()
+
+	if err := h.wishlists.AddItem(c.Request.Context(), item); err != nil {
+		fail(c, err)
+		return
+	}
+
+	RespondSuccess(c, http.StatusCreated, "Item added to wishlist successfully", item)
+}
+
+// RemoveFromWishlist godoc
+// @Summary Remove a product from a wishlist
+// @Tags Wishlists
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "Wishlist ID or default"
+// @Param productId path string true "Product ID"
+// @Success 200 {object} map[string]string
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /wishlists/{id}/items/{productId} [delete]
+func (h *WishlistHandler) RemoveFromWishlist(c *gin.Context) {
+	user, ok := currentUser(c, h.users)
+	if !ok {
+		return
+	}
+
+	productId, ok := parseId(c, c.Param("productId"), "product")
+	if !ok {
+		return
+	}
+
+	wishlist, ok := h.loadOwnWishlist(c, user)
+	if !ok {
+		return
+	}
+
+	removed, err := h.wishlists.RemoveItem(c.Request.Context(), wishlist.ID, productId)
+
+	if err != nil {
+		fail(c, err)
+		return
+	}
+
+	if !removed {
+		RespondError(c, http.StatusNotFound, "item not found", "Product is not on this wishlist")
+		return
+	}
+
+	RespondSuccess(c, http.StatusOK, "Item removed from wishlist successfully", nil)
+}
+
+type moveToCartBody struct {
+	Quantity int `json:"quantity" binding:"omitempty,min=1"`
+	// VariantID is required for products with options such as size or colour
+	VariantID *uuid.UUID `json:"variantId,omitempty" binding:"omitempty,exists=variant"`
+}
+
+// MoveWishlistItemToCart godoc
+// @Summary Move a wishlist item to the cart
+// @Description Adds the product to the shopping cart at its current price and removes it from the wishlist. Quantity defaults to 1. Products with options need the variantId of the chosen variant.
+// @Tags Wishlists
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "Wishlist ID or default"
+// @Param productId path string true "Product ID"
+// @Param body body moveToCartBody false "Quantity and variant"
+// @Success 201 {object} model.CartItem
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 409 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /wishlists/{id}/items/{productId}/move-to-cart [post]
+func (h *WishlistHandler) MoveWishlistItemToCart(c *gin.Context) {
+	user, ok := currentUser(c, h.users)
+	if !ok {
+		return
+	}
+
+	productId, ok := parseId(c, c.Param("productId"), "product")
+	if !ok {
+		return
+	}
+
+	body := moveToCartBody{Quantity: 1}
+
+	if c.Request.ContentLength > 0 {
+		if !bindJSON(c, &body) {
+			return
+		}
+	}
+
+	if body.Quantity <= 0 {
+		RespondError(c, http.StatusBadRequest, "invalid quantity", "Quantity must be greater than zero")
+		return
+	}
+
+	wishlist, ok := h.loadOwnWishlist(c, user)
+	if !ok {
+		return
+	}
+
+	onWishlist, err := h.wishlists.ItemExists(c.Request.Context(), wishlist.ID, productId)
+
+	if err != nil {
+		fail(c, err)
+		return
+	}
+
+	if !onWishlist {
+		RespondError(c, http.StatusNotFound, "item not found", "Product is not on this wishlist")
+		return
+	}
+
+	product, err := h.products.GetById(c.Request.Context(), productId)
+
+	if err != nil {
+		fail(c, err)
+		return
+	}
+
+	cart, err := getOrCreateCart(c, h.carts, user.ID)
+
+	if err != nil {
+		fail(c, err)
+		return
+	}
+
+	variant, ok := resolveCartVariant(c, h.productService, product, body.VariantID)
+	if !ok {
+		return
+	}
+
+	inCart, err := h.cartItems.Exists(c.Request.Context(), cart.ID, variant.ID)
+
+	if err != nil {
+		fail(c, err)
+		return
+	}
+
+	if inCart {
+		RespondError(c, http.StatusConflict, "item already exists in cart", "Item already exists in cart")
+		return
+	}
+
+	cartItem := &model.CartItem{
+		ProductId: productId,
+		VariantId: variant.ID,
+		Quantity:  body.Quantity,
+		CartId:    cart.ID,
+		Price:     variant.DiscountedPrice,
+	}
+	cartItem.ID = uuid.New()
+
+	if err := h.cartItems.AddItem(c.Request.Context(), cartItem); err != nil {
+		fail(c, err)
+		return
+	}
+
+	if _, err := h.wishlists.RemoveItem(c.Request.Context(), wishlist.ID, productId); err != nil {
+		fail(c, err)
+		return
+	}
+
+	RespondSuccess(c, http.StatusCreated, "Item moved to cart successfully", cartItem)
+}
+
+// GetSharedWishlist godoc
+// @Summary View a shared wishlist
+// @Description Public, read-only view of a wishlist its owner has shared
+// @Tags Wishlists
+// @Produce json
+// @Param token path string true "Share token"
+// @Success 200 {object} model.Wishlist
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /wishlists/shared/{token} [get]
+func (h *WishlistHandler) GetSharedWishlist(c *gin.Context) {
+	wishlist, err := h.wishlists.GetByShareToken(c.Request.Context(), c.Param("token"))
+
+	if err != nil {
+		fail(c, err)
+		return
+	}
+
+	items, err := h.wishlists.GetItems(c.Request.Context(), wishlist.ID)
+
+	if err != nil {
+		fail(c, err)
+		return
+	}
+
+	wishlist.Items = items
+
+	RespondSuccess(c, http.StatusOK, "Wishlist fetched successfully", wishlist)
+}
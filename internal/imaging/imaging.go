@@ -0,0 +1,239 @@
+// Package imaging cleans up uploaded images and makes the resized variants
+// served to clients.
+package imaging
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"image"
+	"image/draw"
+	"image/gif"
+	"image/jpeg"
+	"image/png"
+
+	"github.com/HugoSmits86/nativewebp"
+	xdraw "golang.org/x/image/draw"
+	"golang.org/x/image/webp"
+)
+
+// MaxPixels caps the size of images that are decoded, so a small file that
+// claims to be enormous cannot exhaust memory.
+const MaxPixels = 40_000_000
+
+// ErrTooManyPixels is returned for images bigger than MaxPixels.
+var ErrTooManyPixels = errors.New("image dimensions are too large")
+
+// Size is a variant size, the image is scaled to fit a Max by Max box.
+type Size struct {
+	Name string
+	Max  int
+}
+
+// Sizes are the variants made of every image.
+var Sizes = []Size{
+	{Name: "thumbnail", Max: 200},
+	{Name: "medium", Max: 600},
+	{Name: "large", Max: 1200},
+}
+
+// File is an encoded image ready to be stored.
+type File struct {
+	ContentType string
+	Extension   string
+	Width       int
+	Height      int
+	Data        []byte
+}
+
+// Variant is one size of an image, in a widely supported format and in WebP.
+type Variant struct {
+	Name string
+	Main *File
+	WebP *File
+}
+
+// Result is an upload after processing.
+type Result struct {
+	Original *File
+	Variants []*Variant
+}
+
+// Process strips the metadata from an uploaded image and makes its variants.
+// contentType is the sniffed type of data.
+//
+// The original is kept byte for byte apart from the metadata, unless it is a
+// JPEG with an EXIF orientation, which has to be re-encoded upright because
+// the orientation goes with the rest of the EXIF data.
+func Process(data []byte, contentType string) (*Result, error) {
+	config, err := decodeConfig(data, contentType)
+	if err != nil {
+		return nil, err
+	}
+
+	if config.Width*config.Height > MaxPixels {
+		return nil, ErrTooManyPixels
+	}
+
+	img, err := decode(data, contentType)
+	if err != nil {
+		return nil, err
+	}
+
+	original := &File{ContentType: contentType, Extension: extension(contentType)}
+
+	switch contentType {
+	case "image/jpeg":
+		if orientation := jpegOrientation(data); orientation != 1 {
+			img = orient(img, orientation)
+			original.Data, err = encodeJPEG(img, 92)
+		} else {
+			original.Data, err = stripJPEG(data)
+		}
+	case "image/png":
+		original.Data, err = stripPNG(data)
+	case "image/webp":
+		original.Data, err = stripWebP(data)
+	default:
+		// GIF has no EXIF, and re-encoding would lose any animation
+		original.Data = data
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	original.Width, original.Height = img.Rect.Dx(), img.Rect.Dy()
+
+	result := &Result{Original: original}
+
+	// photos become JPEG, anything with transparency stays PNG
+	opaque := img.Opaque()
+
+	for _, size := range Sizes {
+		resized := fit(img, size.Max)
+		width, height := resized.Rect.Dx(), resized.Rect.Dy()
+
+		variant := &Variant{Name: size.Name}
+
+		if opaque {
+			encoded, err := encodeJPEG(resized, 82)
+			if err != nil {
+				return nil, err
+			}
+			variant.Main = &File{ContentType: "image/jpeg", Extension: ".jpg", Width: width, Height: height, Data: encoded}
+		} else {
+			var buf bytes.Buffer
+			if err := png.Encode(&buf, resized); err != nil {
+				return nil, err
+			}
+			variant.Main = &File{ContentType: "image/png", Extension: ".png", Width: width, Height: height, Data: buf.Bytes()}
+		}
+
+		var buf bytes.Buffer
+		if err := nativewebp.Encode(&buf, resized, nil); err != nil {
+			return nil, err
+		}
+		variant.WebP = &File{ContentType: "image/webp", Extension: ".webp", Width: width, Height: height, Data: buf.Bytes()}
+
+		result.Variants = append(result.Variants, variant)
+	}
+
+	return result, nil
+}
+
+func extension(contentType string) string {
+	switch contentType {
+	case "image/jpeg":
+		return ".jpg"
+	case "image/png":
+		return ".png"
+	case "image/gif":
+		return ".gif"
+	case "image/webp":
+		return ".webp"
+	}
+
+	return ""
+}
+
+func decodeConfig(data []byte, contentType string) (image.Config, error) {
+	r := bytes.NewReader(data)
+
+	switch contentType {
+	case "image/jpeg":
+		return jpeg.DecodeConfig(r)
+	case "image/png":
+		return png.DecodeConfig(r)
+	case "image/gif":
+		return gif.DecodeConfig(r)
+	case "image/webp":
+		return webp.DecodeConfig(r)
+	}
+
+	return image.Config{}, fmt.Errorf("unsupported image type %s", contentType)
+}
+
+// decode decodes the image into NRGBA, the layout the rest of the package
+// works on.
+func decode(data []byte, contentType string) (*image.NRGBA, error) {
+	r := bytes.NewReader(data)
+
+	var img image.Image
+	var err error
+
+	switch contentType {
+	case "image/jpeg":
+		img, err = jpeg.Decode(r)
+	case "image/png":
+		img, err = png.Decode(r)
+	case "image/gif":
+		img, err = gif.Decode(r)
+	case "image/webp":
+		img, err = webp.Decode(r)
+	default:
+		err = fmt.Errorf("unsupported image type %s", contentType)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	if nrgba, ok := img.(*image.NRGBA); ok {
+		return nrgba, nil
+	}
+
+	nrgba := image.NewNRGBA(image.Rect(0, 0, img.Bounds().Dx(), img.Bounds().Dy()))
+	draw.Draw(nrgba, nrgba.Rect, img, img.Bounds().Min, draw.Src)
+
+	return nrgba, nil
+}
+
+// fit scales the image down to fit a box of the given size, keeping its
+// aspect ratio. Images that already fit are returned as they are, never
+// enlarged.
+func fit(img *image.NRGBA, size int) *image.NRGBA {
+	w, h := img.Rect.Dx(), img.Rect.Dy()
+	if w <= size && h <= size {
+		return img
+	}
+
+	dw, dh := size, max(h*size/w, 1)
+	if h > w {
+		dw, dh = max(w*size/h, 1), size
+	}
+
+	dst := image.NewNRGBA(image.Rect(0, 0, dw, dh))
+	xdraw.CatmullRom.Scale(dst, dst.Rect, img, img.Rect, xdraw.Src, nil)
+
+	return dst
+}
+
+func encodeJPEG(img image.Image, quality int) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, img, &jpeg.Options{Quality: quality}); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
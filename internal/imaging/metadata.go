@@ -0,0 +1,204 @@
+package imaging
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+)
+
+var errMalformed = errors.New("malformed image")
+
+// jpegOrientation reads the EXIF orientation tag of a JPEG, 1 (upright) if
+// there is none.
+func jpegOrientation(data []byte) int {
+	for _, segment := range jpegSegments(data) {
+		if segment.marker != 0xE1 || !bytes.HasPrefix(segment.payload, []byte("Exif\x00\x00")) {
+			continue
+		}
+
+		if o := tiffOrientation(segment.payload[6:]); o != 0 {
+			return o
+		}
+	}
+
+	return 1
+}
+
+// tiffOrientation finds the orientation tag (0x0112) in the first IFD of an
+// EXIF TIFF block.
+func tiffOrientation(tiff []byte) int {
+	if len(tiff) < 8 {
+		return 0
+	}
+
+	var order binary.ByteOrder
+	switch string(tiff[:2]) {
+	case "II":
+		order = binary.LittleEndian
+	case "MM":
+		order = binary.BigEndian
+	default:
+		return 0
+	}
+
+	offset := int(order.Uint32(tiff[4:8]))
+	if offset+2 > len(tiff) {
+		return 0
+	}
+
+	entries := int(order.Uint16(tiff[offset:]))
+	for i := 0; i < entries; i++ {
+		entry := offset + 2 + i*12
+		if entry+12 > len(tiff) {
+			return 0
+		}
+
+		if order.Uint16(tiff[entry:]) == 0x0112 {
+			o := int(order.Uint16(tiff[entry+8:]))
+			if o >= 1 && o <= 8 {
+				return o
+			}
+			return 0
+		}
+	}
+
+	return 0
+}
+
+type jpegSegment struct {
+	marker  byte
+	start   int // offset of the 0xFF byte
+	end     int // offset just past the segment
+	payload []byte
+}
+
+// jpegSegments lists the marker segments before the image data.
+func jpegSegments(data []byte) []jpegSegment {
+	var segments []jpegSegment
+
+	if len(data) < 4 || data[0] != 0xFF || data[1] != 0xD8 {
+		return nil
+	}
+
+	i := 2
+	for i+4 <= len(data) && data[i] == 0xFF {
+		marker := data[i+1]
+
+		// start of scan, the compressed image follows
+		if marker == 0xDA {
+			break
+		}
+
+		length := int(binary.BigEndian.Uint16(data[i+2:]))
+		end := i + 2 + length
+		if length < 2 || end > len(data) {
+			break
+		}
+
+		segments = append(segments, jpegSegment{marker: marker, start: i, end: end, payload: data[i+4 : end]})
+		i = end
+	}
+
+	return segments
+}
+
+// stripJPEG drops the EXIF, XMP and Photoshop (APP1, APP13) segments and
+// comments without touching the image data.
+func stripJPEG(data []byte) ([]byte, error) {
+	segments := jpegSegments(data)
+	if segments == nil {
+		return nil, errMalformed
+	}
+
+	out := make([]byte, 0, len(data))
+	out = append(out, data[:2]...)
+
+	last := 2
+	for _, segment := range segments {
+		if segment.marker == 0xE1 || segment.marker == 0xED || segment.marker == 0xFE {
+			out = append(out, data[last:segment.start]...)
+			last = segment.end
+		}
+	}
+
+	return append(out, data[last:]...), nil
+}
+
+// pngMetadataChunks are the chunks that can carry camera, location or
+// free-form text metadata.
+var pngMetadataChunks = map[string]bool{
+	"eXIf": true,
+	"tEXt": true,
+	"zTXt": true,
+	"iTXt": true,
+	"tIME": true,
+}
+
+// stripPNG drops the metadata chunks. Every chunk carries its own CRC, so
+// the rest are copied as they are.
+func stripPNG(data []byte) ([]byte, error) {
+	const signature = "\x89PNG\r\n\x1a\n"
+
+	if !bytes.HasPrefix(data, []byte(signature)) {
+		return nil, errMalformed
+	}
+
+	out := make([]byte, 0, len(data))
+	out = append(out, signature...)
+
+	i := len(signature)
+	for i+12 <= len(data) {
+		length := int(binary.BigEndian.Uint32(data[i:]))
+		end := i + 12 + length
+		if length < 0 || end > len(data) {
+			return nil, errMalformed
+		}
+
+		if !pngMetadataChunks[string(data[i+4:i+8])] {
+			out = append(out, data[i:end]...)
+		}
+
+		i = end
+	}
+
+	return out, nil
+}
+
+// stripWebP drops the EXIF and XMP chunks of a WebP file and clears their
+// flags in the VP8X header.
+func stripWebP(data []byte) ([]byte, error) {
+	if len(data) < 12 || string(data[:4]) != "RIFF" || string(data[8:12]) != "WEBP" {
+		return nil, errMalformed
+	}
+
+	out := make([]byte, 12, len(data))
+	copy(out, data[:12])
+
+	i := 12
+	for i+8 <= len(data) {
+		fourCC := string(data[i : i+4])
+		length := int(binary.LittleEndian.Uint32(data[i+4:]))
+		end := i + 8 + length + length%2 // chunks are padded to an even size
+		if end > len(data) {
+			return nil, errMalformed
+		}
+
+		switch fourCC {
+		case "EXIF", "XMP ":
+		case "VP8X":
+			chunk := append([]byte(nil), data[i:end]...)
+			if len(chunk) > 8 {
+				chunk[8] &^= 0x08 | 0x04
+			}
+			out = append(out, chunk...)
+		default:
+			out = append(out, data[i:end]...)
+		}
+
+		i = end
+	}
+
+	binary.LittleEndian.PutUint32(out[4:8], uint32(len(out)-8))
+
+	return out, nil
+}
@@ -0,0 +1,351 @@
+package imaging
+
+import (
+	"bytes"
+	"encoding/binary"
+	"hash/crc32"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"testing"
+
+	"github.com/HugoSmits86/nativewebp"
+	"golang.org/x/image/webp"
+)
+
+// secret is written into every metadata fixture, so finding it in a
+// stripped file means something was left behind.
+const secret = "GPS -1.2921,36.8219"
+
+// halves is a 16 by 8 picture, red on the left and blue on the right, which
+// tells which way up it ended.
+func halves() *image.NRGBA {
+	img := image.NewNRGBA(image.Rect(0, 0, 16, 8))
+	for y := 0; y < 8; y++ {
+		for x := 0; x < 16; x++ {
+			c := color.NRGBA{R: 255, A: 255}
+			if x >= 8 {
+				c = color.NRGBA{B: 255, A: 255}
+			}
+			img.SetNRGBA(x, y, c)
+		}
+	}
+
+	return img
+}
+
+type byteOrder interface {
+	binary.ByteOrder
+	binary.AppendByteOrder
+}
+
+// tiff is an EXIF TIFF block whose first IFD holds the orientation.
+func tiff(order byteOrder, orientation uint16) []byte {
+	block := make([]byte, 8, 26)
+	if order == binary.LittleEndian {
+		copy(block, "II")
+	} else {
+		copy(block, "MM")
+	}
+	order.PutUint16(block[2:], 42)
+	order.PutUint32(block[4:], 8)
+
+	block = order.AppendUint16(block, 1)
+	block = order.AppendUint16(block, 0x0112) // orientation
+	block = order.AppendUint16(block, 3)      // SHORT
+	block = order.AppendUint32(block, 1)
+	block = order.AppendUint16(block, orientation)
+	block = order.AppendUint16(block, 0)
+	return order.AppendUint32(block, 0)
+}
+
+func jpegSegmentOf(marker byte, payload []byte) []byte {
+	segment := []byte{0xFF, marker, 0, 0}
+	binary.BigEndian.PutUint16(segment[2:], uint16(len(payload)+2))
+	return append(segment, payload...)
+}
+
+// withSegments puts the segments straight after the start of image marker.
+func withSegments(data []byte, segments ...[]byte) []byte {
+	out := append([]byte(nil), data[:2]...)
+	for _, segment := range segments {
+		out = append(out, segment...)
+	}
+	return append(out, data[2:]...)
+}
+
+func encodedJPEG(t *testing.T) []byte {
+	t.Helper()
+
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, halves(), &jpeg.Options{Quality: 95}); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func exifJPEG(t *testing.T, order byteOrder, orientation uint16) []byte {
+	t.Helper()
+
+	return withSegments(encodedJPEG(t), jpegSegmentOf(0xE1, append([]byte("Exif\x00\x00"), tiff(order, orientation)...)))
+}
+
+func TestJPEGOrientation(t *testing.T) {
+	for orientation := uint16(1); orientation <= 8; orientation++ {
+		for _, order := range []byteOrder{binary.LittleEndian, binary.BigEndian} {
+			if got := jpegOrientation(exifJPEG(t, order, orientation)); got != int(orientation) {
+				t.Errorf("%v orientation %d read as %d", order, orientation, got)
+			}
+		}
+	}
+
+	plain := encodedJPEG(t)
+
+	for name, data := range map[string][]byte{
+		"no EXIF":                  plain,
+		"orientation out of range": exifJPEG(t, binary.BigEndian, 9),
+		"XMP, not EXIF":            withSegments(plain, jpegSegmentOf(0xE1, []byte("http://ns.adobe.com/xap/1.0/\x00"+secret))),
+		"not a TIFF block":         withSegments(plain, jpegSegmentOf(0xE1, []byte("Exif\x00\x00XX\x00\x2a\x00\x00\x00\x08"))),
+		"IFD past the end":         withSegments(plain, jpegSegmentOf(0xE1, []byte("Exif\x00\x00MM\x00\x2a\xff\xff\xff\xf0"))),
+		"entries past the end":     withSegments(plain, jpegSegmentOf(0xE1, []byte("Exif\x00\x00MM\x00\x2a\x00\x00\x00\x08\xff\xff\x01\x12"))),
+		"empty EXIF":               withSegments(plain, jpegSegmentOf(0xE1, []byte("Exif\x00\x00"))),
+	} {
+		if got := jpegOrientation(data); got != 1 {
+			t.Errorf("%s: orientation %d, want 1", name, got)
+		}
+	}
+}
+
+func TestStripJPEG(t *testing.T) {
+	plain := encodedJPEG(t)
+
+	data := withSegments(plain,
+		jpegSegmentOf(0xE1, append([]byte("Exif\x00\x00"), tiff(binary.BigEndian, 1)...)),
+		jpegSegmentOf(0xE1, []byte("http://ns.adobe.com/xap/1.0/\x00<x:xmpmeta>"+secret+"</x:xmpmeta>")),
+		jpegSegmentOf(0xED, []byte("Photoshop 3.0\x00"+secret)),
+		jpegSegmentOf(0xFE, []byte(secret)),
+	)
+
+	stripped, err := stripJPEG(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !bytes.Equal(stripped, plain) {
+		t.Errorf("stripping left %d bytes, want the %d of the JPEG without metadata", len(stripped), len(plain))
+	}
+
+	for _, leftover := range []string{secret, "Exif"} {
+		if bytes.Contains(stripped, []byte(leftover)) {
+			t.Errorf("%q is still in the stripped JPEG", leftover)
+		}
+	}
+
+	if _, err := jpeg.Decode(bytes.NewReader(stripped)); err != nil {
+		t.Errorf("the stripped JPEG does not decode: %v", err)
+	}
+}
+
+func TestProcessOrientsJPEG(t *testing.T) {
+	// 6 is a camera held on its side, the picture turns 90 degrees clockwise
+	result, err := Process(exifJPEG(t, binary.LittleEndian, 6), "image/jpeg")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	original := result.Original
+	if original.Width != 8 || original.Height != 16 {
+		t.Fatalf("the original is %dx%d, want 8x16", original.Width, original.Height)
+	}
+
+	if bytes.Contains(original.Data, []byte("Exif")) {
+		t.Error("the EXIF block was kept")
+	}
+
+	img, err := jpeg.Decode(bytes.NewReader(original.Data))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	top, bottom := color.NRGBAModel.Convert(img.At(4, 2)).(color.NRGBA), color.NRGBAModel.Convert(img.At(4, 13)).(color.NRGBA)
+	if top.R < 200 || top.B > 60 || bottom.B < 200 || bottom.R > 60 {
+		t.Errorf("got %v on top and %v below, want red above blue", top, bottom)
+	}
+}
+
+func pngChunk(kind string, data []byte) []byte {
+	chunk := binary.BigEndian.AppendUint32(nil, uint32(len(data)))
+	chunk = append(chunk, kind...)
+	chunk = append(chunk, data...)
+	return binary.BigEndian.AppendUint32(chunk, crc32.ChecksumIEEE(chunk[4:]))
+}
+
+func encodedPNG(t *testing.T) []byte {
+	t.Helper()
+
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, halves()); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+// metadataPNG has metadata chunks after the IHDR chunk, which ends 33 bytes
+// in.
+func metadataPNG(t *testing.T) []byte {
+	t.Helper()
+
+	plain := encodedPNG(t)
+
+	data := append([]byte(nil), plain[:33]...)
+	data = append(data, pngChunk("tEXt", []byte("Comment\x00"+secret))...)
+	data = append(data, pngChunk("iTXt", []byte("Location\x00\x00\x00\x00\x00"+secret))...)
+	data = append(data, pngChunk("zTXt", []byte("Author\x00\x00"+secret))...)
+	data = append(data, pngChunk("eXIf", tiff(binary.BigEndian, 1))...)
+	data = append(data, pngChunk("tIME", []byte{0x07, 0xea, 10, 19, 12, 0, 0})...)
+	return append(data, plain[33:]...)
+}
+
+func TestStripPNG(t *testing.T) {
+	stripped, err := stripPNG(metadataPNG(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !bytes.Equal(stripped, encodedPNG(t)) {
+		t.Error("stripping did not give back the PNG without metadata")
+	}
+
+	for _, leftover := range []string{secret, "tEXt", "iTXt", "zTXt", "eXIf", "tIME"} {
+		if bytes.Contains(stripped, []byte(leftover)) {
+			t.Errorf("%q is still in the stripped PNG", leftover)
+		}
+	}
+
+	if _, err := png.Decode(bytes.NewReader(stripped)); err != nil {
+		t.Errorf("the stripped PNG does not decode: %v", err)
+	}
+}
+
+func riffChunk(fourCC string, data []byte) []byte {
+	chunk := append([]byte(fourCC), binary.LittleEndian.AppendUint32(nil, uint32(len(data)))...)
+	chunk = append(chunk, data...)
+	if len(data)%2 == 1 {
+		chunk = append(chunk, 0)
+	}
+	return chunk
+}
+
+// metadataWebP is an extended WebP, its VP8X header saying EXIF and XMP
+// chunks follow the image.
+func metadataWebP(t *testing.T) []byte {
+	t.Helper()
+
+	var buf bytes.Buffer
+	if err := nativewebp.Encode(&buf, halves(), nil); err != nil {
+		t.Fatal(err)
+	}
+	encoded := buf.Bytes()
+
+	// flags, reserved, then the canvas width and height less one
+	header := []byte{0x08 | 0x04, 0, 0, 0, 15, 0, 0, 7, 0, 0}
+
+	body := []byte("WEBP")
+	body = append(body, riffChunk("VP8X", header)...)
+	body = append(body, encoded[12:]...)
+	body = append(body, riffChunk("EXIF", tiff(binary.LittleEndian, 1))...)
+	body = append(body, riffChunk("XMP ", []byte("<x:xmpmeta>"+secret+"</x:xmpmeta>"))...)
+
+	return append([]byte("RIFF"), append(binary.LittleEndian.AppendUint32(nil, uint32(len(body))), body...)...)
+}
+
+func TestStripWebP(t *testing.T) {
+	data := metadataWebP(t)
+
+	if _, err := webp.Decode(bytes.NewReader(data)); err != nil {
+		t.Fatalf("the fixture does not decode: %v", err)
+	}
+
+	stripped, err := stripWebP(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, leftover := range []string{secret, "EXIF", "XMP "} {
+		if bytes.Contains(stripped, []byte(leftover)) {
+			t.Errorf("%q is still in the stripped WebP", leftover)
+		}
+	}
+
+	if size := binary.LittleEndian.Uint32(stripped[4:]); int(size) != len(stripped)-8 {
+		t.Errorf("the RIFF header says %d bytes follow, %d do", size, len(stripped)-8)
+	}
+
+	if flags := stripped[20]; flags&(0x08|0x04) != 0 {
+		t.Errorf("the VP8X flags %08b still announce metadata", flags)
+	}
+
+	img, err := webp.Decode(bytes.NewReader(stripped))
+	if err != nil {
+		t.Fatalf("the stripped WebP does not decode: %v", err)
+	}
+
+	if img.Bounds().Dx() != 16 || img.Bounds().Dy() != 8 {
+		t.Errorf("the stripped WebP is %v", img.Bounds())
+	}
+}
+
+// TestMalformed feeds the parsers every truncation of the fixtures and
+// chunks claiming to be longer than the file. They may refuse the data but
+// must not read past it.
+func TestMalformed(t *testing.T) {
+	jpegData := withSegments(exifJPEG(t, binary.BigEndian, 6), jpegSegmentOf(0xFE, []byte(secret)))
+	pngData := metadataPNG(t)
+	webpData := metadataWebP(t)
+
+	overlong := func(data []byte, at int, length []byte) []byte {
+		data = append([]byte(nil), data...)
+		copy(data[at:], length)
+		return data
+	}
+
+	for name, data := range map[string][]byte{
+		"JPEG segment too long":  overlong(jpegData, 4, []byte{0xff, 0xff}),
+		"JPEG segment too short": overlong(jpegData, 4, []byte{0x00, 0x01}),
+		"PNG chunk too long":     overlong(pngData, 33, []byte{0xff, 0xff, 0xff, 0xff}),
+		"WebP chunk too long":    overlong(webpData, 16, []byte{0xff, 0xff, 0xff, 0xff}),
+	} {
+		if _, err := Process(data, contentTypeOf(data)); err == nil {
+			t.Errorf("%s: processed without an error", name)
+		}
+	}
+
+	for _, data := range [][]byte{jpegData, pngData, webpData} {
+		contentType := contentTypeOf(data)
+
+		for n := range len(data) {
+			truncated := data[:n]
+
+			jpegOrientation(truncated)
+			stripJPEG(truncated)
+			stripPNG(truncated)
+			stripWebP(truncated)
+
+			if _, err := Process(truncated, contentType); err == nil && n < len(data)/2 {
+				t.Errorf("%s cut to %d bytes processed without an error", contentType, n)
+			}
+		}
+	}
+}
+
+func contentTypeOf(data []byte) string {
+	switch {
+	case bytes.HasPrefix(data, []byte{0xFF, 0xD8}):
+		return "image/jpeg"
+	case bytes.HasPrefix(data, []byte("\x89PNG")):
+		return "image/png"
+	default:
+		return "image/webp"
+	}
+}
@@ -0,0 +1,52 @@
+package imaging
+
+import (
+	"image"
+)
+
+// orient turns the image upright according to its EXIF orientation, since
+// the tag is lost once the metadata is stripped.
+func orient(src *image.NRGBA, orientation int) *image.NRGBA {
+	if orientation <= 1 || orientation > 8 {
+		return src
+	}
+
+	w, h := src.Rect.Dx(), src.Rect.Dy()
+
+	// orientations 5 to 8 swap width and height
+	dw, dh := w, h
+	if orientation >= 5 {
+		dw, dh = h, w
+	}
+
+	dst := image.NewNRGBA(image.Rect(0, 0, dw, dh))
+
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			var dx, dy int
+
+			switch orientation {
+			case 2: // mirrored
+				dx, dy = w-1-x, y
+			case 3: // rotated 180
+				dx, dy = w-1-x, h-1-y
+			case 4: // flipped
+				dx, dy = x, h-1-y
+			case 5: // transposed
+				dx, dy = y, x
+			case 6: // needs rotating 90 clockwise
+				dx, dy = h-1-y, x
+			case 7: // transversed
+				dx, dy = h-1-y, w-1-x
+			case 8: // needs rotating 90 counter-clockwise
+				dx, dy = y, w-1-x
+			}
+
+			s := src.PixOffset(src.Rect.Min.X+x, src.Rect.Min.Y+y)
+			d := dst.PixOffset(dx, dy)
+			copy(dst.Pix[d:d+4], src.Pix[s:s+4])
+		}
+	}
+
+	return dst
+}
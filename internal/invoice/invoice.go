@@ -0,0 +1,250 @@
+// Package invoice renders order invoices and receipts as PDF.
+package invoice
+
+import (
+	"bytes"
+	"fmt"
+	"time"
+
+	"github.com/go-pdf/fpdf"
+)
+
+// Business is the seller shown at the top of every invoice.
+type Business struct {
+	Name    string
+	Address string
+	Phone   string
+	Email   string
+	KRAPin  string
+}
+
+// Line is one order item as printed on the invoice. Amounts are in shillings.
+type Line struct {
+	Description string
+	Quantity    int
+	UnitPrice   int64
+	Discount    int64
+	TaxLabel    string
+	Tax         int64
+	Total       int64
+}
+
+// TaxBand is the VAT charged at one rate, for the breakdown under the lines.
+type TaxBand struct {
+	Label   string
+	Taxable int64
+	Tax     int64
+}
+
+// Document is everything printed on an invoice. An order that has not been
+// paid has no Number and is rendered as a pro forma invoice.
+type Document struct {
+	Business Business
+
+	Number   string
+	OrderID  string
+	IssuedAt time.Time
+
+	CustomerName  string
+	CustomerEmail string
+
+	Lines         []Line
+	Subtotal      int64
+	DiscountTotal int64
+	TaxBands      []TaxBand
+	TaxTotal      int64
+	Total         int64
+
+	PricesIncludeTax bool
+
+	Paid             bool
+	PaidAt           *time.Time
+	PaymentReference string
+}
+
+// Title is the heading of the document.
+func (d *Document) Title() string {
+	if d.Number == "" {
+		return "PRO FORMA INVOICE"
+	}
+
+	return "TAX INVOICE / RECEIPT"
+}
+
+// Filename is the name the PDF is downloaded or attached as.
+func (d *Document) Filename() string {
+	if d.Number == "" {
+		return "order-" + d.OrderID + ".pdf"
+	}
+
+	return d.Number + ".pdf"
+}
+
+func ksh(amount int64) string {
+	return fmt.Sprintf("Ksh.%d", amount)
+}
+
+// Render draws the document as an A4 PDF.
+func Render(d *Document) ([]byte, error) {
+	pdf := fpdf.New("P", "mm", "A4", "")
+	pdf.SetMargins(15, 15, 15)
+	pdf.SetAutoPageBreak(true, 15)
+	pdf.SetTitle(d.Title(), true)
+	pdf.SetCreator(d.Business.Name, true)
+	pdf.AddPage()
+
+	// the core fonts are cp1252, names and descriptions come in as UTF-8
+	tr := pdf.UnicodeTranslatorFromDescriptor("")
+
+	// seller
+	pdf.SetFont("Helvetica", "B", 16)
+	pdf.CellFormat(110, 8, tr(d.Business.Name), "", 0, "L", false, 0, "")
+
+	pdf.SetFont("Helvetica", "B", 12)
+	pdf.CellFormat(70, 8, d.Title(), "", 1, "R", false, 0, "")
+
+	pdf.SetFont("Helvetica", "", 9)
+	for _, line := range []string{
+		d.Business.Address,
+		d.Business.Phone,
+		d.Business.Email,
+	} {
+		if line != "" {
+			pdf.CellFormat(0, 5, tr(line), "", 1, "L", false, 0, "")
+		}
+	}
+
+	if d.Business.KRAPin != "" {
+		pdf.CellFormat(0, 5, "KRA PIN: "+d.Business.KRAPin, "", 1, "L", false, 0, "")
+	}
+
+	pdf.Ln(6)
+
+	// invoice and customer details
+	details := [][2]string{}
+	if d.Number != "" {
+		details = append(details, [2]string{"Invoice No.", d.Number})
+	}
+	details = append(details,
+		[2]string{"Order ID", d.OrderID},
+		[2]string{"Date", d.IssuedAt.Format("02 Jan 2006")},
+		[2]string{"Billed to", d.CustomerName},
+	)
+	if d.CustomerEmail != "" {
+		details = append(details, [2]string{"", d.CustomerEmail})
+	}
+
+	for _, detail := range details {
+		pdf.SetFont("Helvetica", "B", 9)
+		pdf.CellFormat(30, 5, detail[0], "", 0, "L", false, 0, "")
+		pdf.SetFont("Helvetica", "", 9)
+		pdf.CellFormat(0, 5, tr(detail[1]), "", 1, "L", false, 0, "")
+	}
+
+	pdf.Ln(6)
+
+	// line items
+	widths := []float64{70, 12, 24, 22, 24, 28}
+	headers := []string{"Item", "Qty", "Unit price", "Discount", "VAT", "Amount"}
+
+	pdf.SetFont("Helvetica", "B", 9)
+	pdf.SetFillColor(235, 235, 235)
+	for i, header := range headers {
+		align := "R"
+		if i == 0 {
+			align = "L"
+		}
+		pdf.CellFormat(widths[i], 7, header, "B", 0, align, true, 0, "")
+	}
+	pdf.Ln(-1)
+
+	pdf.SetFont("Helvetica", "", 9)
+	for _, line := range d.Lines {
+		description := tr(line.Description)
+		if len(description) > 45 {
+			description = description[:42] + "..."
+		}
+
+		pdf.CellFormat(widths[0], 6, description, "", 0, "L", false, 0, "")
+		pdf.CellFormat(widths[1], 6, fmt.Sprintf("%d", line.Quantity), "", 0, "R", false, 0, "")
+		pdf.CellFormat(widths[2], 6, ksh(line.UnitPrice), "", 0, "R", false, 0, "")
+		pdf.CellFormat(widths[3], 6, ksh(line.Discount), "", 0, "R", false, 0, "")
+		pdf.CellFormat(widths[4], 6, ksh(line.Tax), "", 0, "R", false, 0, "")
+		pdf.CellFormat(widths[5], 6, ksh(line.Total), "", 1, "R", false, 0, "")
+
+		pdf.SetFont("Helvetica", "I", 7)
+		pdf.CellFormat(widths[0], 4, line.TaxLabel, "", 1, "L", false, 0, "")
+		pdf.SetFont("Helvetica", "", 9)
+	}
+
+	pdf.CellFormat(0, 2, "", "T", 1, "L", false, 0, "")
+
+	// totals
+	totals := [][2]string{{"Subtotal", ksh(d.Subtotal)}}
+	if d.DiscountTotal > 0 {
+		totals = append(totals, [2]string{"Discounts", "-" + ksh(d.DiscountTotal)})
+	}
+	if d.PricesIncludeTax {
+		totals = append(totals, [2]string{"VAT (included)", ksh(d.TaxTotal)})
+	} else {
+		totals = append(totals, [2]string{"VAT", ksh(d.TaxTotal)})
+	}
+	totals = append(totals, [2]string{"Total", ksh(d.Total)})
+
+	for i, total := range totals {
+		style := ""
+		if i == len(totals)-1 {
+			style = "B"
+		}
+		pdf.SetFont("Helvetica", style, 9)
+		pdf.CellFormat(140, 6, total[0], "", 0, "R", false, 0, "")
+		pdf.CellFormat(40, 6, total[1], "", 1, "R", false, 0, "")
+	}
+
+	pdf.Ln(6)
+
+	// VAT breakdown
+	if len(d.TaxBands) > 0 {
+		pdf.SetFont("Helvetica", "B", 9)
+		pdf.CellFormat(0, 6, "VAT breakdown", "", 1, "L", false, 0, "")
+
+		pdf.CellFormat(60, 6, "Rate", "B", 0, "L", false, 0, "")
+		pdf.CellFormat(40, 6, "Taxable amount", "B", 0, "R", false, 0, "")
+		pdf.CellFormat(40, 6, "VAT", "B", 1, "R", false, 0, "")
+
+		pdf.SetFont("Helvetica", "", 9)
+		for _, band := range d.TaxBands {
+			pdf.CellFormat(60, 6, band.Label, "", 0, "L", false, 0, "")
+			pdf.CellFormat(40, 6, ksh(band.Taxable), "", 0, "R", false, 0, "")
+			pdf.CellFormat(40, 6, ksh(band.Tax), "", 1, "R", false, 0, "")
+		}
+
+		pdf.Ln(6)
+	}
+
+	// payment
+	pdf.SetFont("Helvetica", "B", 9)
+	pdf.CellFormat(0, 6, "Payment", "", 1, "L", false, 0, "")
+	pdf.SetFont("Helvetica", "", 9)
+
+	if d.Paid {
+		paidOn := ""
+		if d.PaidAt != nil {
+			paidOn = " on " + d.PaidAt.Format("02 Jan 2006 15:04")
+		}
+		pdf.CellFormat(0, 5, "Paid"+paidOn, "", 1, "L", false, 0, "")
+
+		if d.PaymentReference != "" {
+			pdf.CellFormat(0, 5, "Payment reference: "+tr(d.PaymentReference), "", 1, "L", false, 0, "")
+		}
+	} else {
+		pdf.CellFormat(0, 5, "Not paid yet. A tax invoice with an invoice number is issued once payment is received.", "", 1, "L", false, 0, "")
+	}
+
+	var buf bytes.Buffer
+	if err := pdf.Output(&buf); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
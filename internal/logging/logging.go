@@ -0,0 +1,83 @@
+// Package logging sets up the store's structured logs. Lines logged with the
+// context of a request carry its request ID, route and, once they have
+// signed in, the user, wherever in the store they are logged from.
+package logging
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"strings"
+
+	"github.com/Oj-washingtone/savannah-store/internal/config"
+)
+
+// New is a logger writing cfg.Format lines at cfg.Level and above to w.
+func New(cfg config.Logging, w io.Writer) *slog.Logger {
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(cfg.Level)); err != nil {
+		level = slog.LevelInfo
+	}
+
+	opts := &slog.HandlerOptions{Level: level}
+
+	var handler slog.Handler
+	if strings.EqualFold(cfg.Format, "text") {
+		handler = slog.NewTextHandler(w, opts)
+	} else {
+		handler = slog.NewJSONHandler(w, opts)
+	}
+
+	return slog.New(contextHandler{handler})
+}
+
+// request is what the log lines of a request say about it.
+type request struct {
+	id     string
+	route  string
+	userID string
+}
+
+type requestKey struct{}
+
+// WithRequest is ctx for the request with the given ID, served by route, the
+// pattern it matched such as /api/products/:id.
+func WithRequest(ctx context.Context, id, route string) context.Context {
+	return context.WithValue(ctx, requestKey{}, request{id: id, route: route})
+}
+
+// WithUser is ctx for a request made by the signed-in user with userID.
+func WithUser(ctx context.Context, userID string) context.Context {
+	r, _ := ctx.Value(requestKey{}).(request)
+	r.userID = userID
+	return context.WithValue(ctx, requestKey{}, r)
+}
+
+// contextHandler adds the request ctx belongs to to each line.
+type contextHandler struct {
+	slog.Handler
+}
+
+func (h contextHandler) Handle(ctx context.Context, record slog.Record) error {
+	if r, ok := ctx.Value(requestKey{}).(request); ok {
+		if r.id != "" {
+			record.AddAttrs(slog.String("request_id", r.id))
+		}
+		if r.route != "" {
+			record.AddAttrs(slog.String("route", r.route))
+		}
+		if r.userID != "" {
+			record.AddAttrs(slog.String("user_id", r.userID))
+		}
+	}
+
+	return h.Handler.Handle(ctx, record)
+}
+
+func (h contextHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return contextHandler{h.Handler.WithAttrs(attrs)}
+}
+
+func (h contextHandler) WithGroup(name string) slog.Handler {
+	return contextHandler{h.Handler.WithGroup(name)}
+}
@@ -0,0 +1,53 @@
+package logging
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"testing"
+
+	"github.com/Oj-washingtone/savannah-store/internal/config"
+)
+
+func TestRequestFields(t *testing.T) {
+	var out bytes.Buffer
+	logger := New(config.Logging{Level: "info", Format: "json"}, &out)
+
+	ctx := WithRequest(context.Background(), "req-1", "/api/orders/:id")
+	ctx = WithUser(ctx, "auth0|amina")
+
+	logger.With("order_id", "o-1").InfoContext(ctx, "Paid")
+	logger.DebugContext(ctx, "Not logged at info")
+
+	var line map[string]any
+	if err := json.Unmarshal(out.Bytes(), &line); err != nil {
+		t.Fatalf("want one JSON line, got %q: %v", out.String(), err)
+	}
+
+	for key, want := range map[string]string{
+		"msg":        "Paid",
+		"request_id": "req-1",
+		"route":      "/api/orders/:id",
+		"user_id":    "auth0|amina",
+		"order_id":   "o-1",
+	} {
+		if line[key] != want {
+			t.Errorf("%s = %v, want %q", key, line[key], want)
+		}
+	}
+}
+
+func TestOutsideRequest(t *testing.T) {
+	var out bytes.Buffer
+	New(config.Logging{Level: "debug", Format: "json"}, &out).Log(context.Background(), slog.LevelDebug, "Started")
+
+	var line map[string]any
+	if err := json.Unmarshal(out.Bytes(), &line); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, ok := line["request_id"]; ok {
+		t.Errorf("request_id logged outside of a request: %s", out.String())
+	}
+}
@@ -2,15 +2,17 @@ package middleware
 
 import (
 	"net/http"
-	"os"
 	"strings"
 
-	"github.com/Oj-washingtone/savannah-store/internal/authenticator"
+	"github.com/Oj-washingtone/savannah-store/internal/logging"
 	"github.com/coreos/go-oidc/v3/oidc"
 	"github.com/gin-gonic/gin"
 )
 
-func AuthMiddleware() gin.HandlerFunc {
+// AuthMiddleware lets through requests carrying a bearer ID token that
+// verifier accepts, and puts the token's claims on the context as "user". The
+// token's subject is logged as the user of the request.
+func AuthMiddleware(verifier *oidc.IDTokenVerifier) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		authHeader := c.GetHeader("Authorization")
 
@@ -29,18 +31,7 @@ func AuthMiddleware() gin.HandlerFunc {
 
 		rawIDToken := parts[1]
 
-		auth, err := authenticator.New()
-		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to init authenticator"})
-			c.Abort()
-			return
-		}
-
-		oidcConfig := &oidc.Config{
-			ClientID: os.Getenv("AUTH0_CLIENT_ID"),
-		}
-
-		idToken, err := auth.Verifier(oidcConfig).Verify(c.Request.Context(), rawIDToken)
+		idToken, err := verifier.Verify(c.Request.Context(), rawIDToken)
 		if err != nil {
 			c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid token"})
 			c.Abort()
@@ -56,6 +47,10 @@ func AuthMiddleware() gin.HandlerFunc {
 
 		c.Set("user", claims)
 
+		if sub, ok := claims["sub"].(string); ok {
+			c.Request = c.Request.WithContext(logging.WithUser(c.Request.Context(), sub))
+		}
+
 		c.Next()
 	}
 }
@@ -0,0 +1,42 @@
+package middleware
+
+import (
+	"log/slog"
+
+	"github.com/Oj-washingtone/savannah-store/internal/apperr"
+	"github.com/gin-gonic/gin"
+)
+
+// ErrorHandler renders the last error a handler added with c.Error, using
+// the status of its apperr kind. Internal errors are logged and answered
+// with a fixed message, so database and driver details never reach clients.
+func ErrorHandler() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Next()
+
+		if len(c.Errors) == 0 {
+			return
+		}
+
+		err := c.Errors.Last().Err
+		kind := apperr.KindOf(err)
+
+		if kind == apperr.KindInternal || kind == apperr.KindUnavailable {
+			slog.ErrorContext(c.Request.Context(), "Request failed",
+				"method", c.Request.Method,
+				"path", c.Request.URL.Path,
+				"error", err,
+			)
+		}
+
+		if c.Writer.Written() {
+			return
+		}
+
+		c.JSON(kind.Status(), gin.H{
+			"success": false,
+			"message": apperr.Public(err),
+			"error":   kind.String(),
+		})
+	}
+}
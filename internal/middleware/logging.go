@@ -0,0 +1,93 @@
+package middleware
+
+import (
+	"io"
+	"log/slog"
+	"net/http"
+	"runtime/debug"
+	"strings"
+	"time"
+
+	"github.com/Oj-washingtone/savannah-store/internal/apperr"
+	"github.com/Oj-washingtone/savannah-store/internal/logging"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// RequestIDHeader carries the ID of a request, both ways. A proxy or client
+// can set it to follow a request through their logs and ours.
+const RequestIDHeader = "X-Request-ID"
+
+// maxRequestIDLength keeps a client from filling the logs through the header
+const maxRequestIDLength = 128
+
+// RequestID gives each request an ID, the one in its X-Request-ID header or
+// a new one, sends it back in the same header and puts it and the route on
+// the request's context for the log. It runs before everything else.
+func RequestID() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id := c.GetHeader(RequestIDHeader)
+		if !validRequestID(id) {
+			id = uuid.NewString()
+		}
+
+		c.Header(RequestIDHeader, id)
+		c.Request = c.Request.WithContext(logging.WithRequest(c.Request.Context(), id, c.FullPath()))
+
+		c.Next()
+	}
+}
+
+// validRequestID accepts IDs of printable ASCII without spaces, so one
+// cannot break up a line of the text log.
+func validRequestID(id string) bool {
+	if id == "" || len(id) > maxRequestIDLength {
+		return false
+	}
+
+	return !strings.ContainsFunc(id, func(r rune) bool {
+		return r <= ' ' || r > '~'
+	})
+}
+
+// AccessLog logs each request once it has been answered. The health probes
+// are logged at debug level, as the orchestrator calls them every few
+// seconds.
+func AccessLog() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+
+		c.Next()
+
+		level := slog.LevelInfo
+		if path := c.Request.URL.Path; path == "/healthz" || path == "/readyz" {
+			level = slog.LevelDebug
+		}
+
+		slog.Log(c.Request.Context(), level, "Request",
+			"method", c.Request.Method,
+			"path", c.Request.URL.Path,
+			"status", c.Writer.Status(),
+			"duration_ms", float64(time.Since(start).Microseconds())/1000,
+			"bytes", c.Writer.Size(),
+			"client_ip", c.ClientIP(),
+		)
+	}
+}
+
+// Recovery answers a request whose handler panicked with an internal error
+// and logs the panic with its stack.
+func Recovery() gin.HandlerFunc {
+	return gin.CustomRecoveryWithWriter(io.Discard, func(c *gin.Context, recovered any) {
+		slog.ErrorContext(c.Request.Context(), "Panic serving the request",
+			"panic", recovered,
+			"stack", string(debug.Stack()),
+		)
+
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{
+			"success": false,
+			"message": apperr.Public(nil),
+			"error":   apperr.KindInternal.String(),
+		})
+	})
+}
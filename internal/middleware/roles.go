@@ -0,0 +1,52 @@
+package middleware
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/Oj-washingtone/savannah-store/internal/apperr"
+	"github.com/Oj-washingtone/savannah-store/internal/model"
+	"github.com/Oj-washingtone/savannah-store/internal/repocitory"
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5"
+)
+
+// RequireRole only lets through users with one of the given roles, looking
+// the caller up in users. It must run after AuthMiddleware, which puts the
+// token claims on the context.
+func RequireRole(users repocitory.UserRepository, roles ...model.Roles) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userClaims, exists := c.Get("user")
+		if !exists {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+			c.Abort()
+			return
+		}
+
+		claims := userClaims.(map[string]interface{})
+
+		auth0ID, _ := claims["sub"].(string)
+
+		user, err := users.GetByAuth0Id(c.Request.Context(), auth0ID)
+		if errors.Is(err, pgx.ErrNoRows) {
+			c.Error(apperr.Forbidden("Your account does not have access to this"))
+			c.Abort()
+			return
+		}
+		if err != nil {
+			c.Error(err)
+			c.Abort()
+			return
+		}
+
+		for _, role := range roles {
+			if user.Role == role {
+				c.Next()
+				return
+			}
+		}
+
+		c.Error(apperr.Forbidden("Your account does not have access to this"))
+		c.Abort()
+	}
+}
@@ -11,3 +11,8 @@ type BaseModel struct {
 	CreatedAt time.Time `db:"created_at" json:"createdAt"`
 	UpdatedAt time.Time `db:"updated_at" json:"updatedAt"`
 }
+
+// PageKey is what lists are paged by, see the pagination package.
+func (b BaseModel) PageKey() (time.Time, uuid.UUID) {
+	return b.CreatedAt, b.ID
+}
@@ -1,6 +1,8 @@
 package model
 
 import (
+	"time"
+
 	"github.com/google/uuid"
 )
 
@@ -13,6 +15,30 @@ type CartItem struct {
 	BaseModel
 	CartId    uuid.UUID `db:"cart_id" json:"cartId"`
 	ProductId uuid.UUID `db:"product_id" json:"productId"`
+	VariantId uuid.UUID `db:"variant_id" json:"variantId"`
 	Quantity  int       `db:"quantity" json:"quantity"`
 	Price     int64     `db:"price" json:"price"`
 }
+
+// CartReminder records an abandoned cart reminder so the same idle cart
+// is not reminded about twice.
+type CartReminder struct {
+	BaseModel
+	CartId   uuid.UUID `db:"cart_id" json:"cartId"`
+	UserId   uuid.UUID `db:"user_id" json:"userId"`
+	Channels string    `db:"channels" json:"channels"`
+	SentAt   time.Time `db:"sent_at" json:"sentAt"`
+}
+
+// AbandonedCart is a cart with items that have not been touched for a while,
+// together with the contact details of its owner.
+type AbandonedCart struct {
+	CartId       uuid.UUID
+	UserId       uuid.UUID
+	Name         string
+	Email        string
+	Phone        string
+	ItemCount    int
+	Subtotal     int64
+	LastActivity time.Time
+}
@@ -0,0 +1,26 @@
+package model
+
+import "github.com/google/uuid"
+
+// Breadcrumb is one step on the path from a top level category down to a
+// category.
+type Breadcrumb struct {
+	ID   uuid.UUID `json:"id"`
+	Name string    `json:"name"`
+}
+
+// CategoryNode is a category in the category tree.
+type CategoryNode struct {
+	ProductCategory
+
+	// Breadcrumbs run from the top level category down to this one,
+	// including it.
+	Breadcrumbs []*Breadcrumb `json:"breadcrumbs"`
+
+	// ProductCount counts the products directly in the category,
+	// TotalProductCount adds those of its subcategories.
+	ProductCount      int `json:"productCount"`
+	TotalProductCount int `json:"totalProductCount"`
+
+	Children []*CategoryNode `json:"children"`
+}
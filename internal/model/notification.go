@@ -0,0 +1,30 @@
+package model
+
+import "time"
+
+type NotificationChannel string
+
+const (
+	NotificationEmail NotificationChannel = "email"
+	NotificationSMS   NotificationChannel = "sms"
+)
+
+// OutboxNotification is an email or SMS that failed to send, kept so it can
+// be sent again later. Subject and Attachments are only used for email.
+type OutboxNotification struct {
+	BaseModel
+	Channel     NotificationChannel      `json:"channel"`
+	Recipient   string                   `json:"recipient"`
+	Subject     string                   `json:"subject"`
+	Body        string                   `json:"body"`
+	Attachments []NotificationAttachment `json:"attachments"`
+	Attempts    int                      `json:"attempts"`
+	LastError   string                   `json:"lastError"`
+	SentAt      *time.Time               `json:"sentAt,omitempty"`
+}
+
+type NotificationAttachment struct {
+	Filename    string `json:"filename"`
+	ContentType string `json:"contentType"`
+	Content     []byte `json:"content"`
+}
@@ -1,6 +1,11 @@
 package model
 
-import "github.com/google/uuid"
+import (
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+)
 
 type OrderStatus string
 
@@ -13,16 +18,47 @@ const (
 
 type Orders struct {
 	BaseModel
-	UserID uuid.UUID   `json:"userId"`
-	Status OrderStatus `json:"status"`
-	Total  int64       `json:"total"` // capture in cents why ?
-	Paid   bool        `json:"paid"`
+	UserID        uuid.UUID   `json:"userId"`
+	Status        OrderStatus `json:"status"`
+	Subtotal      int64       `json:"subtotal"`
+	DiscountTotal int64       `json:"discountTotal"`
+	TaxTotal      int64       `json:"taxTotal"`
+	Total         int64       `json:"total"` // capture in cents why ?
+	Paid          bool        `json:"paid"`
+
+	PricesIncludeTax bool `json:"pricesIncludeTax"`
+
+	// set once the order is paid
+	PaidAt           *time.Time `json:"paidAt,omitempty"`
+	PaymentReference *string    `json:"paymentReference,omitempty"`
+	InvoiceNumber    *int64     `json:"invoiceNumber,omitempty"`
+}
+
+// InvoiceNo is the invoice number as printed on invoices, or an empty string
+// if the order has not been paid yet.
+func (o *Orders) InvoiceNo() string {
+	if o.InvoiceNumber == nil {
+		return ""
+	}
+
+	return fmt.Sprintf("INV-%06d", *o.InvoiceNumber)
 }
 
 type OrderItems struct {
 	BaseModel
 	OrderID   uuid.UUID `json:"orderId"`
 	ProductID uuid.UUID `json:"productId"`
+	VariantID uuid.UUID `json:"variantId"`
 	Quantity  int       `json:"quantity"`
 	Price     int64     `json:"price"`
+	Discount  int64     `json:"discount"`
+	TaxClass  TaxClass  `json:"taxClass"`
+	TaxRate   int       `json:"taxRate"` // basis points, 1600 is 16%
+	Tax       int64     `json:"tax"`
+
+	// ProductName and VariantName are what the item was called when it was
+	// ordered, VariantName being its option values such as "Red / XL" and
+	// empty for a simple product
+	ProductName string `json:"productName"`
+	VariantName string `json:"variantName,omitempty"`
 }
@@ -1,30 +1,168 @@
 package model
 
 import (
+	"math"
+	"time"
+
 	"github.com/google/uuid"
 )
 
+type TaxClass string
+
+const (
+	TaxStandard  TaxClass = "standard"
+	TaxZeroRated TaxClass = "zero_rated"
+	TaxExempt    TaxClass = "exempt"
+)
+
 type Product struct {
 	BaseModel
 	CategoryID  uuid.UUID `json:"categoryId"`
 	Name        string    `json:"name"`
+	Slug        string    `json:"slug"`
+	SKU         *string   `json:"sku,omitempty"`
 	Description string    `json:"description"`
 	Price       int64     `json:"price"`
 	Stock       int       `json:"stock"`
+	TaxClass    TaxClass  `json:"taxClass"`
+	SEO
+
+	// Sale pricing, filled in from the discount that is active right now.
+	OriginalPrice   int64     `json:"originalPrice"`
+	DiscountedPrice int64     `json:"discountedPrice"`
+	PercentOff      float64   `json:"percentOff"`
+	Discount        *Discount `json:"discount,omitempty"`
+
+	// Images in display order, only loaded for product responses.
+	Images []*ProductImage `json:"images"`
+
+	// The option matrix, only loaded for the product detail response.
+	Options  []*ProductOption  `json:"options,omitempty"`
+	Variants []*ProductVariant `json:"variants,omitempty"`
 }
 
+// ApplyDiscount sets the sale pricing fields from the active discount, or
+// from the list price when d is nil.
+func (p *Product) ApplyDiscount(d *Discount) {
+	p.OriginalPrice = p.Price
+	p.DiscountedPrice = discountedPrice(p.Price, d)
+	p.PercentOff = 0
+	p.Discount = d
+
+	if p.Price > 0 {
+		p.PercentOff = math.Round(float64(p.Price-p.DiscountedPrice)*10000/float64(p.Price)) / 100
+	}
+}
+
+// discountedPrice is price with the discount d taken off, never below zero.
+func discountedPrice(price int64, d *Discount) int64 {
+	if d == nil || price <= 0 {
+		return price
+	}
+
+	switch d.Type {
+	case DiscountPercentage:
+		return price - price*d.Value/100
+	case DiscountFixed:
+		return max(price-d.Value, 0)
+	}
+
+	return price
+}
+
+// SEO is the metadata search engines show for a product or category page.
+type SEO struct {
+	MetaTitle       string `json:"metaTitle" binding:"max=100"`
+	MetaDescription string `json:"metaDescription" binding:"max=300"`
+}
+
+const (
+	MaxMetaTitleLength       = 100
+	MaxMetaDescriptionLength = 300
+)
+
 type ProductCategory struct {
 	BaseModel
 	Name     string     `json:"name"`
+	Slug     string     `json:"slug"`
 	ParentId *uuid.UUID `db:"parent_id" json:"parentId,omitempty"`
+	SEO
 }
 
+// CategoryDeletePolicy says what happens to the subcategories and products
+// of a category being deleted.
+type CategoryDeletePolicy string
+
+const (
+	// CategoryDeleteBlock refuses to delete a category that is not empty.
+	CategoryDeleteBlock CategoryDeletePolicy = "block"
+	// CategoryDeleteReassign moves the subcategories and products to
+	// another category first.
+	CategoryDeleteReassign CategoryDeletePolicy = "reassign"
+	// CategoryDeleteCascade deletes the subcategories and their products too.
+	CategoryDeleteCascade CategoryDeletePolicy = "cascade"
+)
+
 type ProductImage struct {
 	BaseModel
-	ProductID uuid.UUID `json:"productId"`
-	URL       string    `json:"url"`
-	AltText   string    `json:"altText"`
-	IsPrimary bool      `json:"isPrimary"`
+	ProductID   uuid.UUID `json:"productId"`
+	URL         string    `json:"url"`
+	AltText     string    `json:"altText"`
+	IsPrimary   bool      `json:"isPrimary"`
+	Position    int       `json:"position"`
+	ContentType string    `json:"contentType"`
+	Size        int64     `json:"size"`
+	Width       int       `json:"width"`
+	Height      int       `json:"height"`
+	StorageKey  string    `json:"-"`
+
+	// VariantID ties the image to one variant, e.g. the red version of a shirt.
+	VariantID *uuid.UUID `json:"variantId,omitempty"`
+
+	// Resized copies by size name: thumbnail, medium and large.
+	Variants map[string]*ImageVariant `json:"variants"`
+}
+
+// ImageVariant is one size of a product image. URL is a JPEG, or a PNG for
+// images with transparency, and WebPURL the same picture as WebP.
+type ImageVariant struct {
+	Width   int    `json:"width"`
+	Height  int    `json:"height"`
+	URL     string `json:"url"`
+	WebPURL string `json:"webpUrl,omitempty"`
+}
+
+// AddVariant adds a stored variant file to the image's Variants, pairing
+// each size with its WebP copy.
+func (img *ProductImage) AddVariant(v *ProductImageVariant) {
+	if img.Variants == nil {
+		img.Variants = map[string]*ImageVariant{}
+	}
+
+	variant, ok := img.Variants[v.Name]
+	if !ok {
+		variant = &ImageVariant{Width: v.Width, Height: v.Height}
+		img.Variants[v.Name] = variant
+	}
+
+	if v.ContentType == "image/webp" {
+		variant.WebPURL = v.URL
+	} else {
+		variant.URL = v.URL
+	}
+}
+
+// ProductImageVariant is a stored file of an image variant.
+type ProductImageVariant struct {
+	BaseModel
+	ImageID     uuid.UUID `json:"imageId"`
+	Name        string    `json:"name"`
+	ContentType string    `json:"contentType"`
+	Width       int       `json:"width"`
+	Height      int       `json:"height"`
+	Size        int64     `json:"size"`
+	StorageKey  string    `json:"-"`
+	URL         string    `json:"url"`
 }
 
 type DiscountType string
@@ -34,9 +172,21 @@ const (
 	DiscountFixed      DiscountType = "fixed"
 )
 
+// Discount is a sale price on a product. Value is a whole percentage for
+// DiscountPercentage and an amount in shillings for DiscountFixed. When
+// several discounts are running at once, the one with the highest Priority
+// wins, then the one that started most recently.
 type Discount struct {
 	BaseModel
 	ProductID uuid.UUID    `json:"productId"`
 	Type      DiscountType `json:"type"`
-	Value     float64      `json:"value"`
+	Value     int64        `json:"value"`
+	StartsAt  *time.Time   `json:"startsAt,omitempty"`
+	EndsAt    *time.Time   `json:"endsAt,omitempty"`
+	Priority  int          `json:"priority"`
+}
+
+// RunningAt reports whether the discount is in its window at the given time.
+func (d *Discount) RunningAt(at time.Time) bool {
+	return (d.StartsAt == nil || !d.StartsAt.After(at)) && (d.EndsAt == nil || d.EndsAt.After(at))
 }
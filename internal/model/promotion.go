@@ -0,0 +1,81 @@
+package model
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type PromotionScope string
+
+const (
+	PromotionScopeCart     PromotionScope = "cart"
+	PromotionScopeProduct  PromotionScope = "product"
+	PromotionScopeCategory PromotionScope = "category"
+)
+
+// Promotion is a discount rule. Promotions with a code only apply once the
+// customer enters the code on their cart, the rest apply automatically.
+// Value is a whole percentage for DiscountPercentage and an amount in
+// shillings for DiscountFixed.
+type Promotion struct {
+	BaseModel
+	Code             *string        `json:"code,omitempty"`
+	Description      string         `json:"description"`
+	Scope            PromotionScope `json:"scope"`
+	ProductID        *uuid.UUID     `json:"productId,omitempty"`
+	CategoryID       *uuid.UUID     `json:"categoryId,omitempty"`
+	Type             DiscountType   `json:"type"`
+	Value            int64          `json:"value"`
+	MinSpend         int64          `json:"minSpend"`
+	StartsAt         *time.Time     `json:"startsAt,omitempty"`
+	EndsAt           *time.Time     `json:"endsAt,omitempty"`
+	UsageLimit       *int           `json:"usageLimit,omitempty"`
+	PerCustomerLimit *int           `json:"perCustomerLimit,omitempty"`
+	Stackable        bool           `json:"stackable"`
+	Active           bool           `json:"active"`
+}
+
+type PromotionRedemption struct {
+	BaseModel
+	PromotionID uuid.UUID `json:"promotionId"`
+	OrderID     uuid.UUID `json:"orderId"`
+	UserID      uuid.UUID `json:"userId"`
+	Code        *string   `json:"code,omitempty"`
+	Amount      int64     `json:"amount"`
+}
+
+// AppliedDiscount is a promotion that reduced the price of a cart.
+type AppliedDiscount struct {
+	PromotionID uuid.UUID `json:"promotionId"`
+	Code        *string   `json:"code,omitempty"`
+	Description string    `json:"description"`
+	Amount      int64     `json:"amount"`
+}
+
+// CartLine is a cart item with the discount it received and the VAT on it.
+type CartLine struct {
+	*CartItem
+	CategoryID uuid.UUID `json:"categoryId"`
+	LineTotal  int64     `json:"lineTotal"`
+	Discount   int64     `json:"discount"`
+	TaxClass   TaxClass  `json:"taxClass"`
+	TaxRate    int       `json:"taxRate"`
+	Tax        int64     `json:"tax"`
+	Total      int64     `json:"total"`
+}
+
+// CartSummary is the priced view of a cart that checkout charges for.
+type CartSummary struct {
+	CartId        uuid.UUID          `json:"cartId"`
+	Lines         []*CartLine        `json:"lines"`
+	Subtotal      int64              `json:"subtotal"`
+	Discounts     []*AppliedDiscount `json:"discounts"`
+	DiscountTotal int64              `json:"discountTotal"`
+	TaxTotal      int64              `json:"taxTotal"`
+	Total         int64              `json:"total"`
+	Codes         []string           `json:"codes"`
+	Notices       []string           `json:"notices,omitempty"`
+
+	PricesIncludeTax bool `json:"pricesIncludeTax"`
+}
@@ -0,0 +1,61 @@
+package model
+
+import (
+	"github.com/Oj-washingtone/savannah-store/internal/pagination"
+	"github.com/google/uuid"
+)
+
+type ProductSort string
+
+const (
+	SortRelevance ProductSort = "relevance"
+	SortPriceAsc  ProductSort = "price_asc"
+	SortPriceDesc ProductSort = "price_desc"
+	SortNewest    ProductSort = "newest"
+	SortName      ProductSort = "name"
+)
+
+// PriceBucketBounds split products into the price facet buckets, in Ksh.
+// Each bound starts a new bucket, the last bucket has no upper limit.
+var PriceBucketBounds = []int64{1000, 5000, 10000, 50000}
+
+// ProductSearch is a product search with its filters. Prices are compared
+// with the price after any running sale discount.
+type ProductSearch struct {
+	Query      string
+	CategoryID *uuid.UUID // includes the category's subcategories
+	MinPrice   *int64
+	MaxPrice   *int64
+	InStock    bool
+	OnSale     bool
+	Sort       ProductSort
+	Page       pagination.Page
+}
+
+type CategoryFacet struct {
+	CategoryID uuid.UUID `json:"categoryId"`
+	Name       string    `json:"name"`
+	Count      int       `json:"count"`
+}
+
+// PriceBucketFacet counts products priced from Min up to, but not including,
+// Max. Max is nil for the top bucket.
+type PriceBucketFacet struct {
+	Min   int64  `json:"min"`
+	Max   *int64 `json:"max,omitempty"`
+	Count int    `json:"count"`
+}
+
+// SearchFacets count the matching products per category and price bucket.
+// Each facet leaves out its own filter, so the counts show what picking
+// another category or price range would give.
+type SearchFacets struct {
+	Categories   []*CategoryFacet    `json:"categories"`
+	PriceBuckets []*PriceBucketFacet `json:"priceBuckets"`
+}
+
+type ProductSearchResult struct {
+	Products []*Product   `json:"products"`
+	Total    int          `json:"total"`
+	Facets   SearchFacets `json:"facets"`
+}
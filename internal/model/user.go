@@ -18,4 +18,7 @@ type User struct {
 	Phone     string     `db:"phone" json:"phone"`
 	Role      Roles      `db:"role" json:"role"`
 	DeletedAt *time.Time `db:"deleted_at" json:"deletedAt,omitempty"`
+
+	CartRemindersOptOut  bool `db:"cart_reminders_opt_out" json:"cartRemindersOptOut"`
+	WishlistAlertsOptOut bool `db:"wishlist_alerts_opt_out" json:"wishlistAlertsOptOut"`
 }
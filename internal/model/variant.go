@@ -0,0 +1,67 @@
+package model
+
+import (
+	"strings"
+
+	"github.com/google/uuid"
+)
+
+// ProductOption is a way a product comes in, such as "Size" or "Colour".
+type ProductOption struct {
+	BaseModel
+	ProductID uuid.UUID             `json:"productId"`
+	Name      string                `json:"name"`
+	Position  int                   `json:"position"`
+	Values    []*ProductOptionValue `json:"values"`
+}
+
+type ProductOptionValue struct {
+	ID       uuid.UUID `json:"id"`
+	OptionID uuid.UUID `json:"optionId"`
+	Value    string    `json:"value"`
+	Position int       `json:"position"`
+}
+
+// ProductVariant is one purchasable version of a product, with a value for
+// every option of the product. Simple products have a single variant with no
+// options. Price overrides the product price when set.
+type ProductVariant struct {
+	BaseModel
+	ProductID uuid.UUID `json:"productId"`
+	SKU       *string   `json:"sku,omitempty"`
+	Price     *int64    `json:"priceOverride,omitempty"`
+	Stock     int       `json:"stock"`
+	Position  int       `json:"position"`
+
+	// Options maps option names to the variant's values, e.g. {"Size": "M"}.
+	Options map[string]string `json:"options"`
+
+	// Pricing worked out from the product price and its active discount.
+	UnitPrice       int64 `json:"price"`
+	DiscountedPrice int64 `json:"discountedPrice"`
+
+	Images []*ProductImage `json:"images"`
+}
+
+// ApplyPricing sets the variant's unit and sale price from its product.
+func (v *ProductVariant) ApplyPricing(product *Product) {
+	v.UnitPrice = product.Price
+	if v.Price != nil {
+		v.UnitPrice = *v.Price
+	}
+
+	v.DiscountedPrice = discountedPrice(v.UnitPrice, product.Discount)
+}
+
+// Label describes the variant by its option values in option order, e.g.
+// "M / Red". Simple products have an empty label.
+func (v *ProductVariant) Label(options []*ProductOption) string {
+	var values []string
+	for _, option := range options {
+		if value, ok := v.Options[option.Name]; ok {
+			values = append(values, value)
+		}
+	}
+
+	return strings.Join(values, " / ")
+}
@@ -0,0 +1,27 @@
+package model
+
+import "github.com/google/uuid"
+
+type Wishlist struct {
+	BaseModel
+	UserId     uuid.UUID       `db:"user_id" json:"userId"`
+	Name       string          `db:"name" json:"name"`
+	IsDefault  bool            `db:"is_default" json:"isDefault"`
+	ShareToken *string         `db:"share_token" json:"shareToken,omitempty"`
+	Items      []*WishlistItem `json:"items,omitempty"`
+}
+
+type WishlistItem struct {
+	BaseModel
+	WishlistId uuid.UUID `db:"wishlist_id" json:"wishlistId"`
+	ProductId  uuid.UUID `db:"product_id" json:"productId"`
+	PriceAtAdd int64     `db:"price_at_add" json:"priceAtAdd"`
+}
+
+// WishlistWatcher is a user with a given product on one of their wishlists.
+type WishlistWatcher struct {
+	UserId uuid.UUID
+	Name   string
+	Email  string
+	Phone  string
+}
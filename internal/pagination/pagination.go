@@ -0,0 +1,241 @@
+// Package pagination pages through lists newest first using keyset cursors
+// over (created_at, id), which stay fast on large tables and never skip or
+// repeat rows when rows are added between requests. Lists in another order,
+// such as search results by price, put their sort key ahead of the two, see
+// Page.KeysetBy.
+package pagination
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	DefaultLimit = 10
+	MaxLimit     = 100
+)
+
+// ErrInvalidCursor is returned for cursors that were not made by Encode.
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+type Direction string
+
+const (
+	// Next pages towards older rows.
+	Next Direction = "next"
+	// Prev pages back towards newer rows.
+	Prev Direction = "prev"
+)
+
+// Cursor points at the row a page starts after. Clients treat it as an
+// opaque string.
+type Cursor struct {
+	CreatedAt time.Time `json:"t"`
+	ID        uuid.UUID `json:"i"`
+	Direction Direction `json:"d"`
+
+	// Sort is the Page.Sort the cursor was made for and Key the row's value
+	// of the sort key, as text, for lists paged with KeysetBy
+	Sort string `json:"s,omitempty"`
+	Key  string `json:"k,omitempty"`
+}
+
+func (c Cursor) Encode() string {
+	data, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func Decode(s string) (*Cursor, error) {
+	data, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+
+	var c Cursor
+	if err := json.Unmarshal(data, &c); err != nil {
+		return nil, ErrInvalidCursor
+	}
+
+	if c.Direction != Next && c.Direction != Prev {
+		return nil, ErrInvalidCursor
+	}
+
+	return &c, nil
+}
+
+// Page is the page of a list being asked for. A nil Cursor is the first page.
+type Page struct {
+	Limit     int
+	Cursor    *Cursor
+	WithTotal bool
+
+	// Sort names the order of a list that can be sorted several ways. A
+	// cursor only fits the order it was made for, see Fits.
+	Sort string
+}
+
+// Fits reports whether the page's cursor, if it has one, was made for the
+// page's order.
+func (p Page) Fits() bool {
+	return p.Cursor == nil || p.Cursor.Sort == p.Sort
+}
+
+// Keyset gives the condition selecting the rows of the page, the ORDER BY
+// clause and the LIMIT, for a table aliased as alias. The condition's
+// arguments are numbered from first and returned in args.
+//
+// One row more than the limit is fetched, to tell whether there is another
+// page. Pass the rows to Trim.
+func (p Page) Keyset(alias string, first int) (where, order, limit string, args []any) {
+	createdAt, id := alias+".created_at", alias+".id"
+	limit = strconv.Itoa(p.Limit + 1)
+
+	if p.Cursor == nil {
+		return "TRUE", createdAt + " DESC, " + id + " DESC", limit, nil
+	}
+
+	placeholders := "($" + strconv.Itoa(first) + ", $" + strconv.Itoa(first+1) + ")"
+	args = []any{p.Cursor.CreatedAt, p.Cursor.ID}
+
+	if p.Cursor.Direction == Prev {
+		return "(" + createdAt + ", " + id + ") > " + placeholders, createdAt + " ASC, " + id + " ASC", limit, args
+	}
+
+	return "(" + createdAt + ", " + id + ") < " + placeholders, createdAt + " DESC, " + id + " DESC", limit, args
+}
+
+// KeysetBy is Keyset for lists ordered by key, an SQL expression of sqlType,
+// ascending or descending, and then newest first. The condition's arguments
+// start with the cursor's Key, which is cast to sqlType. Select the key as
+// text and pass the rows to Trim as Sorted rows, so the cursors carry it.
+func (p Page) KeysetBy(alias, key, sqlType string, desc bool, first int) (where, order, limit string, args []any) {
+	createdAt, id := alias+".created_at", alias+".id"
+	limit = strconv.Itoa(p.Limit + 1)
+
+	// going back walks the order the other way round
+	backwards := p.Cursor != nil && p.Cursor.Direction == Prev
+	keyDesc, newestFirst := desc != backwards, !backwards
+
+	direction := func(desc bool) (string, string) {
+		if desc {
+			return " DESC", " < "
+		}
+		return " ASC", " > "
+	}
+
+	keyOrder, keyAfter := direction(keyDesc)
+	tieOrder, tieAfter := direction(newestFirst)
+
+	order = key + keyOrder + ", " + createdAt + tieOrder + ", " + id + tieOrder
+
+	if p.Cursor == nil {
+		return "TRUE", order, limit, nil
+	}
+
+	value := "CAST($" + strconv.Itoa(first) + "::text AS " + sqlType + ")"
+	placeholders := "($" + strconv.Itoa(first+1) + ", $" + strconv.Itoa(first+2) + ")"
+	args = []any{p.Cursor.Key, p.Cursor.CreatedAt, p.Cursor.ID}
+
+	where = "(" + key + keyAfter + value +
+		" OR (" + key + " = " + value + " AND (" + createdAt + ", " + id + ")" + tieAfter + placeholders + "))"
+
+	return where, order, limit, args
+}
+
+// Info describes a page for the response: the cursors of the pages on
+// either side, if there are any, and the total when it was asked for.
+type Info struct {
+	Limit      int     `json:"limit"`
+	NextCursor *string `json:"nextCursor,omitempty"`
+	PrevCursor *string `json:"prevCursor,omitempty"`
+	Total      *int    `json:"total,omitempty"`
+}
+
+// Keyed is a row that can be paged through, giving its created_at and id.
+type Keyed interface {
+	PageKey() (time.Time, uuid.UUID)
+}
+
+// SortKeyed is a row of a list paged with KeysetBy, giving its value of the
+// sort key as well.
+type SortKeyed interface {
+	Keyed
+	SortKey() string
+}
+
+// Sorted pairs a row with its value of the sort key, as text.
+type Sorted[T Keyed] struct {
+	Row T
+	Key string
+}
+
+func (s Sorted[T]) PageKey() (time.Time, uuid.UUID) {
+	return s.Row.PageKey()
+}
+
+func (s Sorted[T]) SortKey() string {
+	return s.Key
+}
+
+// Rows takes the rows back out of sorted ones.
+func Rows[T Keyed](sorted []Sorted[T]) []T {
+	rows := make([]T, len(sorted))
+	for i, s := range sorted {
+		rows[i] = s.Row
+	}
+	return rows
+}
+
+// Trim cuts rows fetched with Keyset or KeysetBy down to the page, in the
+// list's order, and works out the cursors.
+func Trim[T Keyed](p Page, rows []T) ([]T, *Info) {
+	info := &Info{Limit: p.Limit}
+
+	if rows == nil {
+		rows = []T{}
+	}
+
+	more := len(rows) > p.Limit
+	if more {
+		rows = rows[:p.Limit]
+	}
+
+	backwards := p.Cursor != nil && p.Cursor.Direction == Prev
+	if backwards {
+		slices.Reverse(rows)
+	}
+
+	if len(rows) == 0 {
+		return rows, info
+	}
+
+	cursor := func(row T, direction Direction) *string {
+		createdAt, id := row.PageKey()
+		c := Cursor{CreatedAt: createdAt, ID: id, Direction: direction, Sort: p.Sort}
+
+		if sorted, ok := any(row).(SortKeyed); ok {
+			c.Key = sorted.SortKey()
+		}
+
+		s := c.Encode()
+		return &s
+	}
+
+	// coming back from a later page means there is one after this, and
+	// going forward from a cursor means there is one before
+	if more || backwards {
+		info.NextCursor = cursor(rows[len(rows)-1], Next)
+	}
+
+	if (more && backwards) || (!backwards && p.Cursor != nil) {
+		info.PrevCursor = cursor(rows[0], Prev)
+	}
+
+	return rows, info
+}
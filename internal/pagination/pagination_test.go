@@ -0,0 +1,278 @@
+package pagination
+
+import (
+	"bytes"
+	"encoding/base64"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type row struct {
+	createdAt time.Time
+	id        uuid.UUID
+}
+
+func (r row) PageKey() (time.Time, uuid.UUID) {
+	return r.createdAt, r.id
+}
+
+// compare orders rows the way Postgres orders (created_at, id).
+func compare(a, b row) int {
+	if c := a.createdAt.Compare(b.createdAt); c != 0 {
+		return c
+	}
+	return bytes.Compare(a.id[:], b.id[:])
+}
+
+// fetch runs Keyset's query over rows: the rows after the cursor, in the
+// page's order, one more than the limit.
+func fetch(p Page, rows []row) []row {
+	cursor := row{}
+	if p.Cursor != nil {
+		cursor = row{p.Cursor.CreatedAt, p.Cursor.ID}
+	}
+
+	var found []row
+	for _, r := range rows {
+		switch {
+		case p.Cursor == nil,
+			p.Cursor.Direction == Next && compare(r, cursor) < 0,
+			p.Cursor.Direction == Prev && compare(r, cursor) > 0:
+			found = append(found, r)
+		}
+	}
+
+	slices.SortFunc(found, func(a, b row) int { return -compare(a, b) })
+	if p.Cursor != nil && p.Cursor.Direction == Prev {
+		slices.Reverse(found)
+	}
+
+	if len(found) > p.Limit+1 {
+		found = found[:p.Limit+1]
+	}
+
+	return found
+}
+
+// table has seven rows, three of them made in the same instant.
+func table() []row {
+	start := time.Date(2026, 3, 1, 9, 0, 0, 0, time.UTC)
+	offsets := []time.Duration{0, time.Minute, time.Minute, time.Minute, 2 * time.Minute, 3 * time.Minute, 4 * time.Minute}
+
+	rows := make([]row, len(offsets))
+	for i, offset := range offsets {
+		rows[i] = row{start.Add(offset), uuid.New()}
+	}
+
+	return rows
+}
+
+func TestPagingThrough(t *testing.T) {
+	rows := table()
+
+	want := slices.Clone(rows)
+	slices.SortFunc(want, func(a, b row) int { return -compare(a, b) })
+
+	var pages [][]row
+	page := Page{Limit: 2}
+
+	for {
+		got, info := Trim(page, fetch(page, rows))
+		pages = append(pages, got)
+
+		if (len(pages) == 1) != (info.PrevCursor == nil) {
+			t.Fatalf("page %d has prev cursor %v", len(pages), info.PrevCursor)
+		}
+
+		if info.NextCursor == nil {
+			break
+		}
+
+		cursor, err := Decode(*info.NextCursor)
+		if err != nil {
+			t.Fatal(err)
+		}
+		page.Cursor = cursor
+	}
+
+	if got := slices.Concat(pages...); !slices.Equal(got, want) {
+		t.Fatalf("paging forward gave %v, want %v", got, want)
+	}
+
+	if len(pages) != 4 || len(pages[3]) != 1 {
+		t.Fatalf("want pages of 2, 2, 2 and 1, got %d pages", len(pages))
+	}
+
+	// and back again from the last page
+	last := pages[len(pages)-1]
+	cursor := Cursor{CreatedAt: last[0].createdAt, ID: last[0].id, Direction: Prev}
+	page.Cursor = &cursor
+
+	for i := len(pages) - 2; i >= 0; i-- {
+		got, info := Trim(page, fetch(page, rows))
+
+		if !slices.Equal(got, pages[i]) {
+			t.Fatalf("going back to page %d gave %v, want %v", i+1, got, pages[i])
+		}
+
+		if info.NextCursor == nil {
+			t.Errorf("page %d has no next cursor going back", i+1)
+		}
+
+		if i == 0 {
+			if info.PrevCursor != nil {
+				t.Errorf("the first page has a prev cursor going back")
+			}
+			break
+		}
+
+		page.Cursor, _ = Decode(*info.PrevCursor)
+	}
+}
+
+func TestTrim(t *testing.T) {
+	rows := table()[:3]
+
+	got, info := Trim(Page{Limit: 5}, rows)
+	if len(got) != 3 || info.NextCursor != nil || info.PrevCursor != nil || info.Limit != 5 {
+		t.Errorf("a short first page got %d rows and %+v", len(got), info)
+	}
+
+	got, info = Trim(Page{Limit: 2}, rows)
+	if len(got) != 2 || info.NextCursor == nil || info.PrevCursor != nil {
+		t.Errorf("a full first page got %d rows and %+v", len(got), info)
+	}
+
+	got, info = Trim[row](Page{Limit: 2, Cursor: &Cursor{Direction: Next}}, nil)
+	if got == nil || len(got) != 0 || info.NextCursor != nil || info.PrevCursor != nil {
+		t.Errorf("an empty page got %v and %+v", got, info)
+	}
+}
+
+func TestCursorCarriesSortKey(t *testing.T) {
+	rows := []Sorted[row]{{Row: table()[0], Key: "1500"}, {Row: table()[1], Key: "2000"}}
+
+	_, info := Trim(Page{Limit: 1, Sort: "price"}, rows)
+
+	cursor, err := Decode(*info.NextCursor)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if cursor.Sort != "price" || cursor.Key != "1500" || cursor.Direction != Next {
+		t.Errorf("got cursor %+v", cursor)
+	}
+
+	if !(Page{Sort: "price", Cursor: cursor}).Fits() || (Page{Sort: "newest", Cursor: cursor}).Fits() {
+		t.Error("the cursor fits the wrong orders")
+	}
+}
+
+func TestDecode(t *testing.T) {
+	cursor := Cursor{
+		CreatedAt: time.Date(2026, 3, 1, 9, 0, 0, 123456000, time.UTC),
+		ID:        uuid.New(),
+		Direction: Prev,
+		Sort:      "price",
+		Key:       "1500",
+	}
+
+	got, err := Decode(cursor.Encode())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if *got != cursor {
+		t.Errorf("round trip gave %+v, want %+v", got, cursor)
+	}
+
+	encoded := cursor.Encode()
+
+	for name, raw := range map[string]string{
+		"not base64":        "not a cursor!",
+		"not JSON":          base64.RawURLEncoding.EncodeToString([]byte("created=yesterday")),
+		"no direction":      base64.RawURLEncoding.EncodeToString([]byte(`{"t":"2026-03-01T09:00:00Z"}`)),
+		"unknown direction": base64.RawURLEncoding.EncodeToString([]byte(`{"d":"sideways"}`)),
+		"a bad id":          base64.RawURLEncoding.EncodeToString([]byte(`{"i":"42","d":"next"}`)),
+		"cut short":         encoded[:len(encoded)/2],
+	} {
+		if _, err := Decode(raw); err != ErrInvalidCursor {
+			t.Errorf("%s: got %v, want ErrInvalidCursor", name, err)
+		}
+	}
+}
+
+func TestKeyset(t *testing.T) {
+	at := time.Date(2026, 3, 1, 9, 0, 0, 0, time.UTC)
+	id := uuid.New()
+
+	for _, tc := range []struct {
+		name                string
+		cursor              *Cursor
+		where, order, limit string
+		args                []any
+	}{
+		{"first page", nil, "TRUE", "p.created_at DESC, p.id DESC", "21", nil},
+		{
+			"next", &Cursor{CreatedAt: at, ID: id, Direction: Next},
+			"(p.created_at, p.id) < ($3, $4)", "p.created_at DESC, p.id DESC", "21", []any{at, id},
+		},
+		{
+			"prev", &Cursor{CreatedAt: at, ID: id, Direction: Prev},
+			"(p.created_at, p.id) > ($3, $4)", "p.created_at ASC, p.id ASC", "21", []any{at, id},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			where, order, limit, args := Page{Limit: 20, Cursor: tc.cursor}.Keyset("p", 3)
+
+			if where != tc.where || order != tc.order || limit != tc.limit || !slices.Equal(args, tc.args) {
+				t.Errorf("got %q, %q, %q, %v", where, order, limit, args)
+			}
+		})
+	}
+}
+
+func TestKeysetBy(t *testing.T) {
+	at := time.Date(2026, 3, 1, 9, 0, 0, 0, time.UTC)
+	id := uuid.New()
+
+	for _, tc := range []struct {
+		name         string
+		desc         bool
+		direction    Direction
+		order        string
+		keyAfter     string
+		createdAfter string
+	}{
+		{"ascending", false, Next, "p.price ASC, p.created_at DESC, p.id DESC", " > ", " < "},
+		{"ascending going back", false, Prev, "p.price DESC, p.created_at ASC, p.id ASC", " < ", " > "},
+		{"descending", true, Next, "p.price DESC, p.created_at DESC, p.id DESC", " < ", " < "},
+		{"descending going back", true, Prev, "p.price ASC, p.created_at ASC, p.id ASC", " > ", " > "},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			cursor := &Cursor{CreatedAt: at, ID: id, Direction: tc.direction, Key: "1500"}
+			where, order, limit, args := Page{Limit: 5, Cursor: cursor}.KeysetBy("p", "p.price", "bigint", tc.desc, 2)
+
+			value := "CAST($2::text AS bigint)"
+			want := "(p.price" + tc.keyAfter + value + " OR (p.price = " + value +
+				" AND (p.created_at, p.id)" + tc.createdAfter + "($3, $4)))"
+
+			if where != want {
+				t.Errorf("where is %q, want %q", where, want)
+			}
+
+			if order != tc.order || limit != "6" || !slices.Equal(args, []any{"1500", at, id}) {
+				t.Errorf("got order %q, limit %q and args %v", order, limit, args)
+			}
+		})
+	}
+
+	where, order, _, args := Page{Limit: 5}.KeysetBy("p", "p.price", "bigint", true, 1)
+	if where != "TRUE" || !strings.HasPrefix(order, "p.price DESC") || args != nil {
+		t.Errorf("the first page got %q, %q and %v", where, order, args)
+	}
+}
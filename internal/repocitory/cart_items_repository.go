@@ -13,7 +13,7 @@ type CartItemsRepository interface {
 	RemoveItem(ctx context.Context, itemId uuid.UUID) error
 	GetItems(ctx context.Context, cartId uuid.UUID) ([]*model.CartItem, error)
 	UpdateQuantity(ctx context.Context, itemId uuid.UUID, quantity int) error
-	Exists(ctx context.Context, cartId uuid.UUID, productId string) (bool, error)
+	Exists(ctx context.Context, cartId, variantId uuid.UUID) (bool, error)
 	ClearCart(ctx context.Context, cartId uuid.UUID) error
 }
 
@@ -21,43 +21,44 @@ type cartItemsRepository struct {
 	db *database.DB
 }
 
-func NewCartItemsRepository() CartItemsRepository {
-	return &cartItemsRepository{db: database.GetDB()}
+func NewCartItemsRepository(db *database.DB) CartItemsRepository {
+	return &cartItemsRepository{db: db}
 }
 
 func (r *cartItemsRepository) AddItem(ctx context.Context, item *model.CartItem) error {
 
-	query := `INSERT INTO cart_items (id, cart_id, product_id, quantity, price) VALUES ($1, $2, $3, $4, $5) RETURNING created_at, updated_at`
+	query := `INSERT INTO cart_items (id, cart_id, product_id, variant_id, quantity, price) VALUES ($1, $2, $3, $4, $5, $6) RETURNING created_at, updated_at`
 
-	return r.db.Pool.QueryRow(ctx, query,
+	return dbErr(r.db.Pool.QueryRow(ctx, query,
 		item.ID,
 		item.CartId,
 		item.ProductId,
+		item.VariantId,
 		item.Quantity,
 		item.Price,
-	).Scan(&item.CreatedAt, &item.UpdatedAt)
+	).Scan(&item.CreatedAt, &item.UpdatedAt), "cart item")
 
 }
 
 func (r *cartItemsRepository) RemoveItem(ctx context.Context, itemId uuid.UUID) error {
 	query := `DELETE FROM cart_items WHERE id = $1`
 	_, err := r.db.Pool.Exec(ctx, query, itemId)
-	return err
+	return dbErr(err, "cart item")
 }
 
 func (r *cartItemsRepository) GetItems(ctx context.Context, cartId uuid.UUID) ([]*model.CartItem, error) {
-	query := `SELECT id, cart_id, product_id, quantity, price, created_at, updated_at FROM cart_items WHERE cart_id = $1`
+	query := `SELECT id, cart_id, product_id, variant_id, quantity, price, created_at, updated_at FROM cart_items WHERE cart_id = $1`
 	rows, err := r.db.Pool.Query(ctx, query, cartId)
 	if err != nil {
-		return nil, err
+		return nil, dbErr(err, "cart item")
 	}
 	defer rows.Close()
 
 	var items []*model.CartItem
 	for rows.Next() {
 		item := &model.CartItem{}
-		if err := rows.Scan(&item.ID, &item.CartId, &item.ProductId, &item.Quantity, &item.Price, &item.CreatedAt, &item.UpdatedAt); err != nil {
-			return nil, err
+		if err := rows.Scan(&item.ID, &item.CartId, &item.ProductId, &item.VariantId, &item.Quantity, &item.Price, &item.CreatedAt, &item.UpdatedAt); err != nil {
+			return nil, dbErr(err, "cart item")
 		}
 		items = append(items, item)
 	}
@@ -65,22 +66,22 @@ func (r *cartItemsRepository) GetItems(ctx context.Context, cartId uuid.UUID) ([
 }
 
 func (r *cartItemsRepository) UpdateQuantity(ctx context.Context, itemId uuid.UUID, quantity int) error {
-	query := `UPDATE cart_items SET quantity = $1 WHERE id = $2`
+	query := `UPDATE cart_items SET quantity = $1, updated_at = now() WHERE id = $2`
 	_, err := r.db.Pool.Exec(ctx, query, quantity, itemId)
-	return err
+	return dbErr(err, "cart item")
 }
 
-func (r *cartItemsRepository) Exists(ctx context.Context, cartId uuid.UUID, productId string) (bool, error) {
+func (r *cartItemsRepository) Exists(ctx context.Context, cartId, variantId uuid.UUID) (bool, error) {
 	query := `SELECT EXISTS (
-        SELECT 1 FROM cart_items WHERE cart_id = $1 AND product_id = $2
+        SELECT 1 FROM cart_items WHERE cart_id = $1 AND variant_id = $2
     )`
 	var exists bool
-	err := r.db.Pool.QueryRow(ctx, query, cartId, productId).Scan(&exists)
-	return exists, err
+	err := r.db.Pool.QueryRow(ctx, query, cartId, variantId).Scan(&exists)
+	return exists, dbErr(err, "cart item")
 }
 
 func (r *cartItemsRepository) ClearCart(ctx context.Context, cartId uuid.UUID) error {
 	query := `DELETE FROM cart_items WHERE cart_id = $1`
 	_, err := r.db.Pool.Exec(ctx, query, cartId)
-	return err
+	return dbErr(err, "cart item")
 }
@@ -0,0 +1,94 @@
+package repocitory
+
+import (
+	"context"
+	"time"
+
+	"github.com/Oj-washingtone/savannah-store/internal/database"
+	"github.com/Oj-washingtone/savannah-store/internal/model"
+)
+
+type CartRemindersRepository interface {
+	FindAbandoned(ctx context.Context, idleSince time.Time, limit int) ([]*model.AbandonedCart, error)
+	Create(ctx context.Context, reminder *model.CartReminder) error
+}
+
+type cartRemindersRepository struct {
+	db *database.DB
+}
+
+func NewCartRemindersRepository(db *database.DB) CartRemindersRepository {
+	return &cartRemindersRepository{db: db}
+}
+
+// FindAbandoned returns carts whose items were last touched before idleSince
+// and that have not been reminded about since that last activity. Users who
+// opted out of cart reminders are skipped.
+func (r *cartRemindersRepository) FindAbandoned(ctx context.Context, idleSince time.Time, limit int) ([]*model.AbandonedCart, error) {
+	query := `
+		WITH activity AS (
+			SELECT cart_id,
+				MAX(updated_at) AS last_activity,
+				COUNT(*) AS item_count,
+				SUM(price * quantity)::BIGINT AS subtotal
+			FROM cart_items
+			GROUP BY cart_id
+		)
+		SELECT c.id, u.id, u.name, u.email, COALESCE(u.phone, ''), a.item_count, a.subtotal, a.last_activity
+		FROM activity a
+		JOIN carts c ON c.id = a.cart_id
+		JOIN users u ON u.id = c.user_id
+		WHERE c.deleted_at IS NULL
+			AND u.deleted_at IS NULL
+			AND u.cart_reminders_opt_out = false
+			AND a.last_activity < $1
+			AND NOT EXISTS (
+				SELECT 1 FROM cart_reminders r
+				WHERE r.cart_id = c.id AND r.sent_at >= a.last_activity
+			)
+		ORDER BY a.last_activity
+		LIMIT $2
+	`
+
+	rows, err := r.db.Pool.Query(ctx, query, idleSince, limit)
+	if err != nil {
+		return nil, dbErr(err, "cart")
+	}
+	defer rows.Close()
+
+	var carts []*model.AbandonedCart
+	for rows.Next() {
+		cart := &model.AbandonedCart{}
+		if err := rows.Scan(
+			&cart.CartId,
+			&cart.UserId,
+			&cart.Name,
+			&cart.Email,
+			&cart.Phone,
+			&cart.ItemCount,
+			&cart.Subtotal,
+			&cart.LastActivity,
+		); err != nil {
+			return nil, dbErr(err, "cart")
+		}
+		carts = append(carts, cart)
+	}
+
+	return carts, dbErr(rows.Err(), "cart")
+}
+
+func (r *cartRemindersRepository) Create(ctx context.Context, reminder *model.CartReminder) error {
+	query := `
+		INSERT INTO cart_reminders (id, cart_id, user_id, channels, sent_at)
+		VALUES ($1, $2, $3, $4, $5)
+		RETURNING created_at, updated_at
+	`
+
+	return dbErr(r.db.Pool.QueryRow(ctx, query,
+		reminder.ID,
+		reminder.CartId,
+		reminder.UserId,
+		reminder.Channels,
+		reminder.SentAt,
+	).Scan(&reminder.CreatedAt, &reminder.UpdatedAt), "cart")
+}
@@ -3,105 +3,318 @@ package repocitory
 import (
 	"context"
 
+	"github.com/Oj-washingtone/savannah-store/internal/apperr"
 	"github.com/Oj-washingtone/savannah-store/internal/database"
 	"github.com/Oj-washingtone/savannah-store/internal/model"
+	"github.com/Oj-washingtone/savannah-store/internal/pagination"
 	"github.com/google/uuid"
 )
 
+// ErrCategoryNotEmpty is returned when deleting a category that still has
+// subcategories or products with the block policy.
+var ErrCategoryNotEmpty = apperr.Conflict("Category has subcategories or products")
+
 type CategoryRepository interface {
 	Create(ctx context.Context, category *model.ProductCategory) error
 	GetById(ctx context.Context, id uuid.UUID) (*model.ProductCategory, error)
-	List(ctx context.Context, limit, offset int) ([]model.ProductCategory, error)
+	GetBySlug(ctx context.Context, slug string) (*model.ProductCategory, error)
+	List(ctx context.Context, page pagination.Page) ([]model.ProductCategory, *pagination.Info, error)
 	Update(ctx context.Context, category *model.ProductCategory) error
+	Delete(ctx context.Context, id uuid.UUID, policy model.CategoryDeletePolicy, reassignTo *uuid.UUID) error
+	SlugTaken(ctx context.Context, slug string, categoryId uuid.UUID) (bool, error)
+	ListAll(ctx context.Context) ([]model.ProductCategory, error)
+	ProductCounts(ctx context.Context) (map[uuid.UUID]int, error)
+	Ancestors(ctx context.Context, id uuid.UUID) ([]model.ProductCategory, error)
+}
+
+// categoryColumns are the columns scanCategory reads, in order.
+const categoryColumns = `id, name, slug, parent_id, meta_title, meta_description, created_at, updated_at`
+
+func scanCategory(row interface{ Scan(dest ...any) error }) (*model.ProductCategory, error) {
+	var c model.ProductCategory
+
+	err := row.Scan(
+		&c.ID,
+		&c.Name,
+		&c.Slug,
+		&c.ParentId,
+		&c.MetaTitle,
+		&c.MetaDescription,
+		&c.CreatedAt,
+		&c.UpdatedAt,
+	)
+	if err != nil {
+		return nil, dbErr(err, "category")
+	}
+
+	return &c, nil
+}
+
+// queryCategories runs a query selecting categoryColumns.
+func (r *categoryRepository) queryCategories(ctx context.Context, query string, args ...any) ([]model.ProductCategory, error) {
+	rows, err := r.db.Pool.Query(ctx, query, args...)
+	if err != nil {
+		return nil, dbErr(err, "category")
+	}
+	defer rows.Close()
+
+	categories := []model.ProductCategory{}
+	for rows.Next() {
+		c, err := scanCategory(rows)
+		if err != nil {
+			return nil, dbErr(err, "category")
+		}
+		categories = append(categories, *c)
+	}
+
+	return categories, dbErr(rows.Err(), "category")
+}
+
+// categoryDescendants selects the ids of the category given by the arg
+// placeholder and of all the live categories below it. UNION drops rows
+// already seen, so a cycle in old data cannot make it loop forever.
+func categoryDescendants(arg string) string {
+	return `
+			WITH RECURSIVE tree AS (
+				SELECT id FROM categories WHERE id = ` + arg + `
+				UNION
+				SELECT c.id FROM categories c JOIN tree t ON c.parent_id = t.id WHERE c.deleted_at IS NULL
+			)
+			SELECT id FROM tree`
 }
 
 type categoryRepository struct {
 	db *database.DB
 }
 
-func NewCategoryRepository() CategoryRepository {
-	return &categoryRepository{db: database.GetDB()}
+func NewCategoryRepository(db *database.DB) CategoryRepository {
+	return &categoryRepository{db: db}
 }
 
 func (r *categoryRepository) Create(ctx context.Context, category *model.ProductCategory) error {
 	query := `
-		INSERT INTO categories (id, name, parent_id)
-		VALUES ($1, $2, $3)
+		INSERT INTO categories (id, name, slug, parent_id, meta_title, meta_description)
+		VALUES ($1, $2, $3, $4, $5, $6)
 		RETURNING created_at, updated_at
 	`
 
-	return r.db.Pool.QueryRow(ctx, query,
-		category.ID, category.Name, category.ParentId,
-	).Scan(&category.CreatedAt, &category.UpdatedAt)
+	return dbErr(r.db.Pool.QueryRow(ctx, query,
+		category.ID,
+		category.Name,
+		category.Slug,
+		category.ParentId,
+		category.MetaTitle,
+		category.MetaDescription,
+	).Scan(&category.CreatedAt, &category.UpdatedAt), "category")
 }
 
 func (r *categoryRepository) GetById(ctx context.Context, id uuid.UUID) (*model.ProductCategory, error) {
-	query := `
-		SELECT id, name, parent_id, created_at, updated_at
-		FROM categories
-		WHERE id = $1
-	`
+	query := `SELECT ` + categoryColumns + ` FROM categories WHERE id = $1 AND deleted_at IS NULL`
 
-	row := r.db.Pool.QueryRow(ctx, query, id)
+	return scanCategory(r.db.Pool.QueryRow(ctx, query, id))
+}
 
-	var category model.ProductCategory
-	err := row.Scan(
-		&category.ID,
-		&category.Name,
-		&category.ParentId,
-		&category.CreatedAt,
-		&category.UpdatedAt,
-	)
-	if err != nil {
-		return nil, err
-	}
+func (r *categoryRepository) GetBySlug(ctx context.Context, slug string) (*model.ProductCategory, error) {
+	query := `SELECT ` + categoryColumns + ` FROM categories WHERE slug = $1 AND deleted_at IS NULL`
 
-	return &category, nil
+	return scanCategory(r.db.Pool.QueryRow(ctx, query, slug))
 }
 
-func (r *categoryRepository) List(ctx context.Context, limit, offset int) ([]model.ProductCategory, error) {
+// SlugTaken reports whether a live category other than categoryId uses slug.
+func (r *categoryRepository) SlugTaken(ctx context.Context, slug string, categoryId uuid.UUID) (bool, error) {
 	query := `
-		SELECT id, name, parent_id, created_at, updated_at
-		FROM categories
-		ORDER BY created_at DESC
-		LIMIT $1 OFFSET $2
+		SELECT EXISTS (
+			SELECT 1 FROM categories WHERE slug = $1 AND id <> $2 AND deleted_at IS NULL
+		)
 	`
 
-	rows, err := r.db.Pool.Query(ctx, query, limit, offset)
+	var taken bool
+	err := r.db.Pool.QueryRow(ctx, query, slug, categoryId).Scan(&taken)
+	return taken, dbErr(err, "category")
+}
+
+func (r *categoryRepository) List(ctx context.Context, page pagination.Page) ([]model.ProductCategory, *pagination.Info, error) {
+	where, order, limit, args := page.Keyset("c", 1)
+
+	query := `
+		SELECT ` + prefixColumns("c", categoryColumns) + `
+		FROM categories c
+		WHERE c.deleted_at IS NULL AND ` + where + `
+		ORDER BY ` + order + `
+		LIMIT ` + limit
+
+	categories, err := r.queryCategories(ctx, query, args...)
 	if err != nil {
-		return nil, err
+		return nil, nil, dbErr(err, "category")
 	}
-	defer rows.Close()
 
-	var categories []model.ProductCategory
-	for rows.Next() {
-		var c model.ProductCategory
-		if err := rows.Scan(
-			&c.ID,
-			&c.Name,
-			&c.ParentId,
-			&c.CreatedAt,
-			&c.UpdatedAt,
-		); err != nil {
-			return nil, err
+	categories, info := pagination.Trim(page, categories)
+
+	if page.WithTotal {
+		if info.Total, err = countRows(ctx, r.db.Pool, `SELECT COUNT(*) FROM categories WHERE deleted_at IS NULL`); err != nil {
+			return nil, nil, dbErr(err, "category")
 		}
-		categories = append(categories, c)
 	}
 
-	return categories, nil
+	return categories, info, nil
 }
 
 func (r *categoryRepository) Update(ctx context.Context, category *model.ProductCategory) error {
 	query := `
 		UPDATE categories
-		SET name = $1, parent_id = $2, updated_at = now()
-		WHERE id = $3
+		SET name = $1, slug = $2, parent_id = $3, meta_title = $4, meta_description = $5, updated_at = now()
+		WHERE id = $6 AND deleted_at IS NULL
 		RETURNING updated_at
 	`
 
-	return r.db.Pool.QueryRow(ctx, query,
+	return dbErr(r.db.Pool.QueryRow(ctx, query,
 		category.Name,
+		category.Slug,
 		category.ParentId,
+		category.MetaTitle,
+		category.MetaDescription,
 		category.ID,
-	).Scan(&category.UpdatedAt)
+	).Scan(&category.UpdatedAt), "category")
+}
+
+// Delete soft deletes the category. The policy decides what happens to its
+// subcategories and products: block fails with ErrCategoryNotEmpty if it has
+// any, reassign moves them to reassignTo, and cascade deletes the whole
+// subtree along with its products.
+func (r *categoryRepository) Delete(ctx context.Context, id uuid.UUID, policy model.CategoryDeletePolicy, reassignTo *uuid.UUID) error {
+	tx, err := r.db.Pool.Begin(ctx)
+	if err != nil {
+		return dbErr(err, "category")
+	}
+	defer tx.Rollback(ctx)
+
+	// lock the category so nothing is added under it while it goes
+	var locked uuid.UUID
+	err = tx.QueryRow(ctx,
+		`SELECT id FROM categories WHERE id = $1 AND deleted_at IS NULL FOR UPDATE`, id,
+	).Scan(&locked)
+	if err != nil {
+		return dbErr(err, "category")
+	}
+
+	switch policy {
+	case model.CategoryDeleteBlock:
+		var notEmpty bool
+		err = tx.QueryRow(ctx, `
+			SELECT EXISTS (SELECT 1 FROM categories WHERE parent_id = $1 AND deleted_at IS NULL)
+				OR EXISTS (SELECT 1 FROM products WHERE category_id = $1 AND deleted_at IS NULL)
+		`, id).Scan(&notEmpty)
+		if err != nil {
+			return dbErr(err, "category")
+		}
+
+		if notEmpty {
+			return ErrCategoryNotEmpty
+		}
+
+	case model.CategoryDeleteReassign:
+		if reassignTo == nil {
+			return apperr.Validation("Reassign needs a category to move to")
+		}
+
+		_, err = tx.Exec(ctx, `
+			UPDATE categories SET parent_id = $2, updated_at = now()
+			WHERE parent_id = $1 AND deleted_at IS NULL
+		`, id, *reassignTo)
+		if err != nil {
+			return dbErr(err, "category")
+		}
+
+		_, err = tx.Exec(ctx, `
+			UPDATE products SET category_id = $2, updated_at = now()
+			WHERE category_id = $1 AND deleted_at IS NULL
+		`, id, *reassignTo)
+		if err != nil {
+			return dbErr(err, "category")
+		}
+
+	case model.CategoryDeleteCascade:
+		_, err = tx.Exec(ctx, `
+			UPDATE products SET deleted_at = now()
+			WHERE deleted_at IS NULL AND category_id IN (`+categoryDescendants("$1")+`
+			)
+		`, id)
+		if err != nil {
+			return dbErr(err, "category")
+		}
+
+		_, err = tx.Exec(ctx, `
+			UPDATE categories SET deleted_at = now()
+			WHERE deleted_at IS NULL AND id IN (`+categoryDescendants("$1")+`
+			)
+		`, id)
+		if err != nil {
+			return dbErr(err, "category")
+		}
+
+		return dbErr(tx.Commit(ctx), "category")
+
+	default:
+		return apperr.Validation("Unknown category delete policy " + string(policy))
+	}
+
+	if _, err = tx.Exec(ctx, `UPDATE categories SET deleted_at = now() WHERE id = $1`, id); err != nil {
+		return dbErr(err, "category")
+	}
+
+	return dbErr(tx.Commit(ctx), "category")
+}
+
+// ListAll returns every category, for building the category tree.
+func (r *categoryRepository) ListAll(ctx context.Context) ([]model.ProductCategory, error) {
+	query := `SELECT ` + categoryColumns + ` FROM categories WHERE deleted_at IS NULL ORDER BY name, id`
+
+	return r.queryCategories(ctx, query)
+}
+
+// ProductCounts counts the products directly in each category, on the read
+// replica.
+func (r *categoryRepository) ProductCounts(ctx context.Context) (map[uuid.UUID]int, error) {
+	query := `SELECT category_id, COUNT(*) FROM products WHERE deleted_at IS NULL GROUP BY category_id`
+
+	rows, err := r.db.Reads().Query(ctx, query)
+	if err != nil {
+		return nil, dbErr(err, "category")
+	}
+	defer rows.Close()
+
+	counts := map[uuid.UUID]int{}
+	for rows.Next() {
+		var categoryId uuid.UUID
+		var count int
+		if err := rows.Scan(&categoryId, &count); err != nil {
+			return nil, dbErr(err, "category")
+		}
+		counts[categoryId] = count
+	}
+
+	return counts, dbErr(rows.Err(), "category")
+}
+
+// Ancestors returns the category and the live categories above it, nearest
+// first.
+func (r *categoryRepository) Ancestors(ctx context.Context, id uuid.UUID) ([]model.ProductCategory, error) {
+	// the depth limit stops a cycle in old data from looping forever
+	query := `
+		WITH RECURSIVE ancestors AS (
+			SELECT ` + categoryColumns + `, 0 AS depth
+			FROM categories
+			WHERE id = $1 AND deleted_at IS NULL
+			UNION ALL
+			SELECT ` + prefixColumns("c", categoryColumns) + `, a.depth + 1
+			FROM categories c
+			JOIN ancestors a ON c.id = a.parent_id
+			WHERE a.depth < 100 AND c.deleted_at IS NULL
+		)
+		SELECT ` + categoryColumns + `
+		FROM ancestors
+		ORDER BY depth
+	`
+
+	return r.queryCategories(ctx, query, id)
 }
@@ -0,0 +1,362 @@
+package repocitory
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"time"
+
+	"github.com/Oj-washingtone/savannah-store/internal/apperr"
+	"github.com/Oj-washingtone/savannah-store/internal/database"
+	"github.com/Oj-washingtone/savannah-store/internal/model"
+	"github.com/Oj-washingtone/savannah-store/internal/pagination"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+)
+
+// ErrDiscountOverlap is returned by Create and Update for a discount that
+// would run at the same time as another discount on the product with the
+// same priority. Such pairs are refused so the priority alone decides which
+// discount applies.
+var ErrDiscountOverlap = apperr.Conflict("Another discount on this product with the same priority runs at the same time, give one of them a different priority")
+
+// activeDiscountOrder is the precedence between discounts that are running at
+// the same time: highest priority first, then the most recently started one.
+// The remaining keys only exist to make the choice deterministic.
+const activeDiscountOrder = `d.priority DESC, d.starts_at DESC NULLS LAST, d.created_at DESC, d.id`
+
+// activeDiscountJoin joins the single discount that applies to products p
+// right now. Select activeDiscountColumns to read it.
+const activeDiscountJoin = `
+		LEFT JOIN LATERAL (
+			SELECT d.id, d.type, d.value, d.starts_at, d.ends_at, d.priority, d.created_at, d.updated_at
+			FROM discounts d
+			WHERE d.product_id = p.id
+				AND d.deleted_at IS NULL
+				AND (d.starts_at IS NULL OR d.starts_at <= now())
+				AND (d.ends_at IS NULL OR d.ends_at > now())
+			ORDER BY ` + activeDiscountOrder + `
+			LIMIT 1
+		) ad ON true`
+
+const activeDiscountColumns = `ad.id, ad.type, ad.value, ad.starts_at, ad.ends_at, ad.priority, ad.created_at, ad.updated_at`
+
+// nullableDiscount receives the columns of activeDiscountJoin, which are all
+// NULL when the product has no running discount.
+type nullableDiscount struct {
+	ID        *uuid.UUID
+	Type      *model.DiscountType
+	Value     *int64
+	StartsAt  *time.Time
+	EndsAt    *time.Time
+	Priority  *int
+	CreatedAt *time.Time
+	UpdatedAt *time.Time
+}
+
+func (d nullableDiscount) discount(productId uuid.UUID) *model.Discount {
+	if d.ID == nil {
+		return nil
+	}
+
+	discount := &model.Discount{
+		ProductID: productId,
+		Type:      *d.Type,
+		Value:     *d.Value,
+		StartsAt:  d.StartsAt,
+		EndsAt:    d.EndsAt,
+		Priority:  *d.Priority,
+	}
+	discount.ID = *d.ID
+
+	if d.CreatedAt != nil {
+		discount.CreatedAt = *d.CreatedAt
+	}
+
+	if d.UpdatedAt != nil {
+		discount.UpdatedAt = *d.UpdatedAt
+	}
+
+	return discount
+}
+
+type DiscountRepository interface {
+	Create(ctx context.Context, discount *model.Discount) error
+	GetById(ctx context.Context, id uuid.UUID) (*model.Discount, error)
+	ListByProduct(ctx context.Context, productId uuid.UUID) ([]*model.Discount, error)
+	List(ctx context.Context, productId uuid.UUID, page pagination.Page) ([]*model.Discount, *pagination.Info, error)
+	Update(ctx context.Context, discount *model.Discount) error
+	Delete(ctx context.Context, id uuid.UUID) error
+	ClaimStarted(ctx context.Context, limit int) ([]*model.Discount, error)
+}
+
+type discountRepository struct {
+	db *database.DB
+}
+
+func NewDiscountRepository(db *database.DB) DiscountRepository {
+	return &discountRepository{db: db}
+}
+
+const discountColumns = `d.id, d.product_id, d.type, d.value, d.starts_at, d.ends_at, d.priority, d.created_at, d.updated_at`
+
+func scanDiscount(row interface{ Scan(dest ...any) error }) (*model.Discount, error) {
+	var d model.Discount
+	err := row.Scan(
+		&d.ID,
+		&d.ProductID,
+		&d.Type,
+		&d.Value,
+		&d.StartsAt,
+		&d.EndsAt,
+		&d.Priority,
+		&d.CreatedAt,
+		&d.UpdatedAt,
+	)
+	if err != nil {
+		return nil, dbErr(err, "discount")
+	}
+
+	return &d, nil
+}
+
+// Create and Update set start_alerted_at, see ClaimStarted. A discount that
+// is already running counts as alerted, as saving it alerts the watchers
+// right away, while one that starts later waits to be claimed.
+func (r *discountRepository) Create(ctx context.Context, discount *model.Discount) error {
+	query := `
+		INSERT INTO discounts (id, product_id, type, value, starts_at, ends_at, priority, start_alerted_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, CASE WHEN $5::timestamptz IS NULL OR $5 <= now() THEN now() END)
+		RETURNING created_at, updated_at
+	`
+
+	lock := `SELECT id FROM products WHERE id = $1 FOR UPDATE`
+
+	return r.saveAlone(ctx, discount, lock, discount.ProductID, func(tx pgx.Tx) error {
+		return tx.QueryRow(ctx, query,
+			discount.ID,
+			discount.ProductID,
+			discount.Type,
+			discount.Value,
+			discount.StartsAt,
+			discount.EndsAt,
+			discount.Priority,
+		).Scan(&discount.CreatedAt, &discount.UpdatedAt)
+	})
+}
+
+// saveAlone runs save in a transaction, after locking the discount's product
+// with lock, which selects the product's id given arg, and making sure no
+// other discount overlaps the discount. Saves of discounts on one product
+// take turns on the lock, so two overlapping ones cannot both pass the
+// check. With no product to lock, save runs anyway and fails on its own.
+func (r *discountRepository) saveAlone(ctx context.Context, discount *model.Discount, lock string, arg any, save func(tx pgx.Tx) error) error {
+	tx, err := r.db.Pool.Begin(ctx)
+	if err != nil {
+		return dbErr(err, "discount")
+	}
+	defer tx.Rollback(ctx)
+
+	var productId uuid.UUID
+	err = tx.QueryRow(ctx, lock, arg).Scan(&productId)
+
+	switch {
+	case err == nil:
+		overlaps, err := overlapping(ctx, tx, discount, productId)
+		if err != nil {
+			return err
+		}
+		if overlaps {
+			return ErrDiscountOverlap
+		}
+	case !errors.Is(err, pgx.ErrNoRows):
+		return dbErr(err, "discount")
+	}
+
+	if err := save(tx); err != nil {
+		return dbErr(err, "discount")
+	}
+
+	return dbErr(tx.Commit(ctx), "discount")
+}
+
+func (r *discountRepository) GetById(ctx context.Context, id uuid.UUID) (*model.Discount, error) {
+	query := `SELECT ` + discountColumns + ` FROM discounts d WHERE d.id = $1 AND d.deleted_at IS NULL`
+
+	return scanDiscount(r.db.Pool.QueryRow(ctx, query, id))
+}
+
+// ListByProduct returns every discount of the product, including scheduled
+// and expired ones, in precedence order.
+func (r *discountRepository) ListByProduct(ctx context.Context, productId uuid.UUID) ([]*model.Discount, error) {
+	query := `
+		SELECT ` + discountColumns + `
+		FROM discounts d
+		WHERE d.product_id = $1 AND d.deleted_at IS NULL
+		ORDER BY ` + activeDiscountOrder
+
+	rows, err := r.db.Pool.Query(ctx, query, productId)
+	if err != nil {
+		return nil, dbErr(err, "discount")
+	}
+	defer rows.Close()
+
+	var discounts []*model.Discount
+	for rows.Next() {
+		d, err := scanDiscount(rows)
+		if err != nil {
+			return nil, dbErr(err, "discount")
+		}
+		discounts = append(discounts, d)
+	}
+
+	return discounts, dbErr(rows.Err(), "discount")
+}
+
+// List pages through the discounts of the product, including scheduled and
+// expired ones, newest first.
+func (r *discountRepository) List(ctx context.Context, productId uuid.UUID, page pagination.Page) ([]*model.Discount, *pagination.Info, error) {
+	where, order, limit, args := page.Keyset("d", 2)
+
+	query := `
+		SELECT ` + discountColumns + `
+		FROM discounts d
+		WHERE d.product_id = $1 AND d.deleted_at IS NULL AND ` + where + `
+		ORDER BY ` + order + `
+		LIMIT ` + limit
+
+	rows, err := r.db.Pool.Query(ctx, query, append([]any{productId}, args...)...)
+	if err != nil {
+		return nil, nil, dbErr(err, "discount")
+	}
+	defer rows.Close()
+
+	discounts := []*model.Discount{}
+	for rows.Next() {
+		d, err := scanDiscount(rows)
+		if err != nil {
+			return nil, nil, err
+		}
+		discounts = append(discounts, d)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, nil, dbErr(err, "discount")
+	}
+
+	discounts, info := pagination.Trim(page, discounts)
+
+	if page.WithTotal {
+		countQuery := `SELECT COUNT(*) FROM discounts WHERE product_id = $1 AND deleted_at IS NULL`
+		if info.Total, err = countRows(ctx, r.db.Pool, countQuery, productId); err != nil {
+			return nil, nil, dbErr(err, "discount")
+		}
+	}
+
+	return discounts, info, nil
+}
+
+// overlapping reports whether another discount on the product with the
+// discount's priority runs at any time during the discount's window.
+func overlapping(ctx context.Context, tx pgx.Tx, discount *model.Discount, productId uuid.UUID) (bool, error) {
+	query := `SELECT EXISTS (
+		SELECT 1 FROM discounts
+		WHERE product_id = $1
+			AND priority = $2
+			AND id <> $3
+			AND deleted_at IS NULL
+			AND ($5::timestamptz IS NULL OR starts_at IS NULL OR starts_at < $5)
+			AND ($4::timestamptz IS NULL OR ends_at IS NULL OR ends_at > $4)
+	)`
+
+	var exists bool
+	err := tx.QueryRow(ctx, query,
+		productId,
+		discount.Priority,
+		discount.ID,
+		discount.StartsAt,
+		discount.EndsAt,
+	).Scan(&exists)
+	return exists, dbErr(err, "discount")
+}
+
+func (r *discountRepository) Update(ctx context.Context, discount *model.Discount) error {
+	query := `
+		UPDATE discounts
+		SET type = $1, value = $2, starts_at = $3, ends_at = $4, priority = $5, updated_at = now(),
+			start_alerted_at = CASE WHEN $3::timestamptz IS NULL OR $3 <= now() THEN COALESCE(start_alerted_at, now()) END
+		WHERE id = $6
+		RETURNING updated_at
+	`
+
+	lock := `
+		SELECT p.id FROM products p
+		JOIN discounts d ON d.product_id = p.id
+		WHERE d.id = $1
+		FOR UPDATE OF p
+	`
+
+	return r.saveAlone(ctx, discount, lock, discount.ID, func(tx pgx.Tx) error {
+		return tx.QueryRow(ctx, query,
+			discount.Type,
+			discount.Value,
+			discount.StartsAt,
+			discount.EndsAt,
+			discount.Priority,
+			discount.ID,
+		).Scan(&discount.UpdatedAt)
+	})
+}
+
+func (r *discountRepository) Delete(ctx context.Context, id uuid.UUID) error {
+	query := `
+		UPDATE discounts
+		SET deleted_at = now()
+		WHERE id = $1 AND deleted_at IS NULL
+	`
+	_, err := r.db.Pool.Exec(ctx, query, id)
+	return dbErr(err, "discount")
+}
+
+// ClaimStarted marks up to limit discounts that started after they were
+// saved, and whose watchers have not been told yet, as alerted and returns
+// them, the earliest start first. Each discount is claimed once, however many
+// servers ask.
+func (r *discountRepository) ClaimStarted(ctx context.Context, limit int) ([]*model.Discount, error) {
+	query := `
+		UPDATE discounts d
+		SET start_alerted_at = now()
+		WHERE d.id IN (
+			SELECT id FROM discounts
+			WHERE start_alerted_at IS NULL AND deleted_at IS NULL AND starts_at <= now()
+			ORDER BY starts_at
+			LIMIT $1
+			FOR UPDATE SKIP LOCKED
+		)
+		RETURNING ` + discountColumns
+
+	rows, err := r.db.Pool.Query(ctx, query, limit)
+	if err != nil {
+		return nil, dbErr(err, "discount")
+	}
+	defer rows.Close()
+
+	var discounts []*model.Discount
+	for rows.Next() {
+		d, err := scanDiscount(rows)
+		if err != nil {
+			return nil, err
+		}
+		discounts = append(discounts, d)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, dbErr(err, "discount")
+	}
+
+	slices.SortFunc(discounts, func(a, b *model.Discount) int {
+		return a.StartsAt.Compare(*b.StartsAt)
+	})
+
+	return discounts, nil
+}
@@ -0,0 +1,141 @@
+package repocitory
+
+import (
+	"context"
+	"errors"
+	"strings"
+
+	"github.com/Oj-washingtone/savannah-store/internal/apperr"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// conflictMessages are shown for the unique constraints a request can break.
+// Other unique violations get a general message.
+var conflictMessages = map[string]string{
+	"users_email_key":                           "An account with this email already exists",
+	"users_auth0_id_key":                        "An account for this login already exists",
+	"cart_items_cart_id_variant_id_key":         "The item is already in the cart",
+	"idx_categories_slug":                       "Another category already has this slug",
+	"idx_products_slug":                         "Another product already has this slug",
+	"idx_products_sku":                          "Another product already has this SKU",
+	"idx_product_variants_sku":                  "Another variant already has this SKU",
+	"product_options_product_id_name_key":       "The product already has an option with this name",
+	"product_option_values_option_id_value_key": "The option already has this value",
+	"promotions_code_key":                       "Another promotion already has this code",
+	"wishlist_items_wishlist_id_product_id_key": "The product is already in the wishlist",
+	"idx_wishlists_default_per_user":            "You already have a default wishlist",
+}
+
+const (
+	missingReferenceMessage = "It refers to something that does not exist"
+	inUseMessage            = "It is still in use"
+	notAllowedMessage       = "A value is not allowed"
+)
+
+// NotFoundError, ConflictError, MissingReferenceError, InUseError and
+// NotAllowedError are the errors dbErr gives for a missing row, a broken
+// unique constraint, a reference to a row that does not exist, deleting a row
+// that others still refer to and a failed check. Implementations
+// of the repositories that do not use Postgres return them, so callers see
+// the same errors whatever the storage.
+func NotFoundError(what string) error {
+	return dbErr(pgx.ErrNoRows, what)
+}
+
+func ConflictError(constraint string) error {
+	return apperr.Conflict(conflictMessage(constraint))
+}
+
+func MissingReferenceError() error {
+	return apperr.Validation(missingReferenceMessage)
+}
+
+func InUseError() error {
+	return apperr.Conflict(inUseMessage)
+}
+
+func NotAllowedError() error {
+	return apperr.Validation(notAllowedMessage)
+}
+
+// dbErr turns an error from the database into an apperr.Error, so handlers
+// can respond without looking at pgx errors. what names the thing the query
+// was about, for not found messages. The original error stays in the chain,
+// so errors.Is(err, pgx.ErrNoRows) keeps working.
+func dbErr(err error, what string) error {
+	if err == nil {
+		return nil
+	}
+
+	var appErr *apperr.Error
+	if errors.As(err, &appErr) {
+		return err
+	}
+
+	if errors.Is(err, pgx.ErrNoRows) {
+		return apperr.Wrap(apperr.KindNotFound, capitalize(what)+" not found", err)
+	}
+
+	if errors.Is(err, context.DeadlineExceeded) {
+		return apperr.Unavailable("The database took too long to answer", err)
+	}
+
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) {
+		return pgErrorKind(pgErr, err)
+	}
+
+	var connectErr *pgconn.ConnectError
+	if errors.As(err, &connectErr) || pgconn.SafeToRetry(err) {
+		return apperr.Unavailable("The database is unavailable", err)
+	}
+
+	return err
+}
+
+// pgErrorKind sorts Postgres errors by their SQLSTATE code.
+func pgErrorKind(pgErr *pgconn.PgError, err error) error {
+	switch pgErr.Code {
+	case "23505": // unique_violation
+		return apperr.Wrap(apperr.KindConflict, conflictMessage(pgErr.ConstraintName), err)
+
+	case "23503": // foreign_key_violation
+		// inserts and updates point at a missing row, deletes leave rows
+		// pointing at the one being deleted
+		if strings.HasPrefix(pgErr.Message, "update or delete") {
+			return apperr.Wrap(apperr.KindConflict, inUseMessage, err)
+		}
+		return apperr.Wrap(apperr.KindValidation, missingReferenceMessage, err)
+
+	case "23514", "23502", "22001", "22003", "22P02": // check, not null, too long, out of range, not a value of the type
+		return apperr.Wrap(apperr.KindValidation, notAllowedMessage, err)
+
+	case "40001", "40P01": // serialization failure, deadlock
+		return apperr.Unavailable("The database was busy, please try again", err)
+
+	case "57014": // query_canceled, which includes statement timeouts
+		return apperr.Unavailable("The database took too long to answer", err)
+	}
+
+	switch pgErr.Code[:2] {
+	case "08", "53", "57": // connection, resources, operator intervention
+		return apperr.Unavailable("The database is unavailable", err)
+	}
+
+	return err
+}
+
+func conflictMessage(constraint string) string {
+	if message, ok := conflictMessages[constraint]; ok {
+		return message
+	}
+	return "This already exists"
+}
+
+func capitalize(s string) string {
+	if s == "" {
+		return "Record"
+	}
+	return strings.ToUpper(s[:1]) + s[1:]
+}
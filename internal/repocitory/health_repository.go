@@ -0,0 +1,32 @@
+package repocitory
+
+import (
+	"context"
+
+	"github.com/Oj-washingtone/savannah-store/internal/database"
+)
+
+// HealthRepository checks on the database itself rather than any table, for
+// the readiness probe.
+type HealthRepository interface {
+	Ping(ctx context.Context) error
+	SchemaVersion(ctx context.Context) (version uint, dirty bool, err error)
+}
+
+type healthRepository struct {
+	db *database.DB
+}
+
+func NewHealthRepository(db *database.DB) HealthRepository {
+	return &healthRepository{db: db}
+}
+
+func (r *healthRepository) Ping(ctx context.Context) error {
+	return r.db.Pool.Ping(ctx)
+}
+
+// SchemaVersion is the migration the database is at, see
+// database.SchemaVersion.
+func (r *healthRepository) SchemaVersion(ctx context.Context) (uint, bool, error) {
+	return database.SchemaVersion(ctx, r.db.Pool)
+}
@@ -0,0 +1,40 @@
+package repocitory
+
+import (
+	"context"
+	"strings"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// prefixColumns qualifies a comma separated column list with a table alias so
+// the shared column lists can be used in joins.
+func prefixColumns(alias, columns string) string {
+	parts := strings.Split(columns, ",")
+	for i, part := range parts {
+		parts[i] = alias + "." + strings.TrimSpace(part)
+	}
+
+	return strings.Join(parts, ", ")
+}
+
+// countRows runs a COUNT query on pool, for the totals of paginated lists.
+func countRows(ctx context.Context, pool *pgxpool.Pool, query string, args ...any) (*int, error) {
+	var total int
+	if err := pool.QueryRow(ctx, query, args...).Scan(&total); err != nil {
+		return nil, err
+	}
+
+	return &total, nil
+}
+
+// withKey scans the sort key selected after the columns the row is scanned
+// into, for lists paged with pagination.Page.KeysetBy.
+type withKey struct {
+	row interface{ Scan(dest ...any) error }
+	key *string
+}
+
+func (r withKey) Scan(dest ...any) error {
+	return r.row.Scan(append(dest, r.key)...)
+}
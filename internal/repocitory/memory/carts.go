@@ -0,0 +1,259 @@
+package memory
+
+import (
+	"context"
+	"slices"
+	"time"
+	"unicode/utf8"
+
+	"github.com/Oj-washingtone/savannah-store/internal/model"
+	"github.com/Oj-washingtone/savannah-store/internal/repocitory"
+	"github.com/google/uuid"
+)
+
+type shoppingCartRepository struct {
+	s *store
+}
+
+func (r *shoppingCartRepository) CreateCart(ctx context.Context, userId uuid.UUID) (*model.Cart, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	if _, ok := r.s.users[userId]; !ok {
+		return nil, repocitory.MissingReferenceError()
+	}
+
+	cart := model.Cart{UserId: userId}
+	cart.ID = uuid.New()
+	cart.CreatedAt = r.s.now()
+	cart.UpdatedAt = cart.CreatedAt
+
+	r.s.carts[cart.ID] = &row[model.Cart]{value: cart}
+
+	return &cart, nil
+}
+
+func (r *shoppingCartRepository) GetShoppingCart(ctx context.Context, userId uuid.UUID) (*model.Cart, error) {
+	r.s.mu.RLock()
+	defer r.s.mu.RUnlock()
+
+	for _, c := range r.s.carts {
+		if !c.deleted && c.value.UserId == userId {
+			cart := c.value
+			return &cart, nil
+		}
+	}
+
+	return nil, repocitory.NotFoundError("cart")
+}
+
+type cartItemsRepository struct {
+	s *store
+}
+
+func (r *cartItemsRepository) AddItem(ctx context.Context, item *model.CartItem) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	if _, ok := r.s.cartItems[item.ID]; ok {
+		return repocitory.ConflictError("cart_items_pkey")
+	}
+
+	if item.Quantity <= 0 || item.Price < 0 {
+		return repocitory.NotAllowedError()
+	}
+
+	_, cartOk := r.s.carts[item.CartId]
+	_, productOk := r.s.products[item.ProductId]
+	_, variantOk := r.s.variants[item.VariantId]
+
+	if !cartOk || !productOk || !variantOk {
+		return repocitory.MissingReferenceError()
+	}
+
+	for _, other := range r.s.cartItems {
+		if other.CartId == item.CartId && other.VariantId == item.VariantId {
+			return repocitory.ConflictError("cart_items_cart_id_variant_id_key")
+		}
+	}
+
+	item.CreatedAt = r.s.now()
+	item.UpdatedAt = item.CreatedAt
+
+	stored := *item
+	r.s.cartItems[item.ID] = &stored
+
+	return nil
+}
+
+func (r *cartItemsRepository) RemoveItem(ctx context.Context, itemId uuid.UUID) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	delete(r.s.cartItems, itemId)
+
+	return nil
+}
+
+func (r *cartItemsRepository) GetItems(ctx context.Context, cartId uuid.UUID) ([]*model.CartItem, error) {
+	r.s.mu.RLock()
+	defer r.s.mu.RUnlock()
+
+	var items []*model.CartItem
+	for _, item := range r.s.cartItems {
+		if item.CartId == cartId {
+			i := *item
+			items = append(items, &i)
+		}
+	}
+
+	oldestFirst(items)
+
+	return items, nil
+}
+
+func (r *cartItemsRepository) UpdateQuantity(ctx context.Context, itemId uuid.UUID, quantity int) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	if quantity <= 0 {
+		return repocitory.NotAllowedError()
+	}
+
+	if item, ok := r.s.cartItems[itemId]; ok {
+		item.Quantity = quantity
+		item.UpdatedAt = r.s.now()
+	}
+
+	return nil
+}
+
+func (r *cartItemsRepository) Exists(ctx context.Context, cartId, variantId uuid.UUID) (bool, error) {
+	r.s.mu.RLock()
+	defer r.s.mu.RUnlock()
+
+	for _, item := range r.s.cartItems {
+		if item.CartId == cartId && item.VariantId == variantId {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
+func (r *cartItemsRepository) ClearCart(ctx context.Context, cartId uuid.UUID) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	r.s.clearCart(cartId)
+
+	return nil
+}
+
+func (s *store) clearCart(cartId uuid.UUID) {
+	for id, item := range s.cartItems {
+		if item.CartId == cartId {
+			delete(s.cartItems, id)
+		}
+	}
+}
+
+type cartRemindersRepository struct {
+	s *store
+}
+
+// FindAbandoned returns carts whose items were last touched before idleSince
+// and that have not been reminded about since that last activity. Users who
+// opted out of cart reminders are skipped.
+func (r *cartRemindersRepository) FindAbandoned(ctx context.Context, idleSince time.Time, limit int) ([]*model.AbandonedCart, error) {
+	r.s.mu.RLock()
+	defer r.s.mu.RUnlock()
+
+	activity := map[uuid.UUID]*model.AbandonedCart{}
+	for _, item := range r.s.cartItems {
+		a, ok := activity[item.CartId]
+		if !ok {
+			a = &model.AbandonedCart{CartId: item.CartId}
+			activity[item.CartId] = a
+		}
+
+		a.ItemCount++
+		a.Subtotal += item.Price * int64(item.Quantity)
+		if item.UpdatedAt.After(a.LastActivity) {
+			a.LastActivity = item.UpdatedAt
+		}
+	}
+
+	var carts []*model.AbandonedCart
+	for cartId, a := range activity {
+		c := r.s.carts[cartId]
+		if c.deleted || !a.LastActivity.Before(idleSince) {
+			continue
+		}
+
+		user, ok := r.s.users[c.value.UserId]
+		if !ok || user.DeletedAt != nil || user.CartRemindersOptOut {
+			continue
+		}
+
+		if r.s.remindedSince(cartId, a.LastActivity) {
+			continue
+		}
+
+		a.UserId = user.ID
+		a.Name = user.Name
+		a.Email = user.Email
+		a.Phone = user.Phone
+		carts = append(carts, a)
+	}
+
+	slices.SortFunc(carts, func(a, b *model.AbandonedCart) int {
+		return a.LastActivity.Compare(b.LastActivity)
+	})
+
+	if len(carts) > limit {
+		carts = carts[:limit]
+	}
+
+	return carts, nil
+}
+
+// remindedSince reports whether a reminder about the cart was sent at or
+// after the given time.
+func (s *store) remindedSince(cartId uuid.UUID, since time.Time) bool {
+	for _, reminder := range s.cartReminders {
+		if reminder.CartId == cartId && !reminder.SentAt.Before(since) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (r *cartRemindersRepository) Create(ctx context.Context, reminder *model.CartReminder) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	if _, ok := r.s.cartReminders[reminder.ID]; ok {
+		return repocitory.ConflictError("cart_reminders_pkey")
+	}
+
+	if utf8.RuneCountInString(reminder.Channels) > 50 {
+		return repocitory.NotAllowedError()
+	}
+
+	_, cartOk := r.s.carts[reminder.CartId]
+	_, userOk := r.s.users[reminder.UserId]
+
+	if !cartOk || !userOk {
+		return repocitory.MissingReferenceError()
+	}
+
+	reminder.CreatedAt = r.s.now()
+	reminder.UpdatedAt = reminder.CreatedAt
+
+	stored := *reminder
+	r.s.cartReminders[reminder.ID] = &stored
+
+	return nil
+}
@@ -0,0 +1,308 @@
+package memory
+
+import (
+	"cmp"
+	"context"
+	"slices"
+	"unicode/utf8"
+
+	"github.com/Oj-washingtone/savannah-store/internal/apperr"
+	"github.com/Oj-washingtone/savannah-store/internal/model"
+	"github.com/Oj-washingtone/savannah-store/internal/pagination"
+	"github.com/Oj-washingtone/savannah-store/internal/repocitory"
+	"github.com/google/uuid"
+)
+
+type categoryRepository struct {
+	s *store
+}
+
+// categoryDescendants gives the ids of the category and of all the live
+// categories below it. A cycle in the parents cannot make it loop, as each
+// category is only visited once.
+func (s *store) categoryDescendants(id uuid.UUID) map[uuid.UUID]bool {
+	tree := map[uuid.UUID]bool{}
+	if _, ok := s.categories[id]; !ok {
+		return tree
+	}
+
+	tree[id] = true
+	queue := []uuid.UUID{id}
+
+	for len(queue) > 0 {
+		parent := queue[0]
+		queue = queue[1:]
+
+		for _, c := range s.categories {
+			if c.deleted || c.value.ParentId == nil || *c.value.ParentId != parent || tree[c.value.ID] {
+				continue
+			}
+
+			tree[c.value.ID] = true
+			queue = append(queue, c.value.ID)
+		}
+	}
+
+	return tree
+}
+
+// checkCategory holds a category to the constraints of the categories table.
+func (s *store) checkCategory(category *model.ProductCategory) error {
+	if utf8.RuneCountInString(category.Name) > 100 ||
+		utf8.RuneCountInString(category.MetaTitle) > model.MaxMetaTitleLength ||
+		utf8.RuneCountInString(category.MetaDescription) > model.MaxMetaDescriptionLength {
+		return repocitory.NotAllowedError()
+	}
+
+	if category.ParentId != nil {
+		if _, ok := s.categories[*category.ParentId]; !ok {
+			return repocitory.MissingReferenceError()
+		}
+	}
+
+	for _, c := range s.categories {
+		if !c.deleted && c.value.ID != category.ID && c.value.Slug == category.Slug {
+			return repocitory.ConflictError("idx_categories_slug")
+		}
+	}
+
+	return nil
+}
+
+func copyCategory(c *model.ProductCategory) model.ProductCategory {
+	category := *c
+	category.ParentId = copyOf(c.ParentId)
+	return category
+}
+
+func (r *categoryRepository) Create(ctx context.Context, category *model.ProductCategory) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	if _, ok := r.s.categories[category.ID]; ok {
+		return repocitory.ConflictError("categories_pkey")
+	}
+
+	if err := r.s.checkCategory(category); err != nil {
+		return err
+	}
+
+	category.CreatedAt = r.s.now()
+	category.UpdatedAt = category.CreatedAt
+
+	r.s.categories[category.ID] = &row[model.ProductCategory]{value: copyCategory(category)}
+
+	return nil
+}
+
+func (r *categoryRepository) GetById(ctx context.Context, id uuid.UUID) (*model.ProductCategory, error) {
+	r.s.mu.RLock()
+	defer r.s.mu.RUnlock()
+
+	c, ok := r.s.categories[id]
+	if !ok || c.deleted {
+		return nil, repocitory.NotFoundError("category")
+	}
+
+	category := copyCategory(&c.value)
+	return &category, nil
+}
+
+func (r *categoryRepository) GetBySlug(ctx context.Context, slug string) (*model.ProductCategory, error) {
+	r.s.mu.RLock()
+	defer r.s.mu.RUnlock()
+
+	for _, c := range r.s.categories {
+		if !c.deleted && c.value.Slug == slug {
+			category := copyCategory(&c.value)
+			return &category, nil
+		}
+	}
+
+	return nil, repocitory.NotFoundError("category")
+}
+
+func (r *categoryRepository) SlugTaken(ctx context.Context, slug string, categoryId uuid.UUID) (bool, error) {
+	r.s.mu.RLock()
+	defer r.s.mu.RUnlock()
+
+	for _, c := range r.s.categories {
+		if !c.deleted && c.value.ID != categoryId && c.value.Slug == slug {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
+// live returns copies of the categories that are not deleted.
+func (r *categoryRepository) live() []model.ProductCategory {
+	categories := []model.ProductCategory{}
+	for _, c := range r.s.categories {
+		if !c.deleted {
+			categories = append(categories, copyCategory(&c.value))
+		}
+	}
+
+	return categories
+}
+
+func (r *categoryRepository) List(ctx context.Context, page pagination.Page) ([]model.ProductCategory, *pagination.Info, error) {
+	r.s.mu.RLock()
+	defer r.s.mu.RUnlock()
+
+	categories, info := paginate(page, r.live())
+	return categories, info, nil
+}
+
+func (r *categoryRepository) Update(ctx context.Context, category *model.ProductCategory) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	c, ok := r.s.categories[category.ID]
+	if !ok || c.deleted {
+		return repocitory.NotFoundError("category")
+	}
+
+	if err := r.s.checkCategory(category); err != nil {
+		return err
+	}
+
+	category.UpdatedAt = r.s.now()
+
+	c.value.Name = category.Name
+	c.value.Slug = category.Slug
+	c.value.ParentId = copyOf(category.ParentId)
+	c.value.MetaTitle = category.MetaTitle
+	c.value.MetaDescription = category.MetaDescription
+	c.value.UpdatedAt = category.UpdatedAt
+
+	return nil
+}
+
+// Delete soft deletes the category, handling its subcategories and products
+// as the policy says, see the Postgres repository.
+func (r *categoryRepository) Delete(ctx context.Context, id uuid.UUID, policy model.CategoryDeletePolicy, reassignTo *uuid.UUID) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	c, ok := r.s.categories[id]
+	if !ok || c.deleted {
+		return repocitory.NotFoundError("category")
+	}
+
+	var children []*row[model.ProductCategory]
+	for _, child := range r.s.categories {
+		if !child.deleted && child.value.ParentId != nil && *child.value.ParentId == id {
+			children = append(children, child)
+		}
+	}
+
+	var products []*row[model.Product]
+	for _, p := range r.s.products {
+		if !p.deleted && p.value.CategoryID == id {
+			products = append(products, p)
+		}
+	}
+
+	switch policy {
+	case model.CategoryDeleteBlock:
+		if len(children) > 0 || len(products) > 0 {
+			return repocitory.ErrCategoryNotEmpty
+		}
+
+	case model.CategoryDeleteReassign:
+		if reassignTo == nil {
+			return apperr.Validation("Reassign needs a category to move to")
+		}
+
+		if _, ok := r.s.categories[*reassignTo]; !ok && (len(children) > 0 || len(products) > 0) {
+			return repocitory.MissingReferenceError()
+		}
+
+		updatedAt := r.s.now()
+
+		for _, child := range children {
+			child.value.ParentId = copyOf(reassignTo)
+			child.value.UpdatedAt = updatedAt
+		}
+
+		for _, p := range products {
+			p.value.CategoryID = *reassignTo
+			p.value.UpdatedAt = updatedAt
+		}
+
+	case model.CategoryDeleteCascade:
+		tree := r.s.categoryDescendants(id)
+
+		for _, p := range r.s.products {
+			if tree[p.value.CategoryID] {
+				p.deleted = true
+			}
+		}
+
+		for categoryId := range tree {
+			r.s.categories[categoryId].deleted = true
+		}
+
+		return nil
+
+	default:
+		return apperr.Validation("Unknown category delete policy " + string(policy))
+	}
+
+	c.deleted = true
+
+	return nil
+}
+
+func (r *categoryRepository) ListAll(ctx context.Context) ([]model.ProductCategory, error) {
+	r.s.mu.RLock()
+	defer r.s.mu.RUnlock()
+
+	categories := r.live()
+	slices.SortFunc(categories, func(a, b model.ProductCategory) int {
+		if c := cmp.Compare(a.Name, b.Name); c != 0 {
+			return c
+		}
+		return compareIds(a.ID, b.ID)
+	})
+
+	return categories, nil
+}
+
+func (r *categoryRepository) ProductCounts(ctx context.Context) (map[uuid.UUID]int, error) {
+	r.s.mu.RLock()
+	defer r.s.mu.RUnlock()
+
+	counts := map[uuid.UUID]int{}
+	for _, p := range r.s.products {
+		if !p.deleted {
+			counts[p.value.CategoryID]++
+		}
+	}
+
+	return counts, nil
+}
+
+// Ancestors returns the category and the live categories above it, nearest
+// first, stopping after 100 levels like the Postgres query.
+func (r *categoryRepository) Ancestors(ctx context.Context, id uuid.UUID) ([]model.ProductCategory, error) {
+	r.s.mu.RLock()
+	defer r.s.mu.RUnlock()
+
+	ancestors := []model.ProductCategory{}
+
+	c, ok := r.s.categories[id]
+	for depth := 0; ok && !c.deleted && depth <= 100; depth++ {
+		ancestors = append(ancestors, copyCategory(&c.value))
+
+		if c.value.ParentId == nil {
+			break
+		}
+
+		c, ok = r.s.categories[*c.value.ParentId]
+	}
+
+	return ancestors, nil
+}
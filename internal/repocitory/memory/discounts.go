@@ -0,0 +1,247 @@
+package memory
+
+import (
+	"cmp"
+	"context"
+	"slices"
+	"time"
+
+	"github.com/Oj-washingtone/savannah-store/internal/model"
+	"github.com/Oj-washingtone/savannah-store/internal/pagination"
+	"github.com/Oj-washingtone/savannah-store/internal/repocitory"
+	"github.com/google/uuid"
+)
+
+type discountRepository struct {
+	s *store
+}
+
+// compareDiscounts orders discounts by precedence: highest priority first,
+// then the most recently started one, as activeDiscountOrder does.
+func compareDiscounts(a, b *model.Discount) int {
+	startsAt := func(a, b *model.Discount) int {
+		switch {
+		case a.StartsAt == nil && b.StartsAt == nil:
+			return 0
+		case a.StartsAt == nil:
+			return 1
+		case b.StartsAt == nil:
+			return -1
+		}
+		return b.StartsAt.Compare(*a.StartsAt)
+	}
+
+	return cmp.Or(
+		cmp.Compare(b.Priority, a.Priority),
+		startsAt(a, b),
+		b.CreatedAt.Compare(a.CreatedAt),
+		compareIds(a.ID, b.ID),
+	)
+}
+
+func copyDiscount(d *model.Discount) model.Discount {
+	discount := *d
+	discount.StartsAt = copyOf(d.StartsAt)
+	discount.EndsAt = copyOf(d.EndsAt)
+	return discount
+}
+
+// checkDiscount holds a discount to the constraints of the discounts table.
+func checkDiscount(d *model.Discount) error {
+	switch d.Type {
+	case model.DiscountPercentage, model.DiscountFixed:
+	default:
+		return repocitory.NotAllowedError()
+	}
+
+	if d.Value <= 0 || (d.Type == model.DiscountPercentage && d.Value > 100) {
+		return repocitory.NotAllowedError()
+	}
+
+	if d.StartsAt != nil && d.EndsAt != nil && !d.EndsAt.After(*d.StartsAt) {
+		return repocitory.NotAllowedError()
+	}
+
+	return nil
+}
+
+// Create and Update check for an overlap before the table's constraints, as
+// the Postgres repository does.
+func (r *discountRepository) Create(ctx context.Context, discount *model.Discount) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	if r.s.overlaps(discount, discount.ProductID) {
+		return repocitory.ErrDiscountOverlap
+	}
+
+	if _, ok := r.s.discounts[discount.ID]; ok {
+		return repocitory.ConflictError("discounts_pkey")
+	}
+
+	if err := checkDiscount(discount); err != nil {
+		return err
+	}
+
+	if _, ok := r.s.products[discount.ProductID]; !ok {
+		return repocitory.MissingReferenceError()
+	}
+
+	discount.CreatedAt = r.s.now()
+	discount.UpdatedAt = discount.CreatedAt
+
+	r.s.discounts[discount.ID] = &row[model.Discount]{value: copyDiscount(discount)}
+	r.s.setStartAlerted(discount, discount.CreatedAt)
+
+	return nil
+}
+
+func (r *discountRepository) GetById(ctx context.Context, id uuid.UUID) (*model.Discount, error) {
+	r.s.mu.RLock()
+	defer r.s.mu.RUnlock()
+
+	d, ok := r.s.discounts[id]
+	if !ok || d.deleted {
+		return nil, repocitory.NotFoundError("discount")
+	}
+
+	discount := copyDiscount(&d.value)
+	return &discount, nil
+}
+
+// ListByProduct returns every discount of the product, including scheduled
+// and expired ones, in precedence order.
+func (r *discountRepository) ListByProduct(ctx context.Context, productId uuid.UUID) ([]*model.Discount, error) {
+	r.s.mu.RLock()
+	defer r.s.mu.RUnlock()
+
+	var discounts []*model.Discount
+	for _, d := range r.s.discounts {
+		if !d.deleted && d.value.ProductID == productId {
+			discount := copyDiscount(&d.value)
+			discounts = append(discounts, &discount)
+		}
+	}
+
+	slices.SortFunc(discounts, compareDiscounts)
+
+	return discounts, nil
+}
+
+func (r *discountRepository) List(ctx context.Context, productId uuid.UUID, page pagination.Page) ([]*model.Discount, *pagination.Info, error) {
+	r.s.mu.RLock()
+	defer r.s.mu.RUnlock()
+
+	var discounts []*model.Discount
+	for _, d := range r.s.discounts {
+		if !d.deleted && d.value.ProductID == productId {
+			discount := copyDiscount(&d.value)
+			discounts = append(discounts, &discount)
+		}
+	}
+
+	discounts, info := paginate(page, discounts)
+	return discounts, info, nil
+}
+
+// overlaps reports whether another discount on the product with the
+// discount's priority runs at any time during the discount's window.
+func (s *store) overlaps(discount *model.Discount, productId uuid.UUID) bool {
+	for _, d := range s.discounts {
+		other := &d.value
+
+		if d.deleted || other.ProductID != productId || other.Priority != discount.Priority || other.ID == discount.ID {
+			continue
+		}
+
+		startsBeforeEnd := discount.EndsAt == nil || other.StartsAt == nil || other.StartsAt.Before(*discount.EndsAt)
+		endsAfterStart := discount.StartsAt == nil || other.EndsAt == nil || other.EndsAt.After(*discount.StartsAt)
+
+		if startsBeforeEnd && endsAfterStart {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (r *discountRepository) Update(ctx context.Context, discount *model.Discount) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	d, ok := r.s.discounts[discount.ID]
+	if !ok {
+		return repocitory.NotFoundError("discount")
+	}
+
+	if r.s.overlaps(discount, d.value.ProductID) {
+		return repocitory.ErrDiscountOverlap
+	}
+
+	if err := checkDiscount(discount); err != nil {
+		return err
+	}
+
+	discount.UpdatedAt = r.s.now()
+
+	d.value.Type = discount.Type
+	d.value.Value = discount.Value
+	d.value.StartsAt = copyOf(discount.StartsAt)
+	d.value.EndsAt = copyOf(discount.EndsAt)
+	d.value.Priority = discount.Priority
+	d.value.UpdatedAt = discount.UpdatedAt
+
+	r.s.setStartAlerted(discount, discount.UpdatedAt)
+
+	return nil
+}
+
+// setStartAlerted counts a discount saved at the given time as alerted when
+// it is already running, as the Postgres repository does.
+func (s *store) setStartAlerted(d *model.Discount, at time.Time) {
+	if d.StartsAt == nil || !d.StartsAt.After(at) {
+		s.startAlerted[d.ID] = true
+	} else {
+		delete(s.startAlerted, d.ID)
+	}
+}
+
+func (r *discountRepository) Delete(ctx context.Context, id uuid.UUID) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	if d, ok := r.s.discounts[id]; ok {
+		d.deleted = true
+	}
+
+	return nil
+}
+
+func (r *discountRepository) ClaimStarted(ctx context.Context, limit int) ([]*model.Discount, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	now := r.s.now()
+
+	var started []*model.Discount
+	for _, d := range r.s.discounts {
+		if !d.deleted && !r.s.startAlerted[d.value.ID] && d.value.StartsAt != nil && !d.value.StartsAt.After(now) {
+			discount := copyDiscount(&d.value)
+			started = append(started, &discount)
+		}
+	}
+
+	slices.SortFunc(started, func(a, b *model.Discount) int {
+		return cmp.Or(a.StartsAt.Compare(*b.StartsAt), compareIds(a.ID, b.ID))
+	})
+
+	if len(started) > limit {
+		started = started[:limit]
+	}
+
+	for _, d := range started {
+		r.s.startAlerted[d.ID] = true
+	}
+
+	return started, nil
+}
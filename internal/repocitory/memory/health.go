@@ -0,0 +1,22 @@
+package memory
+
+import (
+	"context"
+
+	"github.com/Oj-washingtone/savannah-store/internal/database"
+)
+
+type healthRepository struct {
+	s *store
+}
+
+func (r *healthRepository) Ping(ctx context.Context) error {
+	return nil
+}
+
+// SchemaVersion reports the latest migration, as the store always has the
+// schema the code expects.
+func (r *healthRepository) SchemaVersion(ctx context.Context) (uint, bool, error) {
+	version, err := database.LatestMigration()
+	return version, false, err
+}
@@ -0,0 +1,283 @@
+package memory
+
+import (
+	"cmp"
+	"context"
+	"slices"
+	"unicode/utf8"
+
+	"github.com/Oj-washingtone/savannah-store/internal/model"
+	"github.com/Oj-washingtone/savannah-store/internal/repocitory"
+	"github.com/google/uuid"
+)
+
+type productImageRepository struct {
+	s *store
+}
+
+// Create adds the image after the product's existing images, together with
+// its variants. The first image of a product becomes its primary image.
+func (r *productImageRepository) Create(ctx context.Context, image *model.ProductImage, variants []*model.ProductImageVariant) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	if _, ok := r.s.images[image.ID]; ok {
+		return repocitory.ConflictError("product_images_pkey")
+	}
+
+	if utf8.RuneCountInString(image.AltText) > 100 {
+		return repocitory.NotAllowedError()
+	}
+
+	if _, ok := r.s.products[image.ProductID]; !ok {
+		return repocitory.MissingReferenceError()
+	}
+
+	isPrimary, position := true, 0
+	for _, img := range r.s.liveImages(image.ProductID) {
+		isPrimary = isPrimary && !img.value.IsPrimary
+		position = max(position, img.value.Position+1)
+	}
+
+	type variantKey struct{ name, contentType string }
+	seen := map[variantKey]bool{}
+
+	for _, v := range variants {
+		if _, ok := r.s.imageVariants[v.ID]; ok {
+			return repocitory.ConflictError("product_image_variants_pkey")
+		}
+
+		if v.ImageID != image.ID {
+			if _, ok := r.s.images[v.ImageID]; !ok {
+				return repocitory.MissingReferenceError()
+			}
+		}
+
+		key := variantKey{v.Name, v.ContentType}
+		if seen[key] {
+			return repocitory.ConflictError("product_image_variants_image_id_name_content_type_key")
+		}
+		seen[key] = true
+	}
+
+	image.IsPrimary = isPrimary
+	image.Position = position
+	image.CreatedAt = r.s.now()
+	image.UpdatedAt = image.CreatedAt
+
+	stored := *image
+	stored.VariantID = nil
+	stored.Variants = nil
+	r.s.images[image.ID] = &row[model.ProductImage]{value: stored}
+
+	for _, v := range variants {
+		v.CreatedAt = image.CreatedAt
+		v.UpdatedAt = image.CreatedAt
+
+		stored := *v
+		r.s.imageVariants[v.ID] = &stored
+	}
+
+	return nil
+}
+
+// liveImages returns the stored live images of a product.
+func (s *store) liveImages(productId uuid.UUID) []*row[model.ProductImage] {
+	var images []*row[model.ProductImage]
+	for _, img := range s.images {
+		if !img.deleted && img.value.ProductID == productId {
+			images = append(images, img)
+		}
+	}
+
+	slices.SortFunc(images, func(a, b *row[model.ProductImage]) int {
+		return cmp.Or(cmp.Compare(a.value.Position, b.value.Position), a.value.CreatedAt.Compare(b.value.CreatedAt))
+	})
+
+	return images
+}
+
+// image gives a copy of a stored image with its variants.
+func (s *store) image(img *row[model.ProductImage]) *model.ProductImage {
+	image := img.value
+	image.VariantID = copyOf(img.value.VariantID)
+	image.Variants = map[string]*model.ImageVariant{}
+
+	for _, v := range s.listVariants([]uuid.UUID{image.ID}) {
+		image.AddVariant(v)
+	}
+
+	return &image
+}
+
+func (r *productImageRepository) GetById(ctx context.Context, id uuid.UUID) (*model.ProductImage, error) {
+	r.s.mu.RLock()
+	defer r.s.mu.RUnlock()
+
+	img, ok := r.s.images[id]
+	if !ok || img.deleted {
+		return nil, repocitory.NotFoundError("image")
+	}
+
+	return r.s.image(img), nil
+}
+
+func (r *productImageRepository) ListByProduct(ctx context.Context, productId uuid.UUID) ([]*model.ProductImage, error) {
+	images, err := r.ListByProducts(ctx, []uuid.UUID{productId})
+	if err != nil {
+		return nil, err
+	}
+
+	return images[productId], nil
+}
+
+func (r *productImageRepository) ListByProducts(ctx context.Context, productIds []uuid.UUID) (map[uuid.UUID][]*model.ProductImage, error) {
+	r.s.mu.RLock()
+	defer r.s.mu.RUnlock()
+
+	images := map[uuid.UUID][]*model.ProductImage{}
+	for _, productId := range productIds {
+		if _, done := images[productId]; done {
+			continue
+		}
+
+		for _, img := range r.s.liveImages(productId) {
+			images[productId] = append(images[productId], r.s.image(img))
+		}
+	}
+
+	return images, nil
+}
+
+// listVariants returns the variant files of the images, by image, width and
+// content type.
+func (s *store) listVariants(imageIds []uuid.UUID) []*model.ProductImageVariant {
+	var variants []*model.ProductImageVariant
+	for _, v := range s.imageVariants {
+		if slices.Contains(imageIds, v.ImageID) {
+			variant := *v
+			variants = append(variants, &variant)
+		}
+	}
+
+	slices.SortFunc(variants, func(a, b *model.ProductImageVariant) int {
+		return cmp.Or(compareIds(a.ImageID, b.ImageID), cmp.Compare(a.Width, b.Width), cmp.Compare(a.ContentType, b.ContentType))
+	})
+
+	return variants
+}
+
+func (r *productImageRepository) ListVariants(ctx context.Context, imageIds []uuid.UUID) ([]*model.ProductImageVariant, error) {
+	r.s.mu.RLock()
+	defer r.s.mu.RUnlock()
+
+	return r.s.listVariants(imageIds), nil
+}
+
+func (r *productImageRepository) UpdateAltText(ctx context.Context, id uuid.UUID, altText string) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	if utf8.RuneCountInString(altText) > 100 {
+		return repocitory.NotAllowedError()
+	}
+
+	if img, ok := r.s.images[id]; ok && !img.deleted {
+		img.value.AltText = altText
+		img.value.UpdatedAt = r.s.now()
+	}
+
+	return nil
+}
+
+func (r *productImageRepository) SetVariant(ctx context.Context, id uuid.UUID, variantId *uuid.UUID) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	img, ok := r.s.images[id]
+	if !ok || img.deleted {
+		return nil
+	}
+
+	if variantId != nil {
+		if _, ok := r.s.variants[*variantId]; !ok {
+			return repocitory.MissingReferenceError()
+		}
+	}
+
+	img.value.VariantID = copyOf(variantId)
+	img.value.UpdatedAt = r.s.now()
+
+	return nil
+}
+
+// SetPrimary makes the image the primary image of its product.
+func (r *productImageRepository) SetPrimary(ctx context.Context, productId, imageId uuid.UUID) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	updatedAt := r.s.now()
+
+	for _, img := range r.s.images {
+		if img.value.ProductID == productId && img.value.IsPrimary && img.value.ID != imageId {
+			img.value.IsPrimary = false
+			img.value.UpdatedAt = updatedAt
+		}
+	}
+
+	if img, ok := r.s.images[imageId]; ok && !img.deleted && img.value.ProductID == productId {
+		img.value.IsPrimary = true
+		img.value.UpdatedAt = updatedAt
+	}
+
+	return nil
+}
+
+// Reorder gives the images the positions of their place in imageIds. Images
+// of the product left out of the list keep their relative order after them.
+func (r *productImageRepository) Reorder(ctx context.Context, productId uuid.UUID, imageIds []uuid.UUID) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	place := func(id uuid.UUID) int {
+		if i := slices.Index(imageIds, id); i >= 0 {
+			return i
+		}
+		return len(imageIds)
+	}
+
+	images := r.s.liveImages(productId)
+	slices.SortStableFunc(images, func(a, b *row[model.ProductImage]) int {
+		return cmp.Compare(place(a.value.ID), place(b.value.ID))
+	})
+
+	updatedAt := r.s.now()
+
+	for position, img := range images {
+		img.value.Position = position
+		img.value.UpdatedAt = updatedAt
+	}
+
+	return nil
+}
+
+// Delete soft deletes the image. If it was the primary image, the next image
+// in line takes over.
+func (r *productImageRepository) Delete(ctx context.Context, image *model.ProductImage) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	if img, ok := r.s.images[image.ID]; ok && !img.deleted {
+		img.deleted = true
+		img.value.IsPrimary = false
+	}
+
+	if image.IsPrimary {
+		if next := r.s.liveImages(image.ProductID); len(next) > 0 {
+			next[0].value.IsPrimary = true
+			next[0].value.UpdatedAt = r.s.now()
+		}
+	}
+
+	return nil
+}
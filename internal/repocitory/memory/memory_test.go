@@ -0,0 +1,15 @@
+package memory_test
+
+import (
+	"testing"
+
+	"github.com/Oj-washingtone/savannah-store/internal/repocitory"
+	"github.com/Oj-washingtone/savannah-store/internal/repocitory/memory"
+	"github.com/Oj-washingtone/savannah-store/internal/repocitory/repotest"
+)
+
+func TestContract(t *testing.T) {
+	repotest.Run(t, func(t *testing.T) *repocitory.Repositories {
+		return memory.NewRepositories()
+	})
+}
@@ -0,0 +1,117 @@
+package memory
+
+import (
+	"context"
+	"slices"
+	"time"
+	"unicode/utf8"
+
+	"github.com/Oj-washingtone/savannah-store/internal/model"
+	"github.com/Oj-washingtone/savannah-store/internal/repocitory"
+	"github.com/google/uuid"
+)
+
+type notificationOutboxRepository struct {
+	s *store
+}
+
+func copyNotification(n *model.OutboxNotification) *model.OutboxNotification {
+	c := *n
+	c.Attachments = slices.Clone(n.Attachments)
+	c.SentAt = copyOf(n.SentAt)
+	return &c
+}
+
+func (r *notificationOutboxRepository) Add(ctx context.Context, n *model.OutboxNotification) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	if _, ok := r.s.outbox[n.ID]; ok {
+		return repocitory.ConflictError("notification_outbox_pkey")
+	}
+
+	switch n.Channel {
+	case model.NotificationEmail, model.NotificationSMS:
+	default:
+		return repocitory.NotAllowedError()
+	}
+
+	if utf8.RuneCountInString(n.Recipient) > 150 || n.Attempts < 0 {
+		return repocitory.NotAllowedError()
+	}
+
+	if n.Attachments == nil {
+		n.Attachments = []model.NotificationAttachment{}
+	}
+
+	if n.Attempts == 0 {
+		n.Attempts = 1
+	}
+
+	n.CreatedAt = r.s.now()
+	n.UpdatedAt = n.CreatedAt
+
+	r.s.outbox[n.ID] = copyNotification(n)
+
+	return nil
+}
+
+func (r *notificationOutboxRepository) ListUnsent(ctx context.Context, maxAttempts, limit int) ([]*model.OutboxNotification, error) {
+	r.s.mu.RLock()
+	defer r.s.mu.RUnlock()
+
+	var notifications []*model.OutboxNotification
+	for _, n := range r.s.outbox {
+		if n.SentAt == nil && n.Attempts < maxAttempts {
+			notifications = append(notifications, copyNotification(n))
+		}
+	}
+
+	oldestFirst(notifications)
+
+	if len(notifications) > limit {
+		notifications = notifications[:limit]
+	}
+
+	return notifications, nil
+}
+
+func (r *notificationOutboxRepository) MarkSent(ctx context.Context, id uuid.UUID) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	if n, ok := r.s.outbox[id]; ok {
+		now := r.s.now()
+		n.SentAt = &now
+		n.UpdatedAt = now
+	}
+
+	return nil
+}
+
+func (r *notificationOutboxRepository) RecordFailure(ctx context.Context, id uuid.UUID, lastError string) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	if n, ok := r.s.outbox[id]; ok {
+		n.Attempts++
+		n.LastError = lastError
+		n.UpdatedAt = r.s.now()
+	}
+
+	return nil
+}
+
+func (r *notificationOutboxRepository) OldestUnsent(ctx context.Context, maxAttempts int) (*time.Time, error) {
+	r.s.mu.RLock()
+	defer r.s.mu.RUnlock()
+
+	var oldest *time.Time
+	for _, n := range r.s.outbox {
+		if n.SentAt == nil && n.Attempts < maxAttempts && (oldest == nil || n.CreatedAt.Before(*oldest)) {
+			oldest = &n.CreatedAt
+		}
+	}
+
+	return copyOf(oldest), nil
+}
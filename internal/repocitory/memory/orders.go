@@ -0,0 +1,267 @@
+package memory
+
+import (
+	"context"
+	"slices"
+
+	"github.com/Oj-washingtone/savannah-store/internal/model"
+	"github.com/Oj-washingtone/savannah-store/internal/pagination"
+	"github.com/Oj-washingtone/savannah-store/internal/repocitory"
+	"github.com/google/uuid"
+)
+
+type ordersRepository struct {
+	s *store
+}
+
+func copyOrder(o *model.Orders) *model.Orders {
+	order := *o
+	order.PaidAt = copyOf(o.PaidAt)
+	order.PaymentReference = copyOf(o.PaymentReference)
+	order.InvoiceNumber = copyOf(o.InvoiceNumber)
+	return &order
+}
+
+// Create stores a new pending order and returns it as stored.
+func (r *ordersRepository) Create(ctx context.Context, order *model.Orders) (*model.Orders, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	return r.s.createOrder(order)
+}
+
+// Place stores the order with its items and promotion redemptions and empties
+// the cart, or, if any of it is refused, none of it.
+func (r *ordersRepository) Place(ctx context.Context, checkout *repocitory.Checkout) (*model.Orders, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	order, err := r.s.createOrder(checkout.Order)
+	if err != nil {
+		return nil, err
+	}
+
+	undo := func() {
+		for id, redemption := range r.s.redemptions {
+			if redemption.OrderID == order.ID {
+				delete(r.s.redemptions, id)
+			}
+		}
+		delete(r.s.orders, order.ID)
+	}
+
+	for _, redemption := range checkout.Redemptions {
+		if err := r.s.redeem(redemption); err != nil {
+			undo()
+			return nil, err
+		}
+	}
+
+	if err := r.s.createOrderItems(checkout.Items); err != nil {
+		undo()
+		return nil, err
+	}
+
+	r.s.clearCart(checkout.CartID)
+
+	r.s.cartPromotions = slices.DeleteFunc(r.s.cartPromotions, func(cp cartPromotion) bool {
+		return cp.cartId == checkout.CartID
+	})
+
+	return order, nil
+}
+
+func (s *store) createOrder(order *model.Orders) (*model.Orders, error) {
+	if _, ok := s.orders[order.ID]; ok {
+		return nil, repocitory.ConflictError("orders_pkey")
+	}
+
+	if order.Subtotal < 0 || order.DiscountTotal < 0 || order.TaxTotal < 0 || order.Total < 0 {
+		return nil, repocitory.NotAllowedError()
+	}
+
+	if _, ok := s.users[order.UserID]; !ok {
+		return nil, repocitory.MissingReferenceError()
+	}
+
+	stored := &model.Orders{
+		BaseModel:        order.BaseModel,
+		UserID:           order.UserID,
+		Status:           model.StatusPending,
+		Subtotal:         order.Subtotal,
+		DiscountTotal:    order.DiscountTotal,
+		TaxTotal:         order.TaxTotal,
+		Total:            order.Total,
+		Paid:             order.Paid,
+		PricesIncludeTax: order.PricesIncludeTax,
+	}
+	stored.CreatedAt = s.now()
+	stored.UpdatedAt = stored.CreatedAt
+
+	s.orders[order.ID] = stored
+
+	return copyOrder(stored), nil
+}
+
+func (r *ordersRepository) GetById(ctx context.Context, id uuid.UUID) (*model.Orders, error) {
+	r.s.mu.RLock()
+	defer r.s.mu.RUnlock()
+
+	order, ok := r.s.orders[id]
+	if !ok {
+		return nil, repocitory.NotFoundError("order")
+	}
+
+	return copyOrder(order), nil
+}
+
+func (r *ordersRepository) GetByUser(ctx context.Context, userId uuid.UUID) ([]*model.Orders, error) {
+	r.s.mu.RLock()
+	defer r.s.mu.RUnlock()
+
+	var orders []*model.Orders
+	for _, order := range r.s.orders {
+		if order.UserID == userId {
+			orders = append(orders, copyOrder(order))
+		}
+	}
+
+	newestFirst(orders)
+
+	return orders, nil
+}
+
+func (r *ordersRepository) UpdateStatus(ctx context.Context, orderId uuid.UUID, status model.OrderStatus) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	if order, ok := r.s.orders[orderId]; ok {
+		order.Status = status
+		order.UpdatedAt = r.s.now()
+	}
+
+	return nil
+}
+
+// MarkPaid records the payment of an order and gives it the next invoice
+// number. A failed payment never uses up a number, so invoice numbers have no
+// gaps.
+func (r *ordersRepository) MarkPaid(ctx context.Context, orderId uuid.UUID, paymentReference string) (*model.Orders, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	order, ok := r.s.orders[orderId]
+	if !ok {
+		return nil, repocitory.NotFoundError("order")
+	}
+
+	if order.Paid {
+		return nil, repocitory.ErrOrderAlreadyPaid
+	}
+
+	if order.Status == model.StatusCancelled {
+		return nil, repocitory.ErrOrderCancelled
+	}
+
+	r.s.invoiceNumber++
+
+	paidAt := r.s.now()
+	invoiceNumber := r.s.invoiceNumber
+
+	order.Paid = true
+	order.PaidAt = &paidAt
+	order.PaymentReference = &paymentReference
+	order.InvoiceNumber = &invoiceNumber
+	order.UpdatedAt = paidAt
+
+	return copyOrder(order), nil
+}
+
+func (r *ordersRepository) GetAll(ctx context.Context, page pagination.Page) ([]*model.Orders, *pagination.Info, error) {
+	r.s.mu.RLock()
+	defer r.s.mu.RUnlock()
+
+	var orders []*model.Orders
+	for _, order := range r.s.orders {
+		orders = append(orders, copyOrder(order))
+	}
+
+	orders, info := paginate(page, orders)
+	return orders, info, nil
+}
+
+type orderItemsRepository struct {
+	s *store
+}
+
+func (r *orderItemsRepository) Create(ctx context.Context, item *model.OrderItems) error {
+	return r.CreateBulk(ctx, []*model.OrderItems{item})
+}
+
+// CreateBulk stores all the items or, if one of them is refused, none.
+func (r *orderItemsRepository) CreateBulk(ctx context.Context, items []*model.OrderItems) error {
+	if len(items) == 0 {
+		return nil
+	}
+
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	return r.s.createOrderItems(items)
+}
+
+func (s *store) createOrderItems(items []*model.OrderItems) error {
+	seen := map[uuid.UUID]bool{}
+	for _, item := range items {
+		if _, ok := s.orderItems[item.ID]; ok || seen[item.ID] {
+			return repocitory.ConflictError("order_items_pkey")
+		}
+		seen[item.ID] = true
+
+		switch item.TaxClass {
+		case model.TaxStandard, model.TaxZeroRated, model.TaxExempt:
+		default:
+			return repocitory.NotAllowedError()
+		}
+
+		if item.Quantity <= 0 || item.Price < 0 || item.Discount < 0 || item.Tax < 0 {
+			return repocitory.NotAllowedError()
+		}
+
+		_, orderOk := s.orders[item.OrderID]
+		_, productOk := s.products[item.ProductID]
+		_, variantOk := s.variants[item.VariantID]
+
+		if !orderOk || !productOk || !variantOk {
+			return repocitory.MissingReferenceError()
+		}
+	}
+
+	createdAt := s.now()
+
+	for _, item := range items {
+		stored := *item
+		stored.CreatedAt = createdAt
+		stored.UpdatedAt = createdAt
+		s.orderItems[item.ID] = &stored
+	}
+
+	return nil
+}
+
+func (r *orderItemsRepository) GetByOrder(ctx context.Context, orderId uuid.UUID) ([]*model.OrderItems, error) {
+	r.s.mu.RLock()
+	defer r.s.mu.RUnlock()
+
+	var items []*model.OrderItems
+	for _, item := range r.s.orderItems {
+		if item.OrderID == orderId {
+			i := *item
+			items = append(items, &i)
+		}
+	}
+
+	oldestFirst(items)
+
+	return items, nil
+}
@@ -0,0 +1,376 @@
+package memory
+
+import (
+	"context"
+	"slices"
+	"time"
+	"unicode/utf8"
+
+	"github.com/Oj-washingtone/savannah-store/internal/model"
+	"github.com/Oj-washingtone/savannah-store/internal/pagination"
+	"github.com/Oj-washingtone/savannah-store/internal/repocitory"
+	"github.com/google/uuid"
+)
+
+type productRepository struct {
+	s *store
+}
+
+// activeDiscount is the discount that applies to the product at the given
+// time, picked in the order of activeDiscountOrder in the Postgres
+// repository.
+func (s *store) activeDiscount(productId uuid.UUID, at time.Time) *model.Discount {
+	var running []*model.Discount
+	for _, d := range s.discounts {
+		if d.deleted || d.value.ProductID != productId {
+			continue
+		}
+
+		if d.value.RunningAt(at) {
+			running = append(running, &d.value)
+		}
+	}
+
+	if len(running) == 0 {
+		return nil
+	}
+
+	slices.SortFunc(running, compareDiscounts)
+
+	discount := copyDiscount(running[0])
+	return &discount
+}
+
+// product gives a copy of a stored product with its sale pricing applied.
+func (s *store) product(p *row[model.Product]) *model.Product {
+	product := p.value
+	product.SKU = copyOf(p.value.SKU)
+	product.ApplyDiscount(s.activeDiscount(product.ID, time.Now()))
+
+	return &product
+}
+
+// syncProductStock keeps the product's stock at the total stock of its live
+// variants.
+func (s *store) syncProductStock(productId uuid.UUID, updatedAt time.Time) {
+	p, ok := s.products[productId]
+	if !ok {
+		return
+	}
+
+	stock := 0
+	for _, v := range s.variants {
+		if !v.deleted && v.value.ProductID == productId {
+			stock += v.value.Stock
+		}
+	}
+
+	p.value.Stock = stock
+	p.value.UpdatedAt = updatedAt
+}
+
+// checkProduct holds a product to the constraints of the products table.
+func (s *store) checkProduct(product *model.Product) error {
+	if utf8.RuneCountInString(product.Name) > 100 ||
+		utf8.RuneCountInString(product.Slug) > 120 ||
+		(product.SKU != nil && utf8.RuneCountInString(*product.SKU) > 64) ||
+		utf8.RuneCountInString(product.MetaTitle) > model.MaxMetaTitleLength ||
+		utf8.RuneCountInString(product.MetaDescription) > model.MaxMetaDescriptionLength ||
+		product.Price < 0 {
+		return repocitory.NotAllowedError()
+	}
+
+	switch product.TaxClass {
+	case model.TaxStandard, model.TaxZeroRated, model.TaxExempt:
+	default:
+		return repocitory.NotAllowedError()
+	}
+
+	if _, ok := s.categories[product.CategoryID]; !ok {
+		return repocitory.MissingReferenceError()
+	}
+
+	for _, p := range s.products {
+		if p.deleted || p.value.ID == product.ID {
+			continue
+		}
+
+		if p.value.Slug == product.Slug {
+			return repocitory.ConflictError("idx_products_slug")
+		}
+
+		if sameString(p.value.SKU, product.SKU) {
+			return repocitory.ConflictError("idx_products_sku")
+		}
+	}
+
+	return nil
+}
+
+// Create adds the product together with its default variant, see the
+// Postgres repository.
+func (r *productRepository) Create(ctx context.Context, product *model.Product) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	if _, ok := r.s.products[product.ID]; ok {
+		return repocitory.ConflictError("products_pkey")
+	}
+
+	if err := r.s.checkProduct(product); err != nil {
+		return err
+	}
+
+	if product.Stock < 0 {
+		return repocitory.NotAllowedError()
+	}
+
+	product.CreatedAt = r.s.now()
+	product.UpdatedAt = product.CreatedAt
+
+	stored := model.Product{
+		BaseModel:   product.BaseModel,
+		CategoryID:  product.CategoryID,
+		Name:        product.Name,
+		Slug:        product.Slug,
+		SKU:         copyOf(product.SKU),
+		Description: product.Description,
+		Price:       product.Price,
+		Stock:       product.Stock,
+		TaxClass:    product.TaxClass,
+		SEO:         product.SEO,
+	}
+
+	r.s.products[product.ID] = &row[model.Product]{value: stored}
+
+	variant := model.ProductVariant{ProductID: product.ID, Stock: product.Stock, Options: map[string]string{}}
+	variant.ID = uuid.New()
+	variant.CreatedAt = product.CreatedAt
+	variant.UpdatedAt = product.CreatedAt
+
+	r.s.variants[variant.ID] = &row[model.ProductVariant]{value: variant}
+
+	delete(r.s.slugHistory, product.Slug)
+
+	return nil
+}
+
+func (r *productRepository) GetById(ctx context.Context, id uuid.UUID) (*model.Product, error) {
+	r.s.mu.RLock()
+	defer r.s.mu.RUnlock()
+
+	p, ok := r.s.products[id]
+	if !ok || p.deleted {
+		return nil, repocitory.NotFoundError("product")
+	}
+
+	return r.s.product(p), nil
+}
+
+func (r *productRepository) GetBySlug(ctx context.Context, slug string) (*model.Product, error) {
+	r.s.mu.RLock()
+	defer r.s.mu.RUnlock()
+
+	for _, p := range r.s.products {
+		if !p.deleted && p.value.Slug == slug {
+			return r.s.product(p), nil
+		}
+	}
+
+	return nil, repocitory.NotFoundError("product")
+}
+
+// GetBySKU finds the product with the SKU, or the product one of whose
+// variants has it.
+func (r *productRepository) GetBySKU(ctx context.Context, sku string) (*model.Product, error) {
+	r.s.mu.RLock()
+	defer r.s.mu.RUnlock()
+
+	for _, p := range r.s.products {
+		if !p.deleted && p.value.SKU != nil && *p.value.SKU == sku {
+			return r.s.product(p), nil
+		}
+	}
+
+	for _, v := range r.s.variants {
+		if v.deleted || v.value.SKU == nil || *v.value.SKU != sku {
+			continue
+		}
+
+		if p, ok := r.s.products[v.value.ProductID]; ok && !p.deleted {
+			return r.s.product(p), nil
+		}
+	}
+
+	return nil, repocitory.NotFoundError("product")
+}
+
+func (r *productRepository) SlugRedirect(ctx context.Context, oldSlug string) (string, error) {
+	r.s.mu.RLock()
+	defer r.s.mu.RUnlock()
+
+	redirect, ok := r.s.slugHistory[oldSlug]
+	if !ok {
+		return "", repocitory.NotFoundError("product")
+	}
+
+	p, ok := r.s.products[redirect.productId]
+	if !ok || p.deleted {
+		return "", repocitory.NotFoundError("product")
+	}
+
+	return p.value.Slug, nil
+}
+
+func (r *productRepository) SlugTaken(ctx context.Context, slug string, productId uuid.UUID) (bool, error) {
+	r.s.mu.RLock()
+	defer r.s.mu.RUnlock()
+
+	for _, p := range r.s.products {
+		if !p.deleted && p.value.ID != productId && p.value.Slug == slug {
+			return true, nil
+		}
+	}
+
+	if redirect, ok := r.s.slugHistory[slug]; ok && redirect.productId != productId {
+		if p, ok := r.s.products[redirect.productId]; ok && !p.deleted {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
+func (r *productRepository) SKUTaken(ctx context.Context, sku string, productId uuid.UUID) (bool, error) {
+	r.s.mu.RLock()
+	defer r.s.mu.RUnlock()
+
+	return r.s.skuTaken(sku, productId, uuid.Nil, productId), nil
+}
+
+// skuTaken reports whether a live product other than exceptProduct, or a
+// live variant other than exceptVariant and not of variantsOf, has the SKU.
+func (s *store) skuTaken(sku string, exceptProduct, exceptVariant, variantsOf uuid.UUID) bool {
+	for _, p := range s.products {
+		if !p.deleted && p.value.ID != exceptProduct && p.value.SKU != nil && *p.value.SKU == sku {
+			return true
+		}
+	}
+
+	for _, v := range s.variants {
+		if v.deleted || v.value.ID == exceptVariant || v.value.ProductID == variantsOf {
+			continue
+		}
+
+		if v.value.SKU != nil && *v.value.SKU == sku {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (r *productRepository) List(ctx context.Context, page pagination.Page) ([]model.Product, *pagination.Info, error) {
+	return r.list(page, func(p *model.Product) bool { return true })
+}
+
+func (r *productRepository) ListByCategory(ctx context.Context, categoryId uuid.UUID, page pagination.Page) ([]model.Product, *pagination.Info, error) {
+	r.s.mu.RLock()
+	tree := r.s.categoryDescendants(categoryId)
+	r.s.mu.RUnlock()
+
+	return r.list(page, func(p *model.Product) bool { return tree[p.CategoryID] })
+}
+
+// list pages through the live products matching filter.
+func (r *productRepository) list(page pagination.Page, filter func(p *model.Product) bool) ([]model.Product, *pagination.Info, error) {
+	r.s.mu.RLock()
+	defer r.s.mu.RUnlock()
+
+	products := []model.Product{}
+	for _, p := range r.s.products {
+		if !p.deleted && filter(&p.value) {
+			products = append(products, *r.s.product(p))
+		}
+	}
+
+	products, info := paginate(page, products)
+	return products, info, nil
+}
+
+// Update saves the product details, leaving the stock alone and keeping the
+// old slug in the slug history when it changes.
+func (r *productRepository) Update(ctx context.Context, product *model.Product) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	return r.s.updateProduct(product)
+}
+
+// UpdateWithStock saves the product like Update and the stock of variant,
+// the single variant of a simple product, all or nothing. product.Stock
+// becomes the new stock.
+func (r *productRepository) UpdateWithStock(ctx context.Context, product *model.Product, variant *model.ProductVariant) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	v, ok := r.s.variants[variant.ID]
+	if !ok || v.deleted || v.value.ProductID != product.ID || len(r.s.variantValues[variant.ID]) > 0 {
+		return repocitory.NotFoundError("variant")
+	}
+
+	if err := r.s.updateProduct(product); err != nil {
+		return err
+	}
+
+	variant.UpdatedAt = product.UpdatedAt
+	v.value.Stock = variant.Stock
+	v.value.UpdatedAt = variant.UpdatedAt
+
+	r.s.syncProductStock(product.ID, product.UpdatedAt)
+	product.Stock = variant.Stock
+
+	return nil
+}
+
+func (s *store) updateProduct(product *model.Product) error {
+	p, ok := s.products[product.ID]
+	if !ok || p.deleted {
+		return repocitory.NotFoundError("product")
+	}
+
+	if err := s.checkProduct(product); err != nil {
+		return err
+	}
+
+	oldSlug := p.value.Slug
+	product.UpdatedAt = s.now()
+
+	p.value.CategoryID = product.CategoryID
+	p.value.Name = product.Name
+	p.value.Slug = product.Slug
+	p.value.SKU = copyOf(product.SKU)
+	p.value.Description = product.Description
+	p.value.Price = product.Price
+	p.value.TaxClass = product.TaxClass
+	p.value.SEO = product.SEO
+	p.value.UpdatedAt = product.UpdatedAt
+
+	if oldSlug != product.Slug {
+		s.slugHistory[oldSlug] = slugRedirect{productId: product.ID, createdAt: product.UpdatedAt}
+		delete(s.slugHistory, product.Slug)
+	}
+
+	return nil
+}
+
+func (r *productRepository) Delete(ctx context.Context, id uuid.UUID) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	if p, ok := r.s.products[id]; ok {
+		p.deleted = true
+	}
+
+	return nil
+}
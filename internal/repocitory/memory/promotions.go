@@ -0,0 +1,352 @@
+package memory
+
+import (
+	"cmp"
+	"context"
+	"slices"
+	"time"
+	"unicode/utf8"
+
+	"github.com/Oj-washingtone/savannah-store/internal/model"
+	"github.com/Oj-washingtone/savannah-store/internal/pagination"
+	"github.com/Oj-washingtone/savannah-store/internal/repocitory"
+	"github.com/google/uuid"
+)
+
+type promotionRepository struct {
+	s *store
+}
+
+func copyPromotion(p *model.Promotion) *model.Promotion {
+	promotion := *p
+	promotion.Code = copyOf(p.Code)
+	promotion.ProductID = copyOf(p.ProductID)
+	promotion.CategoryID = copyOf(p.CategoryID)
+	promotion.StartsAt = copyOf(p.StartsAt)
+	promotion.EndsAt = copyOf(p.EndsAt)
+	promotion.UsageLimit = copyOf(p.UsageLimit)
+	promotion.PerCustomerLimit = copyOf(p.PerCustomerLimit)
+	return &promotion
+}
+
+// checkPromotion holds a promotion to the constraints of the promotions
+// table.
+func (s *store) checkPromotion(promotion *model.Promotion) error {
+	if promotion.Code != nil && utf8.RuneCountInString(*promotion.Code) > 50 {
+		return repocitory.NotAllowedError()
+	}
+
+	switch promotion.Type {
+	case model.DiscountPercentage, model.DiscountFixed:
+	default:
+		return repocitory.NotAllowedError()
+	}
+
+	if promotion.Value <= 0 || (promotion.Type == model.DiscountPercentage && promotion.Value > 100) {
+		return repocitory.NotAllowedError()
+	}
+
+	switch {
+	case promotion.Scope == model.PromotionScopeCart && promotion.ProductID == nil && promotion.CategoryID == nil:
+	case promotion.Scope == model.PromotionScopeProduct && promotion.ProductID != nil:
+	case promotion.Scope == model.PromotionScopeCategory && promotion.CategoryID != nil:
+	default:
+		return repocitory.NotAllowedError()
+	}
+
+	if promotion.ProductID != nil {
+		if _, ok := s.products[*promotion.ProductID]; !ok {
+			return repocitory.MissingReferenceError()
+		}
+	}
+
+	if promotion.CategoryID != nil {
+		if _, ok := s.categories[*promotion.CategoryID]; !ok {
+			return repocitory.MissingReferenceError()
+		}
+	}
+
+	for _, p := range s.promotions {
+		if p.value.ID != promotion.ID && sameString(p.value.Code, promotion.Code) {
+			return repocitory.ConflictError("promotions_code_key")
+		}
+	}
+
+	return nil
+}
+
+func (r *promotionRepository) Create(ctx context.Context, promotion *model.Promotion) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	if _, ok := r.s.promotions[promotion.ID]; ok {
+		return repocitory.ConflictError("promotions_pkey")
+	}
+
+	if err := r.s.checkPromotion(promotion); err != nil {
+		return err
+	}
+
+	promotion.CreatedAt = r.s.now()
+	promotion.UpdatedAt = promotion.CreatedAt
+
+	r.s.promotions[promotion.ID] = &row[model.Promotion]{value: *copyPromotion(promotion)}
+
+	return nil
+}
+
+func (r *promotionRepository) GetById(ctx context.Context, id uuid.UUID) (*model.Promotion, error) {
+	r.s.mu.RLock()
+	defer r.s.mu.RUnlock()
+
+	p, ok := r.s.promotions[id]
+	if !ok || p.deleted {
+		return nil, repocitory.NotFoundError("promotion")
+	}
+
+	return copyPromotion(&p.value), nil
+}
+
+func (r *promotionRepository) GetByCode(ctx context.Context, code string) (*model.Promotion, error) {
+	r.s.mu.RLock()
+	defer r.s.mu.RUnlock()
+
+	for _, p := range r.s.promotions {
+		if !p.deleted && p.value.Code != nil && *p.value.Code == code {
+			return copyPromotion(&p.value), nil
+		}
+	}
+
+	return nil, repocitory.NotFoundError("promotion")
+}
+
+func (r *promotionRepository) List(ctx context.Context, page pagination.Page) ([]*model.Promotion, *pagination.Info, error) {
+	r.s.mu.RLock()
+	defer r.s.mu.RUnlock()
+
+	var promotions []*model.Promotion
+	for _, p := range r.s.promotions {
+		if !p.deleted {
+			promotions = append(promotions, copyPromotion(&p.value))
+		}
+	}
+
+	promotions, info := paginate(page, promotions)
+	return promotions, info, nil
+}
+
+// ListAutomatic returns the active promotions that need no code and are
+// running at the given time.
+func (r *promotionRepository) ListAutomatic(ctx context.Context, at time.Time) ([]*model.Promotion, error) {
+	r.s.mu.RLock()
+	defer r.s.mu.RUnlock()
+
+	var promotions []*model.Promotion
+	for _, p := range r.s.promotions {
+		promotion := &p.value
+
+		if p.deleted || !promotion.Active || promotion.Code != nil {
+			continue
+		}
+
+		if (promotion.StartsAt == nil || !promotion.StartsAt.After(at)) && (promotion.EndsAt == nil || promotion.EndsAt.After(at)) {
+			promotions = append(promotions, copyPromotion(promotion))
+		}
+	}
+
+	oldestFirst(promotions)
+
+	return promotions, nil
+}
+
+func (r *promotionRepository) Update(ctx context.Context, promotion *model.Promotion) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	p, ok := r.s.promotions[promotion.ID]
+	if !ok {
+		return repocitory.NotFoundError("promotion")
+	}
+
+	if err := r.s.checkPromotion(promotion); err != nil {
+		return err
+	}
+
+	promotion.UpdatedAt = r.s.now()
+
+	updated := copyPromotion(promotion)
+	updated.CreatedAt = p.value.CreatedAt
+	p.value = *updated
+
+	return nil
+}
+
+// Delete soft deletes the promotion and frees its code for reuse.
+func (r *promotionRepository) Delete(ctx context.Context, id uuid.UUID) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	if p, ok := r.s.promotions[id]; ok && !p.deleted {
+		p.deleted = true
+		p.value.Code = nil
+		p.value.Active = false
+	}
+
+	return nil
+}
+
+// usage counts the redemptions of the promotion, in total and by the user.
+func (s *store) usage(promotionId, userId uuid.UUID) (total int, byUser int) {
+	for _, redemption := range s.redemptions {
+		if redemption.PromotionID != promotionId {
+			continue
+		}
+
+		total++
+		if redemption.UserID == userId {
+			byUser++
+		}
+	}
+
+	return total, byUser
+}
+
+func (r *promotionRepository) Usage(ctx context.Context, promotionId, userId uuid.UUID) (int, int, error) {
+	r.s.mu.RLock()
+	defer r.s.mu.RUnlock()
+
+	total, byUser := r.s.usage(promotionId, userId)
+	return total, byUser, nil
+}
+
+// Redeem records a promotion use against an order, or returns
+// ErrPromotionExhausted when a usage limit has been reached. The store's
+// lock stands in for the row lock the Postgres repository takes.
+func (r *promotionRepository) Redeem(ctx context.Context, redemption *model.PromotionRedemption) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	return r.s.redeem(redemption)
+}
+
+func (s *store) redeem(redemption *model.PromotionRedemption) error {
+	p, ok := s.promotions[redemption.PromotionID]
+	if !ok {
+		return repocitory.NotFoundError("promotion")
+	}
+
+	total, byUser := s.usage(redemption.PromotionID, redemption.UserID)
+
+	if (p.value.UsageLimit != nil && total >= *p.value.UsageLimit) || (p.value.PerCustomerLimit != nil && byUser >= *p.value.PerCustomerLimit) {
+		return repocitory.ErrPromotionExhausted
+	}
+
+	if _, ok := s.redemptions[redemption.ID]; ok {
+		return repocitory.ConflictError("promotion_redemptions_pkey")
+	}
+
+	if redemption.Code != nil && utf8.RuneCountInString(*redemption.Code) > 50 {
+		return repocitory.NotAllowedError()
+	}
+
+	if _, ok := s.orders[redemption.OrderID]; !ok {
+		return repocitory.MissingReferenceError()
+	}
+
+	if _, ok := s.users[redemption.UserID]; !ok {
+		return repocitory.MissingReferenceError()
+	}
+
+	redemption.CreatedAt = s.now()
+	redemption.UpdatedAt = redemption.CreatedAt
+
+	stored := *redemption
+	stored.Code = copyOf(redemption.Code)
+	s.redemptions[redemption.ID] = &stored
+
+	return nil
+}
+
+func (r *promotionRepository) ReleaseRedemptions(ctx context.Context, orderId uuid.UUID) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	for id, redemption := range r.s.redemptions {
+		if redemption.OrderID == orderId {
+			delete(r.s.redemptions, id)
+		}
+	}
+
+	return nil
+}
+
+func (r *promotionRepository) AddToCart(ctx context.Context, cartId, promotionId uuid.UUID) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	if _, ok := r.s.carts[cartId]; !ok {
+		return repocitory.MissingReferenceError()
+	}
+
+	if _, ok := r.s.promotions[promotionId]; !ok {
+		return repocitory.MissingReferenceError()
+	}
+
+	for _, cp := range r.s.cartPromotions {
+		if cp.cartId == cartId && cp.promotionId == promotionId {
+			return nil
+		}
+	}
+
+	r.s.cartPromotions = append(r.s.cartPromotions, cartPromotion{cartId: cartId, promotionId: promotionId, createdAt: r.s.now()})
+
+	return nil
+}
+
+func (r *promotionRepository) RemoveFromCart(ctx context.Context, cartId, promotionId uuid.UUID) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	r.s.cartPromotions = slices.DeleteFunc(r.s.cartPromotions, func(cp cartPromotion) bool {
+		return cp.cartId == cartId && cp.promotionId == promotionId
+	})
+
+	return nil
+}
+
+// ListForCart returns the promotions whose codes were entered on the cart.
+func (r *promotionRepository) ListForCart(ctx context.Context, cartId uuid.UUID) ([]*model.Promotion, error) {
+	r.s.mu.RLock()
+	defer r.s.mu.RUnlock()
+
+	var entered []cartPromotion
+	for _, cp := range r.s.cartPromotions {
+		if cp.cartId == cartId {
+			entered = append(entered, cp)
+		}
+	}
+
+	slices.SortStableFunc(entered, func(a, b cartPromotion) int {
+		return cmp.Compare(a.createdAt.UnixMicro(), b.createdAt.UnixMicro())
+	})
+
+	var promotions []*model.Promotion
+	for _, cp := range entered {
+		if p, ok := r.s.promotions[cp.promotionId]; ok && !p.deleted {
+			promotions = append(promotions, copyPromotion(&p.value))
+		}
+	}
+
+	return promotions, nil
+}
+
+func (r *promotionRepository) ClearCart(ctx context.Context, cartId uuid.UUID) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	r.s.cartPromotions = slices.DeleteFunc(r.s.cartPromotions, func(cp cartPromotion) bool {
+		return cp.cartId == cartId
+	})
+
+	return nil
+}
@@ -0,0 +1,311 @@
+package memory
+
+import (
+	"cmp"
+	"context"
+	"slices"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/Oj-washingtone/savannah-store/internal/model"
+	"github.com/Oj-washingtone/savannah-store/internal/pagination"
+	"github.com/google/uuid"
+)
+
+// stopWords are left out of queries, as Postgres' english text search
+// configuration does.
+var stopWords = map[string]bool{
+	"a": true, "an": true, "and": true, "are": true, "as": true, "at": true, "be": true, "by": true,
+	"for": true, "from": true, "in": true, "is": true, "it": true, "of": true, "on": true, "or": true,
+	"the": true, "to": true, "with": true,
+}
+
+// words splits text into lower case words.
+func words(text string) []string {
+	return strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
+
+// stem cuts the common English endings off a word, a rough stand in for the
+// Snowball stemmer Postgres uses.
+func stem(word string) string {
+	for _, suffix := range []string{"ing", "es", "ed", "s"} {
+		if len(word) > len(suffix)+2 && strings.HasSuffix(word, suffix) {
+			return strings.TrimSuffix(word, suffix)
+		}
+	}
+	return word
+}
+
+// trigrams are the trigrams pg_trgm makes of a word.
+func trigrams(word string) map[string]bool {
+	padded := []rune("  " + word + " ")
+
+	set := map[string]bool{}
+	for i := 0; i+3 <= len(padded); i++ {
+		set[string(padded[i:i+3])] = true
+	}
+	return set
+}
+
+// wordSimilarity is how close query comes to a run of words in text, close
+// to pg_trgm's word_similarity.
+func wordSimilarity(query, text string) float64 {
+	queryWords, textWords := words(query), words(text)
+	if len(queryWords) == 0 || len(textWords) == 0 {
+		return 0
+	}
+
+	queryTrigrams := trigrams(strings.Join(queryWords, " "))
+
+	best := 0.0
+	for i := range textWords {
+		for j := i + 1; j <= len(textWords) && j-i <= len(queryWords); j++ {
+			textTrigrams := trigrams(strings.Join(textWords[i:j], " "))
+
+			shared := 0
+			for t := range queryTrigrams {
+				if textTrigrams[t] {
+					shared++
+				}
+			}
+
+			best = max(best, float64(shared)/float64(len(queryTrigrams)+len(textTrigrams)-shared))
+		}
+	}
+
+	return best
+}
+
+// textRank is how well the product's words match every word of the query,
+// with name matches counting more than description ones like the weights of
+// the search vector. It is 0 if a word of the query is missing.
+func textRank(query []string, p *model.Product) float64 {
+	if len(query) == 0 {
+		return 0
+	}
+
+	name, description := map[string]bool{}, map[string]bool{}
+	for _, w := range words(p.Name) {
+		name[stem(w)] = true
+	}
+	for _, w := range words(p.Description) {
+		description[stem(w)] = true
+	}
+
+	rank := 0.0
+	for _, w := range query {
+		switch {
+		case name[w]:
+			rank += 1
+		case description[w]:
+			rank += 0.4
+		default:
+			return 0
+		}
+	}
+
+	return rank / float64(len(query))
+}
+
+// searchTerms are the stemmed words of the query without the stop words.
+func searchTerms(query string) []string {
+	var terms []string
+	for _, w := range words(query) {
+		if !stopWords[w] {
+			terms = append(terms, stem(w))
+		}
+	}
+	return terms
+}
+
+// matches reports whether the product passes the search's filters, leaving
+// out the category or price filters for the facets. tree holds the ids of
+// the searched category and its subcategories.
+func matches(s *model.ProductSearch, p *model.Product, terms []string, tree map[uuid.UUID]bool, skipCategory, skipPrice bool) bool {
+	if s.Query != "" && textRank(terms, p) == 0 && wordSimilarity(s.Query, p.Name) <= 0.6 {
+		return false
+	}
+
+	if s.CategoryID != nil && !skipCategory && !tree[p.CategoryID] {
+		return false
+	}
+
+	if !skipPrice {
+		if s.MinPrice != nil && p.DiscountedPrice < *s.MinPrice {
+			return false
+		}
+
+		if s.MaxPrice != nil && p.DiscountedPrice > *s.MaxPrice {
+			return false
+		}
+	}
+
+	if s.InStock && p.Stock <= 0 {
+		return false
+	}
+
+	if s.OnSale && p.Discount == nil {
+		return false
+	}
+
+	return true
+}
+
+// Search works like the Postgres search, with the text search done in Go:
+// words match on a rough stem, and names within trigram distance of the
+// query match too. Rankings and stemming can differ from Postgres for
+// unusual words.
+func (r *productRepository) Search(ctx context.Context, s *model.ProductSearch) (*model.ProductSearchResult, *pagination.Info, error) {
+	r.s.mu.RLock()
+	defer r.s.mu.RUnlock()
+
+	terms := searchTerms(s.Query)
+
+	var tree map[uuid.UUID]bool
+	if s.CategoryID != nil {
+		tree = r.s.categoryDescendants(*s.CategoryID)
+	}
+
+	var live []*model.Product
+	for _, p := range r.s.products {
+		if !p.deleted {
+			live = append(live, r.s.product(p))
+		}
+	}
+
+	result := &model.ProductSearchResult{}
+
+	var found []*model.Product
+	for _, p := range live {
+		if matches(s, p, terms, tree, false, false) {
+			found = append(found, p)
+		}
+	}
+
+	result.Total = len(found)
+
+	page, info, err := searchPage(s, terms, found)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	result.Products = page
+
+	if s.Page.WithTotal {
+		info.Total = &result.Total
+	}
+
+	result.Facets.Categories = r.categoryFacets(s, live, terms, tree)
+	result.Facets.PriceBuckets = priceFacets(s, live, terms, tree)
+
+	return result, info, nil
+}
+
+// searchPage picks the page of the products found in the order of the sort,
+// keyed as the Postgres search keys them. Relevance needs a query and falls
+// back to newest without one.
+func searchPage(s *model.ProductSearch, terms []string, found []*model.Product) ([]*model.Product, *pagination.Info, error) {
+	sorted := func(key func(p *model.Product) string) []pagination.Sorted[*model.Product] {
+		rows := make([]pagination.Sorted[*model.Product], len(found))
+		for i, p := range found {
+			rows[i] = pagination.Sorted[*model.Product]{Row: p, Key: key(p)}
+		}
+		return rows
+	}
+
+	price := func(p *model.Product) string {
+		return strconv.FormatInt(p.DiscountedPrice, 10)
+	}
+	parsePrice := func(key string) (int64, error) {
+		return strconv.ParseInt(key, 10, 64)
+	}
+
+	var rows []pagination.Sorted[*model.Product]
+	var info *pagination.Info
+	var err error
+
+	switch {
+	case s.Sort == model.SortPriceAsc:
+		rows, info, err = paginateBy(s.Page, sorted(price), false, parsePrice)
+	case s.Sort == model.SortPriceDesc:
+		rows, info, err = paginateBy(s.Page, sorted(price), true, parsePrice)
+	case s.Sort == model.SortName:
+		rows, info, err = paginateBy(s.Page, sorted(func(p *model.Product) string {
+			return p.Name
+		}), false, func(key string) (string, error) {
+			return key, nil
+		})
+	case s.Sort == model.SortNewest || s.Query == "":
+		page, info := paginate(s.Page, found)
+		return page, info, nil
+	default:
+		rows, info, err = paginateBy(s.Page, sorted(func(p *model.Product) string {
+			return strconv.FormatFloat(textRank(terms, p)+wordSimilarity(s.Query, p.Name), 'g', -1, 64)
+		}), true, func(key string) (float64, error) {
+			return strconv.ParseFloat(key, 64)
+		})
+	}
+
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return pagination.Rows(rows), info, nil
+}
+
+// categoryFacets counts the matches in each category they are directly in.
+func (r *productRepository) categoryFacets(s *model.ProductSearch, live []*model.Product, terms []string, tree map[uuid.UUID]bool) []*model.CategoryFacet {
+	counts := map[uuid.UUID]int{}
+	for _, p := range live {
+		if matches(s, p, terms, tree, true, false) {
+			counts[p.CategoryID]++
+		}
+	}
+
+	facets := []*model.CategoryFacet{}
+	for categoryId, count := range counts {
+		if c, ok := r.s.categories[categoryId]; ok {
+			facets = append(facets, &model.CategoryFacet{CategoryID: categoryId, Name: c.value.Name, Count: count})
+		}
+	}
+
+	slices.SortFunc(facets, func(a, b *model.CategoryFacet) int {
+		return cmp.Or(cmp.Compare(b.Count, a.Count), cmp.Compare(a.Name, b.Name))
+	})
+
+	return facets
+}
+
+// priceFacets counts the matches in each bucket of model.PriceBucketBounds,
+// including empty buckets.
+func priceFacets(s *model.ProductSearch, live []*model.Product, terms []string, tree map[uuid.UUID]bool) []*model.PriceBucketFacet {
+	bounds := model.PriceBucketBounds
+	facets := make([]*model.PriceBucketFacet, len(bounds)+1)
+
+	for i := range facets {
+		facets[i] = &model.PriceBucketFacet{}
+		if i > 0 {
+			facets[i].Min = bounds[i-1]
+		}
+		if i < len(bounds) {
+			upper := bounds[i]
+			facets[i].Max = &upper
+		}
+	}
+
+	for _, p := range live {
+		if !matches(s, p, terms, tree, false, true) {
+			continue
+		}
+
+		// the bucket is the number of bounds at or below the price, as
+		// width_bucket gives it
+		bucket, _ := slices.BinarySearch(bounds, p.DiscountedPrice+1)
+		facets[bucket].Count++
+	}
+
+	return facets
+}
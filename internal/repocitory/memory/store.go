@@ -0,0 +1,304 @@
+// Package memory keeps the repositories in memory instead of Postgres, for
+// tests and for running the API without a database. The repositories share
+// one store behind a single lock, so the ones that look across tables see a
+// consistent view, and they keep the same rules as the Postgres ones: soft
+// deletes, unique constraints, references and the errors for breaking them.
+// The contract tests in repotest keep the two in step.
+package memory
+
+import (
+	"bytes"
+	"cmp"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/Oj-washingtone/savannah-store/internal/model"
+	"github.com/Oj-washingtone/savannah-store/internal/pagination"
+	"github.com/Oj-washingtone/savannah-store/internal/repocitory"
+	"github.com/google/uuid"
+)
+
+// row is a stored value together with whether it was soft deleted.
+type row[T any] struct {
+	value   T
+	deleted bool
+}
+
+type slugRedirect struct {
+	productId uuid.UUID
+	createdAt time.Time
+}
+
+type cartPromotion struct {
+	cartId      uuid.UUID
+	promotionId uuid.UUID
+	createdAt   time.Time
+}
+
+type store struct {
+	mu sync.RWMutex
+
+	users      map[uuid.UUID]*model.User
+	categories map[uuid.UUID]*row[model.ProductCategory]
+
+	products      map[uuid.UUID]*row[model.Product]
+	slugHistory   map[string]slugRedirect
+	options       map[uuid.UUID]*model.ProductOption
+	variants      map[uuid.UUID]*row[model.ProductVariant]
+	variantValues map[uuid.UUID][]uuid.UUID
+	images        map[uuid.UUID]*row[model.ProductImage]
+	imageVariants map[uuid.UUID]*model.ProductImageVariant
+	discounts     map[uuid.UUID]*row[model.Discount]
+
+	// startAlerted holds the discounts whose start watchers were told of
+	startAlerted map[uuid.UUID]bool
+
+	promotions     map[uuid.UUID]*row[model.Promotion]
+	redemptions    map[uuid.UUID]*model.PromotionRedemption
+	cartPromotions []cartPromotion
+
+	carts         map[uuid.UUID]*row[model.Cart]
+	cartItems     map[uuid.UUID]*model.CartItem
+	cartReminders map[uuid.UUID]*model.CartReminder
+
+	orders        map[uuid.UUID]*model.Orders
+	orderItems    map[uuid.UUID]*model.OrderItems
+	invoiceNumber int64
+
+	wishlists     map[uuid.UUID]*row[model.Wishlist]
+	wishlistItems map[uuid.UUID]*model.WishlistItem
+
+	outbox map[uuid.UUID]*model.OutboxNotification
+
+	// clock is the last time handed out by now
+	clock time.Time
+}
+
+func newStore() *store {
+	return &store{
+		users:         map[uuid.UUID]*model.User{},
+		categories:    map[uuid.UUID]*row[model.ProductCategory]{},
+		products:      map[uuid.UUID]*row[model.Product]{},
+		slugHistory:   map[string]slugRedirect{},
+		options:       map[uuid.UUID]*model.ProductOption{},
+		variants:      map[uuid.UUID]*row[model.ProductVariant]{},
+		variantValues: map[uuid.UUID][]uuid.UUID{},
+		images:        map[uuid.UUID]*row[model.ProductImage]{},
+		imageVariants: map[uuid.UUID]*model.ProductImageVariant{},
+		discounts:     map[uuid.UUID]*row[model.Discount]{},
+		startAlerted:  map[uuid.UUID]bool{},
+		promotions:    map[uuid.UUID]*row[model.Promotion]{},
+		redemptions:   map[uuid.UUID]*model.PromotionRedemption{},
+		carts:         map[uuid.UUID]*row[model.Cart]{},
+		cartItems:     map[uuid.UUID]*model.CartItem{},
+		cartReminders: map[uuid.UUID]*model.CartReminder{},
+		orders:        map[uuid.UUID]*model.Orders{},
+		orderItems:    map[uuid.UUID]*model.OrderItems{},
+		wishlists:     map[uuid.UUID]*row[model.Wishlist]{},
+		wishlistItems: map[uuid.UUID]*model.WishlistItem{},
+		outbox:        map[uuid.UUID]*model.OutboxNotification{},
+	}
+}
+
+// NewRepositories gives a set of empty in-memory repositories sharing one
+// store.
+func NewRepositories() *repocitory.Repositories {
+	s := newStore()
+
+	return &repocitory.Repositories{
+		Users:         &userRepository{s},
+		Products:      &productRepository{s},
+		Variants:      &productVariantRepository{s},
+		Images:        &productImageRepository{s},
+		Categories:    &categoryRepository{s},
+		Discounts:     &discountRepository{s},
+		Promotions:    &promotionRepository{s},
+		Carts:         &shoppingCartRepository{s},
+		CartItems:     &cartItemsRepository{s},
+		CartReminders: &cartRemindersRepository{s},
+		Orders:        &ordersRepository{s},
+		OrderItems:    &orderItemsRepository{s},
+		Wishlists:     &wishlistRepository{s},
+		Outbox:        &notificationOutboxRepository{s},
+		Health:        &healthRepository{s},
+	}
+}
+
+// now is the time rows are stamped with, at the microsecond precision
+// Postgres keeps. Separate statements in Postgres practically never share a
+// timestamp, so the store's clock always moves on by at least a microsecond
+// to keep orders by created_at the same. Callers hold the write lock.
+func (s *store) now() time.Time {
+	t := time.Now().Truncate(time.Microsecond)
+	if !t.After(s.clock) {
+		t = s.clock.Add(time.Microsecond)
+	}
+
+	s.clock = t
+	return t
+}
+
+// compareKeys orders rows by created_at and then id, as Postgres compares
+// them.
+func compareKeys(aTime time.Time, aId uuid.UUID, bTime time.Time, bId uuid.UUID) int {
+	if c := aTime.Compare(bTime); c != 0 {
+		return c
+	}
+	return compareIds(aId, bId)
+}
+
+// compareIds orders ids byte by byte, as Postgres compares uuids.
+func compareIds(a, b uuid.UUID) int {
+	return bytes.Compare(a[:], b[:])
+}
+
+// newestFirst sorts rows by created_at and id, newest first.
+func newestFirst[T pagination.Keyed](rows []T) {
+	slices.SortFunc(rows, func(a, b T) int {
+		aTime, aId := a.PageKey()
+		bTime, bId := b.PageKey()
+		return compareKeys(bTime, bId, aTime, aId)
+	})
+}
+
+// oldestFirst sorts rows by created_at and id, oldest first.
+func oldestFirst[T pagination.Keyed](rows []T) {
+	slices.SortFunc(rows, func(a, b T) int {
+		aTime, aId := a.PageKey()
+		bTime, bId := b.PageKey()
+		return compareKeys(aTime, aId, bTime, bId)
+	})
+}
+
+// paginate picks the page of rows that pagination.Page.Keyset selects in
+// SQL, and trims it with pagination.Trim.
+func paginate[T pagination.Keyed](p pagination.Page, rows []T) ([]T, *pagination.Info) {
+	total := len(rows)
+	backwards := p.Cursor != nil && p.Cursor.Direction == pagination.Prev
+
+	var selected []T
+	for _, r := range rows {
+		if p.Cursor != nil {
+			createdAt, id := r.PageKey()
+			c := compareKeys(createdAt, id, p.Cursor.CreatedAt, p.Cursor.ID)
+
+			if (backwards && c <= 0) || (!backwards && c >= 0) {
+				continue
+			}
+		}
+
+		selected = append(selected, r)
+	}
+
+	if backwards {
+		oldestFirst(selected)
+	} else {
+		newestFirst(selected)
+	}
+
+	if len(selected) > p.Limit+1 {
+		selected = selected[:p.Limit+1]
+	}
+
+	page, info := pagination.Trim(p, selected)
+
+	if p.WithTotal {
+		info.Total = &total
+	}
+
+	return page, info
+}
+
+// paginateBy is paginate for rows ordered by their sort key, ascending or
+// descending, and then newest first, as pagination.Page.KeysetBy selects
+// them. parse reads a key back from its text, a key that does not parse
+// failing the way the cast does in Postgres.
+func paginateBy[T pagination.SortKeyed, K cmp.Ordered](p pagination.Page, rows []T, desc bool, parse func(key string) (K, error)) ([]T, *pagination.Info, error) {
+	type keyed struct {
+		row       T
+		key       K
+		createdAt time.Time
+		id        uuid.UUID
+	}
+
+	compare := func(a, b keyed) int {
+		c := cmp.Compare(a.key, b.key)
+		if desc {
+			c = -c
+		}
+		return cmp.Or(c, compareKeys(b.createdAt, b.id, a.createdAt, a.id))
+	}
+
+	all := make([]keyed, 0, len(rows))
+	for _, r := range rows {
+		key, err := parse(r.SortKey())
+		if err != nil {
+			return nil, nil, err
+		}
+
+		createdAt, id := r.PageKey()
+		all = append(all, keyed{row: r, key: key, createdAt: createdAt, id: id})
+	}
+
+	slices.SortFunc(all, compare)
+
+	var after *keyed
+	if p.Cursor != nil {
+		key, err := parse(p.Cursor.Key)
+		if err != nil {
+			return nil, nil, repocitory.NotAllowedError()
+		}
+
+		after = &keyed{key: key, createdAt: p.Cursor.CreatedAt, id: p.Cursor.ID}
+	}
+
+	backwards := p.Cursor != nil && p.Cursor.Direction == pagination.Prev
+
+	var selected []T
+	for _, k := range all {
+		if after != nil {
+			c := compare(k, *after)
+
+			if (backwards && c >= 0) || (!backwards && c <= 0) {
+				continue
+			}
+		}
+
+		selected = append(selected, k.row)
+	}
+
+	if backwards {
+		slices.Reverse(selected)
+	}
+
+	if len(selected) > p.Limit+1 {
+		selected = selected[:p.Limit+1]
+	}
+
+	page, info := pagination.Trim(p, selected)
+
+	if p.WithTotal {
+		total := len(rows)
+		info.Total = &total
+	}
+
+	return page, info, nil
+}
+
+// sameString reports whether two nullable columns hold the same value, NULLs
+// never being equal the way unique indexes treat them.
+func sameString(a, b *string) bool {
+	return a != nil && b != nil && *a == *b
+}
+
+// copyOf gives a copy of a nullable column, so callers cannot change what
+// is stored through the pointer.
+func copyOf[T any](p *T) *T {
+	if p == nil {
+		return nil
+	}
+
+	c := *p
+	return &c
+}
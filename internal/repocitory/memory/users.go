@@ -0,0 +1,116 @@
+package memory
+
+import (
+	"context"
+
+	"github.com/Oj-washingtone/savannah-store/internal/model"
+	"github.com/Oj-washingtone/savannah-store/internal/repocitory"
+	"github.com/google/uuid"
+)
+
+type userRepository struct {
+	s *store
+}
+
+func (r *userRepository) Create(ctx context.Context, user *model.User) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	if _, ok := r.s.users[user.ID]; ok {
+		return repocitory.ConflictError("users_pkey")
+	}
+
+	switch user.Role {
+	case model.CustomerRole, model.AdminRole, model.SuperAdminRole:
+	default:
+		return repocitory.NotAllowedError()
+	}
+
+	for _, u := range r.s.users {
+		if u.Email == user.Email {
+			return repocitory.ConflictError("users_email_key")
+		}
+
+		if u.Auth0Id == user.Auth0Id {
+			return repocitory.ConflictError("users_auth0_id_key")
+		}
+	}
+
+	user.CreatedAt = r.s.now()
+	user.UpdatedAt = user.CreatedAt
+
+	stored := *user
+	stored.Phone = ""
+	stored.DeletedAt = nil
+	stored.CartRemindersOptOut = false
+	stored.WishlistAlertsOptOut = false
+
+	r.s.users[user.ID] = &stored
+
+	return nil
+}
+
+func (r *userRepository) GetById(ctx context.Context, id uuid.UUID) (*model.User, error) {
+	return r.find(func(u *model.User) bool { return u.ID == id })
+}
+
+func (r *userRepository) GetByEmail(ctx context.Context, email string) (*model.User, error) {
+	return r.find(func(u *model.User) bool { return u.Email == email })
+}
+
+func (r *userRepository) GetByAuth0Id(ctx context.Context, auth0Id string) (*model.User, error) {
+	return r.find(func(u *model.User) bool { return u.Auth0Id == auth0Id })
+}
+
+// find returns a copy of the user matching, deleted or not, like the
+// Postgres lookups.
+func (r *userRepository) find(match func(u *model.User) bool) (*model.User, error) {
+	r.s.mu.RLock()
+	defer r.s.mu.RUnlock()
+
+	for _, u := range r.s.users {
+		if match(u) {
+			user := *u
+			user.DeletedAt = copyOf(user.DeletedAt)
+			return &user, nil
+		}
+	}
+
+	return nil, repocitory.NotFoundError("user")
+}
+
+func (r *userRepository) UpdatePhone(ctx context.Context, id uuid.UUID, phone string) error {
+	return r.update(id, func(u *model.User) { u.Phone = phone })
+}
+
+func (r *userRepository) UpdateRole(ctx context.Context, id uuid.UUID, role model.Roles) error {
+	switch role {
+	case model.CustomerRole, model.AdminRole, model.SuperAdminRole:
+	default:
+		return repocitory.NotAllowedError()
+	}
+
+	return r.update(id, func(u *model.User) { u.Role = role })
+}
+
+func (r *userRepository) UpdateCartRemindersOptOut(ctx context.Context, id uuid.UUID, optOut bool) error {
+	return r.update(id, func(u *model.User) { u.CartRemindersOptOut = optOut })
+}
+
+func (r *userRepository) UpdateWishlistAlertsOptOut(ctx context.Context, id uuid.UUID, optOut bool) error {
+	return r.update(id, func(u *model.User) { u.WishlistAlertsOptOut = optOut })
+}
+
+// update changes the user if there is one, updating a missing user is not an
+// error.
+func (r *userRepository) update(id uuid.UUID, change func(u *model.User)) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	if u, ok := r.s.users[id]; ok {
+		change(u)
+		u.UpdatedAt = r.s.now()
+	}
+
+	return nil
+}
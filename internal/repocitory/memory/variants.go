@@ -0,0 +1,385 @@
+package memory
+
+import (
+	"cmp"
+	"context"
+	"slices"
+	"unicode/utf8"
+
+	"github.com/Oj-washingtone/savannah-store/internal/model"
+	"github.com/Oj-washingtone/savannah-store/internal/repocitory"
+	"github.com/google/uuid"
+)
+
+type productVariantRepository struct {
+	s *store
+}
+
+// optionValue finds an option value and its option.
+func (s *store) optionValue(valueId uuid.UUID) (*model.ProductOption, *model.ProductOptionValue) {
+	for _, option := range s.options {
+		for _, value := range option.Values {
+			if value.ID == valueId {
+				return option, value
+			}
+		}
+	}
+
+	return nil, nil
+}
+
+// checkOptionValue holds a value to the constraints of the
+// product_option_values table.
+func (s *store) checkOptionValue(option *model.ProductOption, value *model.ProductOptionValue) error {
+	if utf8.RuneCountInString(value.Value) > 50 {
+		return repocitory.NotAllowedError()
+	}
+
+	if _, existing := s.optionValue(value.ID); existing != nil {
+		return repocitory.ConflictError("product_option_values_pkey")
+	}
+
+	for _, v := range option.Values {
+		if v.Value == value.Value {
+			return repocitory.ConflictError("product_option_values_option_id_value_key")
+		}
+	}
+
+	return nil
+}
+
+func copyOption(o *model.ProductOption) *model.ProductOption {
+	option := *o
+	option.Values = []*model.ProductOptionValue{}
+
+	for _, v := range o.Values {
+		value := *v
+		option.Values = append(option.Values, &value)
+	}
+
+	slices.SortStableFunc(option.Values, func(a, b *model.ProductOptionValue) int {
+		return cmp.Compare(a.Position, b.Position)
+	})
+
+	return &option
+}
+
+func (r *productVariantRepository) ListOptions(ctx context.Context, productId uuid.UUID) ([]*model.ProductOption, error) {
+	r.s.mu.RLock()
+	defer r.s.mu.RUnlock()
+
+	var options []*model.ProductOption
+	for _, option := range r.s.options {
+		if option.ProductID == productId {
+			options = append(options, copyOption(option))
+		}
+	}
+
+	slices.SortFunc(options, func(a, b *model.ProductOption) int {
+		return cmp.Or(cmp.Compare(a.Position, b.Position), a.CreatedAt.Compare(b.CreatedAt))
+	})
+
+	return options, nil
+}
+
+// CreateOption adds the option and its values after the product's existing
+// options.
+func (r *productVariantRepository) CreateOption(ctx context.Context, option *model.ProductOption) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	if _, ok := r.s.options[option.ID]; ok {
+		return repocitory.ConflictError("product_options_pkey")
+	}
+
+	if utf8.RuneCountInString(option.Name) > 50 {
+		return repocitory.NotAllowedError()
+	}
+
+	if _, ok := r.s.products[option.ProductID]; !ok {
+		return repocitory.MissingReferenceError()
+	}
+
+	position := 0
+	for _, o := range r.s.options {
+		if o.ProductID != option.ProductID {
+			continue
+		}
+
+		if o.Name == option.Name {
+			return repocitory.ConflictError("product_options_product_id_name_key")
+		}
+
+		position = max(position, o.Position+1)
+	}
+
+	stored := &model.ProductOption{
+		BaseModel: option.BaseModel,
+		ProductID: option.ProductID,
+		Name:      option.Name,
+		Position:  position,
+		Values:    []*model.ProductOptionValue{},
+	}
+
+	for _, value := range option.Values {
+		if err := r.s.checkOptionValue(stored, value); err != nil {
+			return err
+		}
+
+		v := *value
+		v.OptionID = option.ID
+		stored.Values = append(stored.Values, &v)
+	}
+
+	option.Position = position
+	option.CreatedAt = r.s.now()
+	option.UpdatedAt = option.CreatedAt
+
+	stored.CreatedAt = option.CreatedAt
+	stored.UpdatedAt = option.UpdatedAt
+
+	r.s.options[option.ID] = stored
+
+	return nil
+}
+
+func (r *productVariantRepository) AddOptionValue(ctx context.Context, value *model.ProductOptionValue) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	option, ok := r.s.options[value.OptionID]
+	if !ok {
+		return repocitory.MissingReferenceError()
+	}
+
+	if err := r.s.checkOptionValue(option, value); err != nil {
+		return err
+	}
+
+	v := *value
+	option.Values = append(option.Values, &v)
+
+	return nil
+}
+
+// DeleteOption removes the option and its values. Deleted variants lose the
+// value they had, while a live variant using one of the values makes it fail.
+func (r *productVariantRepository) DeleteOption(ctx context.Context, optionId uuid.UUID) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	option, ok := r.s.options[optionId]
+	if !ok {
+		return nil
+	}
+
+	inOption := map[uuid.UUID]bool{}
+	for _, value := range option.Values {
+		inOption[value.ID] = true
+	}
+
+	for variantId, valueIds := range r.s.variantValues {
+		if v := r.s.variants[variantId]; !v.deleted && slices.ContainsFunc(valueIds, func(id uuid.UUID) bool { return inOption[id] }) {
+			return repocitory.InUseError()
+		}
+	}
+
+	for variantId, valueIds := range r.s.variantValues {
+		r.s.variantValues[variantId] = slices.DeleteFunc(valueIds, func(id uuid.UUID) bool { return inOption[id] })
+	}
+
+	delete(r.s.options, optionId)
+
+	return nil
+}
+
+// variant gives a copy of a stored variant with its option values.
+func (s *store) variant(v *row[model.ProductVariant]) *model.ProductVariant {
+	variant := v.value
+	variant.SKU = copyOf(v.value.SKU)
+	variant.Price = copyOf(v.value.Price)
+	variant.Options = map[string]string{}
+	variant.Images = []*model.ProductImage{}
+
+	for _, valueId := range s.variantValues[variant.ID] {
+		if option, value := s.optionValue(valueId); option != nil {
+			variant.Options[option.Name] = value.Value
+		}
+	}
+
+	return &variant
+}
+
+func (r *productVariantRepository) GetById(ctx context.Context, id uuid.UUID) (*model.ProductVariant, error) {
+	r.s.mu.RLock()
+	defer r.s.mu.RUnlock()
+
+	v, ok := r.s.variants[id]
+	if !ok || v.deleted {
+		return nil, repocitory.NotFoundError("variant")
+	}
+
+	return r.s.variant(v), nil
+}
+
+func (r *productVariantRepository) GetByIdIncludingDeleted(ctx context.Context, id uuid.UUID) (*model.ProductVariant, error) {
+	r.s.mu.RLock()
+	defer r.s.mu.RUnlock()
+
+	v, ok := r.s.variants[id]
+	if !ok {
+		return nil, repocitory.NotFoundError("variant")
+	}
+
+	return r.s.variant(v), nil
+}
+
+func (r *productVariantRepository) ListByProduct(ctx context.Context, productId uuid.UUID) ([]*model.ProductVariant, error) {
+	r.s.mu.RLock()
+	defer r.s.mu.RUnlock()
+
+	var variants []*model.ProductVariant
+	for _, v := range r.s.variants {
+		if !v.deleted && v.value.ProductID == productId {
+			variants = append(variants, r.s.variant(v))
+		}
+	}
+
+	slices.SortFunc(variants, func(a, b *model.ProductVariant) int {
+		return cmp.Or(cmp.Compare(a.Position, b.Position), a.CreatedAt.Compare(b.CreatedAt))
+	})
+
+	return variants, nil
+}
+
+// checkVariant holds a variant to the constraints of the product_variants
+// table.
+func (s *store) checkVariant(variant *model.ProductVariant) error {
+	if (variant.SKU != nil && utf8.RuneCountInString(*variant.SKU) > 64) ||
+		(variant.Price != nil && *variant.Price < 0) ||
+		variant.Stock < 0 {
+		return repocitory.NotAllowedError()
+	}
+
+	for _, v := range s.variants {
+		if !v.deleted && v.value.ID != variant.ID && sameString(v.value.SKU, variant.SKU) {
+			return repocitory.ConflictError("idx_product_variants_sku")
+		}
+	}
+
+	return nil
+}
+
+// Create adds the variant with the given option values. The first variant
+// with options replaces the option-less variant the product had while it was
+// a simple product.
+func (r *productVariantRepository) Create(ctx context.Context, variant *model.ProductVariant, valueIds []uuid.UUID) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	if _, ok := r.s.variants[variant.ID]; ok {
+		return repocitory.ConflictError("product_variants_pkey")
+	}
+
+	if _, ok := r.s.products[variant.ProductID]; !ok {
+		return repocitory.MissingReferenceError()
+	}
+
+	if err := r.s.checkVariant(variant); err != nil {
+		return err
+	}
+
+	seen := map[uuid.UUID]bool{}
+	for _, valueId := range valueIds {
+		if option, _ := r.s.optionValue(valueId); option == nil {
+			return repocitory.MissingReferenceError()
+		}
+
+		if seen[valueId] {
+			return repocitory.ConflictError("product_variant_values_pkey")
+		}
+		seen[valueId] = true
+	}
+
+	position := 0
+	for _, v := range r.s.variants {
+		if !v.deleted && v.value.ProductID == variant.ProductID {
+			position = max(position, v.value.Position+1)
+		}
+	}
+
+	variant.Position = position
+	variant.CreatedAt = r.s.now()
+	variant.UpdatedAt = variant.CreatedAt
+
+	if len(valueIds) > 0 {
+		for _, v := range r.s.variants {
+			if !v.deleted && v.value.ProductID == variant.ProductID && len(r.s.variantValues[v.value.ID]) == 0 {
+				v.deleted = true
+			}
+		}
+	}
+
+	stored := model.ProductVariant{
+		BaseModel: variant.BaseModel,
+		ProductID: variant.ProductID,
+		SKU:       copyOf(variant.SKU),
+		Price:     copyOf(variant.Price),
+		Stock:     variant.Stock,
+		Position:  variant.Position,
+	}
+
+	r.s.variants[variant.ID] = &row[model.ProductVariant]{value: stored}
+	r.s.variantValues[variant.ID] = slices.Clone(valueIds)
+
+	r.s.syncProductStock(variant.ProductID, variant.CreatedAt)
+
+	return nil
+}
+
+func (r *productVariantRepository) Update(ctx context.Context, variant *model.ProductVariant) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	v, ok := r.s.variants[variant.ID]
+	if !ok {
+		return repocitory.NotFoundError("variant")
+	}
+
+	if err := r.s.checkVariant(variant); err != nil {
+		return err
+	}
+
+	variant.UpdatedAt = r.s.now()
+
+	v.value.SKU = copyOf(variant.SKU)
+	v.value.Price = copyOf(variant.Price)
+	v.value.Stock = variant.Stock
+	v.value.UpdatedAt = variant.UpdatedAt
+
+	r.s.syncProductStock(variant.ProductID, variant.UpdatedAt)
+
+	return nil
+}
+
+// Delete soft deletes the variant, keeping its option values so past orders
+// can still describe it.
+func (r *productVariantRepository) Delete(ctx context.Context, variant *model.ProductVariant) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	if v, ok := r.s.variants[variant.ID]; ok {
+		v.deleted = true
+	}
+
+	r.s.syncProductStock(variant.ProductID, r.s.now())
+
+	return nil
+}
+
+func (r *productVariantRepository) SKUTaken(ctx context.Context, sku string, productId, excludeId uuid.UUID) (bool, error) {
+	r.s.mu.RLock()
+	defer r.s.mu.RUnlock()
+
+	return r.s.skuTaken(sku, productId, excludeId, uuid.Nil), nil
+}
@@ -0,0 +1,271 @@
+package memory
+
+import (
+	"context"
+	"strconv"
+	"unicode/utf8"
+
+	"github.com/Oj-washingtone/savannah-store/internal/model"
+	"github.com/Oj-washingtone/savannah-store/internal/pagination"
+	"github.com/Oj-washingtone/savannah-store/internal/repocitory"
+	"github.com/google/uuid"
+)
+
+type wishlistRepository struct {
+	s *store
+}
+
+func copyWishlist(w *model.Wishlist) *model.Wishlist {
+	wishlist := *w
+	wishlist.ShareToken = copyOf(w.ShareToken)
+	wishlist.Items = nil
+	return &wishlist
+}
+
+// checkWishlist holds a wishlist to the constraints of the wishlists table.
+func (s *store) checkWishlist(wishlist *model.Wishlist) error {
+	if utf8.RuneCountInString(wishlist.Name) > 100 ||
+		(wishlist.ShareToken != nil && utf8.RuneCountInString(*wishlist.ShareToken) > 64) {
+		return repocitory.NotAllowedError()
+	}
+
+	for _, w := range s.wishlists {
+		if w.value.ID != wishlist.ID && sameString(w.value.ShareToken, wishlist.ShareToken) {
+			return repocitory.ConflictError("wishlists_share_token_key")
+		}
+	}
+
+	return nil
+}
+
+func (r *wishlistRepository) Create(ctx context.Context, wishlist *model.Wishlist) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	if _, ok := r.s.wishlists[wishlist.ID]; ok {
+		return repocitory.ConflictError("wishlists_pkey")
+	}
+
+	if err := r.s.checkWishlist(wishlist); err != nil {
+		return err
+	}
+
+	if _, ok := r.s.users[wishlist.UserId]; !ok {
+		return repocitory.MissingReferenceError()
+	}
+
+	if wishlist.IsDefault {
+		for _, w := range r.s.wishlists {
+			if !w.deleted && w.value.IsDefault && w.value.UserId == wishlist.UserId {
+				return repocitory.ConflictError("idx_wishlists_default_per_user")
+			}
+		}
+	}
+
+	wishlist.CreatedAt = r.s.now()
+	wishlist.UpdatedAt = wishlist.CreatedAt
+
+	r.s.wishlists[wishlist.ID] = &row[model.Wishlist]{value: *copyWishlist(wishlist)}
+
+	return nil
+}
+
+// find returns a copy of the first live wishlist the filter accepts.
+func (r *wishlistRepository) find(filter func(w *model.Wishlist) bool) (*model.Wishlist, error) {
+	r.s.mu.RLock()
+	defer r.s.mu.RUnlock()
+
+	for _, w := range r.s.wishlists {
+		if !w.deleted && filter(&w.value) {
+			return copyWishlist(&w.value), nil
+		}
+	}
+
+	return nil, repocitory.NotFoundError("wishlist")
+}
+
+func (r *wishlistRepository) GetById(ctx context.Context, id uuid.UUID) (*model.Wishlist, error) {
+	return r.find(func(w *model.Wishlist) bool { return w.ID == id })
+}
+
+func (r *wishlistRepository) GetDefault(ctx context.Context, userId uuid.UUID) (*model.Wishlist, error) {
+	return r.find(func(w *model.Wishlist) bool { return w.UserId == userId && w.IsDefault })
+}
+
+func (r *wishlistRepository) GetByShareToken(ctx context.Context, token string) (*model.Wishlist, error) {
+	return r.find(func(w *model.Wishlist) bool { return w.ShareToken != nil && *w.ShareToken == token })
+}
+
+// ListByUser pages through the user's wishlists, the default one first and
+// then the named ones newest first.
+func (r *wishlistRepository) ListByUser(ctx context.Context, userId uuid.UUID, page pagination.Page) ([]*model.Wishlist, *pagination.Info, error) {
+	r.s.mu.RLock()
+	defer r.s.mu.RUnlock()
+
+	var wishlists []pagination.Sorted[*model.Wishlist]
+	for _, w := range r.s.wishlists {
+		if !w.deleted && w.value.UserId == userId {
+			key := "0"
+			if w.value.IsDefault {
+				key = "1"
+			}
+
+			wishlists = append(wishlists, pagination.Sorted[*model.Wishlist]{Row: copyWishlist(&w.value), Key: key})
+		}
+	}
+
+	wishlists, info, err := paginateBy(page, wishlists, true, strconv.Atoi)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return pagination.Rows(wishlists), info, nil
+}
+
+func (r *wishlistRepository) Update(ctx context.Context, wishlist *model.Wishlist) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	w, ok := r.s.wishlists[wishlist.ID]
+	if !ok {
+		return repocitory.NotFoundError("wishlist")
+	}
+
+	if err := r.s.checkWishlist(wishlist); err != nil {
+		return err
+	}
+
+	wishlist.UpdatedAt = r.s.now()
+
+	w.value.Name = wishlist.Name
+	w.value.ShareToken = copyOf(wishlist.ShareToken)
+	w.value.UpdatedAt = wishlist.UpdatedAt
+
+	return nil
+}
+
+// Delete soft deletes the wishlist and stops its share link from working.
+func (r *wishlistRepository) Delete(ctx context.Context, id uuid.UUID) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	if w, ok := r.s.wishlists[id]; ok && !w.deleted {
+		w.deleted = true
+		w.value.ShareToken = nil
+	}
+
+	return nil
+}
+
+func (r *wishlistRepository) AddItem(ctx context.Context, item *model.WishlistItem) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	if _, ok := r.s.wishlistItems[item.ID]; ok {
+		return repocitory.ConflictError("wishlist_items_pkey")
+	}
+
+	_, wishlistOk := r.s.wishlists[item.WishlistId]
+	_, productOk := r.s.products[item.ProductId]
+
+	if !wishlistOk || !productOk {
+		return repocitory.MissingReferenceError()
+	}
+
+	for _, i := range r.s.wishlistItems {
+		if i.WishlistId == item.WishlistId && i.ProductId == item.ProductId {
+			return repocitory.ConflictError("wishlist_items_wishlist_id_product_id_key")
+		}
+	}
+
+	item.CreatedAt = r.s.now()
+	item.UpdatedAt = item.CreatedAt
+
+	stored := *item
+	r.s.wishlistItems[item.ID] = &stored
+
+	return nil
+}
+
+// RemoveItem deletes a product from a wishlist and reports whether it was there.
+func (r *wishlistRepository) RemoveItem(ctx context.Context, wishlistId, productId uuid.UUID) (bool, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	for id, item := range r.s.wishlistItems {
+		if item.WishlistId == wishlistId && item.ProductId == productId {
+			delete(r.s.wishlistItems, id)
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
+func (r *wishlistRepository) GetItems(ctx context.Context, wishlistId uuid.UUID) ([]*model.WishlistItem, error) {
+	r.s.mu.RLock()
+	defer r.s.mu.RUnlock()
+
+	var items []*model.WishlistItem
+	for _, item := range r.s.wishlistItems {
+		if item.WishlistId != wishlistId || r.s.products[item.ProductId].deleted {
+			continue
+		}
+
+		i := *item
+		items = append(items, &i)
+	}
+
+	newestFirst(items)
+
+	return items, nil
+}
+
+func (r *wishlistRepository) ItemExists(ctx context.Context, wishlistId, productId uuid.UUID) (bool, error) {
+	r.s.mu.RLock()
+	defer r.s.mu.RUnlock()
+
+	for _, item := range r.s.wishlistItems {
+		if item.WishlistId == wishlistId && item.ProductId == productId {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
+// ListWatchers returns every user that has the product on at least one of
+// their wishlists and has not opted out of wishlist alerts.
+func (r *wishlistRepository) ListWatchers(ctx context.Context, productId uuid.UUID) ([]*model.WishlistWatcher, error) {
+	r.s.mu.RLock()
+	defer r.s.mu.RUnlock()
+
+	seen := map[uuid.UUID]bool{}
+
+	var watchers []*model.WishlistWatcher
+	for _, item := range r.s.wishlistItems {
+		if item.ProductId != productId {
+			continue
+		}
+
+		w := r.s.wishlists[item.WishlistId]
+		if w.deleted {
+			continue
+		}
+
+		user, ok := r.s.users[w.value.UserId]
+		if !ok || user.DeletedAt != nil || user.WishlistAlertsOptOut || seen[user.ID] {
+			continue
+		}
+		seen[user.ID] = true
+
+		watchers = append(watchers, &model.WishlistWatcher{
+			UserId: user.ID,
+			Name:   user.Name,
+			Email:  user.Email,
+			Phone:  user.Phone,
+		})
+	}
+
+	return watchers, nil
+}
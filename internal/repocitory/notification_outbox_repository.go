@@ -0,0 +1,119 @@
+package repocitory
+
+import (
+	"context"
+	"time"
+
+	"github.com/Oj-washingtone/savannah-store/internal/database"
+	"github.com/Oj-washingtone/savannah-store/internal/model"
+	"github.com/google/uuid"
+)
+
+type NotificationOutboxRepository interface {
+	Add(ctx context.Context, notification *model.OutboxNotification) error
+	ListUnsent(ctx context.Context, maxAttempts, limit int) ([]*model.OutboxNotification, error)
+	MarkSent(ctx context.Context, id uuid.UUID) error
+	RecordFailure(ctx context.Context, id uuid.UUID, lastError string) error
+	OldestUnsent(ctx context.Context, maxAttempts int) (*time.Time, error)
+}
+
+type notificationOutboxRepository struct {
+	db *database.DB
+}
+
+func NewNotificationOutboxRepository(db *database.DB) NotificationOutboxRepository {
+	return &notificationOutboxRepository{db: db}
+}
+
+// Add stores a notification after its first attempt failed.
+func (r *notificationOutboxRepository) Add(ctx context.Context, n *model.OutboxNotification) error {
+	if n.Attachments == nil {
+		n.Attachments = []model.NotificationAttachment{}
+	}
+
+	if n.Attempts == 0 {
+		n.Attempts = 1
+	}
+
+	query := `
+		INSERT INTO notification_outbox (id, channel, recipient, subject, body, attachments, attempts, last_error)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+		RETURNING created_at, updated_at
+	`
+
+	return dbErr(r.db.Pool.QueryRow(ctx, query,
+		n.ID,
+		n.Channel,
+		n.Recipient,
+		n.Subject,
+		n.Body,
+		n.Attachments,
+		n.Attempts,
+		n.LastError,
+	).Scan(&n.CreatedAt, &n.UpdatedAt), "notification")
+}
+
+// ListUnsent returns the oldest notifications still to be sent that have
+// been tried fewer than maxAttempts times.
+func (r *notificationOutboxRepository) ListUnsent(ctx context.Context, maxAttempts, limit int) ([]*model.OutboxNotification, error) {
+	query := `
+		SELECT id, channel, recipient, subject, body, attachments, attempts, last_error, sent_at, created_at, updated_at
+		FROM notification_outbox
+		WHERE sent_at IS NULL AND attempts < $1
+		ORDER BY created_at, id
+		LIMIT $2
+	`
+
+	rows, err := r.db.Pool.Query(ctx, query, maxAttempts, limit)
+	if err != nil {
+		return nil, dbErr(err, "notification")
+	}
+	defer rows.Close()
+
+	var notifications []*model.OutboxNotification
+	for rows.Next() {
+		n := &model.OutboxNotification{}
+		if err := rows.Scan(
+			&n.ID,
+			&n.Channel,
+			&n.Recipient,
+			&n.Subject,
+			&n.Body,
+			&n.Attachments,
+			&n.Attempts,
+			&n.LastError,
+			&n.SentAt,
+			&n.CreatedAt,
+			&n.UpdatedAt,
+		); err != nil {
+			return nil, dbErr(err, "notification")
+		}
+		notifications = append(notifications, n)
+	}
+
+	return notifications, dbErr(rows.Err(), "notification")
+}
+
+func (r *notificationOutboxRepository) MarkSent(ctx context.Context, id uuid.UUID) error {
+	query := `UPDATE notification_outbox SET sent_at = now(), updated_at = now() WHERE id = $1`
+	_, err := r.db.Pool.Exec(ctx, query, id)
+	return dbErr(err, "notification")
+}
+
+// RecordFailure counts another failed attempt at sending the notification.
+func (r *notificationOutboxRepository) RecordFailure(ctx context.Context, id uuid.UUID, lastError string) error {
+	query := `UPDATE notification_outbox SET attempts = attempts + 1, last_error = $1, updated_at = now() WHERE id = $2`
+	_, err := r.db.Pool.Exec(ctx, query, lastError, id)
+	return dbErr(err, "notification")
+}
+
+// OldestUnsent is when the oldest notification still to be sent was first
+// tried, or nil when there is none. Notifications tried maxAttempts times are
+// left out, as nothing will send them.
+func (r *notificationOutboxRepository) OldestUnsent(ctx context.Context, maxAttempts int) (*time.Time, error) {
+	query := `SELECT MIN(created_at) FROM notification_outbox WHERE sent_at IS NULL AND attempts < $1`
+
+	var oldest *time.Time
+	err := r.db.Pool.QueryRow(ctx, query, maxAttempts).Scan(&oldest)
+	return oldest, dbErr(err, "notification")
+}
@@ -7,37 +7,48 @@ import (
 
 	"github.com/Oj-washingtone/savannah-store/internal/database"
 	"github.com/Oj-washingtone/savannah-store/internal/model"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
 )
 
 type OrderItemsRepository interface {
 	Create(ctx context.Context, item *model.OrderItems) error
 
 	CreateBulk(ctx context.Context, items []*model.OrderItems) error
+
+	GetByOrder(ctx context.Context, orderId uuid.UUID) ([]*model.OrderItems, error)
 }
 
 type orderItemsRepository struct {
 	db *database.DB
 }
 
-func NewOrderItemsRepository() OrderItemsRepository {
-	return &orderItemsRepository{db: database.GetDB()}
+func NewOrderItemsRepository(db *database.DB) OrderItemsRepository {
+	return &orderItemsRepository{db: db}
 }
 
 func (r *orderItemsRepository) Create(ctx context.Context, item *model.OrderItems) error {
 	query := `
-		INSERT INTO order_items (id, order_id, product_id, quantity, price)
-		VALUES ($1, $2, $3, $4, $5)
+		INSERT INTO order_items (id, order_id, product_id, variant_id, quantity, price, discount, tax_class, tax_rate, tax, product_name, variant_name)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12)
 	`
 
 	_, err := r.db.Pool.Exec(ctx, query,
 		item.ID,
 		item.OrderID,
 		item.ProductID,
+		item.VariantID,
 		item.Quantity,
 		item.Price,
+		item.Discount,
+		item.TaxClass,
+		item.TaxRate,
+		item.Tax,
+		item.ProductName,
+		item.VariantName,
 	)
 
-	return err
+	return dbErr(err, "order item")
 }
 
 func (r *orderItemsRepository) CreateBulk(ctx context.Context, items []*model.OrderItems) error {
@@ -47,23 +58,78 @@ func (r *orderItemsRepository) CreateBulk(ctx context.Context, items []*model.Or
 
 	tx, err := r.db.Pool.Begin(ctx)
 	if err != nil {
-		return err
+		return dbErr(err, "order item")
 	}
 	defer tx.Rollback(ctx)
 
-	query := `INSERT INTO order_items (id, order_id, product_id, quantity, price) VALUES `
+	if err := insertOrderItems(ctx, tx, items); err != nil {
+		return err
+	}
+
+	return dbErr(tx.Commit(ctx), "order item")
+}
+
+// insertOrderItems adds the items in one statement on tx.
+func insertOrderItems(ctx context.Context, tx pgx.Tx, items []*model.OrderItems) error {
+	if len(items) == 0 {
+		return nil
+	}
+
+	const columns = 12
+
+	query := `INSERT INTO order_items (id, order_id, product_id, variant_id, quantity, price, discount, tax_class, tax_rate, tax, product_name, variant_name) VALUES `
 	args := []interface{}{}
 	for i, item := range items {
-		start := i*5 + 1
-		query += `($` + strconv.Itoa(start) + `,$` + strconv.Itoa(start+1) + `,$` + strconv.Itoa(start+2) + `,$` + strconv.Itoa(start+3) + `,$` + strconv.Itoa(start+4) + `),`
-		args = append(args, item.ID, item.OrderID, item.ProductID, item.Quantity, item.Price)
+		placeholders := make([]string, columns)
+		for j := range placeholders {
+			placeholders[j] = `$` + strconv.Itoa(i*columns+j+1)
+		}
+		query += `(` + strings.Join(placeholders, `,`) + `),`
+		args = append(args, item.ID, item.OrderID, item.ProductID, item.VariantID, item.Quantity, item.Price, item.Discount, item.TaxClass, item.TaxRate, item.Tax, item.ProductName, item.VariantName)
 	}
 	query = strings.TrimRight(query, ",")
 
-	_, err = tx.Exec(ctx, query, args...)
+	_, err := tx.Exec(ctx, query, args...)
+	return dbErr(err, "order item")
+}
+
+func (r *orderItemsRepository) GetByOrder(ctx context.Context, orderId uuid.UUID) ([]*model.OrderItems, error) {
+	query := `
+		SELECT id, order_id, product_id, variant_id, quantity, price, discount, tax_class, tax_rate, tax, product_name, variant_name, created_at, updated_at
+		FROM order_items
+		WHERE order_id = $1
+		ORDER BY created_at, id
+	`
+
+	rows, err := r.db.Pool.Query(ctx, query, orderId)
 	if err != nil {
-		return err
+		return nil, dbErr(err, "order item")
+	}
+	defer rows.Close()
+
+	var items []*model.OrderItems
+	for rows.Next() {
+		item := &model.OrderItems{}
+		if err := rows.Scan(
+			&item.ID,
+			&item.OrderID,
+			&item.ProductID,
+			&item.VariantID,
+			&item.Quantity,
+			&item.Price,
+			&item.Discount,
+			&item.TaxClass,
+			&item.TaxRate,
+			&item.Tax,
+			&item.ProductName,
+			&item.VariantName,
+			&item.CreatedAt,
+			&item.UpdatedAt,
+		); err != nil {
+			return nil, dbErr(err, "order item")
+		}
+		items = append(items, item)
 	}
 
-	return tx.Commit(ctx)
+	return items, dbErr(rows.Err(), "order item")
 }
@@ -4,146 +4,250 @@ import (
 	"context"
 	"time"
 
+	"github.com/Oj-washingtone/savannah-store/internal/apperr"
 	"github.com/Oj-washingtone/savannah-store/internal/database"
 	"github.com/Oj-washingtone/savannah-store/internal/model"
+	"github.com/Oj-washingtone/savannah-store/internal/pagination"
 	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgxpool"
 )
 
+var (
+	// ErrOrderAlreadyPaid is returned by MarkPaid for an order that already
+	// has an invoice number.
+	ErrOrderAlreadyPaid = apperr.Conflict("Order is already paid")
+
+	// ErrOrderCancelled is returned by MarkPaid for a cancelled order.
+	ErrOrderCancelled = apperr.Conflict("Order is cancelled")
+)
+
+// Checkout is everything placing an order writes.
+type Checkout struct {
+	Order       *model.Orders
+	Items       []*model.OrderItems
+	Redemptions []*model.PromotionRedemption
+
+	// CartID is the cart the order was placed from, which is emptied of its
+	// items and coupon codes
+	CartID uuid.UUID
+}
+
 type OrdersRepository interface {
 	Create(ctx context.Context, order *model.Orders) (*model.Orders, error)
+	Place(ctx context.Context, checkout *Checkout) (*model.Orders, error)
 	GetById(ctx context.Context, id uuid.UUID) (*model.Orders, error)
 	GetByUser(ctx context.Context, userId uuid.UUID) ([]*model.Orders, error)
 	UpdateStatus(ctx context.Context, orderId uuid.UUID, status model.OrderStatus) error
-	UpdatePaidStatus(ctx context.Context, orderId uuid.UUID, paid bool) error
-	GetAll(ctx context.Context) ([]*model.Orders, error)
+	MarkPaid(ctx context.Context, orderId uuid.UUID, paymentReference string) (*model.Orders, error)
+	GetAll(ctx context.Context, page pagination.Page) ([]*model.Orders, *pagination.Info, error)
 }
 
 type ordersRepository struct {
 	db *database.DB
 }
 
-func NewOrdersRepository() OrdersRepository {
-	return &ordersRepository{db: database.GetDB()}
+func NewOrdersRepository(db *database.DB) OrdersRepository {
+	return &ordersRepository{db: db}
 }
 
-func (r *ordersRepository) Create(ctx context.Context, order *model.Orders) (*model.Orders, error) {
-	query := `
-		INSERT INTO orders (id, user_id, total, paid)
-		VALUES ($1, $2, $3, $4)
-		RETURNING id, user_id, status, total, paid, created_at, updated_at
-	`
+const orderColumns = `id, user_id, status, subtotal, discount_total, tax_total, total, paid, prices_include_tax,
+	paid_at, payment_reference, invoice_number, created_at, updated_at`
 
-	err := r.db.Pool.QueryRow(ctx, query,
-		order.ID,
-		order.UserID,
-		order.Total,
-		order.Paid,
-	).Scan(
+func scanOrder(row interface{ Scan(dest ...any) error }) (*model.Orders, error) {
+	var order model.Orders
+	err := row.Scan(
 		&order.ID,
 		&order.UserID,
 		&order.Status,
+		&order.Subtotal,
+		&order.DiscountTotal,
+		&order.TaxTotal,
 		&order.Total,
 		&order.Paid,
+		&order.PricesIncludeTax,
+		&order.PaidAt,
+		&order.PaymentReference,
+		&order.InvoiceNumber,
 		&order.CreatedAt,
 		&order.UpdatedAt,
 	)
-
 	if err != nil {
-		return nil, err
+		return nil, dbErr(err, "order")
 	}
 
-	return order, nil
+	return &order, nil
 }
 
-func (r *ordersRepository) GetById(ctx context.Context, id uuid.UUID) (*model.Orders, error) {
-	query := `SELECT id, user_id, status, total, paid, created_at, updated_at
-			  FROM orders WHERE id = $1`
+func (r *ordersRepository) queryOrders(ctx context.Context, pool *pgxpool.Pool, query string, args ...any) ([]*model.Orders, error) {
+	rows, err := pool.Query(ctx, query, args...)
+	if err != nil {
+		return nil, dbErr(err, "order")
+	}
+	defer rows.Close()
 
-	row := r.db.Pool.QueryRow(ctx, query, id)
+	var orders []*model.Orders
+	for rows.Next() {
+		order, err := scanOrder(rows)
+		if err != nil {
+			return nil, dbErr(err, "order")
+		}
+		orders = append(orders, order)
+	}
 
-	var order model.Orders
-	err := row.Scan(
-		&order.ID,
-		&order.UserID,
-		&order.Status,
-		&order.Total,
-		&order.Paid,
-		&order.CreatedAt,
-		&order.UpdatedAt,
-	)
-	if err != nil {
-		return nil, err
+	return orders, dbErr(rows.Err(), "order")
+}
+
+const insertOrder = `
+	INSERT INTO orders (id, user_id, subtotal, discount_total, tax_total, total, paid, prices_include_tax)
+	VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+	RETURNING ` + orderColumns
+
+func insertOrderArgs(order *model.Orders) []any {
+	return []any{
+		order.ID,
+		order.UserID,
+		order.Subtotal,
+		order.DiscountTotal,
+		order.TaxTotal,
+		order.Total,
+		order.Paid,
+		order.PricesIncludeTax,
 	}
-	return &order, nil
 }
 
-func (r *ordersRepository) GetByUser(ctx context.Context, userId uuid.UUID) ([]*model.Orders, error) {
-	query := `SELECT id, user_id, status, total, paid, created_at, updated_at
-			  FROM orders WHERE user_id = $1 ORDER BY created_at DESC`
+func (r *ordersRepository) Create(ctx context.Context, order *model.Orders) (*model.Orders, error) {
+	return scanOrder(r.db.Pool.QueryRow(ctx, insertOrder, insertOrderArgs(order)...))
+}
+
+// Place stores the order with its items and promotion redemptions and empties
+// the cart, in one transaction, so a checkout that fails part way leaves no
+// order behind and uses up no promotion. ErrPromotionExhausted is returned
+// when a promotion reached a usage limit since the cart was priced.
+func (r *ordersRepository) Place(ctx context.Context, checkout *Checkout) (*model.Orders, error) {
+	tx, err := r.db.Pool.Begin(ctx)
+	if err != nil {
+		return nil, dbErr(err, "order")
+	}
+	defer tx.Rollback(ctx)
 
-	rows, err := r.db.Pool.Query(ctx, query, userId)
+	order, err := scanOrder(tx.QueryRow(ctx, insertOrder, insertOrderArgs(checkout.Order)...))
 	if err != nil {
 		return nil, err
 	}
-	defer rows.Close()
 
-	var orders []*model.Orders
-	for rows.Next() {
-		order := &model.Orders{}
-		if err := rows.Scan(
-			&order.ID,
-			&order.UserID,
-			&order.Status,
-			&order.Total,
-			&order.Paid,
-			&order.CreatedAt,
-			&order.UpdatedAt,
-		); err != nil {
+	for _, redemption := range checkout.Redemptions {
+		if err := redeem(ctx, tx, redemption); err != nil {
 			return nil, err
 		}
-		orders = append(orders, order)
 	}
-	return orders, nil
+
+	if err := insertOrderItems(ctx, tx, checkout.Items); err != nil {
+		return nil, err
+	}
+
+	if _, err := tx.Exec(ctx, `DELETE FROM cart_promotions WHERE cart_id = $1`, checkout.CartID); err != nil {
+		return nil, dbErr(err, "order")
+	}
+
+	if _, err := tx.Exec(ctx, `DELETE FROM cart_items WHERE cart_id = $1`, checkout.CartID); err != nil {
+		return nil, dbErr(err, "order")
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return nil, dbErr(err, "order")
+	}
+
+	return order, nil
+}
+
+func (r *ordersRepository) GetById(ctx context.Context, id uuid.UUID) (*model.Orders, error) {
+	query := `SELECT ` + orderColumns + ` FROM orders WHERE id = $1`
+
+	return scanOrder(r.db.Pool.QueryRow(ctx, query, id))
+}
+
+func (r *ordersRepository) GetByUser(ctx context.Context, userId uuid.UUID) ([]*model.Orders, error) {
+	query := `SELECT ` + orderColumns + ` FROM orders WHERE user_id = $1 ORDER BY created_at DESC`
+
+	return r.queryOrders(ctx, r.db.Pool, query, userId)
 }
 
 func (r *ordersRepository) UpdateStatus(ctx context.Context, orderId uuid.UUID, status model.OrderStatus) error {
 	query := `UPDATE orders SET status = $1, updated_at = $2 WHERE id = $3`
 	_, err := r.db.Pool.Exec(ctx, query, status, time.Now(), orderId)
-	return err
+	return dbErr(err, "order")
 }
 
-func (r *ordersRepository) UpdatePaidStatus(ctx context.Context, orderId uuid.UUID, paid bool) error {
-	query := `UPDATE orders SET paid = $1, updated_at = $2 WHERE id = $3`
-	_, err := r.db.Pool.Exec(ctx, query, paid, time.Now(), orderId)
-	return err
+// MarkPaid records the payment of an order and gives it the next invoice
+// number. The counter is bumped in the same transaction as the order, so a
+// failed payment never uses up a number and invoice numbers have no gaps.
+func (r *ordersRepository) MarkPaid(ctx context.Context, orderId uuid.UUID, paymentReference string) (*model.Orders, error) {
+	tx, err := r.db.Pool.Begin(ctx)
+	if err != nil {
+		return nil, dbErr(err, "order")
+	}
+	defer tx.Rollback(ctx)
+
+	order, err := scanOrder(tx.QueryRow(ctx, `SELECT `+orderColumns+` FROM orders WHERE id = $1 FOR UPDATE`, orderId))
+	if err != nil {
+		return nil, dbErr(err, "order")
+	}
+
+	if order.Paid {
+		return nil, ErrOrderAlreadyPaid
+	}
+
+	if order.Status == model.StatusCancelled {
+		return nil, ErrOrderCancelled
+	}
+
+	var invoiceNumber int64
+	err = tx.QueryRow(ctx,
+		`UPDATE invoice_counter SET last_number = last_number + 1 RETURNING last_number`,
+	).Scan(&invoiceNumber)
+	if err != nil {
+		return nil, dbErr(err, "order")
+	}
+
+	order, err = scanOrder(tx.QueryRow(ctx, `
+		UPDATE orders
+		SET paid = true, paid_at = now(), payment_reference = $1, invoice_number = $2, updated_at = now()
+		WHERE id = $3
+		RETURNING `+orderColumns,
+		paymentReference,
+		invoiceNumber,
+		orderId,
+	))
+	if err != nil {
+		return nil, dbErr(err, "order")
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return nil, dbErr(err, "order")
+	}
+
+	return order, nil
 }
 
-func (r *ordersRepository) GetAll(ctx context.Context) ([]*model.Orders, error) {
-	query := `SELECT id, user_id, status, total, paid, created_at, updated_at
-			  FROM orders ORDER BY created_at DESC`
+// GetAll pages through every order for the back office, on the read
+// replica.
+func (r *ordersRepository) GetAll(ctx context.Context, page pagination.Page) ([]*model.Orders, *pagination.Info, error) {
+	where, order, limit, args := page.Keyset("orders", 1)
+
+	query := `SELECT ` + orderColumns + ` FROM orders WHERE ` + where + ` ORDER BY ` + order + ` LIMIT ` + limit
 
-	rows, err := r.db.Pool.Query(ctx, query)
+	orders, err := r.queryOrders(ctx, r.db.Reads(), query, args...)
 	if err != nil {
-		return nil, err
+		return nil, nil, dbErr(err, "order")
 	}
-	defer rows.Close()
 
-	var orders []*model.Orders
-	for rows.Next() {
-		order := &model.Orders{}
-		if err := rows.Scan(
-			&order.ID,
-			&order.UserID,
-			&order.Status,
-			&order.Total,
-			&order.Paid,
-			&order.CreatedAt,
-			&order.UpdatedAt,
-		); err != nil {
-			return nil, err
+	orders, info := pagination.Trim(page, orders)
+
+	if page.WithTotal {
+		if info.Total, err = countRows(ctx, r.db.Reads(), `SELECT COUNT(*) FROM orders`); err != nil {
+			return nil, nil, dbErr(err, "order")
 		}
-		orders = append(orders, order)
 	}
 
-	return orders, nil
+	return orders, info, nil
 }
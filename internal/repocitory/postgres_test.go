@@ -0,0 +1,45 @@
+package repocitory_test
+
+import (
+	"context"
+	"os"
+	"testing"
+
+	"github.com/Oj-washingtone/savannah-store/internal/database"
+	"github.com/Oj-washingtone/savannah-store/internal/repocitory"
+	"github.com/Oj-washingtone/savannah-store/internal/repocitory/repotest"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// TestContract runs the repository contract against the database at
+// TEST_DATABASE_URL, which must have the migrations applied. Every table in
+// it is emptied, so never point it at a database you want to keep.
+func TestContract(t *testing.T) {
+	url := os.Getenv("TEST_DATABASE_URL")
+	if url == "" {
+		t.Skip("TEST_DATABASE_URL is not set")
+	}
+
+	pool, err := pgxpool.New(context.Background(), url)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(pool.Close)
+
+	db := &database.DB{Pool: pool}
+
+	repotest.Run(t, func(t *testing.T) *repocitory.Repositories {
+		_, err := pool.Exec(context.Background(), `
+			TRUNCATE users, categories, products, product_slug_history, product_options, product_option_values,
+				product_variants, product_variant_values, product_images, product_image_variants, discounts,
+				promotions, promotion_redemptions, cart_promotions, carts, cart_items, cart_reminders,
+				orders, order_items, wishlists, wishlist_items, notification_outbox CASCADE;
+			UPDATE invoice_counter SET last_number = 0;
+		`)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		return repocitory.NewRepositories(db)
+	})
+}
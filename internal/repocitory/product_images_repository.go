@@ -0,0 +1,343 @@
+package repocitory
+
+import (
+	"context"
+
+	"github.com/Oj-washingtone/savannah-store/internal/database"
+	"github.com/Oj-washingtone/savannah-store/internal/model"
+	"github.com/google/uuid"
+)
+
+type ProductImageRepository interface {
+	Create(ctx context.Context, image *model.ProductImage, variants []*model.ProductImageVariant) error
+	GetById(ctx context.Context, id uuid.UUID) (*model.ProductImage, error)
+	ListByProduct(ctx context.Context, productId uuid.UUID) ([]*model.ProductImage, error)
+	ListByProducts(ctx context.Context, productIds []uuid.UUID) (map[uuid.UUID][]*model.ProductImage, error)
+	ListVariants(ctx context.Context, imageIds []uuid.UUID) ([]*model.ProductImageVariant, error)
+	UpdateAltText(ctx context.Context, id uuid.UUID, altText string) error
+	SetVariant(ctx context.Context, id uuid.UUID, variantId *uuid.UUID) error
+	SetPrimary(ctx context.Context, productId, imageId uuid.UUID) error
+	Reorder(ctx context.Context, productId uuid.UUID, imageIds []uuid.UUID) error
+	Delete(ctx context.Context, image *model.ProductImage) error
+}
+
+type productImageRepository struct {
+	db *database.DB
+}
+
+func NewProductImageRepository(db *database.DB) ProductImageRepository {
+	return &productImageRepository{db: db}
+}
+
+// images uploaded before storage keys were recorded have NULL in the newer
+// columns
+const productImageColumns = `id, product_id, url, alt_text, is_primary, position,
+	COALESCE(content_type, ''), size_bytes, width, height, COALESCE(storage_key, ''), variant_id, created_at, updated_at`
+
+func scanProductImage(row interface{ Scan(dest ...any) error }) (*model.ProductImage, error) {
+	var image model.ProductImage
+	err := row.Scan(
+		&image.ID,
+		&image.ProductID,
+		&image.URL,
+		&image.AltText,
+		&image.IsPrimary,
+		&image.Position,
+		&image.ContentType,
+		&image.Size,
+		&image.Width,
+		&image.Height,
+		&image.StorageKey,
+		&image.VariantID,
+		&image.CreatedAt,
+		&image.UpdatedAt,
+	)
+	if err != nil {
+		return nil, dbErr(err, "image")
+	}
+
+	return &image, nil
+}
+
+// Create adds the image after the product's existing images, together with
+// its variants. The first image of a product becomes its primary image.
+func (r *productImageRepository) Create(ctx context.Context, image *model.ProductImage, variants []*model.ProductImageVariant) error {
+	tx, err := r.db.Pool.Begin(ctx)
+	if err != nil {
+		return dbErr(err, "image")
+	}
+	defer tx.Rollback(ctx)
+
+	query := `
+		INSERT INTO product_images (id, product_id, url, alt_text, is_primary, position, content_type, size_bytes, width, height, storage_key)
+		SELECT $1, $2, $3, $4,
+			NOT EXISTS (SELECT 1 FROM product_images WHERE product_id = $2 AND is_primary AND deleted_at IS NULL),
+			COALESCE((SELECT MAX(position) + 1 FROM product_images WHERE product_id = $2 AND deleted_at IS NULL), 0),
+			$5, $6, $7, $8, $9
+		RETURNING is_primary, position, created_at, updated_at
+	`
+
+	err = tx.QueryRow(ctx, query,
+		image.ID,
+		image.ProductID,
+		image.URL,
+		image.AltText,
+		image.ContentType,
+		image.Size,
+		image.Width,
+		image.Height,
+		image.StorageKey,
+	).Scan(&image.IsPrimary, &image.Position, &image.CreatedAt, &image.UpdatedAt)
+	if err != nil {
+		return dbErr(err, "image")
+	}
+
+	for _, variant := range variants {
+		err = tx.QueryRow(ctx, `
+			INSERT INTO product_image_variants (id, image_id, name, content_type, width, height, size_bytes, storage_key, url)
+			VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+			RETURNING created_at, updated_at`,
+			variant.ID,
+			variant.ImageID,
+			variant.Name,
+			variant.ContentType,
+			variant.Width,
+			variant.Height,
+			variant.Size,
+			variant.StorageKey,
+			variant.URL,
+		).Scan(&variant.CreatedAt, &variant.UpdatedAt)
+		if err != nil {
+			return dbErr(err, "image")
+		}
+	}
+
+	return dbErr(tx.Commit(ctx), "image")
+}
+
+func (r *productImageRepository) GetById(ctx context.Context, id uuid.UUID) (*model.ProductImage, error) {
+	query := `SELECT ` + productImageColumns + ` FROM product_images WHERE id = $1 AND deleted_at IS NULL`
+
+	image, err := scanProductImage(r.db.Pool.QueryRow(ctx, query, id))
+	if err != nil {
+		return nil, dbErr(err, "image")
+	}
+
+	if err := r.loadVariants(ctx, image); err != nil {
+		return nil, dbErr(err, "image")
+	}
+
+	return image, nil
+}
+
+func (r *productImageRepository) ListByProduct(ctx context.Context, productId uuid.UUID) ([]*model.ProductImage, error) {
+	images, err := r.ListByProducts(ctx, []uuid.UUID{productId})
+	if err != nil {
+		return nil, dbErr(err, "image")
+	}
+
+	return images[productId], nil
+}
+
+// ListByProducts returns the images of several products at once, keyed by
+// product and in display order.
+func (r *productImageRepository) ListByProducts(ctx context.Context, productIds []uuid.UUID) (map[uuid.UUID][]*model.ProductImage, error) {
+	query := `
+		SELECT ` + productImageColumns + `
+		FROM product_images
+		WHERE product_id = ANY($1) AND deleted_at IS NULL
+		ORDER BY product_id, position, created_at
+	`
+
+	rows, err := r.db.Pool.Query(ctx, query, productIds)
+	if err != nil {
+		return nil, dbErr(err, "image")
+	}
+	defer rows.Close()
+
+	images := map[uuid.UUID][]*model.ProductImage{}
+	var all []*model.ProductImage
+
+	for rows.Next() {
+		image, err := scanProductImage(rows)
+		if err != nil {
+			return nil, dbErr(err, "image")
+		}
+
+		images[image.ProductID] = append(images[image.ProductID], image)
+		all = append(all, image)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, dbErr(err, "image")
+	}
+
+	if err := r.loadVariants(ctx, all...); err != nil {
+		return nil, dbErr(err, "image")
+	}
+
+	return images, nil
+}
+
+// loadVariants fills in the Variants of the images.
+func (r *productImageRepository) loadVariants(ctx context.Context, images ...*model.ProductImage) error {
+	if len(images) == 0 {
+		return nil
+	}
+
+	byId := map[uuid.UUID]*model.ProductImage{}
+	imageIds := make([]uuid.UUID, len(images))
+
+	for i, image := range images {
+		image.Variants = map[string]*model.ImageVariant{}
+		byId[image.ID] = image
+		imageIds[i] = image.ID
+	}
+
+	variants, err := r.ListVariants(ctx, imageIds)
+	if err != nil {
+		return dbErr(err, "image")
+	}
+
+	for _, v := range variants {
+		byId[v.ImageID].AddVariant(v)
+	}
+
+	return nil
+}
+
+func (r *productImageRepository) ListVariants(ctx context.Context, imageIds []uuid.UUID) ([]*model.ProductImageVariant, error) {
+	query := `
+		SELECT id, image_id, name, content_type, width, height, size_bytes, storage_key, url, created_at, updated_at
+		FROM product_image_variants
+		WHERE image_id = ANY($1)
+		ORDER BY image_id, width, content_type
+	`
+
+	rows, err := r.db.Pool.Query(ctx, query, imageIds)
+	if err != nil {
+		return nil, dbErr(err, "image")
+	}
+	defer rows.Close()
+
+	var variants []*model.ProductImageVariant
+	for rows.Next() {
+		v := &model.ProductImageVariant{}
+		if err := rows.Scan(
+			&v.ID,
+			&v.ImageID,
+			&v.Name,
+			&v.ContentType,
+			&v.Width,
+			&v.Height,
+			&v.Size,
+			&v.StorageKey,
+			&v.URL,
+			&v.CreatedAt,
+			&v.UpdatedAt,
+		); err != nil {
+			return nil, dbErr(err, "image")
+		}
+		variants = append(variants, v)
+	}
+
+	return variants, dbErr(rows.Err(), "image")
+}
+
+func (r *productImageRepository) UpdateAltText(ctx context.Context, id uuid.UUID, altText string) error {
+	query := `UPDATE product_images SET alt_text = $1, updated_at = now() WHERE id = $2 AND deleted_at IS NULL`
+	_, err := r.db.Pool.Exec(ctx, query, altText, id)
+	return dbErr(err, "image")
+}
+
+// SetVariant ties the image to a variant of its product, or to the product as
+// a whole when variantId is nil.
+func (r *productImageRepository) SetVariant(ctx context.Context, id uuid.UUID, variantId *uuid.UUID) error {
+	query := `UPDATE product_images SET variant_id = $1, updated_at = now() WHERE id = $2 AND deleted_at IS NULL`
+	_, err := r.db.Pool.Exec(ctx, query, variantId, id)
+	return dbErr(err, "image")
+}
+
+// SetPrimary makes the image the primary image of its product. The old
+// primary is cleared first so the one-primary index is never violated.
+func (r *productImageRepository) SetPrimary(ctx context.Context, productId, imageId uuid.UUID) error {
+	tx, err := r.db.Pool.Begin(ctx)
+	if err != nil {
+		return dbErr(err, "image")
+	}
+	defer tx.Rollback(ctx)
+
+	_, err = tx.Exec(ctx,
+		`UPDATE product_images SET is_primary = false, updated_at = now() WHERE product_id = $1 AND is_primary AND id <> $2`,
+		productId, imageId,
+	)
+	if err != nil {
+		return dbErr(err, "image")
+	}
+
+	_, err = tx.Exec(ctx,
+		`UPDATE product_images SET is_primary = true, updated_at = now() WHERE id = $1 AND product_id = $2 AND deleted_at IS NULL`,
+		imageId, productId,
+	)
+	if err != nil {
+		return dbErr(err, "image")
+	}
+
+	return dbErr(tx.Commit(ctx), "image")
+}
+
+// Reorder gives the images the positions of their place in imageIds. Images
+// of the product left out of the list keep their relative order after them.
+func (r *productImageRepository) Reorder(ctx context.Context, productId uuid.UUID, imageIds []uuid.UUID) error {
+	query := `
+		UPDATE product_images pi
+		SET position = ordered.position, updated_at = now()
+		FROM (
+			SELECT id, ROW_NUMBER() OVER (
+				ORDER BY COALESCE(array_position($2::uuid[], id), cardinality($2::uuid[]) + 1), position, created_at
+			) - 1 AS position
+			FROM product_images
+			WHERE product_id = $1 AND deleted_at IS NULL
+		) ordered
+		WHERE pi.id = ordered.id
+	`
+
+	_, err := r.db.Pool.Exec(ctx, query, productId, imageIds)
+	return dbErr(err, "image")
+}
+
+// Delete soft deletes the image. If it was the primary image, the next image
+// in line takes over.
+func (r *productImageRepository) Delete(ctx context.Context, image *model.ProductImage) error {
+	tx, err := r.db.Pool.Begin(ctx)
+	if err != nil {
+		return dbErr(err, "image")
+	}
+	defer tx.Rollback(ctx)
+
+	_, err = tx.Exec(ctx,
+		`UPDATE product_images SET deleted_at = now(), is_primary = false WHERE id = $1 AND deleted_at IS NULL`,
+		image.ID,
+	)
+	if err != nil {
+		return dbErr(err, "image")
+	}
+
+	if image.IsPrimary {
+		_, err = tx.Exec(ctx, `
+			UPDATE product_images SET is_primary = true, updated_at = now()
+			WHERE id = (
+				SELECT id FROM product_images
+				WHERE product_id = $1 AND deleted_at IS NULL
+				ORDER BY position, created_at
+				LIMIT 1
+			)`,
+			image.ProductID,
+		)
+		if err != nil {
+			return dbErr(err, "image")
+		}
+	}
+
+	return dbErr(tx.Commit(ctx), "image")
+}
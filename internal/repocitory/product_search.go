@@ -0,0 +1,261 @@
+package repocitory
+
+import (
+	"context"
+	"strconv"
+	"strings"
+
+	"github.com/Oj-washingtone/savannah-store/internal/model"
+	"github.com/Oj-washingtone/savannah-store/internal/pagination"
+)
+
+// effectivePrice is the price of products p after the discount joined by
+// activeDiscountJoin, matching model.Product.ApplyDiscount.
+const effectivePrice = `CASE ad.type
+			WHEN 'percentage' THEN p.price - p.price * ad.value / 100
+			WHEN 'fixed' THEN GREATEST(p.price - ad.value, 0)
+			ELSE p.price
+		END`
+
+// searchQuery collects the conditions and arguments of a search query.
+type searchQuery struct {
+	conditions []string
+	args       []any
+}
+
+func (q *searchQuery) arg(value any) string {
+	q.args = append(q.args, value)
+	return "$" + strconv.Itoa(len(q.args))
+}
+
+func (q *searchQuery) where() string {
+	return strings.Join(q.conditions, " AND ")
+}
+
+// searchConditions builds the WHERE clause of the search. The facets leave
+// out their own filter, skipCategory and skipPrice do that.
+func searchConditions(s *model.ProductSearch, skipCategory, skipPrice bool) *searchQuery {
+	q := &searchQuery{conditions: []string{"p.deleted_at IS NULL"}}
+
+	if s.Query != "" {
+		text := q.arg(s.Query)
+		q.conditions = append(q.conditions,
+			`(p.search_vector @@ websearch_to_tsquery('english', `+text+`) OR `+text+` <% p.name)`)
+	}
+
+	if s.CategoryID != nil && !skipCategory {
+		q.conditions = append(q.conditions, `p.category_id IN (`+categoryDescendants(q.arg(*s.CategoryID))+`
+		)`)
+	}
+
+	if !skipPrice {
+		if s.MinPrice != nil {
+			q.conditions = append(q.conditions, effectivePrice+` >= `+q.arg(*s.MinPrice))
+		}
+
+		if s.MaxPrice != nil {
+			q.conditions = append(q.conditions, effectivePrice+` <= `+q.arg(*s.MaxPrice))
+		}
+	}
+
+	if s.InStock {
+		q.conditions = append(q.conditions, `p.stock > 0`)
+	}
+
+	if s.OnSale {
+		q.conditions = append(q.conditions, `ad.id IS NOT NULL`)
+	}
+
+	return q
+}
+
+// searchKey is the sort key of a search: an expression, its SQL type and
+// whether it sorts descending. Products with the same key come newest first.
+type searchKey struct {
+	expr    string
+	sqlType string
+	desc    bool
+}
+
+// searchSortKey is the key of the sort, adding to q the arguments it needs,
+// or nil when the search is sorted newest first. Relevance needs a query and
+// falls back to newest without one.
+func searchSortKey(s *model.ProductSearch, q *searchQuery) *searchKey {
+	switch s.Sort {
+	case model.SortPriceAsc:
+		return &searchKey{effectivePrice, "bigint", false}
+	case model.SortPriceDesc:
+		return &searchKey{effectivePrice, "bigint", true}
+	case model.SortName:
+		return &searchKey{"p.name", "text", false}
+	case model.SortNewest:
+		return nil
+	}
+
+	if s.Query == "" {
+		return nil
+	}
+
+	text := q.arg(s.Query)
+
+	return &searchKey{
+		expr:    `ts_rank(p.search_vector, websearch_to_tsquery('english', ` + text + `)) + word_similarity(` + text + `, p.name)`,
+		sqlType: "real",
+		desc:    true,
+	}
+}
+
+// Search finds the products matching the search, one page at a time, with
+// the total number of matches and the facet counts. It reads from the
+// replica.
+func (r *productRepository) Search(ctx context.Context, s *model.ProductSearch) (*model.ProductSearchResult, *pagination.Info, error) {
+	result := &model.ProductSearchResult{Products: []*model.Product{}}
+
+	q := searchConditions(s, false, false)
+
+	countQuery := `SELECT COUNT(*) FROM products p ` + activeDiscountJoin + ` WHERE ` + q.where()
+
+	if err := r.db.Reads().QueryRow(ctx, countQuery, q.args...).Scan(&result.Total); err != nil {
+		return nil, nil, dbErr(err, "product")
+	}
+
+	// the key is selected as text for the cursors
+	keyColumn := `''`
+	var where, order, limit string
+	var args []any
+
+	if key := searchSortKey(s, q); key != nil {
+		keyColumn = `(` + key.expr + `)::text`
+		where, order, limit, args = s.Page.KeysetBy("p", key.expr, key.sqlType, key.desc, len(q.args)+1)
+	} else {
+		where, order, limit, args = s.Page.Keyset("p", len(q.args)+1)
+	}
+
+	query := `
+		SELECT ` + prefixColumns("p", productColumns) + `,
+			` + activeDiscountColumns + `,
+			` + keyColumn + `
+		FROM products p
+		` + activeDiscountJoin + `
+		WHERE ` + q.where() + ` AND ` + where + `
+		ORDER BY ` + order + `
+		LIMIT ` + limit
+
+	rows, err := r.db.Reads().Query(ctx, query, append(q.args, args...)...)
+	if err != nil {
+		return nil, nil, dbErr(err, "product")
+	}
+	defer rows.Close()
+
+	var found []pagination.Sorted[*model.Product]
+	for rows.Next() {
+		var key string
+
+		p, err := scanProductWithDiscount(withKey{rows, &key})
+		if err != nil {
+			return nil, nil, dbErr(err, "product")
+		}
+		found = append(found, pagination.Sorted[*model.Product]{Row: p, Key: key})
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, nil, dbErr(err, "product")
+	}
+
+	found, info := pagination.Trim(s.Page, found)
+	result.Products = pagination.Rows(found)
+
+	if s.Page.WithTotal {
+		info.Total = &result.Total
+	}
+
+	if result.Facets.Categories, err = r.categoryFacets(ctx, s); err != nil {
+		return nil, nil, dbErr(err, "product")
+	}
+
+	if result.Facets.PriceBuckets, err = r.priceFacets(ctx, s); err != nil {
+		return nil, nil, dbErr(err, "product")
+	}
+
+	return result, info, nil
+}
+
+// categoryFacets counts the matches in each category they are directly in.
+func (r *productRepository) categoryFacets(ctx context.Context, s *model.ProductSearch) ([]*model.CategoryFacet, error) {
+	q := searchConditions(s, true, false)
+
+	query := `
+		SELECT c.id, c.name, COUNT(*)
+		FROM products p
+		` + activeDiscountJoin + `
+		JOIN categories c ON c.id = p.category_id
+		WHERE ` + q.where() + `
+		GROUP BY c.id, c.name
+		ORDER BY COUNT(*) DESC, c.name
+	`
+
+	rows, err := r.db.Reads().Query(ctx, query, q.args...)
+	if err != nil {
+		return nil, dbErr(err, "product")
+	}
+	defer rows.Close()
+
+	facets := []*model.CategoryFacet{}
+	for rows.Next() {
+		var f model.CategoryFacet
+		if err := rows.Scan(&f.CategoryID, &f.Name, &f.Count); err != nil {
+			return nil, dbErr(err, "product")
+		}
+		facets = append(facets, &f)
+	}
+
+	return facets, dbErr(rows.Err(), "product")
+}
+
+// priceFacets counts the matches in each bucket of model.PriceBucketBounds,
+// including empty buckets.
+func (r *productRepository) priceFacets(ctx context.Context, s *model.ProductSearch) ([]*model.PriceBucketFacet, error) {
+	q := searchConditions(s, false, true)
+
+	// width_bucket gives 0 below the first bound and len(bounds) from the last
+	query := `
+		SELECT width_bucket(` + effectivePrice + `, ` + q.arg(model.PriceBucketBounds) + `::BIGINT[]) AS bucket, COUNT(*)
+		FROM products p
+		` + activeDiscountJoin + `
+		WHERE ` + q.where() + `
+		GROUP BY bucket
+	`
+
+	rows, err := r.db.Reads().Query(ctx, query, q.args...)
+	if err != nil {
+		return nil, dbErr(err, "product")
+	}
+	defer rows.Close()
+
+	bounds := model.PriceBucketBounds
+	facets := make([]*model.PriceBucketFacet, len(bounds)+1)
+
+	for i := range facets {
+		facets[i] = &model.PriceBucketFacet{}
+		if i > 0 {
+			facets[i].Min = bounds[i-1]
+		}
+		if i < len(bounds) {
+			upper := bounds[i]
+			facets[i].Max = &upper
+		}
+	}
+
+	for rows.Next() {
+		var bucket, count int
+		if err := rows.Scan(&bucket, &count); err != nil {
+			return nil, dbErr(err, "product")
+		}
+
+		if bucket >= 0 && bucket < len(facets) {
+			facets[bucket].Count = count
+		}
+	}
+
+	return facets, dbErr(rows.Err(), "product")
+}
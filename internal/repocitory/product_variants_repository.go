@@ -0,0 +1,365 @@
+package repocitory
+
+import (
+	"context"
+
+	"github.com/Oj-washingtone/savannah-store/internal/database"
+	"github.com/Oj-washingtone/savannah-store/internal/model"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+)
+
+type ProductVariantRepository interface {
+	ListOptions(ctx context.Context, productId uuid.UUID) ([]*model.ProductOption, error)
+	CreateOption(ctx context.Context, option *model.ProductOption) error
+	AddOptionValue(ctx context.Context, value *model.ProductOptionValue) error
+	DeleteOption(ctx context.Context, optionId uuid.UUID) error
+
+	GetById(ctx context.Context, id uuid.UUID) (*model.ProductVariant, error)
+	GetByIdIncludingDeleted(ctx context.Context, id uuid.UUID) (*model.ProductVariant, error)
+	ListByProduct(ctx context.Context, productId uuid.UUID) ([]*model.ProductVariant, error)
+	Create(ctx context.Context, variant *model.ProductVariant, valueIds []uuid.UUID) error
+	Update(ctx context.Context, variant *model.ProductVariant) error
+	Delete(ctx context.Context, variant *model.ProductVariant) error
+	SKUTaken(ctx context.Context, sku string, productId, excludeId uuid.UUID) (bool, error)
+}
+
+type productVariantRepository struct {
+	db *database.DB
+}
+
+func NewProductVariantRepository(db *database.DB) ProductVariantRepository {
+	return &productVariantRepository{db: db}
+}
+
+// syncProductStock keeps products.stock at the total stock of the product's
+// variants, which is what listings and stock alerts read.
+func syncProductStock(ctx context.Context, tx pgx.Tx, productId uuid.UUID) error {
+	_, err := tx.Exec(ctx, `
+		UPDATE products
+		SET stock = (SELECT COALESCE(SUM(stock), 0) FROM product_variants WHERE product_id = $1 AND deleted_at IS NULL),
+			updated_at = now()
+		WHERE id = $1`,
+		productId,
+	)
+	return dbErr(err, "variant")
+}
+
+func (r *productVariantRepository) ListOptions(ctx context.Context, productId uuid.UUID) ([]*model.ProductOption, error) {
+	query := `
+		SELECT o.id, o.product_id, o.name, o.position, o.created_at, o.updated_at, v.id, v.value, v.position
+		FROM product_options o
+		LEFT JOIN product_option_values v ON v.option_id = o.id
+		WHERE o.product_id = $1
+		ORDER BY o.position, o.created_at, v.position
+	`
+
+	rows, err := r.db.Pool.Query(ctx, query, productId)
+	if err != nil {
+		return nil, dbErr(err, "variant")
+	}
+	defer rows.Close()
+
+	var options []*model.ProductOption
+	byId := map[uuid.UUID]*model.ProductOption{}
+
+	for rows.Next() {
+		var option model.ProductOption
+		var valueId *uuid.UUID
+		var value *string
+		var valuePosition *int
+
+		if err := rows.Scan(
+			&option.ID,
+			&option.ProductID,
+			&option.Name,
+			&option.Position,
+			&option.CreatedAt,
+			&option.UpdatedAt,
+			&valueId,
+			&value,
+			&valuePosition,
+		); err != nil {
+			return nil, dbErr(err, "variant")
+		}
+
+		existing, ok := byId[option.ID]
+		if !ok {
+			option.Values = []*model.ProductOptionValue{}
+			existing = &option
+			byId[option.ID] = existing
+			options = append(options, existing)
+		}
+
+		if valueId != nil {
+			existing.Values = append(existing.Values, &model.ProductOptionValue{
+				ID:       *valueId,
+				OptionID: option.ID,
+				Value:    *value,
+				Position: *valuePosition,
+			})
+		}
+	}
+
+	return options, dbErr(rows.Err(), "variant")
+}
+
+// CreateOption adds the option and its values after the product's existing
+// options.
+func (r *productVariantRepository) CreateOption(ctx context.Context, option *model.ProductOption) error {
+	tx, err := r.db.Pool.Begin(ctx)
+	if err != nil {
+		return dbErr(err, "variant")
+	}
+	defer tx.Rollback(ctx)
+
+	err = tx.QueryRow(ctx, `
+		INSERT INTO product_options (id, product_id, name, position)
+		SELECT $1, $2, $3, COALESCE((SELECT MAX(position) + 1 FROM product_options WHERE product_id = $2), 0)
+		RETURNING position, created_at, updated_at`,
+		option.ID,
+		option.ProductID,
+		option.Name,
+	).Scan(&option.Position, &option.CreatedAt, &option.UpdatedAt)
+	if err != nil {
+		return dbErr(err, "variant")
+	}
+
+	for _, value := range option.Values {
+		_, err = tx.Exec(ctx,
+			`INSERT INTO product_option_values (id, option_id, value, position) VALUES ($1, $2, $3, $4)`,
+			value.ID, option.ID, value.Value, value.Position,
+		)
+		if err != nil {
+			return dbErr(err, "variant")
+		}
+	}
+
+	return dbErr(tx.Commit(ctx), "variant")
+}
+
+func (r *productVariantRepository) AddOptionValue(ctx context.Context, value *model.ProductOptionValue) error {
+	query := `INSERT INTO product_option_values (id, option_id, value, position) VALUES ($1, $2, $3, $4)`
+	_, err := r.db.Pool.Exec(ctx, query, value.ID, value.OptionID, value.Value, value.Position)
+	return dbErr(err, "variant")
+}
+
+// DeleteOption removes the option and its values. Deleted variants lose the
+// value they had, while a live variant using one of the values makes it fail.
+func (r *productVariantRepository) DeleteOption(ctx context.Context, optionId uuid.UUID) error {
+	tx, err := r.db.Pool.Begin(ctx)
+	if err != nil {
+		return dbErr(err, "variant")
+	}
+	defer tx.Rollback(ctx)
+
+	_, err = tx.Exec(ctx, `
+		DELETE FROM product_variant_values vv
+		USING product_variants pv, product_option_values ov
+		WHERE pv.id = vv.variant_id AND pv.deleted_at IS NOT NULL
+			AND ov.id = vv.option_value_id AND ov.option_id = $1`,
+		optionId,
+	)
+	if err != nil {
+		return dbErr(err, "variant")
+	}
+
+	if _, err := tx.Exec(ctx, `DELETE FROM product_options WHERE id = $1`, optionId); err != nil {
+		return dbErr(err, "variant")
+	}
+
+	return dbErr(tx.Commit(ctx), "variant")
+}
+
+const productVariantColumns = `pv.id, pv.product_id, pv.sku, pv.price, pv.stock, pv.position, pv.created_at, pv.updated_at,
+	COALESCE(
+		(SELECT jsonb_object_agg(o.name, ov.value)
+		FROM product_variant_values vv
+		JOIN product_option_values ov ON ov.id = vv.option_value_id
+		JOIN product_options o ON o.id = ov.option_id
+		WHERE vv.variant_id = pv.id),
+		'{}'::jsonb
+	)`
+
+func scanProductVariant(row interface{ Scan(dest ...any) error }) (*model.ProductVariant, error) {
+	var v model.ProductVariant
+	err := row.Scan(
+		&v.ID,
+		&v.ProductID,
+		&v.SKU,
+		&v.Price,
+		&v.Stock,
+		&v.Position,
+		&v.CreatedAt,
+		&v.UpdatedAt,
+		&v.Options,
+	)
+	if err != nil {
+		return nil, dbErr(err, "variant")
+	}
+
+	v.Images = []*model.ProductImage{}
+
+	return &v, nil
+}
+
+func (r *productVariantRepository) GetById(ctx context.Context, id uuid.UUID) (*model.ProductVariant, error) {
+	query := `SELECT ` + productVariantColumns + ` FROM product_variants pv WHERE pv.id = $1 AND pv.deleted_at IS NULL`
+
+	return scanProductVariant(r.db.Pool.QueryRow(ctx, query, id))
+}
+
+// GetByIdIncludingDeleted also finds deleted variants, for describing what
+// was ordered.
+func (r *productVariantRepository) GetByIdIncludingDeleted(ctx context.Context, id uuid.UUID) (*model.ProductVariant, error) {
+	query := `SELECT ` + productVariantColumns + ` FROM product_variants pv WHERE pv.id = $1`
+
+	return scanProductVariant(r.db.Pool.QueryRow(ctx, query, id))
+}
+
+func (r *productVariantRepository) ListByProduct(ctx context.Context, productId uuid.UUID) ([]*model.ProductVariant, error) {
+	query := `
+		SELECT ` + productVariantColumns + `
+		FROM product_variants pv
+		WHERE pv.product_id = $1 AND pv.deleted_at IS NULL
+		ORDER BY pv.position, pv.created_at
+	`
+
+	rows, err := r.db.Pool.Query(ctx, query, productId)
+	if err != nil {
+		return nil, dbErr(err, "variant")
+	}
+	defer rows.Close()
+
+	var variants []*model.ProductVariant
+	for rows.Next() {
+		v, err := scanProductVariant(rows)
+		if err != nil {
+			return nil, dbErr(err, "variant")
+		}
+		variants = append(variants, v)
+	}
+
+	return variants, dbErr(rows.Err(), "variant")
+}
+
+// Create adds the variant with the given option values. The first variant
+// with options replaces the option-less variant the product had while it was
+// a simple product.
+func (r *productVariantRepository) Create(ctx context.Context, variant *model.ProductVariant, valueIds []uuid.UUID) error {
+	tx, err := r.db.Pool.Begin(ctx)
+	if err != nil {
+		return dbErr(err, "variant")
+	}
+	defer tx.Rollback(ctx)
+
+	err = tx.QueryRow(ctx, `
+		INSERT INTO product_variants (id, product_id, sku, price, stock, position)
+		SELECT $1, $2, $3, $4, $5, COALESCE((SELECT MAX(position) + 1 FROM product_variants WHERE product_id = $2 AND deleted_at IS NULL), 0)
+		RETURNING position, created_at, updated_at`,
+		variant.ID,
+		variant.ProductID,
+		variant.SKU,
+		variant.Price,
+		variant.Stock,
+	).Scan(&variant.Position, &variant.CreatedAt, &variant.UpdatedAt)
+	if err != nil {
+		return dbErr(err, "variant")
+	}
+
+	for _, valueId := range valueIds {
+		_, err = tx.Exec(ctx,
+			`INSERT INTO product_variant_values (variant_id, option_value_id) VALUES ($1, $2)`,
+			variant.ID, valueId,
+		)
+		if err != nil {
+			return dbErr(err, "variant")
+		}
+	}
+
+	if len(valueIds) > 0 {
+		_, err = tx.Exec(ctx, `
+			UPDATE product_variants pv
+			SET deleted_at = now()
+			WHERE pv.product_id = $1
+				AND pv.deleted_at IS NULL
+				AND NOT EXISTS (SELECT 1 FROM product_variant_values vv WHERE vv.variant_id = pv.id)`,
+			variant.ProductID,
+		)
+		if err != nil {
+			return dbErr(err, "variant")
+		}
+	}
+
+	if err := syncProductStock(ctx, tx, variant.ProductID); err != nil {
+		return dbErr(err, "variant")
+	}
+
+	return dbErr(tx.Commit(ctx), "variant")
+}
+
+func (r *productVariantRepository) Update(ctx context.Context, variant *model.ProductVariant) error {
+	tx, err := r.db.Pool.Begin(ctx)
+	if err != nil {
+		return dbErr(err, "variant")
+	}
+	defer tx.Rollback(ctx)
+
+	err = tx.QueryRow(ctx, `
+		UPDATE product_variants
+		SET sku = $1, price = $2, stock = $3, updated_at = now()
+		WHERE id = $4
+		RETURNING updated_at`,
+		variant.SKU,
+		variant.Price,
+		variant.Stock,
+		variant.ID,
+	).Scan(&variant.UpdatedAt)
+	if err != nil {
+		return dbErr(err, "variant")
+	}
+
+	if err := syncProductStock(ctx, tx, variant.ProductID); err != nil {
+		return dbErr(err, "variant")
+	}
+
+	return dbErr(tx.Commit(ctx), "variant")
+}
+
+// Delete soft deletes the variant, keeping its option values so past orders
+// can still describe it. Its SKU is freed for reuse.
+func (r *productVariantRepository) Delete(ctx context.Context, variant *model.ProductVariant) error {
+	tx, err := r.db.Pool.Begin(ctx)
+	if err != nil {
+		return dbErr(err, "variant")
+	}
+	defer tx.Rollback(ctx)
+
+	_, err = tx.Exec(ctx,
+		`UPDATE product_variants SET deleted_at = now() WHERE id = $1 AND deleted_at IS NULL`,
+		variant.ID,
+	)
+	if err != nil {
+		return dbErr(err, "variant")
+	}
+
+	if err := syncProductStock(ctx, tx, variant.ProductID); err != nil {
+		return dbErr(err, "variant")
+	}
+
+	return dbErr(tx.Commit(ctx), "variant")
+}
+
+// SKUTaken reports whether another variant, or another product than the
+// variant's own, already uses the SKU.
+func (r *productVariantRepository) SKUTaken(ctx context.Context, sku string, productId, excludeId uuid.UUID) (bool, error) {
+	query := `SELECT EXISTS (
+		SELECT 1 FROM product_variants WHERE sku = $1 AND id <> $3 AND deleted_at IS NULL
+	) OR EXISTS (
+		SELECT 1 FROM products WHERE sku = $1 AND id <> $2 AND deleted_at IS NULL
+	)`
+
+	var taken bool
+	err := r.db.Pool.QueryRow(ctx, query, sku, productId, excludeId).Scan(&taken)
+	return taken, dbErr(err, "variant")
+}
@@ -5,121 +5,385 @@ import (
 
 	"github.com/Oj-washingtone/savannah-store/internal/database"
 	"github.com/Oj-washingtone/savannah-store/internal/model"
+	"github.com/Oj-washingtone/savannah-store/internal/pagination"
 	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
 )
 
 type ProductRepository interface {
 	Create(ctx context.Context, product *model.Product) error
 	GetById(ctx context.Context, id uuid.UUID) (*model.Product, error)
-	List(ctx context.Context, limit, offset int) ([]model.Product, error)
+	GetBySlug(ctx context.Context, slug string) (*model.Product, error)
+	GetBySKU(ctx context.Context, sku string) (*model.Product, error)
+	SlugRedirect(ctx context.Context, oldSlug string) (string, error)
+	SlugTaken(ctx context.Context, slug string, productId uuid.UUID) (bool, error)
+	SKUTaken(ctx context.Context, sku string, productId uuid.UUID) (bool, error)
+	List(ctx context.Context, page pagination.Page) ([]model.Product, *pagination.Info, error)
+	ListByCategory(ctx context.Context, categoryId uuid.UUID, page pagination.Page) ([]model.Product, *pagination.Info, error)
+	Search(ctx context.Context, search *model.ProductSearch) (*model.ProductSearchResult, *pagination.Info, error)
 	Update(ctx context.Context, product *model.Product) error
+	UpdateWithStock(ctx context.Context, product *model.Product, variant *model.ProductVariant) error
 	Delete(ctx context.Context, id uuid.UUID) error
 }
 
+// productColumns are the columns scanProductWithDiscount reads, in order.
+const productColumns = `id, category_id, name, slug, sku, description, price, stock, tax_class, meta_title, meta_description, created_at, updated_at`
+
 type productRepository struct {
 	db *database.DB
 }
 
-func NewProductRepository() ProductRepository {
-	return &productRepository{db: database.GetDB()}
+func NewProductRepository(db *database.DB) ProductRepository {
+	return &productRepository{db: db}
 }
 
+// Create adds the product together with its default variant, which holds the
+// stock until the product gets options.
 func (r *productRepository) Create(ctx context.Context, product *model.Product) error {
+	tx, err := r.db.Pool.Begin(ctx)
+	if err != nil {
+		return dbErr(err, "product")
+	}
+	defer tx.Rollback(ctx)
+
 	query := `
-		INSERT INTO products (id, category_id, name, description, price, stock)
-		VALUES ($1, $2, $3, $4, $5, $6)
+		INSERT INTO products (id, category_id, name, slug, sku, description, price, stock, tax_class, meta_title, meta_description)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)
 		RETURNING created_at, updated_at
 	`
 
-	return r.db.Pool.QueryRow(ctx, query,
+	err = tx.QueryRow(ctx, query,
 		product.ID,
 		product.CategoryID,
 		product.Name,
+		product.Slug,
+		product.SKU,
 		product.Description,
 		product.Price,
 		product.Stock,
+		product.TaxClass,
+		product.MetaTitle,
+		product.MetaDescription,
 	).Scan(&product.CreatedAt, &product.UpdatedAt)
+	if err != nil {
+		return dbErr(err, "product")
+	}
+
+	_, err = tx.Exec(ctx,
+		`INSERT INTO product_variants (id, product_id, stock) VALUES ($1, $2, $3)`,
+		uuid.New(), product.ID, product.Stock,
+	)
+	if err != nil {
+		return dbErr(err, "product")
+	}
+
+	// the slug may be the old slug of a deleted product, which stops
+	// redirecting there
+	if _, err = tx.Exec(ctx, `DELETE FROM product_slug_history WHERE slug = $1`, product.Slug); err != nil {
+		return dbErr(err, "product")
+	}
+
+	return dbErr(tx.Commit(ctx), "product")
 }
 
 func (r *productRepository) GetById(ctx context.Context, id uuid.UUID) (*model.Product, error) {
 	query := `
-		SELECT id, category_id, name, description, price, stock, created_at, updated_at
-		FROM products
-		WHERE id = $1 AND deleted_at IS NULL
+		SELECT ` + prefixColumns("p", productColumns) + `,
+			` + activeDiscountColumns + `
+		FROM products p
+		` + activeDiscountJoin + `
+		WHERE p.id = $1 AND p.deleted_at IS NULL
+	`
+
+	return scanProductWithDiscount(r.db.Pool.QueryRow(ctx, query, id))
+}
+
+func (r *productRepository) GetBySlug(ctx context.Context, slug string) (*model.Product, error) {
+	query := `
+		SELECT ` + prefixColumns("p", productColumns) + `,
+			` + activeDiscountColumns + `
+		FROM products p
+		` + activeDiscountJoin + `
+		WHERE p.slug = $1 AND p.deleted_at IS NULL
+	`
+
+	return scanProductWithDiscount(r.db.Pool.QueryRow(ctx, query, slug))
+}
+
+// GetBySKU finds the product with the SKU, or the product one of whose
+// variants has it.
+func (r *productRepository) GetBySKU(ctx context.Context, sku string) (*model.Product, error) {
+	query := `
+		SELECT ` + prefixColumns("p", productColumns) + `,
+			` + activeDiscountColumns + `
+		FROM products p
+		` + activeDiscountJoin + `
+		WHERE p.deleted_at IS NULL AND (
+			p.sku = $1
+			OR p.id IN (SELECT product_id FROM product_variants WHERE sku = $1 AND deleted_at IS NULL)
+		)
+		ORDER BY COALESCE(p.sku = $1, FALSE) DESC
+		LIMIT 1
+	`
+
+	return scanProductWithDiscount(r.db.Pool.QueryRow(ctx, query, sku))
+}
+
+// SlugRedirect returns the current slug of the live product that used to
+// have oldSlug.
+func (r *productRepository) SlugRedirect(ctx context.Context, oldSlug string) (string, error) {
+	query := `
+		SELECT p.slug
+		FROM product_slug_history h
+		JOIN products p ON p.id = h.product_id
+		WHERE h.slug = $1 AND p.deleted_at IS NULL
 	`
 
-	row := r.db.Pool.QueryRow(ctx, query, id)
+	var slug string
+	err := r.db.Pool.QueryRow(ctx, query, oldSlug).Scan(&slug)
+	return slug, dbErr(err, "product")
+}
+
+// SlugTaken reports whether another live product has the slug, now or as an
+// old slug that still redirects to it.
+func (r *productRepository) SlugTaken(ctx context.Context, slug string, productId uuid.UUID) (bool, error) {
+	query := `
+		SELECT EXISTS (
+			SELECT 1 FROM products WHERE slug = $1 AND id <> $2 AND deleted_at IS NULL
+		) OR EXISTS (
+			SELECT 1
+			FROM product_slug_history h
+			JOIN products p ON p.id = h.product_id
+			WHERE h.slug = $1 AND h.product_id <> $2 AND p.deleted_at IS NULL
+		)
+	`
+
+	var taken bool
+	err := r.db.Pool.QueryRow(ctx, query, slug, productId).Scan(&taken)
+	return taken, dbErr(err, "product")
+}
+
+// SKUTaken reports whether another live product, or a variant of one, has
+// the SKU.
+func (r *productRepository) SKUTaken(ctx context.Context, sku string, productId uuid.UUID) (bool, error) {
+	query := `
+		SELECT EXISTS (
+			SELECT 1 FROM products WHERE sku = $1 AND id <> $2 AND deleted_at IS NULL
+		) OR EXISTS (
+			SELECT 1 FROM product_variants WHERE sku = $1 AND product_id <> $2 AND deleted_at IS NULL
+		)
+	`
 
+	var taken bool
+	err := r.db.Pool.QueryRow(ctx, query, sku, productId).Scan(&taken)
+	return taken, dbErr(err, "product")
+}
+
+func (r *productRepository) List(ctx context.Context, page pagination.Page) ([]model.Product, *pagination.Info, error) {
+	return r.list(ctx, page, "TRUE")
+}
+
+// ListByCategory pages through the products of the category and all its
+// subcategories.
+func (r *productRepository) ListByCategory(ctx context.Context, categoryId uuid.UUID, page pagination.Page) ([]model.Product, *pagination.Info, error) {
+	return r.list(ctx, page, `p.category_id IN (`+categoryDescendants("$1")+`
+		)`, categoryId)
+}
+
+// list pages through the products matching filter, whose arguments are
+// numbered from $1. Listing is read from the replica.
+func (r *productRepository) list(ctx context.Context, page pagination.Page, filter string, filterArgs ...any) ([]model.Product, *pagination.Info, error) {
+	where, order, limit, args := page.Keyset("p", len(filterArgs)+1)
+
+	query := `
+		SELECT ` + prefixColumns("p", productColumns) + `,
+			` + activeDiscountColumns + `
+		FROM products p
+		` + activeDiscountJoin + `
+		WHERE p.deleted_at IS NULL AND ` + filter + ` AND ` + where + `
+		ORDER BY ` + order + `
+		LIMIT ` + limit
+
+	rows, err := r.db.Reads().Query(ctx, query, append(filterArgs, args...)...)
+	if err != nil {
+		return nil, nil, dbErr(err, "product")
+	}
+	defer rows.Close()
+
+	products := []model.Product{}
+	for rows.Next() {
+		p, err := scanProductWithDiscount(rows)
+		if err != nil {
+			return nil, nil, dbErr(err, "product")
+		}
+		products = append(products, *p)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, nil, dbErr(err, "product")
+	}
+
+	products, info := pagination.Trim(page, products)
+
+	if page.WithTotal {
+		countQuery := `SELECT COUNT(*) FROM products p WHERE p.deleted_at IS NULL AND ` + filter
+		if info.Total, err = countRows(ctx, r.db.Reads(), countQuery, filterArgs...); err != nil {
+			return nil, nil, dbErr(err, "product")
+		}
+	}
+
+	return products, info, nil
+}
+
+// scanProductWithDiscount scans the product columns followed by
+// activeDiscountColumns and applies the discount to the product.
+func scanProductWithDiscount(row interface{ Scan(dest ...any) error }) (*model.Product, error) {
 	var product model.Product
+	var d nullableDiscount
+
 	err := row.Scan(
 		&product.ID,
 		&product.CategoryID,
 		&product.Name,
+		&product.Slug,
+		&product.SKU,
 		&product.Description,
 		&product.Price,
 		&product.Stock,
+		&product.TaxClass,
+		&product.MetaTitle,
+		&product.MetaDescription,
 		&product.CreatedAt,
 		&product.UpdatedAt,
+		&d.ID,
+		&d.Type,
+		&d.Value,
+		&d.StartsAt,
+		&d.EndsAt,
+		&d.Priority,
+		&d.CreatedAt,
+		&d.UpdatedAt,
 	)
 	if err != nil {
-		return nil, err
+		return nil, dbErr(err, "product")
 	}
 
+	product.ApplyDiscount(d.discount(product.ID))
+
 	return &product, nil
 }
 
-func (r *productRepository) List(ctx context.Context, limit, offset int) ([]model.Product, error) {
-	query := `
-		SELECT id, category_id, name, description, price, stock, created_at, updated_at
-		FROM products
-		WHERE deleted_at IS NULL
-		ORDER BY created_at DESC
-		LIMIT $1 OFFSET $2
-	`
+// Update saves the product details. Stock is left alone, it is the total of
+// the variants' stock and changes with them. When the slug changes the old
+// one is kept in the slug history so links to it still work.
+func (r *productRepository) Update(ctx context.Context, product *model.Product) error {
+	tx, err := r.db.Pool.Begin(ctx)
+	if err != nil {
+		return dbErr(err, "product")
+	}
+	defer tx.Rollback(ctx)
+
+	if err := updateProduct(ctx, tx, product); err != nil {
+		return err
+	}
 
-	rows, err := r.db.Pool.Query(ctx, query, limit, offset)
+	return dbErr(tx.Commit(ctx), "product")
+}
+
+// UpdateWithStock saves the product like Update and, in the same
+// transaction, the stock of variant, the single variant of a simple
+// product. product.Stock becomes the new stock.
+func (r *productRepository) UpdateWithStock(ctx context.Context, product *model.Product, variant *model.ProductVariant) error {
+	tx, err := r.db.Pool.Begin(ctx)
 	if err != nil {
-		return nil, err
+		return dbErr(err, "product")
 	}
-	defer rows.Close()
+	defer tx.Rollback(ctx)
 
-	var products []model.Product
-	for rows.Next() {
-		var p model.Product
-		if err := rows.Scan(
-			&p.ID,
-			&p.CategoryID,
-			&p.Name,
-			&p.Description,
-			&p.Price,
-			&p.Stock,
-			&p.CreatedAt,
-			&p.UpdatedAt,
-		); err != nil {
-			return nil, err
-		}
-		products = append(products, p)
+	if err := updateProduct(ctx, tx, product); err != nil {
+		return err
+	}
+
+	// the variant must still be the product's only one, options may have
+	// been added since it was read
+	err = tx.QueryRow(ctx, `
+		UPDATE product_variants pv
+		SET stock = $1, updated_at = now()
+		WHERE pv.id = $2 AND pv.product_id = $3 AND pv.deleted_at IS NULL
+			AND NOT EXISTS (SELECT 1 FROM product_variant_values vv WHERE vv.variant_id = pv.id)
+		RETURNING updated_at`,
+		variant.Stock,
+		variant.ID,
+		product.ID,
+	).Scan(&variant.UpdatedAt)
+	if err != nil {
+		return dbErr(err, "variant")
 	}
 
-	return products, nil
+	if err := syncProductStock(ctx, tx, product.ID); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return dbErr(err, "product")
+	}
+
+	product.Stock = variant.Stock
+
+	return nil
 }
 
-func (r *productRepository) Update(ctx context.Context, product *model.Product) error {
+// updateProduct saves the product details within tx.
+func updateProduct(ctx context.Context, tx pgx.Tx, product *model.Product) error {
+	var oldSlug string
+	err := tx.QueryRow(ctx,
+		`SELECT slug FROM products WHERE id = $1 AND deleted_at IS NULL FOR UPDATE`, product.ID,
+	).Scan(&oldSlug)
+	if err != nil {
+		return dbErr(err, "product")
+	}
+
 	query := `
 		UPDATE products
-		SET category_id = $1, name = $2, description = $3, price = $4, stock = $5, updated_at = now()
-		WHERE id = $6
+		SET category_id = $1, name = $2, slug = $3, sku = $4, description = $5, price = $6, tax_class = $7,
+			meta_title = $8, meta_description = $9, updated_at = now()
+		WHERE id = $10
 		RETURNING updated_at
 	`
 
-	return r.db.Pool.QueryRow(ctx, query,
+	err = tx.QueryRow(ctx, query,
 		product.CategoryID,
 		product.Name,
+		product.Slug,
+		product.SKU,
 		product.Description,
 		product.Price,
-		product.Stock,
+		product.TaxClass,
+		product.MetaTitle,
+		product.MetaDescription,
 		product.ID,
 	).Scan(&product.UpdatedAt)
+	if err != nil {
+		return dbErr(err, "product")
+	}
+
+	if oldSlug != product.Slug {
+		_, err = tx.Exec(ctx, `
+			INSERT INTO product_slug_history (slug, product_id)
+			VALUES ($1, $2)
+			ON CONFLICT (slug) DO UPDATE SET product_id = EXCLUDED.product_id, created_at = now()
+		`, oldSlug, product.ID)
+		if err != nil {
+			return dbErr(err, "product")
+		}
+
+		// going back to an old slug makes it current again
+		if _, err = tx.Exec(ctx, `DELETE FROM product_slug_history WHERE slug = $1`, product.Slug); err != nil {
+			return dbErr(err, "product")
+		}
+	}
+
+	return nil
 }
 
 func (r *productRepository) Delete(ctx context.Context, id uuid.UUID) error {
@@ -129,5 +393,5 @@ func (r *productRepository) Delete(ctx context.Context, id uuid.UUID) error {
 		WHERE id = $1 AND deleted_at IS NULL
 	`
 	_, err := r.db.Pool.Exec(ctx, query, id)
-	return err
+	return dbErr(err, "product")
 }
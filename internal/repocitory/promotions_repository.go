@@ -0,0 +1,330 @@
+package repocitory
+
+import (
+	"context"
+	"time"
+
+	"github.com/Oj-washingtone/savannah-store/internal/apperr"
+	"github.com/Oj-washingtone/savannah-store/internal/database"
+	"github.com/Oj-washingtone/savannah-store/internal/model"
+	"github.com/Oj-washingtone/savannah-store/internal/pagination"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+)
+
+// ErrPromotionExhausted is returned by Redeem and OrdersRepository.Place when
+// the promotion has reached its overall or per customer usage limit.
+var ErrPromotionExhausted = apperr.Conflict("Promotion usage limit reached")
+
+type PromotionRepository interface {
+	Create(ctx context.Context, promotion *model.Promotion) error
+	GetById(ctx context.Context, id uuid.UUID) (*model.Promotion, error)
+	GetByCode(ctx context.Context, code string) (*model.Promotion, error)
+	List(ctx context.Context, page pagination.Page) ([]*model.Promotion, *pagination.Info, error)
+	ListAutomatic(ctx context.Context, at time.Time) ([]*model.Promotion, error)
+	Update(ctx context.Context, promotion *model.Promotion) error
+	Delete(ctx context.Context, id uuid.UUID) error
+
+	Usage(ctx context.Context, promotionId, userId uuid.UUID) (total int, byUser int, err error)
+	Redeem(ctx context.Context, redemption *model.PromotionRedemption) error
+	ReleaseRedemptions(ctx context.Context, orderId uuid.UUID) error
+
+	AddToCart(ctx context.Context, cartId, promotionId uuid.UUID) error
+	RemoveFromCart(ctx context.Context, cartId, promotionId uuid.UUID) error
+	ListForCart(ctx context.Context, cartId uuid.UUID) ([]*model.Promotion, error)
+	ClearCart(ctx context.Context, cartId uuid.UUID) error
+}
+
+type promotionRepository struct {
+	db *database.DB
+}
+
+func NewPromotionRepository(db *database.DB) PromotionRepository {
+	return &promotionRepository{db: db}
+}
+
+const promotionColumns = `id, code, description, scope, product_id, category_id, type, value, min_spend,
+	starts_at, ends_at, usage_limit, per_customer_limit, stackable, active, created_at, updated_at`
+
+func scanPromotion(row interface{ Scan(dest ...any) error }) (*model.Promotion, error) {
+	var p model.Promotion
+	err := row.Scan(
+		&p.ID,
+		&p.Code,
+		&p.Description,
+		&p.Scope,
+		&p.ProductID,
+		&p.CategoryID,
+		&p.Type,
+		&p.Value,
+		&p.MinSpend,
+		&p.StartsAt,
+		&p.EndsAt,
+		&p.UsageLimit,
+		&p.PerCustomerLimit,
+		&p.Stackable,
+		&p.Active,
+		&p.CreatedAt,
+		&p.UpdatedAt,
+	)
+	if err != nil {
+		return nil, dbErr(err, "promotion")
+	}
+
+	return &p, nil
+}
+
+func (r *promotionRepository) queryPromotions(ctx context.Context, query string, args ...any) ([]*model.Promotion, error) {
+	rows, err := r.db.Pool.Query(ctx, query, args...)
+	if err != nil {
+		return nil, dbErr(err, "promotion")
+	}
+	defer rows.Close()
+
+	var promotions []*model.Promotion
+	for rows.Next() {
+		p, err := scanPromotion(rows)
+		if err != nil {
+			return nil, dbErr(err, "promotion")
+		}
+		promotions = append(promotions, p)
+	}
+
+	return promotions, dbErr(rows.Err(), "promotion")
+}
+
+func (r *promotionRepository) Create(ctx context.Context, promotion *model.Promotion) error {
+	query := `
+		INSERT INTO promotions (id, code, description, scope, product_id, category_id, type, value, min_spend,
+			starts_at, ends_at, usage_limit, per_customer_limit, stackable, active)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15)
+		RETURNING created_at, updated_at
+	`
+
+	return dbErr(r.db.Pool.QueryRow(ctx, query,
+		promotion.ID,
+		promotion.Code,
+		promotion.Description,
+		promotion.Scope,
+		promotion.ProductID,
+		promotion.CategoryID,
+		promotion.Type,
+		promotion.Value,
+		promotion.MinSpend,
+		promotion.StartsAt,
+		promotion.EndsAt,
+		promotion.UsageLimit,
+		promotion.PerCustomerLimit,
+		promotion.Stackable,
+		promotion.Active,
+	).Scan(&promotion.CreatedAt, &promotion.UpdatedAt), "promotion")
+}
+
+func (r *promotionRepository) GetById(ctx context.Context, id uuid.UUID) (*model.Promotion, error) {
+	query := `SELECT ` + promotionColumns + ` FROM promotions WHERE id = $1 AND deleted_at IS NULL`
+
+	return scanPromotion(r.db.Pool.QueryRow(ctx, query, id))
+}
+
+func (r *promotionRepository) GetByCode(ctx context.Context, code string) (*model.Promotion, error) {
+	query := `SELECT ` + promotionColumns + ` FROM promotions WHERE code = $1 AND deleted_at IS NULL`
+
+	return scanPromotion(r.db.Pool.QueryRow(ctx, query, code))
+}
+
+func (r *promotionRepository) List(ctx context.Context, page pagination.Page) ([]*model.Promotion, *pagination.Info, error) {
+	where, order, limit, args := page.Keyset("promotions", 1)
+
+	query := `
+		SELECT ` + promotionColumns + `
+		FROM promotions
+		WHERE deleted_at IS NULL AND ` + where + `
+		ORDER BY ` + order + `
+		LIMIT ` + limit
+
+	promotions, err := r.queryPromotions(ctx, query, args...)
+	if err != nil {
+		return nil, nil, dbErr(err, "promotion")
+	}
+
+	promotions, info := pagination.Trim(page, promotions)
+
+	if page.WithTotal {
+		if info.Total, err = countRows(ctx, r.db.Pool, `SELECT COUNT(*) FROM promotions WHERE deleted_at IS NULL`); err != nil {
+			return nil, nil, dbErr(err, "promotion")
+		}
+	}
+
+	return promotions, info, nil
+}
+
+// ListAutomatic returns the active promotions that need no code and are
+// running at the given time.
+func (r *promotionRepository) ListAutomatic(ctx context.Context, at time.Time) ([]*model.Promotion, error) {
+	query := `
+		SELECT ` + promotionColumns + `
+		FROM promotions
+		WHERE deleted_at IS NULL
+			AND active
+			AND code IS NULL
+			AND (starts_at IS NULL OR starts_at <= $1)
+			AND (ends_at IS NULL OR ends_at > $1)
+		ORDER BY created_at
+	`
+
+	return r.queryPromotions(ctx, query, at)
+}
+
+func (r *promotionRepository) Update(ctx context.Context, promotion *model.Promotion) error {
+	query := `
+		UPDATE promotions
+		SET code = $1, description = $2, scope = $3, product_id = $4, category_id = $5, type = $6, value = $7,
+			min_spend = $8, starts_at = $9, ends_at = $10, usage_limit = $11, per_customer_limit = $12,
+			stackable = $13, active = $14, updated_at = now()
+		WHERE id = $15
+		RETURNING updated_at
+	`
+
+	return dbErr(r.db.Pool.QueryRow(ctx, query,
+		promotion.Code,
+		promotion.Description,
+		promotion.Scope,
+		promotion.ProductID,
+		promotion.CategoryID,
+		promotion.Type,
+		promotion.Value,
+		promotion.MinSpend,
+		promotion.StartsAt,
+		promotion.EndsAt,
+		promotion.UsageLimit,
+		promotion.PerCustomerLimit,
+		promotion.Stackable,
+		promotion.Active,
+		promotion.ID,
+	).Scan(&promotion.UpdatedAt), "promotion")
+}
+
+// Delete soft deletes the promotion and frees its code for reuse.
+func (r *promotionRepository) Delete(ctx context.Context, id uuid.UUID) error {
+	query := `
+		UPDATE promotions
+		SET deleted_at = now(), code = NULL, active = false
+		WHERE id = $1 AND deleted_at IS NULL
+	`
+	_, err := r.db.Pool.Exec(ctx, query, id)
+	return dbErr(err, "promotion")
+}
+
+// Usage returns how many times the promotion has been redeemed in total and
+// by the given user.
+func (r *promotionRepository) Usage(ctx context.Context, promotionId, userId uuid.UUID) (int, int, error) {
+	query := `
+		SELECT COUNT(*), COUNT(*) FILTER (WHERE user_id = $2)
+		FROM promotion_redemptions
+		WHERE promotion_id = $1
+	`
+
+	var total, byUser int
+	err := r.db.Pool.QueryRow(ctx, query, promotionId, userId).Scan(&total, &byUser)
+	return total, byUser, dbErr(err, "promotion")
+}
+
+// Redeem records a promotion use against an order. The promotion row is
+// locked while the limits are checked so concurrent checkouts cannot go over
+// them; ErrPromotionExhausted is returned when a limit has been reached.
+func (r *promotionRepository) Redeem(ctx context.Context, redemption *model.PromotionRedemption) error {
+	tx, err := r.db.Pool.Begin(ctx)
+	if err != nil {
+		return dbErr(err, "promotion")
+	}
+	defer tx.Rollback(ctx)
+
+	if err := redeem(ctx, tx, redemption); err != nil {
+		return err
+	}
+
+	return dbErr(tx.Commit(ctx), "promotion")
+}
+
+// redeem records the promotion use on tx, holding the promotion's row lock
+// until tx ends.
+func redeem(ctx context.Context, tx pgx.Tx, redemption *model.PromotionRedemption) error {
+	var usageLimit, perCustomerLimit *int
+	err := tx.QueryRow(ctx,
+		`SELECT usage_limit, per_customer_limit FROM promotions WHERE id = $1 FOR UPDATE`,
+		redemption.PromotionID,
+	).Scan(&usageLimit, &perCustomerLimit)
+	if err != nil {
+		return dbErr(err, "promotion")
+	}
+
+	var total, byUser int
+	err = tx.QueryRow(ctx,
+		`SELECT COUNT(*), COUNT(*) FILTER (WHERE user_id = $2) FROM promotion_redemptions WHERE promotion_id = $1`,
+		redemption.PromotionID, redemption.UserID,
+	).Scan(&total, &byUser)
+	if err != nil {
+		return dbErr(err, "promotion")
+	}
+
+	if (usageLimit != nil && total >= *usageLimit) || (perCustomerLimit != nil && byUser >= *perCustomerLimit) {
+		return ErrPromotionExhausted
+	}
+
+	err = tx.QueryRow(ctx, `
+		INSERT INTO promotion_redemptions (id, promotion_id, order_id, user_id, code, amount)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		RETURNING created_at, updated_at`,
+		redemption.ID,
+		redemption.PromotionID,
+		redemption.OrderID,
+		redemption.UserID,
+		redemption.Code,
+		redemption.Amount,
+	).Scan(&redemption.CreatedAt, &redemption.UpdatedAt)
+
+	return dbErr(err, "promotion")
+}
+
+// ReleaseRedemptions gives back the promotion uses of an order that did not
+// go through.
+func (r *promotionRepository) ReleaseRedemptions(ctx context.Context, orderId uuid.UUID) error {
+	query := `DELETE FROM promotion_redemptions WHERE order_id = $1`
+	_, err := r.db.Pool.Exec(ctx, query, orderId)
+	return dbErr(err, "promotion")
+}
+
+func (r *promotionRepository) AddToCart(ctx context.Context, cartId, promotionId uuid.UUID) error {
+	query := `
+		INSERT INTO cart_promotions (cart_id, promotion_id)
+		VALUES ($1, $2)
+		ON CONFLICT (cart_id, promotion_id) DO NOTHING
+	`
+	_, err := r.db.Pool.Exec(ctx, query, cartId, promotionId)
+	return dbErr(err, "promotion")
+}
+
+func (r *promotionRepository) RemoveFromCart(ctx context.Context, cartId, promotionId uuid.UUID) error {
+	query := `DELETE FROM cart_promotions WHERE cart_id = $1 AND promotion_id = $2`
+	_, err := r.db.Pool.Exec(ctx, query, cartId, promotionId)
+	return dbErr(err, "promotion")
+}
+
+// ListForCart returns the promotions whose codes were entered on the cart.
+func (r *promotionRepository) ListForCart(ctx context.Context, cartId uuid.UUID) ([]*model.Promotion, error) {
+	query := `
+		SELECT ` + prefixColumns("p", promotionColumns) + `
+		FROM cart_promotions cp
+		JOIN promotions p ON p.id = cp.promotion_id
+		WHERE cp.cart_id = $1 AND p.deleted_at IS NULL
+		ORDER BY cp.created_at
+	`
+
+	return r.queryPromotions(ctx, query, cartId)
+}
+
+func (r *promotionRepository) ClearCart(ctx context.Context, cartId uuid.UUID) error {
+	query := `DELETE FROM cart_promotions WHERE cart_id = $1`
+	_, err := r.db.Pool.Exec(ctx, query, cartId)
+	return dbErr(err, "promotion")
+}
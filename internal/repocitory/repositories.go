@@ -0,0 +1,80 @@
+package repocitory
+
+import (
+	"context"
+	"errors"
+
+	"github.com/Oj-washingtone/savannah-store/internal/database"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+)
+
+// Repositories holds one of each repository, so the services and handlers
+// that need several can be given them in one go. Tests can fill it with
+// their own implementations.
+type Repositories struct {
+	Users         UserRepository
+	Products      ProductRepository
+	Variants      ProductVariantRepository
+	Images        ProductImageRepository
+	Categories    CategoryRepository
+	Discounts     DiscountRepository
+	Promotions    PromotionRepository
+	Carts         ShoppingCartRepository
+	CartItems     CartItemsRepository
+	CartReminders CartRemindersRepository
+	Orders        OrdersRepository
+	OrderItems    OrderItemsRepository
+	Wishlists     WishlistRepository
+	Outbox        NotificationOutboxRepository
+	Health        HealthRepository
+}
+
+// NewRepositories gives the Postgres repositories, all using db.
+func NewRepositories(db *database.DB) *Repositories {
+	return &Repositories{
+		Users:         NewUserRepository(db),
+		Products:      NewProductRepository(db),
+		Variants:      NewProductVariantRepository(db),
+		Images:        NewProductImageRepository(db),
+		Categories:    NewCategoryRepository(db),
+		Discounts:     NewDiscountRepository(db),
+		Promotions:    NewPromotionRepository(db),
+		Carts:         NewShoppingCartRepository(db),
+		CartItems:     NewCartItemsRepository(db),
+		CartReminders: NewCartRemindersRepository(db),
+		Orders:        NewOrdersRepository(db),
+		OrderItems:    NewOrderItemsRepository(db),
+		Wishlists:     NewWishlistRepository(db),
+		Outbox:        NewNotificationOutboxRepository(db),
+		Health:        NewHealthRepository(db),
+	}
+}
+
+// Lookups report whether a live category, product or variant has an id.
+// They back the exists validation tag, by its kind.
+func (r *Repositories) Lookups() map[string]func(ctx context.Context, id uuid.UUID) (bool, error) {
+	return map[string]func(ctx context.Context, id uuid.UUID) (bool, error){
+		"category": func(ctx context.Context, id uuid.UUID) (bool, error) {
+			_, err := r.Categories.GetById(ctx, id)
+			return found(err)
+		},
+		"product": func(ctx context.Context, id uuid.UUID) (bool, error) {
+			_, err := r.Products.GetById(ctx, id)
+			return found(err)
+		},
+		"variant": func(ctx context.Context, id uuid.UUID) (bool, error) {
+			_, err := r.Variants.GetById(ctx, id)
+			return found(err)
+		},
+	}
+}
+
+// found turns the error of a lookup by id into whether it found the row.
+func found(err error) (bool, error) {
+	if errors.Is(err, pgx.ErrNoRows) {
+		return false, nil
+	}
+
+	return err == nil, err
+}
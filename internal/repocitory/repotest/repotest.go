@@ -0,0 +1,1739 @@
+// Package repotest is the contract every implementation of the repositories
+// has to meet. The Postgres and in-memory repositories both run it, so a
+// behaviour one of them has and the other lacks shows up as a failing test
+// rather than as a difference between tests and production.
+package repotest
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Oj-washingtone/savannah-store/internal/apperr"
+	"github.com/Oj-washingtone/savannah-store/internal/database"
+	"github.com/Oj-washingtone/savannah-store/internal/model"
+	"github.com/Oj-washingtone/savannah-store/internal/pagination"
+	"github.com/Oj-washingtone/savannah-store/internal/repocitory"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+)
+
+// Factory gives a set of repositories with nothing in them. It is called once
+// per test, and the tests do not run in parallel with each other.
+type Factory func(t *testing.T) *repocitory.Repositories
+
+// Run runs the contract against the repositories newRepos gives.
+func Run(t *testing.T, newRepos Factory) {
+	tests := []struct {
+		name string
+		test func(t *testing.T, repos *repocitory.Repositories)
+	}{
+		{"Users", testUsers},
+		{"Categories", testCategories},
+		{"CategoryDelete", testCategoryDelete},
+		{"Products", testProducts},
+		{"ProductPagination", testProductPagination},
+		{"ProductSearch", testProductSearch},
+		{"Variants", testVariants},
+		{"Images", testImages},
+		{"Discounts", testDiscounts},
+		{"DiscountStartAlerts", testDiscountStartAlerts},
+		{"Promotions", testPromotions},
+		{"PromotionRedeemConcurrently", testPromotionRedeemConcurrently},
+		{"Carts", testCarts},
+		{"CartReminders", testCartReminders},
+		{"Orders", testOrders},
+		{"PlaceOrder", testPlaceOrder},
+		{"OrderItems", testOrderItems},
+		{"Wishlists", testWishlists},
+		{"NotificationOutbox", testNotificationOutbox},
+		{"Health", testHealth},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.test(t, newRepos(t))
+		})
+	}
+}
+
+// wantKind fails the test unless err is an apperr.Error of the kind.
+func wantKind(t *testing.T, err error, kind apperr.Kind) {
+	t.Helper()
+
+	if err == nil {
+		t.Fatalf("got no error, want a %s error", kind)
+	}
+
+	if got := apperr.KindOf(err); got != kind {
+		t.Fatalf("got a %s error (%v), want a %s error", got, err, kind)
+	}
+}
+
+// wantNotFound fails the test unless err says nothing was found, both as an
+// apperr kind and through pgx.ErrNoRows, which callers check for.
+func wantNotFound(t *testing.T, err error) {
+	t.Helper()
+
+	wantKind(t, err, apperr.KindNotFound)
+
+	if !errors.Is(err, pgx.ErrNoRows) {
+		t.Fatalf("errors.Is(%v, pgx.ErrNoRows) is false", err)
+	}
+}
+
+func must(t *testing.T, err error) {
+	t.Helper()
+
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func ptr[T any](v T) *T {
+	return &v
+}
+
+// unique gives a string no other test data has, for emails, slugs and codes.
+func unique(prefix string) string {
+	return prefix + "-" + uuid.NewString()[:8]
+}
+
+func newUser(t *testing.T, repos *repocitory.Repositories) *model.User {
+	t.Helper()
+
+	user := &model.User{
+		BaseModel: model.BaseModel{ID: uuid.New()},
+		Name:      "Wanjiku",
+		Email:     unique("wanjiku") + "@example.com",
+		Role:      model.CustomerRole,
+		Auth0Id:   unique("auth0"),
+	}
+	must(t, repos.Users.Create(context.Background(), user))
+
+	return user
+}
+
+func newCategory(t *testing.T, repos *repocitory.Repositories, parentId *uuid.UUID) *model.ProductCategory {
+	t.Helper()
+
+	category := &model.ProductCategory{
+		BaseModel: model.BaseModel{ID: uuid.New()},
+		Name:      "Kitchen",
+		Slug:      unique("kitchen"),
+		ParentId:  parentId,
+	}
+	must(t, repos.Categories.Create(context.Background(), category))
+
+	return category
+}
+
+func newProduct(t *testing.T, repos *repocitory.Repositories, categoryId uuid.UUID, name string, price int64) *model.Product {
+	t.Helper()
+
+	product := &model.Product{
+		BaseModel:   model.BaseModel{ID: uuid.New()},
+		CategoryID:  categoryId,
+		Name:        name,
+		Slug:        unique("product"),
+		Description: "Made in Nairobi",
+		Price:       price,
+		Stock:       10,
+		TaxClass:    model.TaxStandard,
+	}
+	must(t, repos.Products.Create(context.Background(), product))
+
+	return product
+}
+
+// defaultVariant gives the variant a product is created with.
+func defaultVariant(t *testing.T, repos *repocitory.Repositories, productId uuid.UUID) *model.ProductVariant {
+	t.Helper()
+
+	variants, err := repos.Variants.ListByProduct(context.Background(), productId)
+	must(t, err)
+
+	if len(variants) != 1 {
+		t.Fatalf("got %d variants, want the default one", len(variants))
+	}
+
+	return variants[0]
+}
+
+func newOrder(t *testing.T, repos *repocitory.Repositories, userId uuid.UUID) *model.Orders {
+	t.Helper()
+
+	order, err := repos.Orders.Create(context.Background(), &model.Orders{
+		BaseModel: model.BaseModel{ID: uuid.New()},
+		UserID:    userId,
+		Subtotal:  1000,
+		Total:     1000,
+	})
+	must(t, err)
+
+	return order
+}
+
+func testUsers(t *testing.T, repos *repocitory.Repositories) {
+	ctx := context.Background()
+	user := newUser(t, repos)
+
+	if user.CreatedAt.IsZero() {
+		t.Error("Create did not set CreatedAt")
+	}
+
+	for name, get := range map[string]func() (*model.User, error){
+		"GetById":      func() (*model.User, error) { return repos.Users.GetById(ctx, user.ID) },
+		"GetByEmail":   func() (*model.User, error) { return repos.Users.GetByEmail(ctx, user.Email) },
+		"GetByAuth0Id": func() (*model.User, error) { return repos.Users.GetByAuth0Id(ctx, user.Auth0Id) },
+	} {
+		got, err := get()
+		must(t, err)
+
+		if got.ID != user.ID || got.Email != user.Email || got.Role != user.Role || got.Auth0Id != user.Auth0Id {
+			t.Errorf("%s got %+v, want %+v", name, got, user)
+		}
+	}
+
+	_, err := repos.Users.GetById(ctx, uuid.New())
+	wantNotFound(t, err)
+
+	duplicate := &model.User{
+		BaseModel: model.BaseModel{ID: uuid.New()},
+		Name:      "Someone else",
+		Email:     user.Email,
+		Role:      model.CustomerRole,
+		Auth0Id:   unique("auth0"),
+	}
+	wantKind(t, repos.Users.Create(ctx, duplicate), apperr.KindConflict)
+
+	must(t, repos.Users.UpdatePhone(ctx, user.ID, "+254700000001"))
+	must(t, repos.Users.UpdateCartRemindersOptOut(ctx, user.ID, true))
+	must(t, repos.Users.UpdateWishlistAlertsOptOut(ctx, user.ID, true))
+	must(t, repos.Users.UpdateRole(ctx, user.ID, model.AdminRole))
+
+	wantKind(t, repos.Users.UpdateRole(ctx, user.ID, "owner"), apperr.KindValidation)
+
+	got, err := repos.Users.GetById(ctx, user.ID)
+	must(t, err)
+
+	if got.Phone != "+254700000001" || !got.CartRemindersOptOut || !got.WishlistAlertsOptOut || got.Role != model.AdminRole {
+		t.Errorf("updates were not stored, got %+v", got)
+	}
+}
+
+func testCategories(t *testing.T, repos *repocitory.Repositories) {
+	ctx := context.Background()
+	root := newCategory(t, repos, nil)
+	child := newCategory(t, repos, &root.ID)
+	grandchild := newCategory(t, repos, &child.ID)
+
+	got, err := repos.Categories.GetBySlug(ctx, child.Slug)
+	must(t, err)
+
+	if got.ID != child.ID || got.ParentId == nil || *got.ParentId != root.ID {
+		t.Errorf("GetBySlug got %+v, want %+v", got, child)
+	}
+
+	wantKind(t, repos.Categories.Create(ctx, &model.ProductCategory{
+		BaseModel: model.BaseModel{ID: uuid.New()},
+		Name:      "Copy",
+		Slug:      root.Slug,
+	}), apperr.KindConflict)
+
+	wantKind(t, repos.Categories.Create(ctx, &model.ProductCategory{
+		BaseModel: model.BaseModel{ID: uuid.New()},
+		Name:      "Orphan",
+		Slug:      unique("orphan"),
+		ParentId:  ptr(uuid.New()),
+	}), apperr.KindValidation)
+
+	taken, err := repos.Categories.SlugTaken(ctx, root.Slug, child.ID)
+	must(t, err)
+	if !taken {
+		t.Error("SlugTaken is false for another category's slug")
+	}
+
+	taken, err = repos.Categories.SlugTaken(ctx, root.Slug, root.ID)
+	must(t, err)
+	if taken {
+		t.Error("SlugTaken is true for the category's own slug")
+	}
+
+	ancestors, err := repos.Categories.Ancestors(ctx, grandchild.ID)
+	must(t, err)
+
+	var ids []uuid.UUID
+	for _, c := range ancestors {
+		ids = append(ids, c.ID)
+	}
+
+	if want := []uuid.UUID{grandchild.ID, child.ID, root.ID}; !slices.Equal(ids, want) {
+		t.Errorf("Ancestors got %v, want %v", ids, want)
+	}
+
+	newProduct(t, repos, child.ID, "Sufuria", 1500)
+	newProduct(t, repos, child.ID, "Jiko", 2500)
+
+	counts, err := repos.Categories.ProductCounts(ctx)
+	must(t, err)
+
+	if counts[child.ID] != 2 || counts[root.ID] != 0 {
+		t.Errorf("ProductCounts got %v", counts)
+	}
+
+	child.Name = "Cookware"
+	must(t, repos.Categories.Update(ctx, child))
+
+	got, err = repos.Categories.GetById(ctx, child.ID)
+	must(t, err)
+
+	if got.Name != "Cookware" {
+		t.Errorf("Update did not store the name, got %q", got.Name)
+	}
+
+	wantNotFound(t, repos.Categories.Update(ctx, &model.ProductCategory{
+		BaseModel: model.BaseModel{ID: uuid.New()},
+		Name:      "Missing",
+		Slug:      unique("missing"),
+	}))
+}
+
+func testCategoryDelete(t *testing.T, repos *repocitory.Repositories) {
+	ctx := context.Background()
+	root := newCategory(t, repos, nil)
+	child := newCategory(t, repos, &root.ID)
+	product := newProduct(t, repos, child.ID, "Kikapu", 800)
+
+	err := repos.Categories.Delete(ctx, root.ID, model.CategoryDeleteBlock, nil)
+	if !errors.Is(err, repocitory.ErrCategoryNotEmpty) {
+		t.Fatalf("block got %v, want ErrCategoryNotEmpty", err)
+	}
+
+	wantKind(t, repos.Categories.Delete(ctx, root.ID, model.CategoryDeleteReassign, nil), apperr.KindValidation)
+
+	other := newCategory(t, repos, nil)
+	must(t, repos.Categories.Delete(ctx, child.ID, model.CategoryDeleteReassign, &other.ID))
+
+	moved, err := repos.Products.GetById(ctx, product.ID)
+	must(t, err)
+
+	if moved.CategoryID != other.ID {
+		t.Errorf("reassign left the product in %v, want %v", moved.CategoryID, other.ID)
+	}
+
+	_, err = repos.Categories.GetById(ctx, child.ID)
+	wantNotFound(t, err)
+
+	sub := newCategory(t, repos, &other.ID)
+	subProduct := newProduct(t, repos, sub.ID, "Kiondo", 1200)
+
+	must(t, repos.Categories.Delete(ctx, other.ID, model.CategoryDeleteCascade, nil))
+
+	for _, id := range []uuid.UUID{other.ID, sub.ID} {
+		_, err = repos.Categories.GetById(ctx, id)
+		wantNotFound(t, err)
+	}
+
+	for _, id := range []uuid.UUID{product.ID, subProduct.ID} {
+		_, err = repos.Products.GetById(ctx, id)
+		wantNotFound(t, err)
+	}
+
+	must(t, repos.Categories.Delete(ctx, root.ID, model.CategoryDeleteBlock, nil))
+}
+
+func testProducts(t *testing.T, repos *repocitory.Repositories) {
+	ctx := context.Background()
+	category := newCategory(t, repos, nil)
+	product := newProduct(t, repos, category.ID, "Kiondo", 1200)
+
+	got, err := repos.Products.GetById(ctx, product.ID)
+	must(t, err)
+
+	if got.Name != product.Name || got.Price != 1200 || got.Stock != 10 || got.DiscountedPrice != 1200 {
+		t.Errorf("GetById got %+v", got)
+	}
+
+	if variant := defaultVariant(t, repos, product.ID); variant.Stock != 10 || len(variant.Options) != 0 {
+		t.Errorf("default variant got %+v", variant)
+	}
+
+	duplicate := &model.Product{
+		BaseModel:  model.BaseModel{ID: uuid.New()},
+		CategoryID: category.ID,
+		Name:       "Copy",
+		Slug:       product.Slug,
+		TaxClass:   model.TaxStandard,
+	}
+	wantKind(t, repos.Products.Create(ctx, duplicate), apperr.KindConflict)
+
+	duplicate.Slug = unique("product")
+	duplicate.CategoryID = uuid.New()
+	wantKind(t, repos.Products.Create(ctx, duplicate), apperr.KindValidation)
+
+	oldSlug := product.Slug
+	product.Slug = unique("renamed")
+	product.SKU = ptr(unique("SKU"))
+	must(t, repos.Products.Update(ctx, product))
+
+	redirect, err := repos.Products.SlugRedirect(ctx, oldSlug)
+	must(t, err)
+	if redirect != product.Slug {
+		t.Errorf("SlugRedirect got %q, want %q", redirect, product.Slug)
+	}
+
+	taken, err := repos.Products.SlugTaken(ctx, oldSlug, uuid.New())
+	must(t, err)
+	if !taken {
+		t.Error("SlugTaken is false for a slug that still redirects")
+	}
+
+	got, err = repos.Products.GetBySKU(ctx, *product.SKU)
+	must(t, err)
+	if got.ID != product.ID {
+		t.Errorf("GetBySKU got %v, want %v", got.ID, product.ID)
+	}
+
+	taken, err = repos.Products.SKUTaken(ctx, *product.SKU, uuid.New())
+	must(t, err)
+	if !taken {
+		t.Error("SKUTaken is false for another product's SKU")
+	}
+
+	must(t, repos.Products.Delete(ctx, product.ID))
+
+	_, err = repos.Products.GetById(ctx, product.ID)
+	wantNotFound(t, err)
+
+	_, err = repos.Products.GetBySlug(ctx, product.Slug)
+	wantNotFound(t, err)
+}
+
+func testProductPagination(t *testing.T, repos *repocitory.Repositories) {
+	ctx := context.Background()
+	category := newCategory(t, repos, nil)
+
+	var products []model.Product
+	for range 5 {
+		products = append(products, *newProduct(t, repos, category.ID, "Kikoi", 900))
+	}
+
+	// newest first, the way lists are ordered
+	slices.SortFunc(products, func(a, b model.Product) int {
+		if c := b.CreatedAt.Compare(a.CreatedAt); c != 0 {
+			return c
+		}
+		return -slices.Compare(a.ID[:], b.ID[:])
+	})
+
+	ids := func(products []model.Product) []uuid.UUID {
+		var ids []uuid.UUID
+		for _, p := range products {
+			ids = append(ids, p.ID)
+		}
+		return ids
+	}
+
+	page := pagination.Page{Limit: 2, WithTotal: true}
+
+	var seen []uuid.UUID
+	var pages [][]uuid.UUID
+	for {
+		got, info, err := repos.Products.ListByCategory(ctx, category.ID, page)
+		must(t, err)
+
+		if info.Total == nil || *info.Total != 5 {
+			t.Fatalf("got total %v, want 5", info.Total)
+		}
+
+		seen = append(seen, ids(got)...)
+		pages = append(pages, ids(got))
+
+		if info.NextCursor == nil {
+			break
+		}
+
+		cursor, err := pagination.Decode(*info.NextCursor)
+		must(t, err)
+		page.Cursor = cursor
+	}
+
+	if want := ids(products); !slices.Equal(seen, want) {
+		t.Fatalf("paging forwards got %v, want %v", seen, want)
+	}
+
+	// going back from the second page gives the first again
+	_, info, err := repos.Products.ListByCategory(ctx, category.ID, pagination.Page{
+		Limit:  2,
+		Cursor: &pagination.Cursor{CreatedAt: products[1].CreatedAt, ID: products[1].ID, Direction: pagination.Next},
+	})
+	must(t, err)
+
+	if info.PrevCursor == nil {
+		t.Fatal("the second page has no previous cursor")
+	}
+
+	cursor, err := pagination.Decode(*info.PrevCursor)
+	must(t, err)
+
+	got, _, err := repos.Products.ListByCategory(ctx, category.ID, pagination.Page{Limit: 2, Cursor: cursor})
+	must(t, err)
+
+	if !slices.Equal(ids(got), pages[0]) {
+		t.Errorf("paging back got %v, want %v", ids(got), pages[0])
+	}
+}
+
+func testProductSearch(t *testing.T, repos *repocitory.Repositories) {
+	ctx := context.Background()
+	category := newCategory(t, repos, nil)
+	basket := newProduct(t, repos, category.ID, "Woven basket", 1500)
+	newProduct(t, repos, category.ID, "Clay pot", 3000)
+
+	result, _, err := repos.Products.Search(ctx, &model.ProductSearch{Query: "basket", Page: pagination.Page{Limit: 10}})
+	must(t, err)
+
+	if result.Total != 1 || len(result.Products) != 1 || result.Products[0].ID != basket.ID {
+		t.Fatalf("searching for basket got %+v", result)
+	}
+
+	result, _, err = repos.Products.Search(ctx, &model.ProductSearch{CategoryID: &category.ID, MaxPrice: ptr[int64](2000), Page: pagination.Page{Limit: 10}})
+	must(t, err)
+
+	if result.Total != 1 || result.Products[0].ID != basket.ID {
+		t.Errorf("searching below 2000 got %+v", result)
+	}
+
+	if len(result.Facets.Categories) != 1 || result.Facets.Categories[0].Count != 1 {
+		t.Errorf("got category facets %+v", result.Facets.Categories)
+	}
+
+	inBuckets := 0
+	for _, bucket := range result.Facets.PriceBuckets {
+		inBuckets += bucket.Count
+	}
+
+	// the price facets leave out the price filter
+	if inBuckets != 2 {
+		t.Errorf("price facets count %d products, want 2", inBuckets)
+	}
+
+	// paging by price keeps products with the same price apart, newest first
+	mat := newProduct(t, repos, category.ID, "Sisal mat", 1500)
+	stool := newProduct(t, repos, category.ID, "Carved stool", 800)
+
+	want := []uuid.UUID{stool.ID, mat.ID, basket.ID}
+
+	search := &model.ProductSearch{
+		CategoryID: &category.ID,
+		MaxPrice:   ptr[int64](2000),
+		Sort:       model.SortPriceAsc,
+		Page:       pagination.Page{Limit: 2, Sort: string(model.SortPriceAsc)},
+	}
+
+	var seen []uuid.UUID
+	var firstPage []uuid.UUID
+	for {
+		result, info, err := repos.Products.Search(ctx, search)
+		must(t, err)
+
+		for _, p := range result.Products {
+			seen = append(seen, p.ID)
+		}
+
+		if firstPage == nil {
+			firstPage = slices.Clone(seen)
+		}
+
+		if info.NextCursor == nil {
+			break
+		}
+
+		if search.Page.Cursor, err = pagination.Decode(*info.NextCursor); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if !slices.Equal(seen, want) {
+		t.Fatalf("paging by price got %v, want %v", seen, want)
+	}
+
+	// going back from the last page gives the first again
+	_, info, err := repos.Products.Search(ctx, search)
+	must(t, err)
+
+	if info.PrevCursor == nil {
+		t.Fatal("the last page has no previous cursor")
+	}
+
+	if search.Page.Cursor, err = pagination.Decode(*info.PrevCursor); err != nil {
+		t.Fatal(err)
+	}
+
+	result, _, err = repos.Products.Search(ctx, search)
+	must(t, err)
+
+	if len(result.Products) != 2 || result.Products[0].ID != firstPage[0] || result.Products[1].ID != firstPage[1] {
+		t.Errorf("paging back by price got %+v, want %v", result.Products, firstPage)
+	}
+
+	search.Page.Cursor.Key = "not a price"
+	_, _, err = repos.Products.Search(ctx, search)
+	wantKind(t, err, apperr.KindValidation)
+}
+
+func testVariants(t *testing.T, repos *repocitory.Repositories) {
+	ctx := context.Background()
+	category := newCategory(t, repos, nil)
+	product := newProduct(t, repos, category.ID, "Kanga", 700)
+	simple := defaultVariant(t, repos, product.ID)
+
+	product.Name = "Kanga Print"
+	simple.Stock = 15
+	must(t, repos.Products.UpdateWithStock(ctx, product, simple))
+
+	got, err := repos.Products.GetById(ctx, product.ID)
+	must(t, err)
+	if got.Name != "Kanga Print" || got.Stock != 15 || product.Stock != 15 {
+		t.Errorf("UpdateWithStock got %q with stock %d, want Kanga Print with 15", got.Name, got.Stock)
+	}
+
+	option := &model.ProductOption{
+		BaseModel: model.BaseModel{ID: uuid.New()},
+		ProductID: product.ID,
+		Name:      "Size",
+	}
+	small := &model.ProductOptionValue{ID: uuid.New(), OptionID: option.ID, Value: "S", Position: 0}
+	large := &model.ProductOptionValue{ID: uuid.New(), OptionID: option.ID, Value: "L", Position: 1}
+	option.Values = []*model.ProductOptionValue{small, large}
+	must(t, repos.Variants.CreateOption(ctx, option))
+
+	wantKind(t, repos.Variants.CreateOption(ctx, &model.ProductOption{
+		BaseModel: model.BaseModel{ID: uuid.New()},
+		ProductID: product.ID,
+		Name:      "Size",
+	}), apperr.KindConflict)
+
+	sku := unique("KANGA-S")
+	for _, v := range []struct {
+		value *model.ProductOptionValue
+		sku   *string
+		stock int
+	}{{small, &sku, 3}, {large, nil, 4}} {
+		variant := &model.ProductVariant{BaseModel: model.BaseModel{ID: uuid.New()}, ProductID: product.ID, SKU: v.sku, Stock: v.stock}
+		must(t, repos.Variants.Create(ctx, variant, []uuid.UUID{v.value.ID}))
+	}
+
+	variants, err := repos.Variants.ListByProduct(ctx, product.ID)
+	must(t, err)
+
+	if len(variants) != 2 || variants[0].Options["Size"] != "S" || variants[1].Options["Size"] != "L" {
+		t.Fatalf("ListByProduct got %+v", variants)
+	}
+
+	// the simple product's variant is replaced by the ones with options
+	_, err = repos.Variants.GetById(ctx, simple.ID)
+	wantNotFound(t, err)
+
+	if _, err := repos.Variants.GetByIdIncludingDeleted(ctx, simple.ID); err != nil {
+		t.Errorf("GetByIdIncludingDeleted lost the replaced variant: %v", err)
+	}
+
+	got, err = repos.Products.GetById(ctx, product.ID)
+	must(t, err)
+	if got.Stock != 7 {
+		t.Errorf("product stock is %d, want the variants' 7", got.Stock)
+	}
+
+	// a product with options takes no stock of its own, and nothing of the
+	// update is kept
+	product.Name = "Kanga Plain"
+	for _, variant := range []*model.ProductVariant{simple, variants[0]} {
+		variant.Stock = 1
+		wantNotFound(t, repos.Products.UpdateWithStock(ctx, product, variant))
+	}
+
+	got, err = repos.Products.GetById(ctx, product.ID)
+	must(t, err)
+	if got.Name != "Kanga Print" || got.Stock != 7 {
+		t.Errorf("a failed UpdateWithStock left %q with stock %d", got.Name, got.Stock)
+	}
+
+	duplicate := &model.ProductVariant{BaseModel: model.BaseModel{ID: uuid.New()}, ProductID: product.ID, SKU: &sku}
+	wantKind(t, repos.Variants.Create(ctx, duplicate, []uuid.UUID{large.ID}), apperr.KindConflict)
+
+	taken, err := repos.Variants.SKUTaken(ctx, sku, product.ID, uuid.New())
+	must(t, err)
+	if !taken {
+		t.Error("SKUTaken is false for another variant's SKU")
+	}
+
+	wantKind(t, repos.Variants.DeleteOption(ctx, option.ID), apperr.KindConflict)
+
+	variants[1].Stock = 10
+	must(t, repos.Variants.Update(ctx, variants[1]))
+	must(t, repos.Variants.Delete(ctx, variants[0]))
+
+	got, err = repos.Products.GetById(ctx, product.ID)
+	must(t, err)
+	if got.Stock != 10 {
+		t.Errorf("product stock is %d after the update and delete, want 10", got.Stock)
+	}
+}
+
+func testImages(t *testing.T, repos *repocitory.Repositories) {
+	ctx := context.Background()
+	category := newCategory(t, repos, nil)
+	product := newProduct(t, repos, category.ID, "Kiondo", 1500)
+	other := newProduct(t, repos, category.ID, "Kikoi", 900)
+
+	newImage := func(productId uuid.UUID, variants ...*model.ProductImageVariant) *model.ProductImage {
+		t.Helper()
+
+		image := &model.ProductImage{
+			BaseModel:   model.BaseModel{ID: uuid.New()},
+			ProductID:   productId,
+			URL:         "/uploads/" + unique("image") + ".jpg",
+			ContentType: "image/jpeg",
+			Size:        2048,
+			Width:       1600,
+			Height:      1200,
+			StorageKey:  unique("products/image"),
+		}
+		for _, v := range variants {
+			v.ImageID = image.ID
+		}
+		must(t, repos.Images.Create(ctx, image, variants))
+
+		return image
+	}
+
+	variant := func(name, contentType string, width int) *model.ProductImageVariant {
+		return &model.ProductImageVariant{
+			BaseModel:   model.BaseModel{ID: uuid.New()},
+			Name:        name,
+			ContentType: contentType,
+			Width:       width,
+			Height:      width * 3 / 4,
+			Size:        512,
+			StorageKey:  unique("products/" + name),
+			URL:         "/uploads/" + unique(name),
+		}
+	}
+
+	largeJPEG := variant("large", "image/jpeg", 1200)
+	thumbWebP := variant("thumbnail", "image/webp", 150)
+	thumbJPEG := variant("thumbnail", "image/jpeg", 150)
+
+	first := newImage(product.ID, largeJPEG, thumbWebP, thumbJPEG)
+	second := newImage(product.ID)
+	third := newImage(product.ID)
+
+	if !first.IsPrimary || second.IsPrimary || first.Position != 0 || second.Position != 1 || third.Position != 2 {
+		t.Errorf("Create got primary %v, %v and positions %d, %d, %d, want the first primary in upload order",
+			first.IsPrimary, second.IsPrimary, first.Position, second.Position, third.Position)
+	}
+
+	got, err := repos.Images.GetById(ctx, first.ID)
+	must(t, err)
+
+	thumbnail, large := got.Variants["thumbnail"], got.Variants["large"]
+	if len(got.Variants) != 2 || thumbnail == nil || large == nil ||
+		thumbnail.URL != thumbJPEG.URL || thumbnail.WebPURL != thumbWebP.URL || large.URL != largeJPEG.URL || large.WebPURL != "" {
+		t.Errorf("GetById got variants %+v", got.Variants)
+	}
+
+	variants, err := repos.Images.ListVariants(ctx, []uuid.UUID{first.ID, second.ID})
+	must(t, err)
+
+	if len(variants) != 3 || variants[0].ID != thumbJPEG.ID || variants[1].ID != thumbWebP.ID || variants[2].ID != largeJPEG.ID {
+		t.Errorf("ListVariants got %+v, want them by width then content type", variants)
+	}
+
+	duplicate := &model.ProductImage{BaseModel: model.BaseModel{ID: uuid.New()}, ProductID: product.ID, URL: "/uploads/duplicate.jpg"}
+	copies := []*model.ProductImageVariant{variant("medium", "image/jpeg", 600), variant("medium", "image/jpeg", 600)}
+	for _, v := range copies {
+		v.ImageID = duplicate.ID
+	}
+	wantKind(t, repos.Images.Create(ctx, duplicate, copies), apperr.KindConflict)
+
+	// nothing of the image was kept
+	_, err = repos.Images.GetById(ctx, duplicate.ID)
+	wantNotFound(t, err)
+
+	wantKind(t, repos.Images.Create(ctx, &model.ProductImage{
+		BaseModel: model.BaseModel{ID: uuid.New()},
+		ProductID: uuid.New(),
+		URL:       "/uploads/orphan.jpg",
+	}, nil), apperr.KindValidation)
+
+	must(t, repos.Images.Reorder(ctx, product.ID, []uuid.UUID{third.ID, first.ID}))
+
+	listed, err := repos.Images.ListByProduct(ctx, product.ID)
+	must(t, err)
+
+	if len(listed) != 3 || listed[0].ID != third.ID || listed[1].ID != first.ID || listed[2].ID != second.ID {
+		t.Fatalf("ListByProduct after Reorder got %+v", listed)
+	}
+	for i, image := range listed {
+		if image.Position != i {
+			t.Errorf("image %d is at position %d", i, image.Position)
+		}
+	}
+
+	must(t, repos.Images.SetPrimary(ctx, product.ID, second.ID))
+	must(t, repos.Images.UpdateAltText(ctx, second.ID, "Kiondo basket, front"))
+	wantKind(t, repos.Images.UpdateAltText(ctx, second.ID, strings.Repeat("a", 101)), apperr.KindValidation)
+
+	productVariant := defaultVariant(t, repos, product.ID)
+	must(t, repos.Images.SetVariant(ctx, third.ID, &productVariant.ID))
+
+	byProduct, err := repos.Images.ListByProducts(ctx, []uuid.UUID{product.ID, other.ID})
+	must(t, err)
+
+	if len(byProduct[product.ID]) != 3 || len(byProduct[other.ID]) != 0 {
+		t.Fatalf("ListByProducts got %+v", byProduct)
+	}
+
+	for _, image := range byProduct[product.ID] {
+		if image.IsPrimary != (image.ID == second.ID) {
+			t.Errorf("image %v has primary %v after SetPrimary", image.ID, image.IsPrimary)
+		}
+
+		if image.ID == second.ID && image.AltText != "Kiondo basket, front" {
+			t.Errorf("UpdateAltText left %q", image.AltText)
+		}
+
+		if (image.ID == third.ID) != (image.VariantID != nil && *image.VariantID == productVariant.ID) {
+			t.Errorf("image %v has variant %v after SetVariant", image.ID, image.VariantID)
+		}
+	}
+
+	must(t, repos.Images.SetVariant(ctx, third.ID, nil))
+
+	got, err = repos.Images.GetById(ctx, third.ID)
+	must(t, err)
+	if got.VariantID != nil {
+		t.Errorf("SetVariant nil left %v", got.VariantID)
+	}
+
+	// deleting the primary image hands it to the first image left
+	second.IsPrimary = true
+	must(t, repos.Images.Delete(ctx, second))
+
+	_, err = repos.Images.GetById(ctx, second.ID)
+	wantNotFound(t, err)
+
+	listed, err = repos.Images.ListByProduct(ctx, product.ID)
+	must(t, err)
+
+	if len(listed) != 2 || listed[0].ID != third.ID || !listed[0].IsPrimary || listed[1].IsPrimary {
+		t.Errorf("ListByProduct after Delete got %+v, want the third image primary", listed)
+	}
+
+	_, err = repos.Images.GetById(ctx, uuid.New())
+	wantNotFound(t, err)
+}
+
+func testDiscounts(t *testing.T, repos *repocitory.Repositories) {
+	ctx := context.Background()
+	category := newCategory(t, repos, nil)
+	product := newProduct(t, repos, category.ID, "Shuka", 2000)
+
+	discount := &model.Discount{
+		BaseModel: model.BaseModel{ID: uuid.New()},
+		ProductID: product.ID,
+		Type:      model.DiscountPercentage,
+		Value:     25,
+	}
+	must(t, repos.Discounts.Create(ctx, discount))
+
+	got, err := repos.Products.GetById(ctx, product.ID)
+	must(t, err)
+
+	if got.DiscountedPrice != 1500 || got.Discount == nil || got.Discount.ID != discount.ID {
+		t.Errorf("the discount was not applied, got %+v", got)
+	}
+
+	listed, info, err := repos.Discounts.List(ctx, product.ID, pagination.Page{Limit: 10, WithTotal: true})
+	must(t, err)
+	if len(listed) != 1 || listed[0].ID != discount.ID || info.Total == nil || *info.Total != 1 {
+		t.Errorf("List got %+v (%+v), want the discount", listed, info)
+	}
+
+	// one overlapping the open ended discount at its priority is refused,
+	// at another priority it is fine
+	overlapping := &model.Discount{
+		BaseModel: model.BaseModel{ID: uuid.New()},
+		ProductID: product.ID,
+		Type:      model.DiscountFixed,
+		Value:     100,
+		StartsAt:  ptr(time.Now().Add(time.Hour)),
+	}
+	if err := repos.Discounts.Create(ctx, overlapping); !errors.Is(err, repocitory.ErrDiscountOverlap) {
+		t.Errorf("creating an overlapping discount got %v, want ErrDiscountOverlap", err)
+	}
+
+	overlapping.Priority = 1
+	must(t, repos.Discounts.Create(ctx, overlapping))
+
+	overlapping.Priority = 0
+	if err := repos.Discounts.Update(ctx, overlapping); !errors.Is(err, repocitory.ErrDiscountOverlap) {
+		t.Errorf("updating into an overlap got %v, want ErrDiscountOverlap", err)
+	}
+
+	stored, err := repos.Discounts.GetById(ctx, overlapping.ID)
+	must(t, err)
+	if stored.Priority != 1 {
+		t.Errorf("a refused Update left priority %d", stored.Priority)
+	}
+
+	// of overlapping discounts saved at the same time only one gets in
+	const saves = 8
+
+	var wg sync.WaitGroup
+	errs := make([]error, saves)
+
+	for i := range saves {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errs[i] = repos.Discounts.Create(ctx, &model.Discount{
+				BaseModel: model.BaseModel{ID: uuid.New()},
+				ProductID: product.ID,
+				Type:      model.DiscountFixed,
+				Value:     50,
+				StartsAt:  ptr(time.Now().Add(time.Duration(i) * time.Minute)),
+				Priority:  5,
+			})
+		}()
+	}
+	wg.Wait()
+
+	saved := 0
+	for _, err := range errs {
+		switch {
+		case err == nil:
+			saved++
+		case !errors.Is(err, repocitory.ErrDiscountOverlap):
+			t.Errorf("Create got %v", err)
+		}
+	}
+
+	if saved != 1 {
+		t.Errorf("%d overlapping discounts were saved at once, want 1", saved)
+	}
+
+	wantKind(t, repos.Discounts.Create(ctx, &model.Discount{
+		BaseModel: model.BaseModel{ID: uuid.New()},
+		ProductID: product.ID,
+		Type:      model.DiscountPercentage,
+		Value:     150,
+		Priority:  9,
+	}), apperr.KindValidation)
+
+	must(t, repos.Discounts.Delete(ctx, discount.ID))
+
+	_, err = repos.Discounts.GetById(ctx, discount.ID)
+	wantNotFound(t, err)
+
+	got, err = repos.Products.GetById(ctx, product.ID)
+	must(t, err)
+	if got.DiscountedPrice != 2000 || got.Discount != nil {
+		t.Errorf("a deleted discount is still applied, got %+v", got)
+	}
+}
+
+func testDiscountStartAlerts(t *testing.T, repos *repocitory.Repositories) {
+	ctx := context.Background()
+	category := newCategory(t, repos, nil)
+	product := newProduct(t, repos, category.ID, "Kikoi", 1200)
+
+	running := &model.Discount{
+		BaseModel: model.BaseModel{ID: uuid.New()},
+		ProductID: product.ID,
+		Type:      model.DiscountFixed,
+		Value:     100,
+		StartsAt:  ptr(time.Now().Add(-time.Hour)),
+	}
+	must(t, repos.Discounts.Create(ctx, running))
+
+	scheduled := &model.Discount{
+		BaseModel: model.BaseModel{ID: uuid.New()},
+		ProductID: product.ID,
+		Type:      model.DiscountPercentage,
+		Value:     20,
+		StartsAt:  ptr(time.Now().Add(300 * time.Millisecond)),
+		Priority:  1,
+	}
+	must(t, repos.Discounts.Create(ctx, scheduled))
+
+	later := &model.Discount{
+		BaseModel: model.BaseModel{ID: uuid.New()},
+		ProductID: product.ID,
+		Type:      model.DiscountPercentage,
+		Value:     30,
+		StartsAt:  ptr(time.Now().Add(time.Hour)),
+		Priority:  2,
+	}
+	must(t, repos.Discounts.Create(ctx, later))
+
+	claimed := func() []uuid.UUID {
+		t.Helper()
+
+		discounts, err := repos.Discounts.ClaimStarted(ctx, 100)
+		must(t, err)
+
+		var ids []uuid.UUID
+		for _, d := range discounts {
+			if d.ProductID == product.ID {
+				ids = append(ids, d.ID)
+			}
+		}
+		return ids
+	}
+
+	if ids := claimed(); len(ids) != 0 {
+		t.Fatalf("claimed %v before any scheduled discount started", ids)
+	}
+
+	time.Sleep(500 * time.Millisecond)
+
+	if ids := claimed(); len(ids) != 1 || ids[0] != scheduled.ID {
+		t.Fatalf("claimed %v once the scheduled discount started, want only %v", ids, scheduled.ID)
+	}
+
+	if ids := claimed(); len(ids) != 0 {
+		t.Errorf("claimed %v again", ids)
+	}
+
+	// moving a discount that already started forward makes it wait again
+	scheduled.StartsAt = ptr(time.Now().Add(300 * time.Millisecond))
+	must(t, repos.Discounts.Update(ctx, scheduled))
+
+	time.Sleep(500 * time.Millisecond)
+
+	if ids := claimed(); len(ids) != 1 || ids[0] != scheduled.ID {
+		t.Errorf("claimed %v after the discount was rescheduled, want %v", ids, scheduled.ID)
+	}
+}
+
+func testPromotions(t *testing.T, repos *repocitory.Repositories) {
+	ctx := context.Background()
+	user := newUser(t, repos)
+
+	code := unique("KARIBU")
+	promotion := &model.Promotion{
+		BaseModel:        model.BaseModel{ID: uuid.New()},
+		Code:             &code,
+		Scope:            model.PromotionScopeCart,
+		Type:             model.DiscountFixed,
+		Value:            100,
+		PerCustomerLimit: ptr(1),
+		Active:           true,
+	}
+	must(t, repos.Promotions.Create(ctx, promotion))
+
+	got, err := repos.Promotions.GetByCode(ctx, code)
+	must(t, err)
+	if got.ID != promotion.ID {
+		t.Errorf("GetByCode got %v, want %v", got.ID, promotion.ID)
+	}
+
+	wantKind(t, repos.Promotions.Create(ctx, &model.Promotion{
+		BaseModel: model.BaseModel{ID: uuid.New()},
+		Code:      &code,
+		Scope:     model.PromotionScopeCart,
+		Type:      model.DiscountFixed,
+		Value:     50,
+	}), apperr.KindConflict)
+
+	wantKind(t, repos.Promotions.Create(ctx, &model.Promotion{
+		BaseModel: model.BaseModel{ID: uuid.New()},
+		Scope:     model.PromotionScopeProduct,
+		Type:      model.DiscountFixed,
+		Value:     50,
+	}), apperr.KindValidation)
+
+	automatic := &model.Promotion{
+		BaseModel: model.BaseModel{ID: uuid.New()},
+		Scope:     model.PromotionScopeCart,
+		Type:      model.DiscountPercentage,
+		Value:     10,
+		Active:    true,
+	}
+	must(t, repos.Promotions.Create(ctx, automatic))
+
+	running, err := repos.Promotions.ListAutomatic(ctx, time.Now())
+	must(t, err)
+	if len(running) != 1 || running[0].ID != automatic.ID {
+		t.Errorf("ListAutomatic got %+v", running)
+	}
+
+	cart, err := repos.Carts.CreateCart(ctx, user.ID)
+	must(t, err)
+
+	must(t, repos.Promotions.AddToCart(ctx, cart.ID, promotion.ID))
+	must(t, repos.Promotions.AddToCart(ctx, cart.ID, promotion.ID))
+
+	entered, err := repos.Promotions.ListForCart(ctx, cart.ID)
+	must(t, err)
+	if len(entered) != 1 || entered[0].ID != promotion.ID {
+		t.Errorf("ListForCart got %+v", entered)
+	}
+
+	order := newOrder(t, repos, user.ID)
+	redemption := func() *model.PromotionRedemption {
+		return &model.PromotionRedemption{
+			BaseModel:   model.BaseModel{ID: uuid.New()},
+			PromotionID: promotion.ID,
+			OrderID:     order.ID,
+			UserID:      user.ID,
+			Code:        &code,
+			Amount:      100,
+		}
+	}
+
+	must(t, repos.Promotions.Redeem(ctx, redemption()))
+
+	if err := repos.Promotions.Redeem(ctx, redemption()); !errors.Is(err, repocitory.ErrPromotionExhausted) {
+		t.Errorf("redeeming past the per customer limit got %v, want ErrPromotionExhausted", err)
+	}
+
+	total, byUser, err := repos.Promotions.Usage(ctx, promotion.ID, user.ID)
+	must(t, err)
+	if total != 1 || byUser != 1 {
+		t.Errorf("Usage got %d, %d, want 1, 1", total, byUser)
+	}
+
+	must(t, repos.Promotions.ReleaseRedemptions(ctx, order.ID))
+	must(t, repos.Promotions.Redeem(ctx, redemption()))
+
+	must(t, repos.Promotions.Delete(ctx, promotion.ID))
+
+	_, err = repos.Promotions.GetByCode(ctx, code)
+	wantNotFound(t, err)
+
+	// a deleted promotion's code can be used again
+	must(t, repos.Promotions.Create(ctx, &model.Promotion{
+		BaseModel: model.BaseModel{ID: uuid.New()},
+		Code:      &code,
+		Scope:     model.PromotionScopeCart,
+		Type:      model.DiscountFixed,
+		Value:     50,
+	}))
+
+	entered, err = repos.Promotions.ListForCart(ctx, cart.ID)
+	must(t, err)
+	if len(entered) != 0 {
+		t.Errorf("ListForCart still has the deleted promotion: %+v", entered)
+	}
+}
+
+// testPromotionRedeemConcurrently checks that a usage limit holds when many
+// orders redeem the promotion at once.
+func testPromotionRedeemConcurrently(t *testing.T, repos *repocitory.Repositories) {
+	ctx := context.Background()
+
+	promotion := &model.Promotion{
+		BaseModel:  model.BaseModel{ID: uuid.New()},
+		Scope:      model.PromotionScopeCart,
+		Type:       model.DiscountFixed,
+		Value:      100,
+		UsageLimit: ptr(5),
+		Active:     true,
+	}
+	must(t, repos.Promotions.Create(ctx, promotion))
+
+	const customers = 20
+
+	var redemptions []*model.PromotionRedemption
+	for range customers {
+		user := newUser(t, repos)
+		redemptions = append(redemptions, &model.PromotionRedemption{
+			BaseModel:   model.BaseModel{ID: uuid.New()},
+			PromotionID: promotion.ID,
+			OrderID:     newOrder(t, repos, user.ID).ID,
+			UserID:      user.ID,
+			Amount:      100,
+		})
+	}
+
+	var wg sync.WaitGroup
+	errs := make([]error, customers)
+
+	for i, redemption := range redemptions {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errs[i] = repos.Promotions.Redeem(ctx, redemption)
+		}()
+	}
+	wg.Wait()
+
+	redeemed := 0
+	for _, err := range errs {
+		switch {
+		case err == nil:
+			redeemed++
+		case !errors.Is(err, repocitory.ErrPromotionExhausted):
+			t.Errorf("Redeem got %v", err)
+		}
+	}
+
+	if redeemed != 5 {
+		t.Errorf("%d redemptions went through, want the limit of 5", redeemed)
+	}
+}
+
+func testCarts(t *testing.T, repos *repocitory.Repositories) {
+	ctx := context.Background()
+	user := newUser(t, repos)
+
+	_, err := repos.Carts.GetShoppingCart(ctx, user.ID)
+	wantNotFound(t, err)
+
+	cart, err := repos.Carts.CreateCart(ctx, user.ID)
+	must(t, err)
+
+	got, err := repos.Carts.GetShoppingCart(ctx, user.ID)
+	must(t, err)
+	if got.ID != cart.ID || got.UserId != user.ID {
+		t.Errorf("GetShoppingCart got %+v, want %+v", got, cart)
+	}
+
+	_, err = repos.Carts.CreateCart(ctx, uuid.New())
+	wantKind(t, err, apperr.KindValidation)
+
+	category := newCategory(t, repos, nil)
+	product := newProduct(t, repos, category.ID, "Kikoi", 900)
+	variant := defaultVariant(t, repos, product.ID)
+
+	item := &model.CartItem{
+		BaseModel: model.BaseModel{ID: uuid.New()},
+		CartId:    cart.ID,
+		ProductId: product.ID,
+		VariantId: variant.ID,
+		Quantity:  2,
+		Price:     900,
+	}
+	must(t, repos.CartItems.AddItem(ctx, item))
+
+	// a variant is in a cart once, its quantity says how many
+	again := *item
+	again.ID = uuid.New()
+	wantKind(t, repos.CartItems.AddItem(ctx, &again), apperr.KindConflict)
+
+	wantKind(t, repos.CartItems.UpdateQuantity(ctx, item.ID, 0), apperr.KindValidation)
+
+	exists, err := repos.CartItems.Exists(ctx, cart.ID, variant.ID)
+	must(t, err)
+	if !exists {
+		t.Error("Exists is false for an item in the cart")
+	}
+
+	must(t, repos.CartItems.UpdateQuantity(ctx, item.ID, 5))
+
+	items, err := repos.CartItems.GetItems(ctx, cart.ID)
+	must(t, err)
+	if len(items) != 1 || items[0].Quantity != 5 || items[0].Price != 900 {
+		t.Errorf("GetItems got %+v", items)
+	}
+
+	must(t, repos.CartItems.ClearCart(ctx, cart.ID))
+
+	items, err = repos.CartItems.GetItems(ctx, cart.ID)
+	must(t, err)
+	if len(items) != 0 {
+		t.Errorf("ClearCart left %d items", len(items))
+	}
+}
+
+func testCartReminders(t *testing.T, repos *repocitory.Repositories) {
+	ctx := context.Background()
+	category := newCategory(t, repos, nil)
+	product := newProduct(t, repos, category.ID, "Kikoi", 900)
+	variant := defaultVariant(t, repos, product.ID)
+
+	fillCart := func(user *model.User) *model.Cart {
+		cart, err := repos.Carts.CreateCart(ctx, user.ID)
+		must(t, err)
+
+		must(t, repos.CartItems.AddItem(ctx, &model.CartItem{
+			BaseModel: model.BaseModel{ID: uuid.New()},
+			CartId:    cart.ID,
+			ProductId: product.ID,
+			VariantId: variant.ID,
+			Quantity:  3,
+			Price:     900,
+		}))
+
+		return cart
+	}
+
+	user := newUser(t, repos)
+	cart := fillCart(user)
+
+	optedOut := newUser(t, repos)
+	fillCart(optedOut)
+	must(t, repos.Users.UpdateCartRemindersOptOut(ctx, optedOut.ID, true))
+
+	idleSince := time.Now().Add(time.Hour)
+
+	abandoned, err := repos.CartReminders.FindAbandoned(ctx, idleSince, 10)
+	must(t, err)
+
+	if len(abandoned) != 1 {
+		t.Fatalf("FindAbandoned got %d carts, want 1", len(abandoned))
+	}
+
+	if a := abandoned[0]; a.CartId != cart.ID || a.Email != user.Email || a.ItemCount != 1 || a.Subtotal != 2700 {
+		t.Errorf("FindAbandoned got %+v", a)
+	}
+
+	must(t, repos.CartReminders.Create(ctx, &model.CartReminder{
+		BaseModel: model.BaseModel{ID: uuid.New()},
+		CartId:    cart.ID,
+		UserId:    user.ID,
+		Channels:  "email",
+		SentAt:    time.Now(),
+	}))
+
+	abandoned, err = repos.CartReminders.FindAbandoned(ctx, idleSince, 10)
+	must(t, err)
+	if len(abandoned) != 0 {
+		t.Errorf("FindAbandoned returned a cart that was reminded about: %+v", abandoned)
+	}
+}
+
+func testOrders(t *testing.T, repos *repocitory.Repositories) {
+	ctx := context.Background()
+	user := newUser(t, repos)
+
+	first := newOrder(t, repos, user.ID)
+	second := newOrder(t, repos, user.ID)
+	cancelled := newOrder(t, repos, user.ID)
+
+	if first.Status != model.StatusPending || first.Paid || first.InvoiceNumber != nil {
+		t.Errorf("a new order got %+v", first)
+	}
+
+	_, err := repos.Orders.Create(ctx, &model.Orders{BaseModel: model.BaseModel{ID: uuid.New()}, UserID: uuid.New()})
+	wantKind(t, err, apperr.KindValidation)
+
+	for i, order := range []*model.Orders{first, second} {
+		paid, err := repos.Orders.MarkPaid(ctx, order.ID, "MPESA-"+order.ID.String()[:8])
+		must(t, err)
+
+		if !paid.Paid || paid.PaidAt == nil || paid.InvoiceNumber == nil || *paid.InvoiceNumber != int64(i+1) {
+			t.Errorf("MarkPaid got %+v, want invoice number %d", paid, i+1)
+		}
+	}
+
+	_, err = repos.Orders.MarkPaid(ctx, first.ID, "MPESA-AGAIN")
+	if !errors.Is(err, repocitory.ErrOrderAlreadyPaid) {
+		t.Errorf("paying twice got %v, want ErrOrderAlreadyPaid", err)
+	}
+
+	must(t, repos.Orders.UpdateStatus(ctx, cancelled.ID, model.StatusCancelled))
+
+	_, err = repos.Orders.MarkPaid(ctx, cancelled.ID, "MPESA-LATE")
+	if !errors.Is(err, repocitory.ErrOrderCancelled) {
+		t.Errorf("paying a cancelled order got %v, want ErrOrderCancelled", err)
+	}
+
+	_, err = repos.Orders.MarkPaid(ctx, uuid.New(), "MPESA-NONE")
+	wantNotFound(t, err)
+
+	orders, err := repos.Orders.GetByUser(ctx, user.ID)
+	must(t, err)
+	if len(orders) != 3 || orders[0].ID != cancelled.ID || orders[0].Status != model.StatusCancelled {
+		t.Errorf("GetByUser got %+v, want the newest order first", orders)
+	}
+
+	all, info, err := repos.Orders.GetAll(ctx, pagination.Page{Limit: 2, WithTotal: true})
+	must(t, err)
+	if len(all) != 2 || info.NextCursor == nil || info.Total == nil || *info.Total != 3 {
+		t.Errorf("GetAll got %d orders and %+v", len(all), info)
+	}
+}
+
+// testPlaceOrder checks that a checkout is stored whole or not at all.
+func testPlaceOrder(t *testing.T, repos *repocitory.Repositories) {
+	ctx := context.Background()
+	user := newUser(t, repos)
+	category := newCategory(t, repos, nil)
+	product := newProduct(t, repos, category.ID, "Kikoi", 900)
+	variant := defaultVariant(t, repos, product.ID)
+
+	promotion := &model.Promotion{
+		BaseModel:  model.BaseModel{ID: uuid.New()},
+		Scope:      model.PromotionScopeCart,
+		Type:       model.DiscountFixed,
+		Value:      100,
+		UsageLimit: ptr(1),
+		Active:     true,
+	}
+	must(t, repos.Promotions.Create(ctx, promotion))
+
+	cart, err := repos.Carts.CreateCart(ctx, user.ID)
+	must(t, err)
+
+	must(t, repos.CartItems.AddItem(ctx, &model.CartItem{
+		BaseModel: model.BaseModel{ID: uuid.New()},
+		CartId:    cart.ID,
+		ProductId: product.ID,
+		VariantId: variant.ID,
+		Quantity:  1,
+		Price:     900,
+	}))
+	must(t, repos.Promotions.AddToCart(ctx, cart.ID, promotion.ID))
+
+	checkout := func(itemOrderId *uuid.UUID) *repocitory.Checkout {
+		order := &model.Orders{
+			BaseModel:     model.BaseModel{ID: uuid.New()},
+			UserID:        user.ID,
+			Subtotal:      900,
+			DiscountTotal: 100,
+			Total:         800,
+		}
+
+		item := &model.OrderItems{
+			BaseModel: model.BaseModel{ID: uuid.New()},
+			OrderID:   order.ID,
+			ProductID: product.ID,
+			VariantID: variant.ID,
+			Quantity:  1,
+			Price:     900,
+			Discount:  100,
+			TaxClass:  model.TaxStandard,
+		}
+		if itemOrderId != nil {
+			item.OrderID = *itemOrderId
+		}
+
+		return &repocitory.Checkout{
+			Order: order,
+			Items: []*model.OrderItems{item},
+			Redemptions: []*model.PromotionRedemption{{
+				BaseModel:   model.BaseModel{ID: uuid.New()},
+				PromotionID: promotion.ID,
+				OrderID:     order.ID,
+				UserID:      user.ID,
+				Amount:      100,
+			}},
+			CartID: cart.ID,
+		}
+	}
+
+	// nothing of a checkout with a bad item is kept, the promotion use
+	// included
+	failed := checkout(ptr(uuid.New()))
+	_, err = repos.Orders.Place(ctx, failed)
+	wantKind(t, err, apperr.KindValidation)
+
+	_, err = repos.Orders.GetById(ctx, failed.Order.ID)
+	wantNotFound(t, err)
+
+	total, _, err := repos.Promotions.Usage(ctx, promotion.ID, user.ID)
+	must(t, err)
+	if total != 0 {
+		t.Errorf("a failed checkout used the promotion %d times", total)
+	}
+
+	items, err := repos.CartItems.GetItems(ctx, cart.ID)
+	must(t, err)
+	if len(items) != 1 {
+		t.Errorf("a failed checkout left %d items in the cart, want 1", len(items))
+	}
+
+	placed := checkout(nil)
+	order, err := repos.Orders.Place(ctx, placed)
+	must(t, err)
+	if order.ID != placed.Order.ID || order.Status != model.StatusPending || order.Total != 800 {
+		t.Errorf("Place got %+v", order)
+	}
+
+	orderItems, err := repos.OrderItems.GetByOrder(ctx, order.ID)
+	must(t, err)
+	if len(orderItems) != 1 {
+		t.Errorf("the order has %d items, want 1", len(orderItems))
+	}
+
+	items, err = repos.CartItems.GetItems(ctx, cart.ID)
+	must(t, err)
+	entered, err := repos.Promotions.ListForCart(ctx, cart.ID)
+	must(t, err)
+	if len(items) != 0 || len(entered) != 0 {
+		t.Errorf("the cart still has %d items and %d promotions", len(items), len(entered))
+	}
+
+	// the promotion's single use is gone
+	again := checkout(nil)
+	if _, err := repos.Orders.Place(ctx, again); !errors.Is(err, repocitory.ErrPromotionExhausted) {
+		t.Errorf("placing past the usage limit got %v, want ErrPromotionExhausted", err)
+	}
+
+	_, err = repos.Orders.GetById(ctx, again.Order.ID)
+	wantNotFound(t, err)
+}
+
+func testOrderItems(t *testing.T, repos *repocitory.Repositories) {
+	ctx := context.Background()
+	user := newUser(t, repos)
+	order := newOrder(t, repos, user.ID)
+	category := newCategory(t, repos, nil)
+	product := newProduct(t, repos, category.ID, "Kikoi", 900)
+	variant := defaultVariant(t, repos, product.ID)
+
+	item := func(orderId uuid.UUID) *model.OrderItems {
+		return &model.OrderItems{
+			BaseModel: model.BaseModel{ID: uuid.New()},
+			OrderID:   orderId,
+			ProductID: product.ID,
+			VariantID: variant.ID,
+			Quantity:  1,
+			Price:     900,
+			TaxClass:  model.TaxStandard,
+			TaxRate:   1600,
+			Tax:       124,
+
+			ProductName: "Kikoi",
+			VariantName: "Blue / L",
+		}
+	}
+
+	must(t, repos.OrderItems.CreateBulk(ctx, nil))
+
+	// one bad item keeps the others out too
+	wantKind(t, repos.OrderItems.CreateBulk(ctx, []*model.OrderItems{item(order.ID), item(uuid.New())}), apperr.KindValidation)
+
+	items, err := repos.OrderItems.GetByOrder(ctx, order.ID)
+	must(t, err)
+	if len(items) != 0 {
+		t.Fatalf("a failed CreateBulk stored %d items", len(items))
+	}
+
+	none := item(order.ID)
+	none.Quantity = 0
+	wantKind(t, repos.OrderItems.Create(ctx, none), apperr.KindValidation)
+
+	must(t, repos.OrderItems.CreateBulk(ctx, []*model.OrderItems{item(order.ID), item(order.ID)}))
+	must(t, repos.OrderItems.Create(ctx, item(order.ID)))
+
+	items, err = repos.OrderItems.GetByOrder(ctx, order.ID)
+	must(t, err)
+	if len(items) != 3 || items[0].TaxRate != 1600 || items[0].Tax != 124 ||
+		items[0].ProductName != "Kikoi" || items[0].VariantName != "Blue / L" {
+		t.Errorf("GetByOrder got %+v", items)
+	}
+}
+
+func testWishlists(t *testing.T, repos *repocitory.Repositories) {
+	ctx := context.Background()
+	user := newUser(t, repos)
+	category := newCategory(t, repos, nil)
+	product := newProduct(t, repos, category.ID, "Kiondo", 1200)
+
+	wishlist := &model.Wishlist{
+		BaseModel:  model.BaseModel{ID: uuid.New()},
+		UserId:     user.ID,
+		Name:       "Wishlist",
+		IsDefault:  true,
+		ShareToken: ptr(unique("share")),
+	}
+	must(t, repos.Wishlists.Create(ctx, wishlist))
+
+	wantKind(t, repos.Wishlists.Create(ctx, &model.Wishlist{
+		BaseModel: model.BaseModel{ID: uuid.New()},
+		UserId:    user.ID,
+		Name:      "Another default",
+		IsDefault: true,
+	}), apperr.KindConflict)
+
+	gifts := &model.Wishlist{BaseModel: model.BaseModel{ID: uuid.New()}, UserId: user.ID, Name: "Gifts"}
+	must(t, repos.Wishlists.Create(ctx, gifts))
+
+	birthday := &model.Wishlist{BaseModel: model.BaseModel{ID: uuid.New()}, UserId: user.ID, Name: "Birthday"}
+	must(t, repos.Wishlists.Create(ctx, birthday))
+
+	// the default first, then the named ones newest first, a page at a time
+	lists, info, err := repos.Wishlists.ListByUser(ctx, user.ID, pagination.Page{Limit: 2, WithTotal: true})
+	must(t, err)
+	if len(lists) != 2 || lists[0].ID != wishlist.ID || lists[1].ID != birthday.ID {
+		t.Errorf("ListByUser got %+v, want the default first", lists)
+	}
+
+	if info.Total == nil || *info.Total != 3 || info.NextCursor == nil {
+		t.Fatalf("got page %+v, want a next page and 3 wishlists in all", info)
+	}
+
+	cursor, err := pagination.Decode(*info.NextCursor)
+	must(t, err)
+
+	lists, info, err = repos.Wishlists.ListByUser(ctx, user.ID, pagination.Page{Limit: 2, Cursor: cursor})
+	must(t, err)
+	if len(lists) != 1 || lists[0].ID != gifts.ID || info.NextCursor != nil {
+		t.Errorf("the second page got %+v, want only %v", lists, gifts.ID)
+	}
+
+	if info.PrevCursor == nil {
+		t.Fatal("the second page has no previous cursor")
+	}
+
+	cursor, err = pagination.Decode(*info.PrevCursor)
+	must(t, err)
+
+	lists, _, err = repos.Wishlists.ListByUser(ctx, user.ID, pagination.Page{Limit: 2, Cursor: cursor})
+	must(t, err)
+	if len(lists) != 2 || lists[0].ID != wishlist.ID || lists[1].ID != birthday.ID {
+		t.Errorf("paging back got %+v, want the first page again", lists)
+	}
+
+	got, err := repos.Wishlists.GetByShareToken(ctx, *wishlist.ShareToken)
+	must(t, err)
+	if got.ID != wishlist.ID {
+		t.Errorf("GetByShareToken got %v, want %v", got.ID, wishlist.ID)
+	}
+
+	addItem := func(wishlistId uuid.UUID) error {
+		return repos.Wishlists.AddItem(ctx, &model.WishlistItem{
+			BaseModel:  model.BaseModel{ID: uuid.New()},
+			WishlistId: wishlistId,
+			ProductId:  product.ID,
+			PriceAtAdd: 1200,
+		})
+	}
+
+	must(t, addItem(wishlist.ID))
+	must(t, addItem(gifts.ID))
+	wantKind(t, addItem(wishlist.ID), apperr.KindConflict)
+
+	watchers, err := repos.Wishlists.ListWatchers(ctx, product.ID)
+	must(t, err)
+	if len(watchers) != 1 || watchers[0].UserId != user.ID {
+		t.Errorf("ListWatchers got %+v, want the user once", watchers)
+	}
+
+	must(t, repos.Users.UpdateWishlistAlertsOptOut(ctx, user.ID, true))
+
+	watchers, err = repos.Wishlists.ListWatchers(ctx, product.ID)
+	must(t, err)
+	if len(watchers) != 0 {
+		t.Errorf("ListWatchers includes a user who opted out: %+v", watchers)
+	}
+
+	removed, err := repos.Wishlists.RemoveItem(ctx, gifts.ID, product.ID)
+	must(t, err)
+	if !removed {
+		t.Error("RemoveItem says the product was not there")
+	}
+
+	must(t, repos.Products.Delete(ctx, product.ID))
+
+	items, err := repos.Wishlists.GetItems(ctx, wishlist.ID)
+	must(t, err)
+	if len(items) != 0 {
+		t.Errorf("GetItems includes a deleted product: %+v", items)
+	}
+
+	must(t, repos.Wishlists.Delete(ctx, wishlist.ID))
+
+	_, err = repos.Wishlists.GetByShareToken(ctx, *wishlist.ShareToken)
+	wantNotFound(t, err)
+
+	_, err = repos.Wishlists.GetDefault(ctx, user.ID)
+	wantNotFound(t, err)
+}
+
+func testNotificationOutbox(t *testing.T, repos *repocitory.Repositories) {
+	ctx := context.Background()
+
+	email := &model.OutboxNotification{
+		BaseModel: model.BaseModel{ID: uuid.New()},
+		Channel:   model.NotificationEmail,
+		Recipient: unique("buyer") + "@example.com",
+		Subject:   "Your order has been received",
+		Body:      "Thank you for your order",
+		Attachments: []model.NotificationAttachment{
+			{Filename: "invoice.pdf", ContentType: "application/pdf", Content: []byte("%PDF-1.3")},
+		},
+		LastError: "connection refused",
+	}
+	must(t, repos.Outbox.Add(ctx, email))
+
+	sms := &model.OutboxNotification{
+		BaseModel: model.BaseModel{ID: uuid.New()},
+		Channel:   model.NotificationSMS,
+		Recipient: "+254700000001",
+		Body:      "Your order has been received",
+	}
+	must(t, repos.Outbox.Add(ctx, sms))
+
+	wantKind(t, repos.Outbox.Add(ctx, &model.OutboxNotification{
+		BaseModel: model.BaseModel{ID: uuid.New()},
+		Channel:   "pigeon",
+		Recipient: "someone",
+		Body:      "Hello",
+	}), apperr.KindValidation)
+
+	unsent, err := repos.Outbox.ListUnsent(ctx, 3, 10)
+	must(t, err)
+	if len(unsent) != 2 || unsent[0].ID != email.ID || unsent[1].ID != sms.ID {
+		t.Fatalf("ListUnsent got %+v, want the email and then the SMS", unsent)
+	}
+
+	got := unsent[0]
+	if got.Attempts != 1 || got.LastError != "connection refused" || len(got.Attachments) != 1 ||
+		string(got.Attachments[0].Content) != "%PDF-1.3" || got.SentAt != nil {
+		t.Errorf("ListUnsent got %+v", got)
+	}
+
+	oldest, err := repos.Outbox.OldestUnsent(ctx, 3)
+	must(t, err)
+	if oldest == nil || !oldest.Equal(email.CreatedAt) {
+		t.Errorf("OldestUnsent got %v, want the email's %v", oldest, email.CreatedAt)
+	}
+
+	must(t, repos.Outbox.RecordFailure(ctx, email.ID, "timeout"))
+	must(t, repos.Outbox.RecordFailure(ctx, email.ID, "timeout"))
+	must(t, repos.Outbox.MarkSent(ctx, sms.ID))
+
+	unsent, err = repos.Outbox.ListUnsent(ctx, 3, 10)
+	must(t, err)
+	if len(unsent) != 0 {
+		t.Errorf("ListUnsent returned sent notifications or ones out of attempts: %+v", unsent)
+	}
+
+	unsent, err = repos.Outbox.ListUnsent(ctx, 4, 10)
+	must(t, err)
+	if len(unsent) != 1 || unsent[0].Attempts != 3 || unsent[0].LastError != "timeout" {
+		t.Errorf("ListUnsent got %+v, want the email after 3 attempts", unsent)
+	}
+
+	oldest, err = repos.Outbox.OldestUnsent(ctx, 3)
+	must(t, err)
+	if oldest != nil {
+		t.Errorf("OldestUnsent got %v with nothing left to send", oldest)
+	}
+}
+
+func testHealth(t *testing.T, repos *repocitory.Repositories) {
+	ctx := context.Background()
+
+	must(t, repos.Health.Ping(ctx))
+
+	latest, err := database.LatestMigration()
+	must(t, err)
+
+	version, dirty, err := repos.Health.SchemaVersion(ctx)
+	must(t, err)
+	if version != latest || dirty {
+		t.Errorf("SchemaVersion got %d, dirty %v, want %d after migrating", version, dirty, latest)
+	}
+}
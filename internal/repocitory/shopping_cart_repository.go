@@ -17,8 +17,8 @@ type shoppingCartRepository struct {
 	db *database.DB
 }
 
-func NewShoppingCartRepository() ShoppingCartRepository {
-	return &shoppingCartRepository{db: database.GetDB()}
+func NewShoppingCartRepository(db *database.DB) ShoppingCartRepository {
+	return &shoppingCartRepository{db: db}
 }
 
 func (r *shoppingCartRepository) CreateCart(ctx context.Context, userId uuid.UUID) (*model.Cart, error) {
@@ -34,7 +34,7 @@ func (r *shoppingCartRepository) CreateCart(ctx context.Context, userId uuid.UUI
 	)
 
 	if err != nil {
-		return nil, err
+		return nil, dbErr(err, "cart")
 	}
 
 	return &cart, nil
@@ -52,7 +52,7 @@ func (r *shoppingCartRepository) GetShoppingCart(ctx context.Context, userId uui
 	)
 
 	if err != nil {
-		return nil, err
+		return nil, dbErr(err, "cart")
 	}
 
 	return &cart, nil
@@ -8,12 +8,49 @@ import (
 	"github.com/google/uuid"
 )
 
+type UserRepository interface {
+	Create(ctx context.Context, user *model.User) error
+	GetById(ctx context.Context, id uuid.UUID) (*model.User, error)
+	GetByEmail(ctx context.Context, email string) (*model.User, error)
+	GetByAuth0Id(ctx context.Context, auth0Id string) (*model.User, error)
+	UpdatePhone(ctx context.Context, id uuid.UUID, phone string) error
+	UpdateRole(ctx context.Context, id uuid.UUID, role model.Roles) error
+	UpdateCartRemindersOptOut(ctx context.Context, id uuid.UUID, optOut bool) error
+	UpdateWishlistAlertsOptOut(ctx context.Context, id uuid.UUID, optOut bool) error
+}
+
+// userColumns are the columns scanUser reads, in order.
+const userColumns = `id, name, email, COALESCE(phone, ''), role, COALESCE(auth0_id, ''),
+	cart_reminders_opt_out, wishlist_alerts_opt_out, created_at, updated_at`
+
+func scanUser(row interface{ Scan(dest ...any) error }) (*model.User, error) {
+	var user model.User
+
+	err := row.Scan(
+		&user.ID,
+		&user.Name,
+		&user.Email,
+		&user.Phone,
+		&user.Role,
+		&user.Auth0Id,
+		&user.CartRemindersOptOut,
+		&user.WishlistAlertsOptOut,
+		&user.CreatedAt,
+		&user.UpdatedAt,
+	)
+	if err != nil {
+		return nil, dbErr(err, "user")
+	}
+
+	return &user, nil
+}
+
 type userRepository struct {
 	db *database.DB
 }
 
-func NewUserRepository() *userRepository {
-	return &userRepository{db: database.GetDB()}
+func NewUserRepository(db *database.DB) UserRepository {
+	return &userRepository{db: db}
 }
 
 func (r *userRepository) Create(ctx context.Context, user *model.User) error {
@@ -23,63 +60,49 @@ func (r *userRepository) Create(ctx context.Context, user *model.User) error {
 		RETURNING created_at, updated_at
 	`
 
-	return r.db.Pool.QueryRow(ctx, query,
+	return dbErr(r.db.Pool.QueryRow(ctx, query,
 		user.ID, user.Name, user.Email, user.Role, user.Auth0Id,
-	).Scan(&user.CreatedAt, &user.UpdatedAt)
+	).Scan(&user.CreatedAt, &user.UpdatedAt), "user")
 }
 
-func (r *userRepository) GetById(context context.Context, id uuid.UUID) (*model.User, error) {
-	row := r.db.Pool.QueryRow(context,
-		`SELECT id, name, email, role FROM users WHERE id=$1`,
-		id,
-	)
+func (r *userRepository) GetById(ctx context.Context, id uuid.UUID) (*model.User, error) {
+	query := `SELECT ` + userColumns + ` FROM users WHERE id = $1`
 
-	user := &model.User{}
+	return scanUser(r.db.Pool.QueryRow(ctx, query, id))
+}
 
-	err := row.Scan(&user.ID, &user.Name, &user.Email, &user.Role)
-	if err != nil {
-		return nil, err
-	}
+func (r *userRepository) GetByEmail(ctx context.Context, email string) (*model.User, error) {
+	query := `SELECT ` + userColumns + ` FROM users WHERE email = $1`
 
-	return user, nil
+	return scanUser(r.db.Pool.QueryRow(ctx, query, email))
 }
 
-func (r *userRepository) GetByEmail(ctx context.Context, email string) (*model.User, error) {
-	row := r.db.Pool.QueryRow(ctx,
-		`SELECT id, name, email, role, created_at, updated_at FROM users WHERE email=$1`,
-		email,
-	)
+func (r *userRepository) GetByAuth0Id(ctx context.Context, auth0Id string) (*model.User, error) {
+	query := `SELECT ` + userColumns + ` FROM users WHERE auth0_id = $1`
 
-	user := &model.User{}
-	err := row.Scan(&user.ID, &user.Name, &user.Email, &user.Role, &user.CreatedAt, &user.UpdatedAt)
-	if err != nil {
-		return nil, err
-	}
+	return scanUser(r.db.Pool.QueryRow(ctx, query, auth0Id))
+}
 
-	return user, nil
+func (r *userRepository) UpdatePhone(ctx context.Context, id uuid.UUID, phone string) error {
+	query := `UPDATE users SET phone = $1, updated_at = now() WHERE id = $2`
+	_, err := r.db.Pool.Exec(ctx, query, phone, id)
+	return dbErr(err, "user")
 }
 
-func (r *userRepository) GetByAuth0Id(ctx context.Context, auth0Id string) (*model.User, error) {
-	row := r.db.Pool.QueryRow(ctx,
-		`SELECT id, name, email, role, auth0_id, created_at, updated_at 
-		 FROM users 
-		 WHERE auth0_id=$1`,
-		auth0Id,
-	)
+func (r *userRepository) UpdateRole(ctx context.Context, id uuid.UUID, role model.Roles) error {
+	query := `UPDATE users SET role = $1, updated_at = now() WHERE id = $2`
+	_, err := r.db.Pool.Exec(ctx, query, role, id)
+	return dbErr(err, "user")
+}
 
-	user := &model.User{}
-	err := row.Scan(
-		&user.ID,
-		&user.Name,
-		&user.Email,
-		&user.Role,
-		&user.Auth0Id,
-		&user.CreatedAt,
-		&user.UpdatedAt,
-	)
-	if err != nil {
-		return nil, err
-	}
+func (r *userRepository) UpdateCartRemindersOptOut(ctx context.Context, id uuid.UUID, optOut bool) error {
+	query := `UPDATE users SET cart_reminders_opt_out = $1, updated_at = now() WHERE id = $2`
+	_, err := r.db.Pool.Exec(ctx, query, optOut, id)
+	return dbErr(err, "user")
+}
 
-	return user, nil
+func (r *userRepository) UpdateWishlistAlertsOptOut(ctx context.Context, id uuid.UUID, optOut bool) error {
+	query := `UPDATE users SET wishlist_alerts_opt_out = $1, updated_at = now() WHERE id = $2`
+	_, err := r.db.Pool.Exec(ctx, query, optOut, id)
+	return dbErr(err, "user")
 }
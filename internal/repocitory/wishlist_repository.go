@@ -0,0 +1,263 @@
+package repocitory
+
+import (
+	"context"
+
+	"github.com/Oj-washingtone/savannah-store/internal/database"
+	"github.com/Oj-washingtone/savannah-store/internal/model"
+	"github.com/Oj-washingtone/savannah-store/internal/pagination"
+	"github.com/google/uuid"
+)
+
+type WishlistRepository interface {
+	Create(ctx context.Context, wishlist *model.Wishlist) error
+	GetById(ctx context.Context, id uuid.UUID) (*model.Wishlist, error)
+	GetDefault(ctx context.Context, userId uuid.UUID) (*model.Wishlist, error)
+	GetByShareToken(ctx context.Context, token string) (*model.Wishlist, error)
+	ListByUser(ctx context.Context, userId uuid.UUID, page pagination.Page) ([]*model.Wishlist, *pagination.Info, error)
+	Update(ctx context.Context, wishlist *model.Wishlist) error
+	Delete(ctx context.Context, id uuid.UUID) error
+
+	AddItem(ctx context.Context, item *model.WishlistItem) error
+	RemoveItem(ctx context.Context, wishlistId, productId uuid.UUID) (bool, error)
+	GetItems(ctx context.Context, wishlistId uuid.UUID) ([]*model.WishlistItem, error)
+	ItemExists(ctx context.Context, wishlistId, productId uuid.UUID) (bool, error)
+	ListWatchers(ctx context.Context, productId uuid.UUID) ([]*model.WishlistWatcher, error)
+}
+
+type wishlistRepository struct {
+	db *database.DB
+}
+
+func NewWishlistRepository(db *database.DB) WishlistRepository {
+	return &wishlistRepository{db: db}
+}
+
+const wishlistColumns = `id, user_id, name, is_default, share_token, created_at, updated_at`
+
+func scanWishlist(row interface{ Scan(dest ...any) error }) (*model.Wishlist, error) {
+	var w model.Wishlist
+	err := row.Scan(
+		&w.ID,
+		&w.UserId,
+		&w.Name,
+		&w.IsDefault,
+		&w.ShareToken,
+		&w.CreatedAt,
+		&w.UpdatedAt,
+	)
+	if err != nil {
+		return nil, dbErr(err, "wishlist")
+	}
+
+	return &w, nil
+}
+
+func (r *wishlistRepository) Create(ctx context.Context, wishlist *model.Wishlist) error {
+	query := `
+		INSERT INTO wishlists (id, user_id, name, is_default, share_token)
+		VALUES ($1, $2, $3, $4, $5)
+		RETURNING created_at, updated_at
+	`
+
+	return dbErr(r.db.Pool.QueryRow(ctx, query,
+		wishlist.ID,
+		wishlist.UserId,
+		wishlist.Name,
+		wishlist.IsDefault,
+		wishlist.ShareToken,
+	).Scan(&wishlist.CreatedAt, &wishlist.UpdatedAt), "wishlist")
+}
+
+func (r *wishlistRepository) GetById(ctx context.Context, id uuid.UUID) (*model.Wishlist, error) {
+	query := `SELECT ` + wishlistColumns + ` FROM wishlists WHERE id = $1 AND deleted_at IS NULL`
+
+	return scanWishlist(r.db.Pool.QueryRow(ctx, query, id))
+}
+
+func (r *wishlistRepository) GetDefault(ctx context.Context, userId uuid.UUID) (*model.Wishlist, error) {
+	query := `SELECT ` + wishlistColumns + ` FROM wishlists WHERE user_id = $1 AND is_default AND deleted_at IS NULL`
+
+	return scanWishlist(r.db.Pool.QueryRow(ctx, query, userId))
+}
+
+func (r *wishlistRepository) GetByShareToken(ctx context.Context, token string) (*model.Wishlist, error) {
+	query := `SELECT ` + wishlistColumns + ` FROM wishlists WHERE share_token = $1 AND deleted_at IS NULL`
+
+	return scanWishlist(r.db.Pool.QueryRow(ctx, query, token))
+}
+
+// defaultFirst is the sort key putting the user's default wishlist ahead of
+// the named ones.
+const defaultFirst = `w.is_default::int`
+
+// ListByUser pages through the user's wishlists, the default one first and
+// then the named ones newest first.
+func (r *wishlistRepository) ListByUser(ctx context.Context, userId uuid.UUID, page pagination.Page) ([]*model.Wishlist, *pagination.Info, error) {
+	where, order, limit, args := page.KeysetBy("w", defaultFirst, "int", true, 2)
+
+	query := `
+		SELECT ` + wishlistColumns + `, (` + defaultFirst + `)::text
+		FROM wishlists w
+		WHERE user_id = $1 AND deleted_at IS NULL AND ` + where + `
+		ORDER BY ` + order + `
+		LIMIT ` + limit
+
+	rows, err := r.db.Pool.Query(ctx, query, append([]any{userId}, args...)...)
+	if err != nil {
+		return nil, nil, dbErr(err, "wishlist")
+	}
+	defer rows.Close()
+
+	var wishlists []pagination.Sorted[*model.Wishlist]
+	for rows.Next() {
+		var key string
+
+		w, err := scanWishlist(withKey{rows, &key})
+		if err != nil {
+			return nil, nil, dbErr(err, "wishlist")
+		}
+		wishlists = append(wishlists, pagination.Sorted[*model.Wishlist]{Row: w, Key: key})
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, nil, dbErr(err, "wishlist")
+	}
+
+	wishlists, info := pagination.Trim(page, wishlists)
+
+	if page.WithTotal {
+		countQuery := `SELECT COUNT(*) FROM wishlists WHERE user_id = $1 AND deleted_at IS NULL`
+		if info.Total, err = countRows(ctx, r.db.Pool, countQuery, userId); err != nil {
+			return nil, nil, dbErr(err, "wishlist")
+		}
+	}
+
+	return pagination.Rows(wishlists), info, nil
+}
+
+func (r *wishlistRepository) Update(ctx context.Context, wishlist *model.Wishlist) error {
+	query := `
+		UPDATE wishlists
+		SET name = $1, share_token = $2, updated_at = now()
+		WHERE id = $3
+		RETURNING updated_at
+	`
+
+	return dbErr(r.db.Pool.QueryRow(ctx, query,
+		wishlist.Name,
+		wishlist.ShareToken,
+		wishlist.ID,
+	).Scan(&wishlist.UpdatedAt), "wishlist")
+}
+
+func (r *wishlistRepository) Delete(ctx context.Context, id uuid.UUID) error {
+	query := `
+		UPDATE wishlists
+		SET deleted_at = now(), share_token = NULL
+		WHERE id = $1 AND deleted_at IS NULL
+	`
+	_, err := r.db.Pool.Exec(ctx, query, id)
+	return dbErr(err, "wishlist")
+}
+
+func (r *wishlistRepository) AddItem(ctx context.Context, item *model.WishlistItem) error {
+	query := `
+		INSERT INTO wishlist_items (id, wishlist_id, product_id, price_at_add)
+		VALUES ($1, $2, $3, $4)
+		RETURNING created_at, updated_at
+	`
+
+	return dbErr(r.db.Pool.QueryRow(ctx, query,
+		item.ID,
+		item.WishlistId,
+		item.ProductId,
+		item.PriceAtAdd,
+	).Scan(&item.CreatedAt, &item.UpdatedAt), "wishlist")
+}
+
+// RemoveItem deletes a product from a wishlist and reports whether it was there.
+func (r *wishlistRepository) RemoveItem(ctx context.Context, wishlistId, productId uuid.UUID) (bool, error) {
+	query := `DELETE FROM wishlist_items WHERE wishlist_id = $1 AND product_id = $2`
+	tag, err := r.db.Pool.Exec(ctx, query, wishlistId, productId)
+	if err != nil {
+		return false, dbErr(err, "wishlist")
+	}
+
+	return tag.RowsAffected() > 0, nil
+}
+
+func (r *wishlistRepository) GetItems(ctx context.Context, wishlistId uuid.UUID) ([]*model.WishlistItem, error) {
+	query := `
+		SELECT wi.id, wi.wishlist_id, wi.product_id, wi.price_at_add, wi.created_at, wi.updated_at
+		FROM wishlist_items wi
+		JOIN products p ON p.id = wi.product_id
+		WHERE wi.wishlist_id = $1 AND p.deleted_at IS NULL
+		ORDER BY wi.created_at DESC
+	`
+
+	rows, err := r.db.Pool.Query(ctx, query, wishlistId)
+	if err != nil {
+		return nil, dbErr(err, "wishlist")
+	}
+	defer rows.Close()
+
+	var items []*model.WishlistItem
+	for rows.Next() {
+		item := &model.WishlistItem{}
+		if err := rows.Scan(
+			&item.ID,
+			&item.WishlistId,
+			&item.ProductId,
+			&item.PriceAtAdd,
+			&item.CreatedAt,
+			&item.UpdatedAt,
+		); err != nil {
+			return nil, dbErr(err, "wishlist")
+		}
+		items = append(items, item)
+	}
+
+	return items, dbErr(rows.Err(), "wishlist")
+}
+
+func (r *wishlistRepository) ItemExists(ctx context.Context, wishlistId, productId uuid.UUID) (bool, error) {
+	query := `SELECT EXISTS (
+		SELECT 1 FROM wishlist_items WHERE wishlist_id = $1 AND product_id = $2
+	)`
+	var exists bool
+	err := r.db.Pool.QueryRow(ctx, query, wishlistId, productId).Scan(&exists)
+	return exists, dbErr(err, "wishlist")
+}
+
+// ListWatchers returns every user that has the product on at least one of
+// their wishlists and has not opted out of wishlist alerts.
+func (r *wishlistRepository) ListWatchers(ctx context.Context, productId uuid.UUID) ([]*model.WishlistWatcher, error) {
+	query := `
+		SELECT DISTINCT u.id, u.name, u.email, COALESCE(u.phone, '')
+		FROM wishlist_items wi
+		JOIN wishlists w ON w.id = wi.wishlist_id
+		JOIN users u ON u.id = w.user_id
+		WHERE wi.product_id = $1
+			AND w.deleted_at IS NULL
+			AND u.deleted_at IS NULL
+			AND u.wishlist_alerts_opt_out = false
+	`
+
+	rows, err := r.db.Pool.Query(ctx, query, productId)
+	if err != nil {
+		return nil, dbErr(err, "wishlist")
+	}
+	defer rows.Close()
+
+	var watchers []*model.WishlistWatcher
+	for rows.Next() {
+		w := &model.WishlistWatcher{}
+		if err := rows.Scan(&w.UserId, &w.Name, &w.Email, &w.Phone); err != nil {
+			return nil, dbErr(err, "wishlist")
+		}
+		watchers = append(watchers, w)
+	}
+
+	return watchers, dbErr(rows.Err(), "wishlist")
+}
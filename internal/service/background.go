@@ -0,0 +1,102 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"sync"
+)
+
+// Background keeps track of the work the server does outside of requests,
+// so shutting down can wait for it rather than cut it off. Tasks are one-off
+// jobs a request starts without waiting for, such as sending notifications.
+// Workers, such as the cart reminders, loop until they are told to stop.
+type Background struct {
+	mu      sync.Mutex
+	wg      sync.WaitGroup
+	stopped bool
+
+	// workers is the context the workers run with, cancelled to stop them
+	workers context.Context
+	stop    context.CancelFunc
+}
+
+func NewBackground() *Background {
+	workers, stop := context.WithCancel(context.Background())
+	return &Background{workers: workers, stop: stop}
+}
+
+// Go runs task in its own goroutine. what names the task in the log should
+// it fail. The task gets ctx without its cancellation, so it keeps the
+// request ID for the log but finishes after the request that started it. A
+// task started before Shutdown gets to finish. One started by a request that
+// outlived the shutdown timeout still runs, but nothing waits for it.
+func (b *Background) Go(ctx context.Context, what string, task func(ctx context.Context) error) {
+	ctx = context.WithoutCancel(ctx)
+
+	if !b.track() {
+		go runTask(ctx, what, task)
+		return
+	}
+
+	go func() {
+		defer b.wg.Done()
+		runTask(ctx, what, task)
+	}()
+}
+
+func runTask(ctx context.Context, what string, task func(ctx context.Context) error) {
+	if err := task(ctx); err != nil {
+		slog.ErrorContext(ctx, "Failed to "+what, "error", err)
+	}
+}
+
+// track counts another goroutine to wait for, unless Shutdown has already
+// started waiting.
+func (b *Background) track() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.stopped {
+		return false
+	}
+
+	b.wg.Add(1)
+	return true
+}
+
+// Run runs worker in its own goroutine until Shutdown cancels its context.
+func (b *Background) Run(worker func(ctx context.Context)) {
+	if !b.track() {
+		return
+	}
+
+	go func() {
+		defer b.wg.Done()
+		worker(b.workers)
+	}()
+}
+
+// Shutdown stops the workers and waits for them and the tasks to finish, or
+// for ctx to be done. It is called once the server has stopped taking
+// requests.
+func (b *Background) Shutdown(ctx context.Context) error {
+	b.mu.Lock()
+	b.stopped = true
+	b.mu.Unlock()
+
+	b.stop()
+
+	done := make(chan struct{})
+	go func() {
+		b.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return fmt.Errorf("background work still running: %w", ctx.Err())
+	}
+}
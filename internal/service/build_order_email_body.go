@@ -5,12 +5,22 @@ import (
 	"fmt"
 
 	"github.com/Oj-washingtone/savannah-store/internal/model"
-	"github.com/Oj-washingtone/savannah-store/internal/repocitory"
+	"github.com/Oj-washingtone/savannah-store/internal/tax"
 )
 
-func BuildOrderEmailBody(ctx context.Context, order *model.Orders, items []*model.OrderItems) string {
+// BuildOrderEmailBody is the text of the emails about a new order.
+func (s *NotificationService) BuildOrderEmailBody(ctx context.Context, order *model.Orders, items []*model.OrderItems) string {
 	body := "Your order has been created successfully!\n\n"
 	body += "Order ID: " + order.ID.String() + "\n"
+	if order.DiscountTotal > 0 {
+		body += "Subtotal: Ksh." + fmt.Sprintf("%d", order.Subtotal) + "\n"
+		body += "Discounts: -Ksh." + fmt.Sprintf("%d", order.DiscountTotal) + "\n"
+	}
+	if order.PricesIncludeTax {
+		body += "VAT (included): Ksh." + fmt.Sprintf("%d", order.TaxTotal) + "\n"
+	} else {
+		body += "VAT: Ksh." + fmt.Sprintf("%d", order.TaxTotal) + "\n"
+	}
 	body += "Total: Ksh." + fmt.Sprintf("%d", order.Total) + "\n\n"
 
 	paymentStatus := "Not Paid"
@@ -21,21 +31,21 @@ func BuildOrderEmailBody(ctx context.Context, order *model.Orders, items []*mode
 	body += "Payment Status: " + paymentStatus + "\n"
 	body += "Items:\n"
 
-	productRepo := repocitory.NewProductRepository()
-
 	for _, item := range items {
-		product, err := productRepo.GetById(ctx, item.ProductID)
+		product, err := s.products.GetById(ctx, item.ProductID)
 		if err != nil {
 
 			body += fmt.Sprintf("- Product ID: %s, Quantity: %d, Price: Ksh.%d\n", item.ProductID, item.Quantity, item.Price)
 			continue
 		}
 
-		body += fmt.Sprintf("- %s: %s\n  Quantity: %d\n  Unit Price: Ksh.%d\n",
+		body += fmt.Sprintf("- %s: %s\n  Quantity: %d\n  Unit Price: Ksh.%d\n  %s: Ksh.%d\n",
 			product.Name,
 			product.Description,
 			item.Quantity,
 			product.Price,
+			tax.Label(item.TaxClass),
+			item.Tax,
 		)
 	}
 
@@ -0,0 +1,124 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/Oj-washingtone/savannah-store/internal/config"
+	"github.com/Oj-washingtone/savannah-store/internal/model"
+	"github.com/Oj-washingtone/savannah-store/internal/repocitory"
+	"github.com/google/uuid"
+)
+
+// CartReminderService reminds customers of the carts they left behind.
+type CartReminderService struct {
+	reminders repocitory.CartRemindersRepository
+	email     EmailSender
+	sms       SMSSender
+	cfg       config.CartReminders
+
+	// storefrontURL is where the reminders link to
+	storefrontURL string
+}
+
+func NewCartReminderService(reminders repocitory.CartRemindersRepository, email EmailSender, sms SMSSender, cfg config.CartReminders, storefrontURL string) *CartReminderService {
+	return &CartReminderService{reminders: reminders, email: email, sms: sms, cfg: cfg, storefrontURL: storefrontURL}
+}
+
+// Run sends abandoned cart reminders every cfg.Interval until ctx is
+// cancelled. It is meant to be run in its own goroutine.
+func (s *CartReminderService) Run(ctx context.Context) {
+	cfg := s.cfg
+
+	if !cfg.Enabled {
+		return
+	}
+
+	ticker := time.NewTicker(cfg.Interval)
+	defer ticker.Stop()
+
+	for {
+		sent, err := s.SendReminders(ctx)
+		if err != nil {
+			slog.ErrorContext(ctx, "Failed to send cart reminders", "error", err)
+		} else if sent > 0 {
+			slog.InfoContext(ctx, "Sent cart reminders", "count", sent)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// SendReminders reminds the owners of carts idle for longer than
+// cfg.After and records each reminder so it is only sent once per period
+// of inactivity. It returns the number of carts that were reminded.
+func (s *CartReminderService) SendReminders(ctx context.Context) (int, error) {
+	cfg := s.cfg
+
+	carts, err := s.reminders.FindAbandoned(ctx, time.Now().Add(-cfg.After), cfg.BatchSize)
+	if err != nil {
+		return 0, err
+	}
+
+	sent := 0
+	for _, cart := range carts {
+		link := s.storefrontURL + "/cart"
+
+		var channels []string
+
+		if cart.Email != "" {
+			if err := s.email.SendEmail(cart.Email, "You left something in your cart", BuildCartReminderEmailBody(cart, link, s.storefrontURL)); err != nil {
+				slog.ErrorContext(ctx, "Failed to send a cart reminder email", "cart_id", cart.CartId, "error", err)
+			} else {
+				channels = append(channels, "email")
+			}
+		}
+
+		if cart.Phone != "" {
+			message := fmt.Sprintf("You still have %d item(s) waiting in your Savannah Store cart. Complete your order: %s", cart.ItemCount, link)
+			if err := s.sms.SendSMS(cart.Phone, message); err != nil {
+				slog.ErrorContext(ctx, "Failed to send a cart reminder SMS", "cart_id", cart.CartId, "error", err)
+			} else {
+				channels = append(channels, "sms")
+			}
+		}
+
+		// nothing went out, try again on the next run
+		if len(channels) == 0 {
+			continue
+		}
+
+		reminder := &model.CartReminder{
+			CartId:   cart.CartId,
+			UserId:   cart.UserId,
+			Channels: strings.Join(channels, ","),
+			SentAt:   time.Now(),
+		}
+		reminder.ID = uuid.New()
+
+		if err := s.reminders.Create(ctx, reminder); err != nil {
+			return sent, err
+		}
+
+		sent++
+	}
+
+	return sent, nil
+}
+
+func BuildCartReminderEmailBody(cart *model.AbandonedCart, link, storefrontURL string) string {
+	body := fmt.Sprintf("Hi %s,\n\n", cart.Name)
+	body += fmt.Sprintf("You left %d item(s) in your cart worth Ksh.%d.\n", cart.ItemCount, cart.Subtotal)
+	body += "They are still waiting for you:\n\n"
+	body += link + "\n\n"
+	body += "Don't want these reminders? Turn them off in your account preferences: " + storefrontURL + "/account/preferences\n"
+
+	return body
+}
@@ -0,0 +1,102 @@
+package service
+
+import (
+	"context"
+
+	"github.com/Oj-washingtone/savannah-store/internal/model"
+	"github.com/Oj-washingtone/savannah-store/internal/repocitory"
+	"github.com/google/uuid"
+)
+
+// CategoryService works on the category tree as a whole.
+type CategoryService struct {
+	categories repocitory.CategoryRepository
+}
+
+func NewCategoryService(categories repocitory.CategoryRepository) *CategoryService {
+	return &CategoryService{categories: categories}
+}
+
+// Tree builds the category tree with breadcrumbs and product counts.
+// It returns the top level categories, and every category in the tree by id.
+// Categories whose parent is gone count as top level.
+func (s *CategoryService) Tree(ctx context.Context) ([]*model.CategoryNode, map[uuid.UUID]*model.CategoryNode, error) {
+	categories, err := s.categories.ListAll(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	counts, err := s.categories.ProductCounts(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	nodes := map[uuid.UUID]*model.CategoryNode{}
+	for _, category := range categories {
+		nodes[category.ID] = &model.CategoryNode{
+			ProductCategory: category,
+			ProductCount:    counts[category.ID],
+			Children:        []*model.CategoryNode{},
+		}
+	}
+
+	// categories come sorted by name, so children end up sorted too
+	roots := []*model.CategoryNode{}
+	for _, category := range categories {
+		node := nodes[category.ID]
+
+		if category.ParentId != nil {
+			if parent, ok := nodes[*category.ParentId]; ok {
+				parent.Children = append(parent.Children, node)
+				continue
+			}
+		}
+
+		roots = append(roots, node)
+	}
+
+	// only categories reachable from the top are kept, which leaves out any
+	// cycle in old data
+	tree := map[uuid.UUID]*model.CategoryNode{}
+
+	var walk func(node *model.CategoryNode, crumbs []*model.Breadcrumb) int
+	walk = func(node *model.CategoryNode, crumbs []*model.Breadcrumb) int {
+		tree[node.ID] = node
+
+		node.Breadcrumbs = append(append([]*model.Breadcrumb{}, crumbs...), &model.Breadcrumb{ID: node.ID, Name: node.Name})
+		node.TotalProductCount = node.ProductCount
+
+		for _, child := range node.Children {
+			node.TotalProductCount += walk(child, node.Breadcrumbs)
+		}
+
+		return node.TotalProductCount
+	}
+
+	for _, root := range roots {
+		walk(root, nil)
+	}
+
+	return roots, tree, nil
+}
+
+// WouldCreateCycle reports whether moving the category under parentId would
+// put it below itself.
+func (s *CategoryService) WouldCreateCycle(ctx context.Context, categoryId, parentId uuid.UUID) (bool, error) {
+	if categoryId == parentId {
+		return true, nil
+	}
+
+	ancestors, err := s.categories.Ancestors(ctx, parentId)
+	if err != nil {
+		return false, err
+	}
+
+	for _, ancestor := range ancestors {
+		if ancestor.ID == categoryId {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
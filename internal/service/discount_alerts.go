@@ -0,0 +1,113 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"time"
+
+	"github.com/Oj-washingtone/savannah-store/internal/model"
+	"github.com/Oj-washingtone/savannah-store/internal/repocitory"
+	"github.com/jackc/pgx/v5"
+)
+
+const (
+	// discountAlertInterval is how often started discounts are looked for,
+	// and so about how late their alerts can go out
+	discountAlertInterval = time.Minute
+
+	discountAlertBatchSize = 100
+)
+
+// DiscountAlertService tells wishlist watchers of the price drop when a
+// scheduled discount starts. Discounts that are already running when they
+// are saved are alerted on right away by the discount handlers.
+type DiscountAlertService struct {
+	discounts     repocitory.DiscountRepository
+	products      *ProductService
+	notifications *NotificationService
+}
+
+func NewDiscountAlertService(discounts repocitory.DiscountRepository, products *ProductService, notifications *NotificationService) *DiscountAlertService {
+	return &DiscountAlertService{discounts: discounts, products: products, notifications: notifications}
+}
+
+// Run sends the alerts of started discounts every discountAlertInterval until
+// ctx is cancelled. It is meant to be run in its own goroutine.
+func (s *DiscountAlertService) Run(ctx context.Context) {
+	ticker := time.NewTicker(discountAlertInterval)
+	defer ticker.Stop()
+
+	for {
+		started, err := s.SendStartAlerts(ctx)
+		if err != nil {
+			slog.ErrorContext(ctx, "Failed to look for started discounts", "error", err)
+		} else if started > 0 {
+			slog.InfoContext(ctx, "Sent wishlist alerts for started discounts", "count", started)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// SendStartAlerts alerts the watchers of the products whose scheduled
+// discounts started since the last run. It returns the number of discounts
+// that started.
+func (s *DiscountAlertService) SendStartAlerts(ctx context.Context) (int, error) {
+	discounts, err := s.discounts.ClaimStarted(ctx, discountAlertBatchSize)
+	if err != nil {
+		return 0, err
+	}
+
+	for _, discount := range discounts {
+		if err := s.alertStart(ctx, discount); err != nil {
+			slog.ErrorContext(ctx, "Failed to send wishlist alerts for a started discount", "discount_id", discount.ID, "error", err)
+		}
+	}
+
+	return len(discounts), nil
+}
+
+// alertStart compares the product's price now with the price it had just
+// before the discount started, under the discount that applied then.
+func (s *DiscountAlertService) alertStart(ctx context.Context, started *model.Discount) error {
+	after, err := s.products.WatchedProduct(ctx, started.ProductID)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	// it ended already or another discount wins, so it changed no price
+	if after.Discount == nil || after.Discount.ID != started.ID {
+		return nil
+	}
+
+	discounts, err := s.discounts.ListByProduct(ctx, started.ProductID)
+	if err != nil {
+		return err
+	}
+
+	justBefore := started.StartsAt.Add(-time.Nanosecond)
+
+	var previous *model.Discount
+	for _, d := range discounts {
+		if d.ID != started.ID && d.RunningAt(justBefore) {
+			previous = d
+			break
+		}
+	}
+
+	before := *after
+	before.ApplyDiscount(previous)
+	if err := s.products.lowestVariantPrice(ctx, &before); err != nil {
+		return err
+	}
+
+	return s.notifications.NotifyWishlistWatchers(ctx, &before, after)
+}
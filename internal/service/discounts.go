@@ -0,0 +1,43 @@
+package service
+
+import (
+	"context"
+
+	"github.com/Oj-washingtone/savannah-store/internal/model"
+	"github.com/Oj-washingtone/savannah-store/internal/repocitory"
+)
+
+// DiscountService puts products on sale.
+type DiscountService struct {
+	discounts repocitory.DiscountRepository
+	products  *ProductService
+}
+
+func NewDiscountService(discounts repocitory.DiscountRepository, products *ProductService) *DiscountService {
+	return &DiscountService{discounts: discounts, products: products}
+}
+
+// Save creates the discount, or updates it when create is false. The
+// repository refuses one that overlaps another discount of the product with
+// repocitory.ErrDiscountOverlap. Wishlist watchers hear if the product just
+// got cheaper.
+func (s *DiscountService) Save(ctx context.Context, discount *model.Discount, create bool) error {
+	before, err := s.products.WatchedProduct(ctx, discount.ProductID)
+	if err != nil {
+		return err
+	}
+
+	if create {
+		err = s.discounts.Create(ctx, discount)
+	} else {
+		err = s.discounts.Update(ctx, discount)
+	}
+
+	if err != nil {
+		return err
+	}
+
+	s.products.alertWatchers(ctx, before)
+
+	return nil
+}
@@ -0,0 +1,97 @@
+package service
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/Oj-washingtone/savannah-store/internal/database"
+	"github.com/Oj-washingtone/savannah-store/internal/repocitory"
+)
+
+// readyTimeout bounds each readiness check, so a stuck database makes the
+// probe fail rather than hang.
+const readyTimeout = 2 * time.Second
+
+// Readiness is whether the server can take traffic and why.
+type Readiness struct {
+	Ready bool `json:"ready"`
+
+	// Database is "ok" or "unavailable". Why it could not be reached is
+	// logged rather than told to whoever calls the probe.
+	Database string `json:"database"`
+
+	Migration MigrationReadiness `json:"migration"`
+
+	// OutboxLagSeconds is how long the oldest notification still to be sent
+	// has been waiting, 0 with none. It is reported for alerting but does not
+	// affect Ready, as taking the server out of service would not get an
+	// email provider back up.
+	OutboxLagSeconds float64 `json:"outboxLagSeconds"`
+}
+
+// MigrationReadiness compares the database's migration with the newest one
+// this build has. The server is not ready on a dirty database or one behind
+// Latest. One ahead is fine, which is the case while a new version rolls out.
+type MigrationReadiness struct {
+	Version uint `json:"version"`
+	Latest  uint `json:"latest"`
+	Dirty   bool `json:"dirty"`
+
+	// State is "ok", "dirty", "behind", or "unavailable" when the version
+	// could not be read
+	State string `json:"state"`
+}
+
+// HealthService answers the orchestrator's readiness probe.
+type HealthService struct {
+	health repocitory.HealthRepository
+	outbox repocitory.NotificationOutboxRepository
+}
+
+func NewHealthService(repos *repocitory.Repositories) *HealthService {
+	return &HealthService{health: repos.Health, outbox: repos.Outbox}
+}
+
+// Ready checks the database can be reached and is migrated, and measures the
+// outbox lag.
+func (s *HealthService) Ready(ctx context.Context) *Readiness {
+	ctx, cancel := context.WithTimeout(ctx, readyTimeout)
+	defer cancel()
+
+	r := &Readiness{Ready: true, Database: "ok", Migration: MigrationReadiness{State: "ok"}}
+
+	if err := s.health.Ping(ctx); err != nil {
+		slog.ErrorContext(ctx, "The database is unreachable", "error", err)
+		r.Ready = false
+		r.Database = "unavailable"
+		r.Migration.State = "unavailable"
+		return r
+	}
+
+	latest, err := database.LatestMigration()
+	if err == nil {
+		r.Migration.Latest = latest
+		r.Migration.Version, r.Migration.Dirty, err = s.health.SchemaVersion(ctx)
+	}
+
+	switch {
+	case err != nil:
+		slog.ErrorContext(ctx, "Failed to read the migration version", "error", err)
+		r.Ready = false
+		r.Migration.State = "unavailable"
+	case r.Migration.Dirty:
+		r.Ready = false
+		r.Migration.State = "dirty"
+	case r.Migration.Version < r.Migration.Latest:
+		r.Ready = false
+		r.Migration.State = "behind"
+	}
+
+	oldest, err := s.outbox.OldestUnsent(ctx, MaxSendAttempts)
+	if err == nil && oldest != nil {
+		r.OutboxLagSeconds = time.Since(*oldest).Seconds()
+	}
+
+	return r
+}
@@ -0,0 +1,72 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/Oj-washingtone/savannah-store/internal/database"
+	"github.com/Oj-washingtone/savannah-store/internal/repocitory/memory"
+)
+
+// stubHealth is a database in whatever state a test needs.
+type stubHealth struct {
+	pingErr, versionErr error
+	version             uint
+	dirty               bool
+}
+
+func (h stubHealth) Ping(context.Context) error {
+	return h.pingErr
+}
+
+func (h stubHealth) SchemaVersion(context.Context) (uint, bool, error) {
+	return h.version, h.dirty, h.versionErr
+}
+
+func TestReady(t *testing.T) {
+	latest, err := database.LatestMigration()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	secret := errors.New(`dial tcp 10.0.4.7:5432: password authentication failed for user "savannah"`)
+
+	for _, tc := range []struct {
+		name     string
+		health   stubHealth
+		ready    bool
+		database string
+		state    string
+	}{
+		{"migrated", stubHealth{version: latest}, true, "ok", "ok"},
+		{"ahead", stubHealth{version: latest + 1}, true, "ok", "ok"},
+		{"behind", stubHealth{version: latest - 1}, false, "ok", "behind"},
+		{"dirty", stubHealth{version: latest, dirty: true}, false, "ok", "dirty"},
+		{"unreachable", stubHealth{pingErr: secret}, false, "unavailable", "unavailable"},
+		{"version unreadable", stubHealth{versionErr: secret}, false, "ok", "unavailable"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			repos := memory.NewRepositories()
+			repos.Health = tc.health
+
+			r := NewHealthService(repos).Ready(context.Background())
+
+			if r.Ready != tc.ready || r.Database != tc.database || r.Migration.State != tc.state {
+				t.Errorf("got ready %v, database %q and state %q, want %v, %q and %q",
+					r.Ready, r.Database, r.Migration.State, tc.ready, tc.database, tc.state)
+			}
+
+			body, err := json.Marshal(r)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if strings.Contains(string(body), "10.0.4.7") {
+				t.Errorf("the probe tells who calls it why the database failed: %s", body)
+			}
+		})
+	}
+}
@@ -0,0 +1,133 @@
+package service
+
+import (
+	"context"
+
+	"github.com/Oj-washingtone/savannah-store/internal/config"
+	"github.com/Oj-washingtone/savannah-store/internal/invoice"
+	"github.com/Oj-washingtone/savannah-store/internal/model"
+	"github.com/Oj-washingtone/savannah-store/internal/repocitory"
+	"github.com/Oj-washingtone/savannah-store/internal/tax"
+)
+
+// InvoiceService puts together the invoices of orders.
+type InvoiceService struct {
+	orderItems repocitory.OrderItemsRepository
+	users      repocitory.UserRepository
+	business   invoice.Business
+}
+
+// NewInvoiceService builds invoices with business as the seller.
+func NewInvoiceService(repos *repocitory.Repositories, business config.Business) *InvoiceService {
+	return &InvoiceService{
+		orderItems: repos.OrderItems,
+		users:      repos.Users,
+		business: invoice.Business{
+			Name:    business.Name,
+			Address: business.Address,
+			Phone:   business.Phone,
+			Email:   business.Email,
+			KRAPin:  business.KRAPin,
+		},
+	}
+}
+
+// Build collects what goes on the invoice of an order. Paid orders get
+// a tax invoice with their invoice number, unpaid ones a pro forma invoice.
+func (s *InvoiceService) Build(ctx context.Context, order *model.Orders) (*invoice.Document, error) {
+	items, err := s.orderItems.GetByOrder(ctx, order.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	doc := &invoice.Document{
+		Business:         s.business,
+		Number:           order.InvoiceNo(),
+		OrderID:          order.ID.String(),
+		IssuedAt:         order.CreatedAt,
+		Subtotal:         order.Subtotal,
+		DiscountTotal:    order.DiscountTotal,
+		TaxTotal:         order.TaxTotal,
+		Total:            order.Total,
+		PricesIncludeTax: order.PricesIncludeTax,
+		Paid:             order.Paid,
+		PaidAt:           order.PaidAt,
+	}
+
+	if order.PaidAt != nil {
+		doc.IssuedAt = *order.PaidAt
+	}
+
+	if order.PaymentReference != nil {
+		doc.PaymentReference = *order.PaymentReference
+	}
+
+	if user, err := s.users.GetById(ctx, order.UserID); err == nil {
+		doc.CustomerName = user.Name
+		doc.CustomerEmail = user.Email
+	}
+
+	bands := map[model.TaxClass]*invoice.TaxBand{}
+
+	for _, item := range items {
+		// the names the item had when it was ordered
+		description := item.ProductName
+		if description == "" {
+			description = "Product " + item.ProductID.String()
+		}
+
+		if item.VariantName != "" {
+			description += " (" + item.VariantName + ")"
+		}
+
+		amount := item.Price*int64(item.Quantity) - item.Discount
+
+		net, gross := amount, amount+item.Tax
+		if order.PricesIncludeTax {
+			net, gross = amount-item.Tax, amount
+		}
+
+		doc.Lines = append(doc.Lines, invoice.Line{
+			Description: description,
+			Quantity:    item.Quantity,
+			UnitPrice:   item.Price,
+			Discount:    item.Discount,
+			TaxLabel:    tax.Label(item.TaxClass),
+			Tax:         item.Tax,
+			Total:       gross,
+		})
+
+		band, ok := bands[item.TaxClass]
+		if !ok {
+			band = &invoice.TaxBand{Label: tax.Label(item.TaxClass)}
+			bands[item.TaxClass] = band
+		}
+
+		band.Taxable += net
+		band.Tax += item.Tax
+	}
+
+	for _, class := range []model.TaxClass{model.TaxStandard, model.TaxZeroRated, model.TaxExempt} {
+		if band, ok := bands[class]; ok {
+			doc.TaxBands = append(doc.TaxBands, *band)
+		}
+	}
+
+	return doc, nil
+}
+
+// Render builds and renders the invoice of an order, returning the
+// PDF and the file name to give it.
+func (s *InvoiceService) Render(ctx context.Context, order *model.Orders) ([]byte, string, error) {
+	doc, err := s.Build(ctx, order)
+	if err != nil {
+		return nil, "", err
+	}
+
+	pdf, err := invoice.Render(doc)
+	if err != nil {
+		return nil, "", err
+	}
+
+	return pdf, doc.Filename(), nil
+}
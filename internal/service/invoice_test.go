@@ -0,0 +1,97 @@
+package service
+
+import (
+	"context"
+	"testing"
+
+	"github.com/Oj-washingtone/savannah-store/internal/config"
+	"github.com/Oj-washingtone/savannah-store/internal/model"
+	"github.com/Oj-washingtone/savannah-store/internal/repocitory/memory"
+	"github.com/google/uuid"
+)
+
+func TestInvoiceKeepsOrderedNames(t *testing.T) {
+	ctx := context.Background()
+	repos := memory.NewRepositories()
+
+	user := &model.User{BaseModel: model.BaseModel{ID: uuid.New()}, Name: "Wanjiku", Email: "wanjiku@example.com", Role: model.CustomerRole, Auth0Id: "auth0|wanjiku"}
+	category := &model.ProductCategory{BaseModel: model.BaseModel{ID: uuid.New()}, Name: "Textiles", Slug: "textiles"}
+	product := &model.Product{
+		BaseModel:  model.BaseModel{ID: uuid.New()},
+		CategoryID: category.ID,
+		Name:       "Kanga",
+		Slug:       "kanga",
+		Price:      900,
+		TaxClass:   model.TaxStandard,
+	}
+
+	for _, err := range []error{
+		repos.Users.Create(ctx, user),
+		repos.Categories.Create(ctx, category),
+		repos.Products.Create(ctx, product),
+	} {
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	option := &model.ProductOption{BaseModel: model.BaseModel{ID: uuid.New()}, ProductID: product.ID, Name: "Colour"}
+	blue := &model.ProductOptionValue{ID: uuid.New(), OptionID: option.ID, Value: "Blue"}
+	option.Values = []*model.ProductOptionValue{blue}
+	if err := repos.Variants.CreateOption(ctx, option); err != nil {
+		t.Fatal(err)
+	}
+
+	variant := &model.ProductVariant{BaseModel: model.BaseModel{ID: uuid.New()}, ProductID: product.ID, Stock: 3}
+	if err := repos.Variants.Create(ctx, variant, []uuid.UUID{blue.ID}); err != nil {
+		t.Fatal(err)
+	}
+
+	order, err := repos.Orders.Create(ctx, &model.Orders{BaseModel: model.BaseModel{ID: uuid.New()}, UserID: user.ID, Subtotal: 900, Total: 1044})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	item := &model.OrderItems{
+		BaseModel: model.BaseModel{ID: uuid.New()},
+		OrderID:   order.ID,
+		ProductID: product.ID,
+		VariantID: variant.ID,
+		Quantity:  1,
+		Price:     900,
+		TaxClass:  model.TaxStandard,
+		TaxRate:   1600,
+		Tax:       144,
+	}
+
+	orders := NewOrderService(repos, nil, nil, nil)
+	if err := orders.nameItems(ctx, []*model.OrderItems{item}); err != nil {
+		t.Fatal(err)
+	}
+
+	if item.ProductName != "Kanga" || item.VariantName != "Blue" {
+		t.Fatalf("the item was named %q and %q", item.ProductName, item.VariantName)
+	}
+
+	if err := repos.OrderItems.Create(ctx, item); err != nil {
+		t.Fatal(err)
+	}
+
+	// the shop renames the product and then takes it off sale
+	product.Name = "Kanga Print"
+	if err := repos.Products.Update(ctx, product); err != nil {
+		t.Fatal(err)
+	}
+	if err := repos.Products.Delete(ctx, product.ID); err != nil {
+		t.Fatal(err)
+	}
+
+	doc, err := NewInvoiceService(repos, config.Business{}).Build(ctx, order)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(doc.Lines) != 1 || doc.Lines[0].Description != "Kanga (Blue)" {
+		t.Errorf("the invoice lines are %+v, want Kanga (Blue)", doc.Lines)
+	}
+}
@@ -0,0 +1,115 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+
+	"github.com/Oj-washingtone/savannah-store/internal/model"
+	"github.com/google/uuid"
+)
+
+// MaxSendAttempts is how many times RetryFailed tries a notification before
+// leaving it in the outbox for someone to look into.
+const MaxSendAttempts = 5
+
+// sendEmail sends an email, keeping it in the outbox if that fails. what
+// names the email in the log.
+func (s *NotificationService) sendEmail(ctx context.Context, what, to, subject, body string, attachments ...EmailAttachment) {
+	err := s.email.SendEmail(to, subject, body, attachments...)
+	if err == nil {
+		return
+	}
+
+	slog.ErrorContext(ctx, "Failed to send "+what, "error", err)
+
+	notification := &model.OutboxNotification{
+		Channel:   model.NotificationEmail,
+		Recipient: to,
+		Subject:   subject,
+		Body:      body,
+	}
+
+	for _, a := range attachments {
+		notification.Attachments = append(notification.Attachments, model.NotificationAttachment{
+			Filename:    a.Filename,
+			ContentType: a.ContentType,
+			Content:     a.Content,
+		})
+	}
+
+	s.keep(ctx, notification, err)
+}
+
+// sendSMS sends a text message, keeping it in the outbox if that fails.
+func (s *NotificationService) sendSMS(ctx context.Context, what, to, message string) {
+	err := s.sms.SendSMS(to, message)
+	if err == nil {
+		return
+	}
+
+	slog.ErrorContext(ctx, "Failed to send "+what, "error", err)
+
+	s.keep(ctx, &model.OutboxNotification{
+		Channel:   model.NotificationSMS,
+		Recipient: to,
+		Body:      message,
+	}, err)
+}
+
+// keep puts a notification that failed to send in the outbox. Nothing is
+// kept while email or SMS is not configured, as retrying could not help.
+func (s *NotificationService) keep(ctx context.Context, notification *model.OutboxNotification, sendErr error) {
+	if errors.Is(sendErr, ErrEmailNotConfigured) || errors.Is(sendErr, ErrSMSNotConfigured) {
+		return
+	}
+
+	notification.ID = uuid.New()
+	notification.LastError = sendErr.Error()
+
+	if err := s.outbox.Add(ctx, notification); err != nil {
+		slog.ErrorContext(ctx, "Failed to keep the notification for later", "error", err)
+	}
+}
+
+// RetryFailed sends the oldest limit notifications in the outbox again. It
+// returns how many went out and how many failed once more.
+func (s *NotificationService) RetryFailed(ctx context.Context, limit int) (sent, failed int, err error) {
+	notifications, err := s.outbox.ListUnsent(ctx, MaxSendAttempts, limit)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	for _, n := range notifications {
+		var sendErr error
+
+		switch n.Channel {
+		case model.NotificationEmail:
+			attachments := make([]EmailAttachment, len(n.Attachments))
+			for i, a := range n.Attachments {
+				attachments[i] = EmailAttachment{Filename: a.Filename, ContentType: a.ContentType, Content: a.Content}
+			}
+			sendErr = s.email.SendEmail(n.Recipient, n.Subject, n.Body, attachments...)
+		case model.NotificationSMS:
+			sendErr = s.sms.SendSMS(n.Recipient, n.Body)
+		default:
+			sendErr = fmt.Errorf("unknown channel %q", n.Channel)
+		}
+
+		if sendErr != nil {
+			failed++
+			if err := s.outbox.RecordFailure(ctx, n.ID, sendErr.Error()); err != nil {
+				return sent, failed, err
+			}
+			continue
+		}
+
+		sent++
+		if err := s.outbox.MarkSent(ctx, n.ID); err != nil {
+			return sent, failed, err
+		}
+	}
+
+	return sent, failed, nil
+}
@@ -0,0 +1,93 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+
+	"github.com/Oj-washingtone/savannah-store/internal/config"
+	"github.com/Oj-washingtone/savannah-store/internal/model"
+	"github.com/Oj-washingtone/savannah-store/internal/repocitory"
+)
+
+// NotificationService tells customers and the shop about orders, payments
+// and wishlist changes by email and SMS. Failures to send are logged rather
+// than returned, since the thing being announced has already happened, and
+// the message is kept in the outbox for RetryFailed to send later.
+type NotificationService struct {
+	email     EmailSender
+	sms       SMSSender
+	users     repocitory.UserRepository
+	products  repocitory.ProductRepository
+	wishlists repocitory.WishlistRepository
+	outbox    repocitory.NotificationOutboxRepository
+	invoices  *InvoiceService
+	shop      config.Shop
+}
+
+// NewNotificationService builds the notification service. shop says where
+// links point and who gets the shop's copy of new orders.
+func NewNotificationService(repos *repocitory.Repositories, email EmailSender, sms SMSSender, invoices *InvoiceService, shop config.Shop) *NotificationService {
+	return &NotificationService{
+		email:     email,
+		sms:       sms,
+		users:     repos.Users,
+		products:  repos.Products,
+		wishlists: repos.Wishlists,
+		outbox:    repos.Outbox,
+		invoices:  invoices,
+		shop:      shop,
+	}
+}
+
+// OrderPlaced texts the customer and emails them and the admin the order
+// with its pro forma invoice.
+func (s *NotificationService) OrderPlaced(ctx context.Context, user *model.User, order *model.Orders, items []*model.OrderItems) {
+	if user.Phone != "" {
+		s.sendSMS(ctx, "SMS", user.Phone, "Your order has been received and is being processed")
+	}
+
+	emailBody := s.BuildOrderEmailBody(ctx, order, items)
+
+	var attachments []EmailAttachment
+
+	pdf, filename, err := s.invoices.Render(ctx, order)
+	if err != nil {
+		slog.ErrorContext(ctx, "Failed to render the invoice", "order_id", order.ID, "error", err)
+	} else {
+		attachments = append(attachments, EmailAttachment{Filename: filename, Content: pdf, ContentType: "application/pdf"})
+	}
+
+	if user.Email != "" {
+		s.sendEmail(ctx, "order confirmation", user.Email, "Your order has been received", emailBody, attachments...)
+	}
+
+	if adminEmail := s.shop.AdminEmail; adminEmail != "" {
+		s.sendEmail(ctx, "new order email", adminEmail, "New Order Created", emailBody, attachments...)
+	}
+}
+
+// OrderPaid emails the customer the tax invoice of a paid order.
+func (s *NotificationService) OrderPaid(ctx context.Context, order *model.Orders) {
+	user, err := s.users.GetById(ctx, order.UserID)
+	if err != nil {
+		slog.ErrorContext(ctx, "Failed to load the customer for the receipt", "order_id", order.ID, "error", err)
+		return
+	}
+
+	pdf, filename, err := s.invoices.Render(ctx, order)
+	if err != nil {
+		slog.ErrorContext(ctx, "Failed to render the invoice", "order_id", order.ID, "error", err)
+		return
+	}
+
+	body := "Thank you, we have received your payment.\n\n"
+	body += "Invoice No.: " + order.InvoiceNo() + "\n"
+	body += "Order ID: " + order.ID.String() + "\n"
+	body += "Amount paid: Ksh." + fmt.Sprintf("%d", order.Total) + "\n\n"
+	body += "Your tax invoice is attached."
+
+	attachment := EmailAttachment{Filename: filename, Content: pdf, ContentType: "application/pdf"}
+
+	s.sendEmail(ctx, "receipt", user.Email, "Receipt for your order "+order.InvoiceNo(), body, attachment)
+}
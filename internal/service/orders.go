@@ -0,0 +1,192 @@
+package service
+
+import (
+	"context"
+	"errors"
+
+	"github.com/Oj-washingtone/savannah-store/internal/apperr"
+	"github.com/Oj-washingtone/savannah-store/internal/model"
+	"github.com/Oj-washingtone/savannah-store/internal/repocitory"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+)
+
+var (
+	// ErrEmptyCart is returned when checking out without anything in the
+	// cart.
+	ErrEmptyCart = apperr.NotFound("Your cart is empty")
+
+	// ErrPromotionUnavailable is returned when a promotion on the cart ran
+	// out between pricing the cart and placing the order.
+	ErrPromotionUnavailable = apperr.Conflict("A promotion on your cart has reached its usage limit, please review your cart and try again")
+)
+
+// OrderService turns carts into orders and records their payment.
+type OrderService struct {
+	carts         repocitory.ShoppingCartRepository
+	cartItems     repocitory.CartItemsRepository
+	orders        repocitory.OrdersRepository
+	products      repocitory.ProductRepository
+	variants      repocitory.ProductVariantRepository
+	pricing       *PricingService
+	notifications *NotificationService
+	background    *Background
+}
+
+func NewOrderService(repos *repocitory.Repositories, pricing *PricingService, notifications *NotificationService, background *Background) *OrderService {
+	return &OrderService{
+		carts:         repos.Carts,
+		cartItems:     repos.CartItems,
+		orders:        repos.Orders,
+		products:      repos.Products,
+		variants:      repos.Variants,
+		pricing:       pricing,
+		notifications: notifications,
+		background:    background,
+	}
+}
+
+// PlaceOrder orders what is in the user's cart at the cart's current prices,
+// records the promotions used, empties the cart and lets the user know in the
+// background.
+func (s *OrderService) PlaceOrder(ctx context.Context, user *model.User) (*model.Orders, error) {
+	cart, err := s.carts.GetShoppingCart(ctx, user.ID)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrEmptyCart
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	items, err := s.cartItems.GetItems(ctx, cart.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(items) == 0 {
+		return nil, ErrEmptyCart
+	}
+
+	summary, err := s.pricing.PriceCart(ctx, cart.ID, user.ID, items)
+	if err != nil {
+		return nil, err
+	}
+
+	order := &model.Orders{
+		UserID:        user.ID,
+		Subtotal:      summary.Subtotal,
+		DiscountTotal: summary.DiscountTotal,
+		TaxTotal:      summary.TaxTotal,
+		Total:         summary.Total,
+
+		PricesIncludeTax: summary.PricesIncludeTax,
+	}
+
+	order.ID = uuid.New()
+
+	checkout := &repocitory.Checkout{Order: order, CartID: cart.ID}
+
+	for _, discount := range summary.Discounts {
+		redemption := &model.PromotionRedemption{
+			PromotionID: discount.PromotionID,
+			OrderID:     order.ID,
+			UserID:      user.ID,
+			Code:        discount.Code,
+			Amount:      discount.Amount,
+		}
+
+		redemption.ID = uuid.New()
+		checkout.Redemptions = append(checkout.Redemptions, redemption)
+	}
+
+	for _, line := range summary.Lines {
+		orderItem := &model.OrderItems{
+			OrderID:   order.ID,
+			ProductID: line.ProductId,
+			VariantID: line.VariantId,
+			Quantity:  line.Quantity,
+			Price:     line.Price,
+			Discount:  line.Discount,
+			TaxClass:  line.TaxClass,
+			TaxRate:   line.TaxRate,
+			Tax:       line.Tax,
+		}
+
+		orderItem.ID = uuid.New()
+		checkout.Items = append(checkout.Items, orderItem)
+	}
+
+	if err := s.nameItems(ctx, checkout.Items); err != nil {
+		return nil, err
+	}
+
+	// the order, its items, the promotion uses and emptying the cart go
+	// through together or not at all, backing out if a usage limit was
+	// reached since the cart was priced
+	theOrder, err := s.orders.Place(ctx, checkout)
+	if errors.Is(err, repocitory.ErrPromotionExhausted) {
+		return nil, ErrPromotionUnavailable
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	s.background.Go(ctx, "send the order confirmation", func(ctx context.Context) error {
+		s.notifications.OrderPlaced(ctx, user, theOrder, checkout.Items)
+		return nil
+	})
+
+	return theOrder, nil
+}
+
+// nameItems records what each item is called now on the item, so its invoice
+// reads the same after the product is renamed or deleted. A product that is
+// already gone leaves its items unnamed, as pricing lets them through too.
+func (s *OrderService) nameItems(ctx context.Context, items []*model.OrderItems) error {
+	options := map[uuid.UUID][]*model.ProductOption{}
+
+	for _, item := range items {
+		product, err := s.products.GetById(ctx, item.ProductID)
+		if errors.Is(err, pgx.ErrNoRows) {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		item.ProductName = product.Name
+
+		variant, err := s.variants.GetByIdIncludingDeleted(ctx, item.VariantID)
+		if err != nil {
+			return err
+		}
+
+		productOptions, ok := options[item.ProductID]
+		if !ok {
+			productOptions, err = s.variants.ListOptions(ctx, item.ProductID)
+			if err != nil {
+				return err
+			}
+			options[item.ProductID] = productOptions
+		}
+
+		item.VariantName = variant.Label(productOptions)
+	}
+
+	return nil
+}
+
+// MarkPaid records the payment of an order and sends the customer their tax
+// invoice in the background.
+func (s *OrderService) MarkPaid(ctx context.Context, orderId uuid.UUID, paymentReference string) (*model.Orders, error) {
+	order, err := s.orders.MarkPaid(ctx, orderId, paymentReference)
+	if err != nil {
+		return nil, err
+	}
+
+	s.background.Go(ctx, "send the receipt", func(ctx context.Context) error {
+		s.notifications.OrderPaid(ctx, order)
+		return nil
+	})
+
+	return order, nil
+}
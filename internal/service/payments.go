@@ -0,0 +1,80 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/Oj-washingtone/savannah-store/internal/model"
+	"github.com/Oj-washingtone/savannah-store/internal/repocitory"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+)
+
+// Payment is a payment for an order as the payment provider reports it.
+type Payment struct {
+	Reference string
+	OrderID   uuid.UUID
+	Amount    int64
+}
+
+// PaymentOutcome is what reconciling a payment did.
+type PaymentOutcome string
+
+const (
+	// PaymentRecorded means the order was marked paid.
+	PaymentRecorded PaymentOutcome = "recorded"
+
+	// PaymentAlreadyRecorded means the order was already marked paid with
+	// the same reference, so there was nothing to do.
+	PaymentAlreadyRecorded PaymentOutcome = "already recorded"
+
+	// The rest need someone to look at the payment, the order is left as
+	// it is.
+	PaymentUnknownOrder   PaymentOutcome = "unknown order"
+	PaymentOrderCancelled PaymentOutcome = "order cancelled"
+	PaymentDuplicate      PaymentOutcome = "order paid with another reference"
+	PaymentWrongAmount    PaymentOutcome = "amount does not match"
+)
+
+// ReconcilePayment marks the order of a payment the provider received as
+// paid, when the store missed the payment's callback. Only a payment of the
+// order's exact total is recorded. The receipt is sent before it returns,
+// rather than in the background as MarkPaid does, so the caller can exit
+// straight after.
+func (s *OrderService) ReconcilePayment(ctx context.Context, payment Payment) (PaymentOutcome, error) {
+	order, err := s.orders.GetById(ctx, payment.OrderID)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return PaymentUnknownOrder, nil
+	}
+	if err != nil {
+		return "", err
+	}
+
+	switch {
+	case order.Paid && order.PaymentReference != nil && *order.PaymentReference == payment.Reference:
+		return PaymentAlreadyRecorded, nil
+	case order.Paid:
+		return PaymentDuplicate, nil
+	case order.Status == model.StatusCancelled:
+		return PaymentOrderCancelled, nil
+	case payment.Amount != order.Total:
+		return PaymentWrongAmount, nil
+	}
+
+	order, err = s.orders.MarkPaid(ctx, order.ID, payment.Reference)
+
+	// the callback may have come in since the order was loaded
+	switch {
+	case errors.Is(err, repocitory.ErrOrderAlreadyPaid):
+		return s.ReconcilePayment(ctx, payment)
+	case errors.Is(err, repocitory.ErrOrderCancelled):
+		return PaymentOrderCancelled, nil
+	case err != nil:
+		return "", fmt.Errorf("marking order %s paid: %w", payment.OrderID, err)
+	}
+
+	s.notifications.OrderPaid(ctx, order)
+
+	return PaymentRecorded, nil
+}
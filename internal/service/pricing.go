@@ -0,0 +1,377 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/Oj-washingtone/savannah-store/internal/model"
+	"github.com/Oj-washingtone/savannah-store/internal/repocitory"
+	"github.com/Oj-washingtone/savannah-store/internal/tax"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+)
+
+// NormalizePromotionCode is how codes are stored and looked up, so customers
+// can type them in any case.
+func NormalizePromotionCode(code string) string {
+	return strings.ToUpper(strings.TrimSpace(code))
+}
+
+// PricingService prices carts, applying promotions and VAT.
+type PricingService struct {
+	products   repocitory.ProductRepository
+	variants   repocitory.ProductVariantRepository
+	promotions repocitory.PromotionRepository
+
+	// pricesIncludeTax is whether catalogue prices already contain VAT
+	pricesIncludeTax bool
+}
+
+func NewPricingService(products repocitory.ProductRepository, variants repocitory.ProductVariantRepository, promotions repocitory.PromotionRepository, pricesIncludeTax bool) *PricingService {
+	return &PricingService{products: products, variants: variants, promotions: promotions, pricesIncludeTax: pricesIncludeTax}
+}
+
+// CheckPromotion reports why a promotion cannot be used by the user right
+// now, or an empty string if it can. Minimum spend is checked when the cart is
+// priced since it depends on the cart contents.
+func (s *PricingService) CheckPromotion(ctx context.Context, promotion *model.Promotion, userId uuid.UUID, at time.Time) (string, error) {
+	if !promotion.Active {
+		return "this promotion is no longer active", nil
+	}
+
+	if promotion.StartsAt != nil && at.Before(*promotion.StartsAt) {
+		return "this promotion has not started yet", nil
+	}
+
+	if promotion.EndsAt != nil && !at.Before(*promotion.EndsAt) {
+		return "this promotion has expired", nil
+	}
+
+	if promotion.UsageLimit == nil && promotion.PerCustomerLimit == nil {
+		return "", nil
+	}
+
+	total, byUser, err := s.promotions.Usage(ctx, promotion.ID, userId)
+	if err != nil {
+		return "", err
+	}
+
+	if promotion.UsageLimit != nil && total >= *promotion.UsageLimit {
+		return "this promotion has been fully redeemed", nil
+	}
+
+	if promotion.PerCustomerLimit != nil && byUser >= *promotion.PerCustomerLimit {
+		return "you have already used this promotion", nil
+	}
+
+	return "", nil
+}
+
+// PriceCart prices the cart items for the user, applying the automatic
+// promotions that are running and the codes entered on the cart, then VAT.
+// Each line is priced at what its variant sells for now, see reprice.
+//
+// Stacking rules: every stackable promotion that applies is combined, while a
+// promotion that is not stackable can only be used on its own. Whichever of
+// the two gives the customer the bigger discount wins, with the stackable set
+// preferred on a tie. A line is never discounted below zero.
+func (s *PricingService) PriceCart(ctx context.Context, cartId, userId uuid.UUID, items []*model.CartItem) (*model.CartSummary, error) {
+	summary := &model.CartSummary{
+		CartId:    cartId,
+		Lines:     []*model.CartLine{},
+		Discounts: []*model.AppliedDiscount{},
+		Codes:     []string{},
+	}
+
+	for _, item := range items {
+		line := &model.CartLine{
+			CartItem: item,
+			TaxClass: model.TaxStandard,
+		}
+
+		notice, err := s.reprice(ctx, line)
+		if err != nil {
+			return nil, err
+		}
+
+		if notice != "" {
+			summary.Notices = append(summary.Notices, notice)
+		}
+
+		line.LineTotal = item.Price * int64(item.Quantity)
+
+		summary.Lines = append(summary.Lines, line)
+		summary.Subtotal += line.LineTotal
+	}
+
+	now := time.Now()
+
+	automatic, err := s.promotions.ListAutomatic(ctx, now)
+	if err != nil {
+		return nil, err
+	}
+
+	coded, err := s.promotions.ListForCart(ctx, cartId)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, promotion := range coded {
+		if promotion.Code != nil {
+			summary.Codes = append(summary.Codes, *promotion.Code)
+		}
+	}
+
+	var candidates []*promotionCandidate
+
+	for _, promotion := range append(automatic, coded...) {
+		reason, err := s.CheckPromotion(ctx, promotion, userId, now)
+		if err != nil {
+			return nil, err
+		}
+
+		if reason == "" && summary.Subtotal < promotion.MinSpend {
+			reason = "your cart is below the minimum spend for this promotion"
+		}
+
+		var allocation []int64
+		if reason == "" {
+			allocation = allocateDiscount(promotion, summary.Lines, summary.Subtotal)
+
+			if sumOf(allocation) == 0 {
+				reason = "this promotion does not apply to the items in your cart"
+			}
+		}
+
+		if reason != "" {
+			if promotion.Code != nil {
+				summary.Notices = append(summary.Notices, *promotion.Code+": "+reason)
+			}
+			continue
+		}
+
+		candidates = append(candidates, &promotionCandidate{promotion: promotion, allocation: allocation})
+	}
+
+	chosen := chooseStack(candidates, summary.Lines)
+
+	for _, candidate := range candidates {
+		if candidate.promotion.Code != nil && !containsCandidate(chosen, candidate) {
+			summary.Notices = append(summary.Notices, *candidate.promotion.Code+": this promotion cannot be combined with a better offer on your cart")
+		}
+	}
+
+	for _, candidate := range chosen {
+		applied := &model.AppliedDiscount{
+			PromotionID: candidate.promotion.ID,
+			Code:        candidate.promotion.Code,
+			Description: candidate.promotion.Description,
+		}
+
+		for i, line := range summary.Lines {
+			amount := min(candidate.allocation[i], line.LineTotal-line.Discount)
+			line.Discount += amount
+			applied.Amount += amount
+		}
+
+		if applied.Amount > 0 {
+			summary.Discounts = append(summary.Discounts, applied)
+			summary.DiscountTotal += applied.Amount
+		}
+	}
+
+	// VAT is worked out per line on the discounted amount
+	summary.PricesIncludeTax = s.pricesIncludeTax
+
+	for _, line := range summary.Lines {
+		line.TaxRate = tax.Rate(line.TaxClass)
+
+		_, vat, gross := tax.Compute(line.LineTotal-line.Discount, line.TaxRate, summary.PricesIncludeTax)
+
+		line.Tax = vat
+		line.Total = gross
+
+		summary.TaxTotal += vat
+		summary.Total += gross
+	}
+
+	return summary, nil
+}
+
+// reprice sets the line's unit price to what its variant sells for now,
+// under the product's active discount, since the price stored on the cart
+// item is the one it had when it was added. It returns a notice for the
+// customer when the price changed since. A product or variant that is gone
+// keeps its stored price.
+func (s *PricingService) reprice(ctx context.Context, line *model.CartLine) (string, error) {
+	product, err := s.products.GetById(ctx, line.ProductId)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return "", nil
+	}
+	if err != nil {
+		return "", err
+	}
+
+	line.CategoryID = product.CategoryID
+	line.TaxClass = product.TaxClass
+
+	variant, err := s.variants.GetById(ctx, line.VariantId)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return "", nil
+	}
+	if err != nil {
+		return "", err
+	}
+
+	variant.ApplyPricing(product)
+
+	if variant.DiscountedPrice == line.Price {
+		return "", nil
+	}
+
+	notice := fmt.Sprintf("%s: the price is now Ksh.%d (was Ksh.%d when you added it)", product.Name, variant.DiscountedPrice, line.Price)
+	line.Price = variant.DiscountedPrice
+
+	return notice, nil
+}
+
+type promotionCandidate struct {
+	promotion  *model.Promotion
+	allocation []int64
+}
+
+// allocateDiscount works out how much the promotion takes off each line.
+// Cart wide discounts are spread over the lines in proportion to their value
+// so that per line figures (and the tax on them) add up to the cart total.
+func allocateDiscount(promotion *model.Promotion, lines []*model.CartLine, subtotal int64) []int64 {
+	allocation := make([]int64, len(lines))
+
+	if promotion.Scope == model.PromotionScopeCart {
+		var amount int64
+		if promotion.Type == model.DiscountPercentage {
+			amount = subtotal * promotion.Value / 100
+		} else {
+			amount = min(promotion.Value, subtotal)
+		}
+
+		spreadProportionally(amount, lines, allocation)
+		return allocation
+	}
+
+	for i, line := range lines {
+		matches := (promotion.Scope == model.PromotionScopeProduct && promotion.ProductID != nil && *promotion.ProductID == line.ProductId) ||
+			(promotion.Scope == model.PromotionScopeCategory && promotion.CategoryID != nil && *promotion.CategoryID == line.CategoryID)
+
+		if !matches {
+			continue
+		}
+
+		if promotion.Type == model.DiscountPercentage {
+			allocation[i] = line.LineTotal * promotion.Value / 100
+		} else {
+			allocation[i] = min(promotion.Value*int64(line.Quantity), line.LineTotal)
+		}
+	}
+
+	return allocation
+}
+
+// spreadProportionally splits amount over the lines by their value, handing
+// the shillings lost to rounding to the lines with the largest remainders.
+func spreadProportionally(amount int64, lines []*model.CartLine, allocation []int64) {
+	var total int64
+	for _, line := range lines {
+		total += line.LineTotal
+	}
+
+	if total == 0 || amount == 0 {
+		return
+	}
+
+	remainders := make([]int64, len(lines))
+	var allocated int64
+
+	for i, line := range lines {
+		share := amount * line.LineTotal
+		allocation[i] = share / total
+		remainders[i] = share % total
+		allocated += allocation[i]
+	}
+
+	for allocated < amount {
+		best := -1
+		for i := range lines {
+			if allocation[i] < lines[i].LineTotal && (best == -1 || remainders[i] > remainders[best]) {
+				best = i
+			}
+		}
+
+		if best == -1 {
+			return
+		}
+
+		allocation[best]++
+		remainders[best] = -1
+		allocated++
+	}
+}
+
+func chooseStack(candidates []*promotionCandidate, lines []*model.CartLine) []*promotionCandidate {
+	var stackable []*promotionCandidate
+	var bestSingle *promotionCandidate
+	var bestSingleAmount int64
+
+	for _, candidate := range candidates {
+		if candidate.promotion.Stackable {
+			stackable = append(stackable, candidate)
+			continue
+		}
+
+		amount := stackedAmount([]*promotionCandidate{candidate}, lines)
+		if bestSingle == nil || amount > bestSingleAmount {
+			bestSingle = candidate
+			bestSingleAmount = amount
+		}
+	}
+
+	if bestSingle != nil && bestSingleAmount > stackedAmount(stackable, lines) {
+		return []*promotionCandidate{bestSingle}
+	}
+
+	return stackable
+}
+
+func stackedAmount(candidates []*promotionCandidate, lines []*model.CartLine) int64 {
+	var total int64
+	for i, line := range lines {
+		var lineDiscount int64
+		for _, candidate := range candidates {
+			lineDiscount += candidate.allocation[i]
+		}
+		total += min(lineDiscount, line.LineTotal)
+	}
+
+	return total
+}
+
+func containsCandidate(candidates []*promotionCandidate, candidate *promotionCandidate) bool {
+	for _, c := range candidates {
+		if c == candidate {
+			return true
+		}
+	}
+
+	return false
+}
+
+func sumOf(values []int64) int64 {
+	var total int64
+	for _, v := range values {
+		total += v
+	}
+
+	return total
+}
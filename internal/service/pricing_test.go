@@ -0,0 +1,321 @@
+package service
+
+import (
+	"context"
+	"slices"
+	"testing"
+
+	"github.com/Oj-washingtone/savannah-store/internal/model"
+	"github.com/Oj-washingtone/savannah-store/internal/repocitory/memory"
+	"github.com/google/uuid"
+)
+
+// cartLines are lines of the given totals, one of each unit.
+func cartLines(totals ...int64) []*model.CartLine {
+	lines := make([]*model.CartLine, len(totals))
+	for i, total := range totals {
+		lines[i] = &model.CartLine{
+			CartItem:  &model.CartItem{ProductId: uuid.New(), Quantity: 1, Price: total},
+			LineTotal: total,
+		}
+	}
+
+	return lines
+}
+
+func TestSpreadProportionally(t *testing.T) {
+	for _, tc := range []struct {
+		name   string
+		amount int64
+		totals []int64
+		want   []int64
+	}{
+		{"exact shares", 100, []int64{300, 700}, []int64{30, 70}},
+		{"remainder to the largest", 5, []int64{2, 3, 4}, []int64{1, 2, 2}},
+		{"tied remainders go first come", 2, []int64{1, 1, 1}, []int64{1, 1, 0}},
+		{"the whole cart", 1000, []int64{300, 700}, []int64{300, 700}},
+		{"a line that is free", 50, []int64{0, 100}, []int64{0, 50}},
+		{"nothing to spread", 0, []int64{300, 700}, []int64{0, 0}},
+		{"zero total cart", 50, []int64{0, 0}, []int64{0, 0}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			allocation := make([]int64, len(tc.totals))
+			spreadProportionally(tc.amount, cartLines(tc.totals...), allocation)
+
+			if !slices.Equal(allocation, tc.want) {
+				t.Errorf("got %v, want %v", allocation, tc.want)
+			}
+		})
+	}
+}
+
+func TestAllocateDiscount(t *testing.T) {
+	lines := cartLines(999, 250)
+	lines[1].Quantity = 3
+	lines[1].CategoryID = uuid.New()
+
+	for _, tc := range []struct {
+		name      string
+		promotion *model.Promotion
+		want      []int64
+	}{
+		{
+			name:      "cart percentage rounds down then spreads",
+			promotion: &model.Promotion{Scope: model.PromotionScopeCart, Type: model.DiscountPercentage, Value: 10},
+			want:      []int64{99, 25},
+		},
+		{
+			name:      "cart fixed capped at the subtotal",
+			promotion: &model.Promotion{Scope: model.PromotionScopeCart, Type: model.DiscountFixed, Value: 5000},
+			want:      []int64{999, 250},
+		},
+		{
+			name:      "product percentage",
+			promotion: &model.Promotion{Scope: model.PromotionScopeProduct, ProductID: &lines[0].ProductId, Type: model.DiscountPercentage, Value: 15},
+			want:      []int64{149, 0},
+		},
+		{
+			name:      "fixed per unit capped at the line",
+			promotion: &model.Promotion{Scope: model.PromotionScopeProduct, ProductID: &lines[1].ProductId, Type: model.DiscountFixed, Value: 100},
+			want:      []int64{0, 250},
+		},
+		{
+			name:      "category",
+			promotion: &model.Promotion{Scope: model.PromotionScopeCategory, CategoryID: &lines[1].CategoryID, Type: model.DiscountFixed, Value: 20},
+			want:      []int64{0, 60},
+		},
+		{
+			name:      "product not in the cart",
+			promotion: &model.Promotion{Scope: model.PromotionScopeProduct, ProductID: ptrTo(uuid.New()), Type: model.DiscountPercentage, Value: 50},
+			want:      []int64{0, 0},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			got := allocateDiscount(tc.promotion, lines, 1249)
+
+			if !slices.Equal(got, tc.want) {
+				t.Errorf("got %v, want %v", got, tc.want)
+			}
+		})
+	}
+
+	if got := allocateDiscount(&model.Promotion{Scope: model.PromotionScopeCart, Type: model.DiscountFixed, Value: 100}, cartLines(0), 0); got[0] != 0 {
+		t.Errorf("a zero total cart got %v off", got)
+	}
+}
+
+func TestChooseStack(t *testing.T) {
+	candidate := func(stackable bool, allocation ...int64) *promotionCandidate {
+		return &promotionCandidate{promotion: &model.Promotion{Stackable: stackable}, allocation: allocation}
+	}
+
+	stackA, stackB := candidate(true, 90, 0), candidate(true, 90, 0)
+	single := candidate(false, 60, 60)
+	smallSingle := candidate(false, 50, 0)
+	tie := candidate(false, 100, 0)
+
+	for _, tc := range []struct {
+		name       string
+		candidates []*promotionCandidate
+		want       []*promotionCandidate
+	}{
+		{"nothing applies", nil, nil},
+		{"stackables combine", []*promotionCandidate{stackA, smallSingle, stackB}, []*promotionCandidate{stackA, stackB}},
+		// 90 + 90 only takes the 100 the first line is worth
+		{"the stack is capped at each line", []*promotionCandidate{stackA, stackB, single}, []*promotionCandidate{single}},
+		{"the best single wins", []*promotionCandidate{smallSingle, single}, []*promotionCandidate{single}},
+		{"a tie goes to the stack", []*promotionCandidate{tie, stackA, stackB}, []*promotionCandidate{stackA, stackB}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			got := chooseStack(tc.candidates, cartLines(100, 100))
+
+			if !slices.Equal(got, tc.want) {
+				t.Errorf("got %v, want %v", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestPriceCart(t *testing.T) {
+	type line struct {
+		price    int64
+		quantity int
+	}
+
+	for _, tc := range []struct {
+		name       string
+		lines      []line
+		promotions func(products []uuid.UUID) []*model.Promotion
+		inclusive  bool
+
+		discounts []int64
+		discount  int64
+		tax       int64
+		total     int64
+	}{
+		{
+			name:  "stacked promotions with VAT on top",
+			lines: []line{{999, 1}, {1001, 2}},
+			promotions: func(products []uuid.UUID) []*model.Promotion {
+				return []*model.Promotion{
+					{Scope: model.PromotionScopeCart, Type: model.DiscountPercentage, Value: 10, Stackable: true},
+					{Scope: model.PromotionScopeProduct, ProductID: &products[1], Type: model.DiscountFixed, Value: 50, Stackable: true},
+				}
+			},
+			// 300 off the cart spreads as 100 and 200, VAT is 143.84 and
+			// 272.32 on the 899 and 1702 left
+			discounts: []int64{100, 300},
+			discount:  400,
+			tax:       416,
+			total:     3017,
+		},
+		{
+			name:  "stacked promotions with VAT included",
+			lines: []line{{999, 1}, {1001, 2}},
+			promotions: func(products []uuid.UUID) []*model.Promotion {
+				return []*model.Promotion{
+					{Scope: model.PromotionScopeCart, Type: model.DiscountPercentage, Value: 10, Stackable: true},
+					{Scope: model.PromotionScopeProduct, ProductID: &products[1], Type: model.DiscountFixed, Value: 50, Stackable: true},
+				}
+			},
+			inclusive: true,
+			// VAT is 124 and 234.76 of the 899 and 1702
+			discounts: []int64{100, 300},
+			discount:  400,
+			tax:       359,
+			total:     2601,
+		},
+		{
+			name:  "a stack never takes a line below zero",
+			lines: []line{{999, 1}},
+			promotions: func(products []uuid.UUID) []*model.Promotion {
+				return []*model.Promotion{
+					{Scope: model.PromotionScopeProduct, ProductID: &products[0], Type: model.DiscountPercentage, Value: 60, Stackable: true},
+					{Scope: model.PromotionScopeProduct, ProductID: &products[0], Type: model.DiscountFixed, Value: 500, Stackable: true},
+					{Scope: model.PromotionScopeCart, Type: model.DiscountPercentage, Value: 90},
+				}
+			},
+			discounts: []int64{999},
+			discount:  999,
+			tax:       0,
+			total:     0,
+		},
+		{
+			name:  "a single promotion beats a smaller stack",
+			lines: []line{{999, 1}},
+			promotions: func(products []uuid.UUID) []*model.Promotion {
+				return []*model.Promotion{
+					{Scope: model.PromotionScopeCart, Type: model.DiscountPercentage, Value: 10, Stackable: true},
+					{Scope: model.PromotionScopeCart, Type: model.DiscountFixed, Value: 700},
+				}
+			},
+			// VAT of 47.84 on the 299 left
+			discounts: []int64{700},
+			discount:  700,
+			tax:       48,
+			total:     347,
+		},
+		{
+			name:  "zero total cart",
+			lines: []line{{0, 2}},
+			promotions: func(products []uuid.UUID) []*model.Promotion {
+				return []*model.Promotion{
+					{Scope: model.PromotionScopeCart, Type: model.DiscountPercentage, Value: 10},
+					{Scope: model.PromotionScopeCart, Type: model.DiscountFixed, Value: 100, Stackable: true},
+				}
+			},
+			discounts: []int64{0},
+		},
+		{
+			name:      "empty cart",
+			discounts: []int64{},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			ctx := context.Background()
+			repos := memory.NewRepositories()
+			pricing := NewPricingService(repos.Products, repos.Variants, repos.Promotions, tc.inclusive)
+
+			category := &model.ProductCategory{BaseModel: model.BaseModel{ID: uuid.New()}, Name: "Textiles", Slug: "textiles"}
+			if err := repos.Categories.Create(ctx, category); err != nil {
+				t.Fatal(err)
+			}
+
+			var products []uuid.UUID
+			var items []*model.CartItem
+
+			for i, l := range tc.lines {
+				product := &model.Product{
+					BaseModel:  model.BaseModel{ID: uuid.New()},
+					CategoryID: category.ID,
+					Name:       "Kikoi",
+					Slug:       "kikoi-" + string(rune('a'+i)),
+					Price:      l.price,
+					Stock:      10,
+					TaxClass:   model.TaxStandard,
+				}
+				if err := repos.Products.Create(ctx, product); err != nil {
+					t.Fatal(err)
+				}
+
+				variants, err := repos.Variants.ListByProduct(ctx, product.ID)
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				products = append(products, product.ID)
+				items = append(items, &model.CartItem{
+					BaseModel: model.BaseModel{ID: uuid.New()},
+					ProductId: product.ID,
+					VariantId: variants[0].ID,
+					Quantity:  l.quantity,
+					Price:     l.price,
+				})
+			}
+
+			if tc.promotions != nil {
+				for _, promotion := range tc.promotions(products) {
+					promotion.ID = uuid.New()
+					promotion.Active = true
+					if err := repos.Promotions.Create(ctx, promotion); err != nil {
+						t.Fatal(err)
+					}
+				}
+			}
+
+			summary, err := pricing.PriceCart(ctx, uuid.New(), uuid.New(), items)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			discounts := []int64{}
+			var lineTax, lineTotal int64
+			for _, line := range summary.Lines {
+				discounts = append(discounts, line.Discount)
+				lineTax += line.Tax
+				lineTotal += line.Total
+			}
+
+			if !slices.Equal(discounts, tc.discounts) {
+				t.Errorf("line discounts are %v, want %v", discounts, tc.discounts)
+			}
+
+			if summary.DiscountTotal != tc.discount || summary.TaxTotal != tc.tax || summary.Total != tc.total {
+				t.Errorf("got discount %d, tax %d and total %d, want %d, %d and %d",
+					summary.DiscountTotal, summary.TaxTotal, summary.Total, tc.discount, tc.tax, tc.total)
+			}
+
+			if lineTax != summary.TaxTotal || lineTotal != summary.Total {
+				t.Errorf("the lines add up to tax %d and total %d, the cart says %d and %d", lineTax, lineTotal, summary.TaxTotal, summary.Total)
+			}
+
+			if len(summary.Notices) != 0 {
+				t.Errorf("unexpected notices %v", summary.Notices)
+			}
+		})
+	}
+}
+
+func ptrTo[T any](v T) *T {
+	return &v
+}
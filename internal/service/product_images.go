@@ -0,0 +1,189 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+
+	"github.com/Oj-washingtone/savannah-store/internal/apperr"
+	"github.com/Oj-washingtone/savannah-store/internal/imaging"
+	"github.com/Oj-washingtone/savannah-store/internal/model"
+	"github.com/google/uuid"
+)
+
+var (
+	// ErrUnsupportedImage is returned for uploads that are not JPEG, PNG, GIF
+	// or WebP images, whatever their file name or declared content type says.
+	ErrUnsupportedImage = apperr.UnsupportedMedia("Only JPEG, PNG, GIF and WebP images are accepted")
+
+	// ErrInvalidImage is returned for uploads that look like an image but
+	// cannot be decoded, or are too large to process.
+	ErrInvalidImage = apperr.Validation("The image could not be read")
+)
+
+var supportedImageTypes = map[string]bool{
+	"image/jpeg": true,
+	"image/png":  true,
+	"image/gif":  true,
+	"image/webp": true,
+}
+
+// MaxImageSize is the largest image upload accepted.
+func (s *ProductService) MaxImageSize() int64 {
+	return s.maxImageSize
+}
+
+// UploadProductImage cleans up the image, makes its variants, stores them and
+// adds the image to the product's images. The image type is worked out from
+// the file contents.
+func (s *ProductService) UploadProductImage(ctx context.Context, productId uuid.UUID, file io.Reader, altText string) (*model.ProductImage, error) {
+	data, err := io.ReadAll(file)
+	if err != nil {
+		return nil, err
+	}
+
+	contentType := http.DetectContentType(data)
+	if !supportedImageTypes[contentType] {
+		return nil, ErrUnsupportedImage
+	}
+
+	processed, err := imaging.Process(data, contentType)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidImage, err)
+	}
+
+	store := s.store
+
+	// files already stored, removed again if a later step fails
+	var stored []string
+
+	put := func(key string, f *imaging.File) (string, error) {
+		if err := store.Put(ctx, key, f.ContentType, bytes.NewReader(f.Data), int64(len(f.Data))); err != nil {
+			return "", err
+		}
+		stored = append(stored, key)
+		return store.URL(key), nil
+	}
+
+	cleanup := func() {
+		for _, key := range stored {
+			if err := store.Delete(ctx, key); err != nil {
+				slog.ErrorContext(ctx, "Failed to remove an orphaned image", "key", key, "error", err)
+			}
+		}
+	}
+
+	image := &model.ProductImage{
+		ProductID:   productId,
+		AltText:     altText,
+		ContentType: processed.Original.ContentType,
+		Size:        int64(len(processed.Original.Data)),
+		Width:       processed.Original.Width,
+		Height:      processed.Original.Height,
+	}
+	image.ID = uuid.New()
+
+	// variants sit next to the original: <id>.jpg, <id>_thumbnail.jpg, <id>_thumbnail.webp, ...
+	base := fmt.Sprintf("products/%s/%s", productId, image.ID)
+	image.StorageKey = base + processed.Original.Extension
+
+	if image.URL, err = put(image.StorageKey, processed.Original); err != nil {
+		cleanup()
+		return nil, err
+	}
+
+	var variants []*model.ProductImageVariant
+
+	for _, variant := range processed.Variants {
+		for _, f := range []*imaging.File{variant.Main, variant.WebP} {
+			v := &model.ProductImageVariant{
+				ImageID:     image.ID,
+				Name:        variant.Name,
+				ContentType: f.ContentType,
+				Width:       f.Width,
+				Height:      f.Height,
+				Size:        int64(len(f.Data)),
+				StorageKey:  base + "_" + variant.Name + f.Extension,
+			}
+			v.ID = uuid.New()
+
+			if v.URL, err = put(v.StorageKey, f); err != nil {
+				cleanup()
+				return nil, err
+			}
+
+			variants = append(variants, v)
+		}
+	}
+
+	if err := s.images.Create(ctx, image, variants); err != nil {
+		cleanup()
+		return nil, err
+	}
+
+	for _, v := range variants {
+		image.AddVariant(v)
+	}
+
+	return image, nil
+}
+
+// DeleteProductImage removes the image and its variants from the product and
+// from storage.
+func (s *ProductService) DeleteProductImage(ctx context.Context, image *model.ProductImage) error {
+	variants, err := s.images.ListVariants(ctx, []uuid.UUID{image.ID})
+	if err != nil {
+		return err
+	}
+
+	if err := s.images.Delete(ctx, image); err != nil {
+		return err
+	}
+
+	keys := []string{image.StorageKey}
+	for _, variant := range variants {
+		keys = append(keys, variant.StorageKey)
+	}
+
+	for _, key := range keys {
+		if key == "" {
+			continue
+		}
+
+		if err := s.store.Delete(ctx, key); err != nil {
+			slog.ErrorContext(ctx, "Failed to remove an image from storage", "key", key, "error", err)
+		}
+	}
+
+	return nil
+}
+
+// AttachProductImages loads the images of the products for product
+// responses.
+func (s *ProductService) AttachProductImages(ctx context.Context, products ...*model.Product) error {
+	if len(products) == 0 {
+		return nil
+	}
+
+	ids := make([]uuid.UUID, len(products))
+	for i, product := range products {
+		ids[i] = product.ID
+	}
+
+	images, err := s.images.ListByProducts(ctx, ids)
+	if err != nil {
+		return err
+	}
+
+	for _, product := range products {
+		product.Images = images[product.ID]
+		if product.Images == nil {
+			product.Images = []*model.ProductImage{}
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,165 @@
+package service
+
+import (
+	"context"
+
+	"github.com/Oj-washingtone/savannah-store/internal/apperr"
+	"github.com/Oj-washingtone/savannah-store/internal/model"
+	"github.com/Oj-washingtone/savannah-store/internal/repocitory"
+	"github.com/Oj-washingtone/savannah-store/internal/storage"
+	"github.com/google/uuid"
+)
+
+var (
+	// ErrVariantRequired is returned when a product with options is added to
+	// a cart without saying which variant.
+	ErrVariantRequired = apperr.Validation("Choose a variant of this product")
+
+	// ErrVariantNotFound is returned for a variant that does not exist or
+	// belongs to another product.
+	ErrVariantNotFound = apperr.NotFound("No variant with this id on the product")
+)
+
+// ProductService does the work on products that spans their variants,
+// images and the file storage behind them, and tells wishlist watchers about
+// the changes.
+type ProductService struct {
+	products      repocitory.ProductRepository
+	variants      repocitory.ProductVariantRepository
+	images        repocitory.ProductImageRepository
+	store         storage.Storage
+	notifications *NotificationService
+	background    *Background
+
+	maxImageSize int64
+}
+
+// NewProductService builds the product service, keeping uploaded images in
+// store and refusing any larger than maxImageSize bytes. Wishlist alerts are
+// sent in the background.
+func NewProductService(repos *repocitory.Repositories, store storage.Storage, notifications *NotificationService, background *Background, maxImageSize int64) *ProductService {
+	return &ProductService{
+		products:      repos.Products,
+		variants:      repos.Variants,
+		images:        repos.Images,
+		store:         store,
+		notifications: notifications,
+		background:    background,
+		maxImageSize:  maxImageSize,
+	}
+}
+
+// IsSimpleProduct reports whether variants are the single option-less variant
+// every product starts with.
+func IsSimpleProduct(variants []*model.ProductVariant) bool {
+	return len(variants) == 1 && len(variants[0].Options) == 0
+}
+
+// ResolveVariant picks the variant of the product being bought. variantId may
+// be nil for simple products, which have only one variant to choose from.
+// The variant comes back priced from the product.
+func (s *ProductService) ResolveVariant(ctx context.Context, product *model.Product, variantId *uuid.UUID) (*model.ProductVariant, error) {
+	variants, err := s.variants.ListByProduct(ctx, product.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	var variant *model.ProductVariant
+
+	if variantId == nil {
+		if !IsSimpleProduct(variants) {
+			return nil, ErrVariantRequired
+		}
+		variant = variants[0]
+	} else {
+		for _, v := range variants {
+			if v.ID == *variantId {
+				variant = v
+			}
+		}
+
+		if variant == nil {
+			return nil, ErrVariantNotFound
+		}
+	}
+
+	variant.ApplyPricing(product)
+
+	return variant, nil
+}
+
+// WatchedProduct is the product as its wishlist watchers see it, to compare
+// before and after a change for NotifyWishlistWatchers. Stock is the total
+// of the variants and DiscountedPrice the lowest any variant sells for, so a
+// restocked or cheaper variant counts for the product.
+func (s *ProductService) WatchedProduct(ctx context.Context, productId uuid.UUID) (*model.Product, error) {
+	product, err := s.products.GetById(ctx, productId)
+	if err != nil {
+		return nil, err
+	}
+
+	return product, s.lowestVariantPrice(ctx, product)
+}
+
+// lowestVariantPrice sets the product's DiscountedPrice to the lowest its
+// variants sell for with the product's discount.
+func (s *ProductService) lowestVariantPrice(ctx context.Context, product *model.Product) error {
+	variants, err := s.variants.ListByProduct(ctx, product.ID)
+	if err != nil {
+		return err
+	}
+
+	for i, variant := range variants {
+		variant.ApplyPricing(product)
+
+		if i == 0 || variant.DiscountedPrice < product.DiscountedPrice {
+			product.DiscountedPrice = variant.DiscountedPrice
+		}
+	}
+
+	return nil
+}
+
+// AttachProductVariants loads the options and variants of the product for the
+// product detail response, pricing each variant and giving it the images tied
+// to it. Images must already be attached.
+func (s *ProductService) AttachProductVariants(ctx context.Context, product *model.Product) error {
+	options, err := s.variants.ListOptions(ctx, product.ID)
+	if err != nil {
+		return err
+	}
+
+	variants, err := s.variants.ListByProduct(ctx, product.ID)
+	if err != nil {
+		return err
+	}
+
+	byId := map[uuid.UUID]*model.ProductVariant{}
+
+	for _, variant := range variants {
+		variant.ApplyPricing(product)
+		byId[variant.ID] = variant
+	}
+
+	for _, image := range product.Images {
+		if image.VariantID == nil {
+			continue
+		}
+
+		if variant, ok := byId[*image.VariantID]; ok {
+			variant.Images = append(variant.Images, image)
+		}
+	}
+
+	product.Options = options
+	if product.Options == nil {
+		product.Options = []*model.ProductOption{}
+	}
+
+	product.Variants = variants
+	if product.Variants == nil {
+		product.Variants = []*model.ProductVariant{}
+	}
+
+	return nil
+}
@@ -0,0 +1,83 @@
+package service
+
+import (
+	"context"
+
+	"github.com/Oj-washingtone/savannah-store/internal/apperr"
+	"github.com/Oj-washingtone/savannah-store/internal/model"
+)
+
+// ErrProductHasVariants is returned when the stock of a product with options
+// is set on the product rather than on its variants.
+var ErrProductHasVariants = apperr.Validation("The product has variants, set the stock on each variant instead")
+
+// UpdateProduct saves the product's details and, when stock is not nil, the
+// stock of a simple product's single variant, in one transaction. Wishlist
+// watchers hear about a restock or a lower price.
+func (s *ProductService) UpdateProduct(ctx context.Context, product *model.Product, stock *int) error {
+	before, err := s.WatchedProduct(ctx, product.ID)
+	if err != nil {
+		return err
+	}
+
+	if stock == nil {
+		err = s.products.Update(ctx, product)
+	} else {
+		err = s.updateWithStock(ctx, product, *stock)
+	}
+
+	if err != nil {
+		return err
+	}
+
+	s.alertWatchers(ctx, before)
+
+	return nil
+}
+
+func (s *ProductService) updateWithStock(ctx context.Context, product *model.Product, stock int) error {
+	variants, err := s.variants.ListByProduct(ctx, product.ID)
+	if err != nil {
+		return err
+	}
+
+	if !IsSimpleProduct(variants) {
+		return ErrProductHasVariants
+	}
+
+	variant := variants[0]
+	variant.Stock = stock
+
+	return s.products.UpdateWithStock(ctx, product, variant)
+}
+
+// UpdateVariant saves the variant. A restocked or cheaper variant is news
+// for the watchers of its product.
+func (s *ProductService) UpdateVariant(ctx context.Context, variant *model.ProductVariant) error {
+	before, err := s.WatchedProduct(ctx, variant.ProductID)
+	if err != nil {
+		return err
+	}
+
+	if err := s.variants.Update(ctx, variant); err != nil {
+		return err
+	}
+
+	s.alertWatchers(ctx, before)
+
+	return nil
+}
+
+// alertWatchers compares the product as WatchedProduct saw it before a
+// change with how it is now, and lets its wishlist watchers know about a
+// restock or a price drop without holding up the caller.
+func (s *ProductService) alertWatchers(ctx context.Context, before *model.Product) {
+	s.background.Go(ctx, "send wishlist alerts", func(ctx context.Context) error {
+		after, err := s.WatchedProduct(ctx, before.ID)
+		if err != nil {
+			return err
+		}
+
+		return s.notifications.NotifyWishlistWatchers(ctx, before, after)
+	})
+}
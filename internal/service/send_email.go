@@ -1,8 +1,9 @@
 package service
 
 import (
-	"os"
+	"errors"
 
+	"github.com/Oj-washingtone/savannah-store/internal/config"
 	"github.com/resend/resend-go/v2"
 )
 
@@ -13,19 +14,53 @@ type EmailParams struct {
 	Subject string
 }
 
-func SendEmail(to string, subject string, body string) error {
+// EmailAttachment is a file sent along with an email.
+type EmailAttachment struct {
+	Filename    string
+	Content     []byte
+	ContentType string
+}
+
+// EmailSender sends emails. Tests swap in one that records what was sent.
+type EmailSender interface {
+	SendEmail(to string, subject string, body string, attachments ...EmailAttachment) error
+}
 
-	apiKey := os.Getenv("RESEND_KEY")
+// ErrEmailNotConfigured is returned for every email when no Resend key is
+// set.
+var ErrEmailNotConfigured = errors.New("email is not configured, set RESEND_KEY")
 
-	client := resend.NewClient(apiKey)
+// ResendEmailSender sends emails through Resend.
+type ResendEmailSender struct {
+	cfg config.Email
+}
+
+func NewResendEmailSender(cfg config.Email) *ResendEmailSender {
+	return &ResendEmailSender{cfg: cfg}
+}
+
+func (s *ResendEmailSender) SendEmail(to string, subject string, body string, attachments ...EmailAttachment) error {
+	if s.cfg.ResendKey == "" {
+		return ErrEmailNotConfigured
+	}
+
+	client := resend.NewClient(string(s.cfg.ResendKey))
 
 	params := &resend.SendEmailRequest{
 		To:      []string{to},
-		From:    "orders@linxs.co.ke",
+		From:    s.cfg.From,
 		Text:    body,
 		Subject: subject,
 	}
 
+	for _, attachment := range attachments {
+		params.Attachments = append(params.Attachments, &resend.Attachment{
+			Filename:    attachment.Filename,
+			Content:     attachment.Content,
+			ContentType: attachment.ContentType,
+		})
+	}
+
 	_, err := client.Emails.Send(params)
 
 	if err != nil {
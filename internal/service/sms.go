@@ -2,11 +2,13 @@ package service
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
 	"net/url"
-	"os"
 	"strings"
+
+	"github.com/Oj-washingtone/savannah-store/internal/config"
 )
 
 type smsResponse struct {
@@ -17,30 +19,44 @@ type smsResponse struct {
 	} `json:"SMSMessageData"`
 }
 
-func SendSMS(to, message string) error {
-	apiKey := os.Getenv("AFRICASTALKING_API_KEY")
-	username := os.Getenv("AFRICASTALKING_USERNAME")
+// SMSSender sends text messages. Tests swap in one that records what was
+// sent.
+type SMSSender interface {
+	SendSMS(to, message string) error
+}
+
+// ErrSMSNotConfigured is returned for every text message when no Africa's
+// Talking account is set.
+var ErrSMSNotConfigured = errors.New("SMS is not configured, set AFRICASTALKING_USERNAME and AFRICASTALKING_API_KEY")
 
-	if apiKey == "" || username == "" {
-		return fmt.Errorf("missing Africa's Talking credentials in environment variables")
-	}
+// AfricasTalkingSMSSender sends text messages through Africa's Talking.
+type AfricasTalkingSMSSender struct {
+	cfg config.SMS
+}
+
+func NewAfricasTalkingSMSSender(cfg config.SMS) *AfricasTalkingSMSSender {
+	return &AfricasTalkingSMSSender{cfg: cfg}
+}
 
-	baseURL := os.Getenv("AFRICASTALKING_URL")
+func (s *AfricasTalkingSMSSender) SendSMS(to, message string) error {
+	if s.cfg.Username == "" || s.cfg.APIKey == "" {
+		return ErrSMSNotConfigured
+	}
 
 	form := url.Values{}
-	form.Set("username", username)
+	form.Set("username", s.cfg.Username)
 	form.Set("to", to)
 	form.Set("message", message)
 	form.Set("enqueue", "1")
 
-	req, err := http.NewRequest("POST", baseURL, strings.NewReader(form.Encode()))
+	req, err := http.NewRequest("POST", s.cfg.URL, strings.NewReader(form.Encode()))
 	if err != nil {
 		return err
 	}
 
 	req.Header.Set("Accept", "application/json")
 	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
-	req.Header.Set("apiKey", apiKey)
+	req.Header.Set("apiKey", string(s.cfg.APIKey))
 
 	client := &http.Client{}
 	resp, err := client.Do(req)
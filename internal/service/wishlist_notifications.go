@@ -0,0 +1,55 @@
+package service
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/Oj-washingtone/savannah-store/internal/model"
+)
+
+// NotifyWishlistWatchers tells everyone with the product on a wishlist that it
+// is back in stock or cheaper than before. before and after are the product as
+// it was prior to and following the update; prices are compared after any
+// sale discount.
+func (s *NotificationService) NotifyWishlistWatchers(ctx context.Context, before, after *model.Product) error {
+	backInStock := before.Stock <= 0 && after.Stock > 0
+	priceDrop := after.DiscountedPrice < before.DiscountedPrice
+
+	if !backInStock && !priceDrop {
+		return nil
+	}
+
+	watchers, err := s.wishlists.ListWatchers(ctx, after.ID)
+	if err != nil {
+		return err
+	}
+
+	link := s.shop.StorefrontURL + "/products/" + after.ID.String()
+
+	var subject, message string
+	switch {
+	case backInStock && priceDrop:
+		subject = after.Name + " is back in stock and cheaper"
+		message = fmt.Sprintf("%s from your wishlist is back in stock and now Ksh.%d (was Ksh.%d). %s", after.Name, after.DiscountedPrice, before.DiscountedPrice, link)
+	case backInStock:
+		subject = after.Name + " is back in stock"
+		message = fmt.Sprintf("%s from your wishlist is back in stock. %s", after.Name, link)
+	default:
+		subject = "Price drop on " + after.Name
+		message = fmt.Sprintf("%s from your wishlist is now Ksh.%d (was Ksh.%d). %s", after.Name, after.DiscountedPrice, before.DiscountedPrice, link)
+	}
+
+	for _, watcher := range watchers {
+		if watcher.Email != "" {
+			body := fmt.Sprintf("Hi %s,\n\n%s\n\nYou can turn these alerts off in your account preferences: %s/account/preferences\n", watcher.Name, message, s.shop.StorefrontURL)
+
+			s.sendEmail(ctx, "wishlist alert email", watcher.Email, subject, body)
+		}
+
+		if watcher.Phone != "" {
+			s.sendSMS(ctx, "wishlist alert SMS", watcher.Phone, message)
+		}
+	}
+
+	return nil
+}
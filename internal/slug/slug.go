@@ -0,0 +1,77 @@
+// Package slug makes the human-readable identifiers used in URLs.
+package slug
+
+import (
+	"context"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+// MaxLength is the longest slug made from a name. Custom slugs may be as long
+// as the column allows.
+const MaxLength = 80
+
+var valid = regexp.MustCompile(`^[a-z0-9]+(-[a-z0-9]+)*$`)
+
+// Make turns a name such as "Men's Shoes & Boots" into "men-s-shoes-boots".
+// Accents are dropped, so "Café" becomes "cafe". Names with nothing usable in
+// them give an empty slug.
+func Make(name string) string {
+	var b strings.Builder
+	dash := false
+
+	for _, r := range norm.NFD.String(name) {
+		if unicode.Is(unicode.Mn, r) {
+			continue
+		}
+
+		r = unicode.ToLower(r)
+
+		if (r >= 'a' && r <= 'z') || (r >= '0' && r <= '9') {
+			if dash && b.Len() > 0 {
+				b.WriteByte('-')
+			}
+			b.WriteRune(r)
+			dash = false
+			continue
+		}
+
+		dash = true
+	}
+
+	s := b.String()
+	if len(s) > MaxLength {
+		s = strings.TrimRight(s[:MaxLength], "-")
+	}
+
+	return s
+}
+
+// Valid reports whether s is a well formed slug: lowercase letters and
+// digits separated by single dashes.
+func Valid(s string) bool {
+	return valid.MatchString(s)
+}
+
+// Unique returns base, or base with the first free number appended
+// ("shoes-2", "shoes-3", ...) if base is taken.
+func Unique(ctx context.Context, base string, taken func(ctx context.Context, slug string) (bool, error)) (string, error) {
+	candidate := base
+
+	for n := 2; ; n++ {
+		exists, err := taken(ctx, candidate)
+		if err != nil {
+			return "", err
+		}
+
+		if !exists {
+			return candidate, nil
+		}
+
+		candidate = base + "-" + strconv.Itoa(n)
+	}
+}
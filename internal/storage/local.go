@@ -0,0 +1,87 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Local keeps files in a directory on disk. The server serves the directory
+// itself, see LocalDir.
+type Local struct {
+	Dir       string
+	PublicURL string
+}
+
+func NewLocal(dir, publicURL string) *Local {
+	return &Local{Dir: dir, PublicURL: publicURL}
+}
+
+// path resolves key inside Dir, refusing keys that would escape it.
+func (l *Local) path(key string) (string, error) {
+	clean := filepath.Clean("/" + key)
+	if clean == "/" || strings.Contains(key, "..") {
+		return "", errors.New("invalid storage key")
+	}
+
+	return filepath.Join(l.Dir, clean), nil
+}
+
+func (l *Local) Put(ctx context.Context, key string, contentType string, body io.Reader, size int64) error {
+	path, err := l.path(key)
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+
+	// write to a temporary file first so a failed upload never leaves half
+	// a file behind under the real name
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".upload-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := io.Copy(tmp, body); err != nil {
+		tmp.Close()
+		return err
+	}
+
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp.Name(), path)
+}
+
+func (l *Local) Get(ctx context.Context, key string) (io.ReadCloser, error) {
+	path, err := l.path(key)
+	if err != nil {
+		return nil, err
+	}
+
+	return os.Open(path)
+}
+
+func (l *Local) Delete(ctx context.Context, key string) error {
+	path, err := l.path(key)
+	if err != nil {
+		return err
+	}
+
+	if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+
+	return nil
+}
+
+func (l *Local) URL(key string) string {
+	return joinURL(l.PublicURL, key)
+}
@@ -0,0 +1,77 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"io"
+
+	"github.com/minio/minio-go/v7"
+	"github.com/minio/minio-go/v7/pkg/credentials"
+)
+
+// S3Config points at an S3-compatible bucket: AWS S3, DigitalOcean Spaces, or
+// a MinIO server for local development.
+type S3Config struct {
+	Endpoint  string
+	Region    string
+	Bucket    string
+	AccessKey string
+	SecretKey string
+	UseSSL    bool
+
+	// PublicURL is the base address files are served from, such as a CDN.
+	// Defaults to the bucket on the endpoint.
+	PublicURL string
+}
+
+type S3 struct {
+	client    *minio.Client
+	bucket    string
+	publicURL string
+}
+
+func NewS3(cfg S3Config) (*S3, error) {
+	if cfg.Endpoint == "" || cfg.Bucket == "" {
+		return nil, fmt.Errorf("S3_ENDPOINT and S3_BUCKET are required")
+	}
+
+	client, err := minio.New(cfg.Endpoint, &minio.Options{
+		Creds:  credentials.NewStaticV4(cfg.AccessKey, cfg.SecretKey, ""),
+		Secure: cfg.UseSSL,
+		Region: cfg.Region,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	publicURL := cfg.PublicURL
+	if publicURL == "" {
+		scheme := "https"
+		if !cfg.UseSSL {
+			scheme = "http"
+		}
+		publicURL = fmt.Sprintf("%s://%s/%s", scheme, cfg.Endpoint, cfg.Bucket)
+	}
+
+	return &S3{client: client, bucket: cfg.Bucket, publicURL: publicURL}, nil
+}
+
+func (s *S3) Put(ctx context.Context, key string, contentType string, body io.Reader, size int64) error {
+	_, err := s.client.PutObject(ctx, s.bucket, key, body, size, minio.PutObjectOptions{
+		ContentType:  contentType,
+		CacheControl: "public, max-age=31536000, immutable",
+	})
+	return err
+}
+
+func (s *S3) Get(ctx context.Context, key string) (io.ReadCloser, error) {
+	return s.client.GetObject(ctx, s.bucket, key, minio.GetObjectOptions{})
+}
+
+func (s *S3) Delete(ctx context.Context, key string) error {
+	return s.client.RemoveObject(ctx, s.bucket, key, minio.RemoveObjectOptions{})
+}
+
+func (s *S3) URL(key string) string {
+	return joinURL(s.publicURL, key)
+}
@@ -0,0 +1,51 @@
+// Package storage keeps uploaded files such as product images, either on the
+// local disk or in an S3-compatible bucket.
+package storage
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/Oj-washingtone/savannah-store/internal/config"
+)
+
+// Storage is where uploaded files are kept. Keys are slash separated paths
+// such as "products/<id>/<file>.jpg".
+type Storage interface {
+	Put(ctx context.Context, key string, contentType string, body io.Reader, size int64) error
+	Get(ctx context.Context, key string) (io.ReadCloser, error)
+	Delete(ctx context.Context, key string) error
+
+	// URL is the public address the file can be downloaded from.
+	URL(key string) string
+}
+
+// Connect sets up the storage backend chosen by cfg.Driver, "local" or "s3".
+func Connect(cfg config.Storage) (Storage, error) {
+	switch cfg.Driver {
+	case "local":
+		return NewLocal(cfg.LocalDir, cfg.PublicURL), nil
+	case "s3":
+		s3, err := NewS3(S3Config{
+			Endpoint:  cfg.S3.Endpoint,
+			Region:    cfg.S3.Region,
+			Bucket:    cfg.S3.Bucket,
+			AccessKey: cfg.S3.AccessKey,
+			SecretKey: string(cfg.S3.SecretKey),
+			UseSSL:    cfg.S3.UseSSL,
+			PublicURL: cfg.PublicURL,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("unable to connect to storage: %w", err)
+		}
+		return s3, nil
+	}
+
+	return nil, fmt.Errorf("unknown storage driver %q, use local or s3", cfg.Driver)
+}
+
+func joinURL(base, key string) string {
+	return strings.TrimRight(base, "/") + "/" + key
+}
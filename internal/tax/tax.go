@@ -0,0 +1,70 @@
+// Package tax works out Kenyan VAT on order lines.
+package tax
+
+import (
+	"github.com/Oj-washingtone/savannah-store/internal/model"
+)
+
+// Rates are in basis points, so 1600 is 16%.
+const (
+	StandardRate  = 1600
+	ZeroRatedRate = 0
+)
+
+// Rate returns the VAT rate of a tax class in basis points. Exempt supplies
+// carry no VAT at all, unlike zero-rated ones which are taxable at 0%, so
+// both come out as 0 here and are told apart by their class.
+func Rate(class model.TaxClass) int {
+	switch class {
+	case model.TaxZeroRated, model.TaxExempt:
+		return ZeroRatedRate
+	default:
+		return StandardRate
+	}
+}
+
+// ValidClass reports whether class is one of the known tax classes.
+func ValidClass(class model.TaxClass) bool {
+	switch class {
+	case model.TaxStandard, model.TaxZeroRated, model.TaxExempt:
+		return true
+	}
+
+	return false
+}
+
+// Compute splits amount into its net value and VAT for the given rate.
+// With inclusive pricing the VAT is taken out of amount, otherwise it is added
+// on top; gross is what the customer pays. VAT is rounded half up to the
+// nearest shilling.
+func Compute(amount int64, rate int, inclusive bool) (net, vat, gross int64) {
+	if rate == 0 || amount <= 0 {
+		return amount, 0, amount
+	}
+
+	r := int64(rate)
+
+	if inclusive {
+		vat = divRound(amount*r, 10000+r)
+		return amount - vat, vat, amount
+	}
+
+	vat = divRound(amount*r, 10000)
+	return amount, vat, amount + vat
+}
+
+func divRound(a, b int64) int64 {
+	return (2*a + b) / (2 * b)
+}
+
+// Label is the short description of a rate used on emails and invoices.
+func Label(class model.TaxClass) string {
+	switch class {
+	case model.TaxZeroRated:
+		return "VAT 0%"
+	case model.TaxExempt:
+		return "VAT exempt"
+	default:
+		return "VAT 16%"
+	}
+}
@@ -0,0 +1,39 @@
+package tax
+
+import "testing"
+
+func TestCompute(t *testing.T) {
+	for _, tc := range []struct {
+		name      string
+		amount    int64
+		rate      int
+		inclusive bool
+
+		net, vat, gross int64
+	}{
+		{"exclusive exact", 1000, StandardRate, false, 1000, 160, 1160},
+		{"exclusive rounds up", 99, StandardRate, false, 99, 16, 115},
+		{"exclusive rounds down", 1702, StandardRate, false, 1702, 272, 1974},
+		{"exclusive half rounds up", 10, 500, false, 10, 1, 11},
+		{"inclusive exact", 1160, StandardRate, true, 1000, 160, 1160},
+		{"inclusive rounds up", 100, StandardRate, true, 86, 14, 100},
+		{"inclusive rounds down", 10, StandardRate, true, 9, 1, 10},
+		{"below a shilling of VAT", 3, StandardRate, false, 3, 0, 3},
+		{"zero rated", 999, ZeroRatedRate, false, 999, 0, 999},
+		{"zero amount", 0, StandardRate, true, 0, 0, 0},
+		{"negative amount", -50, StandardRate, false, -50, 0, -50},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			net, vat, gross := Compute(tc.amount, tc.rate, tc.inclusive)
+
+			if net != tc.net || vat != tc.vat || gross != tc.gross {
+				t.Errorf("Compute(%d, %d, %v) = %d, %d, %d, want %d, %d, %d",
+					tc.amount, tc.rate, tc.inclusive, net, vat, gross, tc.net, tc.vat, tc.gross)
+			}
+
+			if net+vat != gross {
+				t.Errorf("net %d and VAT %d do not add up to %d", net, vat, gross)
+			}
+		})
+	}
+}
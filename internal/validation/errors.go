@@ -0,0 +1,136 @@
+package validation
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+	"strings"
+
+	"github.com/go-playground/validator/v10"
+)
+
+// FieldError says why one field of a request was rejected. Field is the
+// json path of the field, such as "price" or "values[2]".
+type FieldError struct {
+	Field  string `json:"field"`
+	Reason string `json:"reason"`
+}
+
+// Fields explains an error from binding a request body. It gives the invalid
+// fields when the error is about fields, and otherwise a short description of
+// what is wrong with the body as a whole.
+func Fields(err error) ([]FieldError, string) {
+	var invalid validator.ValidationErrors
+	if errors.As(err, &invalid) {
+		fields := make([]FieldError, 0, len(invalid))
+		for _, fe := range invalid {
+			fields = append(fields, FieldError{Field: fieldPath(fe), Reason: reason(fe)})
+		}
+		return fields, "Some fields are missing or invalid"
+	}
+
+	var typeErr *json.UnmarshalTypeError
+	if errors.As(err, &typeErr) {
+		field := typeErr.Field
+		if field == "" {
+			return nil, "Request body must be a JSON object"
+		}
+		return []FieldError{{Field: field, Reason: "must be " + describeType(typeErr.Type)}}, "Some fields are missing or invalid"
+	}
+
+	var syntaxErr *json.SyntaxError
+	if errors.As(err, &syntaxErr) || errors.Is(err, io.ErrUnexpectedEOF) {
+		return nil, "Request body is not valid JSON"
+	}
+
+	if errors.Is(err, io.EOF) {
+		return nil, "Request body is empty"
+	}
+
+	// values that decode themselves, such as UUIDs and times, fail with their
+	// own errors and no field
+	return nil, "Request body has a value in the wrong format"
+}
+
+// fieldPath drops the struct name the namespace starts with, and the names
+// of embedded structs.
+func fieldPath(fe validator.FieldError) string {
+	parts := strings.Split(fe.Namespace(), ".")
+
+	path := make([]string, 0, len(parts))
+	for _, part := range parts[1:] {
+		if part != embedded {
+			path = append(path, part)
+		}
+	}
+
+	if len(path) == 0 {
+		return fe.Field()
+	}
+
+	return strings.Join(path, ".")
+}
+
+func reason(fe validator.FieldError) string {
+	text := fe.Kind() == reflect.String
+	list := fe.Kind() == reflect.Slice || fe.Kind() == reflect.Map
+
+	switch fe.Tag() {
+	case "required":
+		return "is required"
+	case "min":
+		if text {
+			return "must be at least " + fe.Param() + " characters"
+		}
+		if list {
+			return "must have at least " + fe.Param() + " items"
+		}
+		return "must be at least " + fe.Param()
+	case "max":
+		if text {
+			return "can be at most " + fe.Param() + " characters"
+		}
+		if list {
+			return "can have at most " + fe.Param() + " items"
+		}
+		return "can be at most " + fe.Param()
+	case "gt":
+		return "must be more than " + fe.Param()
+	case "oneof":
+		return "must be one of " + strings.Join(strings.Fields(fe.Param()), ", ")
+	case "uuid":
+		return "must be a UUID"
+	case "money":
+		return "must be a positive amount in Ksh"
+	case "ke_phone":
+		return "must be a Kenyan phone number such as 0712345678 or +254712345678"
+	case "tax_class":
+		return "must be standard, zero_rated or exempt"
+	case "exists":
+		return "no " + fe.Param() + " with this id"
+	}
+
+	return fmt.Sprintf("failed the %s check", fe.Tag())
+}
+
+func describeType(t reflect.Type) string {
+	switch t.Kind() {
+	case reflect.String:
+		return "text"
+	case reflect.Bool:
+		return "true or false"
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return "a whole number"
+	case reflect.Float32, reflect.Float64:
+		return "a number"
+	case reflect.Slice, reflect.Array:
+		return "a list"
+	case reflect.Map, reflect.Struct:
+		return "an object"
+	}
+
+	return "a " + t.String()
+}
@@ -0,0 +1,215 @@
+// Package validation adds the store's own rules to the validator gin binds
+// request bodies with, and turns binding errors into per-field reasons that
+// are safe to show to clients.
+//
+// The custom tags are:
+//
+//	money         a positive amount of Ksh
+//	ke_phone      a Kenyan mobile number, 07XX/01XX or +254 form
+//	tax_class     one of the tax classes in package tax
+//	exists=kind   the UUID of a live category, product or variant
+package validation
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"regexp"
+	"strings"
+	"sync"
+
+	"github.com/Oj-washingtone/savannah-store/internal/apperr"
+	"github.com/Oj-washingtone/savannah-store/internal/model"
+	"github.com/Oj-washingtone/savannah-store/internal/tax"
+	"github.com/gin-gonic/gin/binding"
+	"github.com/go-playground/validator/v10"
+	"github.com/google/uuid"
+)
+
+// existsKinds are what exists= can look ids up as.
+var existsKinds = map[string]bool{
+	"category": true,
+	"product":  true,
+	"variant":  true,
+}
+
+// Lookups report, by the kind in the exists tag, whether a live row has an
+// id.
+type Lookups map[string]func(ctx context.Context, id uuid.UUID) (bool, error)
+
+var kenyanPhone = regexp.MustCompile(`^(?:\+?254|0)([17]\d{8})$`)
+
+// embedded names embedded structs in field paths. JSON flattens their fields
+// into the outer object, so the name is dropped from the paths again.
+const embedded = "~"
+
+var (
+	registerOnce sync.Once
+
+	lookupMu sync.RWMutex
+	lookups  Lookups
+)
+
+// Register adds the custom tags to gin's validator and makes field errors use
+// the json names of fields. lookups answer the exists tag, and must have one
+// for each kind it takes, otherwise Register panics. It is safe to call more
+// than once, the latest lookups being used.
+func Register(kinds Lookups) {
+	for kind := range existsKinds {
+		if kinds[kind] == nil {
+			panic("validation: no lookup for exists=" + kind)
+		}
+	}
+	for kind := range kinds {
+		if !existsKinds[kind] {
+			panic("validation: exists takes no kind " + kind)
+		}
+	}
+
+	lookupMu.Lock()
+	lookups = kinds
+	lookupMu.Unlock()
+
+	registerOnce.Do(func() {
+		v, ok := binding.Validator.Engine().(*validator.Validate)
+		if !ok {
+			return
+		}
+
+		v.RegisterTagNameFunc(func(field reflect.StructField) string {
+			name := strings.SplitN(field.Tag.Get("json"), ",", 2)[0]
+			if name == "-" {
+				return ""
+			}
+			if name == "" && field.Anonymous {
+				return embedded
+			}
+			if name == "" {
+				return field.Name
+			}
+			return name
+		})
+
+		// the rules are fixed, so an error here is a bug
+		must(v.RegisterValidation("money", validMoney))
+		must(v.RegisterValidation("ke_phone", validKenyanPhone))
+		must(v.RegisterValidationCtx("exists", validExists))
+		must(v.RegisterValidation("tax_class", validTaxClass))
+	})
+}
+
+func must(err error) {
+	if err != nil {
+		panic(err)
+	}
+}
+
+func validMoney(fl validator.FieldLevel) bool {
+	switch fl.Field().Kind() {
+	case reflect.Int, reflect.Int32, reflect.Int64:
+		return fl.Field().Int() > 0
+	}
+
+	return false
+}
+
+func validKenyanPhone(fl validator.FieldLevel) bool {
+	return fl.Field().Kind() == reflect.String && kenyanPhone.MatchString(strings.ReplaceAll(fl.Field().String(), " ", ""))
+}
+
+func validTaxClass(fl validator.FieldLevel) bool {
+	return fl.Field().Kind() == reflect.String && tax.ValidClass(model.TaxClass(fl.Field().String()))
+}
+
+// NormalizeKenyanPhone gives a phone number that passes ke_phone in the
+// +254XXXXXXXXX form SMS providers expect.
+func NormalizeKenyanPhone(phone string) string {
+	match := kenyanPhone.FindStringSubmatch(strings.ReplaceAll(phone, " ", ""))
+	if match == nil {
+		return phone
+	}
+
+	return "+254" + match[1]
+}
+
+// lookupErrKey holds where validExists puts a failed lookup while Struct
+// runs.
+type lookupErrKey struct{}
+
+// Struct checks the binding rules of body, a pointer to a struct. The exists
+// lookups run with ctx, which should be the request's. A lookup that fails,
+// rather than finding nothing, gives an apperr error for the failure instead
+// of a field error, as the field may well be right.
+func Struct(ctx context.Context, body any) error {
+	v, ok := binding.Validator.Engine().(*validator.Validate)
+	if !ok {
+		return binding.Validator.ValidateStruct(body)
+	}
+
+	var lookupErr error
+	err := v.StructCtx(context.WithValue(ctx, lookupErrKey{}, &lookupErr), body)
+
+	if lookupErr != nil {
+		return lookupErr
+	}
+
+	return err
+}
+
+// validExists looks the id up with the registered lookup for the tag's kind.
+func validExists(ctx context.Context, fl validator.FieldLevel) bool {
+	var id uuid.UUID
+
+	switch value := fl.Field().Interface().(type) {
+	case uuid.UUID:
+		id = value
+	case string:
+		parsed, err := uuid.Parse(value)
+		if err != nil {
+			return false
+		}
+		id = parsed
+	default:
+		return false
+	}
+
+	lookupMu.RLock()
+	exists := lookups[fl.Param()]
+	lookupMu.RUnlock()
+
+	if exists == nil {
+		return failedLookup(ctx, apperr.Wrap(apperr.KindInternal, "Failed to check the request", fmt.Errorf("exists has no kind %q", fl.Param())))
+	}
+
+	found, err := exists(ctx, id)
+	if err != nil {
+		return failedLookup(ctx, lookupError(err))
+	}
+
+	return found
+}
+
+// failedLookup leaves err for Struct and lets the field pass. Outside of
+// Struct there is nowhere to leave it, so the field fails instead.
+func failedLookup(ctx context.Context, err error) bool {
+	lookupErr, ok := ctx.Value(lookupErrKey{}).(*error)
+	if !ok {
+		return false
+	}
+
+	if *lookupErr == nil {
+		*lookupErr = err
+	}
+
+	return true
+}
+
+// lookupError keeps the kind a repository gave a failed lookup, such as
+// unavailable for a database that is down, and makes anything else internal.
+func lookupError(err error) error {
+	if apperr.KindOf(err) != apperr.KindInternal {
+		return err
+	}
+
+	return apperr.Wrap(apperr.KindInternal, "Failed to check the request", err)
+}
@@ -0,0 +1,216 @@
+package validation
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/Oj-washingtone/savannah-store/internal/apperr"
+	"github.com/google/uuid"
+)
+
+type requestKey struct{}
+
+var (
+	liveCategory = uuid.New()
+	brokenLookup = uuid.New()
+	downLookup   = uuid.New()
+)
+
+// testLookups find liveCategory only, fail for brokenLookup and report the
+// database down for downLookup. They fail for a context without requestKey,
+// so a lookup made without the request's context shows up.
+func testLookups() Lookups {
+	lookup := func(ctx context.Context, id uuid.UUID) (bool, error) {
+		if ctx.Value(requestKey{}) == nil {
+			return false, errors.New("not the request's context")
+		}
+
+		switch id {
+		case brokenLookup:
+			return false, errors.New("relation does not exist")
+		case downLookup:
+			return false, apperr.Unavailable("The database is unavailable", errors.New("connection refused"))
+		}
+
+		return id == liveCategory, nil
+	}
+
+	return Lookups{"category": lookup, "product": lookup, "variant": lookup}
+}
+
+func requestContext() context.Context {
+	return context.WithValue(context.Background(), requestKey{}, true)
+}
+
+type address struct {
+	Phone string `json:"phone" binding:"required,ke_phone"`
+}
+
+type body struct {
+	Price    int64     `json:"price" binding:"money"`
+	TaxClass string    `json:"taxClass,omitempty" binding:"omitempty,tax_class"`
+	Category uuid.UUID `json:"categoryId" binding:"exists=category"`
+	Names    []string  `json:"names" binding:"omitempty,dive,max=3"`
+	address
+}
+
+func validBody() body {
+	return body{Price: 100, Category: liveCategory, address: address{Phone: "0712345678"}}
+}
+
+func TestStruct(t *testing.T) {
+	Register(testLookups())
+
+	for _, tc := range []struct {
+		name   string
+		change func(b *body)
+		fields []FieldError
+	}{
+		{"valid", func(b *body) {}, nil},
+		{"a shilling", func(b *body) { b.Price = 1 }, nil},
+		{"zero price", func(b *body) { b.Price = 0 }, []FieldError{{"price", "must be a positive amount in Ksh"}}},
+		{"negative price", func(b *body) { b.Price = -5 }, []FieldError{{"price", "must be a positive amount in Ksh"}}},
+		{"phone in +254 form", func(b *body) { b.Phone = "+254 112 345 678" }, nil},
+		{"phone without the plus", func(b *body) { b.Phone = "254712345678" }, nil},
+		{"landline", func(b *body) { b.Phone = "0201234567" }, []FieldError{{"phone", "must be a Kenyan phone number such as 0712345678 or +254712345678"}}},
+		{"short phone", func(b *body) { b.Phone = "071234567" }, []FieldError{{"phone", "must be a Kenyan phone number such as 0712345678 or +254712345678"}}},
+		{"missing phone", func(b *body) { b.Phone = "" }, []FieldError{{"phone", "is required"}}},
+		{"zero rated", func(b *body) { b.TaxClass = "zero_rated" }, nil},
+		{"unknown tax class", func(b *body) { b.TaxClass = "luxury" }, []FieldError{{"taxClass", "must be standard, zero_rated or exempt"}}},
+		{"no such category", func(b *body) { b.Category = uuid.New() }, []FieldError{{"categoryId", "no category with this id"}}},
+		{"an item of a list", func(b *body) { b.Names = []string{"a", "long"} }, []FieldError{{"names[1]", "can be at most 3 characters"}}},
+		{
+			name:   "several fields",
+			change: func(b *body) { b.Price, b.Phone = 0, "12" },
+			fields: []FieldError{{"price", "must be a positive amount in Ksh"}, {"phone", "must be a Kenyan phone number such as 0712345678 or +254712345678"}},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			b := validBody()
+			tc.change(&b)
+
+			err := Struct(requestContext(), &b)
+			if tc.fields == nil {
+				if err != nil {
+					t.Fatalf("want no error, got %v", err)
+				}
+				return
+			}
+
+			fields, problem := Fields(err)
+			if !slices.Equal(fields, tc.fields) {
+				t.Errorf("got fields %v, want %v", fields, tc.fields)
+			}
+
+			if problem != "Some fields are missing or invalid" {
+				t.Errorf("got problem %q", problem)
+			}
+		})
+	}
+}
+
+func TestFailedLookup(t *testing.T) {
+	Register(testLookups())
+
+	for _, tc := range []struct {
+		name string
+		ctx  context.Context
+		id   uuid.UUID
+		kind apperr.Kind
+	}{
+		{"database down", requestContext(), downLookup, apperr.KindUnavailable},
+		{"unexpected error", requestContext(), brokenLookup, apperr.KindInternal},
+		{"not the request's context", context.Background(), liveCategory, apperr.KindInternal},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			b := validBody()
+			b.Category = tc.id
+
+			err := Struct(tc.ctx, &b)
+
+			var appErr *apperr.Error
+			if !errors.As(err, &appErr) {
+				t.Fatalf("want an apperr error, got %v", err)
+			}
+
+			if appErr.Kind != tc.kind {
+				t.Errorf("got kind %v, want %v", appErr.Kind, tc.kind)
+			}
+		})
+	}
+}
+
+func TestUnknownExistsKind(t *testing.T) {
+	Register(testLookups())
+
+	b := struct {
+		Brand uuid.UUID `json:"brandId" binding:"exists=brand"`
+	}{Brand: liveCategory}
+
+	if kind := apperr.KindOf(Struct(requestContext(), &b)); kind != apperr.KindInternal {
+		t.Errorf("got kind %v, want internal", kind)
+	}
+}
+
+func TestRegisterChecksKinds(t *testing.T) {
+	for name, lookups := range map[string]Lookups{
+		"missing a kind": {"category": testLookups()["category"], "product": testLookups()["product"]},
+		"an extra kind":  func() Lookups { l := testLookups(); l["brand"] = l["category"]; return l }(),
+	} {
+		t.Run(name, func(t *testing.T) {
+			defer func() {
+				if recover() == nil {
+					t.Error("Register did not panic")
+				}
+			}()
+
+			Register(lookups)
+		})
+	}
+}
+
+func TestFieldsOfBodyErrors(t *testing.T) {
+	for _, tc := range []struct {
+		name    string
+		json    string
+		fields  []FieldError
+		problem string
+	}{
+		{"wrong type", `{"price": "ten"}`, []FieldError{{"price", "must be a whole number"}}, "Some fields are missing or invalid"},
+		{"not an object", `[1]`, nil, "Request body must be a JSON object"},
+		{"broken JSON", `{"price": `, nil, "Request body is not valid JSON"},
+		{"empty", ``, nil, "Request body is empty"},
+		{"bad UUID", `{"categoryId": "nope"}`, nil, "Request body has a value in the wrong format"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			var b body
+			err := json.NewDecoder(strings.NewReader(tc.json)).Decode(&b)
+			if err == nil {
+				t.Fatal("the body decoded")
+			}
+
+			fields, problem := Fields(err)
+			if !slices.Equal(fields, tc.fields) || problem != tc.problem {
+				t.Errorf("got %v and %q, want %v and %q", fields, problem, tc.fields, tc.problem)
+			}
+		})
+	}
+}
+
+func TestNormalizeKenyanPhone(t *testing.T) {
+	for phone, want := range map[string]string{
+		"0712345678":       "+254712345678",
+		"0112 345 678":     "+254112345678",
+		"254712345678":     "+254712345678",
+		"+254 712 345 678": "+254712345678",
+		"not a phone":      "not a phone",
+	} {
+		if got := NormalizeKenyanPhone(phone); got != want {
+			t.Errorf("NormalizeKenyanPhone(%q) = %q, want %q", phone, got, want)
+		}
+	}
+}
@@ -0,0 +1,5 @@
+DROP INDEX IF EXISTS idx_cart_items_updated_at;
+
+DROP TABLE IF EXISTS cart_reminders;
+
+ALTER TABLE users DROP COLUMN cart_reminders_opt_out;
@@ -0,0 +1,15 @@
+ALTER TABLE users ADD COLUMN cart_reminders_opt_out BOOLEAN NOT NULL DEFAULT false;
+
+CREATE TABLE IF NOT EXISTS cart_reminders (
+    id UUID PRIMARY KEY,
+    cart_id UUID NOT NULL REFERENCES carts (id) ON DELETE CASCADE,
+    user_id UUID NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    channels VARCHAR(50) NOT NULL,
+    sent_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    created_at TIMESTAMPTZ DEFAULT now(),
+    updated_at TIMESTAMPTZ DEFAULT now()
+);
+
+CREATE INDEX idx_cart_reminders_cart_id ON cart_reminders (cart_id);
+
+CREATE INDEX idx_cart_items_updated_at ON cart_items (updated_at);
@@ -0,0 +1,5 @@
+DROP TABLE IF EXISTS wishlist_items;
+
+DROP TABLE IF EXISTS wishlists;
+
+ALTER TABLE users DROP COLUMN wishlist_alerts_opt_out;
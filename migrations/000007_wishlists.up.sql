@@ -0,0 +1,31 @@
+ALTER TABLE users ADD COLUMN wishlist_alerts_opt_out BOOLEAN NOT NULL DEFAULT false;
+
+CREATE TABLE IF NOT EXISTS wishlists (
+    id UUID PRIMARY KEY,
+    user_id UUID NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    name VARCHAR(100) NOT NULL,
+    is_default BOOLEAN NOT NULL DEFAULT false,
+    share_token VARCHAR(64) UNIQUE,
+    created_at TIMESTAMPTZ DEFAULT now(),
+    updated_at TIMESTAMPTZ DEFAULT now(),
+    deleted_at TIMESTAMPTZ NULL
+);
+
+CREATE TABLE IF NOT EXISTS wishlist_items (
+    id UUID PRIMARY KEY,
+    wishlist_id UUID NOT NULL REFERENCES wishlists (id) ON DELETE CASCADE,
+    product_id UUID NOT NULL REFERENCES products (id) ON DELETE CASCADE,
+    price_at_add BIGINT NOT NULL,
+    created_at TIMESTAMPTZ DEFAULT now(),
+    updated_at TIMESTAMPTZ DEFAULT now(),
+    UNIQUE (wishlist_id, product_id)
+);
+
+CREATE INDEX idx_wishlists_user_id ON wishlists (user_id);
+
+CREATE UNIQUE INDEX idx_wishlists_default_per_user ON wishlists (user_id)
+WHERE
+    is_default
+    AND deleted_at IS NULL;
+
+CREATE INDEX idx_wishlist_items_product_id ON wishlist_items (product_id);
@@ -0,0 +1,11 @@
+ALTER TABLE order_items DROP COLUMN discount;
+
+ALTER TABLE orders DROP COLUMN subtotal, DROP COLUMN discount_total;
+
+DROP TABLE IF EXISTS promotion_redemptions;
+
+DROP TABLE IF EXISTS cart_promotions;
+
+DROP TABLE IF EXISTS promotions;
+
+DROP TYPE IF EXISTS promotion_scope;
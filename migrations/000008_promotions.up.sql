@@ -0,0 +1,56 @@
+CREATE TYPE promotion_scope AS ENUM ('cart', 'product', 'category');
+
+CREATE TABLE IF NOT EXISTS promotions (
+    id UUID PRIMARY KEY,
+    code VARCHAR(50) UNIQUE,
+    description TEXT NOT NULL DEFAULT '',
+    scope promotion_scope NOT NULL,
+    product_id UUID REFERENCES products (id) ON DELETE CASCADE,
+    category_id UUID REFERENCES categories (id) ON DELETE CASCADE,
+    type discount_type NOT NULL,
+    value BIGINT NOT NULL CHECK (value > 0),
+    min_spend BIGINT NOT NULL DEFAULT 0,
+    starts_at TIMESTAMPTZ NULL,
+    ends_at TIMESTAMPTZ NULL,
+    usage_limit INTEGER NULL,
+    per_customer_limit INTEGER NULL,
+    stackable BOOLEAN NOT NULL DEFAULT false,
+    active BOOLEAN NOT NULL DEFAULT true,
+    created_at TIMESTAMPTZ DEFAULT now(),
+    updated_at TIMESTAMPTZ DEFAULT now(),
+    deleted_at TIMESTAMPTZ NULL,
+    CHECK (
+        (scope = 'cart' AND product_id IS NULL AND category_id IS NULL)
+        OR (scope = 'product' AND product_id IS NOT NULL)
+        OR (scope = 'category' AND category_id IS NOT NULL)
+    ),
+    CHECK (type <> 'percentage' OR value <= 100)
+);
+
+CREATE TABLE IF NOT EXISTS cart_promotions (
+    cart_id UUID NOT NULL REFERENCES carts (id) ON DELETE CASCADE,
+    promotion_id UUID NOT NULL REFERENCES promotions (id) ON DELETE CASCADE,
+    created_at TIMESTAMPTZ DEFAULT now(),
+    PRIMARY KEY (cart_id, promotion_id)
+);
+
+CREATE TABLE IF NOT EXISTS promotion_redemptions (
+    id UUID PRIMARY KEY,
+    promotion_id UUID NOT NULL REFERENCES promotions (id) ON DELETE RESTRICT,
+    order_id UUID NOT NULL REFERENCES orders (id) ON DELETE CASCADE,
+    user_id UUID NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    code VARCHAR(50),
+    amount BIGINT NOT NULL,
+    created_at TIMESTAMPTZ DEFAULT now(),
+    updated_at TIMESTAMPTZ DEFAULT now()
+);
+
+CREATE INDEX idx_promotion_redemptions_promotion_id ON promotion_redemptions (promotion_id, user_id);
+
+CREATE INDEX idx_promotion_redemptions_order_id ON promotion_redemptions (order_id);
+
+ALTER TABLE orders
+ADD COLUMN subtotal BIGINT NOT NULL DEFAULT 0,
+ADD COLUMN discount_total BIGINT NOT NULL DEFAULT 0;
+
+ALTER TABLE order_items ADD COLUMN discount BIGINT NOT NULL DEFAULT 0;
@@ -0,0 +1,9 @@
+DROP INDEX IF EXISTS idx_discounts_product_id;
+
+ALTER TABLE discounts
+DROP CONSTRAINT discounts_window_check,
+DROP CONSTRAINT discounts_value_check,
+DROP COLUMN priority,
+DROP COLUMN ends_at,
+DROP COLUMN starts_at,
+ALTER COLUMN value TYPE INTEGER;
@@ -0,0 +1,18 @@
+ALTER TABLE discounts
+ALTER COLUMN value TYPE BIGINT,
+ADD COLUMN starts_at TIMESTAMPTZ NULL,
+ADD COLUMN ends_at TIMESTAMPTZ NULL,
+ADD COLUMN priority INTEGER NOT NULL DEFAULT 0,
+ADD CONSTRAINT discounts_value_check CHECK (
+    value > 0
+    AND (type <> 'percentage' OR value <= 100)
+),
+ADD CONSTRAINT discounts_window_check CHECK (
+    starts_at IS NULL
+    OR ends_at IS NULL
+    OR ends_at > starts_at
+);
+
+CREATE INDEX idx_discounts_product_id ON discounts (product_id)
+WHERE
+    deleted_at IS NULL;
@@ -0,0 +1,7 @@
+ALTER TABLE orders DROP COLUMN prices_include_tax, DROP COLUMN tax_total;
+
+ALTER TABLE order_items DROP COLUMN tax, DROP COLUMN tax_rate, DROP COLUMN tax_class;
+
+ALTER TABLE products DROP COLUMN tax_class;
+
+DROP TYPE IF EXISTS tax_class;
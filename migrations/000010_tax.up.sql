@@ -0,0 +1,12 @@
+CREATE TYPE tax_class AS ENUM ('standard', 'zero_rated', 'exempt');
+
+ALTER TABLE products ADD COLUMN tax_class tax_class NOT NULL DEFAULT 'standard';
+
+ALTER TABLE order_items
+ADD COLUMN tax_class tax_class NOT NULL DEFAULT 'standard',
+ADD COLUMN tax_rate INTEGER NOT NULL DEFAULT 0,
+ADD COLUMN tax BIGINT NOT NULL DEFAULT 0;
+
+ALTER TABLE orders
+ADD COLUMN tax_total BIGINT NOT NULL DEFAULT 0,
+ADD COLUMN prices_include_tax BOOLEAN NOT NULL DEFAULT true;
@@ -0,0 +1,8 @@
+DROP INDEX IF EXISTS idx_orders_invoice_number;
+
+ALTER TABLE orders
+DROP COLUMN IF EXISTS invoice_number,
+DROP COLUMN IF EXISTS payment_reference,
+DROP COLUMN IF EXISTS paid_at;
+
+DROP TABLE IF EXISTS invoice_counter;
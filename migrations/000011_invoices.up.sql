@@ -0,0 +1,15 @@
+-- a single row counter, incremented in the same transaction that marks an
+-- order paid so invoice numbers are sequential with no gaps
+CREATE TABLE invoice_counter (
+    id BOOLEAN PRIMARY KEY DEFAULT true CHECK (id),
+    last_number BIGINT NOT NULL DEFAULT 0
+);
+
+INSERT INTO invoice_counter (id, last_number) VALUES (true, 0);
+
+ALTER TABLE orders
+ADD COLUMN paid_at TIMESTAMPTZ,
+ADD COLUMN payment_reference TEXT,
+ADD COLUMN invoice_number BIGINT;
+
+CREATE UNIQUE INDEX idx_orders_invoice_number ON orders(invoice_number) WHERE invoice_number IS NOT NULL;
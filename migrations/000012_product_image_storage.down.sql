@@ -0,0 +1,9 @@
+DROP INDEX IF EXISTS idx_product_images_primary;
+
+ALTER TABLE product_images
+DROP COLUMN IF EXISTS position,
+DROP COLUMN IF EXISTS size_bytes,
+DROP COLUMN IF EXISTS content_type,
+DROP COLUMN IF EXISTS storage_key,
+ALTER COLUMN is_primary DROP NOT NULL,
+ALTER COLUMN is_primary DROP DEFAULT;
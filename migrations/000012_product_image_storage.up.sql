@@ -0,0 +1,12 @@
+UPDATE product_images SET is_primary = false WHERE is_primary IS NULL;
+
+ALTER TABLE product_images
+ALTER COLUMN is_primary SET DEFAULT false,
+ALTER COLUMN is_primary SET NOT NULL,
+ADD COLUMN storage_key TEXT,
+ADD COLUMN content_type TEXT,
+ADD COLUMN size_bytes BIGINT NOT NULL DEFAULT 0,
+ADD COLUMN position INTEGER NOT NULL DEFAULT 0;
+
+-- at most one primary image per product
+CREATE UNIQUE INDEX idx_product_images_primary ON product_images(product_id) WHERE is_primary AND deleted_at IS NULL;
@@ -0,0 +1,5 @@
+DROP TABLE IF EXISTS product_image_variants;
+
+ALTER TABLE product_images
+DROP COLUMN IF EXISTS height,
+DROP COLUMN IF EXISTS width;
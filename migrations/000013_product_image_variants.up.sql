@@ -0,0 +1,18 @@
+ALTER TABLE product_images
+ADD COLUMN width INTEGER NOT NULL DEFAULT 0,
+ADD COLUMN height INTEGER NOT NULL DEFAULT 0;
+
+CREATE TABLE product_image_variants (
+    id UUID PRIMARY KEY,
+    image_id UUID NOT NULL REFERENCES product_images (id) ON DELETE CASCADE,
+    name TEXT NOT NULL,
+    content_type TEXT NOT NULL,
+    width INTEGER NOT NULL,
+    height INTEGER NOT NULL,
+    size_bytes BIGINT NOT NULL,
+    storage_key TEXT NOT NULL,
+    url TEXT NOT NULL,
+    created_at TIMESTAMPTZ DEFAULT now(),
+    updated_at TIMESTAMPTZ DEFAULT now(),
+    UNIQUE (image_id, name, content_type)
+);
@@ -0,0 +1,11 @@
+ALTER TABLE order_items DROP COLUMN IF EXISTS variant_id;
+
+DROP INDEX IF EXISTS idx_cart_items_variant_id;
+ALTER TABLE cart_items DROP COLUMN IF EXISTS variant_id;
+
+ALTER TABLE product_images DROP COLUMN IF EXISTS variant_id;
+
+DROP TABLE IF EXISTS product_variant_values;
+DROP TABLE IF EXISTS product_variants;
+DROP TABLE IF EXISTS product_option_values;
+DROP TABLE IF EXISTS product_options;
@@ -0,0 +1,54 @@
+CREATE TABLE product_options (
+    id UUID PRIMARY KEY,
+    product_id UUID NOT NULL REFERENCES products (id) ON DELETE CASCADE,
+    name VARCHAR(50) NOT NULL,
+    position INTEGER NOT NULL DEFAULT 0,
+    created_at TIMESTAMPTZ DEFAULT now(),
+    updated_at TIMESTAMPTZ DEFAULT now(),
+    UNIQUE (product_id, name)
+);
+
+CREATE TABLE product_option_values (
+    id UUID PRIMARY KEY,
+    option_id UUID NOT NULL REFERENCES product_options (id) ON DELETE CASCADE,
+    value VARCHAR(50) NOT NULL,
+    position INTEGER NOT NULL DEFAULT 0,
+    UNIQUE (option_id, value)
+);
+
+-- price is an override of the product price, NULL to use the product price
+CREATE TABLE product_variants (
+    id UUID PRIMARY KEY,
+    product_id UUID NOT NULL REFERENCES products (id) ON DELETE CASCADE,
+    sku VARCHAR(64),
+    price BIGINT CHECK (price >= 0),
+    stock INTEGER NOT NULL DEFAULT 0 CHECK (stock >= 0),
+    position INTEGER NOT NULL DEFAULT 0,
+    created_at TIMESTAMPTZ DEFAULT now(),
+    updated_at TIMESTAMPTZ DEFAULT now(),
+    deleted_at TIMESTAMPTZ NULL
+);
+
+CREATE UNIQUE INDEX idx_product_variants_sku ON product_variants (sku) WHERE sku IS NOT NULL AND deleted_at IS NULL;
+CREATE INDEX idx_product_variants_product_id ON product_variants (product_id);
+
+CREATE TABLE product_variant_values (
+    variant_id UUID NOT NULL REFERENCES product_variants (id) ON DELETE CASCADE,
+    option_value_id UUID NOT NULL REFERENCES product_option_values (id) ON DELETE RESTRICT,
+    PRIMARY KEY (variant_id, option_value_id)
+);
+
+-- every existing product becomes a single variant product
+INSERT INTO product_variants (id, product_id, stock)
+SELECT gen_random_uuid(), id, GREATEST(stock, 0) FROM products;
+
+ALTER TABLE product_images ADD COLUMN variant_id UUID REFERENCES product_variants (id) ON DELETE SET NULL;
+
+ALTER TABLE cart_items ADD COLUMN variant_id UUID REFERENCES product_variants (id) ON DELETE CASCADE;
+UPDATE cart_items ci SET variant_id = pv.id FROM product_variants pv WHERE pv.product_id = ci.product_id;
+ALTER TABLE cart_items ALTER COLUMN variant_id SET NOT NULL;
+CREATE INDEX idx_cart_items_variant_id ON cart_items (variant_id);
+
+ALTER TABLE order_items ADD COLUMN variant_id UUID REFERENCES product_variants (id) ON DELETE RESTRICT;
+UPDATE order_items oi SET variant_id = pv.id FROM product_variants pv WHERE pv.product_id = oi.product_id;
+ALTER TABLE order_items ALTER COLUMN variant_id SET NOT NULL;
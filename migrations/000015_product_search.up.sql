@@ -0,0 +1,15 @@
+CREATE EXTENSION IF NOT EXISTS pg_trgm;
+
+-- names weigh more than descriptions when ranking matches
+ALTER TABLE products
+ADD COLUMN search_vector tsvector GENERATED ALWAYS AS (
+    setweight(to_tsvector('english', coalesce(name, '')), 'A') ||
+    setweight(to_tsvector('english', coalesce(description, '')), 'B')
+) STORED;
+
+CREATE INDEX idx_products_search_vector ON products USING GIN (search_vector);
+
+-- trigram index for matching misspelt names
+CREATE INDEX idx_products_name_trgm ON products USING GIN (name gin_trgm_ops);
+
+CREATE INDEX idx_categories_parent_id ON categories (parent_id);
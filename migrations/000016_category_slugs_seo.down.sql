@@ -0,0 +1,8 @@
+ALTER TABLE products DROP COLUMN IF EXISTS meta_description, DROP COLUMN IF EXISTS meta_title;
+
+DROP INDEX IF EXISTS idx_categories_slug;
+
+ALTER TABLE categories
+DROP COLUMN IF EXISTS meta_description,
+DROP COLUMN IF EXISTS meta_title,
+DROP COLUMN IF EXISTS slug;
@@ -0,0 +1,26 @@
+ALTER TABLE categories
+ADD COLUMN slug VARCHAR(120),
+ADD COLUMN meta_title VARCHAR(100) NOT NULL DEFAULT '',
+ADD COLUMN meta_description VARCHAR(300) NOT NULL DEFAULT '';
+
+-- slugs for existing categories, names that slug the same get part of their
+-- id appended
+UPDATE categories c
+SET slug = CASE WHEN s.n = 1 THEN s.base ELSE s.base || '-' || left(c.id::text, 8) END
+FROM (
+    SELECT id, base, ROW_NUMBER() OVER (PARTITION BY base ORDER BY created_at, id) AS n
+    FROM (
+        SELECT id, created_at,
+            COALESCE(NULLIF(trim(BOTH '-' FROM regexp_replace(lower(name), '[^a-z0-9]+', '-', 'g')), ''), 'category') AS base
+        FROM categories
+    ) named
+) s
+WHERE s.id = c.id;
+
+ALTER TABLE categories ALTER COLUMN slug SET NOT NULL;
+
+CREATE UNIQUE INDEX idx_categories_slug ON categories (slug) WHERE deleted_at IS NULL;
+
+ALTER TABLE products
+ADD COLUMN meta_title VARCHAR(100) NOT NULL DEFAULT '',
+ADD COLUMN meta_description VARCHAR(300) NOT NULL DEFAULT '';
@@ -0,0 +1,8 @@
+DROP TABLE IF EXISTS product_slug_history;
+
+DROP INDEX IF EXISTS idx_products_sku;
+DROP INDEX IF EXISTS idx_products_slug;
+
+ALTER TABLE products
+DROP COLUMN IF EXISTS sku,
+DROP COLUMN IF EXISTS slug;
@@ -0,0 +1,32 @@
+ALTER TABLE products
+ADD COLUMN slug VARCHAR(120),
+ADD COLUMN sku VARCHAR(64);
+
+-- slugs for existing products, names that slug the same get part of their id
+-- appended
+UPDATE products p
+SET slug = CASE WHEN s.n = 1 THEN s.base ELSE s.base || '-' || left(p.id::text, 8) END
+FROM (
+    SELECT id, base, ROW_NUMBER() OVER (PARTITION BY base ORDER BY created_at, id) AS n
+    FROM (
+        SELECT id, created_at,
+            COALESCE(NULLIF(trim(BOTH '-' FROM regexp_replace(lower(name), '[^a-z0-9]+', '-', 'g')), ''), 'product') AS base
+        FROM products
+    ) named
+) s
+WHERE s.id = p.id;
+
+ALTER TABLE products ALTER COLUMN slug SET NOT NULL;
+
+CREATE UNIQUE INDEX idx_products_slug ON products (slug) WHERE deleted_at IS NULL;
+CREATE UNIQUE INDEX idx_products_sku ON products (sku) WHERE sku IS NOT NULL AND deleted_at IS NULL;
+
+-- old slugs of products, so links using them can be redirected to the
+-- current one
+CREATE TABLE product_slug_history (
+    slug VARCHAR(120) PRIMARY KEY,
+    product_id UUID NOT NULL REFERENCES products (id) ON DELETE CASCADE,
+    created_at TIMESTAMPTZ DEFAULT now()
+);
+
+CREATE INDEX idx_product_slug_history_product_id ON product_slug_history (product_id);
@@ -0,0 +1,15 @@
+ALTER TABLE products DROP CONSTRAINT IF EXISTS products_price_check;
+
+ALTER TABLE orders DROP CONSTRAINT IF EXISTS orders_amounts_check;
+
+ALTER TABLE order_items
+DROP CONSTRAINT IF EXISTS order_items_amounts_check,
+DROP CONSTRAINT IF EXISTS order_items_quantity_check;
+
+ALTER TABLE cart_items
+DROP CONSTRAINT IF EXISTS cart_items_price_check,
+DROP CONSTRAINT IF EXISTS cart_items_quantity_check,
+DROP CONSTRAINT IF EXISTS cart_items_cart_id_variant_id_key;
+
+ALTER TABLE order_items ALTER COLUMN price TYPE INTEGER;
+ALTER TABLE orders ALTER COLUMN total TYPE INTEGER;
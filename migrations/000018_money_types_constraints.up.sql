@@ -0,0 +1,45 @@
+-- amounts are in shillings, as BIGINT like products.price
+ALTER TABLE orders ALTER COLUMN total TYPE BIGINT;
+ALTER TABLE order_items ALTER COLUMN price TYPE BIGINT;
+
+-- a variant is in a cart once, with its quantity. Carts that got it twice
+-- keep the oldest line with the quantities added up
+DELETE FROM cart_items WHERE quantity <= 0;
+
+UPDATE cart_items ci
+SET quantity = d.quantity
+FROM (
+    SELECT (array_agg(id ORDER BY created_at, id))[1] AS keep, SUM(quantity) AS quantity
+    FROM cart_items
+    GROUP BY cart_id, variant_id
+    HAVING COUNT(*) > 1
+) d
+WHERE ci.id = d.keep;
+
+DELETE FROM cart_items ci
+USING (
+    SELECT cart_id, variant_id, (array_agg(id ORDER BY created_at, id))[1] AS keep
+    FROM cart_items
+    GROUP BY cart_id, variant_id
+    HAVING COUNT(*) > 1
+) d
+WHERE ci.cart_id = d.cart_id AND ci.variant_id = d.variant_id AND ci.id <> d.keep;
+
+ALTER TABLE cart_items
+ADD CONSTRAINT cart_items_cart_id_variant_id_key UNIQUE (cart_id, variant_id),
+ADD CONSTRAINT cart_items_quantity_check CHECK (quantity > 0),
+ADD CONSTRAINT cart_items_price_check CHECK (price >= 0);
+
+ALTER TABLE order_items
+ADD CONSTRAINT order_items_quantity_check CHECK (quantity > 0),
+ADD CONSTRAINT order_items_amounts_check CHECK (price >= 0 AND discount >= 0 AND tax >= 0);
+
+ALTER TABLE orders
+ADD CONSTRAINT orders_amounts_check CHECK (
+    subtotal >= 0
+    AND discount_total >= 0
+    AND tax_total >= 0
+    AND total >= 0
+);
+
+ALTER TABLE products ADD CONSTRAINT products_price_check CHECK (price >= 0);
@@ -0,0 +1,17 @@
+-- emails and text messages that failed to send, kept until they are retried
+-- successfully
+CREATE TABLE notification_outbox (
+    id UUID PRIMARY KEY,
+    channel VARCHAR(10) NOT NULL CHECK (channel IN ('email', 'sms')),
+    recipient VARCHAR(150) NOT NULL,
+    subject TEXT NOT NULL DEFAULT '',
+    body TEXT NOT NULL,
+    attachments JSONB NOT NULL DEFAULT '[]',
+    attempts INTEGER NOT NULL DEFAULT 1 CHECK (attempts > 0),
+    last_error TEXT NOT NULL DEFAULT '',
+    sent_at TIMESTAMPTZ NULL,
+    created_at TIMESTAMPTZ DEFAULT now(),
+    updated_at TIMESTAMPTZ DEFAULT now()
+);
+
+CREATE INDEX idx_notification_outbox_unsent ON notification_outbox (created_at) WHERE sent_at IS NULL;
@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS idx_discounts_start_alert_pending;
+ALTER TABLE discounts DROP COLUMN IF EXISTS start_alerted_at;
@@ -0,0 +1,8 @@
+-- when wishlist watchers were told a scheduled discount started. Discounts
+-- already running when they are saved are alerted on the spot, so only the
+-- ones still waiting to start are left NULL
+ALTER TABLE discounts ADD COLUMN start_alerted_at TIMESTAMPTZ NULL;
+
+UPDATE discounts SET start_alerted_at = now() WHERE starts_at IS NULL OR starts_at <= now();
+
+CREATE INDEX idx_discounts_start_alert_pending ON discounts (starts_at) WHERE start_alerted_at IS NULL AND deleted_at IS NULL;
@@ -0,0 +1,18 @@
+-- what an item was called when it was ordered, so invoices describe it the
+-- same way after the product is renamed or deleted. variant_name is the
+-- variant's option values, such as "Red / XL", and empty for a simple product
+ALTER TABLE order_items
+    ADD COLUMN product_name VARCHAR(100) NOT NULL DEFAULT '',
+    ADD COLUMN variant_name TEXT NOT NULL DEFAULT '';
+
+UPDATE order_items oi SET product_name = p.name FROM products p WHERE p.id = oi.product_id;
+
+UPDATE order_items oi SET variant_name = labels.label
+FROM (
+    SELECT pvv.variant_id, string_agg(pov.value, ' / ' ORDER BY po.position, po.created_at) AS label
+    FROM product_variant_values pvv
+    JOIN product_option_values pov ON pov.id = pvv.option_value_id
+    JOIN product_options po ON po.id = pov.option_id
+    GROUP BY pvv.variant_id
+) labels
+WHERE labels.variant_id = oi.variant_id;
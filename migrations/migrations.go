@@ -0,0 +1,12 @@
+// Package migrations holds the SQL migrations of the database, embedded so
+// the server can apply them itself, see database.NewMigrator.
+//
+// Each change is a pair of files, NNNNNN_name.up.sql making it and
+// NNNNNN_name.down.sql undoing it, numbered in the order they are applied.
+// `savannah migrate create name` adds the next pair.
+package migrations
+
+import "embed"
+
+//go:embed *.sql
+var FS embed.FS
@@ -1 +1,472 @@
+//go:build e2e
+
 package test
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+)
+
+// stock adds a Kitchen category with a Jiko in it, priced Ksh 1,000 with ten
+// in stock, saving their ids as category and product.
+var stock = []step{
+	{
+		name:   "create category",
+		as:     "admin",
+		method: http.MethodPost,
+		path:   "/api/products/categories/create",
+		body:   `{"name": "Kitchen"}`,
+		status: http.StatusCreated,
+		save:   map[string]string{"category": "data.id"},
+	},
+	{
+		name:   "create product",
+		as:     "admin",
+		method: http.MethodPost,
+		path:   "/api/products/create",
+		body:   `{"name": "Jiko", "sku": "JIKO-1", "categoryId": "{{category}}", "price": 1000, "stock": 10}`,
+		status: http.StatusCreated,
+		save:   map[string]string{"product": "data.id"},
+	},
+}
+
+// inCart puts two Jikos in the customer's cart.
+var inCart = step{
+	name:   "add to cart",
+	as:     "customer",
+	method: http.MethodPost,
+	path:   "/api/cart/create",
+	body:   `{"product_id": "{{product}}", "quantity": 2}`,
+	status: http.StatusCreated,
+	save:   map[string]string{"item": "data.id"},
+}
+
+func TestScenarios(t *testing.T) {
+	scenarios := []struct {
+		name  string
+		steps []step
+	}{
+		{
+			name: "health",
+			steps: []step{
+				{
+					name:   "alive",
+					method: http.MethodGet,
+					path:   "/healthz",
+					status: http.StatusOK,
+					check: func(t *testing.T, h *harness, r *response) {
+						if r.Header.Get("X-Request-ID") == "" {
+							t.Fatal("no X-Request-ID header")
+						}
+					},
+				},
+				{
+					name:   "ready",
+					method: http.MethodGet,
+					path:   "/readyz",
+					status: http.StatusOK,
+					check: func(t *testing.T, h *harness, r *response) {
+						wantValue(t, r, "data.ready", true)
+						wantValue(t, r, "data.database", "ok")
+						wantValue(t, r, "data.migration.dirty", false)
+						wantValue(t, r, "data.migration.state", "ok")
+						wantValue(t, r, "data.outboxLagSeconds", float64(0))
+					},
+				},
+			},
+		},
+		{
+			name: "catalog",
+			steps: append(stock[:len(stock):len(stock)],
+				step{
+					name:   "get product",
+					method: http.MethodGet,
+					path:   "/api/products/{{product}}",
+					status: http.StatusOK,
+					check: func(t *testing.T, h *harness, r *response) {
+						wantValue(t, r, "data.name", "Jiko")
+						wantValue(t, r, "data.price", float64(1000))
+						wantValue(t, r, "data.slug", "jiko")
+					},
+				},
+				step{
+					name:   "get product by slug",
+					method: http.MethodGet,
+					path:   "/api/products/by-slug/jiko",
+					status: http.StatusOK,
+				},
+				step{
+					name:   "get product by SKU",
+					method: http.MethodGet,
+					path:   "/api/products/by-sku/JIKO-1",
+					status: http.StatusOK,
+				},
+				step{
+					name:   "list category products",
+					method: http.MethodGet,
+					path:   "/api/products/categories/{{category}}/products",
+					status: http.StatusOK,
+					check: func(t *testing.T, h *harness, r *response) {
+						wantValue(t, r, "data.0.name", "Jiko")
+					},
+				},
+				step{
+					name:   "search",
+					method: http.MethodGet,
+					path:   "/api/products/search?q=jiko",
+					status: http.StatusOK,
+					check: func(t *testing.T, h *harness, r *response) {
+						wantValue(t, r, "data.products.0.name", "Jiko")
+						wantValue(t, r, "pagination.limit", float64(20))
+					},
+				},
+				step{
+					name:   "SKU taken",
+					as:     "admin",
+					method: http.MethodPost,
+					path:   "/api/products/create",
+					body:   `{"name": "Sufuria", "sku": "JIKO-1", "categoryId": "{{category}}", "price": 500}`,
+					status: http.StatusConflict,
+				},
+				step{
+					name:   "missing category",
+					as:     "admin",
+					method: http.MethodPost,
+					path:   "/api/products/create",
+					body:   `{"name": "Sufuria", "categoryId": "00000000-0000-0000-0000-000000000000", "price": 500}`,
+					status: http.StatusBadRequest,
+					check: func(t *testing.T, h *harness, r *response) {
+						wantValue(t, r, "fields.0.field", "categoryId")
+					},
+				},
+			),
+		},
+		{
+			name: "cart",
+			steps: append(stock[:len(stock):len(stock)],
+				inCart,
+				step{
+					name:   "add again",
+					as:     "customer",
+					method: http.MethodPost,
+					path:   "/api/cart/create",
+					body:   `{"product_id": "{{product}}", "quantity": 1}`,
+					status: http.StatusConflict,
+				},
+				step{
+					name:   "add none",
+					as:     "customer",
+					method: http.MethodPost,
+					path:   "/api/cart/create",
+					body:   `{"product_id": "{{product}}", "quantity": 0}`,
+					status: http.StatusBadRequest,
+				},
+				step{
+					name:   "signed out",
+					method: http.MethodGet,
+					path:   "/api/cart/",
+					status: http.StatusUnauthorized,
+				},
+				step{
+					name:   "view cart",
+					as:     "customer",
+					method: http.MethodGet,
+					path:   "/api/cart/",
+					status: http.StatusOK,
+					check: func(t *testing.T, h *harness, r *response) {
+						wantValue(t, r, "summary.total", float64(2000))
+					},
+				},
+				step{
+					name:   "change quantity",
+					as:     "customer",
+					method: http.MethodPatch,
+					path:   "/api/cart/update/quantity/{{item}}",
+					body:   `{"quantity": 3}`,
+					status: http.StatusOK,
+				},
+				step{
+					name:   "view changed cart",
+					as:     "customer",
+					method: http.MethodGet,
+					path:   "/api/cart/",
+					status: http.StatusOK,
+					check: func(t *testing.T, h *harness, r *response) {
+						wantValue(t, r, "summary.total", float64(3000))
+					},
+				},
+				step{
+					name:   "remove item",
+					as:     "customer",
+					method: http.MethodDelete,
+					path:   "/api/cart/remove/{{item}}",
+					status: http.StatusOK,
+				},
+				step{
+					name:   "view emptied cart",
+					as:     "customer",
+					method: http.MethodGet,
+					path:   "/api/cart/",
+					status: http.StatusOK,
+					check: func(t *testing.T, h *harness, r *response) {
+						wantValue(t, r, "summary.total", float64(0))
+					},
+				},
+			),
+		},
+		{
+			name: "checkout",
+			steps: append(stock[:len(stock):len(stock)],
+				inCart,
+				step{
+					name:   "place order",
+					as:     "customer",
+					method: http.MethodPost,
+					path:   "/api/orders/create",
+					status: http.StatusCreated,
+					save:   map[string]string{"order": "data.id"},
+					check: func(t *testing.T, h *harness, r *response) {
+						wantValue(t, r, "data.status", "pending")
+						wantValue(t, r, "data.total", float64(2000))
+
+						customer := h.users["customer"]
+
+						h.sms.waitFor(t, customer.Phone, "received")
+						email := h.email.waitFor(t, customer.Email, "Your order has been received")
+						h.email.waitFor(t, adminEmail, "New Order Created")
+
+						if len(email.Attachments) != 1 || email.Attachments[0].ContentType != "application/pdf" {
+							t.Fatalf("order confirmation has %d attachments, want the pro forma invoice", len(email.Attachments))
+						}
+					},
+				},
+				step{
+					name:   "order again",
+					as:     "customer",
+					method: http.MethodPost,
+					path:   "/api/orders/create",
+					status: http.StatusNotFound,
+				},
+				step{
+					name:   "pro forma invoice",
+					as:     "customer",
+					method: http.MethodGet,
+					path:   "/api/me/orders/{{order}}/invoice.pdf",
+					status: http.StatusOK,
+					check: func(t *testing.T, h *harness, r *response) {
+						if !strings.HasPrefix(string(r.Body), "%PDF") {
+							t.Fatalf("invoice is not a PDF")
+						}
+					},
+				},
+				step{
+					name:   "someone else's invoice",
+					as:     "admin",
+					method: http.MethodGet,
+					path:   "/api/me/orders/{{order}}/invoice.pdf",
+					status: http.StatusNotFound,
+				},
+				step{
+					name:   "customer records payment",
+					as:     "customer",
+					method: http.MethodPost,
+					path:   "/api/orders/{{order}}/payments",
+					body:   `{"paymentReference": "QJK3XYZ123"}`,
+					status: http.StatusForbidden,
+				},
+				step{
+					name:   "record payment",
+					as:     "admin",
+					method: http.MethodPost,
+					path:   "/api/orders/{{order}}/payments",
+					body:   `{"paymentReference": "QJK3XYZ123"}`,
+					status: http.StatusOK,
+					check: func(t *testing.T, h *harness, r *response) {
+						wantValue(t, r, "data.paid", true)
+						wantValue(t, r, "data.invoiceNumber", float64(1))
+
+						h.email.waitFor(t, h.users["customer"].Email, "Receipt for your order")
+					},
+				},
+				step{
+					name:   "record payment again",
+					as:     "admin",
+					method: http.MethodPost,
+					path:   "/api/orders/{{order}}/payments",
+					body:   `{"paymentReference": "QJK3XYZ123"}`,
+					status: http.StatusConflict,
+				},
+			),
+		},
+		{
+			name: "admin",
+			steps: append(stock[:len(stock):len(stock)],
+				step{
+					name:   "signed out",
+					method: http.MethodGet,
+					path:   "/api/promotions/",
+					status: http.StatusUnauthorized,
+				},
+				step{
+					name:   "customer creates promotion",
+					as:     "customer",
+					method: http.MethodPost,
+					path:   "/api/promotions/",
+					body:   `{"code": "KARIBU10", "scope": "cart", "type": "percentage", "value": 10}`,
+					status: http.StatusForbidden,
+				},
+				step{
+					name:   "create promotion",
+					as:     "admin",
+					method: http.MethodPost,
+					path:   "/api/promotions/",
+					body:   `{"code": "KARIBU10", "scope": "cart", "type": "percentage", "value": 10}`,
+					status: http.StatusCreated,
+					save:   map[string]string{"promotion": "data.id"},
+				},
+				inCart,
+				step{
+					name:   "apply coupon",
+					as:     "customer",
+					method: http.MethodPost,
+					path:   "/api/cart/coupons",
+					body:   `{"code": "KARIBU10"}`,
+					status: http.StatusOK,
+					check: func(t *testing.T, h *harness, r *response) {
+						wantValue(t, r, "data.discountTotal", float64(200))
+						wantValue(t, r, "data.total", float64(1800))
+					},
+				},
+				step{
+					name:   "create discount",
+					as:     "admin",
+					method: http.MethodPost,
+					path:   "/api/discounts/",
+					body:   `{"productId": "{{product}}", "type": "fixed", "value": 250}`,
+					status: http.StatusCreated,
+				},
+				step{
+					name:   "discounted product",
+					method: http.MethodGet,
+					path:   "/api/products/{{product}}",
+					status: http.StatusOK,
+					check: func(t *testing.T, h *harness, r *response) {
+						wantValue(t, r, "data.discountedPrice", float64(750))
+					},
+				},
+				step{
+					name:   "cart repriced",
+					as:     "customer",
+					method: http.MethodGet,
+					path:   "/api/cart/",
+					status: http.StatusOK,
+					check: func(t *testing.T, h *harness, r *response) {
+						wantValue(t, r, "summary.lines.0.price", float64(750))
+						wantValue(t, r, "summary.subtotal", float64(1500))
+						wantValue(t, r, "summary.notices.0", "Jiko: the price is now Ksh.750 (was Ksh.1000 when you added it)")
+					},
+				},
+				step{
+					name:   "list discounts",
+					as:     "admin",
+					method: http.MethodGet,
+					path:   "/api/discounts/?productId={{product}}",
+					status: http.StatusOK,
+					check: func(t *testing.T, h *harness, r *response) {
+						wantValue(t, r, "data.0.value", float64(250))
+						wantValue(t, r, "pagination.limit", float64(10))
+					},
+				},
+				step{
+					name:   "customer deletes category",
+					as:     "customer",
+					method: http.MethodDelete,
+					path:   "/api/products/categories/{{category}}",
+					status: http.StatusForbidden,
+				},
+				step{
+					name:   "delete promotion",
+					as:     "admin",
+					method: http.MethodDelete,
+					path:   "/api/promotions/{{promotion}}",
+					status: http.StatusOK,
+				},
+				step{
+					name:   "deleted promotion",
+					as:     "admin",
+					method: http.MethodGet,
+					path:   "/api/promotions/{{promotion}}",
+					status: http.StatusNotFound,
+				},
+			),
+		},
+		{
+			name: "wishlist alerts",
+			steps: append(stock[:len(stock):len(stock)],
+				step{
+					name:   "add size option",
+					as:     "admin",
+					method: http.MethodPost,
+					path:   "/api/products/{{product}}/options",
+					body:   `{"name": "Size", "values": ["S", "M"]}`,
+					status: http.StatusCreated,
+				},
+				step{
+					name:   "add small variant",
+					as:     "admin",
+					method: http.MethodPost,
+					path:   "/api/products/{{product}}/variants",
+					body:   `{"options": {"Size": "S"}, "stock": 0}`,
+					status: http.StatusCreated,
+					save:   map[string]string{"small": "data.id"},
+				},
+				step{
+					name:   "add medium variant",
+					as:     "admin",
+					method: http.MethodPost,
+					path:   "/api/products/{{product}}/variants",
+					body:   `{"options": {"Size": "M"}, "stock": 0}`,
+					status: http.StatusCreated,
+					save:   map[string]string{"medium": "data.id"},
+				},
+				step{
+					name:   "add to wishlist",
+					as:     "customer",
+					method: http.MethodPost,
+					path:   "/api/wishlists/default/items",
+					body:   `{"product_id": "{{product}}"}`,
+					status: http.StatusCreated,
+				},
+				step{
+					name:   "restock a variant",
+					as:     "admin",
+					method: http.MethodPatch,
+					path:   "/api/products/{{product}}/variants/{{small}}",
+					body:   `{"stock": 3}`,
+					status: http.StatusOK,
+					check: func(t *testing.T, h *harness, r *response) {
+						h.email.waitFor(t, h.users["customer"].Email, "Jiko is back in stock")
+					},
+				},
+				step{
+					name:   "cheaper variant",
+					as:     "admin",
+					method: http.MethodPatch,
+					path:   "/api/products/{{product}}/variants/{{medium}}",
+					body:   `{"price": 800}`,
+					status: http.StatusOK,
+					check: func(t *testing.T, h *harness, r *response) {
+						h.email.waitFor(t, h.users["customer"].Email, "Price drop on Jiko")
+					},
+				},
+			),
+		},
+	}
+
+	for _, scenario := range scenarios {
+		t.Run(scenario.name, func(t *testing.T) {
+			newHarness(t).run(t, scenario.steps)
+		})
+	}
+}
@@ -0,0 +1,557 @@
+//go:build e2e
+
+package test
+
+import (
+	"bytes"
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Oj-washingtone/savannah-store/internal/api"
+	"github.com/Oj-washingtone/savannah-store/internal/app"
+	"github.com/Oj-washingtone/savannah-store/internal/config"
+	"github.com/Oj-washingtone/savannah-store/internal/database"
+	"github.com/Oj-washingtone/savannah-store/internal/middleware"
+	"github.com/Oj-washingtone/savannah-store/internal/model"
+	"github.com/Oj-washingtone/savannah-store/internal/repocitory"
+	"github.com/Oj-washingtone/savannah-store/internal/service"
+	"github.com/Oj-washingtone/savannah-store/internal/storage"
+	"github.com/coreos/go-oidc/v3/oidc"
+	embeddedpostgres "github.com/fergusstrange/embedded-postgres"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// pool is the database every scenario runs against. TestMain creates it with
+// the migrations applied, and each harness empties it first.
+var pool *pgxpool.Pool
+
+// adminEmail is where the shop's copies of new orders go.
+const adminEmail = "shop@savannah.test"
+
+// TestMain starts an ephemeral Postgres, applies the migrations to it and
+// tears it down when the tests are done.
+//
+// By default Postgres is the embedded binary, downloaded once into
+// ~/.embedded-postgres-go. With E2E_DATABASE_URL set, a throwaway database is
+// created on that server instead and dropped afterwards.
+func TestMain(m *testing.M) {
+	gin.SetMode(gin.TestMode)
+
+	databaseURL, stop, err := startDatabase()
+	if err != nil {
+		log.Fatalf("Failed to start Postgres: %v", err)
+	}
+
+	code := func() int {
+		defer stop()
+
+		ctx := context.Background()
+
+		pool, err = pgxpool.New(ctx, databaseURL)
+		if err != nil {
+			log.Printf("Failed to connect to Postgres: %v", err)
+			return 1
+		}
+		defer pool.Close()
+
+		if err := database.AutoMigrate(ctx, pool); err != nil {
+			log.Printf("Failed to apply the migrations: %v", err)
+			return 1
+		}
+
+		return m.Run()
+	}()
+
+	os.Exit(code)
+}
+
+// startDatabase returns the URL of an empty database and a function that
+// gets rid of it.
+func startDatabase() (string, func(), error) {
+	if serverURL := os.Getenv("E2E_DATABASE_URL"); serverURL != "" {
+		return createDatabase(serverURL)
+	}
+
+	port, err := freePort()
+	if err != nil {
+		return "", nil, err
+	}
+
+	dir, err := os.MkdirTemp("", "savannah-e2e-")
+	if err != nil {
+		return "", nil, err
+	}
+
+	config := embeddedpostgres.DefaultConfig().
+		Port(port).
+		Database("savannah").
+		RuntimePath(filepath.Join(dir, "runtime")).
+		DataPath(filepath.Join(dir, "data")).
+		StartTimeout(time.Minute).
+		Logger(io.Discard)
+
+	postgres := embeddedpostgres.NewDatabase(config)
+
+	if err := postgres.Start(); err != nil {
+		os.RemoveAll(dir)
+		return "", nil, err
+	}
+
+	stop := func() {
+		if err := postgres.Stop(); err != nil {
+			log.Printf("Failed to stop Postgres: %v", err)
+		}
+		os.RemoveAll(dir)
+	}
+
+	return config.GetConnectionURL() + "?sslmode=disable", stop, nil
+}
+
+// createDatabase creates a database with a random name on the server at
+// serverURL, for servers that are already running.
+func createDatabase(serverURL string) (string, func(), error) {
+	ctx := context.Background()
+
+	server, err := pgxpool.New(ctx, serverURL)
+	if err != nil {
+		return "", nil, err
+	}
+
+	name := "savannah_e2e_" + strings.ReplaceAll(uuid.NewString(), "-", "")
+
+	if _, err := server.Exec(ctx, "CREATE DATABASE "+name); err != nil {
+		server.Close()
+		return "", nil, err
+	}
+
+	stop := func() {
+		if _, err := server.Exec(ctx, "DROP DATABASE IF EXISTS "+name+" WITH (FORCE)"); err != nil {
+			log.Printf("Failed to drop %s: %v", name, err)
+		}
+		server.Close()
+	}
+
+	databaseURL, err := url.Parse(serverURL)
+	if err != nil {
+		stop()
+		return "", nil, err
+	}
+	databaseURL.Path = "/" + name
+
+	return databaseURL.String(), stop, nil
+}
+
+func freePort() (uint32, error) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return 0, err
+	}
+	defer listener.Close()
+
+	return uint32(listener.Addr().(*net.TCPAddr).Port), nil
+}
+
+// emptyDatabase deletes every row the migrations did not put there.
+func emptyDatabase(ctx context.Context) error {
+	rows, err := pool.Query(ctx, `
+		SELECT quote_ident(tablename) FROM pg_tables
+		WHERE schemaname = 'public' AND tablename NOT IN ('invoice_counter', 'schema_migrations')`)
+	if err != nil {
+		return err
+	}
+
+	var tables []string
+	for rows.Next() {
+		var table string
+		if err := rows.Scan(&table); err != nil {
+			return err
+		}
+		tables = append(tables, table)
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	_, err = pool.Exec(ctx, "TRUNCATE "+strings.Join(tables, ", ")+" CASCADE; UPDATE invoice_counter SET last_number = 0")
+	return err
+}
+
+// issuer signs ID tokens the way the Auth0 tenant does, with a key made up
+// for the test run.
+type issuer struct {
+	url      string
+	clientID string
+	key      *rsa.PrivateKey
+}
+
+func newIssuer(t *testing.T) *issuer {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return &issuer{url: "https://savannah.test/", clientID: "e2e", key: key}
+}
+
+// verifier accepts the tokens the issuer signs, standing in for
+// authenticator.NewVerifier.
+func (i *issuer) verifier() *oidc.IDTokenVerifier {
+	keys := &oidc.StaticKeySet{PublicKeys: []crypto.PublicKey{&i.key.PublicKey}}
+	return oidc.NewVerifier(i.url, keys, &oidc.Config{ClientID: i.clientID})
+}
+
+// token returns a signed ID token for the Auth0 user sub.
+func (i *issuer) token(t *testing.T, sub string) string {
+	now := time.Now()
+
+	header, _ := json.Marshal(map[string]string{"alg": "RS256", "typ": "JWT"})
+	claims, _ := json.Marshal(map[string]any{
+		"iss": i.url,
+		"aud": i.clientID,
+		"sub": sub,
+		"iat": now.Unix(),
+		"exp": now.Add(time.Hour).Unix(),
+	})
+
+	signed := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(claims)
+	digest := sha256.Sum256([]byte(signed))
+
+	signature, err := rsa.SignPKCS1v15(rand.Reader, i.key, crypto.SHA256, digest[:])
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return signed + "." + base64.RawURLEncoding.EncodeToString(signature)
+}
+
+type sentEmail struct {
+	To          string
+	Subject     string
+	Body        string
+	Attachments []service.EmailAttachment
+}
+
+// emailSink is an EmailSender that keeps what it is given.
+type emailSink struct {
+	mu   sync.Mutex
+	sent []sentEmail
+}
+
+func (s *emailSink) SendEmail(to, subject, body string, attachments ...service.EmailAttachment) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.sent = append(s.sent, sentEmail{To: to, Subject: subject, Body: body, Attachments: attachments})
+	return nil
+}
+
+// waitFor returns the first email to to whose subject starts with subject.
+// Notifications go out in the background, so it waits a while for one.
+func (s *emailSink) waitFor(t *testing.T, to, subject string) sentEmail {
+	t.Helper()
+
+	var found *sentEmail
+	eventually(t, func() bool {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+
+		for _, email := range s.sent {
+			if email.To == to && strings.HasPrefix(email.Subject, subject) {
+				found = &email
+				return true
+			}
+		}
+		return false
+	}, "no email to %s with subject %q", to, subject)
+
+	return *found
+}
+
+type sentSMS struct {
+	To      string
+	Message string
+}
+
+// smsSink is an SMSSender that keeps what it is given.
+type smsSink struct {
+	mu   sync.Mutex
+	sent []sentSMS
+}
+
+func (s *smsSink) SendSMS(to, message string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.sent = append(s.sent, sentSMS{To: to, Message: message})
+	return nil
+}
+
+// waitFor returns the first text to to that contains message.
+func (s *smsSink) waitFor(t *testing.T, to, message string) sentSMS {
+	t.Helper()
+
+	var found *sentSMS
+	eventually(t, func() bool {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+
+		for _, sms := range s.sent {
+			if sms.To == to && strings.Contains(sms.Message, message) {
+				found = &sms
+				return true
+			}
+		}
+		return false
+	}, "no SMS to %s containing %q", to, message)
+
+	return *found
+}
+
+// eventually fails the test if done does not return true within five
+// seconds.
+func eventually(t *testing.T, done func() bool, format string, args ...any) {
+	t.Helper()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for !done() {
+		if time.Now().After(deadline) {
+			t.Fatalf(format, args...)
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+}
+
+// harness is the store served over HTTP, backed by an empty database, with
+// a customer and an admin signed up.
+type harness struct {
+	router http.Handler
+	repos  *repocitory.Repositories
+	issuer *issuer
+	email  *emailSink
+	sms    *smsSink
+
+	// users by the name steps sign in as
+	users map[string]*model.User
+}
+
+func newHarness(t *testing.T) *harness {
+	t.Helper()
+
+	ctx := context.Background()
+
+	if err := emptyDatabase(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	h := &harness{
+		repos:  repocitory.NewRepositories(&database.DB{Pool: pool}),
+		issuer: newIssuer(t),
+		email:  &emailSink{},
+		sms:    &smsSink{},
+		users:  map[string]*model.User{},
+	}
+
+	cfg := config.Defaults()
+	cfg.Shop.AdminEmail = adminEmail
+
+	svc := app.NewServices(cfg, h.repos, storage.NewLocal(t.TempDir(), "/uploads"), h.email, h.sms)
+
+	handlers := app.NewHandlers(cfg, h.repos, svc)
+
+	router := gin.New()
+	router.Use(middleware.RequestID(), middleware.Recovery())
+	api.RegisterHealthRoutes(router, handlers.Health)
+	api.AppRoutes(router.Group("/api"), handlers, h.repos, h.issuer.verifier())
+	h.router = router
+
+	// let notifications still going out finish before the next harness
+	// empties the database
+	t.Cleanup(func() {
+		if err := svc.Background.Shutdown(ctx); err != nil {
+			t.Error(err)
+		}
+	})
+
+	h.signUp(t, "customer", model.CustomerRole)
+	h.signUp(t, "admin", model.AdminRole)
+
+	return h
+}
+
+// signUp creates a user with the role, known to steps by name.
+func (h *harness) signUp(t *testing.T, name string, role model.Roles) *model.User {
+	t.Helper()
+
+	user := &model.User{
+		Auth0Id: "auth0|" + name,
+		Name:    name,
+		Email:   name + "@savannah.test",
+		Role:    role,
+	}
+	user.ID = uuid.New()
+
+	ctx := context.Background()
+
+	if err := h.repos.Users.Create(ctx, user); err != nil {
+		t.Fatal(err)
+	}
+
+	// the phone number is added after sign up, as through /me/phone
+	user.Phone = "+2547" + strconv.Itoa(10000000+len(h.users))
+
+	if err := h.repos.Users.UpdatePhone(ctx, user.ID, user.Phone); err != nil {
+		t.Fatal(err)
+	}
+
+	h.users[name] = user
+	return user
+}
+
+// response is what a step got back.
+type response struct {
+	Status int
+	Header http.Header
+	Body   []byte
+
+	// JSON is the decoded body when it is JSON
+	JSON any
+}
+
+// get returns the value at a dotted path into the JSON body, such as
+// "data.id" or "data.lines.0.quantity", or nil if there is none.
+func (r *response) get(path string) any {
+	value := r.JSON
+
+	for _, key := range strings.Split(path, ".") {
+		switch v := value.(type) {
+		case map[string]any:
+			value = v[key]
+		case []any:
+			i, err := strconv.Atoi(key)
+			if err != nil || i < 0 || i >= len(v) {
+				return nil
+			}
+			value = v[i]
+		default:
+			return nil
+		}
+	}
+
+	return value
+}
+
+// step is one request of a scenario. {{name}} in the path or body is
+// replaced by a value an earlier step saved.
+type step struct {
+	name   string
+	as     string // the user to sign in as, or "" for none
+	method string
+	path   string
+	body   string
+	status int
+
+	// save keeps values from the response for later steps, by JSON path
+	save map[string]string
+
+	check func(t *testing.T, h *harness, r *response)
+}
+
+// run sends the steps in order, stopping at the first that fails.
+func (h *harness) run(t *testing.T, steps []step) {
+	t.Helper()
+
+	vars := map[string]string{}
+
+	for _, s := range steps {
+		var pairs []string
+		for name, value := range vars {
+			pairs = append(pairs, "{{"+name+"}}", value)
+		}
+		expand := strings.NewReplacer(pairs...).Replace
+
+		r := h.do(t, s.as, s.method, expand(s.path), expand(s.body))
+
+		if r.Status != s.status {
+			t.Fatalf("%s: %s %s responded %d, want %d\n%s", s.name, s.method, s.path, r.Status, s.status, r.Body)
+		}
+
+		for name, path := range s.save {
+			value := r.get(path)
+			if value == nil {
+				t.Fatalf("%s: nothing at %s in\n%s", s.name, path, r.Body)
+			}
+			vars[name] = fmt.Sprint(value)
+		}
+
+		if s.check != nil {
+			s.check(t, h, r)
+		}
+	}
+}
+
+// do sends a request to the router, signed in as the named user.
+func (h *harness) do(t *testing.T, as, method, path, body string) *response {
+	t.Helper()
+
+	var reader io.Reader
+	if body != "" {
+		reader = bytes.NewBufferString(body)
+	}
+
+	req := httptest.NewRequest(method, path, reader)
+	if body != "" {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	if as != "" {
+		user, ok := h.users[as]
+		if !ok {
+			t.Fatalf("no user %q", as)
+		}
+		req.Header.Set("Authorization", "Bearer "+h.issuer.token(t, user.Auth0Id))
+	}
+
+	rec := httptest.NewRecorder()
+	h.router.ServeHTTP(rec, req)
+
+	r := &response{Status: rec.Code, Header: rec.Header(), Body: rec.Body.Bytes()}
+
+	if strings.HasPrefix(rec.Header().Get("Content-Type"), "application/json") {
+		if err := json.Unmarshal(r.Body, &r.JSON); err != nil {
+			t.Fatalf("%s %s: invalid JSON: %v\n%s", method, path, err, r.Body)
+		}
+	}
+
+	return r
+}
+
+// wantValue fails the test unless the response has want at path. Numbers
+// are compared as JSON decodes them, so pass float64.
+func wantValue(t *testing.T, r *response, path string, want any) {
+	t.Helper()
+
+	if got := r.get(path); got != want {
+		t.Fatalf("%s = %v (%T), want %v (%T)\n%s", path, got, got, want, want, r.Body)
+	}
+}